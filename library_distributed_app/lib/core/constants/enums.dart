@@ -55,7 +55,7 @@ enum SortOrder { ascending, descending }
 
 enum BookStatus {
   available('Có sẵn'),
-  borrowed('Đang mượn'),
+  borrowed('Đang được mượn'),
   damaged('Bị hỏng');
 
   final String text;
@@ -65,7 +65,7 @@ enum BookStatus {
     switch (value) {
       case 'Có sẵn':
         return BookStatus.available;
-      case 'Đang mượn':
+      case 'Đang được mượn':
         return BookStatus.borrowed;
       case 'Bị hỏng':
         return BookStatus.damaged;
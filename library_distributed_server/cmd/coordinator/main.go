@@ -17,6 +17,11 @@
 // @host localhost:8080
 // @BasePath /
 //
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and JWT token.
+//
 // @schemes http https
 package main
 
@@ -31,8 +36,11 @@ import (
 	"time"
 
 	"library_distributed_server/internal/auth"
+	"library_distributed_server/internal/cache"
 	"library_distributed_server/internal/config"
 	"library_distributed_server/internal/distributed"
+	"library_distributed_server/internal/handlers"
+	"library_distributed_server/internal/membership"
 	"library_distributed_server/internal/models"
 	"library_distributed_server/pkg/database"
 	"library_distributed_server/pkg/utils"
@@ -46,6 +54,8 @@ import (
 
 type CoordinatorHandler struct {
 	coordinator *distributed.TwoPhaseCommitCoordinator
+	onboarding  *distributed.OnboardingManager
+	relocation  *distributed.RelocationManager
 }
 
 type TransferBookRequest struct {
@@ -54,9 +64,29 @@ type TransferBookRequest struct {
 	ToSite      string `json:"toSite" binding:"required" example:"Q3" validate:"required"`         // Destination site ID
 }
 
-func NewCoordinatorHandler(coordinator *distributed.TwoPhaseCommitCoordinator) *CoordinatorHandler {
+type OnboardSiteRequest struct {
+	SiteID     string `json:"siteId" binding:"required" example:"Q5" validate:"required"`                                // New branch site ID (becomes its MaCN)
+	Name       string `json:"name" example:"Site_TV_Q5"`                                                                 // Site name in the topology
+	ServiceURL string `json:"serviceURL" binding:"required" example:"http://localhost:8085" validate:"required"`         // URL the site service will listen on
+	DBHost     string `json:"dbHost" binding:"required" example:"10.211.55.3" validate:"required"`                       // Database host of the new site
+	DBPort     int    `json:"dbPort" binding:"required" example:"1435" validate:"required"`                              // Database port of the new site
+	DBName     string `json:"dbName" binding:"required" example:"ThuVienQ5" validate:"required"`                         // Existing, empty database for the new site
+	TenCN      string `json:"tenCN" binding:"required" example:"Thư viện Quận 5" validate:"required"`                    // Branch name stored in CHINHANH
+	DiaChi     string `json:"diaChi" binding:"required" example:"789 Trần Hưng Đạo, Quận 5, TP.HCM" validate:"required"` // Branch address stored in CHINHANH
+	SourceSite string `json:"sourceSite" example:"Q1"`                                                                   // Replica to copy SACH/CHINHANH from (defaults to the first site)
+}
+
+type RelocateFragmentRequest struct {
+	Branch     string `json:"branch" binding:"required" example:"Q1" validate:"required"`     // Branch (MaCN) whose fragments are moved
+	TargetSite string `json:"targetSite" binding:"required" example:"Q3" validate:"required"` // Site that will store the fragments
+	Restart    bool   `json:"restart" example:"false"`                                        // Discard a partial copy on the target instead of resuming
+}
+
+func NewCoordinatorHandler(coordinator *distributed.TwoPhaseCommitCoordinator, onboarding *distributed.OnboardingManager, relocation *distributed.RelocationManager) *CoordinatorHandler {
 	return &CoordinatorHandler{
 		coordinator: coordinator,
+		onboarding:  onboarding,
+		relocation:  relocation,
 	}
 }
 
@@ -110,20 +140,178 @@ func (h *CoordinatorHandler) TransferBook(c *gin.Context) {
 	})
 }
 
+// OnboardSite handles POST /coordinator/sites/onboard
+// Brings a new branch site online while the system stays writable
+// @Summary Onboard a new branch site
+// @Description Create the schema on the new site's database, copy the replicated tables (SACH, CHINHANH) from an existing replica while capturing concurrent catalog changes, then register the site and its fragment predicate in the topology. Runs in the background; poll the returned job.
+// @Tags Coordinator
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body OnboardSiteRequest true "New site"
+// @Success 202 {object} distributed.OnboardingJob "Onboarding started"
+// @Failure 400 {object} models.ErrorResponse "Invalid request"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Access denied - Manager role required"
+// @Failure 409 {object} models.ErrorResponse "Cannot start onboarding"
+// @Router /coordinator/sites/onboard [post]
+func (h *CoordinatorHandler) OnboardSite(c *gin.Context) {
+	var req OnboardSiteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	job, err := h.onboarding.Start(distributed.OnboardingSpec{
+		Site: config.SiteConfig{
+			SiteID:     req.SiteID,
+			Name:       req.Name,
+			ServiceURL: req.ServiceURL,
+			Host:       req.DBHost,
+			Port:       req.DBPort,
+			Database:   req.DBName,
+		},
+		TenCN:      req.TenCN,
+		DiaChi:     req.DiaChi,
+		SourceSite: req.SourceSite,
+	})
+	if err != nil {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Failed to start onboarding",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetOnboardingJob handles GET /coordinator/sites/onboard/:jobId
+// @Summary Get onboarding job status
+// @Description Get the phase and progress of a site onboarding job
+// @Tags Coordinator
+// @Produce json
+// @Security BearerAuth
+// @Param jobId path string true "Onboarding job ID"
+// @Success 200 {object} distributed.OnboardingJob "Onboarding job"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Access denied - Manager role required"
+// @Failure 404 {object} models.ErrorResponse "Job not found"
+// @Router /coordinator/sites/onboard/{jobId} [get]
+func (h *CoordinatorHandler) GetOnboardingJob(c *gin.Context) {
+	job, err := h.onboarding.GetJob(c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Onboarding job not found",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// RelocateFragment handles POST /coordinator/fragments/relocate
+// Moves every horizontal fragment of a branch to another site
+// @Summary Relocate a branch's fragments to another site
+// @Description Copy the DOCGIA, QUYENSACH, PHIEUMUON, DATCHO, PHAT, GIAODICHPHAT, YEUCAUMUON and CHUYENTRA fragments of a branch to the target site in resumable chunks, verify row counts and checksums, switch the allocation in the topology and drop the source rows. Writes to the source fragments are blocked while the job runs. Starting a failed job again resumes from its last committed chunk. Runs in the background; poll the returned job.
+// @Tags Coordinator
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body RelocateFragmentRequest true "Fragment relocation"
+// @Success 202 {object} distributed.RelocationJob "Relocation started"
+// @Failure 400 {object} models.ErrorResponse "Invalid request"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Access denied - Manager role required"
+// @Failure 409 {object} models.ErrorResponse "Cannot start relocation"
+// @Router /coordinator/fragments/relocate [post]
+func (h *CoordinatorHandler) RelocateFragment(c *gin.Context) {
+	var req RelocateFragmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	job, err := h.relocation.Start(distributed.RelocationSpec{
+		Branch:     req.Branch,
+		TargetSite: req.TargetSite,
+		Restart:    req.Restart,
+	})
+	if err != nil {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "Failed to start relocation",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetRelocationJob handles GET /coordinator/fragments/relocate/:jobId
+// @Summary Get relocation job status
+// @Description Get the phase and per-relation progress of a fragment relocation job
+// @Tags Coordinator
+// @Produce json
+// @Security BearerAuth
+// @Param jobId path string true "Relocation job ID"
+// @Success 200 {object} distributed.RelocationJob "Relocation job"
+// @Failure 401 {object} models.ErrorResponse "Unauthorized"
+// @Failure 403 {object} models.ErrorResponse "Access denied - Manager role required"
+// @Failure 404 {object} models.ErrorResponse "Job not found"
+// @Router /coordinator/fragments/relocate/{jobId} [get]
+func (h *CoordinatorHandler) GetRelocationJob(c *gin.Context) {
+	job, err := h.relocation.GetJob(c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Relocation job not found",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
 func main() {
-	cfg, err := config.Load()
+	store, err := config.NewStore(loadCoordinatorConfig)
 	if err != nil {
 		log.Fatal("Failed to load configuration:", err)
 	}
+	cfg := store.Current()
 
-	// Override port for coordinator
-	cfg.Server.Port = 8080
+	// The coordinator has no database of its own; it only heartbeats the branch sites
+	members := membership.New(store, cfg.Coordinator.SiteID, nil)
+	database.GetPool().SetAvailabilityCheck(members.CheckAvailable)
+	members.Start()
 
 	authService := auth.NewAuthService(cfg.Auth.JWTSecret, cfg.Auth.TokenExpiry)
-	coordinator := distributed.NewTwoPhaseCommitCoordinator(cfg)
-	coordinatorHandler := NewCoordinatorHandler(coordinator)
+	// The coordinator has no cache of its own but tells the sites about the catalog writes it makes
+	publisher := cache.NewPublisher(store, cfg.Coordinator.SiteID, nil)
+	coordinator := distributed.NewTwoPhaseCommitCoordinator(store, publisher)
+	coordinatorHandler := NewCoordinatorHandler(coordinator, distributed.NewOnboardingManager(store, publisher), distributed.NewRelocationManager(store))
+
+	// Apply configuration reloads (SIGHUP or edits to .env / topology file) without a restart
+	store.Subscribe(func(old, new *config.Config) {
+		authService.UpdateSettings(new.Auth.JWTSecret, new.Auth.TokenExpiry)
+		for _, siteID := range config.ConnectionChanges(old, new) {
+			database.GetPool().Drain(siteID)
+		}
+		if old.Server != new.Server {
+			log.Println("Server listen port and timeouts take effect after a restart")
+		}
+	})
+	stopWatch := make(chan struct{})
+	store.Watch(stopWatch)
 
-	router := setupRouter(authService, coordinatorHandler)
+	router := setupRouter(authService, coordinatorHandler, handlers.NewMembershipHandler(members), handlers.RequireSiteSecret(store))
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
@@ -152,17 +340,38 @@ func main() {
 		log.Fatal("Coordinator forced to shutdown:", err)
 	}
 
+	close(stopWatch)
+	members.Stop()
 	database.GetPool().CloseAll()
 	log.Println("Coordinator exited")
 }
 
-func setupRouter(_ *auth.AuthService, coordinatorHandler *CoordinatorHandler) *gin.Engine {
+// loadCoordinatorConfig loads the configuration; the listen port comes from the
+// coordinator's service URL in the topology when one is declared
+func loadCoordinatorConfig() (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Coordinator.ServiceURL != "" {
+		cfg.Server.Port, err = cfg.Coordinator.ListenPort()
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine listen port: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+func setupRouter(authService *auth.AuthService, coordinatorHandler *CoordinatorHandler, membershipHandler *handlers.MembershipHandler, siteSecret gin.HandlerFunc) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
 
 	// Add CORS middleware
 	router.Use(utils.CORS())
 
+	// The coordinator has no user store; tokens issued by the sites are validated with the shared JWT secret
+	authHandler := handlers.NewAuthHandler(authService, nil)
+
 	// Swagger endpoint
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -182,14 +391,26 @@ func setupRouter(_ *auth.AuthService, coordinatorHandler *CoordinatorHandler) *g
 		})
 	})
 
+	// Membership - heartbeats between the coordinator and the branch sites
+	membershipGroup := router.Group("/membership")
+	{
+		membershipGroup.POST("/heartbeat", siteSecret, membershipHandler.Heartbeat)
+		membershipGroup.GET("", membershipHandler.GetMembership)
+	}
+
 	// Coordinator APIs (typically used by managers or system administrators)
 	coordinatorGroup := router.Group("/coordinator")
 	{
 		// Public endpoint for academic demonstration
 		coordinatorGroup.POST("/transfer-book", coordinatorHandler.TransferBook)
 
-		// Protected endpoints would require authentication in production
-		// For academic purposes, we keep it simple
+		// Site onboarding - add a branch without downtime
+		coordinatorGroup.POST("/sites/onboard", authHandler.RequireAuth(), authHandler.ValidateOperationAccess("MANAGE_TOPOLOGY"), coordinatorHandler.OnboardSite)            // QUANLY only
+		coordinatorGroup.GET("/sites/onboard/:jobId", authHandler.RequireAuth(), authHandler.ValidateOperationAccess("MANAGE_TOPOLOGY"), coordinatorHandler.GetOnboardingJob) // QUANLY only
+
+		// Fragment rebalancing - move a branch's fragments to another site
+		coordinatorGroup.POST("/fragments/relocate", authHandler.RequireAuth(), authHandler.ValidateOperationAccess("MANAGE_TOPOLOGY"), coordinatorHandler.RelocateFragment)       // QUANLY only
+		coordinatorGroup.GET("/fragments/relocate/:jobId", authHandler.RequireAuth(), authHandler.ValidateOperationAccess("MANAGE_TOPOLOGY"), coordinatorHandler.GetRelocationJob) // QUANLY only
 	}
 
 	return router
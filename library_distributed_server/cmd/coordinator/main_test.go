@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"library_distributed_server/internal/auth"
+	"library_distributed_server/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTopologyRoutesRequireManager(t *testing.T) {
+	authService := auth.NewAuthService("test-secret", time.Hour)
+	router := setupRouter(authService, NewCoordinatorHandler(nil, nil, nil), handlers.NewMembershipHandler(nil), func(c *gin.Context) { c.Next() })
+
+	token := func(role string) string {
+		t.Helper()
+		signed, err := authService.GenerateToken("1", "user", role, "Q1")
+		if err != nil {
+			t.Fatalf("GenerateToken failed: %v", err)
+		}
+		return "Bearer " + signed
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		want          int
+	}{
+		{"relocate without a token", http.MethodPost, "/coordinator/fragments/relocate", "", http.StatusUnauthorized},
+		{"relocate with a bad token", http.MethodPost, "/coordinator/fragments/relocate", "Bearer forged", http.StatusUnauthorized},
+		{"relocate as librarian", http.MethodPost, "/coordinator/fragments/relocate", token("THUTHU"), http.StatusForbidden},
+		{"relocate as manager", http.MethodPost, "/coordinator/fragments/relocate", token("QUANLY"), http.StatusBadRequest},
+		{"relocation status without a token", http.MethodGet, "/coordinator/fragments/relocate/job-1", "", http.StatusUnauthorized},
+		{"relocation status as librarian", http.MethodGet, "/coordinator/fragments/relocate/job-1", token("THUTHU"), http.StatusForbidden},
+		{"onboard without a token", http.MethodPost, "/coordinator/sites/onboard", "", http.StatusUnauthorized},
+		{"onboard as librarian", http.MethodPost, "/coordinator/sites/onboard", token("THUTHU"), http.StatusForbidden},
+		{"onboard as manager", http.MethodPost, "/coordinator/sites/onboard", token("QUANLY"), http.StatusBadRequest},
+		{"onboarding status without a token", http.MethodGet, "/coordinator/sites/onboard/job-1", "", http.StatusUnauthorized},
+		{"onboarding status as librarian", http.MethodGet, "/coordinator/sites/onboard/job-1", token("THUTHU"), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The body is not valid JSON, so a request passing the guard stops at binding
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader("not json"))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,465 @@
+// Package main Distributed Library Management System API
+//
+// This is a distributed library management system implemented in Go with horizontal fragmentation and full replication.
+// The system manages multiple library branches with distributed database operations.
+// A single binary serves every branch; the branch is selected with --site=<id> and
+// its database and listen address are looked up in the site topology file.
+//
+// @title Distributed Library Management System API - Branch Site
+// @version 1.0
+// @description This is a distributed library management system with horizontal fragmentation and full replication
+// @termsOfService N/A
+//
+// @contact.name API Support
+// @contact.email dev@library.com
+//
+// @license.name MIT
+// @license.url https://opensource.org/licenses/MIT
+//
+// @host localhost:8081
+// @BasePath /
+//
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Type "Bearer" followed by a space and JWT token.
+//
+// @schemes http https
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"library_distributed_server/internal/auth"
+	"library_distributed_server/internal/cache"
+	"library_distributed_server/internal/config"
+	"library_distributed_server/internal/handlers"
+	"library_distributed_server/internal/membership"
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/repository"
+	"library_distributed_server/pkg/database"
+	"library_distributed_server/pkg/utils"
+
+	sitedocs "library_distributed_server/docs/site" // docs is generated by Swag CLI, you have to import it.
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+func main() {
+	siteFlag := flag.String("site", "", "ID of the branch site to serve, as declared in the topology file")
+	flag.Parse()
+
+	store, err := config.NewStore(func() (*config.Config, error) {
+		return loadSiteConfig(*siteFlag)
+	})
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+	cfg := store.Current()
+	siteID := *siteFlag
+
+	sitedocs.SwaggerInfo.Title = fmt.Sprintf("Distributed Library Management System API - Site %s", siteID)
+	sitedocs.SwaggerInfo.Host = fmt.Sprintf("localhost:%d", cfg.Server.Port)
+
+	// Heartbeat the other sites and make connections to sites detected as down fail fast
+	members := membership.New(store, siteID, func(ctx context.Context) error {
+		connectionString, err := store.Current().GetConnectionString(siteID)
+		if err != nil {
+			return err
+		}
+		db, err := database.GetPool().GetConnection(siteID, connectionString)
+		if err != nil {
+			return err
+		}
+		return db.PingContext(ctx)
+	})
+	database.GetPool().SetAvailabilityCheck(members.CheckAvailable)
+	members.Start()
+
+	// Replicated reference data is cached in process; catalog writes at any site invalidate it
+	refCache := cache.New(store)
+	publisher := cache.NewPublisher(store, siteID, refCache)
+
+	// Bring the branch schema up to date; databases created from the SQL scripts lack later tables
+	if connectionString, err := cfg.GetConnectionString(siteID); err == nil {
+		if db, err := database.GetPool().GetConnection(siteID, connectionString); err != nil {
+			log.Printf("Schema not migrated, database unavailable: %v", err)
+		} else if err := database.Migrate(db, siteID); err != nil {
+			log.Printf("Schema migration failed: %v", err)
+		}
+	}
+
+	authService := auth.NewAuthService(cfg.Auth.JWTSecret, cfg.Auth.TokenExpiry)
+	userRepo := repository.NewUserRepository(store, siteID)
+	bookRepo := repository.NewBookRepository(store, siteID, refCache, publisher)
+	borrowRepo := repository.NewBorrowRepository(store, siteID)
+	readerRepo := repository.NewReaderRepository(store, siteID, refCache)
+	holdRepo := repository.NewHoldRepository(store, siteID)
+	fineRepo := repository.NewFineRepository(store, siteID)
+	policyRepo := repository.NewPolicyRepository(store, siteID)
+	illRepo := repository.NewILLRepository(store, siteID)
+	transferRepo := repository.NewTransferRepository(store, siteID)
+
+	// Build the catalog search index from the local replica and keep it current with catalog writes
+	indexCtx, cancelIndex := context.WithTimeout(context.Background(), cfg.Query.SiteTimeout)
+	if err := bookRepo.RebuildIndex(indexCtx); err != nil {
+		log.Printf("Search index not built, searches use LIKE patterns until it is: %v", err)
+	}
+	cancelIndex()
+	refCache.OnInvalidate(func(inv cache.Invalidation) {
+		ctx, cancel := context.WithTimeout(context.Background(), store.Current().Query.SiteTimeout)
+		defer cancel()
+		if err := bookRepo.RefreshIndex(ctx, inv); err != nil {
+			log.Printf("Failed to update search index: %v", err)
+		}
+	})
+	authHandler := handlers.NewAuthHandler(authService, userRepo)
+	bookHandler := handlers.NewBookHandler(bookRepo, siteID)
+	borrowHandler := handlers.NewBorrowHandler(borrowRepo, siteID)
+	readerHandler := handlers.NewReaderHandler(readerRepo, siteID)
+	holdHandler := handlers.NewHoldHandler(holdRepo, siteID)
+	fineHandler := handlers.NewFineHandler(fineRepo, siteID)
+	policyHandler := handlers.NewPolicyHandler(policyRepo, siteID)
+	illHandler := handlers.NewILLHandler(illRepo, siteID)
+	transferHandler := handlers.NewTransferHandler(transferRepo, siteID)
+	managerHandler := handlers.NewManagerHandler(bookRepo, borrowRepo, readerRepo, store)
+	statsHandler := handlers.NewStatsHandler(repository.NewStatsRepository(store), siteID)
+	membershipHandler := handlers.NewMembershipHandler(members)
+	cacheHandler := handlers.NewCacheHandler(refCache, publisher, store, siteID)
+
+	// Apply configuration reloads (SIGHUP or edits to .env / topology file) without a restart
+	store.Subscribe(func(old, new *config.Config) {
+		applyReload(authService, old, new)
+	})
+	stopWatch := make(chan struct{})
+	store.Watch(stopWatch)
+
+	// Expire holds left unclaimed past their pickup deadline and pass the copies on, and
+	// finish inter-library loan steps that committed on one branch only
+	stopSweep := make(chan struct{})
+	go sweep(func() time.Duration { return store.Current().Holds.SweepInterval }, func(ctx context.Context) {
+		expired, err := holdRepo.ExpireHolds(ctx)
+		if err != nil {
+			log.Printf("Hold sweep failed: %v", err)
+		} else if expired > 0 {
+			log.Printf("Hold sweep expired %d holds", expired)
+		}
+	}, stopSweep)
+	go sweep(func() time.Duration { return store.Current().ILL.ReconcileInterval }, func(ctx context.Context) {
+		repaired, err := illRepo.ReconcileRequests(ctx)
+		if err != nil {
+			log.Printf("Inter-library loan reconciliation failed: %v", err)
+		} else if repaired > 0 {
+			log.Printf("Inter-library loan reconciliation repaired %d requests", repaired)
+		}
+	}, stopSweep)
+
+	router := setupRouter(siteID, authHandler, bookHandler, borrowHandler, readerHandler, holdHandler, fineHandler, policyHandler, illHandler, transferHandler, managerHandler, statsHandler, membershipHandler, cacheHandler, handlers.RequireSiteSecret(store))
+	server := &http.Server{
+		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler:      router,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}
+	go func() {
+		log.Printf("Site %s server starting on port %d", siteID, cfg.Server.Port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Server failed to start:", err)
+		}
+	}()
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down server...")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Fatal("Server forced to shutdown:", err)
+	}
+	close(stopWatch)
+	close(stopSweep)
+	members.Stop()
+	database.GetPool().CloseAll()
+	log.Println("Server exited")
+}
+
+// loadSiteConfig loads the configuration for the branch site selected with --site.
+// The listen port comes from the site's service URL in the topology.
+func loadSiteConfig(siteID string) (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if siteID == "" {
+		return nil, fmt.Errorf("missing --site flag, expected one of %v", cfg.SiteIDs())
+	}
+	site, err := cfg.GetSite(siteID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --site flag: %w (expected one of %v)", err, cfg.SiteIDs())
+	}
+
+	cfg.Server.Port, err = site.ListenPort()
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine listen port: %w", err)
+	}
+	return cfg, nil
+}
+
+// sweep runs task at the interval returned by every until stop is closed, each run bounded
+// by the interval. The interval is read each round so reloads apply; 0 pauses the sweep.
+func sweep(every func() time.Duration, task func(ctx context.Context), stop <-chan struct{}) {
+	for {
+		interval := every()
+		wait := interval
+		if wait <= 0 {
+			wait = time.Minute
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(wait):
+		}
+		if interval <= 0 {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		task(ctx)
+		cancel()
+	}
+}
+
+// applyReload pushes a reloaded configuration into components that keep their own copy of settings
+func applyReload(authService *auth.AuthService, old, new *config.Config) {
+	authService.UpdateSettings(new.Auth.JWTSecret, new.Auth.TokenExpiry)
+
+	// Reconnect sites whose database settings changed; requests holding the old
+	// connection finish on it before it is closed
+	for _, siteID := range config.ConnectionChanges(old, new) {
+		database.GetPool().Drain(siteID)
+	}
+
+	if old.Server != new.Server {
+		log.Println("Server listen port and timeouts take effect after a restart")
+	}
+}
+
+func setupRouter(
+	siteID string,
+	authHandler *handlers.AuthHandler,
+	bookHandler *handlers.BookHandler,
+	borrowHandler *handlers.BorrowHandler,
+	readerHandler *handlers.ReaderHandler,
+	holdHandler *handlers.HoldHandler,
+	fineHandler *handlers.FineHandler,
+	policyHandler *handlers.PolicyHandler,
+	illHandler *handlers.ILLHandler,
+	transferHandler *handlers.TransferHandler,
+	managerHandler *handlers.ManagerHandler,
+	statsHandler *handlers.StatsHandler,
+	membershipHandler *handlers.MembershipHandler,
+	cacheHandler *handlers.CacheHandler,
+	siteSecret gin.HandlerFunc,
+) *gin.Engine {
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.Default()
+
+	// Add CORS middleware
+	router.Use(utils.CORS())
+
+	// Swagger endpoint
+	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	// @Summary Health check
+	// @Description Check if the service is running
+	// @Tags Health
+	// @Produce json
+	// @Success 200 {object} models.HealthResponse "Service is healthy"
+	// @Router /health [get]
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(200, models.HealthResponse{
+			Status:  "healthy",
+			Site:    siteID,
+			Time:    time.Now(),
+			Service: fmt.Sprintf("Site %s API", siteID),
+		})
+	})
+
+	// Membership - heartbeats between sites and the coordinator carry the site secret; the view is public, like /health
+	membershipGroup := router.Group("/membership")
+	{
+		membershipGroup.POST("/heartbeat", siteSecret, membershipHandler.Heartbeat)
+		membershipGroup.GET("", membershipHandler.GetMembership)
+	}
+
+	// Cache invalidations from the site or coordinator that wrote a replicated table
+	router.POST("/cache/invalidate", siteSecret, cacheHandler.Invalidate)
+
+	// Auth routes (public)
+	authGroup := router.Group("/auth")
+	{
+		authGroup.POST("/login", authHandler.Login)
+		authGroup.POST("/logout", authHandler.RequireAuth(), authHandler.Logout)
+		authGroup.GET("/profile", authHandler.RequireAuth(), authHandler.GetCurrentUser)
+	}
+
+	// Universal routes - role-based access control applied within handlers
+	// These routes can be accessed from any site based on user role and permissions
+
+	// Book operations - accessible to all authenticated users with appropriate role checks
+	booksGroup := router.Group("/books")
+	booksGroup.Use(authHandler.RequireAuth())
+	{
+		booksGroup.GET("", bookHandler.GetBooks)                             // All roles: view book catalog
+		booksGroup.GET("/:isbn", bookHandler.GetBookByISBN)                  // All roles: view book details
+		booksGroup.GET("/:isbn/available", bookHandler.GetAvailableBookCopy) // All roles: check availability
+		booksGroup.GET("/:isbn/holds", holdHandler.GetHoldQueue)             // All roles: hold queue across branches
+	}
+
+	// Book copies operations - site and role specific
+	bookCopiesGroup := router.Group("/book-copies")
+	bookCopiesGroup.Use(authHandler.RequireAuth())
+	{
+		bookCopiesGroup.GET("", bookHandler.GetBookCopies)                                                                            // All roles: view copies
+		bookCopiesGroup.POST("", authHandler.ValidateOperationAccess("CREATE_BOOK_COPY"), bookHandler.CreateQuyenSach)                // FR9: THUTHU only
+		bookCopiesGroup.GET("/:maQuyenSach", bookHandler.GetQuyenSach)                                                                // All roles: view copy details
+		bookCopiesGroup.PUT("/:maQuyenSach", authHandler.ValidateOperationAccess("UPDATE_BOOK_COPY"), bookHandler.UpdateQuyenSach)    // FR9: THUTHU only
+		bookCopiesGroup.DELETE("/:maQuyenSach", authHandler.ValidateOperationAccess("DELETE_BOOK_COPY"), bookHandler.DeleteQuyenSach) // FR9: THUTHU only
+	}
+
+	// Borrowing operations - THUTHU only at their site
+	borrowGroup := router.Group("/borrow")
+	borrowGroup.Use(authHandler.RequireAuth())
+	{
+		borrowGroup.POST("", authHandler.ValidateOperationAccess("BORROW_BOOK"), borrowHandler.CreateBorrow)            // FR2: THUTHU only
+		borrowGroup.PUT("/return/:id", authHandler.ValidateOperationAccess("RETURN_BOOK"), borrowHandler.ReturnBook)    // FR3: THUTHU only, at any branch
+		borrowGroup.POST("/batch", authHandler.ValidateOperationAccess("BORROW_BOOK"), borrowHandler.CreateBorrows)     // FR2: THUTHU only, several copies at once
+		borrowGroup.PUT("/return/batch", authHandler.ValidateOperationAccess("RETURN_BOOK"), borrowHandler.ReturnBooks) // FR3: THUTHU only, several copies at once
+		borrowGroup.PUT("/:id/renew", authHandler.ValidateOperationAccess("RENEW_BORROW"), borrowHandler.RenewBorrow)   // THUTHU only
+		borrowGroup.GET("", borrowHandler.GetBorrows)                                                                   // View borrows - role-based filtering in handler
+		borrowGroup.GET("/detailed", borrowHandler.GetBorrowRecordsWithDetails)                                         // Enhanced detailed view for Flutter
+	}
+
+	// Reader operations - site and role specific
+	readersGroup := router.Group("/readers")
+	readersGroup.Use(authHandler.RequireAuth())
+	{
+		readersGroup.GET("", readerHandler.GetAllDocGia)                                                                // Role-based: THUTHU sees local, QUANLY sees all
+		readersGroup.POST("", authHandler.ValidateOperationAccess("CREATE_READER"), readerHandler.CreateDocGia)         // FR8: THUTHU only
+		readersGroup.GET("/search", authHandler.ValidateOperationAccess("SEARCH_READERS"), readerHandler.SearchReaders) // Fuzzy search at every branch
+		readersGroup.GET("/:maDG", readerHandler.GetDocGia)                                                             // Role-based: THUTHU sees local, QUANLY sees all
+		readersGroup.PUT("/:maDG", authHandler.ValidateOperationAccess("UPDATE_READER"), readerHandler.UpdateDocGia)    // FR8: THUTHU only
+		readersGroup.DELETE("/:maDG", authHandler.ValidateOperationAccess("DELETE_READER"), readerHandler.DeleteDocGia) // FR8: THUTHU only
+		readersGroup.GET("/:maDG/holds", holdHandler.GetReaderHolds)                                                    // Holds placed for the reader
+		readersGroup.GET("/:maDG/fines", fineHandler.GetReaderFines)                                                    // Fines owed at every branch
+	}
+
+	// Hold queue for titles - placed at the reader's branch, served by returns at any branch
+	holdsGroup := router.Group("/holds")
+	holdsGroup.Use(authHandler.RequireAuth())
+	{
+		holdsGroup.POST("", authHandler.ValidateOperationAccess("PLACE_HOLD"), holdHandler.PlaceHold)           // THUTHU only
+		holdsGroup.GET("/shelf", holdHandler.GetHoldShelf)                                                      // Role-based: THUTHU sees local, QUANLY any site
+		holdsGroup.GET("/:maDC", holdHandler.GetHold)                                                           // All roles
+		holdsGroup.DELETE("/:maDC", authHandler.ValidateOperationAccess("CANCEL_HOLD"), holdHandler.CancelHold) // THUTHU only
+	}
+
+	// Overdue fines - assessed on late returns, settled at the branch that made the loan
+	finesGroup := router.Group("/fines")
+	finesGroup.Use(authHandler.RequireAuth())
+	{
+		finesGroup.GET("", fineHandler.GetFines)                                                                                    // Role-based: THUTHU sees local, QUANLY any site
+		finesGroup.GET("/report", authHandler.ValidateOperationAccess("SYSTEM_STATS"), fineHandler.GetFineReport)                   // QUANLY only
+		finesGroup.GET("/:maPhat", fineHandler.GetFine)                                                                             // All roles
+		finesGroup.POST("/:maPhat/payments", authHandler.ValidateOperationAccess("RECORD_FINE_PAYMENT"), fineHandler.RecordPayment) // THUTHU only
+		finesGroup.POST("/:maPhat/waivers", authHandler.ValidateOperationAccess("WAIVE_FINE"), fineHandler.WaiveFine)               // THUTHU only
+	}
+
+	// Circulation policies - defined by managers per branch and reader category, replicated to every site
+	policiesGroup := router.Group("/policies")
+	policiesGroup.Use(authHandler.RequireAuth())
+	{
+		policiesGroup.GET("", policyHandler.GetPolicies)                                                                   // All roles
+		policiesGroup.GET("/effective", policyHandler.GetEffectivePolicy)                                                  // All roles
+		policiesGroup.GET("/:maCS", policyHandler.GetPolicy)                                                               // All roles
+		policiesGroup.POST("", authHandler.ValidateOperationAccess("MANAGE_POLICIES"), policyHandler.CreatePolicy)         // QUANLY only
+		policiesGroup.PUT("/:maCS", authHandler.ValidateOperationAccess("MANAGE_POLICIES"), policyHandler.UpdatePolicy)    // QUANLY only
+		policiesGroup.DELETE("/:maCS", authHandler.ValidateOperationAccess("MANAGE_POLICIES"), policyHandler.DeletePolicy) // QUANLY only
+	}
+
+	// Inter-library loans - requested and lent by the reader's branch, shipped by the branch owning the copy
+	illGroup := router.Group("/ill")
+	illGroup.Use(authHandler.RequireAuth())
+	{
+		illGroup.POST("", authHandler.ValidateOperationAccess("REQUEST_ILL"), illHandler.RequestCopy)                        // THUTHU only
+		illGroup.GET("", illHandler.GetRequests)                                                                             // Role-based: THUTHU sees local, QUANLY any site
+		illGroup.GET("/:maYC", illHandler.GetRequest)                                                                        // All roles
+		illGroup.POST("/:maYC/ship", authHandler.ValidateOperationAccess("SHIP_ILL"), illHandler.ShipCopy)                   // THUTHU of the owning branch
+		illGroup.POST("/:maYC/arrive", authHandler.ValidateOperationAccess("RECEIVE_ILL"), illHandler.ReceiveCopy)           // THUTHU of the requesting branch
+		illGroup.POST("/:maYC/lend", authHandler.ValidateOperationAccess("BORROW_BOOK"), illHandler.LendCopy)                // THUTHU of the requesting branch
+		illGroup.POST("/:maYC/return", authHandler.ValidateOperationAccess("RETURN_BOOK"), illHandler.ReturnCopy)            // THUTHU of the requesting branch
+		illGroup.POST("/:maYC/receive-return", authHandler.ValidateOperationAccess("RECEIVE_ILL"), illHandler.ReceiveReturn) // THUTHU of the owning branch
+		illGroup.DELETE("/:maYC", authHandler.ValidateOperationAccess("CANCEL_ILL"), illHandler.CancelRequest)               // THUTHU of either branch
+	}
+
+	// Copies returned at another branch - shipped home by the branch that took the return, checked in at home
+	transfersGroup := router.Group("/transfers")
+	transfersGroup.Use(authHandler.RequireAuth())
+	{
+		transfersGroup.GET("/outgoing", transferHandler.GetOutgoingTransfers)                                                  // Role-based: THUTHU sees local, QUANLY any site
+		transfersGroup.GET("/incoming", transferHandler.GetIncomingTransfers)                                                  // Role-based: THUTHU sees local, QUANLY any site
+		transfersGroup.PUT("/check-in/:id", authHandler.ValidateOperationAccess("CHECK_IN_TRANSFER"), transferHandler.CheckIn) // THUTHU of the home branch
+	}
+
+	// Statistics operations - Enhanced for Flutter
+	statsGroup := router.Group("/stats")
+	statsGroup.Use(authHandler.RequireAuth())
+	{
+		statsGroup.GET("/readers", statsHandler.GetReadersWithStats)                                                     // Enhanced reader statistics
+		statsGroup.GET("/system", authHandler.ValidateOperationAccess("VIEW_SYSTEM_STATS"), statsHandler.GetSystemStats) // Manager-only system stats
+	}
+
+	// Manager-only operations - system-wide access
+	managerGroup := router.Group("/manager")
+	managerGroup.Use(authHandler.RequireAuth())
+	managerGroup.Use(authHandler.RequireRole("QUANLY")) // Only QUANLY can access these endpoints
+	{
+		// FR10 - Book catalog management with 2PC
+		managerGroup.POST("/books", managerHandler.CreateSach)   // Create book in catalog
+		managerGroup.GET("/books/:isbn", managerHandler.GetSach) // Get book from catalog
+
+		// FR7 - Distributed book search
+		managerGroup.GET("/books/search", managerHandler.SearchAvailableBooks) // System-wide book search
+
+		// FR6 - System statistics
+		managerGroup.GET("/statistics", managerHandler.GetSystemStats) // System-wide statistics
+
+		// FR11 - Global reader access
+		managerGroup.GET("/readers", managerHandler.GetAllReaders) // System-wide reader access
+
+		// Distributed data dictionary
+		managerGroup.GET("/catalog/fragments", managerHandler.GetFragmentCatalog) // Fragmentation and allocation schema
+
+		// Reference data cache (SACH, CHINHANH)
+		managerGroup.GET("/cache", cacheHandler.GetStats) // Hit-rate metrics of this site
+		managerGroup.DELETE("/cache", cacheHandler.Flush) // Flush every site's cache
+	}
+
+	// NOTE: Legacy site-specific routes with /site/{siteID} have been removed
+	// All operations now use role-based authentication with site info from JWT tokens
+	// This provides better security and eliminates the need for site parameters in URLs
+
+	return router
+}
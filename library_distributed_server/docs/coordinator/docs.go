@@ -189,7 +189,7 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Create a new book copy at the current site",
+                "description": "Create a new book copy at the current site. New copies are available (Có sẵn); tinhTrang may be left empty.",
                 "consumes": [
                     "application/json"
                 ],
@@ -274,7 +274,7 @@ const docTemplate = `{
                 }
             },
             "put": {
-                "description": "Update book copy information",
+                "description": "Change the status of a book copy of the current site. Only these moves may be made by hand: Có sẵn to Bị hỏng, Bị mất or Đã thanh lý; Bị hỏng to Đang sửa chữa or Đã thanh lý; Đang sửa chữa or Bị mất to Có sẵn or Đã thanh lý; Đang được mượn to Bị mất, which closes the loan. Loans, returns, holds and shipments make the other moves. A copy put back on the shelf is set aside for the first reader waiting for the title.",
                 "consumes": [
                     "application/json"
                 ],
@@ -322,6 +322,24 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
+                    "422": {
+                        "description": "Status change not allowed",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.ErrorResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/models.TransitionError"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
                     "500": {
                         "description": "Failed to update book copy",
                         "schema": {
@@ -372,7 +390,7 @@ const docTemplate = `{
         },
         "/books": {
             "get": {
-                "description": "Retrieve all books in the catalog",
+                "description": "Retrieve all books in the catalog, or search it by title, author or ISBN. Searches ignore Vietnamese diacritics, match word prefixes and return the best matches first with their relevance score. Filters narrow the books by author, branch with an available copy, copy status or whether copies exist, and return facet counts merged from all sites.",
                 "produces": [
                     "application/json"
                 ],
@@ -381,6 +399,60 @@ const docTemplate = `{
                 ],
                 "summary": "Get all books",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search text, e.g. lap trinh",
+                        "name": "query",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Author, repeatable; diacritics and case are ignored",
+                        "name": "author",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Branch (MaCN) holding an available copy, repeatable",
+                        "name": "branch",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Status (TinhTrang) of at least one copy, repeatable",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only books with (true) or without (false) copies",
+                        "name": "hasCopies",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return facet counts even without filters",
+                        "name": "facets",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
+                    },
                     {
                         "type": "integer",
                         "description": "Page number (0-based, default 0)",
@@ -401,18 +473,30 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ListResponse"
                         }
                     },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Failed to retrieve books",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
                 }
             }
         },
         "/books/search": {
             "get": {
-                "description": "Search for books across all sites with availability info",
+                "description": "Search for available books across all sites with availability info, ranked by relevance. Diacritics are ignored and words match as prefixes.",
                 "produces": [
                     "application/json"
                 ],
@@ -427,17 +511,59 @@ const docTemplate = `{
                         "name": "query",
                         "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Author, repeatable; diacritics and case are ignored",
+                        "name": "author",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Branch (MaCN) holding an available copy, repeatable",
+                        "name": "branch",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Status (TinhTrang) of at least one copy, repeatable",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only books with (true) or without (false) copies",
+                        "name": "hasCopies",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Search results",
+                        "description": "Search results with facet counts",
                         "schema": {
                             "$ref": "#/definitions/models.SuccessResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid query",
+                        "description": "Invalid query or filter",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -447,6 +573,12 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -533,6 +665,68 @@ const docTemplate = `{
                 }
             }
         },
+        "/books/{isbn}/holds": {
+            "get": {
+                "description": "List the active holds on a title at every branch in queue order; waiting holds carry their position (viTri)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Holds"
+                ],
+                "summary": "Get hold queue of a title",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Book ISBN",
+                        "name": "isbn",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Hold queue",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.DatCho"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve hold queue",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/borrow": {
             "get": {
                 "description": "Retrieve borrow transactions with role-based filtering",
@@ -573,7 +767,7 @@ const docTemplate = `{
                 }
             },
             "post": {
-                "description": "Create a new book borrowing transaction (Librarian only)",
+                "description": "Create a new book borrowing transaction (Librarian only). The copy must be at the librarian's branch; the reader may be registered at any branch. A copy of another branch shipped for the reader's hold is lent at the hold's pickup branch and recorded with the loans of its own branch. The loan length and the loan limit come from the circulation policy of the branch and the reader's category. The loan limit and the overdue block count the reader's loans at every branch, so the request fails when a branch cannot be reached.",
                 "consumes": [
                     "application/json"
                 ],
@@ -608,6 +802,24 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
+                    "422": {
+                        "description": "Blocked by a circulation rule",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.ErrorResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/models.PolicyViolation"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
                     "500": {
                         "description": "Failed to create borrow",
                         "schema": {
@@ -617,6 +829,82 @@ const docTemplate = `{
                 }
             }
         },
+        "/borrow/batch": {
+            "post": {
+                "description": "Lend several copies of the librarian's branch to one reader at once (Librarian only). Every copy is checked as for POST /borrow, and the loan limit of the circulation policy counts the reader's current loans plus every copy of the batch. The loans are made in one transaction: either every copy is lent or none is. A refused batch answers 422 with the outcome for each copy in details.receipt.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Borrowing"
+                ],
+                "summary": "Lend several copies to a reader",
+                "parameters": [
+                    {
+                        "description": "Batch borrow request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchBorrowRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Every copy lent",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.BatchReceipt"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Batch refused, no copy lent",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.ErrorResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/models.BatchRejection"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to create borrows",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/borrow/detailed": {
             "get": {
                 "description": "Get borrow records with book and reader details for Flutter app",
@@ -686,6 +974,18 @@ const docTemplate = `{
                         "description": "Page size (default 20)",
                         "name": "size",
                         "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from the previous page's paging.nextCursor, instead of page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -695,11 +995,23 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ListResponse"
                         }
                     },
+                    "400": {
+                        "description": "Invalid cursor",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Failed to retrieve borrow history",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -730,9 +1042,97 @@ const docTemplate = `{
                 }
             }
         },
+        "/borrow/return/batch": {
+            "put": {
+                "description": "Take back several copies at once (Librarian only). Every copy is returned as with PUT /borrow/return/{id}: late returns are fined, copies of the branch serve the holds on their titles, and copies of other branches are shipped home. Nothing is committed until every copy is processed, so a copy that cannot be returned leaves every loan open; a refused batch answers 422 with the outcome for each copy in details.receipt. The returns are then committed one database at a time and are best-effort, not all-or-nothing: if a commit fails after others went through, the returns already committed stand and 500 lists in details.receipt which copies were returned; the others are still on loan and can be returned again.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Borrowing"
+                ],
+                "summary": "Return several copies",
+                "parameters": [
+                    {
+                        "description": "Batch return request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.BatchReturnRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Every copy returned",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.BatchReceipt"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Batch refused, no copy returned",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.ErrorResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/models.BatchRejection"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to return books, or only some copies returned",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.ErrorResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/models.BatchIncomplete"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
         "/borrow/return/{id}": {
             "put": {
-                "description": "Process book return transaction (Librarian only)",
+                "description": "Process book return transaction (Librarian only). A late return is fined at the daily rate of the circulation policy of the branch and the reader's category, for each day past the due date and its grace days. When readers are waiting for the title, the copy is set aside for the first of them. A copy of another branch may be returned at any branch: its loan is closed at its home branch, and it is marked in transit and listed for shipping home until its branch checks it in at PUT /transfers/check-in/{id}. The fine, the hold and the transfer are returned in data when there are any.",
                 "consumes": [
                     "application/json"
                 ],
@@ -765,7 +1165,19 @@ const docTemplate = `{
                     "200": {
                         "description": "Book returned successfully",
                         "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.ReturnBookResponse"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "400": {
@@ -809,45 +1221,70 @@ const docTemplate = `{
                 }
             }
         },
-        "/coordinator/transfer-book": {
-            "post": {
-                "description": "Transfer a book copy from one site to another using distributed transaction coordination",
-                "consumes": [
-                    "application/json"
-                ],
+        "/borrow/{id}/renew": {
+            "put": {
+                "description": "Push the due date of a loan made at the librarian's site back by one loan period, as many times as the circulation policy of the branch and the reader's category allows. An overdue loan is renewed from today. Titles other readers are waiting for at any branch cannot be renewed. (ThuThu only)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Coordinator"
+                    "Borrowing"
                 ],
-                "summary": "Transfer book between sites using 2PC",
+                "summary": "Renew loan",
                 "parameters": [
                     {
-                        "description": "Book transfer request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/main.TransferBookRequest"
-                        }
+                        "type": "integer",
+                        "description": "Borrow record ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Book transferred successfully",
-                        "schema": {
-                            "$ref": "#/definitions/models.TransferBookResponse"
+                        "description": "Loan renewed",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.PhieuMuon"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "400": {
-                        "description": "Invalid request format",
+                        "description": "Invalid borrow record ID",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
+                    "422": {
+                        "description": "Blocked by a circulation rule",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.ErrorResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/models.PolicyViolation"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
                     "500": {
-                        "description": "Failed to transfer book",
+                        "description": "Failed to renew loan",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -855,9 +1292,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/manager/books": {
+        "/cache/invalidate": {
             "post": {
-                "description": "Create a new book in the system catalog using 2PC protocol (Manager only)",
+                "description": "Drop the cached SACH or CHINHANH entries written at another site, or every entry when no relation is given",
                 "consumes": [
                     "application/json"
                 ],
@@ -865,35 +1302,35 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Manager"
+                    "Cache"
                 ],
-                "summary": "Create book in catalog",
+                "summary": "Apply a cache invalidation",
                 "parameters": [
                     {
-                        "description": "Book information",
-                        "name": "book",
+                        "description": "Committed write",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.Sach"
+                            "$ref": "#/definitions/cache.Invalidation"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Book created successfully",
+                    "200": {
+                        "description": "Invalidation applied",
                         "schema": {
                             "$ref": "#/definitions/models.SuccessResponse"
                         }
                     },
                     "400": {
-                        "description": "Invalid request format",
+                        "description": "Invalid invalidation",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Failed to create book",
+                    "401": {
+                        "description": "Site secret required",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -901,40 +1338,62 @@ const docTemplate = `{
                 }
             }
         },
-        "/manager/books/search": {
-            "get": {
-                "description": "Search for books across all sites with availability information (Manager only)",
+        "/coordinator/fragments/relocate": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Copy the DOCGIA, QUYENSACH, PHIEUMUON, DATCHO, PHAT, GIAODICHPHAT, YEUCAUMUON and CHUYENTRA fragments of a branch to the target site in resumable chunks, verify row counts and checksums, switch the allocation in the topology and drop the source rows. Writes to the source fragments are blocked while the job runs. Starting a failed job again resumes from its last committed chunk. Runs in the background; poll the returned job.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Manager"
+                    "Coordinator"
                 ],
-                "summary": "Search books across all sites",
+                "summary": "Relocate a branch's fragments to another site",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Search query",
-                        "name": "query",
-                        "in": "query",
-                        "required": true
+                        "description": "Fragment relocation",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.RelocateFragmentRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Search results with availability",
+                    "202": {
+                        "description": "Relocation started",
                         "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                            "$ref": "#/definitions/distributed.RelocationJob"
                         }
                     },
                     "400": {
-                        "description": "Invalid query",
+                        "description": "Invalid request",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Search failed",
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - Manager role required",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Cannot start relocation",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -942,48 +1401,66 @@ const docTemplate = `{
                 }
             }
         },
-        "/manager/books/{isbn}": {
+        "/coordinator/fragments/relocate/{jobId}": {
             "get": {
-                "description": "Get book information from catalog (Manager only)",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the phase and per-relation progress of a fragment relocation job",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Manager"
+                    "Coordinator"
                 ],
-                "summary": "Get book by ISBN",
+                "summary": "Get relocation job status",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Book ISBN",
-                        "name": "isbn",
+                        "description": "Relocation job ID",
+                        "name": "jobId",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Book information",
+                        "description": "Relocation job",
                         "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                            "$ref": "#/definitions/distributed.RelocationJob"
                         }
                     },
-                    "404": {
-                        "description": "Book not found",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Failed to retrieve book",
+                    "403": {
+                        "description": "Access denied - Manager role required",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
-            },
-            "put": {
-                "description": "Update book information using 2PC protocol (Manager only)",
+            }
+        },
+        "/coordinator/sites/onboard": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create the schema on the new site's database, copy the replicated tables (SACH, CHINHANH) from an existing replica while capturing concurrent catalog changes, then register the site and its fragment predicate in the topology. Runs in the background; poll the returned job.",
                 "consumes": [
                     "application/json"
                 ],
@@ -991,87 +1468,99 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Manager"
+                    "Coordinator"
                 ],
-                "summary": "Update book in catalog",
+                "summary": "Onboard a new branch site",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Book ISBN",
-                        "name": "isbn",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "Updated book information",
-                        "name": "book",
+                        "description": "New site",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.Sach"
+                            "$ref": "#/definitions/main.OnboardSiteRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Book updated successfully",
+                    "202": {
+                        "description": "Onboarding started",
                         "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                            "$ref": "#/definitions/distributed.OnboardingJob"
                         }
                     },
                     "400": {
-                        "description": "Invalid request format",
+                        "description": "Invalid request",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "Book not found",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Failed to update book",
+                    "403": {
+                        "description": "Access denied - Manager role required",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Cannot start onboarding",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Delete book from catalog using 2PC protocol (Manager only)",
+            }
+        },
+        "/coordinator/sites/onboard/{jobId}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the phase and progress of a site onboarding job",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Manager"
+                    "Coordinator"
                 ],
-                "summary": "Delete book from catalog",
+                "summary": "Get onboarding job status",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Book ISBN",
-                        "name": "isbn",
+                        "description": "Onboarding job ID",
+                        "name": "jobId",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Book deleted successfully",
+                        "description": "Onboarding job",
                         "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                            "$ref": "#/definitions/distributed.OnboardingJob"
                         }
                     },
-                    "404": {
-                        "description": "Book not found",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Failed to delete book",
+                    "403": {
+                        "description": "Access denied - Manager role required",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Job not found",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1079,39 +1568,45 @@ const docTemplate = `{
                 }
             }
         },
-        "/manager/readers": {
-            "get": {
-                "description": "Get readers from all sites with pagination (Manager only)",
+        "/coordinator/transfer-book": {
+            "post": {
+                "description": "Transfer a book copy from one site to another using distributed transaction coordination",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Manager"
+                    "Coordinator"
                 ],
-                "summary": "Get all readers across all sites",
+                "summary": "Transfer book between sites using 2PC",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Page number (0-based, default 0)",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Page size (default 20)",
-                        "name": "size",
-                        "in": "query"
+                        "description": "Book transfer request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.TransferBookRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "List of all readers",
+                        "description": "Book transferred successfully",
                         "schema": {
-                            "$ref": "#/definitions/models.ListResponse"
+                            "$ref": "#/definitions/models.TransferBookResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Failed to retrieve readers",
+                        "description": "Failed to transfer book",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1119,25 +1614,66 @@ const docTemplate = `{
                 }
             }
         },
-        "/manager/statistics": {
+        "/fines": {
             "get": {
-                "description": "Get comprehensive statistics across all sites (Manager only)",
+                "description": "List the fines assessed on late returns at a branch, most recent first. Librarians see their own site; managers may pass siteID.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Manager"
+                    "Fines"
+                ],
+                "summary": "Get fines of a branch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Branch that made the loans (QuanLy only, default: this site)",
+                        "name": "siteID",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only fines with an outstanding amount",
+                        "name": "unpaid",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get system-wide statistics",
                 "responses": {
                     "200": {
-                        "description": "System statistics",
-                        "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                        "description": "Fines",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.Phat"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "500": {
-                        "description": "Failed to retrieve statistics",
+                        "description": "Failed to retrieve fines",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1145,45 +1681,51 @@ const docTemplate = `{
                 }
             }
         },
-        "/manager/transfer": {
-            "post": {
-                "description": "Transfer a book copy from one site to another using 2PC protocol",
-                "consumes": [
-                    "application/json"
-                ],
+        "/fines/report": {
+            "get": {
+                "description": "Outstanding fines and the fines accruing on overdue loans, per branch and across the system. Readers owing at several branches are counted once in the total. (QuanLy only)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Manager"
+                    "Fines"
                 ],
-                "summary": "Transfer book copy between sites",
+                "summary": "Outstanding fines report",
                 "parameters": [
                     {
-                        "description": "Transfer request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.TransferBookRequest"
-                        }
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Book copy transferred successfully",
-                        "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                        "description": "Fine report",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.FineReport"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
-                    "400": {
-                        "description": "Invalid request format",
+                    "500": {
+                        "description": "Failed to build fine report",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Failed to transfer book copy",
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1191,47 +1733,56 @@ const docTemplate = `{
                 }
             }
         },
-        "/readers": {
+        "/fines/{maPhat}": {
             "get": {
-                "description": "Get readers with role-based filtering (ThuThu: local site, QuanLy: all sites)",
+                "description": "Get a fine with its payments and waivers, from whichever branch made the loan",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Readers"
+                    "Fines"
                 ],
-                "summary": "Get all readers",
+                "summary": "Get fine",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Page number (0-based, default 0)",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Page size (default 20)",
-                        "name": "size",
-                        "in": "query"
+                        "type": "string",
+                        "description": "Fine ID",
+                        "name": "maPhat",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "List of readers",
-                        "schema": {
-                            "$ref": "#/definitions/models.ListResponse"
+                        "description": "Fine",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.Phat"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
-                    "500": {
-                        "description": "Failed to retrieve readers",
+                    "404": {
+                        "description": "Fine not found",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/fines/{maPhat}/payments": {
             "post": {
-                "description": "Create a new reader at the user's site (ThuThu only)",
+                "description": "Record a payment of at most the outstanding amount against a fine of the librarian's site. The fine is settled once nothing is outstanding. (ThuThu only)",
                 "consumes": [
                     "application/json"
                 ],
@@ -1239,25 +1790,44 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Readers"
+                    "Fines"
                 ],
-                "summary": "Create new reader",
+                "summary": "Record fine payment",
                 "parameters": [
                     {
-                        "description": "Reader information",
-                        "name": "reader",
+                        "type": "string",
+                        "description": "Fine ID",
+                        "name": "maPhat",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Payment",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.DocGia"
+                            "$ref": "#/definitions/models.FinePaymentRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Reader created successfully",
-                        "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                    "200": {
+                        "description": "Payment recorded",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.Phat"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "400": {
@@ -1267,7 +1837,7 @@ const docTemplate = `{
                         }
                     },
                     "500": {
-                        "description": "Failed to create reader",
+                        "description": "Failed to record payment",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1275,52 +1845,64 @@ const docTemplate = `{
                 }
             }
         },
-        "/readers/search": {
-            "get": {
-                "description": "Search for readers across sites (role-based access)",
+        "/fines/{maPhat}/waivers": {
+            "post": {
+                "description": "Waive part of a fine of the librarian's site, or all of its outstanding amount when soTien is 0. A reason is required. (ThuThu only)",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Readers"
+                    "Fines"
                 ],
-                "summary": "Search readers",
+                "summary": "Waive fine",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Search query",
-                        "name": "query",
-                        "in": "query",
+                        "description": "Fine ID",
+                        "name": "maPhat",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "type": "integer",
-                        "description": "Page number (0-based, default 0)",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Page size (default 20)",
-                        "name": "size",
-                        "in": "query"
+                        "description": "Waiver",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.FineWaiverRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Search results",
-                        "schema": {
-                            "$ref": "#/definitions/models.ListResponse"
+                        "description": "Waiver recorded",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.Phat"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "400": {
-                        "description": "Invalid query",
+                        "description": "Invalid request format",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Search failed",
+                        "description": "Failed to waive fine",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1328,25 +1910,57 @@ const docTemplate = `{
                 }
             }
         },
-        "/readers/stats": {
-            "get": {
-                "description": "Get all readers with borrowing statistics for the site",
+        "/holds": {
+            "post": {
+                "description": "Queue a reader registered at the librarian's site for a title, optionally naming a pickup branch. Holds on a title are served in order across all branches: the first copy freed at any branch is set aside for the oldest hold until its pickup deadline. A copy of another branch than the pickup branch is shipped there first, and the hold is ready once the pickup branch checks it in at PUT /transfers/check-in/{id}. A copy already free is set aside at once. (ThuThu only)",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Readers"
+                    "Holds"
+                ],
+                "summary": "Place hold",
+                "parameters": [
+                    {
+                        "description": "Hold request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.PlaceHoldRequest"
+                        }
+                    }
                 ],
-                "summary": "Get readers with statistics",
                 "responses": {
-                    "200": {
-                        "description": "Readers with statistics",
+                    "201": {
+                        "description": "Hold placed",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.DatCho"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
                         "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Failed to retrieve reader statistics",
+                        "description": "Failed to place hold",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1354,97 +1968,107 @@ const docTemplate = `{
                 }
             }
         },
-        "/readers/{maDG}": {
+        "/holds/shelf": {
             "get": {
-                "description": "Get reader information by ID with role-based access control",
+                "description": "List the ready holds whose copy waits for pickup at a branch, earliest pickup deadline first. Librarians see their own site; managers may pass siteID.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Readers"
+                    "Holds"
                 ],
-                "summary": "Get reader by ID",
+                "summary": "Get hold shelf",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Reader ID",
-                        "name": "maDG",
-                        "in": "path",
-                        "required": true
+                        "description": "Branch storing the copies (QuanLy only, default: this site)",
+                        "name": "siteID",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Reader information",
-                        "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                        "description": "Ready holds",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.DatCho"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
-                    "404": {
-                        "description": "Reader not found",
+                    "500": {
+                        "description": "Failed to retrieve holds",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Failed to retrieve reader",
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
-            },
-            "put": {
-                "description": "Update reader information (ThuThu only)",
-                "consumes": [
-                    "application/json"
-                ],
+            }
+        },
+        "/holds/{maDC}": {
+            "get": {
+                "description": "Get a hold by ID, from whichever branch it was placed at",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Readers"
+                    "Holds"
                 ],
-                "summary": "Update reader",
+                "summary": "Get hold",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Reader ID",
-                        "name": "maDG",
+                        "description": "Hold ID",
+                        "name": "maDC",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Updated reader information",
-                        "name": "reader",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.DocGia"
-                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Reader updated successfully",
-                        "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
-                        }
-                    },
-                    "400": {
-                        "description": "Invalid request format",
-                        "schema": {
-                            "$ref": "#/definitions/models.ErrorResponse"
+                        "description": "Hold",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.DatCho"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "404": {
-                        "description": "Reader not found",
-                        "schema": {
-                            "$ref": "#/definitions/models.ErrorResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Failed to update reader",
+                        "description": "Hold not found",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1452,38 +2076,32 @@ const docTemplate = `{
                 }
             },
             "delete": {
-                "description": "Delete reader (ThuThu only)",
+                "description": "Cancel an active hold placed at the librarian's site. A copy already set aside for it passes to the next reader in the queue. (ThuThu only)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Readers"
+                    "Holds"
                 ],
-                "summary": "Delete reader",
+                "summary": "Cancel hold",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Reader ID",
-                        "name": "maDG",
+                        "description": "Hold ID",
+                        "name": "maDC",
                         "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Reader deleted successfully",
+                        "description": "Hold cancelled",
                         "schema": {
                             "$ref": "#/definitions/models.SuccessResponse"
                         }
                     },
-                    "404": {
-                        "description": "Reader not found",
-                        "schema": {
-                            "$ref": "#/definitions/models.ErrorResponse"
-                        }
-                    },
                     "500": {
-                        "description": "Failed to delete reader",
+                        "description": "Failed to cancel hold",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1491,86 +2109,131 @@ const docTemplate = `{
                 }
             }
         },
-        "/readers/{maDG}/stats": {
+        "/ill": {
             "get": {
-                "description": "Get reader information with borrowing statistics",
+                "description": "List the requests a branch placed or was asked to ship, most recent first. Librarians see their own site; managers may pass siteID.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Readers"
+                    "Inter-library loans"
                 ],
-                "summary": "Get reader with statistics",
+                "summary": "Get inter-library loan requests",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Reader ID",
-                        "name": "maDG",
-                        "in": "path",
-                        "required": true
+                        "description": "Requesting or owning branch (QuanLy only, default: this site)",
+                        "name": "siteID",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "Đã yêu cầu",
+                            "Đang chuyển đến",
+                            "Đã đến",
+                            "Đang cho mượn",
+                            "Đang trả về",
+                            "Hoàn tất",
+                            "Đã hủy"
+                        ],
+                        "type": "string",
+                        "description": "Request state",
+                        "name": "trangThai",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Reader with statistics",
-                        "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                        "description": "Requests",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.YeuCauMuon"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
-                    "404": {
-                        "description": "Reader not found",
+                    "500": {
+                        "description": "Failed to retrieve requests",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Failed to retrieve reader statistics",
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
-            }
-        },
-        "/stats/borrow-trends": {
-            "get": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
+            },
+            "post": {
+                "description": "Ask another branch for a copy of a title that is not free at the librarian's site, on behalf of a reader of any branch. Without maCNSoHuu, a branch with a free copy is chosen. A reader may have one open request per title. (ThuThu only)",
+                "consumes": [
+                    "application/json"
                 ],
-                "description": "Get borrowing trends for site analysis",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Statistics"
+                    "Inter-library loans"
                 ],
-                "summary": "Get borrowing trends",
+                "summary": "Request inter-library loan",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "default": 30,
-                        "description": "Number of days to analyze",
-                        "name": "days",
-                        "in": "query"
+                        "description": "Inter-library loan request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ILLRequest"
+                        }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Borrow trends retrieved successfully",
-                        "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                    "201": {
+                        "description": "Request placed",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.YeuCauMuon"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "400": {
+                        "description": "Invalid request format",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Failed to request copy",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1578,42 +2241,91 @@ const docTemplate = `{
                 }
             }
         },
-        "/stats/distributed": {
+        "/ill/{maYC}": {
             "get": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "Get distributed system health and statistics (Manager only)",
+                "description": "Get a request by ID, from whichever branch placed it",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Statistics"
+                    "Inter-library loans"
+                ],
+                "summary": "Get inter-library loan request",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Request ID",
+                        "name": "maYC",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Get distributed system statistics",
                 "responses": {
                     "200": {
-                        "description": "Distributed statistics retrieved successfully",
-                        "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                        "description": "Request",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.YeuCauMuon"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "404": {
+                        "description": "Request not found",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
-                    },
-                    "403": {
-                        "description": "Access denied - Manager role required",
-                        "schema": {
-                            "$ref": "#/definitions/models.ErrorResponse"
+                    }
+                }
+            },
+            "delete": {
+                "description": "Withdraw a request no copy was shipped for yet. Either the requesting or the owning branch may cancel. (ThuThu only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Inter-library loans"
+                ],
+                "summary": "Cancel inter-library loan request",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Request ID",
+                        "name": "maYC",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Request cancelled",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.YeuCauMuon"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Failed to cancel request",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1621,36 +2333,46 @@ const docTemplate = `{
                 }
             }
         },
-        "/stats/health": {
-            "get": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "Get system health status across all sites",
+        "/ill/{maYC}/arrive": {
+            "post": {
+                "description": "Record the arrival of the shipped copy at the requesting branch, where it waits for the reader. (ThuThu of the requesting branch only)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Statistics"
+                    "Inter-library loans"
+                ],
+                "summary": "Receive shipped copy",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Request ID",
+                        "name": "maYC",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Get system health",
                 "responses": {
                     "200": {
-                        "description": "System health retrieved successfully",
-                        "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
-                        }
-                    },
-                    "401": {
-                        "description": "Unauthorized",
-                        "schema": {
-                            "$ref": "#/definitions/models.ErrorResponse"
+                        "description": "Copy arrived",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.YeuCauMuon"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Failed to receive copy",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1658,45 +2380,158 @@ const docTemplate = `{
                 }
             }
         },
-        "/stats/popular-books": {
-            "get": {
-                "security": [
+        "/ill/{maYC}/lend": {
+            "post": {
+                "description": "Lend the arrived copy to the reader it was requested for. The loan is stored at the requesting branch and made under its circulation policy, while the copy stays in its owner's fragment. A rule of the policy blocking the loan is returned with status 422 and the violated rule in details. (ThuThu of the requesting branch only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Inter-library loans"
+                ],
+                "summary": "Lend arrived copy",
+                "parameters": [
                     {
-                        "BearerAuth": []
+                        "type": "string",
+                        "description": "Request ID",
+                        "name": "maYC",
+                        "in": "path",
+                        "required": true
                     }
                 ],
-                "description": "Get popular books based on borrowing frequency",
+                "responses": {
+                    "200": {
+                        "description": "Copy lent",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.ILLResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "422": {
+                        "description": "Loan not allowed by circulation policy",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.ErrorResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "details": {
+                                            "$ref": "#/definitions/models.PolicyViolation"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to lend copy",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ill/{maYC}/receive-return": {
+            "post": {
+                "description": "Put the copy shipped back on the owning branch's shelf and complete the request. When readers are waiting for the title, the copy is set aside for the first of them. (ThuThu of the owning branch only)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Statistics"
+                    "Inter-library loans"
                 ],
-                "summary": "Get popular books statistics",
+                "summary": "Receive copy shipped back",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "default": 10,
-                        "description": "Number of books to return",
-                        "name": "limit",
-                        "in": "query"
+                        "type": "string",
+                        "description": "Request ID",
+                        "name": "maYC",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Popular books statistics retrieved successfully",
-                        "schema": {
-                            "$ref": "#/definitions/models.SuccessResponse"
+                        "description": "Request completed",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.ILLResponse"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "500": {
+                        "description": "Failed to receive copy",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    }
+                }
+            }
+        },
+        "/ill/{maYC}/return": {
+            "post": {
+                "description": "Take the copy back from the reader, closing the loan and fining a late return as for any loan of the branch, and ship it back to the owning branch. A copy the reader never picked up is shipped back as it is. (ThuThu of the requesting branch only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Inter-library loans"
+                ],
+                "summary": "Return copy to its owner",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Request ID",
+                        "name": "maYC",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Copy shipped back",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.ILLResponse"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Failed to return copy",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1704,58 +2539,63 @@ const docTemplate = `{
                 }
             }
         },
-        "/stats/readers": {
-            "get": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
+        "/ill/{maYC}/ship": {
+            "post": {
+                "description": "Send a free copy of the requested title to the requesting branch; the copy is in transit until it arrives. Without maQuyenSach, a free copy is chosen. (ThuThu of the owning branch only)",
+                "consumes": [
+                    "application/json"
                 ],
-                "description": "Get readers with borrowing statistics with pagination (enhanced for Flutter app)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Statistics"
+                    "Inter-library loans"
                 ],
-                "summary": "Get readers with statistics and pagination",
+                "summary": "Ship copy",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "default": 0,
-                        "description": "Page number (0-based)",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "default": 20,
-                        "description": "Items per page",
-                        "name": "size",
-                        "in": "query"
+                        "type": "string",
+                        "description": "Request ID",
+                        "name": "maYC",
+                        "in": "path",
+                        "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Search term for reader name or ID",
-                        "name": "search",
-                        "in": "query"
+                        "description": "Copy to ship",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/models.ILLShipRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Readers with stats retrieved successfully",
-                        "schema": {
-                            "$ref": "#/definitions/models.ListResponse"
+                        "description": "Copy shipped",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.YeuCauMuon"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "400": {
+                        "description": "Invalid request format",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Failed to ship copy",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1763,36 +2603,45 @@ const docTemplate = `{
                 }
             }
         },
-        "/stats/site": {
-            "get": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
+        "/manager/books": {
+            "post": {
+                "description": "Create a new book in the system catalog using 2PC protocol (Manager only)",
+                "consumes": [
+                    "application/json"
                 ],
-                "description": "Get statistics for current site (available to both roles)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Statistics"
+                    "Manager"
+                ],
+                "summary": "Create book in catalog",
+                "parameters": [
+                    {
+                        "description": "Book information",
+                        "name": "book",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Sach"
+                        }
+                    }
                 ],
-                "summary": "Get site statistics",
                 "responses": {
-                    "200": {
-                        "description": "Site statistics retrieved successfully",
+                    "201": {
+                        "description": "Book created successfully",
                         "schema": {
                             "$ref": "#/definitions/models.SuccessResponse"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "400": {
+                        "description": "Invalid request format",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal server error",
+                        "description": "Failed to create book",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -1800,181 +2649,3569 @@ const docTemplate = `{
                 }
             }
         },
-        "/stats/system": {
+        "/manager/books/search": {
             "get": {
-                "security": [
-                    {
-                        "BearerAuth": []
-                    }
-                ],
-                "description": "Get comprehensive system statistics across all sites (Manager only)",
+                "description": "Search for books across all sites with availability information, ranked by relevance. Diacritics are ignored and words match as prefixes. (Manager only)",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Statistics"
+                    "Manager"
+                ],
+                "summary": "Search books across all sites",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search query",
+                        "name": "query",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Author, repeatable; diacritics and case are ignored",
+                        "name": "author",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Branch (MaCN) holding an available copy, repeatable",
+                        "name": "branch",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Status (TinhTrang) of at least one copy, repeatable",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only books with (true) or without (false) copies",
+                        "name": "hasCopies",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return the distributed query plan instead of executing",
+                        "name": "explain",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Get system statistics",
                 "responses": {
                     "200": {
-                        "description": "System statistics retrieved successfully",
+                        "description": "Search results with availability and facet counts",
                         "schema": {
-                            "$ref": "#/definitions/models.SystemStatsResponse"
+                            "$ref": "#/definitions/models.SuccessResponse"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "400": {
+                        "description": "Invalid query or filter",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "403": {
-                        "description": "Access denied - Manager role required",
+                    "500": {
+                        "description": "Search failed",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error",
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "auth.LoginRequest": {
-            "description": "User login credentials",
-            "type": "object",
-            "required": [
-                "password",
-                "username"
-            ],
-            "properties": {
-                "password": {
-                    "description": "Password",
-                    "type": "string",
-                    "example": "password123"
-                },
-                "username": {
-                    "description": "Username",
-                    "type": "string",
-                    "example": "thuthu01"
-                }
-            }
         },
-        "auth.LoginResponse": {
-            "description": "Successful login response with JWT access token only",
-            "type": "object",
-            "properties": {
-                "accessToken": {
-                    "description": "JWT access token",
-                    "type": "string",
-                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+        "/manager/books/{isbn}": {
+            "get": {
+                "description": "Get book information from catalog (Manager only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Manager"
+                ],
+                "summary": "Get book by ISBN",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Book ISBN",
+                        "name": "isbn",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Book information",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Book not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve book",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
                 }
-            }
-        },
-        "main.TransferBookRequest": {
+            },
+            "put": {
+                "description": "Update book information using 2PC protocol (Manager only)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Manager"
+                ],
+                "summary": "Update book in catalog",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Book ISBN",
+                        "name": "isbn",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated book information",
+                        "name": "book",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.Sach"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Book updated successfully",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Book not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update book",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete book from catalog using 2PC protocol (Manager only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Manager"
+                ],
+                "summary": "Delete book from catalog",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Book ISBN",
+                        "name": "isbn",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Book deleted successfully",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Book not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete book",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/manager/cache": {
+            "get": {
+                "description": "Get the entries, hits, misses, hit rate and invalidations of the reference data cache at this site",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Manager"
+                ],
+                "summary": "Get cache statistics",
+                "responses": {
+                    "200": {
+                        "description": "Cache statistics",
+                        "schema": {
+                            "$ref": "#/definitions/models.CacheStatsResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Drop every cached SACH and CHINHANH entry at this site and at every other branch site",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Manager"
+                ],
+                "summary": "Flush the reference data caches",
+                "responses": {
+                    "200": {
+                        "description": "Sites flushed",
+                        "schema": {
+                            "$ref": "#/definitions/models.CacheFlushResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/manager/catalog/fragments": {
+            "get": {
+                "description": "Get every global relation with its fragmentation type, fragment predicates and site allocation (Manager only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Manager"
+                ],
+                "summary": "Get fragmentation catalog",
+                "responses": {
+                    "200": {
+                        "description": "Fragmentation catalog",
+                        "schema": {
+                            "$ref": "#/definitions/models.FragmentCatalogResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/manager/readers": {
+            "get": {
+                "description": "Get readers from all sites with pagination (Manager only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Manager"
+                ],
+                "summary": "Get all readers across all sites",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number (0-based, default 0)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 20)",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from the previous page's paging.nextCursor, instead of page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return the distributed query plan instead of executing",
+                        "name": "explain",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of all readers",
+                        "schema": {
+                            "$ref": "#/definitions/models.ListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid cursor",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve readers",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/manager/statistics": {
+            "get": {
+                "description": "Get comprehensive statistics across all sites (Manager only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Manager"
+                ],
+                "summary": "Get system-wide statistics",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Return the distributed query plan instead of executing",
+                        "name": "explain",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "System statistics",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve statistics",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/manager/transfer": {
+            "post": {
+                "description": "Transfer a book copy from one site to another using 2PC protocol",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Manager"
+                ],
+                "summary": "Transfer book copy between sites",
+                "parameters": [
+                    {
+                        "description": "Transfer request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TransferBookRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Book copy transferred successfully",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to transfer book copy",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/membership": {
+            "get": {
+                "description": "Get the state (UP, SUSPECT or DOWN) of every other site as seen from this site, with the history of state changes",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Membership"
+                ],
+                "summary": "Get membership view",
+                "responses": {
+                    "200": {
+                        "description": "Membership view",
+                        "schema": {
+                            "$ref": "#/definitions/membership.View"
+                        }
+                    }
+                }
+            }
+        },
+        "/membership/heartbeat": {
+            "post": {
+                "description": "Record a heartbeat from another site and answer with this site's heartbeat, including whether its own database is reachable",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Membership"
+                ],
+                "summary": "Exchange a membership heartbeat",
+                "parameters": [
+                    {
+                        "description": "Sender heartbeat",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/membership.Heartbeat"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Receiver heartbeat",
+                        "schema": {
+                            "$ref": "#/definitions/membership.Heartbeat"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid heartbeat",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Site secret required",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/policies": {
+            "get": {
+                "description": "List the circulation policies, general policies first. Where no policy matches a loan, the configured defaults apply.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Policies"
+                ],
+                "summary": "Get circulation policies",
+                "responses": {
+                    "200": {
+                        "description": "Circulation policies",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.ChinhSach"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve policies",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a policy for a branch and reader category, replicated to every site. Leave maCN or loaiDG empty for every branch or category; only one policy may exist per branch and category. (QuanLy only)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Policies"
+                ],
+                "summary": "Create circulation policy",
+                "parameters": [
+                    {
+                        "description": "Policy",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.PolicyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Policy created",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.ChinhSach"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to create policy",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/policies/effective": {
+            "get": {
+                "description": "Get the policy applying to loans of a branch to a reader category: the policy of both, else of the branch, else of the category, else of every branch and category, else the configured defaults (maCS \"default\")",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Policies"
+                ],
+                "summary": "Get applicable circulation policy",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Lending branch (default: the librarian's branch, or this site)",
+                        "name": "maCN",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Reader category (default: Thường)",
+                        "name": "loaiDG",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Applicable policy",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.ChinhSach"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to resolve policy",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/policies/{maCS}": {
+            "get": {
+                "description": "Get a circulation policy",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Policies"
+                ],
+                "summary": "Get circulation policy",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Policy ID",
+                        "name": "maCS",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Circulation policy",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.ChinhSach"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "404": {
+                        "description": "Policy not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Change the limits of a policy at every site. The branch and reader category it applies to are kept; maCN and loaiDG in the request are ignored. (QuanLy only)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Policies"
+                ],
+                "summary": "Update circulation policy",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Policy ID",
+                        "name": "maCS",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Policy",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.PolicyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Policy updated",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.ChinhSach"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Policy not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update policy",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a policy at every site. Loans it applied to fall back on the next most specific policy. (QuanLy only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Policies"
+                ],
+                "summary": "Delete circulation policy",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Policy ID",
+                        "name": "maCS",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Policy deleted",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete policy",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readers": {
+            "get": {
+                "description": "Get readers with role-based filtering (ThuThu: local site, QuanLy: all sites)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Readers"
+                ],
+                "summary": "Get all readers",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Page number (0-based, default 0)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 20)",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Cursor from the previous page's paging.nextCursor (managers only)",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable (managers only)",
+                        "name": "requireAll",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of readers",
+                        "schema": {
+                            "$ref": "#/definitions/models.ListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid cursor",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve readers",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a new reader at the user's site (ThuThu only)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Readers"
+                ],
+                "summary": "Create new reader",
+                "parameters": [
+                    {
+                        "description": "Reader information",
+                        "name": "reader",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DocGia"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Reader created successfully",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to create reader",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readers/search": {
+            "get": {
+                "description": "Find readers at every branch by an approximate name or a partial ID, ignoring diacritics and tolerating typos, e.g. to spot duplicates before registering a reader. Matches are ranked by similarity and grouped by registration branch. (ThuThu and QuanLy)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Readers"
+                ],
+                "summary": "Search readers",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name or reader ID, e.g. nguyen van b or DG00",
+                        "name": "query",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "Least similarity of a match, from 0 to 1 (default 0.8)",
+                        "name": "minScore",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number (0-based, default 0)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 20)",
+                        "name": "size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Matches grouped by registration branch",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid query",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Role not allowed to search readers",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Search failed",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readers/stats": {
+            "get": {
+                "description": "Get all readers with borrowing statistics and fines for the loans of the site",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Readers"
+                ],
+                "summary": "Get readers with statistics",
+                "responses": {
+                    "200": {
+                        "description": "Readers with statistics",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve reader statistics",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readers/{maDG}": {
+            "get": {
+                "description": "Get reader information by ID with role-based access control",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Readers"
+                ],
+                "summary": "Get reader by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reader ID",
+                        "name": "maDG",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reader information",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Reader not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve reader",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Update reader information (ThuThu only)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Readers"
+                ],
+                "summary": "Update reader",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reader ID",
+                        "name": "maDG",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Updated reader information",
+                        "name": "reader",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DocGia"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reader updated successfully",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Reader not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to update reader",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete reader (ThuThu only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Readers"
+                ],
+                "summary": "Delete reader",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reader ID",
+                        "name": "maDG",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reader deleted successfully",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Reader not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to delete reader",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readers/{maDG}/fines": {
+            "get": {
+                "description": "List the fines of a reader at every branch, most recent first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Fines"
+                ],
+                "summary": "Get fines of a reader",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reader ID",
+                        "name": "maDG",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Fines of the reader",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.Phat"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve fines",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readers/{maDG}/holds": {
+            "get": {
+                "description": "List the holds of a reader, most recent first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Holds"
+                ],
+                "summary": "Get holds of a reader",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reader ID",
+                        "name": "maDG",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Holds of the reader",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.DatCho"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve holds",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/readers/{maDG}/stats": {
+            "get": {
+                "description": "Get reader information with borrowing statistics and the fines owed at every branch",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Readers"
+                ],
+                "summary": "Get reader with statistics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Reader ID",
+                        "name": "maDG",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reader with statistics",
+                        "schema": {
+                            "$ref": "#/definitions/models.SuccessResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Reader not found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve reader statistics",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/borrow-trends": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get borrowing trends for site analysis",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Statistics"
+                ],
+                "summary": "Get borrowing trends",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 30,
+                        "description": "Number of days to analyze",
+                        "name": "days",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Borrow trends retrieved successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.BorrowTrend"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/distributed": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get distributed system health and statistics (Manager only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Statistics"
+                ],
+                "summary": "Get distributed system statistics",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Distributed statistics retrieved successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.SystemStats"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - Manager role required",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/health": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get system health status across all sites",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Statistics"
+                ],
+                "summary": "Get system health",
+                "responses": {
+                    "200": {
+                        "description": "System health retrieved successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.SystemHealth"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/popular-books": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get popular books based on borrowing frequency",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Statistics"
+                ],
+                "summary": "Get popular books statistics",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 10,
+                        "description": "Number of books to return",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Popular books statistics retrieved successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.BookWithAvailability"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/readers": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the copy and reader statistics of the user's site (enhanced for Flutter app)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Statistics"
+                ],
+                "summary": "Get book and reader statistics of the site",
+                "responses": {
+                    "200": {
+                        "description": "Reader statistics retrieved successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.SiteDetailStats"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/site": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get statistics for current site (available to both roles)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Statistics"
+                ],
+                "summary": "Get site statistics",
+                "responses": {
+                    "200": {
+                        "description": "Site statistics retrieved successfully",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.SiteStats"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stats/system": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get comprehensive system statistics across all sites (Manager only). Each site returns partial aggregates (counts, sums, min/max and distinct-count sketches) that are merged into global values; distinct reader and title counts are estimates within about 3%.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Statistics"
+                ],
+                "summary": "Get system statistics",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "System statistics retrieved successfully",
+                        "schema": {
+                            "$ref": "#/definitions/models.SystemStatsResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Access denied - Manager role required",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/transfers/check-in/{id}": {
+            "put": {
+                "description": "Put a copy returned at another branch back on the shelf of its home branch. When readers are waiting for the title, the copy is set aside for the first of them. A copy of another branch shipped for a hold picked up at the librarian's branch is set aside there for the hold, which becomes ready until its pickup deadline; if the hold ended meanwhile, the copy is listed for shipping home instead. (ThuThu of the home or pickup branch only)",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Transfers"
+                ],
+                "summary": "Check in shipped copy",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Book copy ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Copy checked in",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/models.ChuyenTra"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to check in copy",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/transfers/incoming": {
+            "get": {
+                "description": "List the copies on their way to a branch, oldest first: copies of the branch returned at other branches, and copies of other branches shipped for holds picked up there. Librarians see their own site; managers may pass siteID.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Transfers"
+                ],
+                "summary": "Get incoming copies",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Home branch of the copies (QuanLy only, default: this site)",
+                        "name": "siteID",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Incoming copies",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.ChuyenTra"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve transfers",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/transfers/outgoing": {
+            "get": {
+                "description": "List the copies a branch has to ship, oldest first: copies of other branches returned there, to be shipped to their home branch, and copies of the branch set aside for holds picked up at another branch, to be shipped there. Librarians see their own site; managers may pass siteID.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Transfers"
+                ],
+                "summary": "Get copies to ship",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Branch that took the returns (QuanLy only, default: this site)",
+                        "name": "siteID",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Fail with 503 instead of returning partial results when a site is unavailable",
+                        "name": "requireAll",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Copies to ship",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/models.SuccessResponse"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "type": "array",
+                                            "items": {
+                                                "$ref": "#/definitions/models.ChuyenTra"
+                                            }
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to retrieve transfers",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A site is unavailable and requireAll is set",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "auth.LoginRequest": {
+            "description": "User login credentials",
+            "type": "object",
+            "required": [
+                "password",
+                "username"
+            ],
+            "properties": {
+                "password": {
+                    "description": "Password",
+                    "type": "string",
+                    "example": "password123"
+                },
+                "username": {
+                    "description": "Username",
+                    "type": "string",
+                    "example": "thuthu01"
+                }
+            }
+        },
+        "auth.LoginResponse": {
+            "description": "Successful login response with JWT access token only",
+            "type": "object",
+            "properties": {
+                "accessToken": {
+                    "description": "JWT access token",
+                    "type": "string",
+                    "example": "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."
+                }
+            }
+        },
+        "cache.Invalidation": {
+            "type": "object",
+            "properties": {
+                "keys": {
+                    "description": "Primary keys written; empty for the whole relation",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "978-0-123456-78-9"
+                    ]
+                },
+                "origin": {
+                    "description": "Site or coordinator that made the write",
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "relation": {
+                    "description": "Empty to drop every relation",
+                    "type": "string",
+                    "example": "SACH"
+                }
+            }
+        },
+        "cache.Stats": {
+            "type": "object",
+            "properties": {
+                "hitRate": {
+                    "description": "Hits over lookups, 0 before the first lookup",
+                    "type": "number",
+                    "example": 0.95
+                },
+                "hits": {
+                    "type": "integer",
+                    "example": 950
+                },
+                "invalidations": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "misses": {
+                    "type": "integer",
+                    "example": 50
+                },
+                "pages": {
+                    "description": "List results cached by request",
+                    "type": "integer",
+                    "example": 8
+                },
+                "relation": {
+                    "type": "string",
+                    "example": "SACH"
+                },
+                "rows": {
+                    "description": "Rows cached by primary key",
+                    "type": "integer",
+                    "example": 120
+                }
+            }
+        },
+        "catalog.Fragment": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "description": "Projected columns (vertical fragments)",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "QUYENSACH_Q1"
+                },
+                "predicate": {
+                    "type": "string",
+                    "example": "MaCN = 'Q1'"
+                },
+                "sites": {
+                    "description": "Allocation",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "value": {
+                    "description": "Fragment key value (horizontal fragments)",
+                    "type": "string",
+                    "example": "Q1"
+                }
+            }
+        },
+        "catalog.FragmentationType": {
+            "type": "string",
+            "enum": [
+                "HORIZONTAL",
+                "REPLICATED",
+                "VERTICAL"
+            ],
+            "x-enum-comments": {
+                "Horizontal": "Rows split by a predicate on FragmentKey",
+                "Replicated": "Full copy at every site",
+                "Vertical": "Columns split, each fragment keeps the primary key"
+            },
+            "x-enum-descriptions": [
+                "Rows split by a predicate on FragmentKey",
+                "Full copy at every site",
+                "Columns split, each fragment keeps the primary key"
+            ],
+            "x-enum-varnames": [
+                "Horizontal",
+                "Replicated",
+                "Vertical"
+            ]
+        },
+        "catalog.Relation": {
+            "type": "object",
+            "properties": {
+                "columns": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "fragmentKey": {
+                    "type": "string",
+                    "example": "MaCN"
+                },
+                "fragments": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/catalog.Fragment"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "QUYENSACH"
+                },
+                "primaryKey": {
+                    "type": "string",
+                    "example": "MaQuyenSach"
+                },
+                "type": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/catalog.FragmentationType"
+                        }
+                    ],
+                    "example": "HORIZONTAL"
+                }
+            }
+        },
+        "distributed.OnboardingJob": {
+            "type": "object",
+            "properties": {
+                "changesReplayed": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "onboard_Q5_1735689600"
+                },
+                "phase": {
+                    "type": "string",
+                    "example": "COPYING"
+                },
+                "rowsCopied": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "siteId": {
+                    "type": "string",
+                    "example": "Q5"
+                },
+                "sourceSite": {
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "startedAt": {
+                    "type": "string"
+                }
+            }
+        },
+        "distributed.RelationProgress": {
+            "type": "object",
+            "properties": {
+                "rowsCopied": {
+                    "type": "integer"
+                },
+                "totalRows": {
+                    "type": "integer"
+                },
+                "verified": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "distributed.RelocationJob": {
+            "type": "object",
+            "properties": {
+                "branch": {
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finishedAt": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "relocate_Q1_to_Q3"
+                },
+                "phase": {
+                    "type": "string",
+                    "example": "COPYING"
+                },
+                "progress": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/distributed.RelationProgress"
+                    }
+                },
+                "sourceSite": {
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "startedAt": {
+                    "type": "string"
+                },
+                "targetSite": {
+                    "type": "string",
+                    "example": "Q3"
+                }
+            }
+        },
+        "main.OnboardSiteRequest": {
+            "type": "object",
+            "required": [
+                "dbHost",
+                "dbName",
+                "dbPort",
+                "diaChi",
+                "serviceURL",
+                "siteId",
+                "tenCN"
+            ],
+            "properties": {
+                "dbHost": {
+                    "description": "Database host of the new site",
+                    "type": "string",
+                    "example": "10.211.55.3"
+                },
+                "dbName": {
+                    "description": "Existing, empty database for the new site",
+                    "type": "string",
+                    "example": "ThuVienQ5"
+                },
+                "dbPort": {
+                    "description": "Database port of the new site",
+                    "type": "integer",
+                    "example": 1435
+                },
+                "diaChi": {
+                    "description": "Branch address stored in CHINHANH",
+                    "type": "string",
+                    "example": "789 Trần Hưng Đạo, Quận 5, TP.HCM"
+                },
+                "name": {
+                    "description": "Site name in the topology",
+                    "type": "string",
+                    "example": "Site_TV_Q5"
+                },
+                "serviceURL": {
+                    "description": "URL the site service will listen on",
+                    "type": "string",
+                    "example": "http://localhost:8085"
+                },
+                "siteId": {
+                    "description": "New branch site ID (becomes its MaCN)",
+                    "type": "string",
+                    "example": "Q5"
+                },
+                "sourceSite": {
+                    "description": "Replica to copy SACH/CHINHANH from (defaults to the first site)",
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "tenCN": {
+                    "description": "Branch name stored in CHINHANH",
+                    "type": "string",
+                    "example": "Thư viện Quận 5"
+                }
+            }
+        },
+        "main.RelocateFragmentRequest": {
+            "type": "object",
+            "required": [
+                "branch",
+                "targetSite"
+            ],
+            "properties": {
+                "branch": {
+                    "description": "Branch (MaCN) whose fragments are moved",
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "restart": {
+                    "description": "Discard a partial copy on the target instead of resuming",
+                    "type": "boolean",
+                    "example": false
+                },
+                "targetSite": {
+                    "description": "Site that will store the fragments",
+                    "type": "string",
+                    "example": "Q3"
+                }
+            }
+        },
+        "main.TransferBookRequest": {
+            "type": "object",
+            "required": [
+                "fromSite",
+                "maQuyenSach",
+                "toSite"
+            ],
+            "properties": {
+                "fromSite": {
+                    "description": "Source site ID",
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "maQuyenSach": {
+                    "description": "Book copy ID to transfer",
+                    "type": "string",
+                    "example": "QS001"
+                },
+                "toSite": {
+                    "description": "Destination site ID",
+                    "type": "string",
+                    "example": "Q3"
+                }
+            }
+        },
+        "membership.Heartbeat": {
+            "type": "object",
+            "properties": {
+                "databaseHealthy": {
+                    "description": "Sender can reach its own database",
+                    "type": "boolean",
+                    "example": true
+                },
+                "incarnation": {
+                    "description": "Changes when the service restarts",
+                    "type": "integer",
+                    "example": 1736935200000000000
+                },
+                "sentAt": {
+                    "type": "string"
+                },
+                "siteId": {
+                    "type": "string",
+                    "example": "Q1"
+                }
+            }
+        },
+        "membership.Member": {
+            "type": "object",
+            "properties": {
+                "databaseHealthy": {
+                    "type": "boolean",
+                    "example": true
+                },
+                "incarnation": {
+                    "type": "integer"
+                },
+                "lastError": {
+                    "type": "string"
+                },
+                "lastSeen": {
+                    "type": "string"
+                },
+                "serviceURL": {
+                    "type": "string",
+                    "example": "http://localhost:8083"
+                },
+                "siteId": {
+                    "type": "string",
+                    "example": "Q3"
+                },
+                "state": {
+                    "type": "string",
+                    "example": "UP"
+                }
+            }
+        },
+        "membership.Transition": {
+            "type": "object",
+            "properties": {
+                "at": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "string",
+                    "example": "UP"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "no heartbeat for 6s"
+                },
+                "siteId": {
+                    "type": "string",
+                    "example": "Q3"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "SUSPECT"
+                }
+            }
+        },
+        "membership.View": {
+            "type": "object",
+            "properties": {
+                "history": {
+                    "description": "Most recent last",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/membership.Transition"
+                    }
+                },
+                "members": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/membership.Member"
+                    }
+                },
+                "self": {
+                    "type": "string",
+                    "example": "Q1"
+                }
+            }
+        },
+        "models.BatchBorrowRequest": {
+            "description": "Request payload for a batch checkout: every copy is lent, or none is",
+            "type": "object",
+            "required": [
+                "maDG",
+                "maQuyenSach"
+            ],
+            "properties": {
+                "maDG": {
+                    "description": "Reader ID",
+                    "type": "string",
+                    "example": "DG001"
+                },
+                "maQuyenSach": {
+                    "description": "Book copy IDs",
+                    "type": "array",
+                    "maxItems": 50,
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "QS001",
+                        "QS002"
+                    ]
+                }
+            }
+        },
+        "models.BatchIncomplete": {
+            "description": "Best-effort batch whose transactions committed on some databases only. The items marked successful were returned and stay returned; the others are still on loan and can be returned again.",
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "description": "Explanation",
+                    "type": "string",
+                    "example": "2 of 3 copies returned: failed to commit returns on site Q3"
+                },
+                "receipt": {
+                    "description": "Outcome per copy",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.BatchReceipt"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.BatchItem": {
+            "description": "Loan made, or return outcome, for one copy of a batch; or why the copy blocked the batch",
+            "type": "object",
+            "properties": {
+                "borrow": {
+                    "description": "Loan made for the copy",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.PhieuMuon"
+                        }
+                    ]
+                },
+                "error": {
+                    "description": "Why the copy blocked the batch",
+                    "type": "string",
+                    "example": "book copy QS002 is not available (status: Bị hỏng)"
+                },
+                "fine": {
+                    "description": "Fine for a late return",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Phat"
+                        }
+                    ]
+                },
+                "hold": {
+                    "description": "Hold served by the returned copy",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.DatCho"
+                        }
+                    ]
+                },
+                "maQuyenSach": {
+                    "description": "Book copy ID",
+                    "type": "string",
+                    "example": "QS001"
+                },
+                "success": {
+                    "description": "Whether the copy passed validation and, for a committed batch, was processed",
+                    "type": "boolean",
+                    "example": true
+                },
+                "transfer": {
+                    "description": "Shipment home of a copy returned at another branch",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.ChuyenTra"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.BatchReceipt": {
+            "description": "Outcome of every copy of a batch, committed together. When the batch is rejected, no copy was lent or returned and the items say which copies blocked it.",
+            "type": "object",
+            "properties": {
+                "committed": {
+                    "description": "Whether the whole batch was applied",
+                    "type": "boolean",
+                    "example": true
+                },
+                "dueDate": {
+                    "description": "Earliest due date of the loans made",
+                    "type": "string"
+                },
+                "items": {
+                    "description": "Outcome per copy, in request order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BatchItem"
+                    }
+                },
+                "maCN": {
+                    "description": "Branch that processed the batch",
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "maDG": {
+                    "description": "Reader (checkout)",
+                    "type": "string",
+                    "example": "DG001"
+                },
+                "processedAt": {
+                    "description": "When the batch was processed",
+                    "type": "string",
+                    "example": "2025-01-15T10:30:00Z"
+                },
+                "totalFine": {
+                    "description": "Total of the fines assessed for late returns (VND)",
+                    "type": "integer",
+                    "example": 15000
+                },
+                "violation": {
+                    "description": "Circulation rule that blocked the whole checkout",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.PolicyViolation"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.BatchRejection": {
+            "description": "Batch refused during validation or processing; no copy was lent or returned",
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "description": "Explanation",
+                    "type": "string",
+                    "example": "2 of 3 copies cannot be lent"
+                },
+                "receipt": {
+                    "description": "Outcome per copy",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.BatchReceipt"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.BatchReturnRequest": {
+            "description": "Request payload for a batch return: every copy is returned, or none is",
+            "type": "object",
+            "required": [
+                "maQuyenSach"
+            ],
+            "properties": {
+                "maQuyenSach": {
+                    "description": "Book copy IDs",
+                    "type": "array",
+                    "maxItems": 50,
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "QS001",
+                        "QS002"
+                    ]
+                }
+            }
+        },
+        "models.BookStatistics": {
+            "description": "Book copy statistics; system-wide values are merged from per-site partial aggregates",
+            "type": "object",
+            "properties": {
+                "availableCopies": {
+                    "description": "Copies available for borrowing",
+                    "type": "integer",
+                    "example": 420
+                },
+                "borrowedCopies": {
+                    "description": "Copies on loan",
+                    "type": "integer",
+                    "example": 80
+                },
+                "totalCopies": {
+                    "description": "Book copies",
+                    "type": "integer",
+                    "example": 500
+                },
+                "uniqueTitles": {
+                    "description": "Distinct ISBNs with copies; estimated when merged from several sites",
+                    "type": "integer",
+                    "example": 120
+                },
+                "utilizationRate": {
+                    "description": "Borrowed copies over all copies, in percent",
+                    "type": "number",
+                    "example": 16
+                }
+            }
+        },
+        "models.BookWithAvailability": {
+            "description": "Book information combined with availability count for client applications",
+            "type": "object",
+            "properties": {
+                "availableCount": {
+                    "description": "Available copies count",
+                    "type": "integer",
+                    "example": 5
+                },
+                "borrowedCount": {
+                    "description": "Currently borrowed count",
+                    "type": "integer",
+                    "example": 5
+                },
+                "isbn": {
+                    "description": "Book ISBN (ID)",
+                    "type": "string",
+                    "example": "978-0-123456-78-9"
+                },
+                "tacGia": {
+                    "description": "Author name",
+                    "type": "string",
+                    "example": "Nguyễn Văn A"
+                },
+                "tenSach": {
+                    "description": "Book title",
+                    "type": "string",
+                    "example": "Lập trình Go"
+                },
+                "totalCount": {
+                    "description": "Total copies count",
+                    "type": "integer",
+                    "example": 10
+                }
+            }
+        },
+        "models.BorrowTrend": {
+            "description": "Number of borrows made on a day",
+            "type": "object",
+            "properties": {
+                "borrowCount": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "date": {
+                    "type": "string",
+                    "example": "2025-01-15"
+                }
+            }
+        },
+        "models.BranchFines": {
+            "description": "Outstanding fines of the loans made at one branch",
+            "type": "object",
+            "properties": {
+                "dailyRate": {
+                    "description": "Fine per day overdue for readers of the default category",
+                    "type": "integer",
+                    "example": 5000
+                },
+                "maCN": {
+                    "description": "Branch code",
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "overdueLoans": {
+                    "description": "Loans past due, not yet returned",
+                    "type": "integer",
+                    "example": 4
+                },
+                "readersOwing": {
+                    "description": "Readers with an outstanding fine here",
+                    "type": "integer",
+                    "example": 7
+                },
+                "totalAccruing": {
+                    "description": "Fines building up on those loans",
+                    "type": "integer",
+                    "example": 60000
+                },
+                "totalUnpaid": {
+                    "description": "Outstanding fines assessed on returns",
+                    "type": "integer",
+                    "example": 250000
+                },
+                "unpaidFines": {
+                    "description": "Fines not settled",
+                    "type": "integer",
+                    "example": 10
+                }
+            }
+        },
+        "models.CacheFlushResponse": {
+            "description": "Sites whose cache was flushed and the ones that could not be reached",
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "description": "Entries at these sites expire after the TTL",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SiteFailure"
+                    }
+                },
+                "flushed": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "Q1",
+                        "Q3"
+                    ]
+                }
+            }
+        },
+        "models.CacheStatsResponse": {
+            "description": "Use of the in-process cache of replicated tables (SACH, CHINHANH) at the site serving the request",
+            "type": "object",
+            "properties": {
+                "relations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/cache.Stats"
+                    }
+                },
+                "site": {
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "ttl": {
+                    "description": "Entry lifetime, bounds staleness when an invalidation is lost",
+                    "type": "string",
+                    "example": "5m0s"
+                }
+            }
+        },
+        "models.CatalogFacets": {
+            "description": "Counts per filter value across all fragments; each facet applies every filter except its own",
+            "type": "object",
+            "properties": {
+                "authors": {
+                    "description": "Books by the author, most frequent first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FacetCount"
+                    }
+                },
+                "branches": {
+                    "description": "Books with an available copy at the branch",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FacetCount"
+                    }
+                },
+                "hasCopies": {
+                    "description": "Books with (\"true\") and without (\"false\") copies",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FacetCount"
+                    }
+                },
+                "statuses": {
+                    "description": "Books with at least one copy in the status",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.FacetCount"
+                    }
+                }
+            }
+        },
+        "models.ChinhSach": {
+            "description": "Circulation policy of a branch and reader category. A policy without a branch applies at every branch, one without a category to every reader category; the most specific policy applies. Amounts are in VND.",
+            "type": "object",
+            "properties": {
+                "loaiDG": {
+                    "description": "Reader category; empty for every category",
+                    "type": "string",
+                    "example": "Sinh viên"
+                },
+                "maCN": {
+                    "description": "Branch; empty for every branch",
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "maCS": {
+                    "description": "Policy ID",
+                    "type": "string",
+                    "example": "CS-Q1-SV"
+                },
+                "mucPhat": {
+                    "description": "Fine per day overdue",
+                    "type": "integer",
+                    "example": 3000
+                },
+                "soLanGiaHan": {
+                    "description": "Renewals allowed per loan",
+                    "type": "integer",
+                    "example": 1
+                },
+                "soNgayAnHan": {
+                    "description": "Days overdue not charged",
+                    "type": "integer",
+                    "example": 2
+                },
+                "soNgayMuon": {
+                    "description": "Loan length in days",
+                    "type": "integer",
+                    "example": 14
+                },
+                "soSachToiDa": {
+                    "description": "Loans a reader may have at once, at every branch",
+                    "type": "integer",
+                    "example": 5
+                }
+            }
+        },
+        "models.ChuyenTra": {
+            "description": "Copy returned at a branch other than its own, shipped back to its home branch, or copy set aside for a hold, shipped to the hold's pickup branch",
+            "type": "object",
+            "properties": {
+                "isbn": {
+                    "description": "Title of the copy",
+                    "type": "string",
+                    "example": "978-0-123456-78-9"
+                },
+                "maCN": {
+                    "description": "Home branch, which checks the copy in unless shipped for a hold",
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "maCNNhanSach": {
+                    "description": "Pickup branch the copy is shipped to for a hold, which checks it in",
+                    "type": "string",
+                    "example": "Q3"
+                },
+                "maCNNhanTra": {
+                    "description": "Branch that ships the copy: where it was returned, or its home branch for a hold",
+                    "type": "string",
+                    "example": "Q3"
+                },
+                "maCT": {
+                    "description": "Transfer ID",
+                    "type": "string",
+                    "example": "Q1-m2x8k1"
+                },
+                "maDC": {
+                    "description": "Hold the copy is shipped for",
+                    "type": "string",
+                    "example": "Q3-m2x8k1"
+                },
+                "maQuyenSach": {
+                    "description": "Copy shipped",
+                    "type": "string",
+                    "example": "QS001"
+                },
+                "ngayNhan": {
+                    "description": "Check-in date",
+                    "type": "string",
+                    "example": "2025-01-16T09:00:00Z"
+                },
+                "ngayNhanTra": {
+                    "description": "Date the copy was returned or set aside for the hold",
+                    "type": "string",
+                    "example": "2025-01-15T10:00:00Z"
+                },
+                "trangThai": {
+                    "description": "Transfer state",
+                    "type": "string",
+                    "enum": [
+                        "Đang vận chuyển",
+                        "Đã nhận"
+                    ],
+                    "example": "Đang vận chuyển"
+                }
+            }
+        },
+        "models.CopyStatus": {
+            "type": "string",
+            "enum": [
+                "Có sẵn",
+                "Đang được mượn",
+                "Đang giữ chỗ",
+                "Đang vận chuyển",
+                "Mượn liên thư viện",
+                "Bị hỏng",
+                "Đang sửa chữa",
+                "Bị mất",
+                "Đã thanh lý"
+            ],
+            "x-enum-comments": {
+                "CopyAtBorrower": "At the branch that requested it through an inter-library loan",
+                "CopyAvailable": "On the shelf, free to lend",
+                "CopyDamaged": "Out of circulation until repaired",
+                "CopyInRepair": "Being repaired",
+                "CopyInTransit": "Shipped between branches",
+                "CopyLost": "Lost by a reader or missing from the shelf",
+                "CopyOnHold": "Set aside for a ready hold",
+                "CopyOnLoan": "Lent to a reader",
+                "CopyWithdrawn": "Removed from circulation for good"
+            },
+            "x-enum-descriptions": [
+                "On the shelf, free to lend",
+                "Lent to a reader",
+                "Set aside for a ready hold",
+                "Shipped between branches",
+                "At the branch that requested it through an inter-library loan",
+                "Out of circulation until repaired",
+                "Being repaired",
+                "Lost by a reader or missing from the shelf",
+                "Removed from circulation for good"
+            ],
+            "x-enum-varnames": [
+                "CopyAvailable",
+                "CopyOnLoan",
+                "CopyOnHold",
+                "CopyInTransit",
+                "CopyAtBorrower",
+                "CopyDamaged",
+                "CopyInRepair",
+                "CopyLost",
+                "CopyWithdrawn"
+            ]
+        },
+        "models.CreateBorrowRequest": {
+            "description": "Request payload for creating a borrow transaction",
+            "type": "object",
+            "required": [
+                "maDG",
+                "maQuyenSach"
+            ],
+            "properties": {
+                "maDG": {
+                    "description": "Reader ID",
+                    "type": "string",
+                    "example": "DG001"
+                },
+                "maQuyenSach": {
+                    "description": "Book copy ID",
+                    "type": "string",
+                    "example": "QS001"
+                }
+            }
+        },
+        "models.DatCho": {
+            "description": "Hold queueing a reader for a title",
+            "type": "object",
+            "required": [
+                "isbn",
+                "maDG"
+            ],
+            "properties": {
+                "hanNhan": {
+                    "description": "Pickup deadline once ready",
+                    "type": "string",
+                    "example": "2025-01-18T10:00:00Z"
+                },
+                "isbn": {
+                    "description": "Title held",
+                    "type": "string",
+                    "example": "978-0-123456-78-9"
+                },
+                "maCN": {
+                    "description": "Branch where the hold was placed",
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "maCNNhanSach": {
+                    "description": "Pickup branch (empty: any branch)",
+                    "type": "string",
+                    "example": "Q3"
+                },
+                "maCNQuyenSach": {
+                    "description": "Branch storing that copy",
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "maDC": {
+                    "description": "Hold ID",
+                    "type": "string",
+                    "example": "Q1-m2x8k1"
+                },
+                "maDG": {
+                    "description": "Reader ID",
+                    "type": "string",
+                    "example": "DG001"
+                },
+                "maQuyenSach": {
+                    "description": "Copy set aside once ready or shipped",
+                    "type": "string",
+                    "example": "QS001"
+                },
+                "ngayDat": {
+                    "description": "Queue order",
+                    "type": "string",
+                    "example": "2025-01-15T10:00:00Z"
+                },
+                "trangThai": {
+                    "description": "Hold state",
+                    "type": "string",
+                    "enum": [
+                        "Đang chờ",
+                        "Đang vận chuyển",
+                        "Sẵn sàng",
+                        "Đã nhận",
+                        "Hết hạn",
+                        "Đã hủy"
+                    ],
+                    "example": "Đang chờ"
+                },
+                "viTri": {
+                    "description": "Position in the queue while waiting",
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "models.DocGia": {
+            "description": "Reader information (fragmented by registration branch)",
+            "type": "object",
+            "required": [
+                "hoTen",
+                "maCNDangKy",
+                "maDG"
+            ],
+            "properties": {
+                "hoTen": {
+                    "description": "Reader name",
+                    "type": "string",
+                    "example": "Nguyễn Văn B"
+                },
+                "loaiDG": {
+                    "description": "Reader category, chooses the circulation policy",
+                    "type": "string",
+                    "example": "Thường"
+                },
+                "maCNDangKy": {
+                    "description": "Registration branch",
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "maDG": {
+                    "description": "Reader ID",
+                    "type": "string",
+                    "example": "DG001"
+                }
+            }
+        },
+        "models.ErrorResponse": {
+            "description": "Generic error response",
+            "type": "object",
+            "properties": {
+                "details": {
+                    "description": "Error details (optional)"
+                },
+                "error": {
+                    "description": "Error message",
+                    "type": "string",
+                    "example": "Bad Request"
+                }
+            }
+        },
+        "models.FacetCount": {
+            "description": "Number of books the search would return with this filter value selected",
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "label": {
+                    "description": "Display name, for branches",
+                    "type": "string",
+                    "example": "Thư Viện Quận 1"
+                },
+                "value": {
+                    "type": "string",
+                    "example": "Q1"
+                }
+            }
+        },
+        "models.FinePaymentRequest": {
+            "description": "Request payload for recording a fine payment",
+            "type": "object",
+            "required": [
+                "soTien"
+            ],
+            "properties": {
+                "ghiChu": {
+                    "description": "Note (optional)",
+                    "type": "string",
+                    "example": "Tiền mặt"
+                },
+                "soTien": {
+                    "description": "Amount paid (VND), at most the outstanding amount",
+                    "type": "integer",
+                    "minimum": 1,
+                    "example": 10000
+                }
+            }
+        },
+        "models.FineReport": {
+            "description": "Outstanding fines at every branch, for managers. Amounts are in VND.",
+            "type": "object",
+            "properties": {
+                "branches": {
+                    "description": "Per branch, in topology order",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.BranchFines"
+                    }
+                },
+                "readersOwing": {
+                    "description": "Readers with an outstanding fine at any branch",
+                    "type": "integer",
+                    "example": 11
+                },
+                "totalAccruing": {
+                    "description": "Fines building up on overdue loans not yet returned",
+                    "type": "integer",
+                    "example": 90000
+                },
+                "totalUnpaid": {
+                    "description": "Outstanding fines assessed on returns",
+                    "type": "integer",
+                    "example": 450000
+                },
+                "unpaidFines": {
+                    "description": "Fines not settled",
+                    "type": "integer",
+                    "example": 18
+                }
+            }
+        },
+        "models.FineWaiverRequest": {
+            "description": "Request payload for waiving all or part of a fine",
+            "type": "object",
+            "required": [
+                "ghiChu"
+            ],
+            "properties": {
+                "ghiChu": {
+                    "description": "Reason",
+                    "type": "string",
+                    "example": "Độc giả nằm viện"
+                },
+                "soTien": {
+                    "description": "Amount waived (VND); 0 waives the whole outstanding amount",
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 0
+                }
+            }
+        },
+        "models.FragmentCatalogResponse": {
+            "description": "Distributed data dictionary: how each global relation is fragmented and where its fragments are stored",
+            "type": "object",
+            "properties": {
+                "relations": {
+                    "description": "Global relations with their fragments",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/catalog.Relation"
+                    }
+                },
+                "sites": {
+                    "description": "Branch sites in the topology",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "Q1",
+                        "Q3"
+                    ]
+                }
+            }
+        },
+        "models.GiaoDichPhat": {
+            "description": "Payment or waiver recorded against a fine",
+            "type": "object",
+            "properties": {
+                "ghiChu": {
+                    "description": "Note or waiver reason",
+                    "type": "string",
+                    "example": "Miễn do sách trả qua hộp trả sách"
+                },
+                "loai": {
+                    "description": "Payment or waiver",
+                    "type": "string",
+                    "enum": [
+                        "Thanh toán",
+                        "Miễn"
+                    ],
+                    "example": "Thanh toán"
+                },
+                "maCN": {
+                    "description": "Branch of the fine",
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "maGD": {
+                    "description": "Transaction ID",
+                    "type": "string",
+                    "example": "Q1-m2x9a4"
+                },
+                "maPhat": {
+                    "description": "Fine ID",
+                    "type": "string",
+                    "example": "Q1-m2x8k1"
+                },
+                "ngayGD": {
+                    "description": "Date recorded",
+                    "type": "string",
+                    "example": "2025-02-21T09:00:00Z"
+                },
+                "nguoiThucHien": {
+                    "description": "Librarian who recorded it",
+                    "type": "string",
+                    "example": "thuthu01"
+                },
+                "soTien": {
+                    "description": "Amount",
+                    "type": "integer",
+                    "example": 10000
+                }
+            }
+        },
+        "models.ILLRequest": {
+            "description": "Request payload for an inter-library loan. Without maCNSoHuu, a branch with a free copy is chosen.",
             "type": "object",
             "required": [
-                "fromSite",
-                "maQuyenSach",
-                "toSite"
+                "isbn",
+                "maDG"
             ],
             "properties": {
-                "fromSite": {
-                    "description": "Source site ID",
+                "isbn": {
+                    "description": "Title requested",
+                    "type": "string",
+                    "example": "978-0-123456-78-9"
+                },
+                "maCNSoHuu": {
+                    "description": "Owning branch (optional)",
                     "type": "string",
                     "example": "Q1"
                 },
+                "maDG": {
+                    "description": "Reader the copy is for",
+                    "type": "string",
+                    "example": "DG004"
+                }
+            }
+        },
+        "models.ILLResponse": {
+            "description": "Request after the step, with the loan made, the fine assessed or the hold served by the step, when any",
+            "type": "object",
+            "properties": {
+                "borrow": {
+                    "description": "Loan made when lending the copy",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.PhieuMuon"
+                        }
+                    ]
+                },
+                "fine": {
+                    "description": "Fine for a late return",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Phat"
+                        }
+                    ]
+                },
+                "hold": {
+                    "description": "Hold served by the copy back on its shelf",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.DatCho"
+                        }
+                    ]
+                },
+                "request": {
+                    "description": "Request after the step",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.YeuCauMuon"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.ILLShipRequest": {
+            "description": "Request payload for shipping a copy. Without maQuyenSach, a free copy of the title is chosen.",
+            "type": "object",
+            "properties": {
                 "maQuyenSach": {
-                    "description": "Book copy ID to transfer",
+                    "description": "Copy to ship (optional)",
                     "type": "string",
                     "example": "QS001"
+                }
+            }
+        },
+        "models.ListResponse": {
+            "description": "Generic paginated list response matching Flutter BookListModel structure",
+            "type": "object",
+            "properties": {
+                "facets": {
+                    "description": "Filter counts of a faceted catalog search",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.CatalogFacets"
+                        }
+                    ]
                 },
-                "toSite": {
-                    "description": "Destination site ID",
+                "items": {
+                    "description": "List of items (matches Flutter items field)"
+                },
+                "metadata": {
+                    "description": "Sites reached by a distributed read (omitted for single-site lists)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.QueryMetadata"
+                        }
+                    ]
+                },
+                "paging": {
+                    "description": "Pagination info (matches Flutter paging field)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.PagingInfo"
+                        }
+                    ]
+                }
+            }
+        },
+        "models.OverdueStatistics": {
+            "description": "Overdue loan statistics; averages are merged from per-site counts and sums",
+            "type": "object",
+            "properties": {
+                "activeBorrows": {
+                    "description": "Loans not yet returned",
+                    "type": "integer",
+                    "example": 80
+                },
+                "avgOverdueDays": {
+                    "description": "Average days past the due date",
+                    "type": "number",
+                    "example": 6.5
+                },
+                "maxOverdueDays": {
+                    "description": "Longest time past the loan period, in days",
+                    "type": "integer",
+                    "example": 21
+                },
+                "overdueRate": {
+                    "description": "Overdue loans over active loans, in percent",
+                    "type": "number",
+                    "example": 15
+                },
+                "totalOverdue": {
+                    "description": "Loans kept past their due date",
+                    "type": "integer",
+                    "example": 12
+                }
+            }
+        },
+        "models.PagingInfo": {
+            "description": "Pagination information matching Flutter PagingModel structure",
+            "type": "object",
+            "properties": {
+                "nextCursor": {
+                    "description": "Pass as ?cursor= to fetch the next page",
                     "type": "string",
-                    "example": "Q3"
+                    "example": "W3sidCI6..."
+                },
+                "page": {
+                    "description": "Current page number (0-based, matches Flutter)",
+                    "type": "integer",
+                    "example": 0
+                },
+                "size": {
+                    "description": "Items per page (matches Flutter)",
+                    "type": "integer",
+                    "example": 20
+                },
+                "totalPages": {
+                    "description": "Total number of pages (matches Flutter)",
+                    "type": "integer",
+                    "example": 10
                 }
             }
         },
-        "models.BookWithAvailability": {
-            "description": "Book information combined with availability count for client applications",
+        "models.Phat": {
+            "description": "Overdue fine assessed when a late loan is returned. Amounts are in VND.",
             "type": "object",
             "properties": {
-                "availableCount": {
-                    "description": "Available copies count",
+                "conLai": {
+                    "description": "Amount outstanding",
                     "type": "integer",
-                    "example": 5
+                    "example": 20000
                 },
-                "borrowedCount": {
-                    "description": "Currently borrowed count",
+                "daMien": {
+                    "description": "Amount waived",
                     "type": "integer",
-                    "example": 5
+                    "example": 0
                 },
-                "isbn": {
-                    "description": "Book ISBN (ID)",
+                "daThanhToan": {
+                    "description": "Amount paid",
+                    "type": "integer",
+                    "example": 10000
+                },
+                "giaoDich": {
+                    "description": "Payments and waivers, oldest first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.GiaoDichPhat"
+                    }
+                },
+                "hanTra": {
+                    "description": "Due date",
                     "type": "string",
-                    "example": "978-0-123456-78-9"
+                    "example": "2025-02-14T10:00:00Z"
                 },
-                "tacGia": {
-                    "description": "Author name",
+                "maCN": {
+                    "description": "Branch that made the loan",
                     "type": "string",
-                    "example": "Nguyễn Văn A"
+                    "example": "Q1"
                 },
-                "tenSach": {
-                    "description": "Book title",
+                "maDG": {
+                    "description": "Reader ID",
                     "type": "string",
-                    "example": "Lập trình Go"
+                    "example": "DG001"
                 },
-                "totalCount": {
-                    "description": "Total copies count",
+                "maPhat": {
+                    "description": "Fine ID",
+                    "type": "string",
+                    "example": "Q1-m2x8k1"
+                },
+                "maQuyenSach": {
+                    "description": "Book copy of the loan",
+                    "type": "string",
+                    "example": "QS001"
+                },
+                "mucPhat": {
+                    "description": "Daily rate of the branch at return",
                     "type": "integer",
-                    "example": 10
+                    "example": 5000
+                },
+                "ngayMuon": {
+                    "description": "Borrow date",
+                    "type": "string",
+                    "example": "2025-01-15T10:00:00Z"
+                },
+                "ngayTao": {
+                    "description": "Assessment date",
+                    "type": "string",
+                    "example": "2025-02-20T14:00:00Z"
+                },
+                "ngayTra": {
+                    "description": "Return date",
+                    "type": "string",
+                    "example": "2025-02-20T14:00:00Z"
+                },
+                "soNgayQuaHan": {
+                    "description": "Days overdue",
+                    "type": "integer",
+                    "example": 6
+                },
+                "soTien": {
+                    "description": "Amount assessed",
+                    "type": "integer",
+                    "example": 30000
+                },
+                "trangThai": {
+                    "description": "Fine state",
+                    "type": "string",
+                    "enum": [
+                        "Chưa thanh toán",
+                        "Đã thanh toán",
+                        "Đã miễn"
+                    ],
+                    "example": "Chưa thanh toán"
                 }
             }
         },
-        "models.CreateBorrowRequest": {
-            "description": "Request payload for creating a borrow transaction",
+        "models.PhieuMuon": {
+            "description": "Borrow transaction (fragmented by branch)",
             "type": "object",
             "required": [
+                "maCN",
                 "maDG",
                 "maQuyenSach"
             ],
             "properties": {
+                "hanTra": {
+                    "description": "Due date, pushed back by renewals",
+                    "type": "string",
+                    "example": "2025-02-14T10:00:00Z"
+                },
+                "maCN": {
+                    "description": "Branch code",
+                    "type": "string",
+                    "example": "Q1"
+                },
                 "maDG": {
                     "description": "Reader ID",
                     "type": "string",
                     "example": "DG001"
                 },
+                "maPM": {
+                    "description": "Borrow ID (auto-generated)",
+                    "type": "integer",
+                    "example": 1
+                },
                 "maQuyenSach": {
                     "description": "Book copy ID",
                     "type": "string",
                     "example": "QS001"
+                },
+                "ngayMuon": {
+                    "description": "Borrow date",
+                    "type": "string",
+                    "example": "2025-01-15T10:00:00Z"
+                },
+                "ngayTra": {
+                    "description": "Return date (null if not returned)",
+                    "type": "string",
+                    "example": "2025-01-20T14:00:00Z"
+                },
+                "soLanGiaHan": {
+                    "description": "Renewals so far",
+                    "type": "integer",
+                    "example": 0
                 }
             }
         },
-        "models.DocGia": {
-            "description": "Reader information (fragmented by registration branch)",
+        "models.PlaceHoldRequest": {
+            "description": "Request payload for placing a hold on a title",
             "type": "object",
             "required": [
-                "hoTen",
-                "maCNDangKy",
+                "isbn",
                 "maDG"
             ],
             "properties": {
-                "hoTen": {
-                    "description": "Reader name",
+                "isbn": {
+                    "description": "Title to hold",
                     "type": "string",
-                    "example": "Nguyễn Văn B"
+                    "example": "978-0-123456-78-9"
                 },
-                "maCNDangKy": {
-                    "description": "Registration branch",
+                "maCNNhanSach": {
+                    "description": "Pickup branch (optional, any branch when empty)",
                     "type": "string",
-                    "example": "Q1"
+                    "example": "Q3"
                 },
                 "maDG": {
                     "description": "Reader ID",
@@ -1983,55 +6220,125 @@ const docTemplate = `{
                 }
             }
         },
-        "models.ErrorResponse": {
-            "description": "Generic error response",
+        "models.PolicyRequest": {
+            "description": "Request payload for a circulation policy. Leave maCN or loaiDG empty for a policy of every branch or category.",
+            "type": "object",
+            "required": [
+                "soNgayMuon"
+            ],
+            "properties": {
+                "loaiDG": {
+                    "description": "Reader category (optional)",
+                    "type": "string",
+                    "example": "Sinh viên"
+                },
+                "maCN": {
+                    "description": "Branch (optional)",
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "mucPhat": {
+                    "description": "Fine per day overdue (VND)",
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 3000
+                },
+                "soLanGiaHan": {
+                    "description": "Renewals allowed per loan",
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 1
+                },
+                "soNgayAnHan": {
+                    "description": "Days overdue not charged",
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 2
+                },
+                "soNgayMuon": {
+                    "description": "Loan length in days",
+                    "type": "integer",
+                    "minimum": 1,
+                    "example": 14
+                },
+                "soSachToiDa": {
+                    "description": "Loans a reader may have at once",
+                    "type": "integer",
+                    "minimum": 0,
+                    "example": 5
+                }
+            }
+        },
+        "models.PolicyViolation": {
+            "description": "Rule of the applicable circulation policy that blocked a loan or renewal",
             "type": "object",
             "properties": {
-                "details": {
-                    "description": "Error details (optional)"
+                "actual": {
+                    "description": "Value the action would exceed",
+                    "type": "integer",
+                    "example": 5
                 },
-                "error": {
-                    "description": "Error message",
+                "limit": {
+                    "description": "Limit set by the rule",
+                    "type": "integer",
+                    "example": 5
+                },
+                "maCS": {
+                    "description": "Policy the rule belongs to; \"default\" for the configured defaults",
                     "type": "string",
-                    "example": "Bad Request"
-                }
-            }
-        },
-        "models.ListResponse": {
-            "description": "Generic paginated list response matching Flutter BookListModel structure",
-            "type": "object",
-            "properties": {
-                "items": {
-                    "description": "List of items (matches Flutter items field)"
+                    "example": "CS-Q1-SV"
                 },
-                "paging": {
-                    "description": "Pagination info (matches Flutter paging field)",
-                    "allOf": [
-                        {
-                            "$ref": "#/definitions/models.PagingInfo"
-                        }
-                    ]
+                "reason": {
+                    "description": "Explanation",
+                    "type": "string",
+                    "example": "reader DG001 has reached maximum borrow limit (5 books)"
+                },
+                "rule": {
+                    "description": "Rule that blocked the action",
+                    "type": "string",
+                    "enum": [
+                        "soSachToiDa",
+                        "soLanGiaHan",
+                        "quaHan",
+                        "nguongChanPhat"
+                    ],
+                    "example": "soSachToiDa"
                 }
             }
         },
-        "models.PagingInfo": {
-            "description": "Pagination information matching Flutter PagingModel structure",
+        "models.QueryMetadata": {
+            "description": "Which sites a distributed read reached; partial results omit the data of failed sites",
             "type": "object",
             "properties": {
-                "page": {
-                    "description": "Current page number (0-based, matches Flutter)",
-                    "type": "integer",
-                    "example": 0
+                "partial": {
+                    "description": "Some sites failed, so the data is incomplete",
+                    "type": "boolean",
+                    "example": true
                 },
-                "size": {
-                    "description": "Items per page (matches Flutter)",
-                    "type": "integer",
-                    "example": 20
+                "sitesFailed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SiteFailure"
+                    }
                 },
-                "totalPages": {
-                    "description": "Total number of pages (matches Flutter)",
-                    "type": "integer",
-                    "example": 10
+                "sitesQueried": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "Q1",
+                        "Q3"
+                    ]
+                },
+                "sitesSucceeded": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "Q1"
+                    ]
                 }
             }
         },
@@ -2061,16 +6368,62 @@ const docTemplate = `{
                 },
                 "tinhTrang": {
                     "description": "Book status",
-                    "type": "string",
                     "enum": [
                         "Có sẵn",
-                        "Đang mượn",
-                        "Bị hỏng"
+                        "Đang được mượn",
+                        "Đang giữ chỗ",
+                        "Đang vận chuyển",
+                        "Mượn liên thư viện",
+                        "Bị hỏng",
+                        "Đang sửa chữa",
+                        "Bị mất",
+                        "Đã thanh lý"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.CopyStatus"
+                        }
                     ],
                     "example": "Có sẵn"
                 }
             }
         },
+        "models.ReaderStatistics": {
+            "description": "Reader statistics; distinct reader counts are estimated when merged from several sites",
+            "type": "object",
+            "properties": {
+                "activeReaders": {
+                    "description": "Readers who have borrowed at least once",
+                    "type": "integer",
+                    "example": 150
+                },
+                "avgBooksPerReader": {
+                    "description": "Borrow records per registered reader",
+                    "type": "number",
+                    "example": 4.5
+                },
+                "readersWithCurrentBorrows": {
+                    "description": "Readers with a book on loan",
+                    "type": "integer",
+                    "example": 60
+                },
+                "readersWithOverdue": {
+                    "description": "Readers with an overdue book",
+                    "type": "integer",
+                    "example": 5
+                },
+                "totalBorrows": {
+                    "description": "Borrow records",
+                    "type": "integer",
+                    "example": 900
+                },
+                "totalReaders": {
+                    "description": "Registered readers",
+                    "type": "integer",
+                    "example": 200
+                }
+            }
+        },
         "models.ReturnBookRequest": {
             "description": "Request payload for returning a borrowed book",
             "type": "object",
@@ -2087,6 +6440,36 @@ const docTemplate = `{
                 }
             }
         },
+        "models.ReturnBookResponse": {
+            "description": "Hold the returned copy was set aside for, fine assessed for a late return and transfer home of a copy returned at another branch, when any",
+            "type": "object",
+            "properties": {
+                "fine": {
+                    "description": "Fine for a late return",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Phat"
+                        }
+                    ]
+                },
+                "hold": {
+                    "description": "Hold served by the returned copy",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.DatCho"
+                        }
+                    ]
+                },
+                "transfer": {
+                    "description": "Shipment home of a copy returned at another branch",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.ChuyenTra"
+                        }
+                    ]
+                }
+            }
+        },
         "models.Sach": {
             "description": "Book information (fully replicated across all sites)",
             "type": "object",
@@ -2112,6 +6495,65 @@ const docTemplate = `{
                 }
             }
         },
+        "models.SiteDetailStats": {
+            "description": "Detailed statistics of the user's site",
+            "type": "object",
+            "properties": {
+                "book_stats": {
+                    "$ref": "#/definitions/models.BookStatistics"
+                },
+                "reader_stats": {
+                    "$ref": "#/definitions/models.ReaderStatistics"
+                },
+                "site_id": {
+                    "type": "string",
+                    "example": "Q1"
+                }
+            }
+        },
+        "models.SiteFailure": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "context deadline exceeded"
+                },
+                "siteId": {
+                    "type": "string",
+                    "example": "Q3"
+                }
+            }
+        },
+        "models.SiteHealth": {
+            "description": "Database health of a site",
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Why the site is unhealthy",
+                    "type": "string"
+                },
+                "lastChecked": {
+                    "type": "string",
+                    "example": "2025-01-15T10:00:00Z"
+                },
+                "siteID": {
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "status": {
+                    "description": "\"healthy\" or \"unhealthy\"",
+                    "type": "string",
+                    "example": "healthy"
+                },
+                "tableCounts": {
+                    "description": "Rows per table of the site's fragments, -1 when the count failed",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
         "models.SiteStats": {
             "description": "Site-specific statistics",
             "type": "object",
@@ -2145,11 +6587,27 @@ const docTemplate = `{
                 "data": {
                     "description": "Response data (optional)"
                 },
+                "facets": {
+                    "description": "Filter counts of a faceted catalog search",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.CatalogFacets"
+                        }
+                    ]
+                },
                 "message": {
                     "description": "Success message",
                     "type": "string",
                     "example": "Operation completed successfully"
                 },
+                "metadata": {
+                    "description": "Sites reached by a distributed read (omitted for single-site operations)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.QueryMetadata"
+                        }
+                    ]
+                },
                 "success": {
                     "description": "Operation success status",
                     "type": "boolean",
@@ -2157,6 +6615,57 @@ const docTemplate = `{
                 }
             }
         },
+        "models.SystemHealth": {
+            "description": "Database health across all sites",
+            "type": "object",
+            "properties": {
+                "healthySites": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "lastChecked": {
+                    "type": "string",
+                    "example": "2025-01-15T10:00:00Z"
+                },
+                "sites": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/models.SiteHealth"
+                    }
+                },
+                "systemStatus": {
+                    "description": "\"healthy\", \"degraded\" or \"unhealthy\"",
+                    "type": "string",
+                    "example": "healthy"
+                },
+                "totalSites": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "unhealthySites": {
+                    "type": "integer",
+                    "example": 0
+                }
+            }
+        },
+        "models.SystemStats": {
+            "description": "Distributed system statistics",
+            "type": "object",
+            "properties": {
+                "statsBySite": {
+                    "description": "Statistics by site",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/models.SiteStats"
+                    }
+                },
+                "totalBooksOnLoan": {
+                    "description": "Total books on loan across all sites",
+                    "type": "integer",
+                    "example": 150
+                }
+            }
+        },
         "models.SystemStatsResponse": {
             "description": "Comprehensive system statistics across all sites",
             "type": "object",
@@ -2166,11 +6675,35 @@ const docTemplate = `{
                     "type": "integer",
                     "example": 500
                 },
+                "books": {
+                    "description": "Copy statistics merged from all sites",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.BookStatistics"
+                        }
+                    ]
+                },
                 "generatedAt": {
                     "description": "Stats generation time",
                     "type": "string",
                     "example": "2025-01-15T10:00:00Z"
                 },
+                "metadata": {
+                    "description": "Sites the statistics were collected from",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.QueryMetadata"
+                        }
+                    ]
+                },
+                "overdue": {
+                    "description": "Overdue statistics merged from all sites",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.OverdueStatistics"
+                        }
+                    ]
+                },
                 "overdueBooks": {
                     "description": "Overdue books",
                     "type": "integer",
@@ -2183,6 +6716,14 @@ const docTemplate = `{
                         "$ref": "#/definitions/models.BookWithAvailability"
                     }
                 },
+                "readers": {
+                    "description": "Reader statistics merged from all sites",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.ReaderStatistics"
+                        }
+                    ]
+                },
                 "siteStats": {
                     "description": "Per-site statistics",
                     "type": "array",
@@ -2269,6 +6810,42 @@ const docTemplate = `{
                 }
             }
         },
+        "models.TransitionError": {
+            "description": "Move of a book copy between two statuses that the copy lifecycle does not allow",
+            "type": "object",
+            "properties": {
+                "allowed": {
+                    "description": "Statuses the copy may be set to by hand",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.CopyStatus"
+                    }
+                },
+                "from": {
+                    "description": "Current status",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.CopyStatus"
+                        }
+                    ],
+                    "example": "Đang được mượn"
+                },
+                "reason": {
+                    "description": "Explanation",
+                    "type": "string",
+                    "example": "a copy cannot go from Đang được mượn to Đang sửa chữa"
+                },
+                "to": {
+                    "description": "Requested status",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.CopyStatus"
+                        }
+                    ],
+                    "example": "Đang sửa chữa"
+                }
+            }
+        },
         "models.UserInfo": {
             "description": "Detailed user information including permissions",
             "type": "object",
@@ -2306,6 +6883,74 @@ const docTemplate = `{
                     "example": "ThuThu_Q1"
                 }
             }
+        },
+        "models.YeuCauMuon": {
+            "description": "Inter-library loan of a copy owned by another branch. The copy stays in its owner's fragment throughout.",
+            "type": "object",
+            "properties": {
+                "isbn": {
+                    "description": "Title requested",
+                    "type": "string",
+                    "example": "978-0-123456-78-9"
+                },
+                "maCN": {
+                    "description": "Requesting branch, which lends the copy",
+                    "type": "string",
+                    "example": "Q3"
+                },
+                "maCNSoHuu": {
+                    "description": "Owning branch, which ships the copy",
+                    "type": "string",
+                    "example": "Q1"
+                },
+                "maDG": {
+                    "description": "Reader the copy is for",
+                    "type": "string",
+                    "example": "DG004"
+                },
+                "maQuyenSach": {
+                    "description": "Copy shipped, once shipped",
+                    "type": "string",
+                    "example": "QS001"
+                },
+                "maYC": {
+                    "description": "Request ID",
+                    "type": "string",
+                    "example": "Q3-m2x8k1"
+                },
+                "ngayCapNhat": {
+                    "description": "Date of the last step",
+                    "type": "string",
+                    "example": "2025-01-16T09:00:00Z"
+                },
+                "ngayYeuCau": {
+                    "description": "Request date",
+                    "type": "string",
+                    "example": "2025-01-15T10:00:00Z"
+                },
+                "trangThai": {
+                    "description": "Request state",
+                    "type": "string",
+                    "enum": [
+                        "Đã yêu cầu",
+                        "Đang chuyển đến",
+                        "Đã đến",
+                        "Đang cho mượn",
+                        "Đang trả về",
+                        "Hoàn tất",
+                        "Đã hủy"
+                    ],
+                    "example": "Đã yêu cầu"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "BearerAuth": {
+            "description": "Type \"Bearer\" followed by a space and JWT token.",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -2315,7 +6960,7 @@ var SwaggerInfo = &swag.Spec{
 	Version:          "1.0",
 	Host:             "localhost:8080",
 	BasePath:         "/",
-	Schemes:          []string{"http", "https"},
+	Schemes:          []string{},
 	Title:            "Distributed Transaction Coordinator API",
 	Description:      "Coordinator service for distributed transactions in the library management system",
 	InfoInstanceName: "swagger",
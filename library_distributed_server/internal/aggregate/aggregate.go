@@ -0,0 +1,59 @@
+// Package aggregate holds partial aggregates that each site computes over its own
+// fragments and that merge into correct global values. Counts and sums add up, but an
+// average or a distinct count of two sites does not: a Stat keeps the count, sum, min
+// and max behind an average, and a Distinct keeps a HyperLogLog sketch of the values.
+package aggregate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+)
+
+// Stat is a partial aggregate of numeric values
+type Stat struct {
+	Count int
+	Sum   float64
+	Min   float64 // Meaningless when Count is 0
+	Max   float64
+}
+
+// Merge returns the aggregate of the values of both
+func (s Stat) Merge(o Stat) Stat {
+	switch {
+	case o.Count == 0:
+		return s
+	case s.Count == 0:
+		return o
+	}
+	return Stat{
+		Count: s.Count + o.Count,
+		Sum:   s.Sum + o.Sum,
+		Min:   math.Min(s.Min, o.Min),
+		Max:   math.Max(s.Max, o.Max),
+	}
+}
+
+// Mean returns the average value, 0 when there are none
+func (s Stat) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// QueryStat aggregates the values of expr over the rows of from, a table optionally
+// followed by a WHERE clause whose parameters are args
+func QueryStat(ctx context.Context, db *sql.DB, expr, from string, args ...interface{}) (Stat, error) {
+	var s Stat
+	var sum, lowest, highest sql.NullFloat64
+	err := db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT COUNT(%[1]s), SUM(CAST(%[1]s AS FLOAT)), MIN(CAST(%[1]s AS FLOAT)), MAX(CAST(%[1]s AS FLOAT)) FROM %[2]s", expr, from),
+		args...).Scan(&s.Count, &sum, &lowest, &highest)
+	if err != nil {
+		return s, err
+	}
+	s.Sum, s.Min, s.Max = sum.Float64, lowest.Float64, highest.Float64
+	return s, nil
+}
@@ -0,0 +1,111 @@
+package aggregate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+)
+
+// Sketch size: 2^precision registers give a standard error of about 3%
+const (
+	precision = 10
+	registers = 1 << precision
+)
+
+// Distinct estimates the number of distinct values of a column over several sites with a
+// HyperLogLog sketch. Each site hashes its values with SHA-256 in SQL and returns only the
+// highest rank per register, so merging sites never counts a value twice. A sketch read
+// from a single site also carries its exact count, which Estimate prefers.
+type Distinct struct {
+	registers [registers]uint8
+	exact     int // Exact count while known, -1 after merging two non-empty sketches
+}
+
+// NewDistinct creates an empty sketch
+func NewDistinct() *Distinct {
+	return &Distinct{}
+}
+
+// Merge adds the values of another sketch
+func (d *Distinct) Merge(o *Distinct) {
+	if o == nil {
+		return
+	}
+	for i, rank := range o.registers {
+		if rank > d.registers[i] {
+			d.registers[i] = rank
+		}
+	}
+	switch {
+	case o.exact == 0:
+	case d.exact == 0:
+		d.exact = o.exact
+	default:
+		d.exact = -1
+	}
+}
+
+// Estimate returns the number of distinct values: the exact count when the sketch comes
+// from one site, otherwise the HyperLogLog estimate with the small-range correction
+func (d *Distinct) Estimate() int {
+	if d.exact >= 0 {
+		return d.exact
+	}
+
+	sum := 0.0
+	zeros := 0
+	for _, rank := range d.registers {
+		sum += math.Pow(2, -float64(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+	m := float64(registers)
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int(math.Round(estimate))
+}
+
+// sketchSQL computes the sketch registers of the distinct values of column over from.
+// The first two bytes of the hash pick the register and the next four give the rank:
+// their leading zeros plus one. The epsilon keeps LOG from rounding powers of 2 down.
+const sketchSQL = `
+	SELECT r, MAX(k) FROM (
+		SELECT
+			CAST(SUBSTRING(h, 1, 2) AS INT) %% %[1]d AS r,
+			CASE WHEN CAST(SUBSTRING(h, 3, 4) AS BIGINT) = 0 THEN 33
+				ELSE CAST(32 - FLOOR(LOG(CAST(SUBSTRING(h, 3, 4) AS BIGINT), 2) + 1e-12) AS INT) END AS k
+		FROM (SELECT HASHBYTES('SHA2_256', CAST(%[2]s AS VARCHAR(64))) AS h FROM %[3]s) hashed
+	) ranked
+	GROUP BY r`
+
+// QueryDistinct reads the sketch and the exact count of the distinct values of column over
+// the rows of from, a table optionally followed by a WHERE clause whose parameters are args
+func QueryDistinct(ctx context.Context, db *sql.DB, column, from string, args ...interface{}) (*Distinct, error) {
+	d := NewDistinct()
+	err := db.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(DISTINCT %s) FROM %s", column, from), args...).Scan(&d.exact)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(sketchSQL, registers, column, from), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var register, rank int
+		if err := rows.Scan(&register, &rank); err != nil {
+			return nil, err
+		}
+		if register < 0 || register >= registers {
+			return nil, fmt.Errorf("sketch register %d out of range", register)
+		}
+		d.registers[register] = uint8(rank)
+	}
+	return d, rows.Err()
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -9,6 +10,7 @@ import (
 )
 
 type AuthService struct {
+	mutex       sync.RWMutex
 	jwtSecret   []byte
 	tokenExpiry time.Duration
 }
@@ -43,29 +45,47 @@ func NewAuthService(secret string, expiry time.Duration) *AuthService {
 	}
 }
 
+// UpdateSettings replaces the signing secret and token expiry after a configuration reload.
+// Tokens signed with a previous secret stop validating.
+func (s *AuthService) UpdateSettings(secret string, expiry time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.jwtSecret = []byte(secret)
+	s.tokenExpiry = expiry
+}
+
+// settings returns the current signing secret and token expiry
+func (s *AuthService) settings() ([]byte, time.Duration) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.jwtSecret, s.tokenExpiry
+}
+
 func (s *AuthService) GenerateToken(userID, username, role, maCN string) (string, error) {
+	jwtSecret, tokenExpiry := s.settings()
 	claims := &Claims{
 		UserID:   userID,
 		Username: username,
 		Role:     role,
 		MaCN:     maCN,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.tokenExpiry)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(tokenExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.jwtSecret)
+	return token.SignedString(jwtSecret)
 }
 
 func (s *AuthService) ValidateToken(tokenString string) (*Claims, error) {
+	jwtSecret, _ := s.settings()
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
 		}
-		return s.jwtSecret, nil
+		return jwtSecret, nil
 	})
 
 	if err != nil {
@@ -126,12 +146,13 @@ func (s *AuthService) CanAccessSite(claims *Claims, siteID string) bool {
 
 // GetJWTSecretHash returns a hash of the JWT secret for debugging (development only)
 func (s *AuthService) GetJWTSecretHash() string {
-	if len(s.jwtSecret) == 0 {
+	jwtSecret, _ := s.settings()
+	if len(jwtSecret) == 0 {
 		return "empty"
 	}
 	// Return first 8 characters for debugging
-	if len(s.jwtSecret) >= 8 {
-		return string(s.jwtSecret[0:8]) + "..."
+	if len(jwtSecret) >= 8 {
+		return string(jwtSecret[0:8]) + "..."
 	}
-	return string(s.jwtSecret) + "..."
+	return string(jwtSecret) + "..."
 }
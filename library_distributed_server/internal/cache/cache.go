@@ -0,0 +1,188 @@
+// Package cache keeps replicated reference data (SACH, CHINHANH) in memory at a site.
+// Rows are cached by primary key and list results by request. Entries are dropped
+// when a catalog write commits, at this site or at any other one, and expire after
+// a TTL in case an invalidation from another site is lost.
+package cache
+
+import (
+	"library_distributed_server/internal/config"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats reports the use of the cached entries of one relation
+type Stats struct {
+	Relation      string  `json:"relation" example:"SACH"`
+	Rows          int     `json:"rows" example:"120"` // Rows cached by primary key
+	Pages         int     `json:"pages" example:"8"`  // List results cached by request
+	Hits          int64   `json:"hits" example:"950"`
+	Misses        int64   `json:"misses" example:"50"`
+	HitRate       float64 `json:"hitRate" example:"0.95"` // Hits over lookups, 0 before the first lookup
+	Invalidations int64   `json:"invalidations" example:"3"`
+}
+
+// Cache holds replicated rows and list results for one site
+type Cache struct {
+	store *config.Store
+
+	mutex     sync.Mutex
+	relations map[string]*relation
+	listeners []func(Invalidation)
+}
+
+// relation holds the entries of one replicated relation. generation changes on every
+// invalidation so a load that raced with a write does not store what it read.
+type relation struct {
+	rows       map[string]entry
+	pages      map[string]entry
+	generation uint64
+
+	hits, misses, invalidations int64
+}
+
+type entry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// New creates an empty cache; entries live for the configured CACHE_TTL
+func New(store *config.Store) *Cache {
+	return &Cache{
+		store:     store,
+		relations: make(map[string]*relation),
+	}
+}
+
+// Row returns the row of a relation with the given primary key, loading and caching it on a miss.
+// Errors are not cached. A nil cache always loads.
+func Row[T any](c *Cache, name, key string, load func() (T, error)) (T, error) {
+	return lookup(c, name, key, false, load)
+}
+
+// Page returns a list result of a relation identified by key, e.g. the search text and
+// page, loading and caching it on a miss. Any write to the relation drops all its pages.
+func Page[T any](c *Cache, name, key string, load func() (T, error)) (T, error) {
+	return lookup(c, name, key, true, load)
+}
+
+func lookup[T any](c *Cache, name, key string, page bool, load func() (T, error)) (T, error) {
+	if c == nil || c.ttl() <= 0 {
+		return load()
+	}
+
+	c.mutex.Lock()
+	rel := c.relation(name)
+	entries := rel.rows
+	if page {
+		entries = rel.pages
+	}
+	if e, exists := entries[key]; exists && time.Now().Before(e.expires) {
+		rel.hits++
+		c.mutex.Unlock()
+		return e.value.(T), nil
+	}
+	rel.misses++
+	generation := rel.generation
+	c.mutex.Unlock()
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if rel.generation == generation {
+		entries[key] = entry{value: value, expires: time.Now().Add(c.ttl())}
+	}
+	return value, nil
+}
+
+// Invalidate drops the rows with the given keys, or every row when none are given,
+// and every list result of the relation
+func (c *Cache) Invalidate(name string, keys ...string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	rel := c.relation(name)
+	rel.generation++
+	rel.invalidations++
+	if len(keys) == 0 {
+		rel.rows = make(map[string]entry)
+	}
+	for _, key := range keys {
+		delete(rel.rows, key)
+	}
+	rel.pages = make(map[string]entry)
+}
+
+// Flush drops every entry of every relation, keeping the statistics
+func (c *Cache) Flush() {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	names := make([]string, 0, len(c.relations))
+	for name := range c.relations {
+		names = append(names, name)
+	}
+	c.mutex.Unlock()
+
+	for _, name := range names {
+		c.Invalidate(name)
+	}
+}
+
+// Stats returns the statistics of every relation looked up so far, by relation name
+func (c *Cache) Stats() []Stats {
+	stats := []Stats{}
+	if c == nil {
+		return stats
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for name, rel := range c.relations {
+		s := Stats{
+			Relation:      name,
+			Rows:          len(rel.rows),
+			Pages:         len(rel.pages),
+			Hits:          rel.hits,
+			Misses:        rel.misses,
+			Invalidations: rel.invalidations,
+		}
+		if lookups := rel.hits + rel.misses; lookups > 0 {
+			s.HitRate = float64(rel.hits) / float64(lookups)
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Relation < stats[j].Relation })
+	return stats
+}
+
+// OnInvalidate registers fn to run after every invalidation applied to the cache, local
+// or received from another site, to refresh data derived from the replicated relations
+func (c *Cache) OnInvalidate(fn func(Invalidation)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.listeners = append(c.listeners, fn)
+}
+
+// relation returns the entries of a relation, creating them; callers must hold the mutex
+func (c *Cache) relation(name string) *relation {
+	rel, exists := c.relations[name]
+	if !exists {
+		rel = &relation{rows: make(map[string]entry), pages: make(map[string]entry)}
+		c.relations[name] = rel
+	}
+	return rel
+}
+
+// ttl returns the entry lifetime currently in effect
+func (c *Cache) ttl() time.Duration {
+	return c.store.Current().Cache.TTL
+}
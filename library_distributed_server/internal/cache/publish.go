@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"library_distributed_server/internal/config"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Invalidation announces a committed write to a replicated relation
+type Invalidation struct {
+	Relation string   `json:"relation" example:"SACH"`                    // Empty to drop every relation
+	Keys     []string `json:"keys,omitempty" example:"978-0-123456-78-9"` // Primary keys written; empty for the whole relation
+	Origin   string   `json:"origin" example:"Q1"`                        // Site or coordinator that made the write
+}
+
+// Apply drops the entries an invalidation refers to and notifies the listeners
+func (c *Cache) Apply(inv Invalidation) {
+	if c == nil {
+		return
+	}
+	if inv.Relation == "" {
+		c.Flush()
+	} else {
+		c.Invalidate(inv.Relation, inv.Keys...)
+	}
+
+	c.mutex.Lock()
+	listeners := append([]func(Invalidation){}, c.listeners...)
+	c.mutex.Unlock()
+	for _, fn := range listeners {
+		fn(inv)
+	}
+}
+
+// Publisher announces catalog writes to the caches of every branch site
+type Publisher struct {
+	store  *config.Store
+	self   string
+	local  *Cache // Cache of this site, nil for the coordinator
+	client *http.Client
+}
+
+// NewPublisher creates the publisher of site self; local may be nil for members without a cache
+func NewPublisher(store *config.Store, self string, local *Cache) *Publisher {
+	return &Publisher{
+		store:  store,
+		self:   self,
+		local:  local,
+		client: &http.Client{},
+	}
+}
+
+// Publish applies the invalidation to the local cache and sends it to every other branch
+// site in parallel, waiting up to QUERY_SITE_TIMEOUT for each. It returns the sites that
+// were notified and the ones that could not be; entries at the latter expire after CACHE_TTL.
+func (p *Publisher) Publish(inv Invalidation) ([]string, map[string]error) {
+	inv.Origin = p.self
+	p.local.Apply(inv)
+
+	cfg := p.store.Current()
+	notified := []string{}
+	failed := make(map[string]error)
+	if p.local != nil {
+		notified = append(notified, p.self)
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, site := range cfg.Sites {
+		if site.SiteID == p.self {
+			continue
+		}
+		wg.Add(1)
+		go func(site config.SiteConfig) {
+			defer wg.Done()
+			err := p.send(cfg, site.ServiceURL, inv)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				log.Printf("Cache: failed to send invalidation of %q to site %s: %v", inv.Relation, site.SiteID, err)
+				failed[site.SiteID] = err
+				return
+			}
+			notified = append(notified, site.SiteID)
+		}(site)
+	}
+	wg.Wait()
+
+	sort.Strings(notified)
+	return notified, failed
+}
+
+// send posts an invalidation to a branch site
+func (p *Publisher) send(cfg *config.Config, serviceURL string, inv Invalidation) error {
+	body, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Query.SiteTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(serviceURL, "/")+"/cache/invalidate", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(config.SiteSecretHeader, cfg.Auth.SiteSecret)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("invalidation rejected with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+// Package catalog is the global data dictionary of the distributed database:
+// for every global relation it records how the relation is fragmented, the
+// predicate defining each fragment and the sites each fragment is allocated to.
+package catalog
+
+import (
+	"fmt"
+	"library_distributed_server/internal/config"
+	"sort"
+)
+
+// FragmentationType describes how a global relation is split across sites
+type FragmentationType string
+
+const (
+	Horizontal FragmentationType = "HORIZONTAL" // Rows split by a predicate on FragmentKey
+	Replicated FragmentationType = "REPLICATED" // Full copy at every site
+	Vertical   FragmentationType = "VERTICAL"   // Columns split, each fragment keeps the primary key
+)
+
+// Fragment is one piece of a global relation and where it is stored
+type Fragment struct {
+	Name      string   `json:"name" example:"QUYENSACH_Q1"`
+	Predicate string   `json:"predicate,omitempty" example:"MaCN = 'Q1'"`
+	Value     string   `json:"value,omitempty" example:"Q1"` // Fragment key value (horizontal fragments)
+	Columns   []string `json:"columns,omitempty"`            // Projected columns (vertical fragments)
+	Sites     []string `json:"sites"`                        // Allocation
+}
+
+// Relation describes one global relation
+type Relation struct {
+	Name        string            `json:"name" example:"QUYENSACH"`
+	Type        FragmentationType `json:"type" example:"HORIZONTAL"`
+	PrimaryKey  string            `json:"primaryKey" example:"MaQuyenSach"`
+	FragmentKey string            `json:"fragmentKey,omitempty" example:"MaCN"`
+	Columns     []string          `json:"columns"`
+	Fragments   []Fragment        `json:"fragments"`
+}
+
+// relationSchema is the static part of a relation's definition
+type relationSchema struct {
+	Name        string
+	Type        FragmentationType
+	PrimaryKey  string
+	FragmentKey string
+	Columns     []string
+}
+
+// schemas lists the global relations, in dependency order
+var schemas = []relationSchema{
+	{Name: "CHINHANH", Type: Replicated, PrimaryKey: "MaCN", Columns: []string{"MaCN", "TenCN", "DiaChi"}},
+	{Name: "SACH", Type: Replicated, PrimaryKey: "ISBN", Columns: []string{"ISBN", "TenSach", "TacGia"}},
+	{Name: "CHINHSACH", Type: Replicated, PrimaryKey: "MaCS", Columns: []string{"MaCS", "MaCN", "LoaiDG", "SoNgayMuon", "SoSachToiDa", "SoLanGiaHan", "MucPhat", "SoNgayAnHan"}},
+	{Name: "DOCGIA", Type: Horizontal, PrimaryKey: "MaDG", FragmentKey: "MaCN_DangKy", Columns: []string{"MaDG", "HoTen", "MaCN_DangKy", "LoaiDG"}},
+	{Name: "QUYENSACH", Type: Horizontal, PrimaryKey: "MaQuyenSach", FragmentKey: "MaCN", Columns: []string{"MaQuyenSach", "ISBN", "MaCN", "TinhTrang"}},
+	{Name: "PHIEUMUON", Type: Horizontal, PrimaryKey: "MaPM", FragmentKey: "MaCN", Columns: []string{"MaPM", "MaDG", "MaQuyenSach", "MaCN", "NgayMuon", "NgayTra", "HanTra", "SoLanGiaHan"}},
+	{Name: "DATCHO", Type: Horizontal, PrimaryKey: "MaDC", FragmentKey: "MaCN", Columns: []string{"MaDC", "MaDG", "ISBN", "MaCN", "MaCN_NhanSach", "NgayDat", "TrangThai", "MaQuyenSach", "MaCN_QuyenSach", "HanNhan"}},
+	{Name: "PHAT", Type: Horizontal, PrimaryKey: "MaPhat", FragmentKey: "MaCN", Columns: []string{"MaPhat", "MaDG", "MaQuyenSach", "MaCN", "NgayMuon", "HanTra", "NgayTra", "SoNgayQuaHan", "MucPhat", "SoTien", "DaThanhToan", "DaMien", "TrangThai", "NgayTao"}},
+	{Name: "GIAODICHPHAT", Type: Horizontal, PrimaryKey: "MaGD", FragmentKey: "MaCN", Columns: []string{"MaGD", "MaPhat", "MaCN", "Loai", "SoTien", "NgayGD", "NguoiThucHien", "GhiChu"}},
+	{Name: "YEUCAUMUON", Type: Horizontal, PrimaryKey: "MaYC", FragmentKey: "MaCN", Columns: []string{"MaYC", "MaDG", "ISBN", "MaCN", "MaCN_SoHuu", "MaQuyenSach", "TrangThai", "NgayYeuCau", "NgayCapNhat"}},
+	{Name: "CHUYENTRA", Type: Horizontal, PrimaryKey: "MaCT", FragmentKey: "MaCN", Columns: []string{"MaCT", "MaQuyenSach", "ISBN", "MaCN", "MaCN_NhanTra", "MaCN_NhanSach", "MaDC", "TrangThai", "NgayNhanTra", "NgayNhan"}},
+}
+
+// ReplicatedRelations returns the relations fully replicated to every site, in dependency order
+func ReplicatedRelations() []Relation {
+	return relationsOfType(Replicated)
+}
+
+// HorizontalRelations returns the horizontally fragmented relations, in dependency order
+func HorizontalRelations() []Relation {
+	return relationsOfType(Horizontal)
+}
+
+func relationsOfType(t FragmentationType) []Relation {
+	var result []Relation
+	for _, s := range schemas {
+		if s.Type == t {
+			result = append(result, Relation{
+				Name:        s.Name,
+				Type:        s.Type,
+				PrimaryKey:  s.PrimaryKey,
+				FragmentKey: s.FragmentKey,
+				Columns:     s.Columns,
+			})
+		}
+	}
+	return result
+}
+
+// Catalog is an immutable snapshot of the fragmentation and allocation schema
+type Catalog struct {
+	relations map[string]*Relation
+	sites     []string
+}
+
+// FromConfig derives the catalog from the site topology.
+// Each branch owns the horizontal fragment whose key equals its site ID unless an
+// allocation override in the topology places that fragment elsewhere.
+func FromConfig(cfg *config.Config) *Catalog {
+	sites := cfg.SiteIDs()
+
+	overrides := make(map[string]string)
+	for _, a := range cfg.Allocations {
+		overrides[a.Relation+"/"+a.Fragment] = a.Site
+	}
+
+	c := &Catalog{
+		relations: make(map[string]*Relation, len(schemas)),
+		sites:     sites,
+	}
+
+	for _, s := range schemas {
+		rel := &Relation{
+			Name:        s.Name,
+			Type:        s.Type,
+			PrimaryKey:  s.PrimaryKey,
+			FragmentKey: s.FragmentKey,
+			Columns:     s.Columns,
+		}
+
+		switch s.Type {
+		case Replicated:
+			rel.Fragments = []Fragment{{Name: s.Name, Sites: sites}}
+		case Horizontal:
+			for _, siteID := range sites {
+				allocated := siteID
+				if override, ok := overrides[s.Name+"/"+siteID]; ok {
+					allocated = override
+				}
+				rel.Fragments = append(rel.Fragments, Fragment{
+					Name:      fmt.Sprintf("%s_%s", s.Name, siteID),
+					Predicate: fmt.Sprintf("%s = '%s'", s.FragmentKey, siteID),
+					Value:     siteID,
+					Sites:     []string{allocated},
+				})
+			}
+		}
+
+		c.relations[s.Name] = rel
+	}
+
+	return c
+}
+
+// Sites returns the branch sites known to the catalog
+func (c *Catalog) Sites() []string {
+	return c.sites
+}
+
+// Relations returns all global relations in dependency order
+func (c *Catalog) Relations() []Relation {
+	result := make([]Relation, 0, len(schemas))
+	for _, s := range schemas {
+		result = append(result, *c.relations[s.Name])
+	}
+	return result
+}
+
+// Relation returns the definition of one global relation
+func (c *Catalog) Relation(name string) (*Relation, error) {
+	rel, exists := c.relations[name]
+	if !exists {
+		return nil, fmt.Errorf("unknown relation: %s", name)
+	}
+	return rel, nil
+}
+
+// SitesFor returns every site holding at least one fragment of the relation
+func (c *Catalog) SitesFor(relation string) ([]string, error) {
+	rel, err := c.Relation(relation)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var sites []string
+	for _, f := range rel.Fragments {
+		for _, siteID := range f.Sites {
+			if !seen[siteID] {
+				seen[siteID] = true
+				sites = append(sites, siteID)
+			}
+		}
+	}
+	sort.Strings(sites)
+	return sites, nil
+}
+
+// FragmentFor returns the fragment of a horizontally fragmented relation that holds rows with the given key value
+func (c *Catalog) FragmentFor(relation, value string) (*Fragment, error) {
+	rel, err := c.Relation(relation)
+	if err != nil {
+		return nil, err
+	}
+	if rel.Type != Horizontal {
+		return nil, fmt.Errorf("relation %s is not horizontally fragmented", relation)
+	}
+
+	for i := range rel.Fragments {
+		if rel.Fragments[i].Value == value {
+			return &rel.Fragments[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no fragment of %s for %s = '%s'", relation, rel.FragmentKey, value)
+}
+
+// SiteFor returns the site that stores rows of the relation with the given fragment key value.
+// Replicated relations are read locally, so the preferred site is returned when it holds a copy.
+func (c *Catalog) SiteFor(relation, value, preferred string) (string, error) {
+	rel, err := c.Relation(relation)
+	if err != nil {
+		return "", err
+	}
+
+	if rel.Type == Replicated {
+		for _, siteID := range rel.Fragments[0].Sites {
+			if siteID == preferred {
+				return siteID, nil
+			}
+		}
+		if len(rel.Fragments[0].Sites) == 0 {
+			return "", fmt.Errorf("relation %s is not allocated to any site", relation)
+		}
+		return rel.Fragments[0].Sites[0], nil
+	}
+
+	fragment, err := c.FragmentFor(relation, value)
+	if err != nil {
+		return "", err
+	}
+	return fragment.Sites[0], nil
+}
+
+// ValidateRow checks that a row of a horizontally fragmented relation may be stored at siteID
+func (c *Catalog) ValidateRow(relation string, data map[string]interface{}, siteID string) error {
+	rel, err := c.Relation(relation)
+	if err != nil {
+		return err
+	}
+	if rel.Type != Horizontal {
+		return nil
+	}
+
+	value, exists := data[rel.FragmentKey]
+	if !exists {
+		return nil
+	}
+
+	fragment, err := c.FragmentFor(relation, fmt.Sprint(value))
+	if err != nil {
+		return fmt.Errorf("fragmentation violation: %w", err)
+	}
+	for _, allocated := range fragment.Sites {
+		if allocated == siteID {
+			return nil
+		}
+	}
+	return fmt.Errorf("fragmentation violation: %s.%s=%v belongs to fragment %s stored at %v, not site %s",
+		relation, rel.FragmentKey, value, fragment.Name, fragment.Sites, siteID)
+}
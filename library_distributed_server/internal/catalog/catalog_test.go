@@ -0,0 +1,137 @@
+package catalog
+
+import (
+	"library_distributed_server/internal/config"
+	"reflect"
+	"testing"
+)
+
+func testCatalog() *Catalog {
+	return FromConfig(&config.Config{
+		Sites: []config.SiteConfig{{SiteID: "Q1"}, {SiteID: "Q3"}},
+		Allocations: []config.AllocationConfig{
+			{Relation: "QUYENSACH", Fragment: "Q3", Site: "Q1"},
+		},
+	})
+}
+
+func TestFragmentFor(t *testing.T) {
+	c := testCatalog()
+
+	tests := []struct {
+		relation string
+		value    string
+		name     string
+		sites    []string
+		wantErr  bool
+	}{
+		{"QUYENSACH", "Q1", "QUYENSACH_Q1", []string{"Q1"}, false},
+		{"QUYENSACH", "Q3", "QUYENSACH_Q3", []string{"Q1"}, false},
+		{"PHIEUMUON", "Q3", "PHIEUMUON_Q3", []string{"Q3"}, false},
+		{"DOCGIA", "Q3", "DOCGIA_Q3", []string{"Q3"}, false},
+		{"QUYENSACH", "Q9", "", nil, true},
+		{"SACH", "Q1", "", nil, true},
+		{"KHONGCO", "Q1", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		fragment, err := c.FragmentFor(tt.relation, tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("FragmentFor(%s, %s) = %s, want an error", tt.relation, tt.value, fragment.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("FragmentFor(%s, %s) failed: %v", tt.relation, tt.value, err)
+			continue
+		}
+		if fragment.Name != tt.name || !reflect.DeepEqual(fragment.Sites, tt.sites) {
+			t.Errorf("FragmentFor(%s, %s) = %s at %v, want %s at %v", tt.relation, tt.value, fragment.Name, fragment.Sites, tt.name, tt.sites)
+		}
+	}
+}
+
+func TestSiteFor(t *testing.T) {
+	c := testCatalog()
+
+	tests := []struct {
+		relation  string
+		value     string
+		preferred string
+		want      string
+		wantErr   bool
+	}{
+		{"SACH", "", "Q3", "Q3", false},
+		{"SACH", "", "Q9", "Q1", false},
+		{"CHINHSACH", "", "Q1", "Q1", false},
+		{"QUYENSACH", "Q3", "Q3", "Q1", false},
+		{"PHIEUMUON", "Q3", "Q1", "Q3", false},
+		{"PHIEUMUON", "Q9", "Q1", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := c.SiteFor(tt.relation, tt.value, tt.preferred)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SiteFor(%s, %s, %s) error = %v, wantErr %v", tt.relation, tt.value, tt.preferred, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("SiteFor(%s, %s, %s) = %s, want %s", tt.relation, tt.value, tt.preferred, got, tt.want)
+		}
+	}
+}
+
+func TestSitesFor(t *testing.T) {
+	c := testCatalog()
+
+	tests := []struct {
+		relation string
+		want     []string
+	}{
+		{"SACH", []string{"Q1", "Q3"}},
+		{"QUYENSACH", []string{"Q1"}},
+		{"PHIEUMUON", []string{"Q1", "Q3"}},
+	}
+
+	for _, tt := range tests {
+		got, err := c.SitesFor(tt.relation)
+		if err != nil {
+			t.Errorf("SitesFor(%s) failed: %v", tt.relation, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SitesFor(%s) = %v, want %v", tt.relation, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRow(t *testing.T) {
+	c := testCatalog()
+
+	tests := []struct {
+		name     string
+		relation string
+		data     map[string]interface{}
+		siteID   string
+		wantErr  bool
+	}{
+		{"own fragment", "PHIEUMUON", map[string]interface{}{"MaCN": "Q1"}, "Q1", false},
+		{"other branch", "PHIEUMUON", map[string]interface{}{"MaCN": "Q3"}, "Q1", true},
+		{"relocated fragment", "QUYENSACH", map[string]interface{}{"MaCN": "Q3"}, "Q1", false},
+		{"relocated away", "QUYENSACH", map[string]interface{}{"MaCN": "Q3"}, "Q3", true},
+		{"unknown branch", "PHIEUMUON", map[string]interface{}{"MaCN": "Q9"}, "Q1", true},
+		{"no fragment key", "PHIEUMUON", map[string]interface{}{"MaDG": "DG01"}, "Q1", false},
+		{"replicated", "SACH", map[string]interface{}{"ISBN": "978"}, "Q3", false},
+		{"unknown relation", "KHONGCO", nil, "Q1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := c.ValidateRow(tt.relation, tt.data, tt.siteID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRow(%s, %v, %s) = %v, wantErr %v", tt.relation, tt.data, tt.siteID, err, tt.wantErr)
+			}
+		})
+	}
+}
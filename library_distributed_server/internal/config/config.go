@@ -3,26 +3,57 @@ package config
 import (
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Database DatabaseConfig
-	Server   ServerConfig
-	Auth     AuthConfig
-	Sites    []SiteConfig
+	Database     DatabaseConfig
+	Server       ServerConfig
+	Auth         AuthConfig
+	Membership   MembershipConfig
+	Query        QueryConfig
+	Cache        CacheConfig
+	Holds        HoldConfig
+	ILL          ILLConfig
+	Loans        LoanConfig
+	Fines        FineConfig
+	Sites        []SiteConfig // Branch sites, in topology file order
+	Coordinator  SiteConfig
+	Allocations  []AllocationConfig     // Fragments stored away from their home site
+	Credentials  map[string]Credentials // Per-site database logins, by site ID
+	TopologyFile string
 }
 
 type DatabaseConfig struct {
-	Host     string
-	Port     int
-	User     string
-	Password string
-	Database string
+	Host            string
+	Port            int
+	User            string // Login used for sites without their own credentials
+	Password        string
+	Database        string
+	TLS             TLSConfig // Defaults for sites that do not override them in the topology
+	CredentialsFile string    // Optional YAML file with per-site logins
+}
+
+// TLSConfig controls encryption of the connection to a site's database
+type TLSConfig struct {
+	Encrypt                string // "disable", "false" (login packet only) or "true"
+	TrustServerCertificate bool   // Accept any server certificate (development only)
+	CAFile                 string // PEM file with the CA certificate that signed the server certificate
+	HostNameInCertificate  string // Expected host name when it differs from the connection host
+}
+
+// Credentials is a database login
+type Credentials struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
 }
 
 type ServerConfig struct {
@@ -36,70 +67,263 @@ type ServerConfig struct {
 type AuthConfig struct {
 	JWTSecret   string
 	TokenExpiry time.Duration
+	SiteSecret  string // Shared by the sites and the coordinator on site-to-site calls
+}
+
+// SiteSecretHeader carries AuthConfig.SiteSecret on cache invalidations and heartbeats
+const SiteSecretHeader = "X-Site-Secret"
+
+// MembershipConfig controls heartbeats between sites and the failure detector
+type MembershipConfig struct {
+	HeartbeatInterval time.Duration
+	SuspectTimeout    time.Duration // Silence after which a site becomes SUSPECT
+	DownTimeout       time.Duration // Silence after which a site becomes DOWN
+}
+
+// QueryConfig controls reads that fan out to several sites
+type QueryConfig struct {
+	SiteTimeout time.Duration // Time one site has to answer before it is reported as failed
+}
+
+// CacheConfig controls the in-process cache of replicated reference data
+type CacheConfig struct {
+	TTL time.Duration // Upper bound on staleness when an invalidation from another site is lost; 0 disables the cache
+}
+
+// LoanConfig controls loan periods, limits and renewals where no circulation policy applies
+type LoanConfig struct {
+	Period      time.Duration // Time from a loan, or a renewal, to the due date
+	MaxItems    int           // Loans a reader may have at once, at every branch
+	MaxRenewals int           // Renewals allowed per loan
+}
+
+// FineConfig controls overdue fines where no circulation policy applies. Amounts are in VND.
+type FineConfig struct {
+	DailyRate      int            // Fine per day overdue at branches without their own rate
+	BranchRates    map[string]int // Per-branch daily rates, by site ID
+	GraceDays      int            // Days overdue not charged
+	BlockThreshold int            // Unpaid balance above which a reader cannot borrow
+}
+
+// RateFor returns the daily fine rate of a branch
+func (f FineConfig) RateFor(siteID string) int {
+	if rate, exists := f.BranchRates[siteID]; exists {
+		return rate
+	}
+	return f.DailyRate
+}
+
+// HoldConfig controls the hold queue for titles
+type HoldConfig struct {
+	PickupWindow  time.Duration // Time a reader has to pick up a copy set aside for their hold
+	SweepInterval time.Duration // How often a site expires the unclaimed holds it stores; 0 pauses the sweep
+}
+
+// ILLConfig controls inter-library loans
+type ILLConfig struct {
+	ReconcileInterval time.Duration // How often a site repairs its requests whose copy missed a step; 0 pauses it
 }
 
 type SiteConfig struct {
-	SiteID   string
-	Name     string
-	Database string
-	Host     string
-	Port     int
+	SiteID     string
+	Name       string
+	Role       string
+	ServiceURL string
+	Database   string
+	Host       string
+	Port       int
+	TLS        TLSConfig // Effective TLS settings, topology overrides merged over the defaults
+}
+
+// AllocationConfig places the fragment of a horizontally fragmented relation
+// whose key equals Fragment (a branch ID) at Site instead of the branch itself
+type AllocationConfig struct {
+	Relation string
+	Fragment string
+	Site     string
 }
 
+// envFile holds local settings; variables set in the process environment take precedence
+const envFile = ".env"
+
 func Load() (*Config, error) {
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: Error loading .env file: %v", err)
-	}
+	env := loadEnvironment()
 
+	jwtSecret := env.get("JWT_SECRET", "distributed-library-system-secret-key-2024")
 	config := &Config{
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "10.211.55.3"),
-			Port:     getEnvAsInt("DB_PORT", 1433),
-			User:     getEnv("DB_USER", "sa"),
-			Password: getEnv("DB_PASSWORD", ""),
-			Database: getEnv("DB_NAME", "library_distributed"),
+			Host:     env.get("DB_HOST", "10.211.55.3"),
+			Port:     env.getInt("DB_PORT", 1433),
+			User:     env.get("DB_USER", "sa"),
+			Password: env.get("DB_PASSWORD", ""),
+			Database: env.get("DB_NAME", "library_distributed"),
+			TLS: TLSConfig{
+				Encrypt:                env.get("DB_ENCRYPT", "disable"),
+				TrustServerCertificate: env.getBool("DB_TRUST_SERVER_CERTIFICATE", false),
+				CAFile:                 env.get("DB_CA_FILE", ""),
+				HostNameInCertificate:  env.get("DB_HOSTNAME_IN_CERTIFICATE", ""),
+			},
+			CredentialsFile: env.get("DB_CREDENTIALS_FILE", ""),
 		},
 		Server: ServerConfig{
-			Host:         getEnv("SERVER_HOST", "localhost"),
-			Port:         getEnvAsInt("SERVER_PORT", 8080),
-			ReadTimeout:  getEnvAsDuration("SERVER_READ_TIMEOUT", 10*time.Second),
-			WriteTimeout: getEnvAsDuration("SERVER_WRITE_TIMEOUT", 10*time.Second),
-			IdleTimeout:  getEnvAsDuration("SERVER_IDLE_TIMEOUT", 60*time.Second),
+			Host:         env.get("SERVER_HOST", "localhost"),
+			Port:         env.getInt("SERVER_PORT", 8080),
+			ReadTimeout:  env.getDuration("SERVER_READ_TIMEOUT", 10*time.Second),
+			WriteTimeout: env.getDuration("SERVER_WRITE_TIMEOUT", 10*time.Second),
+			IdleTimeout:  env.getDuration("SERVER_IDLE_TIMEOUT", 60*time.Second),
 		},
 		Auth: AuthConfig{
-			JWTSecret:   getEnv("JWT_SECRET", "distributed-library-system-secret-key-2024"),
-			TokenExpiry: getEnvAsDuration("JWT_TOKEN_EXPIRY", 24*time.Hour),
+			JWTSecret:   jwtSecret,
+			TokenExpiry: env.getDuration("JWT_TOKEN_EXPIRY", 24*time.Hour),
+			SiteSecret:  env.get("SITE_SECRET", jwtSecret),
 		},
-		Sites: []SiteConfig{
-			{
-				SiteID:   "Q1",
-				Name:     "Site_TV_Q1",
-				Database: "ThuVienQ1",
-				Host:     "10.211.55.3",
-				Port:     1431,
-			},
-			{
-				SiteID:   "Q3",
-				Name:     "Site_TV_Q3",
-				Database: "ThuVienQ3",
-				Host:     "10.211.55.3",
-				Port:     1433,
-			},
+		Membership: MembershipConfig{
+			HeartbeatInterval: env.getDuration("HEARTBEAT_INTERVAL", 2*time.Second),
+			SuspectTimeout:    env.getDuration("HEARTBEAT_SUSPECT_TIMEOUT", 6*time.Second),
+			DownTimeout:       env.getDuration("HEARTBEAT_DOWN_TIMEOUT", 15*time.Second),
+		},
+		Query: QueryConfig{
+			SiteTimeout: env.getDuration("QUERY_SITE_TIMEOUT", 5*time.Second),
+		},
+		Cache: CacheConfig{
+			TTL: env.getDuration("CACHE_TTL", 5*time.Minute),
+		},
+		Loans: LoanConfig{
+			Period:      env.getDuration("LOAN_PERIOD", 30*24*time.Hour),
+			MaxItems:    env.getInt("LOAN_MAX_ITEMS", 3),
+			MaxRenewals: env.getInt("LOAN_MAX_RENEWALS", 2),
+		},
+		Fines: FineConfig{
+			DailyRate:      env.getInt("FINE_DAILY_RATE", 5000),
+			BranchRates:    loadBranchRates(env),
+			GraceDays:      env.getInt("FINE_GRACE_DAYS", 0),
+			BlockThreshold: env.getInt("FINE_BLOCK_THRESHOLD", 50000),
+		},
+		Holds: HoldConfig{
+			PickupWindow:  env.getDuration("HOLD_PICKUP_WINDOW", 72*time.Hour),
+			SweepInterval: env.getDuration("HOLD_SWEEP_INTERVAL", time.Minute),
 		},
+		ILL: ILLConfig{
+			ReconcileInterval: env.getDuration("ILL_RECONCILE_INTERVAL", 5*time.Minute),
+		},
+		TopologyFile: env.get("TOPOLOGY_FILE", "topology.yaml"),
+	}
+
+	if err := config.Database.TLS.validate(); err != nil {
+		return nil, fmt.Errorf("invalid DB_ENCRYPT: %w", err)
+	}
+
+	topology, err := LoadTopology(config.TopologyFile, config.Database.TLS)
+	if err != nil {
+		return nil, err
+	}
+	config.Sites = topology.Sites
+	config.Coordinator = topology.Coordinator
+	config.Allocations = topology.Allocations
+
+	config.Credentials, err = loadCredentials(config.Database.CredentialsFile, env)
+	if err != nil {
+		return nil, err
 	}
 
 	return config, nil
 }
 
-func getEnv(key, defaultValue string) string {
+// loadCredentials collects per-site logins from the credentials file and from
+// DB_USER_<SITE> / DB_PASSWORD_<SITE> variables, which take precedence
+func loadCredentials(path string, env environment) (map[string]Credentials, error) {
+	credentials := make(map[string]Credentials)
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read credentials file %s: %w", path, err)
+		}
+		if err := yaml.Unmarshal(data, &credentials); err != nil {
+			return nil, fmt.Errorf("failed to parse credentials file %s: %w", path, err)
+		}
+	}
+
+	for _, key := range env.keys() {
+		var siteID string
+		var isUser bool
+		switch {
+		case strings.HasPrefix(key, "DB_USER_"):
+			siteID, isUser = strings.TrimPrefix(key, "DB_USER_"), true
+		case strings.HasPrefix(key, "DB_PASSWORD_"):
+			siteID = strings.TrimPrefix(key, "DB_PASSWORD_")
+		default:
+			continue
+		}
+		c := credentials[siteID]
+		if isUser {
+			c.User = env.get(key, "")
+		} else {
+			c.Password = env.get(key, "")
+		}
+		credentials[siteID] = c
+	}
+
+	for siteID, c := range credentials {
+		if c.User == "" {
+			return nil, fmt.Errorf("credentials for site %s have a password but no user", siteID)
+		}
+	}
+	return credentials, nil
+}
+
+// loadBranchRates collects per-branch fine rates from FINE_DAILY_RATE_<SITE> variables
+func loadBranchRates(env environment) map[string]int {
+	rates := make(map[string]int)
+	for _, key := range env.keys() {
+		siteID, found := strings.CutPrefix(key, "FINE_DAILY_RATE_")
+		if !found || siteID == "" {
+			continue
+		}
+		rate, err := strconv.Atoi(env.get(key, ""))
+		if err != nil || rate < 0 {
+			log.Printf("Warning: ignoring %s: expected a non-negative amount", key)
+			continue
+		}
+		rates[siteID] = rate
+	}
+	return rates
+}
+
+// validate checks the encryption mode against the values the SQL Server driver accepts
+func (t TLSConfig) validate() error {
+	switch strings.ToLower(t.Encrypt) {
+	case "disable", "false", "true":
+		return nil
+	}
+	return fmt.Errorf("encrypt must be disable, false or true, got %q", t.Encrypt)
+}
+
+// environment resolves settings from the process environment, falling back to the .env file.
+// The .env file is read rather than loaded into the process so a reload sees its current content.
+type environment map[string]string
+
+func loadEnvironment() environment {
+	values, err := godotenv.Read(envFile)
+	if err != nil {
+		log.Printf("Warning: Error loading .env file: %v", err)
+		values = map[string]string{}
+	}
+	return values
+}
+
+func (e environment) get(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
+	if value := e[key]; value != "" {
+		return value
+	}
 	return defaultValue
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
+func (e environment) getInt(key string, defaultValue int) int {
+	if value := e.get(key, ""); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
 			return intValue
 		}
@@ -107,8 +331,37 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
-func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
-	if value := os.Getenv(key); value != "" {
+func (e environment) getBool(key string, defaultValue bool) bool {
+	if value := e.get(key, ""); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// keys lists every variable set in the process environment or the .env file
+func (e environment) keys() []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, kv := range os.Environ() {
+		key, _, _ := strings.Cut(kv, "=")
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	for key := range e {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func (e environment) getDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := e.get(key, ""); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
 			return duration
 		}
@@ -116,18 +369,99 @@ func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
-func (c *Config) GetConnectionString(siteID string) string {
-	var site SiteConfig
+// GetSite returns the branch site with the given ID
+func (c *Config) GetSite(siteID string) (SiteConfig, error) {
 	for _, s := range c.Sites {
 		if s.SiteID == siteID {
-			site = s
-			break
+			return s, nil
 		}
 	}
-	if site.SiteID == "" {
-		// Default to first site if not found
-		site = c.Sites[0]
+	return SiteConfig{}, fmt.Errorf("unknown site: %s", siteID)
+}
+
+// SiteIDs returns the IDs of all branch sites
+func (c *Config) SiteIDs() []string {
+	ids := make([]string, 0, len(c.Sites))
+	for _, s := range c.Sites {
+		ids = append(ids, s.SiteID)
+	}
+	return ids
+}
+
+// AddSite registers a new branch site. The slice is replaced rather than appended
+// in place so readers holding the previous slice are unaffected.
+func (c *Config) AddSite(site SiteConfig) error {
+	if _, err := c.GetSite(site.SiteID); err == nil {
+		return fmt.Errorf("site %s already exists", site.SiteID)
+	}
+	sites := make([]SiteConfig, 0, len(c.Sites)+1)
+	sites = append(sites, c.Sites...)
+	c.Sites = append(sites, site)
+	return nil
+}
+
+// SetAllocation records where a fragment is stored, replacing any earlier override.
+// Like AddSite it swaps in a new slice instead of mutating the current one.
+func (c *Config) SetAllocation(allocation AllocationConfig) {
+	allocations := make([]AllocationConfig, 0, len(c.Allocations)+1)
+	for _, a := range c.Allocations {
+		if a.Relation != allocation.Relation || a.Fragment != allocation.Fragment {
+			allocations = append(allocations, a)
+		}
+	}
+	if allocation.Site != allocation.Fragment {
+		allocations = append(allocations, allocation)
+	}
+	c.Allocations = allocations
+}
+
+// GetConnectionString returns the connection string of a registered branch site.
+// Unknown site IDs are an error so a typo can never reach another branch's database.
+func (c *Config) GetConnectionString(siteID string) (string, error) {
+	site, err := c.GetSite(siteID)
+	if err != nil {
+		return "", err
+	}
+	return c.ConnectionStringFor(site), nil
+}
+
+// ConnectionStringFor builds the connection string for a site that may not be registered yet.
+// The site's own credentials are used when configured, the global DB_USER/DB_PASSWORD otherwise.
+func (c *Config) ConnectionStringFor(site SiteConfig) string {
+	login := c.CredentialsFor(site.SiteID)
+
+	tls := site.TLS
+	if tls.Encrypt == "" {
+		tls = c.Database.TLS
+	}
+
+	query := url.Values{}
+	query.Set("database", site.Database)
+	query.Set("encrypt", tls.Encrypt)
+	if !strings.EqualFold(tls.Encrypt, "disable") {
+		query.Set("TrustServerCertificate", strconv.FormatBool(tls.TrustServerCertificate))
+		if tls.CAFile != "" {
+			query.Set("certificate", tls.CAFile)
+		}
+		if tls.HostNameInCertificate != "" {
+			query.Set("hostNameInCertificate", tls.HostNameInCertificate)
+		}
+	}
+
+	// URL form so credentials containing ';' or '=' need no escaping rules of their own
+	u := url.URL{
+		Scheme:   "sqlserver",
+		User:     url.UserPassword(login.User, login.Password),
+		Host:     net.JoinHostPort(site.Host, strconv.Itoa(site.Port)),
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// CredentialsFor returns the database login used for a site
+func (c *Config) CredentialsFor(siteID string) Credentials {
+	if login, exists := c.Credentials[siteID]; exists {
+		return login
 	}
-	return fmt.Sprintf("server=%s;port=%d;database=%s;user id=%s;password=%s;encrypt=disable",
-		site.Host, site.Port, site.Database, c.Database.User, c.Database.Password)
+	return Credentials{User: c.Database.User, Password: c.Database.Password}
 }
@@ -0,0 +1,273 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// watchInterval is how often the configuration files are checked for changes
+const watchInterval = 2 * time.Second
+
+// Store holds the current configuration and swaps it atomically on reload.
+// Callers take a snapshot with Current at the start of an operation and use it
+// throughout, so an in-flight request never sees a half-applied reload.
+type Store struct {
+	current atomic.Pointer[Config]
+	load    func() (*Config, error)
+
+	mutex       sync.Mutex // Serializes reloads and updates
+	subscribers []func(old, new *Config)
+}
+
+// NewStore loads the initial configuration with load, which is called again on every reload
+func NewStore(load func() (*Config, error)) (*Store, error) {
+	cfg, err := load()
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{load: load}
+	s.current.Store(cfg)
+	return s, nil
+}
+
+// Current returns the configuration snapshot in effect. It must not be modified.
+func (s *Store) Current() *Config {
+	return s.current.Load()
+}
+
+// Subscribe registers fn to be called after every configuration change
+func (s *Store) Subscribe(fn func(old, new *Config)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Reload loads the configuration again and swaps it in. On error the current configuration is kept.
+func (s *Store) Reload() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	next, err := s.load()
+	if err != nil {
+		return fmt.Errorf("failed to reload configuration: %w", err)
+	}
+	s.swap(next)
+	return nil
+}
+
+// Update applies fn to a copy of the current configuration and swaps the copy in
+func (s *Store) Update(fn func(cfg *Config) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	next := s.current.Load().Clone()
+	if err := fn(next); err != nil {
+		return err
+	}
+	s.swap(next)
+	return nil
+}
+
+// swap installs next, logs the differences and notifies subscribers; callers must hold the mutex
+func (s *Store) swap(next *Config) {
+	old := s.current.Load()
+	changes := Diff(old, next)
+	if len(changes) == 0 {
+		return
+	}
+
+	s.current.Store(next)
+	log.Printf("Configuration changed:")
+	for _, change := range changes {
+		log.Printf("  %s", change)
+	}
+
+	for _, fn := range s.subscribers {
+		fn(old, next)
+	}
+}
+
+// Watch reloads the configuration on SIGHUP and whenever the .env, topology or credentials file changes,
+// until stop is closed
+func (s *Store) Watch(stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hup)
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+
+		modified := s.modTimes()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-hup:
+				log.Println("Received SIGHUP, reloading configuration")
+			case <-ticker.C:
+				current := s.modTimes()
+				if current == modified {
+					continue
+				}
+				modified = current
+				log.Println("Configuration files changed, reloading configuration")
+			}
+
+			if err := s.Reload(); err != nil {
+				log.Printf("%v (keeping previous configuration)", err)
+			}
+		}
+	}()
+}
+
+// modTimes fingerprints the configuration files by modification time
+func (s *Store) modTimes() string {
+	var fingerprint string
+	cfg := s.Current()
+	for _, path := range []string{envFile, cfg.TopologyFile, cfg.Database.CredentialsFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			fingerprint += path + "@" + info.ModTime().String() + ";"
+		}
+	}
+	return fingerprint
+}
+
+// Clone returns a copy of the configuration that can be modified independently
+func (c *Config) Clone() *Config {
+	clone := *c
+	clone.Sites = append([]SiteConfig(nil), c.Sites...)
+	clone.Allocations = append([]AllocationConfig(nil), c.Allocations...)
+	clone.Credentials = make(map[string]Credentials, len(c.Credentials))
+	for siteID, login := range c.Credentials {
+		clone.Credentials[siteID] = login
+	}
+	clone.Fines.BranchRates = make(map[string]int, len(c.Fines.BranchRates))
+	for siteID, rate := range c.Fines.BranchRates {
+		clone.Fines.BranchRates[siteID] = rate
+	}
+	return &clone
+}
+
+// Diff describes the differences between two configurations, one line per changed setting.
+// Secrets are reported as changed without their values.
+func Diff(old, new *Config) []string {
+	var changes []string
+	changed := func(name string, before, after interface{}) {
+		if before != after {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", name, before, after))
+		}
+	}
+
+	changed("Database.User", old.Database.User, new.Database.User)
+	if old.Database.Password != new.Database.Password {
+		changes = append(changes, "Database.Password: changed")
+	}
+	changed("Database.TLS", old.Database.TLS, new.Database.TLS)
+	changed("Database.CredentialsFile", old.Database.CredentialsFile, new.Database.CredentialsFile)
+	changed("Server.ReadTimeout", old.Server.ReadTimeout, new.Server.ReadTimeout)
+	changed("Server.WriteTimeout", old.Server.WriteTimeout, new.Server.WriteTimeout)
+	changed("Server.IdleTimeout", old.Server.IdleTimeout, new.Server.IdleTimeout)
+	changed("Server.Port", old.Server.Port, new.Server.Port)
+	if old.Auth.JWTSecret != new.Auth.JWTSecret {
+		changes = append(changes, "Auth.JWTSecret: changed")
+	}
+	if old.Auth.SiteSecret != new.Auth.SiteSecret {
+		changes = append(changes, "Auth.SiteSecret: changed")
+	}
+	changed("Auth.TokenExpiry", old.Auth.TokenExpiry, new.Auth.TokenExpiry)
+	changed("Membership.HeartbeatInterval", old.Membership.HeartbeatInterval, new.Membership.HeartbeatInterval)
+	changed("Membership.SuspectTimeout", old.Membership.SuspectTimeout, new.Membership.SuspectTimeout)
+	changed("Membership.DownTimeout", old.Membership.DownTimeout, new.Membership.DownTimeout)
+	changed("Query.SiteTimeout", old.Query.SiteTimeout, new.Query.SiteTimeout)
+	changed("Cache.TTL", old.Cache.TTL, new.Cache.TTL)
+	changed("Loans.Period", old.Loans.Period, new.Loans.Period)
+	changed("Loans.MaxItems", old.Loans.MaxItems, new.Loans.MaxItems)
+	changed("Loans.MaxRenewals", old.Loans.MaxRenewals, new.Loans.MaxRenewals)
+	changed("Fines.DailyRate", old.Fines.DailyRate, new.Fines.DailyRate)
+	changed("Fines.BranchRates", fmt.Sprint(old.Fines.BranchRates), fmt.Sprint(new.Fines.BranchRates))
+	changed("Fines.GraceDays", old.Fines.GraceDays, new.Fines.GraceDays)
+	changed("Fines.BlockThreshold", old.Fines.BlockThreshold, new.Fines.BlockThreshold)
+	changed("Holds.PickupWindow", old.Holds.PickupWindow, new.Holds.PickupWindow)
+	changed("Holds.SweepInterval", old.Holds.SweepInterval, new.Holds.SweepInterval)
+	changed("ILL.ReconcileInterval", old.ILL.ReconcileInterval, new.ILL.ReconcileInterval)
+	changed("Coordinator", old.Coordinator, new.Coordinator)
+
+	oldSites := make(map[string]SiteConfig)
+	for _, site := range old.Sites {
+		oldSites[site.SiteID] = site
+	}
+	newSites := make(map[string]bool)
+	for _, site := range new.Sites {
+		newSites[site.SiteID] = true
+		before, exists := oldSites[site.SiteID]
+		if !exists {
+			changes = append(changes, fmt.Sprintf("site %s: added (%s)", site.SiteID, site.describe()))
+			continue
+		}
+		changed("site "+site.SiteID, before.describe(), site.describe())
+	}
+	for _, site := range old.Sites {
+		if !newSites[site.SiteID] {
+			changes = append(changes, fmt.Sprintf("site %s: removed", site.SiteID))
+		}
+	}
+
+	for siteID := range old.Credentials {
+		if _, exists := new.Credentials[siteID]; !exists {
+			changes = append(changes, fmt.Sprintf("credentials for site %s: removed", siteID))
+		}
+	}
+	for siteID, login := range new.Credentials {
+		if before, exists := old.Credentials[siteID]; !exists {
+			changes = append(changes, fmt.Sprintf("credentials for site %s: added (user %s)", siteID, login.User))
+		} else if before != login {
+			changes = append(changes, fmt.Sprintf("credentials for site %s: changed (user %s -> %s)", siteID, before.User, login.User))
+		}
+	}
+
+	oldAllocations := make(map[string]string)
+	for _, a := range old.Allocations {
+		oldAllocations[a.Relation+"/"+a.Fragment] = a.Site
+	}
+	newAllocations := make(map[string]string)
+	for _, a := range new.Allocations {
+		newAllocations[a.Relation+"/"+a.Fragment] = a.Site
+		if oldAllocations[a.Relation+"/"+a.Fragment] != a.Site {
+			changes = append(changes, fmt.Sprintf("allocation %s/%s: -> %s", a.Relation, a.Fragment, a.Site))
+		}
+	}
+	for _, a := range old.Allocations {
+		if _, exists := newAllocations[a.Relation+"/"+a.Fragment]; !exists {
+			changes = append(changes, fmt.Sprintf("allocation %s/%s: -> %s (home site)", a.Relation, a.Fragment, a.Fragment))
+		}
+	}
+
+	return changes
+}
+
+// describe summarizes a site for configuration diffs
+func (s SiteConfig) describe() string {
+	return fmt.Sprintf("%s %s db=%s:%d/%s encrypt=%s", s.Name, s.ServiceURL, s.Host, s.Port, s.Database, s.TLS.Encrypt)
+}
+
+// ConnectionChanges returns the branch sites whose connection string changed or that were removed
+func ConnectionChanges(old, new *Config) []string {
+	var sites []string
+	for _, site := range old.Sites {
+		next, err := new.GetSite(site.SiteID)
+		if err != nil || old.ConnectionStringFor(site) != new.ConnectionStringFor(next) {
+			sites = append(sites, site.SiteID)
+		}
+	}
+	return sites
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testConfig() *Config {
+	return &Config{
+		Database: DatabaseConfig{User: "sa", Password: "old-password"},
+		Auth:     AuthConfig{JWTSecret: "old-jwt", SiteSecret: "old-site", TokenExpiry: 24 * time.Hour},
+		Loans:    LoanConfig{Period: 14 * 24 * time.Hour, MaxItems: 5, MaxRenewals: 2},
+		Fines:    FineConfig{DailyRate: 5000, BranchRates: map[string]int{"Q1": 3000}},
+		Holds:    HoldConfig{PickupWindow: 72 * time.Hour, SweepInterval: time.Minute},
+		Sites: []SiteConfig{
+			{SiteID: "Q1", Name: "Quận 1", Host: "db-q1", Port: 1433, Database: "LibraryQ1"},
+			{SiteID: "Q3", Name: "Quận 3", Host: "db-q3", Port: 1433, Database: "LibraryQ3"},
+		},
+		Allocations: []AllocationConfig{{Relation: "QUYENSACH", Fragment: "Q3", Site: "Q1"}},
+		Credentials: map[string]Credentials{"Q1": {User: "q1_app", Password: "q1-password"}},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(cfg *Config)
+		want   []string
+	}{
+		{
+			name:   "no changes",
+			change: func(cfg *Config) {},
+			want:   nil,
+		},
+		{
+			name:   "setting",
+			change: func(cfg *Config) { cfg.Loans.MaxItems = 8 },
+			want:   []string{"Loans.MaxItems: 5 -> 8"},
+		},
+		{
+			name:   "duration",
+			change: func(cfg *Config) { cfg.Holds.SweepInterval = 0 },
+			want:   []string{"Holds.SweepInterval: 1m0s -> 0s"},
+		},
+		{
+			name: "secrets",
+			change: func(cfg *Config) {
+				cfg.Database.Password = "new-password"
+				cfg.Auth.JWTSecret = "new-jwt"
+				cfg.Auth.SiteSecret = "new-site"
+			},
+			want: []string{"Database.Password: changed", "Auth.JWTSecret: changed", "Auth.SiteSecret: changed"},
+		},
+		{
+			name:   "branch rate",
+			change: func(cfg *Config) { cfg.Fines.BranchRates["Q3"] = 4000 },
+			want:   []string{"Fines.BranchRates: map[Q1:3000] -> map[Q1:3000 Q3:4000]"},
+		},
+		{
+			name:   "site moved",
+			change: func(cfg *Config) { cfg.Sites[1].Host = "db-q3-new" },
+			want:   []string{"site Q3: Quận 3  db=db-q3:1433/LibraryQ3 encrypt= -> Quận 3  db=db-q3-new:1433/LibraryQ3 encrypt="},
+		},
+		{
+			name: "site added and removed",
+			change: func(cfg *Config) {
+				cfg.Sites = []SiteConfig{cfg.Sites[0], {SiteID: "Q5", Name: "Quận 5", Host: "db-q5", Port: 1433, Database: "LibraryQ5"}}
+			},
+			want: []string{"site Q5: added (Quận 5  db=db-q5:1433/LibraryQ5 encrypt=)", "site Q3: removed"},
+		},
+		{
+			name:   "credentials changed",
+			change: func(cfg *Config) { cfg.Credentials["Q1"] = Credentials{User: "q1_admin", Password: "q1-password"} },
+			want:   []string{"credentials for site Q1: changed (user q1_app -> q1_admin)"},
+		},
+		{
+			name:   "password of a site login",
+			change: func(cfg *Config) { cfg.Credentials["Q1"] = Credentials{User: "q1_app", Password: "rotated"} },
+			want:   []string{"credentials for site Q1: changed (user q1_app -> q1_app)"},
+		},
+		{
+			name: "credentials replaced",
+			change: func(cfg *Config) {
+				delete(cfg.Credentials, "Q1")
+				cfg.Credentials["Q3"] = Credentials{User: "q3_app"}
+			},
+			want: []string{"credentials for site Q1: removed", "credentials for site Q3: added (user q3_app)"},
+		},
+		{
+			name: "fragment relocated",
+			change: func(cfg *Config) {
+				cfg.Allocations = append(cfg.Allocations, AllocationConfig{Relation: "PHIEUMUON", Fragment: "Q3", Site: "Q1"})
+			},
+			want: []string{"allocation PHIEUMUON/Q3: -> Q1"},
+		},
+		{
+			name:   "fragment returned home",
+			change: func(cfg *Config) { cfg.Allocations = nil },
+			want:   []string{"allocation QUYENSACH/Q3: -> Q3 (home site)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := testConfig()
+			next := old.Clone()
+			tt.change(next)
+
+			got := Diff(old, next)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff = %q, want %q", got, tt.want)
+			}
+			for _, line := range got {
+				for _, secret := range []string{"new-password", "new-jwt", "new-site", "rotated", "q1-password"} {
+					if strings.Contains(line, secret) {
+						t.Errorf("Diff line %q reveals a secret", line)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	old := testConfig()
+	next := old.Clone()
+	next.Sites[0].Host = "elsewhere"
+	next.Allocations[0].Site = "Q3"
+	next.Credentials["Q1"] = Credentials{User: "other"}
+	next.Fines.BranchRates["Q1"] = 1
+
+	if !reflect.DeepEqual(old, testConfig()) {
+		t.Errorf("changing a clone changed the original: %+v", old)
+	}
+}
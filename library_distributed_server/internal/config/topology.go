@@ -0,0 +1,288 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Site roles declared in the topology file
+const (
+	RoleBranch      = "branch"      // Library branch with its own database fragment
+	RoleCoordinator = "coordinator" // Distributed transaction coordinator (no database)
+)
+
+// topologyFile mirrors the on-disk layout of the topology file
+type topologyFile struct {
+	Sites       []topologySite       `yaml:"sites"`
+	Allocations []topologyAllocation `yaml:"allocations,omitempty"`
+}
+
+type topologyAllocation struct {
+	Relation string `yaml:"relation"`
+	Fragment string `yaml:"fragment"`
+	Site     string `yaml:"site"`
+}
+
+type topologySite struct {
+	ID         string           `yaml:"id"`
+	Name       string           `yaml:"name"`
+	Role       string           `yaml:"role"`
+	ServiceURL string           `yaml:"serviceURL"`
+	Database   topologyDatabase `yaml:"database,omitempty"`
+}
+
+type topologyDatabase struct {
+	Host string       `yaml:"host"`
+	Port int          `yaml:"port"`
+	Name string       `yaml:"name"`
+	TLS  *topologyTLS `yaml:"tls,omitempty"`
+}
+
+// topologyTLS overrides the DB_ENCRYPT / DB_TRUST_SERVER_CERTIFICATE / DB_CA_FILE defaults for one site
+type topologyTLS struct {
+	Encrypt                string `yaml:"encrypt,omitempty"`
+	TrustServerCertificate *bool  `yaml:"trustServerCertificate,omitempty"`
+	CAFile                 string `yaml:"caFile,omitempty"`
+	HostNameInCertificate  string `yaml:"hostNameInCertificate,omitempty"`
+}
+
+// merge applies the site's overrides on top of the defaults
+func (t *topologyTLS) merge(defaults TLSConfig) TLSConfig {
+	merged := defaults
+	if t == nil {
+		return merged
+	}
+	if t.Encrypt != "" {
+		merged.Encrypt = t.Encrypt
+	}
+	if t.TrustServerCertificate != nil {
+		merged.TrustServerCertificate = *t.TrustServerCertificate
+	}
+	if t.CAFile != "" {
+		merged.CAFile = t.CAFile
+	}
+	if t.HostNameInCertificate != "" {
+		merged.HostNameInCertificate = t.HostNameInCertificate
+	}
+	return merged
+}
+
+// Topology is the parsed content of the topology file
+type Topology struct {
+	Sites       []SiteConfig // Branch sites, in file order
+	Coordinator SiteConfig
+	Allocations []AllocationConfig
+}
+
+// LoadTopology reads the site topology file and splits it into branch sites and the coordinator.
+// Each branch's TLS settings are its topology overrides merged over tlsDefaults.
+func LoadTopology(path string, tlsDefaults TLSConfig) (*Topology, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read topology file %s: %w", path, err)
+	}
+
+	var file topologyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse topology file %s: %w", path, err)
+	}
+
+	topology := &Topology{}
+	seen := make(map[string]bool)
+	for _, s := range file.Sites {
+		if s.ID == "" {
+			return nil, fmt.Errorf("topology file %s: site without id", path)
+		}
+		if seen[s.ID] {
+			return nil, fmt.Errorf("topology file %s: duplicate site id %s", path, s.ID)
+		}
+		seen[s.ID] = true
+
+		site := SiteConfig{
+			SiteID:     s.ID,
+			Name:       s.Name,
+			Role:       s.Role,
+			ServiceURL: s.ServiceURL,
+			Database:   s.Database.Name,
+			Host:       s.Database.Host,
+			Port:       s.Database.Port,
+		}
+
+		switch s.Role {
+		case RoleBranch:
+			if site.Database == "" || site.Host == "" || site.Port == 0 {
+				return nil, fmt.Errorf("topology file %s: branch site %s needs database host, port and name", path, s.ID)
+			}
+			site.TLS = s.Database.TLS.merge(tlsDefaults)
+			if err := site.TLS.validate(); err != nil {
+				return nil, fmt.Errorf("topology file %s: site %s: %w", path, s.ID, err)
+			}
+			topology.Sites = append(topology.Sites, site)
+		case RoleCoordinator:
+			if topology.Coordinator.SiteID != "" {
+				return nil, fmt.Errorf("topology file %s: more than one coordinator declared", path)
+			}
+			topology.Coordinator = site
+		default:
+			return nil, fmt.Errorf("topology file %s: site %s has unknown role %q", path, s.ID, s.Role)
+		}
+	}
+
+	if len(topology.Sites) == 0 {
+		return nil, fmt.Errorf("topology file %s: no branch sites declared", path)
+	}
+
+	branches := make(map[string]bool)
+	for _, site := range topology.Sites {
+		branches[site.SiteID] = true
+	}
+	for _, a := range file.Allocations {
+		if a.Relation == "" || !branches[a.Fragment] || !branches[a.Site] {
+			return nil, fmt.Errorf("topology file %s: invalid allocation %s/%s -> %s", path, a.Relation, a.Fragment, a.Site)
+		}
+		topology.Allocations = append(topology.Allocations, AllocationConfig{
+			Relation: a.Relation,
+			Fragment: a.Fragment,
+			Site:     a.Site,
+		})
+	}
+
+	return topology, nil
+}
+
+// AppendTopologySite adds a branch site to the topology file, keeping existing entries and comments
+func AppendTopologySite(path string, site SiteConfig) error {
+	return editTopology(path, func(root *yaml.Node) error {
+		sitesNode := mappingValue(root, "sites")
+		if sitesNode == nil || sitesNode.Kind != yaml.SequenceNode {
+			return fmt.Errorf("missing sites list")
+		}
+
+		var entry yaml.Node
+		if err := entry.Encode(topologySite{
+			ID:         site.SiteID,
+			Name:       site.Name,
+			Role:       RoleBranch,
+			ServiceURL: site.ServiceURL,
+			Database: topologyDatabase{
+				Host: site.Host,
+				Port: site.Port,
+				Name: site.Database,
+			},
+		}); err != nil {
+			return fmt.Errorf("failed to encode site %s: %w", site.SiteID, err)
+		}
+		sitesNode.Content = append(sitesNode.Content, &entry)
+		return nil
+	})
+}
+
+// SetTopologyAllocation records where a fragment is stored in the topology file.
+// Allocating a fragment back to its own branch removes the override.
+func SetTopologyAllocation(path string, allocation AllocationConfig) error {
+	return editTopology(path, func(root *yaml.Node) error {
+		allocationsNode := mappingValue(root, "allocations")
+		if allocationsNode == nil {
+			root.Content = append(root.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Value: "allocations"},
+				&yaml.Node{Kind: yaml.SequenceNode})
+			allocationsNode = root.Content[len(root.Content)-1]
+		}
+
+		var kept []*yaml.Node
+		for _, node := range allocationsNode.Content {
+			var existing topologyAllocation
+			if err := node.Decode(&existing); err != nil {
+				return fmt.Errorf("invalid allocation entry: %w", err)
+			}
+			if existing.Relation != allocation.Relation || existing.Fragment != allocation.Fragment {
+				kept = append(kept, node)
+			}
+		}
+
+		if allocation.Site != allocation.Fragment {
+			var entry yaml.Node
+			if err := entry.Encode(topologyAllocation{
+				Relation: allocation.Relation,
+				Fragment: allocation.Fragment,
+				Site:     allocation.Site,
+			}); err != nil {
+				return fmt.Errorf("failed to encode allocation: %w", err)
+			}
+			kept = append(kept, &entry)
+		}
+		allocationsNode.Kind = yaml.SequenceNode
+		allocationsNode.Content = kept
+		return nil
+	})
+}
+
+// editTopology applies edit to the parsed topology file and writes it back atomically
+func editTopology(path string, edit func(root *yaml.Node) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read topology file %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse topology file %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("topology file %s: expected a mapping at the top level", path)
+	}
+
+	if err := edit(doc.Content[0]); err != nil {
+		return fmt.Errorf("topology file %s: %w", path, err)
+	}
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return fmt.Errorf("failed to encode topology file %s: %w", path, err)
+	}
+
+	// Write to a temp file and rename so a crash never leaves a truncated topology
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, out.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write topology file %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to replace topology file %s: %w", path, err)
+	}
+	return nil
+}
+
+// mappingValue returns the value node for key in a YAML mapping, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// ListenPort returns the port the site's HTTP service listens on, derived from its service URL
+func (s SiteConfig) ListenPort() (int, error) {
+	u, err := url.Parse(s.ServiceURL)
+	if err != nil {
+		return 0, fmt.Errorf("invalid service URL for site %s: %w", s.SiteID, err)
+	}
+	if u.Port() == "" {
+		switch u.Scheme {
+		case "https":
+			return 443, nil
+		case "http":
+			return 80, nil
+		}
+		return 0, fmt.Errorf("service URL for site %s has no port: %s", s.SiteID, s.ServiceURL)
+	}
+	return strconv.Atoi(u.Port())
+}
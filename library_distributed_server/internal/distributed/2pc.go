@@ -3,15 +3,18 @@ package distributed
 import (
 	"database/sql"
 	"fmt"
+	"library_distributed_server/internal/cache"
 	"library_distributed_server/internal/config"
+	"library_distributed_server/internal/models"
 	"library_distributed_server/pkg/database"
 	"log"
 )
 
 // TwoPhaseCommitCoordinator handles distributed transactions using 2PC protocol
 type TwoPhaseCommitCoordinator struct {
-	config *config.Config
-	pool   *database.ConnectionPool
+	store     *config.Store
+	pool      *database.ConnectionPool
+	publisher *cache.Publisher // Announces committed catalog writes to the site caches
 }
 
 // TransactionParticipant represents a site participating in distributed transaction
@@ -30,13 +33,23 @@ type DistributedTransaction struct {
 	Status       string // PREPARING, PREPARED, COMMITTING, COMMITTED, ABORTING, ABORTED
 }
 
-func NewTwoPhaseCommitCoordinator(config *config.Config) *TwoPhaseCommitCoordinator {
+func NewTwoPhaseCommitCoordinator(store *config.Store, publisher *cache.Publisher) *TwoPhaseCommitCoordinator {
 	return &TwoPhaseCommitCoordinator{
-		config: config,
-		pool:   database.GetPool(),
+		store:     store,
+		pool:      database.GetPool(),
+		publisher: publisher,
 	}
 }
 
+// connectSite returns a pooled connection to a registered branch site
+func connectSite(pool *database.ConnectionPool, cfg *config.Config, siteID string) (*sql.DB, error) {
+	connectionString, err := cfg.GetConnectionString(siteID)
+	if err != nil {
+		return nil, err
+	}
+	return pool.GetConnection(siteID, connectionString)
+}
+
 // TransferBook implements distributed book transfer between sites using 2PC
 // This is the academic demonstration of distributed transaction as required
 func (c *TwoPhaseCommitCoordinator) TransferBook(maQuyenSach, fromSite, toSite string) error {
@@ -50,12 +63,12 @@ func (c *TwoPhaseCommitCoordinator) TransferBook(maQuyenSach, fromSite, toSite s
 	}
 
 	// Get connections to both sites
-	fromConn, err := c.pool.GetConnection(fromSite, c.config.GetConnectionString(fromSite))
+	fromConn, err := connectSite(c.pool, c.store.Current(), fromSite)
 	if err != nil {
 		return fmt.Errorf("failed to connect to source site %s: %w", fromSite, err)
 	}
 
-	toConn, err := c.pool.GetConnection(toSite, c.config.GetConnectionString(toSite))
+	toConn, err := connectSite(c.pool, c.store.Current(), toSite)
 	if err != nil {
 		return fmt.Errorf("failed to connect to destination site %s: %w", toSite, err)
 	}
@@ -123,8 +136,9 @@ func (c *TwoPhaseCommitCoordinator) CreateSachDistributed(isbn, tenSach, tacGia,
 	}
 
 	// Get connections to all sites
-	for _, site := range c.config.Sites {
-		conn, err := c.pool.GetConnection(site.SiteID, c.config.GetConnectionString(site.SiteID))
+	cfg := c.store.Current()
+	for _, site := range cfg.Sites {
+		conn, err := connectSite(c.pool, cfg, site.SiteID)
 		if err != nil {
 			return fmt.Errorf("failed to connect to site %s: %w", site.SiteID, err)
 		}
@@ -143,7 +157,10 @@ func (c *TwoPhaseCommitCoordinator) CreateSachDistributed(isbn, tenSach, tacGia,
 	}
 
 	// Phase 2: COMMIT - Call sp_QuanLy_CommitCreateSach on all sites
-	if err := c.commitSachCreation(txn, isbn, tenSach, tacGia, transactionID); err != nil {
+	err := c.commitSachCreation(txn, isbn, tenSach, tacGia, transactionID)
+	// Sites that committed before a failure already hold the book
+	c.publisher.Publish(cache.Invalidation{Relation: "SACH", Keys: []string{isbn}})
+	if err != nil {
 		log.Printf("Commit phase failed for book creation: %v", err)
 		c.abortSachCreation(txn, transactionID)
 		return err
@@ -271,8 +288,8 @@ func (c *TwoPhaseCommitCoordinator) prepareDelete(participant *TransactionPartic
 	// Verify book exists and can be deleted
 	var count int
 	err := participant.Connection.QueryRow(
-		"SELECT COUNT(*) FROM QUYENSACH WHERE MaQuyenSach = ? AND TinhTrang = N'Có sẵn'",
-		maQuyenSach).Scan(&count)
+		"SELECT COUNT(*) FROM QUYENSACH WHERE MaQuyenSach = ? AND TinhTrang = ?",
+		maQuyenSach, models.CopyAvailable).Scan(&count)
 	if err != nil {
 		return err
 	}
@@ -283,8 +300,8 @@ func (c *TwoPhaseCommitCoordinator) prepareDelete(participant *TransactionPartic
 
 	// Prepare to delete (lock the record)
 	_, err = participant.Connection.Exec(
-		"UPDATE QUYENSACH SET TinhTrang = N'Đang chuyển' WHERE MaQuyenSach = ?",
-		maQuyenSach)
+		"UPDATE QUYENSACH SET TinhTrang = ? WHERE MaQuyenSach = ?",
+		models.CopyInTransit, maQuyenSach)
 
 	return err
 }
@@ -304,7 +321,7 @@ func (c *TwoPhaseCommitCoordinator) TransferBookUsingStoredProcedure(maQuyenSach
 	log.Printf("Using stored procedure for book transfer: %s from %s to %s", maQuyenSach, fromSite, toSite)
 
 	// Execute from the source site
-	conn, err := c.pool.GetConnection(fromSite, c.config.GetConnectionString(fromSite))
+	conn, err := connectSite(c.pool, c.store.Current(), fromSite)
 	if err != nil {
 		return fmt.Errorf("failed to connect to source site %s: %w", fromSite, err)
 	}
@@ -0,0 +1,551 @@
+package distributed
+
+import (
+	"database/sql"
+	"fmt"
+	"library_distributed_server/internal/cache"
+	"library_distributed_server/internal/catalog"
+	"library_distributed_server/internal/config"
+	"library_distributed_server/pkg/database"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Onboarding phases, in the order a job goes through them
+const (
+	OnboardPending     = "PENDING"
+	OnboardSchema      = "CREATING_SCHEMA"
+	OnboardCapture     = "INSTALLING_CAPTURE"
+	OnboardCopying     = "COPYING"
+	OnboardCatchingUp  = "CATCHING_UP"
+	OnboardRegistering = "REGISTERING"
+	OnboardSettling    = "SETTLING"
+	OnboardCompleted   = "COMPLETED"
+	OnboardFailed      = "FAILED"
+)
+
+const (
+	onboardBatchSize   = 500
+	onboardSettleDelay = 30 * time.Second // Time for branch sites to pick up the new topology before capture stops
+)
+
+// replicatedTables are copied to every new site
+var replicatedTables = catalog.ReplicatedRelations()
+
+// OnboardingSpec describes the branch site to bring into the system
+type OnboardingSpec struct {
+	Site       config.SiteConfig
+	TenCN      string // Branch name stored in CHINHANH
+	DiaChi     string // Branch address stored in CHINHANH
+	SourceSite string // Existing replica to copy the replicated relations from
+}
+
+// OnboardingJob tracks the progress of one onboarding run
+type OnboardingJob struct {
+	ID              string         `json:"id" example:"onboard_Q5_1735689600"`
+	SiteID          string         `json:"siteId" example:"Q5"`
+	SourceSite      string         `json:"sourceSite" example:"Q1"`
+	Phase           string         `json:"phase" example:"COPYING"`
+	RowsCopied      map[string]int `json:"rowsCopied"`
+	ChangesReplayed int            `json:"changesReplayed"`
+	Error           string         `json:"error,omitempty"`
+	StartedAt       time.Time      `json:"startedAt"`
+	FinishedAt      *time.Time     `json:"finishedAt,omitempty"`
+}
+
+// OnboardingManager runs site onboarding jobs, one at a time.
+// Catalog writes stay possible during the copy: triggers on the source replica record
+// every changed key in ONBOARD_CAPTURE and the job replays them onto the new site.
+type OnboardingManager struct {
+	store       *config.Store
+	pool        *database.ConnectionPool
+	publisher   *cache.Publisher // Announces the new CHINHANH row to the site caches
+	settleDelay time.Duration
+
+	mutex   sync.RWMutex
+	jobs    map[string]*OnboardingJob
+	running string
+	lastSeq int64
+}
+
+func NewOnboardingManager(store *config.Store, publisher *cache.Publisher) *OnboardingManager {
+	return &OnboardingManager{
+		store:       store,
+		pool:        database.GetPool(),
+		publisher:   publisher,
+		settleDelay: onboardSettleDelay,
+		jobs:        make(map[string]*OnboardingJob),
+	}
+}
+
+// Start validates the spec and launches the onboarding job in the background
+func (m *OnboardingManager) Start(spec OnboardingSpec) (*OnboardingJob, error) {
+	if err := database.ValidateSiteID(spec.Site.SiteID); err != nil {
+		return nil, err
+	}
+	cfg := m.store.Current()
+	if _, err := cfg.GetSite(spec.Site.SiteID); err == nil {
+		return nil, fmt.Errorf("site %s is already part of the topology", spec.Site.SiteID)
+	}
+	if spec.SourceSite == "" {
+		spec.SourceSite = cfg.Sites[0].SiteID
+	}
+	if _, err := cfg.GetSite(spec.SourceSite); err != nil {
+		return nil, fmt.Errorf("invalid source site: %w", err)
+	}
+	if _, err := spec.Site.ListenPort(); err != nil {
+		return nil, err
+	}
+	spec.Site.Role = config.RoleBranch
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.running != "" {
+		return nil, fmt.Errorf("onboarding job %s is still running", m.running)
+	}
+
+	job := &OnboardingJob{
+		ID:         fmt.Sprintf("onboard_%s_%d", spec.Site.SiteID, time.Now().Unix()),
+		SiteID:     spec.Site.SiteID,
+		SourceSite: spec.SourceSite,
+		Phase:      OnboardPending,
+		RowsCopied: make(map[string]int),
+		StartedAt:  time.Now(),
+	}
+	m.jobs[job.ID] = job
+	m.running = job.ID
+	m.lastSeq = 0
+
+	go m.run(job, spec)
+
+	return m.snapshot(job), nil
+}
+
+// GetJob returns a copy of the job's current state
+func (m *OnboardingManager) GetJob(jobID string) (*OnboardingJob, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	job, exists := m.jobs[jobID]
+	if !exists {
+		return nil, fmt.Errorf("onboarding job not found: %s", jobID)
+	}
+	return m.snapshot(job), nil
+}
+
+// snapshot copies a job; callers must hold the mutex
+func (m *OnboardingManager) snapshot(job *OnboardingJob) *OnboardingJob {
+	copied := *job
+	copied.RowsCopied = make(map[string]int, len(job.RowsCopied))
+	for k, v := range job.RowsCopied {
+		copied.RowsCopied[k] = v
+	}
+	return &copied
+}
+
+func (m *OnboardingManager) setPhase(job *OnboardingJob, phase string) {
+	m.mutex.Lock()
+	job.Phase = phase
+	m.mutex.Unlock()
+	log.Printf("Onboarding %s: %s", job.SiteID, phase)
+}
+
+func (m *OnboardingManager) finish(job *OnboardingJob, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	job.FinishedAt = &now
+	if err != nil {
+		job.Phase = OnboardFailed
+		job.Error = err.Error()
+		log.Printf("Onboarding %s failed: %v", job.SiteID, err)
+	} else {
+		job.Phase = OnboardCompleted
+		log.Printf("Onboarding %s completed", job.SiteID)
+	}
+	m.running = ""
+}
+
+func (m *OnboardingManager) run(job *OnboardingJob, spec OnboardingSpec) {
+	source, err := connectSite(m.pool, m.store.Current(), spec.SourceSite)
+	if err != nil {
+		m.finish(job, fmt.Errorf("failed to connect to source site %s: %w", spec.SourceSite, err))
+		return
+	}
+
+	err = m.onboard(job, spec, source)
+	if err != nil {
+		// Never leave capture triggers behind on the source replica
+		if cleanupErr := dropCapture(source); cleanupErr != nil {
+			log.Printf("Onboarding %s: failed to remove capture from %s: %v", job.SiteID, spec.SourceSite, cleanupErr)
+		}
+	}
+	m.finish(job, err)
+}
+
+func (m *OnboardingManager) onboard(job *OnboardingJob, spec OnboardingSpec, source *sql.DB) error {
+	siteID := spec.Site.SiteID
+
+	m.setPhase(job, OnboardSchema)
+	target, err := m.pool.GetConnection(siteID, m.store.Current().ConnectionStringFor(spec.Site))
+	if err != nil {
+		return fmt.Errorf("failed to connect to new site %s: %w", siteID, err)
+	}
+	if err := database.Migrate(target, siteID); err != nil {
+		return err
+	}
+
+	// Capture must be in place before the copy starts so no change falls between the two
+	m.setPhase(job, OnboardCapture)
+	if err := installCapture(source); err != nil {
+		return err
+	}
+
+	m.setPhase(job, OnboardCopying)
+	for _, tbl := range replicatedTables {
+		if err := m.copyTable(job, source, target, tbl); err != nil {
+			return err
+		}
+	}
+
+	// Register the branch in CHINHANH on every replica, including the new one
+	chiNhanh, err := lookupReplicatedTable("CHINHANH")
+	if err != nil {
+		return err
+	}
+	branch := []interface{}{siteID, spec.TenCN, spec.DiaChi}
+	cfg := m.store.Current()
+	for _, site := range cfg.Sites {
+		conn, err := connectSite(m.pool, cfg, site.SiteID)
+		if err != nil {
+			return fmt.Errorf("failed to connect to site %s: %w", site.SiteID, err)
+		}
+		if _, err := conn.Exec(upsertQuery(chiNhanh), branch...); err != nil {
+			return fmt.Errorf("failed to add branch %s to CHINHANH on site %s: %w", siteID, site.SiteID, err)
+		}
+	}
+	if _, err := target.Exec(upsertQuery(chiNhanh), branch...); err != nil {
+		return fmt.Errorf("failed to add branch %s to CHINHANH on site %s: %w", siteID, siteID, err)
+	}
+	m.publisher.Publish(cache.Invalidation{Relation: "CHINHANH", Keys: []string{siteID}})
+
+	m.setPhase(job, OnboardCatchingUp)
+	for {
+		n, err := m.replayChanges(job, source, target)
+		if err != nil {
+			return err
+		}
+		if n < onboardBatchSize {
+			break
+		}
+	}
+
+	m.setPhase(job, OnboardRegistering)
+	if err := config.AppendTopologySite(cfg.TopologyFile, spec.Site); err != nil {
+		return err
+	}
+	// The topology file watcher may already have picked up the new site
+	err = m.store.Update(func(next *config.Config) error {
+		if _, err := next.GetSite(siteID); err == nil {
+			return nil
+		}
+		return next.AddSite(spec.Site)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Branch sites only dual-write catalog changes to the new site once they load the
+	// new topology, so keep replaying captured changes for a while before stopping capture
+	m.setPhase(job, OnboardSettling)
+	deadline := time.Now().Add(m.settleDelay)
+	for time.Now().Before(deadline) {
+		if _, err := m.replayChanges(job, source, target); err != nil {
+			return err
+		}
+		time.Sleep(time.Second)
+	}
+
+	if err := m.finalizeCapture(job, source, target); err != nil {
+		return err
+	}
+	// The new site may have cached catalog rows before the replayed changes reached it
+	m.publisher.Publish(cache.Invalidation{})
+	return nil
+}
+
+// copyTable streams a replicated table from the source replica and upserts it in batches
+func (m *OnboardingManager) copyTable(job *OnboardingJob, source, target *sql.DB, tbl catalog.Relation) error {
+	query := fmt.Sprintf("SELECT %s FROM %s ORDER BY %s", strings.Join(tbl.Columns, ", "), tbl.Name, tbl.PrimaryKey)
+	rows, err := source.Query(query)
+	if err != nil {
+		return fmt.Errorf("failed to read %s from source: %w", tbl.Name, err)
+	}
+	defer rows.Close()
+
+	var batch [][]interface{}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := upsertBatch(target, tbl, batch); err != nil {
+			return err
+		}
+		m.mutex.Lock()
+		job.RowsCopied[tbl.Name] += len(batch)
+		m.mutex.Unlock()
+		batch = batch[:0]
+		return nil
+	}
+
+	for rows.Next() {
+		values, err := scanStrings(rows, len(tbl.Columns))
+		if err != nil {
+			return fmt.Errorf("failed to scan %s row: %w", tbl.Name, err)
+		}
+		batch = append(batch, values)
+		if len(batch) >= onboardBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read %s from source: %w", tbl.Name, err)
+	}
+	return flush()
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// capturedChange is one key recorded by the capture triggers
+type capturedChange struct {
+	Seq   int64
+	Table string
+	Key   string
+}
+
+// replayChanges applies the next batch of captured changes to the target.
+// Each change re-reads the current source row, so replaying twice is harmless.
+func (m *OnboardingManager) replayChanges(job *OnboardingJob, source queryer, target *sql.DB) (int, error) {
+	rows, err := source.Query(fmt.Sprintf(
+		"SELECT TOP %d Seq, TableName, KeyValue FROM ONBOARD_CAPTURE WHERE Seq > ? ORDER BY Seq", onboardBatchSize),
+		m.lastSeq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read captured changes: %w", err)
+	}
+
+	var changes []capturedChange
+	for rows.Next() {
+		var ch capturedChange
+		if err := rows.Scan(&ch.Seq, &ch.Table, &ch.Key); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan captured change: %w", err)
+		}
+		changes = append(changes, ch)
+	}
+	rows.Close()
+
+	for _, ch := range changes {
+		tbl, err := lookupReplicatedTable(ch.Table)
+		if err != nil {
+			return 0, err
+		}
+
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = ?", strings.Join(tbl.Columns, ", "), tbl.Name, tbl.PrimaryKey)
+		rowValues := make([]sql.NullString, len(tbl.Columns))
+		dest := make([]interface{}, len(rowValues))
+		for i := range rowValues {
+			dest[i] = &rowValues[i]
+		}
+
+		err = source.QueryRow(query, ch.Key).Scan(dest...)
+		switch {
+		case err == sql.ErrNoRows:
+			deleteQuery := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", tbl.Name, tbl.PrimaryKey)
+			if _, err := target.Exec(deleteQuery, ch.Key); err != nil {
+				return 0, fmt.Errorf("failed to replay delete of %s %s: %w", tbl.Name, ch.Key, err)
+			}
+		case err != nil:
+			return 0, fmt.Errorf("failed to read %s %s from source: %w", tbl.Name, ch.Key, err)
+		default:
+			if _, err := target.Exec(upsertQuery(tbl), params(rowValues)...); err != nil {
+				return 0, fmt.Errorf("failed to replay %s %s: %w", tbl.Name, ch.Key, err)
+			}
+		}
+
+		m.mutex.Lock()
+		m.lastSeq = ch.Seq
+		job.ChangesReplayed++
+		m.mutex.Unlock()
+	}
+
+	return len(changes), nil
+}
+
+// finalizeCapture blocks catalog writes on the source for the final drain, then removes capture
+func (m *OnboardingManager) finalizeCapture(job *OnboardingJob, source, target *sql.DB) error {
+	tx, err := source.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin final drain: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, tbl := range replicatedTables {
+		var count int
+		lockQuery := fmt.Sprintf("SELECT COUNT(*) FROM %s WITH (TABLOCKX, HOLDLOCK)", tbl.Name)
+		if err := tx.QueryRow(lockQuery).Scan(&count); err != nil {
+			return fmt.Errorf("failed to lock %s for final drain: %w", tbl.Name, err)
+		}
+	}
+
+	for {
+		n, err := m.replayChanges(job, tx, target)
+		if err != nil {
+			return err
+		}
+		if n < onboardBatchSize {
+			break
+		}
+	}
+
+	if err := dropCapture(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func lookupReplicatedTable(name string) (catalog.Relation, error) {
+	for _, tbl := range replicatedTables {
+		if tbl.Name == name {
+			return tbl, nil
+		}
+	}
+	return catalog.Relation{}, fmt.Errorf("unknown replicated table: %s", name)
+}
+
+// upsertQuery builds an idempotent MERGE for one row of a replicated table
+func upsertQuery(tbl catalog.Relation) string {
+	sourceCols := make([]string, len(tbl.Columns))
+	insertVals := make([]string, len(tbl.Columns))
+	var updates []string
+	for i, col := range tbl.Columns {
+		sourceCols[i] = "? AS " + col
+		insertVals[i] = "s." + col
+		if col != tbl.PrimaryKey {
+			updates = append(updates, fmt.Sprintf("%s = s.%s", col, col))
+		}
+	}
+
+	return fmt.Sprintf(`MERGE %s WITH (HOLDLOCK) AS t
+		USING (SELECT %s) AS s ON t.%s = s.%s
+		WHEN MATCHED THEN UPDATE SET %s
+		WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s);`,
+		tbl.Name, strings.Join(sourceCols, ", "), tbl.PrimaryKey, tbl.PrimaryKey,
+		strings.Join(updates, ", "), strings.Join(tbl.Columns, ", "), strings.Join(insertVals, ", "))
+}
+
+func upsertBatch(target *sql.DB, tbl catalog.Relation, batch [][]interface{}) error {
+	tx, err := target.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin copy of %s: %w", tbl.Name, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(upsertQuery(tbl))
+	if err != nil {
+		return fmt.Errorf("failed to prepare copy of %s: %w", tbl.Name, err)
+	}
+	defer stmt.Close()
+
+	for _, values := range batch {
+		if _, err := stmt.Exec(values...); err != nil {
+			return fmt.Errorf("failed to copy %s %v: %w", tbl.Name, values[0], err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func scanStrings(rows *sql.Rows, n int) ([]interface{}, error) {
+	values := make([]sql.NullString, n)
+	dest := make([]interface{}, n)
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return params(values), nil
+}
+
+// params turns scanned column values into query parameters, keeping NULLs as NULL
+// (global CHINHSACH policies have no MaCN or LoaiDG)
+func params(values []sql.NullString) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		if v.Valid {
+			result[i] = v.String
+		}
+	}
+	return result
+}
+
+// installCapture creates the capture table and the triggers on the replicated tables
+func installCapture(db *sql.DB) error {
+	_, err := db.Exec(`
+		IF NOT EXISTS (SELECT * FROM sys.tables WHERE name = 'ONBOARD_CAPTURE')
+		CREATE TABLE ONBOARD_CAPTURE (
+			Seq BIGINT IDENTITY(1,1) PRIMARY KEY,
+			TableName VARCHAR(20) NOT NULL,
+			KeyValue VARCHAR(20) NOT NULL,
+			CapturedAt DATETIME NOT NULL DEFAULT GETDATE()
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to create capture table: %w", err)
+	}
+
+	// Leftovers from an earlier failed run are covered by the full copy
+	if _, err := db.Exec("DELETE FROM ONBOARD_CAPTURE"); err != nil {
+		return fmt.Errorf("failed to reset capture table: %w", err)
+	}
+
+	for _, tbl := range replicatedTables {
+		trigger := fmt.Sprintf(`CREATE OR ALTER TRIGGER trg_OnboardCapture_%s ON %s
+			AFTER INSERT, UPDATE, DELETE
+			AS
+			BEGIN
+				SET NOCOUNT ON;
+				INSERT INTO ONBOARD_CAPTURE (TableName, KeyValue)
+				SELECT '%s', %s FROM inserted
+				UNION
+				SELECT '%s', %s FROM deleted;
+			END`, tbl.Name, tbl.Name, tbl.Name, tbl.PrimaryKey, tbl.Name, tbl.PrimaryKey)
+		if _, err := db.Exec(trigger); err != nil {
+			return fmt.Errorf("failed to create capture trigger on %s: %w", tbl.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func dropCapture(q queryer) error {
+	for _, tbl := range replicatedTables {
+		if _, err := q.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS trg_OnboardCapture_%s", tbl.Name)); err != nil {
+			return fmt.Errorf("failed to drop capture trigger on %s: %w", tbl.Name, err)
+		}
+	}
+	if _, err := q.Exec("DROP TABLE IF EXISTS ONBOARD_CAPTURE"); err != nil {
+		return fmt.Errorf("failed to drop capture table: %w", err)
+	}
+	return nil
+}
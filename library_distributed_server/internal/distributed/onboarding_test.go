@@ -0,0 +1,123 @@
+package distributed
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestParamsKeepNulls(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []sql.NullString
+		want   []interface{}
+	}{
+		{
+			name: "branch policy",
+			values: []sql.NullString{
+				{String: "CS-Q1-SV", Valid: true}, {String: "Q1", Valid: true}, {String: "Sinh viên", Valid: true},
+			},
+			want: []interface{}{"CS-Q1-SV", "Q1", "Sinh viên"},
+		},
+		{
+			name: "global policy",
+			values: []sql.NullString{
+				{String: "CS-ALL", Valid: true}, {}, {},
+			},
+			want: []interface{}{"CS-ALL", nil, nil},
+		},
+		{
+			name:   "empty string is not NULL",
+			values: []sql.NullString{{String: "", Valid: true}},
+			want:   []interface{}{""},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := params(tt.values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: params = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// rowsDriver is a database/sql driver whose every query returns the same rows
+type rowsDriver struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (d rowsDriver) Open(string) (driver.Conn, error) { return rowsConn{d}, nil }
+
+type rowsConn struct{ d rowsDriver }
+
+func (c rowsConn) Prepare(string) (driver.Stmt, error) { return rowsStmt(c), nil }
+func (c rowsConn) Close() error                        { return nil }
+func (c rowsConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+type rowsStmt struct{ d rowsDriver }
+
+func (s rowsStmt) Close() error  { return nil }
+func (s rowsStmt) NumInput() int { return -1 }
+func (s rowsStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s rowsStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &fixedRows{columns: s.d.columns, rows: s.d.rows}, nil
+}
+
+type fixedRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fixedRows) Columns() []string { return r.columns }
+func (r *fixedRows) Close() error      { return nil }
+func (r *fixedRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestScanStringsCopiesNulls(t *testing.T) {
+	sql.Register("onboarding-rows", rowsDriver{
+		columns: []string{"MaCS", "MaCN", "LoaiDG", "SoNgayMuon"},
+		rows: [][]driver.Value{
+			{"CS-ALL", nil, nil, int64(14)},
+			{"CS-Q1", "Q1", nil, int64(21)},
+		},
+	})
+	db, err := sql.Open("onboarding-rows", "")
+	if err != nil {
+		t.Fatalf("sql.Open failed: %v", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("SELECT MaCS, MaCN, LoaiDG, SoNgayMuon FROM CHINHSACH")
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	defer rows.Close()
+
+	var copied [][]interface{}
+	for rows.Next() {
+		values, err := scanStrings(rows, 4)
+		if err != nil {
+			t.Fatalf("scanStrings failed: %v", err)
+		}
+		copied = append(copied, values)
+	}
+
+	want := [][]interface{}{
+		{"CS-ALL", nil, nil, "14"},
+		{"CS-Q1", "Q1", nil, "21"},
+	}
+	if !reflect.DeepEqual(copied, want) {
+		t.Errorf("copied %#v, want %#v", copied, want)
+	}
+}
@@ -0,0 +1,513 @@
+package distributed
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"library_distributed_server/internal/catalog"
+	"library_distributed_server/internal/config"
+	"library_distributed_server/pkg/database"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Relocation phases, in the order a job goes through them
+const (
+	RelocatePending   = "PENDING"
+	RelocatePreparing = "PREPARING"
+	RelocateCopying   = "COPYING"
+	RelocateVerifying = "VERIFYING"
+	RelocateSwitching = "SWITCHING_ALLOCATION"
+	RelocateCompleted = "COMPLETED"
+	RelocateFailed    = "FAILED"
+)
+
+const relocateChunkSize = 500
+
+// generatedKeys marks relations whose primary key is an IDENTITY column.
+// Their rows receive new keys at the target site, so the key is left out of copies and checksums.
+var generatedKeys = map[string]bool{
+	"PHIEUMUON": true,
+}
+
+// RelocationSpec describes which branch fragment to move and where
+type RelocationSpec struct {
+	Branch     string // Fragment key value (MaCN) of the branch being moved
+	TargetSite string // Site that will store the branch's fragments
+	Restart    bool   // Discard partial progress on the target and copy from scratch
+}
+
+// RelationProgress tracks the copy of one relation's fragment
+type RelationProgress struct {
+	TotalRows  int  `json:"totalRows"`
+	RowsCopied int  `json:"rowsCopied"`
+	Verified   bool `json:"verified"`
+}
+
+// RelocationJob tracks the progress of one fragment relocation
+type RelocationJob struct {
+	ID         string                      `json:"id" example:"relocate_Q1_to_Q3"`
+	Branch     string                      `json:"branch" example:"Q1"`
+	SourceSite string                      `json:"sourceSite" example:"Q1"`
+	TargetSite string                      `json:"targetSite" example:"Q3"`
+	Phase      string                      `json:"phase" example:"COPYING"`
+	Progress   map[string]RelationProgress `json:"progress"`
+	Error      string                      `json:"error,omitempty"`
+	StartedAt  time.Time                   `json:"startedAt"`
+	FinishedAt *time.Time                  `json:"finishedAt,omitempty"`
+}
+
+// RelocationManager moves every horizontal fragment of a branch (DOCGIA, QUYENSACH, PHIEUMUON, DATCHO,
+// PHAT, GIAODICHPHAT, YEUCAUMUON, CHUYENTRA) to another site. Chunks are copied in target transactions that
+// also advance a progress row in RELOCATION_PROGRESS, so an interrupted job resumes where it
+// stopped when started again.
+// Writes to the branch's source rows are blocked while the job holds its key-range locks; the
+// other branches stored on the source site are not locked.
+type RelocationManager struct {
+	store *config.Store
+	pool  *database.ConnectionPool
+
+	mutex   sync.RWMutex
+	jobs    map[string]*RelocationJob
+	running string
+}
+
+func NewRelocationManager(store *config.Store) *RelocationManager {
+	return &RelocationManager{
+		store: store,
+		pool:  database.GetPool(),
+		jobs:  make(map[string]*RelocationJob),
+	}
+}
+
+// Start validates the spec and launches the relocation in the background
+func (m *RelocationManager) Start(spec RelocationSpec) (*RelocationJob, error) {
+	cfg := m.store.Current()
+	if _, err := cfg.GetSite(spec.TargetSite); err != nil {
+		return nil, fmt.Errorf("invalid target site: %w", err)
+	}
+
+	// All fragments of the branch must currently live on one site
+	fragments := catalog.FromConfig(cfg)
+	sourceSite := ""
+	for _, rel := range catalog.HorizontalRelations() {
+		siteID, err := fragments.SiteFor(rel.Name, spec.Branch, "")
+		if err != nil {
+			return nil, err
+		}
+		if sourceSite != "" && siteID != sourceSite {
+			return nil, fmt.Errorf("fragments of branch %s are split across sites %s and %s", spec.Branch, sourceSite, siteID)
+		}
+		sourceSite = siteID
+	}
+	if sourceSite == spec.TargetSite {
+		return nil, fmt.Errorf("fragments of branch %s are already stored at site %s", spec.Branch, spec.TargetSite)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.running != "" {
+		return nil, fmt.Errorf("relocation job %s is still running", m.running)
+	}
+
+	// The ID is deterministic so starting the same relocation again resumes its progress
+	job := &RelocationJob{
+		ID:         fmt.Sprintf("relocate_%s_to_%s", spec.Branch, spec.TargetSite),
+		Branch:     spec.Branch,
+		SourceSite: sourceSite,
+		TargetSite: spec.TargetSite,
+		Phase:      RelocatePending,
+		Progress:   make(map[string]RelationProgress),
+		StartedAt:  time.Now(),
+	}
+	m.jobs[job.ID] = job
+	m.running = job.ID
+
+	go m.run(job, spec)
+
+	return m.snapshot(job), nil
+}
+
+// GetJob returns a copy of the job's current state
+func (m *RelocationManager) GetJob(jobID string) (*RelocationJob, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	job, exists := m.jobs[jobID]
+	if !exists {
+		return nil, fmt.Errorf("relocation job not found: %s", jobID)
+	}
+	return m.snapshot(job), nil
+}
+
+// snapshot copies a job; callers must hold the mutex
+func (m *RelocationManager) snapshot(job *RelocationJob) *RelocationJob {
+	copied := *job
+	copied.Progress = make(map[string]RelationProgress, len(job.Progress))
+	for k, v := range job.Progress {
+		copied.Progress[k] = v
+	}
+	return &copied
+}
+
+func (m *RelocationManager) setPhase(job *RelocationJob, phase string) {
+	m.mutex.Lock()
+	job.Phase = phase
+	m.mutex.Unlock()
+	log.Printf("Relocation %s: %s", job.ID, phase)
+}
+
+func (m *RelocationManager) updateProgress(job *RelocationJob, relation string, update func(p *RelationProgress)) {
+	m.mutex.Lock()
+	p := job.Progress[relation]
+	update(&p)
+	job.Progress[relation] = p
+	m.mutex.Unlock()
+}
+
+func (m *RelocationManager) finish(job *RelocationJob, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	job.FinishedAt = &now
+	if err != nil {
+		job.Phase = RelocateFailed
+		job.Error = err.Error()
+		log.Printf("Relocation %s failed: %v", job.ID, err)
+	} else {
+		job.Phase = RelocateCompleted
+		log.Printf("Relocation %s completed", job.ID)
+	}
+	m.running = ""
+}
+
+func (m *RelocationManager) run(job *RelocationJob, spec RelocationSpec) {
+	m.finish(job, m.relocate(context.Background(), job, spec))
+}
+
+func (m *RelocationManager) relocate(ctx context.Context, job *RelocationJob, spec RelocationSpec) error {
+	relations := catalog.HorizontalRelations()
+
+	cfg := m.store.Current()
+	source, err := connectSite(m.pool, cfg, job.SourceSite)
+	if err != nil {
+		return fmt.Errorf("failed to connect to source site %s: %w", job.SourceSite, err)
+	}
+	target, err := connectSite(m.pool, cfg, job.TargetSite)
+	if err != nil {
+		return fmt.Errorf("failed to connect to target site %s: %w", job.TargetSite, err)
+	}
+
+	m.setPhase(job, RelocatePreparing)
+	if err := m.prepareTarget(ctx, job, spec, target, relations); err != nil {
+		return err
+	}
+
+	// Update and key-range locks on the branch's rows, held until commit, freeze the source
+	// fragment for the whole move. The fragment key index (migration 0010) keeps them to the
+	// branch's range, so the other branches of the source site stay writable.
+	sourceTx, err := source.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return fmt.Errorf("failed to begin source transaction: %w", err)
+	}
+	defer sourceTx.Rollback()
+
+	for _, rel := range relations {
+		var total int
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s WITH (UPDLOCK, HOLDLOCK, ROWLOCK) WHERE %s = ?", rel.Name, rel.FragmentKey)
+		if err := sourceTx.QueryRowContext(ctx, query, job.Branch).Scan(&total); err != nil {
+			return fmt.Errorf("failed to lock %s on source: %w", rel.Name, err)
+		}
+		m.updateProgress(job, rel.Name, func(p *RelationProgress) { p.TotalRows = total })
+	}
+
+	// A run that failed after committing the source cleanup left the rows on the target only;
+	// it resumes by switching the allocation
+	if m.cleanedUp(job) {
+		log.Printf("Relocation %s: source rows were already dropped, switching allocation", job.ID)
+		if err := sourceTx.Commit(); err != nil {
+			return fmt.Errorf("failed to release source locks: %w", err)
+		}
+		return m.switchAllocation(ctx, job, cfg.TopologyFile, target, relations)
+	}
+
+	m.setPhase(job, RelocateCopying)
+	for _, rel := range relations {
+		if err := m.copyFragment(ctx, job, sourceTx, target, rel); err != nil {
+			return err
+		}
+	}
+
+	m.setPhase(job, RelocateVerifying)
+	for _, rel := range relations {
+		if err := m.verifyFragment(ctx, job, sourceTx, target, rel); err != nil {
+			return err
+		}
+	}
+
+	// Drop the source rows inside the locked transaction; a failure up to its commit leaves the
+	// source fragment intact and still routed to
+	m.setPhase(job, RelocateSwitching)
+	for i := len(relations) - 1; i >= 0; i-- {
+		rel := relations[i]
+		query := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", rel.Name, rel.FragmentKey)
+		if _, err := sourceTx.ExecContext(ctx, query, job.Branch); err != nil {
+			return fmt.Errorf("failed to drop %s fragment on source: %w", rel.Name, err)
+		}
+	}
+	if err := sourceTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit source cleanup: %w", err)
+	}
+
+	// The allocation points to the target only once its rows are the only copy
+	return m.switchAllocation(ctx, job, cfg.TopologyFile, target, relations)
+}
+
+// cleanedUp reports whether the source no longer holds rows of the branch while the target
+// holds the copies recorded by the job's progress, i.e. only the allocation switch is left
+func (m *RelocationManager) cleanedUp(job *RelocationJob) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	copied := 0
+	for _, p := range job.Progress {
+		if p.TotalRows > 0 {
+			return false
+		}
+		copied += p.RowsCopied
+	}
+	return copied > 0
+}
+
+// switchAllocation routes the branch's fragments to the target site and clears the job's progress
+func (m *RelocationManager) switchAllocation(ctx context.Context, job *RelocationJob, topologyFile string, target *sql.DB, relations []catalog.Relation) error {
+	for _, rel := range relations {
+		allocation := config.AllocationConfig{Relation: rel.Name, Fragment: job.Branch, Site: job.TargetSite}
+		if err := config.SetTopologyAllocation(topologyFile, allocation); err != nil {
+			return fmt.Errorf("source rows were dropped but the allocation still points to %s; start again to switch it: %w", job.SourceSite, err)
+		}
+		err := m.store.Update(func(next *config.Config) error {
+			next.SetAllocation(allocation)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("source rows were dropped but the allocation still points to %s; start again to switch it: %w", job.SourceSite, err)
+		}
+	}
+
+	if _, err := target.ExecContext(ctx, "DELETE FROM RELOCATION_PROGRESS WHERE JobID = ?", job.ID); err != nil {
+		log.Printf("Relocation %s: failed to clear progress rows: %v", job.ID, err)
+	}
+
+	return nil
+}
+
+// prepareTarget makes sure the target schema accepts the branch's rows and loads earlier progress
+func (m *RelocationManager) prepareTarget(ctx context.Context, job *RelocationJob, spec RelocationSpec, target *sql.DB, relations []catalog.Relation) error {
+	if err := database.Migrate(target, job.TargetSite); err != nil {
+		return err
+	}
+
+	_, err := target.ExecContext(ctx, `
+		IF NOT EXISTS (SELECT * FROM sys.tables WHERE name = 'RELOCATION_PROGRESS')
+		CREATE TABLE RELOCATION_PROGRESS (
+			JobID VARCHAR(100) NOT NULL,
+			RelationName VARCHAR(50) NOT NULL,
+			LastKey VARCHAR(50) NOT NULL,
+			RowsCopied INT NOT NULL,
+			UpdatedAt DATETIME NOT NULL DEFAULT GETDATE(),
+			PRIMARY KEY (JobID, RelationName)
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to create progress table: %w", err)
+	}
+
+	tx, err := target.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin target preparation: %w", err)
+	}
+	defer tx.Rollback()
+
+	if spec.Restart {
+		for i := len(relations) - 1; i >= 0; i-- {
+			rel := relations[i]
+			query := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", rel.Name, rel.FragmentKey)
+			if _, err := tx.ExecContext(ctx, query, job.Branch); err != nil {
+				return fmt.Errorf("failed to discard partial copy of %s: %w", rel.Name, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM RELOCATION_PROGRESS WHERE JobID = ?", job.ID); err != nil {
+			return fmt.Errorf("failed to reset progress: %w", err)
+		}
+	}
+
+	// Widen each fragment CHECK constraint to the branches the target will store
+	fragments := catalog.FromConfig(m.store.Current())
+	for _, rel := range relations {
+		values := []string{job.Branch}
+		relation, err := fragments.Relation(rel.Name)
+		if err != nil {
+			return err
+		}
+		for _, f := range relation.Fragments {
+			if f.Sites[0] == job.TargetSite {
+				values = append(values, f.Value)
+			}
+		}
+		if err := database.SetFragmentValues(tx, rel.Name, rel.FragmentKey, values); err != nil {
+			return err
+		}
+	}
+
+	for _, rel := range relations {
+		var existing, progressRows, copied int
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = ?", rel.Name, rel.FragmentKey)
+		if err := tx.QueryRowContext(ctx, query, job.Branch).Scan(&existing); err != nil {
+			return fmt.Errorf("failed to inspect target %s: %w", rel.Name, err)
+		}
+		err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*), ISNULL(SUM(RowsCopied), 0) FROM RELOCATION_PROGRESS
+			WHERE JobID = ? AND RelationName = ?
+		`, job.ID, rel.Name).Scan(&progressRows, &copied)
+		if err != nil {
+			return fmt.Errorf("failed to load progress for %s: %w", rel.Name, err)
+		}
+		if existing != copied {
+			return fmt.Errorf("target already holds %d %s rows for branch %s but progress records %d; start again with restart",
+				existing, rel.Name, job.Branch, copied)
+		}
+		m.updateProgress(job, rel.Name, func(p *RelationProgress) { p.RowsCopied = copied })
+	}
+
+	return tx.Commit()
+}
+
+// copyFragment copies one relation's fragment in key order, one chunk per target transaction
+func (m *RelocationManager) copyFragment(ctx context.Context, job *RelocationJob, sourceTx *sql.Tx, target *sql.DB, rel catalog.Relation) error {
+	lastKey := ""
+	if generatedKeys[rel.Name] {
+		lastKey = "0"
+	}
+	err := target.QueryRowContext(ctx,
+		"SELECT LastKey FROM RELOCATION_PROGRESS WHERE JobID = ? AND RelationName = ?",
+		job.ID, rel.Name).Scan(&lastKey)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to load progress for %s: %w", rel.Name, err)
+	}
+
+	insertCols := copyColumns(rel)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(insertCols)), ", ")
+	insertQuery := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", rel.Name, strings.Join(insertCols, ", "), placeholders)
+	selectQuery := fmt.Sprintf("SELECT TOP %d %s, %s FROM %s WHERE %s = ? AND %s > ? ORDER BY %s",
+		relocateChunkSize, rel.PrimaryKey, strings.Join(insertCols, ", "), rel.Name, rel.FragmentKey, rel.PrimaryKey, rel.PrimaryKey)
+
+	for {
+		rows, err := sourceTx.QueryContext(ctx, selectQuery, job.Branch, lastKey)
+		if err != nil {
+			return fmt.Errorf("failed to read %s chunk: %w", rel.Name, err)
+		}
+
+		var keys []string
+		var chunk [][]interface{}
+		for rows.Next() {
+			values := make([]interface{}, len(insertCols)+1)
+			dest := make([]interface{}, len(values))
+			for i := range values {
+				dest[i] = &values[i]
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan %s row: %w", rel.Name, err)
+			}
+			keys = append(keys, fmt.Sprint(values[0]))
+			chunk = append(chunk, values[1:])
+		}
+		rows.Close()
+
+		if len(chunk) == 0 {
+			return nil
+		}
+
+		if err := m.writeChunk(ctx, job, target, rel, insertQuery, chunk, keys[len(keys)-1]); err != nil {
+			return err
+		}
+		lastKey = keys[len(keys)-1]
+
+		if len(chunk) < relocateChunkSize {
+			return nil
+		}
+	}
+}
+
+// writeChunk inserts a chunk and advances the progress row in the same transaction
+func (m *RelocationManager) writeChunk(ctx context.Context, job *RelocationJob, target *sql.DB, rel catalog.Relation, insertQuery string, chunk [][]interface{}, lastKey string) error {
+	tx, err := target.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin %s chunk: %w", rel.Name, err)
+	}
+	defer tx.Rollback()
+
+	for _, values := range chunk {
+		if _, err := tx.ExecContext(ctx, insertQuery, values...); err != nil {
+			return fmt.Errorf("failed to copy %s row: %w", rel.Name, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		MERGE RELOCATION_PROGRESS WITH (HOLDLOCK) AS t
+		USING (SELECT ? AS JobID, ? AS RelationName) AS s
+			ON t.JobID = s.JobID AND t.RelationName = s.RelationName
+		WHEN MATCHED THEN UPDATE SET LastKey = ?, RowsCopied = t.RowsCopied + ?, UpdatedAt = GETDATE()
+		WHEN NOT MATCHED THEN INSERT (JobID, RelationName, LastKey, RowsCopied) VALUES (s.JobID, s.RelationName, ?, ?);
+	`, job.ID, rel.Name, lastKey, len(chunk), lastKey, len(chunk))
+	if err != nil {
+		return fmt.Errorf("failed to record %s progress: %w", rel.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit %s chunk: %w", rel.Name, err)
+	}
+
+	m.updateProgress(job, rel.Name, func(p *RelationProgress) { p.RowsCopied += len(chunk) })
+	return nil
+}
+
+// verifyFragment compares row counts and checksums of the fragment on both sites
+func (m *RelocationManager) verifyFragment(ctx context.Context, job *RelocationJob, sourceTx *sql.Tx, target *sql.DB, rel catalog.Relation) error {
+	query := fmt.Sprintf("SELECT COUNT(*), ISNULL(CHECKSUM_AGG(BINARY_CHECKSUM(%s)), 0) FROM %s WHERE %s = ?",
+		strings.Join(copyColumns(rel), ", "), rel.Name, rel.FragmentKey)
+
+	var sourceCount, targetCount int
+	var sourceSum, targetSum int64
+	if err := sourceTx.QueryRowContext(ctx, query, job.Branch).Scan(&sourceCount, &sourceSum); err != nil {
+		return fmt.Errorf("failed to checksum %s on source: %w", rel.Name, err)
+	}
+	if err := target.QueryRowContext(ctx, query, job.Branch).Scan(&targetCount, &targetSum); err != nil {
+		return fmt.Errorf("failed to checksum %s on target: %w", rel.Name, err)
+	}
+
+	if sourceCount != targetCount || sourceSum != targetSum {
+		return fmt.Errorf("verification failed for %s: source %d rows (checksum %d), target %d rows (checksum %d)",
+			rel.Name, sourceCount, sourceSum, targetCount, targetSum)
+	}
+
+	m.updateProgress(job, rel.Name, func(p *RelationProgress) { p.Verified = true })
+	return nil
+}
+
+// copyColumns lists the columns written at the target, leaving out generated keys
+func copyColumns(rel catalog.Relation) []string {
+	if !generatedKeys[rel.Name] {
+		return rel.Columns
+	}
+	var cols []string
+	for _, col := range rel.Columns {
+		if col != rel.PrimaryKey {
+			cols = append(cols, col)
+		}
+	}
+	return cols
+}
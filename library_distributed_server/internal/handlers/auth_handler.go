@@ -317,8 +317,9 @@ func (h *AuthHandler) ValidateOperationAccess(operation string) gin.HandlerFunc
 				c.Abort()
 				return
 			}
-		case "BORROW_BOOK", "RETURN_BOOK":
-			// FR2, FR3: Only THUTHU can handle borrowing operations
+		case "BORROW_BOOK", "RETURN_BOOK", "RENEW_BORROW", "PLACE_HOLD", "CANCEL_HOLD", "RECORD_FINE_PAYMENT", "WAIVE_FINE",
+			"REQUEST_ILL", "SHIP_ILL", "RECEIVE_ILL", "CANCEL_ILL", "CHECK_IN_TRANSFER":
+			// FR2, FR3: Only THUTHU can handle borrowing operations, holds, fines and inter-library loans
 			if claims.Role != "THUTHU" {
 				c.JSON(http.StatusForbidden, models.ErrorResponse{
 					Error: fmt.Sprintf("Access denied - %s operation requires THUTHU role", operation),
@@ -345,7 +346,21 @@ func (h *AuthHandler) ValidateOperationAccess(operation string) gin.HandlerFunc
 				c.Abort()
 				return
 			}
-		case "SYSTEM_STATS", "GLOBAL_SEARCH", "MANAGE_CATALOG":
+		case "SEARCH_READERS":
+			// Librarians check every branch for duplicates before registering a reader
+			if claims.Role != "THUTHU" && claims.Role != "QUANLY" {
+				c.JSON(http.StatusForbidden, models.ErrorResponse{
+					Error: fmt.Sprintf("Access denied - %s operation requires THUTHU or QUANLY role", operation),
+					Details: gin.H{
+						"operation": operation,
+						"userRole":  claims.Role,
+						"required":  "THUTHU,QUANLY",
+					},
+				})
+				c.Abort()
+				return
+			}
+		case "SYSTEM_STATS", "GLOBAL_SEARCH", "MANAGE_CATALOG", "MANAGE_POLICIES", "MANAGE_TOPOLOGY":
 			// FR6, FR7, FR10: Only QUANLY can perform system-wide operations
 			if claims.Role != "QUANLY" {
 				c.JSON(http.StatusForbidden, models.ErrorResponse{
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -25,18 +27,69 @@ func NewBookHandler(bookRepo repository.BookRepositoryInterface, siteID string)
 
 // GetBooks handles GET /books
 // @Summary Get all books
-// @Description Retrieve all books in the catalog
+// @Description Retrieve all books in the catalog, or search it by title, author or ISBN. Searches ignore Vietnamese diacritics, match word prefixes and return the best matches first with their relevance score. Filters narrow the books by author, branch with an available copy, copy status or whether copies exist, and return facet counts merged from all sites.
 // @Tags Books
 // @Produce json
+// @Param query query string false "Search text, e.g. lap trinh"
+// @Param author query []string false "Author, repeatable; diacritics and case are ignored" collectionFormat(multi)
+// @Param branch query []string false "Branch (MaCN) holding an available copy, repeatable" collectionFormat(multi)
+// @Param status query []string false "Status (TinhTrang) of at least one copy, repeatable" collectionFormat(multi)
+// @Param hasCopies query bool false "Only books with (true) or without (false) copies"
+// @Param facets query bool false "Return facet counts even without filters"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
 // @Param page query int false "Page number (0-based, default 0)"
 // @Param size query int false "Page size (default 20)"
 // @Success 200 {object} models.ListResponse "List of books"
+// @Failure 400 {object} models.ErrorResponse "Invalid filter"
 // @Failure 500 {object} models.ErrorResponse "Failed to retrieve books"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
 // @Router /books [get]
 func (h *BookHandler) GetBooks(c *gin.Context) {
 	ctx := c.Request.Context()
 	pagination := utils.ParsePaginationParams(c)
 
+	filter, err := parseCatalogFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid filter",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	if withFacets, _ := strconv.ParseBool(c.Query("facets")); withFacets || !filter.Empty() {
+		ctx, tracker := trackSites(c)
+		books, total, facets, err := h.bookRepo.SearchCatalog(ctx, c.Query("query"), filter, &pagination)
+		if err != nil {
+			c.JSON(failureStatus(err), models.ErrorResponse{
+				Error:   "Failed to search books",
+				Details: err.Error(),
+			})
+			return
+		}
+
+		listResponse := utils.CreateListResponse(books, pagination, total)
+		listResponse.Facets = facets
+		listResponse.Metadata = tracker.Metadata()
+		c.JSON(http.StatusOK, listResponse)
+		return
+	}
+
+	if searchText := c.Query("query"); searchText != "" {
+		books, total, err := h.bookRepo.SearchBooks(ctx, searchText, &pagination)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Failed to search books",
+				Details: err.Error(),
+			})
+			return
+		}
+
+		listResponse := utils.CreateListResponse(books, pagination, total)
+		c.JSON(http.StatusOK, listResponse)
+		return
+	}
+
 	books, total, err := h.bookRepo.GetAllBooks(ctx, &pagination)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
@@ -50,6 +103,24 @@ func (h *BookHandler) GetBooks(c *gin.Context) {
 	c.JSON(http.StatusOK, listResponse)
 }
 
+// parseCatalogFilter reads the catalog filters of a request: repeated author, branch and
+// status parameters, and hasCopies
+func parseCatalogFilter(c *gin.Context) (models.CatalogFilter, error) {
+	filter := models.CatalogFilter{
+		Authors:  c.QueryArray("author"),
+		Branches: c.QueryArray("branch"),
+		Statuses: c.QueryArray("status"),
+	}
+	if value := c.Query("hasCopies"); value != "" {
+		hasCopies, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, fmt.Errorf("hasCopies must be true or false: %w", err)
+		}
+		filter.HasCopies = &hasCopies
+	}
+	return filter, nil
+}
+
 // GetBookCopies handles GET /book-copies
 // @Summary Get book copies
 // @Description Retrieve book copies for the current site
@@ -79,16 +150,22 @@ func (h *BookHandler) GetBookCopies(c *gin.Context) {
 
 // SearchBooks handles GET /books/search
 // @Summary Search books across all sites
-// @Description Search for books across all sites with availability info
+// @Description Search for available books across all sites with availability info, ranked by relevance. Diacritics are ignored and words match as prefixes.
 // @Tags Books
 // @Produce json
 // @Param query query string true "Search query"
-// @Success 200 {object} models.SuccessResponse "Search results"
-// @Failure 400 {object} models.ErrorResponse "Invalid query"
+// @Param author query []string false "Author, repeatable; diacritics and case are ignored" collectionFormat(multi)
+// @Param branch query []string false "Branch (MaCN) holding an available copy, repeatable" collectionFormat(multi)
+// @Param status query []string false "Status (TinhTrang) of at least one copy, repeatable" collectionFormat(multi)
+// @Param hasCopies query bool false "Only books with (true) or without (false) copies"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
+// @Success 200 {object} models.SuccessResponse "Search results with facet counts"
+// @Failure 400 {object} models.ErrorResponse "Invalid query or filter"
 // @Failure 500 {object} models.ErrorResponse "Search failed"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
 // @Router /books/search [get]
 func (h *BookHandler) SearchBooks(c *gin.Context) {
-	ctx := c.Request.Context()
+	ctx, tracker := trackSites(c)
 	query := c.Query("query")
 
 	if query == "" {
@@ -98,9 +175,18 @@ func (h *BookHandler) SearchBooks(c *gin.Context) {
 		return
 	}
 
-	results, err := h.bookRepo.SearchAvailableBooks(ctx, query)
+	filter, err := parseCatalogFilter(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid filter",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	results, facets, err := h.bookRepo.SearchAvailableBooks(ctx, query, filter)
+	if err != nil {
+		c.JSON(failureStatus(err), models.ErrorResponse{
 			Error:   "Search failed",
 			Details: err.Error(),
 		})
@@ -108,9 +194,11 @@ func (h *BookHandler) SearchBooks(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
-		Success: true,
-		Message: "Search completed successfully",
-		Data:    results,
+		Success:  true,
+		Message:  "Search completed successfully",
+		Data:     results,
+		Metadata: tracker.Metadata(),
+		Facets:   facets,
 	})
 }
 
@@ -183,7 +271,7 @@ func (h *BookHandler) GetAvailableBookCopy(c *gin.Context) {
 
 // CreateQuyenSach handles POST /book-copies
 // @Summary Create book copy
-// @Description Create a new book copy at the current site
+// @Description Create a new book copy at the current site. New copies are available (Có sẵn); tinhTrang may be left empty.
 // @Tags Book Copies
 // @Accept json
 // @Produce json
@@ -256,7 +344,7 @@ func (h *BookHandler) GetQuyenSach(c *gin.Context) {
 
 // UpdateQuyenSach handles PUT /book-copies/:maQuyenSach
 // @Summary Update book copy
-// @Description Update book copy information
+// @Description Change the status of a book copy of the current site. Only these moves may be made by hand: Có sẵn to Bị hỏng, Bị mất or Đã thanh lý; Bị hỏng to Đang sửa chữa or Đã thanh lý; Đang sửa chữa or Bị mất to Có sẵn or Đã thanh lý; Đang được mượn to Bị mất, which closes the loan. Loans, returns, holds and shipments make the other moves. A copy put back on the shelf is set aside for the first reader waiting for the title.
 // @Tags Book Copies
 // @Accept json
 // @Produce json
@@ -265,6 +353,7 @@ func (h *BookHandler) GetQuyenSach(c *gin.Context) {
 // @Success 200 {object} models.SuccessResponse "Book copy updated"
 // @Failure 400 {object} models.ErrorResponse "Invalid request"
 // @Failure 404 {object} models.ErrorResponse "Book copy not found"
+// @Failure 422 {object} models.ErrorResponse{details=models.TransitionError} "Status change not allowed"
 // @Failure 500 {object} models.ErrorResponse "Failed to update book copy"
 // @Router /book-copies/{maQuyenSach} [put]
 func (h *BookHandler) UpdateQuyenSach(c *gin.Context) {
@@ -285,6 +374,14 @@ func (h *BookHandler) UpdateQuyenSach(c *gin.Context) {
 
 	err := h.bookRepo.UpdateBookCopy(ctx, &quyenSach, userSite)
 	if err != nil {
+		var transition *models.TransitionError
+		if errors.As(err, &transition) {
+			c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+				Error:   "Status change not allowed",
+				Details: transition,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Failed to update book copy",
 			Details: err.Error(),
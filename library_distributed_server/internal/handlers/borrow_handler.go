@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
 	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
 	"library_distributed_server/internal/repository"
 	"library_distributed_server/pkg/utils"
 
@@ -25,13 +29,14 @@ func NewBorrowHandler(borrowRepo repository.BorrowRepositoryInterface, siteID st
 // CreateBorrow handles POST /borrow
 // Implements FR2 - Lập phiếu mượn sách (Librarian only, site-specific)
 // @Summary Create borrow transaction
-// @Description Create a new book borrowing transaction (Librarian only)
+// @Description Create a new book borrowing transaction (Librarian only). The copy must be at the librarian's branch; the reader may be registered at any branch. A copy of another branch shipped for the reader's hold is lent at the hold's pickup branch and recorded with the loans of its own branch. The loan length and the loan limit come from the circulation policy of the branch and the reader's category. The loan limit and the overdue block count the reader's loans at every branch, so the request fails when a branch cannot be reached.
 // @Tags Borrowing
 // @Accept json
 // @Produce json
 // @Param request body models.CreateBorrowRequest true "Borrow request"
 // @Success 201 {object} models.SuccessResponse "Borrow created successfully"
 // @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 422 {object} models.ErrorResponse{details=models.PolicyViolation} "Blocked by a circulation rule"
 // @Failure 500 {object} models.ErrorResponse "Failed to create borrow"
 // @Router /borrow [post]
 func (h *BorrowHandler) CreateBorrow(c *gin.Context) {
@@ -56,6 +61,9 @@ func (h *BorrowHandler) CreateBorrow(c *gin.Context) {
 
 	err := h.borrowRepo.CreateBorrow(ctx, borrow, userSite)
 	if err != nil {
+		if respondPolicyViolation(c, "Borrow not allowed by circulation policy", err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Failed to create borrow transaction",
 			Details: err.Error(),
@@ -71,15 +79,15 @@ func (h *BorrowHandler) CreateBorrow(c *gin.Context) {
 }
 
 // ReturnBook handles PUT /borrow/return/:id
-// Implements FR3 - Trả sách (Librarian only, site-specific)
+// Implements FR3 - Trả sách (Librarian only, at any branch)
 // @Summary Return borrowed book
-// @Description Process book return transaction (Librarian only)
+// @Description Process book return transaction (Librarian only). A late return is fined at the daily rate of the circulation policy of the branch and the reader's category, for each day past the due date and its grace days. When readers are waiting for the title, the copy is set aside for the first of them. A copy of another branch may be returned at any branch: its loan is closed at its home branch, and it is marked in transit and listed for shipping home until its branch checks it in at PUT /transfers/check-in/{id}. The fine, the hold and the transfer are returned in data when there are any.
 // @Tags Borrowing
 // @Accept json
 // @Produce json
 // @Param id path string true "Book copy ID"
 // @Param request body models.ReturnBookRequest true "Return request"
-// @Success 200 {object} models.SuccessResponse "Book returned successfully"
+// @Success 200 {object} models.SuccessResponse{data=models.ReturnBookResponse} "Book returned successfully"
 // @Failure 400 {object} models.ErrorResponse "Invalid request"
 // @Failure 500 {object} models.ErrorResponse "Failed to return book"
 // @Router /borrow/return/{id} [put]
@@ -88,7 +96,7 @@ func (h *BorrowHandler) ReturnBook(c *gin.Context) {
 	maQuyenSach := c.Param("id")
 	userSite := c.GetString("maCN")
 
-	err := h.borrowRepo.ReturnBook(ctx, maQuyenSach, userSite)
+	outcome, err := h.borrowRepo.ReturnBook(ctx, maQuyenSach, userSite)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "Failed to return book",
@@ -97,12 +105,199 @@ func (h *BorrowHandler) ReturnBook(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, models.SuccessResponse{
+	response := models.SuccessResponse{
 		Success: true,
 		Message: "Book returned successfully",
+	}
+	if fine := outcome.Fine; fine != nil {
+		response.Message += fmt.Sprintf(", %d days late: fine %s of %d VND", fine.SoNgayQuaHan, fine.MaPhat, fine.SoTien)
+	}
+	if hold := outcome.Hold; hold != nil {
+		response.Message += " and set aside for hold " + hold.MaDC
+	}
+	if transfer := outcome.Transfer; transfer != nil {
+		response.Message += " and to be shipped home to " + transfer.MaCN
+	}
+	if outcome.Hold != nil || outcome.Fine != nil || outcome.Transfer != nil {
+		response.Data = outcome
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateBorrows handles POST /borrow/batch
+// Implements FR2 for a stack of books (Librarian only, site-specific)
+// @Summary Lend several copies to a reader
+// @Description Lend several copies of the librarian's branch to one reader at once (Librarian only). Every copy is checked as for POST /borrow, and the loan limit of the circulation policy counts the reader's current loans plus every copy of the batch. The loans are made in one transaction: either every copy is lent or none is. A refused batch answers 422 with the outcome for each copy in details.receipt.
+// @Tags Borrowing
+// @Accept json
+// @Produce json
+// @Param request body models.BatchBorrowRequest true "Batch borrow request"
+// @Success 201 {object} models.SuccessResponse{data=models.BatchReceipt} "Every copy lent"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 422 {object} models.ErrorResponse{details=models.BatchRejection} "Batch refused, no copy lent"
+// @Failure 500 {object} models.ErrorResponse "Failed to create borrows"
+// @Router /borrow/batch [post]
+func (h *BorrowHandler) CreateBorrows(c *gin.Context) {
+	ctx := c.Request.Context()
+	userSite := c.GetString("maCN")
+
+	var req models.BatchBorrowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	receipt, err := h.borrowRepo.CreateBorrows(ctx, req.MaDG, req.MaQuyenSach, userSite)
+	if err != nil {
+		if respondBatchRejection(c, "Batch borrow refused, no copy was lent", err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to create borrows",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d copies lent to reader %s", len(receipt.Items), receipt.MaDG),
+		Data:    receipt,
 	})
 }
 
+// ReturnBooks handles PUT /borrow/return/batch
+// Implements FR3 for a stack of books (Librarian only, at any branch)
+// @Summary Return several copies
+// @Description Take back several copies at once (Librarian only). Every copy is returned as with PUT /borrow/return/{id}: late returns are fined, copies of the branch serve the holds on their titles, and copies of other branches are shipped home. Nothing is committed until every copy is processed, so a copy that cannot be returned leaves every loan open; a refused batch answers 422 with the outcome for each copy in details.receipt. The returns are then committed one database at a time and are best-effort, not all-or-nothing: if a commit fails after others went through, the returns already committed stand and 500 lists in details.receipt which copies were returned; the others are still on loan and can be returned again.
+// @Tags Borrowing
+// @Accept json
+// @Produce json
+// @Param request body models.BatchReturnRequest true "Batch return request"
+// @Success 200 {object} models.SuccessResponse{data=models.BatchReceipt} "Every copy returned"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 422 {object} models.ErrorResponse{details=models.BatchRejection} "Batch refused, no copy returned"
+// @Failure 500 {object} models.ErrorResponse{details=models.BatchIncomplete} "Failed to return books, or only some copies returned"
+// @Router /borrow/return/batch [put]
+func (h *BorrowHandler) ReturnBooks(c *gin.Context) {
+	ctx := c.Request.Context()
+	userSite := c.GetString("maCN")
+
+	var req models.BatchReturnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	receipt, err := h.borrowRepo.ReturnBooks(ctx, req.MaQuyenSach, userSite)
+	if err != nil {
+		if respondBatchRejection(c, "Batch return refused, no copy was returned", err) {
+			return
+		}
+		var incomplete *models.BatchIncomplete
+		if errors.As(err, &incomplete) {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "Batch return incomplete, only the copies marked successful were returned",
+				Details: incomplete,
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to return books",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	message := fmt.Sprintf("%d copies returned", len(receipt.Items))
+	if receipt.TotalFine > 0 {
+		message += fmt.Sprintf(", fines of %d VND", receipt.TotalFine)
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: message,
+		Data:    receipt,
+	})
+}
+
+// respondBatchRejection answers 422 with the outcome for each copy when a batch checkout or
+// return failed with err because of its copies or a circulation rule, and reports whether it did
+func respondBatchRejection(c *gin.Context, message string, err error) bool {
+	var rejection *models.BatchRejection
+	if !errors.As(err, &rejection) {
+		return false
+	}
+	c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+		Error:   message,
+		Details: rejection,
+	})
+	return true
+}
+
+// RenewBorrow handles PUT /borrow/:id/renew
+// @Summary Renew loan
+// @Description Push the due date of a loan made at the librarian's site back by one loan period, as many times as the circulation policy of the branch and the reader's category allows. An overdue loan is renewed from today. Titles other readers are waiting for at any branch cannot be renewed. (ThuThu only)
+// @Tags Borrowing
+// @Produce json
+// @Param id path int true "Borrow record ID"
+// @Success 200 {object} models.SuccessResponse{data=models.PhieuMuon} "Loan renewed"
+// @Failure 400 {object} models.ErrorResponse "Invalid borrow record ID"
+// @Failure 422 {object} models.ErrorResponse{details=models.PolicyViolation} "Blocked by a circulation rule"
+// @Failure 500 {object} models.ErrorResponse "Failed to renew loan"
+// @Router /borrow/{id}/renew [put]
+func (h *BorrowHandler) RenewBorrow(c *gin.Context) {
+	ctx := c.Request.Context()
+	userSite := c.GetString("maCN")
+
+	maPM, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid borrow record ID",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	borrow, err := h.borrowRepo.RenewBorrow(ctx, maPM, userSite)
+	if err != nil {
+		if respondPolicyViolation(c, "Renewal not allowed by circulation policy", err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to renew loan",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Loan renewed successfully",
+		Data:    borrow,
+	})
+}
+
+// respondPolicyViolation answers 422 with the blocking rule when a circulation policy refused
+// the action that failed with err, and reports whether it did
+func respondPolicyViolation(c *gin.Context, message string, err error) bool {
+	var violation *models.PolicyViolation
+	if !errors.As(err, &violation) {
+		return false
+	}
+	c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+		Error:   message,
+		Details: violation,
+	})
+	return true
+}
+
 // GetBorrowRecordsWithDetails handles GET /borrow/detailed
 // Enhanced for Flutter with comprehensive borrow information
 // @Summary Get detailed borrow records
@@ -217,24 +412,36 @@ func (h *BorrowHandler) GetOverdueBooks(c *gin.Context) {
 // @Param maDG path string true "Reader ID"
 // @Param page query int false "Page number (0-based, default 0)"
 // @Param size query int false "Page size (default 20)"
+// @Param cursor query string false "Cursor from the previous page's paging.nextCursor, instead of page"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
 // @Success 200 {object} models.ListResponse "Reader's borrow history"
+// @Failure 400 {object} models.ErrorResponse "Invalid cursor"
 // @Failure 500 {object} models.ErrorResponse "Failed to retrieve borrow history"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
 // @Router /borrow/history/{maDG} [get]
 func (h *BorrowHandler) GetBorrowHistory(c *gin.Context) {
-	ctx := c.Request.Context()
+	ctx, tracker := trackSites(c)
 	maDG := c.Param("maDG")
 	pagination := utils.ParsePaginationParams(c)
 
-	history, total, err := h.borrowRepo.GetBorrowHistory(ctx, maDG, &pagination)
+	page, err := h.borrowRepo.GetBorrowHistory(ctx, maDG, &pagination)
+	if errors.Is(err, query.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid cursor",
+			Details: err.Error(),
+		})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		c.JSON(failureStatus(err), models.ErrorResponse{
 			Error:   "Failed to retrieve borrow history",
 			Details: err.Error(),
 		})
 		return
 	}
 
-	listResponse := utils.CreateListResponse(history, pagination, total)
+	listResponse := utils.CreateCursorListResponse(page.Items, pagination, page.Total, page.NextCursor)
+	listResponse.Metadata = tracker.Metadata()
 	c.JSON(http.StatusOK, listResponse)
 }
 
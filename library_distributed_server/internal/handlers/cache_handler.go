@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+
+	"library_distributed_server/internal/cache"
+	"library_distributed_server/internal/config"
+	"library_distributed_server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CacheHandler struct {
+	cache     *cache.Cache
+	publisher *cache.Publisher
+	store     *config.Store
+	siteID    string
+}
+
+func NewCacheHandler(refCache *cache.Cache, publisher *cache.Publisher, store *config.Store, siteID string) *CacheHandler {
+	return &CacheHandler{
+		cache:     refCache,
+		publisher: publisher,
+		store:     store,
+		siteID:    siteID,
+	}
+}
+
+// Invalidate handles POST /cache/invalidate
+// Sent by the site or coordinator that committed a write to a replicated table
+// @Summary Apply a cache invalidation
+// @Description Drop the cached SACH or CHINHANH entries written at another site, or every entry when no relation is given
+// @Tags Cache
+// @Accept json
+// @Produce json
+// @Param request body cache.Invalidation true "Committed write"
+// @Success 200 {object} models.SuccessResponse "Invalidation applied"
+// @Failure 400 {object} models.ErrorResponse "Invalid invalidation"
+// @Failure 401 {object} models.ErrorResponse "Site secret required"
+// @Router /cache/invalidate [post]
+func (h *CacheHandler) Invalidate(c *gin.Context) {
+	var inv cache.Invalidation
+	if err := c.ShouldBindJSON(&inv); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid invalidation",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	h.cache.Apply(inv)
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Invalidation applied",
+	})
+}
+
+// GetStats handles GET /manager/cache
+// @Summary Get cache statistics
+// @Description Get the entries, hits, misses, hit rate and invalidations of the reference data cache at this site
+// @Tags Manager
+// @Produce json
+// @Success 200 {object} models.CacheStatsResponse "Cache statistics"
+// @Router /manager/cache [get]
+func (h *CacheHandler) GetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, models.CacheStatsResponse{
+		Site:      h.siteID,
+		TTL:       h.store.Current().Cache.TTL.String(),
+		Relations: h.cache.Stats(),
+	})
+}
+
+// Flush handles DELETE /manager/cache
+// @Summary Flush the reference data caches
+// @Description Drop every cached SACH and CHINHANH entry at this site and at every other branch site
+// @Tags Manager
+// @Produce json
+// @Success 200 {object} models.CacheFlushResponse "Sites flushed"
+// @Router /manager/cache [delete]
+func (h *CacheHandler) Flush(c *gin.Context) {
+	flushed, failed := h.publisher.Publish(cache.Invalidation{})
+
+	response := models.CacheFlushResponse{Flushed: flushed, Failed: []models.SiteFailure{}}
+	for _, siteID := range h.store.Current().SiteIDs() {
+		if err, exists := failed[siteID]; exists {
+			response.Failed = append(response.Failed, models.SiteFailure{SiteID: siteID, Error: err.Error()})
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"library_distributed_server/internal/config"
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trackSites returns the request context with a tracker recording which sites the
+// distributed reads of the request reached. With ?requireAll=true a read that cannot
+// reach every relevant site fails instead of returning partial results.
+func trackSites(c *gin.Context) (context.Context, *query.Tracker) {
+	requireAll, _ := strconv.ParseBool(c.Query("requireAll"))
+	return query.Track(c.Request.Context(), requireAll)
+}
+
+// failureStatus is the HTTP status for a failed distributed read
+func failureStatus(err error) int {
+	if errors.Is(err, query.ErrIncomplete) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// RequireSiteSecret admits only calls from the other sites and the coordinator, which
+// send the shared site secret in the X-Site-Secret header
+func RequireSiteSecret(store *config.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := store.Current().Auth.SiteSecret
+		sent := c.GetHeader(config.SiteSecretHeader)
+		if secret == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(secret)) != 1 {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error: "Site secret required",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FineHandler struct {
+	fineRepo repository.FineRepositoryInterface
+	siteID   string
+}
+
+func NewFineHandler(fineRepo repository.FineRepositoryInterface, siteID string) *FineHandler {
+	return &FineHandler{
+		fineRepo: fineRepo,
+		siteID:   siteID,
+	}
+}
+
+// GetFines handles GET /fines
+// @Summary Get fines of a branch
+// @Description List the fines assessed on late returns at a branch, most recent first. Librarians see their own site; managers may pass siteID.
+// @Tags Fines
+// @Produce json
+// @Param siteID query string false "Branch that made the loans (QuanLy only, default: this site)"
+// @Param unpaid query bool false "Only fines with an outstanding amount"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
+// @Success 200 {object} models.SuccessResponse{data=[]models.Phat} "Fines"
+// @Failure 500 {object} models.ErrorResponse "Failed to retrieve fines"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
+// @Router /fines [get]
+func (h *FineHandler) GetFines(c *gin.Context) {
+	ctx, tracker := trackSites(c)
+	userRole := c.GetString("role")
+	userSite := c.GetString("maCN")
+
+	siteID := h.siteID
+	if userRole == "THUTHU" {
+		siteID = userSite
+	} else if requested := strings.TrimSpace(c.Query("siteID")); requested != "" {
+		siteID = requested
+	}
+
+	fines, err := h.fineRepo.GetSiteFines(ctx, siteID, c.Query("unpaid") == "true")
+	if err != nil {
+		c.JSON(failureStatus(err), models.ErrorResponse{
+			Error:   "Failed to retrieve fines",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success:  true,
+		Message:  "Fines retrieved successfully",
+		Data:     fines,
+		Metadata: tracker.Metadata(),
+	})
+}
+
+// GetFine handles GET /fines/:maPhat
+// @Summary Get fine
+// @Description Get a fine with its payments and waivers, from whichever branch made the loan
+// @Tags Fines
+// @Produce json
+// @Param maPhat path string true "Fine ID"
+// @Success 200 {object} models.SuccessResponse{data=models.Phat} "Fine"
+// @Failure 404 {object} models.ErrorResponse "Fine not found"
+// @Router /fines/{maPhat} [get]
+func (h *FineHandler) GetFine(c *gin.Context) {
+	ctx := c.Request.Context()
+	maPhat := c.Param("maPhat")
+
+	fine, err := h.fineRepo.GetFine(ctx, maPhat)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Fine not found",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Fine retrieved successfully",
+		Data:    fine,
+	})
+}
+
+// RecordPayment handles POST /fines/:maPhat/payments
+// @Summary Record fine payment
+// @Description Record a payment of at most the outstanding amount against a fine of the librarian's site. The fine is settled once nothing is outstanding. (ThuThu only)
+// @Tags Fines
+// @Accept json
+// @Produce json
+// @Param maPhat path string true "Fine ID"
+// @Param request body models.FinePaymentRequest true "Payment"
+// @Success 200 {object} models.SuccessResponse{data=models.Phat} "Payment recorded"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 500 {object} models.ErrorResponse "Failed to record payment"
+// @Router /fines/{maPhat}/payments [post]
+func (h *FineHandler) RecordPayment(c *gin.Context) {
+	ctx := c.Request.Context()
+	maPhat := c.Param("maPhat")
+	userSite := c.GetString("maCN")
+
+	var req models.FinePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	fine, err := h.fineRepo.RecordPayment(ctx, maPhat, req.SoTien, req.GhiChu, currentUsername(c), userSite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to record payment",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Payment recorded successfully",
+		Data:    fine,
+	})
+}
+
+// WaiveFine handles POST /fines/:maPhat/waivers
+// @Summary Waive fine
+// @Description Waive part of a fine of the librarian's site, or all of its outstanding amount when soTien is 0. A reason is required. (ThuThu only)
+// @Tags Fines
+// @Accept json
+// @Produce json
+// @Param maPhat path string true "Fine ID"
+// @Param request body models.FineWaiverRequest true "Waiver"
+// @Success 200 {object} models.SuccessResponse{data=models.Phat} "Waiver recorded"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 500 {object} models.ErrorResponse "Failed to waive fine"
+// @Router /fines/{maPhat}/waivers [post]
+func (h *FineHandler) WaiveFine(c *gin.Context) {
+	ctx := c.Request.Context()
+	maPhat := c.Param("maPhat")
+	userSite := c.GetString("maCN")
+
+	var req models.FineWaiverRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	fine, err := h.fineRepo.WaiveFine(ctx, maPhat, req.SoTien, req.GhiChu, currentUsername(c), userSite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to waive fine",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Waiver recorded successfully",
+		Data:    fine,
+	})
+}
+
+// GetReaderFines handles GET /readers/:maDG/fines
+// @Summary Get fines of a reader
+// @Description List the fines of a reader at every branch, most recent first
+// @Tags Fines
+// @Produce json
+// @Param maDG path string true "Reader ID"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
+// @Success 200 {object} models.SuccessResponse{data=[]models.Phat} "Fines of the reader"
+// @Failure 500 {object} models.ErrorResponse "Failed to retrieve fines"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
+// @Router /readers/{maDG}/fines [get]
+func (h *FineHandler) GetReaderFines(c *gin.Context) {
+	ctx, tracker := trackSites(c)
+	maDG := c.Param("maDG")
+
+	fines, err := h.fineRepo.GetFinesByReader(ctx, maDG)
+	if err != nil {
+		c.JSON(failureStatus(err), models.ErrorResponse{
+			Error:   "Failed to retrieve fines",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success:  true,
+		Message:  "Reader fines retrieved successfully",
+		Data:     fines,
+		Metadata: tracker.Metadata(),
+	})
+}
+
+// GetFineReport handles GET /fines/report
+// @Summary Outstanding fines report
+// @Description Outstanding fines and the fines accruing on overdue loans, per branch and across the system. Readers owing at several branches are counted once in the total. (QuanLy only)
+// @Tags Fines
+// @Produce json
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
+// @Success 200 {object} models.SuccessResponse{data=models.FineReport} "Fine report"
+// @Failure 500 {object} models.ErrorResponse "Failed to build fine report"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
+// @Router /fines/report [get]
+func (h *FineHandler) GetFineReport(c *gin.Context) {
+	ctx, tracker := trackSites(c)
+
+	report, err := h.fineRepo.GetFineReport(ctx)
+	if err != nil {
+		c.JSON(failureStatus(err), models.ErrorResponse{
+			Error:   "Failed to build fine report",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success:  true,
+		Message:  "Fine report generated successfully",
+		Data:     report,
+		Metadata: tracker.Metadata(),
+	})
+}
+
+// currentUsername returns the username of the authenticated user
+func currentUsername(c *gin.Context) string {
+	if claims, ok := GetClaims(c); ok {
+		return claims.Username
+	}
+	return ""
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HoldHandler struct {
+	holdRepo repository.HoldRepositoryInterface
+	siteID   string
+}
+
+func NewHoldHandler(holdRepo repository.HoldRepositoryInterface, siteID string) *HoldHandler {
+	return &HoldHandler{
+		holdRepo: holdRepo,
+		siteID:   siteID,
+	}
+}
+
+// PlaceHold handles POST /holds
+// @Summary Place hold
+// @Description Queue a reader registered at the librarian's site for a title, optionally naming a pickup branch. Holds on a title are served in order across all branches: the first copy freed at any branch is set aside for the oldest hold until its pickup deadline. A copy of another branch than the pickup branch is shipped there first, and the hold is ready once the pickup branch checks it in at PUT /transfers/check-in/{id}. A copy already free is set aside at once. (ThuThu only)
+// @Tags Holds
+// @Accept json
+// @Produce json
+// @Param request body models.PlaceHoldRequest true "Hold request"
+// @Success 201 {object} models.SuccessResponse{data=models.DatCho} "Hold placed"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 500 {object} models.ErrorResponse "Failed to place hold"
+// @Router /holds [post]
+func (h *HoldHandler) PlaceHold(c *gin.Context) {
+	ctx := c.Request.Context()
+	userSite := c.GetString("maCN")
+
+	var req models.PlaceHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	hold := &models.DatCho{
+		MaDG:         req.MaDG,
+		ISBN:         req.ISBN,
+		MaCN:         userSite,
+		MaCNNhanSach: req.MaCNNhanSach,
+	}
+
+	if err := h.holdRepo.PlaceHold(ctx, hold, userSite); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to place hold",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Message: "Hold placed successfully",
+		Data:    hold,
+	})
+}
+
+// GetHold handles GET /holds/:maDC
+// @Summary Get hold
+// @Description Get a hold by ID, from whichever branch it was placed at
+// @Tags Holds
+// @Produce json
+// @Param maDC path string true "Hold ID"
+// @Success 200 {object} models.SuccessResponse{data=models.DatCho} "Hold"
+// @Failure 404 {object} models.ErrorResponse "Hold not found"
+// @Router /holds/{maDC} [get]
+func (h *HoldHandler) GetHold(c *gin.Context) {
+	ctx := c.Request.Context()
+	maDC := c.Param("maDC")
+
+	hold, err := h.holdRepo.GetHold(ctx, maDC)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Hold not found",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Hold retrieved successfully",
+		Data:    hold,
+	})
+}
+
+// CancelHold handles DELETE /holds/:maDC
+// @Summary Cancel hold
+// @Description Cancel an active hold placed at the librarian's site. A copy already set aside for it passes to the next reader in the queue. (ThuThu only)
+// @Tags Holds
+// @Produce json
+// @Param maDC path string true "Hold ID"
+// @Success 200 {object} models.SuccessResponse "Hold cancelled"
+// @Failure 500 {object} models.ErrorResponse "Failed to cancel hold"
+// @Router /holds/{maDC} [delete]
+func (h *HoldHandler) CancelHold(c *gin.Context) {
+	ctx := c.Request.Context()
+	maDC := c.Param("maDC")
+	userSite := c.GetString("maCN")
+
+	if err := h.holdRepo.CancelHold(ctx, maDC, userSite); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to cancel hold",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Hold cancelled successfully",
+	})
+}
+
+// GetHoldShelf handles GET /holds/shelf
+// @Summary Get hold shelf
+// @Description List the ready holds whose copy waits for pickup at a branch, earliest pickup deadline first. Librarians see their own site; managers may pass siteID.
+// @Tags Holds
+// @Produce json
+// @Param siteID query string false "Branch storing the copies (QuanLy only, default: this site)"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
+// @Success 200 {object} models.SuccessResponse{data=[]models.DatCho} "Ready holds"
+// @Failure 500 {object} models.ErrorResponse "Failed to retrieve holds"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
+// @Router /holds/shelf [get]
+func (h *HoldHandler) GetHoldShelf(c *gin.Context) {
+	ctx, tracker := trackSites(c)
+	userRole := c.GetString("role")
+	userSite := c.GetString("maCN")
+
+	siteID := h.siteID
+	if userRole == "THUTHU" {
+		siteID = userSite
+	} else if requested := strings.TrimSpace(c.Query("siteID")); requested != "" {
+		siteID = requested
+	}
+
+	holds, err := h.holdRepo.GetHoldShelf(ctx, siteID)
+	if err != nil {
+		c.JSON(failureStatus(err), models.ErrorResponse{
+			Error:   "Failed to retrieve holds",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success:  true,
+		Message:  "Hold shelf retrieved successfully",
+		Data:     holds,
+		Metadata: tracker.Metadata(),
+	})
+}
+
+// GetHoldQueue handles GET /books/:isbn/holds
+// @Summary Get hold queue of a title
+// @Description List the active holds on a title at every branch in queue order; waiting holds carry their position (viTri)
+// @Tags Holds
+// @Produce json
+// @Param isbn path string true "Book ISBN"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
+// @Success 200 {object} models.SuccessResponse{data=[]models.DatCho} "Hold queue"
+// @Failure 500 {object} models.ErrorResponse "Failed to retrieve hold queue"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
+// @Router /books/{isbn}/holds [get]
+func (h *HoldHandler) GetHoldQueue(c *gin.Context) {
+	ctx, tracker := trackSites(c)
+	isbn := c.Param("isbn")
+
+	holds, err := h.holdRepo.GetHoldQueue(ctx, isbn)
+	if err != nil {
+		c.JSON(failureStatus(err), models.ErrorResponse{
+			Error:   "Failed to retrieve hold queue",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success:  true,
+		Message:  "Hold queue retrieved successfully",
+		Data:     holds,
+		Metadata: tracker.Metadata(),
+	})
+}
+
+// GetReaderHolds handles GET /readers/:maDG/holds
+// @Summary Get holds of a reader
+// @Description List the holds of a reader, most recent first
+// @Tags Holds
+// @Produce json
+// @Param maDG path string true "Reader ID"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
+// @Success 200 {object} models.SuccessResponse{data=[]models.DatCho} "Holds of the reader"
+// @Failure 500 {object} models.ErrorResponse "Failed to retrieve holds"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
+// @Router /readers/{maDG}/holds [get]
+func (h *HoldHandler) GetReaderHolds(c *gin.Context) {
+	ctx, tracker := trackSites(c)
+	maDG := c.Param("maDG")
+
+	holds, err := h.holdRepo.GetHoldsByReader(ctx, maDG)
+	if err != nil {
+		c.JSON(failureStatus(err), models.ErrorResponse{
+			Error:   "Failed to retrieve holds",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success:  true,
+		Message:  "Reader holds retrieved successfully",
+		Data:     holds,
+		Metadata: tracker.Metadata(),
+	})
+}
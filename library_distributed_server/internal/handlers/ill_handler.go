@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ILLHandler struct {
+	illRepo repository.ILLRepositoryInterface
+	siteID  string
+}
+
+func NewILLHandler(illRepo repository.ILLRepositoryInterface, siteID string) *ILLHandler {
+	return &ILLHandler{
+		illRepo: illRepo,
+		siteID:  siteID,
+	}
+}
+
+// RequestCopy handles POST /ill
+// @Summary Request inter-library loan
+// @Description Ask another branch for a copy of a title that is not free at the librarian's site, on behalf of a reader of any branch. Without maCNSoHuu, a branch with a free copy is chosen. A reader may have one open request per title. (ThuThu only)
+// @Tags Inter-library loans
+// @Accept json
+// @Produce json
+// @Param request body models.ILLRequest true "Inter-library loan request"
+// @Success 201 {object} models.SuccessResponse{data=models.YeuCauMuon} "Request placed"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 500 {object} models.ErrorResponse "Failed to request copy"
+// @Router /ill [post]
+func (h *ILLHandler) RequestCopy(c *gin.Context) {
+	ctx := c.Request.Context()
+	userSite := c.GetString("maCN")
+
+	var req models.ILLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	request := &models.YeuCauMuon{
+		MaDG:      req.MaDG,
+		ISBN:      req.ISBN,
+		MaCN:      userSite,
+		MaCNSoHuu: strings.TrimSpace(req.MaCNSoHuu),
+	}
+
+	if err := h.illRepo.RequestCopy(ctx, request, userSite); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to request copy",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Message: "Inter-library loan requested successfully",
+		Data:    request,
+	})
+}
+
+// GetRequests handles GET /ill
+// @Summary Get inter-library loan requests
+// @Description List the requests a branch placed or was asked to ship, most recent first. Librarians see their own site; managers may pass siteID.
+// @Tags Inter-library loans
+// @Produce json
+// @Param siteID query string false "Requesting or owning branch (QuanLy only, default: this site)"
+// @Param trangThai query string false "Request state" Enums(Đã yêu cầu, Đang chuyển đến, Đã đến, Đang cho mượn, Đang trả về, Hoàn tất, Đã hủy)
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
+// @Success 200 {object} models.SuccessResponse{data=[]models.YeuCauMuon} "Requests"
+// @Failure 500 {object} models.ErrorResponse "Failed to retrieve requests"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
+// @Router /ill [get]
+func (h *ILLHandler) GetRequests(c *gin.Context) {
+	ctx, tracker := trackSites(c)
+	userRole := c.GetString("role")
+	userSite := c.GetString("maCN")
+
+	siteID := h.siteID
+	if userRole == "THUTHU" {
+		siteID = userSite
+	} else if requested := strings.TrimSpace(c.Query("siteID")); requested != "" {
+		siteID = requested
+	}
+
+	requests, err := h.illRepo.GetRequests(ctx, siteID, strings.TrimSpace(c.Query("trangThai")))
+	if err != nil {
+		c.JSON(failureStatus(err), models.ErrorResponse{
+			Error:   "Failed to retrieve requests",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success:  true,
+		Message:  "Inter-library loan requests retrieved successfully",
+		Data:     requests,
+		Metadata: tracker.Metadata(),
+	})
+}
+
+// GetRequest handles GET /ill/:maYC
+// @Summary Get inter-library loan request
+// @Description Get a request by ID, from whichever branch placed it
+// @Tags Inter-library loans
+// @Produce json
+// @Param maYC path string true "Request ID"
+// @Success 200 {object} models.SuccessResponse{data=models.YeuCauMuon} "Request"
+// @Failure 404 {object} models.ErrorResponse "Request not found"
+// @Router /ill/{maYC} [get]
+func (h *ILLHandler) GetRequest(c *gin.Context) {
+	ctx := c.Request.Context()
+	maYC := c.Param("maYC")
+
+	request, err := h.illRepo.GetRequest(ctx, maYC)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Request not found",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Inter-library loan request retrieved successfully",
+		Data:    request,
+	})
+}
+
+// ShipCopy handles POST /ill/:maYC/ship
+// @Summary Ship copy
+// @Description Send a free copy of the requested title to the requesting branch; the copy is in transit until it arrives. Without maQuyenSach, a free copy is chosen. (ThuThu of the owning branch only)
+// @Tags Inter-library loans
+// @Accept json
+// @Produce json
+// @Param maYC path string true "Request ID"
+// @Param request body models.ILLShipRequest false "Copy to ship"
+// @Success 200 {object} models.SuccessResponse{data=models.YeuCauMuon} "Copy shipped"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 500 {object} models.ErrorResponse "Failed to ship copy"
+// @Router /ill/{maYC}/ship [post]
+func (h *ILLHandler) ShipCopy(c *gin.Context) {
+	ctx := c.Request.Context()
+	maYC := c.Param("maYC")
+	userSite := c.GetString("maCN")
+
+	var req models.ILLShipRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid request format",
+				Details: err.Error(),
+			})
+			return
+		}
+	}
+
+	request, err := h.illRepo.ShipCopy(ctx, maYC, strings.TrimSpace(req.MaQuyenSach), userSite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to ship copy",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Copy " + request.MaQuyenSach + " shipped to " + request.MaCN,
+		Data:    request,
+	})
+}
+
+// ReceiveCopy handles POST /ill/:maYC/arrive
+// @Summary Receive shipped copy
+// @Description Record the arrival of the shipped copy at the requesting branch, where it waits for the reader. (ThuThu of the requesting branch only)
+// @Tags Inter-library loans
+// @Produce json
+// @Param maYC path string true "Request ID"
+// @Success 200 {object} models.SuccessResponse{data=models.YeuCauMuon} "Copy arrived"
+// @Failure 500 {object} models.ErrorResponse "Failed to receive copy"
+// @Router /ill/{maYC}/arrive [post]
+func (h *ILLHandler) ReceiveCopy(c *gin.Context) {
+	ctx := c.Request.Context()
+	maYC := c.Param("maYC")
+	userSite := c.GetString("maCN")
+
+	request, err := h.illRepo.ReceiveCopy(ctx, maYC, userSite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to receive copy",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Copy " + request.MaQuyenSach + " arrived from " + request.MaCNSoHuu,
+		Data:    request,
+	})
+}
+
+// LendCopy handles POST /ill/:maYC/lend
+// @Summary Lend arrived copy
+// @Description Lend the arrived copy to the reader it was requested for. The loan is stored at the requesting branch and made under its circulation policy, while the copy stays in its owner's fragment. A rule of the policy blocking the loan is returned with status 422 and the violated rule in details. (ThuThu of the requesting branch only)
+// @Tags Inter-library loans
+// @Produce json
+// @Param maYC path string true "Request ID"
+// @Success 200 {object} models.SuccessResponse{data=models.ILLResponse} "Copy lent"
+// @Failure 422 {object} models.ErrorResponse{details=models.PolicyViolation} "Loan not allowed by circulation policy"
+// @Failure 500 {object} models.ErrorResponse "Failed to lend copy"
+// @Router /ill/{maYC}/lend [post]
+func (h *ILLHandler) LendCopy(c *gin.Context) {
+	ctx := c.Request.Context()
+	maYC := c.Param("maYC")
+	userSite := c.GetString("maCN")
+
+	request, borrow, err := h.illRepo.LendCopy(ctx, maYC, userSite)
+	if err != nil {
+		if respondPolicyViolation(c, "Borrow not allowed by circulation policy", err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to lend copy",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Copy " + request.MaQuyenSach + " lent to reader " + request.MaDG,
+		Data:    models.ILLResponse{Request: request, Borrow: borrow},
+	})
+}
+
+// ReturnCopy handles POST /ill/:maYC/return
+// @Summary Return copy to its owner
+// @Description Take the copy back from the reader, closing the loan and fining a late return as for any loan of the branch, and ship it back to the owning branch. A copy the reader never picked up is shipped back as it is. (ThuThu of the requesting branch only)
+// @Tags Inter-library loans
+// @Produce json
+// @Param maYC path string true "Request ID"
+// @Success 200 {object} models.SuccessResponse{data=models.ILLResponse} "Copy shipped back"
+// @Failure 500 {object} models.ErrorResponse "Failed to return copy"
+// @Router /ill/{maYC}/return [post]
+func (h *ILLHandler) ReturnCopy(c *gin.Context) {
+	ctx := c.Request.Context()
+	maYC := c.Param("maYC")
+	userSite := c.GetString("maCN")
+
+	request, fine, err := h.illRepo.ReturnCopy(ctx, maYC, userSite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to return copy",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Copy " + request.MaQuyenSach + " shipped back to " + request.MaCNSoHuu,
+		Data:    models.ILLResponse{Request: request, Fine: fine},
+	})
+}
+
+// ReceiveReturn handles POST /ill/:maYC/receive-return
+// @Summary Receive copy shipped back
+// @Description Put the copy shipped back on the owning branch's shelf and complete the request. When readers are waiting for the title, the copy is set aside for the first of them. (ThuThu of the owning branch only)
+// @Tags Inter-library loans
+// @Produce json
+// @Param maYC path string true "Request ID"
+// @Success 200 {object} models.SuccessResponse{data=models.ILLResponse} "Request completed"
+// @Failure 500 {object} models.ErrorResponse "Failed to receive copy"
+// @Router /ill/{maYC}/receive-return [post]
+func (h *ILLHandler) ReceiveReturn(c *gin.Context) {
+	ctx := c.Request.Context()
+	maYC := c.Param("maYC")
+	userSite := c.GetString("maCN")
+
+	request, hold, err := h.illRepo.ReceiveReturn(ctx, maYC, userSite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to receive copy",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	message := "Copy " + request.MaQuyenSach + " back on the shelf"
+	if hold != nil {
+		message += " and set aside for hold " + hold.MaDC
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: message,
+		Data:    models.ILLResponse{Request: request, Hold: hold},
+	})
+}
+
+// CancelRequest handles DELETE /ill/:maYC
+// @Summary Cancel inter-library loan request
+// @Description Withdraw a request no copy was shipped for yet. Either the requesting or the owning branch may cancel. (ThuThu only)
+// @Tags Inter-library loans
+// @Produce json
+// @Param maYC path string true "Request ID"
+// @Success 200 {object} models.SuccessResponse{data=models.YeuCauMuon} "Request cancelled"
+// @Failure 500 {object} models.ErrorResponse "Failed to cancel request"
+// @Router /ill/{maYC} [delete]
+func (h *ILLHandler) CancelRequest(c *gin.Context) {
+	ctx := c.Request.Context()
+	maYC := c.Param("maYC")
+	userSite := c.GetString("maCN")
+
+	request, err := h.illRepo.CancelRequest(ctx, maYC, userSite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to cancel request",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Inter-library loan request cancelled successfully",
+		Data:    request,
+	})
+}
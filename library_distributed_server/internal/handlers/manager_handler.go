@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
+	"library_distributed_server/internal/catalog"
+	"library_distributed_server/internal/config"
 	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
 	"library_distributed_server/internal/repository"
 	"library_distributed_server/pkg/utils"
 
@@ -14,17 +19,20 @@ type ManagerHandler struct {
 	bookRepo   repository.BookRepositoryInterface
 	borrowRepo repository.BorrowRepositoryInterface
 	readerRepo repository.ReaderRepositoryInterface
+	store      *config.Store
 }
 
 func NewManagerHandler(
 	bookRepo repository.BookRepositoryInterface,
 	borrowRepo repository.BorrowRepositoryInterface,
 	readerRepo repository.ReaderRepositoryInterface,
+	store *config.Store,
 ) *ManagerHandler {
 	return &ManagerHandler{
 		bookRepo:   bookRepo,
 		borrowRepo: borrowRepo,
 		readerRepo: readerRepo,
+		store:      store,
 	}
 }
 
@@ -101,28 +109,51 @@ func (h *ManagerHandler) GetSach(c *gin.Context) {
 // SearchAvailableBooks handles GET /manager/books/search
 // Implements FR7 - Distributed book search
 // @Summary Search books across all sites
-// @Description Search for books across all sites with availability information (Manager only)
+// @Description Search for books across all sites with availability information, ranked by relevance. Diacritics are ignored and words match as prefixes. (Manager only)
 // @Tags Manager
 // @Produce json
 // @Param query query string true "Search query"
-// @Success 200 {object} models.SuccessResponse "Search results with availability"
-// @Failure 400 {object} models.ErrorResponse "Invalid query"
+// @Param author query []string false "Author, repeatable; diacritics and case are ignored" collectionFormat(multi)
+// @Param branch query []string false "Branch (MaCN) holding an available copy, repeatable" collectionFormat(multi)
+// @Param status query []string false "Status (TinhTrang) of at least one copy, repeatable" collectionFormat(multi)
+// @Param hasCopies query bool false "Only books with (true) or without (false) copies"
+// @Param explain query bool false "Return the distributed query plan instead of executing"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
+// @Success 200 {object} models.SuccessResponse "Search results with availability and facet counts"
+// @Failure 400 {object} models.ErrorResponse "Invalid query or filter"
 // @Failure 500 {object} models.ErrorResponse "Search failed"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
 // @Router /manager/books/search [get]
 func (h *ManagerHandler) SearchAvailableBooks(c *gin.Context) {
-	ctx := c.Request.Context()
-	query := c.Query("query")
+	ctx, tracker := trackSites(c)
+	searchText := c.Query("query")
 
-	if query == "" {
+	if searchText == "" {
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error: "Search query is required",
 		})
 		return
 	}
 
-	results, err := h.bookRepo.SearchAvailableBooks(ctx, query)
+	if explainRequested(c) {
+		respondExplanation(c, func() (*query.Explanation, error) {
+			return h.bookRepo.ExplainSearchAvailableBooks(ctx, searchText)
+		})
+		return
+	}
+
+	filter, err := parseCatalogFilter(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid filter",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	results, facets, err := h.bookRepo.SearchAvailableBooks(ctx, searchText, filter)
+	if err != nil {
+		c.JSON(failureStatus(err), models.ErrorResponse{
 			Error:   "Failed to search books across sites",
 			Details: err.Error(),
 		})
@@ -130,9 +161,11 @@ func (h *ManagerHandler) SearchAvailableBooks(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
-		Success: true,
-		Message: "Book search completed successfully",
-		Data:    results,
+		Success:  true,
+		Message:  "Book search completed successfully",
+		Data:     results,
+		Metadata: tracker.Metadata(),
+		Facets:   facets,
 	})
 }
 
@@ -142,16 +175,26 @@ func (h *ManagerHandler) SearchAvailableBooks(c *gin.Context) {
 // @Description Get comprehensive statistics across all sites (Manager only)
 // @Tags Manager
 // @Produce json
+// @Param explain query bool false "Return the distributed query plan instead of executing"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
 // @Success 200 {object} models.SuccessResponse "System statistics"
 // @Failure 500 {object} models.ErrorResponse "Failed to retrieve statistics"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
 // @Router /manager/statistics [get]
 func (h *ManagerHandler) GetSystemStats(c *gin.Context) {
-	ctx := c.Request.Context()
+	ctx, tracker := trackSites(c)
+
+	if explainRequested(c) {
+		respondExplanation(c, func() (*query.Explanation, error) {
+			return h.bookRepo.ExplainBooksWithAvailability(ctx, "")
+		})
+		return
+	}
 
 	// Get system-wide book statistics
 	bookStats, err := h.bookRepo.GetBooksWithAvailability(ctx, "")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		c.JSON(failureStatus(err), models.ErrorResponse{
 			Error:   "Failed to retrieve book statistics",
 			Details: err.Error(),
 		})
@@ -162,14 +205,15 @@ func (h *ManagerHandler) GetSystemStats(c *gin.Context) {
 	stats := map[string]interface{}{
 		"books":    bookStats,
 		"message":  "System statistics retrieved successfully",
-		"sites":    []string{"Q1", "Q3"},
+		"sites":    h.store.Current().SiteIDs(),
 		"protocol": "Distributed Raw SQL Queries",
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
-		Success: true,
-		Message: "System statistics retrieved successfully",
-		Data:    stats,
+		Success:  true,
+		Message:  "System statistics retrieved successfully",
+		Data:     stats,
+		Metadata: tracker.Metadata(),
 	})
 }
 
@@ -181,23 +225,43 @@ func (h *ManagerHandler) GetSystemStats(c *gin.Context) {
 // @Produce json
 // @Param page query int false "Page number (0-based, default 0)"
 // @Param size query int false "Page size (default 20)"
+// @Param cursor query string false "Cursor from the previous page's paging.nextCursor, instead of page"
+// @Param explain query bool false "Return the distributed query plan instead of executing"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
 // @Success 200 {object} models.ListResponse "List of all readers"
+// @Failure 400 {object} models.ErrorResponse "Invalid cursor"
 // @Failure 500 {object} models.ErrorResponse "Failed to retrieve readers"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
 // @Router /manager/readers [get]
 func (h *ManagerHandler) GetAllReaders(c *gin.Context) {
-	ctx := c.Request.Context()
+	ctx, tracker := trackSites(c)
 	pagination := utils.ParsePaginationParams(c)
 
-	readers, total, err := h.readerRepo.GetAllReaders(ctx, &pagination)
+	if explainRequested(c) {
+		respondExplanation(c, func() (*query.Explanation, error) {
+			return h.readerRepo.ExplainGetAllReaders(ctx, &pagination)
+		})
+		return
+	}
+
+	page, err := h.readerRepo.GetAllReaders(ctx, &pagination)
+	if errors.Is(err, query.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid cursor",
+			Details: err.Error(),
+		})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		c.JSON(failureStatus(err), models.ErrorResponse{
 			Error:   "Failed to retrieve readers from all sites",
 			Details: err.Error(),
 		})
 		return
 	}
 
-	listResponse := utils.CreateListResponse(readers, pagination, total)
+	listResponse := utils.CreateCursorListResponse(page.Items, pagination, page.Total, page.NextCursor)
+	listResponse.Metadata = tracker.Metadata()
 	c.JSON(http.StatusOK, listResponse)
 }
 
@@ -321,3 +385,50 @@ func (h *ManagerHandler) TransferBookCopy(c *gin.Context) {
 		Data:    response,
 	})
 }
+
+// GetFragmentCatalog handles GET /manager/catalog/fragments
+// Exposes the distributed data dictionary
+// @Summary Get fragmentation catalog
+// @Description Get every global relation with its fragmentation type, fragment predicates and site allocation (Manager only)
+// @Tags Manager
+// @Produce json
+// @Success 200 {object} models.FragmentCatalogResponse "Fragmentation catalog"
+// @Router /manager/catalog/fragments [get]
+func (h *ManagerHandler) GetFragmentCatalog(c *gin.Context) {
+	fragments := catalog.FromConfig(h.store.Current())
+	c.JSON(http.StatusOK, models.FragmentCatalogResponse{
+		Sites:     fragments.Sites(),
+		Relations: fragments.Relations(),
+	})
+}
+
+// explainRequested reports whether the request asks for the query plan (?explain=true)
+func explainRequested(c *gin.Context) bool {
+	explain, _ := strconv.ParseBool(c.Query("explain"))
+	return explain
+}
+
+// respondExplanation returns the plan produced by explain instead of the operation's result
+func respondExplanation(c *gin.Context, explain func() (*query.Explanation, error)) {
+	explanation, err := explain()
+	if errors.Is(err, query.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid cursor",
+			Details: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to explain query",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Query plan (not executed)",
+		Data:    explanation,
+	})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"library_distributed_server/internal/membership"
+	"library_distributed_server/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MembershipHandler struct {
+	membership *membership.Membership
+}
+
+func NewMembershipHandler(membership *membership.Membership) *MembershipHandler {
+	return &MembershipHandler{
+		membership: membership,
+	}
+}
+
+// Heartbeat handles POST /membership/heartbeat
+// Exchanged between site services and the coordinator for failure detection
+// @Summary Exchange a membership heartbeat
+// @Description Record a heartbeat from another site and answer with this site's heartbeat, including whether its own database is reachable
+// @Tags Membership
+// @Accept json
+// @Produce json
+// @Param request body membership.Heartbeat true "Sender heartbeat"
+// @Success 200 {object} membership.Heartbeat "Receiver heartbeat"
+// @Failure 400 {object} models.ErrorResponse "Invalid heartbeat"
+// @Failure 401 {object} models.ErrorResponse "Site secret required"
+// @Router /membership/heartbeat [post]
+func (h *MembershipHandler) Heartbeat(c *gin.Context) {
+	var hb membership.Heartbeat
+	if err := c.ShouldBindJSON(&hb); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid heartbeat",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.membership.Receive(hb))
+}
+
+// GetMembership handles GET /membership
+// @Summary Get membership view
+// @Description Get the state (UP, SUSPECT or DOWN) of every other site as seen from this site, with the history of state changes
+// @Tags Membership
+// @Produce json
+// @Success 200 {object} membership.View "Membership view"
+// @Router /membership [get]
+func (h *MembershipHandler) GetMembership(c *gin.Context) {
+	c.JSON(http.StatusOK, h.membership.View())
+}
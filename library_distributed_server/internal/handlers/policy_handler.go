@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PolicyHandler struct {
+	policyRepo repository.PolicyRepositoryInterface
+	siteID     string
+}
+
+func NewPolicyHandler(policyRepo repository.PolicyRepositoryInterface, siteID string) *PolicyHandler {
+	return &PolicyHandler{
+		policyRepo: policyRepo,
+		siteID:     siteID,
+	}
+}
+
+// GetPolicies handles GET /policies
+// @Summary Get circulation policies
+// @Description List the circulation policies, general policies first. Where no policy matches a loan, the configured defaults apply.
+// @Tags Policies
+// @Produce json
+// @Success 200 {object} models.SuccessResponse{data=[]models.ChinhSach} "Circulation policies"
+// @Failure 500 {object} models.ErrorResponse "Failed to retrieve policies"
+// @Router /policies [get]
+func (h *PolicyHandler) GetPolicies(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	policies, err := h.policyRepo.GetPolicies(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to retrieve policies",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Policies retrieved successfully",
+		Data:    policies,
+	})
+}
+
+// GetEffectivePolicy handles GET /policies/effective
+// @Summary Get applicable circulation policy
+// @Description Get the policy applying to loans of a branch to a reader category: the policy of both, else of the branch, else of the category, else of every branch and category, else the configured defaults (maCS "default")
+// @Tags Policies
+// @Produce json
+// @Param maCN query string false "Lending branch (default: the librarian's branch, or this site)"
+// @Param loaiDG query string false "Reader category (default: Thường)"
+// @Success 200 {object} models.SuccessResponse{data=models.ChinhSach} "Applicable policy"
+// @Failure 500 {object} models.ErrorResponse "Failed to resolve policy"
+// @Router /policies/effective [get]
+func (h *PolicyHandler) GetEffectivePolicy(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	maCN := strings.TrimSpace(c.Query("maCN"))
+	if maCN == "" {
+		maCN = c.GetString("maCN")
+	}
+	if maCN == "" {
+		maCN = h.siteID
+	}
+
+	policy, err := h.policyRepo.GetEffectivePolicy(ctx, maCN, strings.TrimSpace(c.Query("loaiDG")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to resolve policy",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Applicable policy resolved successfully",
+		Data:    policy,
+	})
+}
+
+// GetPolicy handles GET /policies/:maCS
+// @Summary Get circulation policy
+// @Description Get a circulation policy
+// @Tags Policies
+// @Produce json
+// @Param maCS path string true "Policy ID"
+// @Success 200 {object} models.SuccessResponse{data=models.ChinhSach} "Circulation policy"
+// @Failure 404 {object} models.ErrorResponse "Policy not found"
+// @Router /policies/{maCS} [get]
+func (h *PolicyHandler) GetPolicy(c *gin.Context) {
+	ctx := c.Request.Context()
+	maCS := c.Param("maCS")
+
+	policy, err := h.policyRepo.GetPolicy(ctx, maCS)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Policy not found",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Policy retrieved successfully",
+		Data:    policy,
+	})
+}
+
+// CreatePolicy handles POST /policies
+// @Summary Create circulation policy
+// @Description Create a policy for a branch and reader category, replicated to every site. Leave maCN or loaiDG empty for every branch or category; only one policy may exist per branch and category. (QuanLy only)
+// @Tags Policies
+// @Accept json
+// @Produce json
+// @Param request body models.PolicyRequest true "Policy"
+// @Success 201 {object} models.SuccessResponse{data=models.ChinhSach} "Policy created"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 500 {object} models.ErrorResponse "Failed to create policy"
+// @Router /policies [post]
+func (h *PolicyHandler) CreatePolicy(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req models.PolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	policy := policyFromRequest("", &req)
+	if err := h.policyRepo.CreatePolicy(ctx, policy); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to create policy",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Message: "Policy created successfully",
+		Data:    policy,
+	})
+}
+
+// UpdatePolicy handles PUT /policies/:maCS
+// @Summary Update circulation policy
+// @Description Change the limits of a policy at every site. The branch and reader category it applies to are kept; maCN and loaiDG in the request are ignored. (QuanLy only)
+// @Tags Policies
+// @Accept json
+// @Produce json
+// @Param maCS path string true "Policy ID"
+// @Param request body models.PolicyRequest true "Policy"
+// @Success 200 {object} models.SuccessResponse{data=models.ChinhSach} "Policy updated"
+// @Failure 400 {object} models.ErrorResponse "Invalid request format"
+// @Failure 404 {object} models.ErrorResponse "Policy not found"
+// @Failure 500 {object} models.ErrorResponse "Failed to update policy"
+// @Router /policies/{maCS} [put]
+func (h *PolicyHandler) UpdatePolicy(c *gin.Context) {
+	ctx := c.Request.Context()
+	maCS := c.Param("maCS")
+
+	var req models.PolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "Invalid request format",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	existing, err := h.policyRepo.GetPolicy(ctx, maCS)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "Policy not found",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	policy := policyFromRequest(maCS, &req)
+	policy.MaCN = existing.MaCN
+	policy.LoaiDG = existing.LoaiDG
+	if err := h.policyRepo.UpdatePolicy(ctx, policy); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to update policy",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Policy updated successfully",
+		Data:    policy,
+	})
+}
+
+// DeletePolicy handles DELETE /policies/:maCS
+// @Summary Delete circulation policy
+// @Description Delete a policy at every site. Loans it applied to fall back on the next most specific policy. (QuanLy only)
+// @Tags Policies
+// @Produce json
+// @Param maCS path string true "Policy ID"
+// @Success 200 {object} models.SuccessResponse "Policy deleted"
+// @Failure 500 {object} models.ErrorResponse "Failed to delete policy"
+// @Router /policies/{maCS} [delete]
+func (h *PolicyHandler) DeletePolicy(c *gin.Context) {
+	ctx := c.Request.Context()
+	maCS := c.Param("maCS")
+
+	if err := h.policyRepo.DeletePolicy(ctx, maCS); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to delete policy",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: "Policy deleted successfully",
+	})
+}
+
+// policyFromRequest builds the policy described by a request
+func policyFromRequest(maCS string, req *models.PolicyRequest) *models.ChinhSach {
+	return &models.ChinhSach{
+		MaCS:        maCS,
+		MaCN:        strings.TrimSpace(req.MaCN),
+		LoaiDG:      strings.TrimSpace(req.LoaiDG),
+		SoNgayMuon:  req.SoNgayMuon,
+		SoSachToiDa: req.SoSachToiDa,
+		SoLanGiaHan: req.SoLanGiaHan,
+		MucPhat:     req.MucPhat,
+		SoNgayAnHan: req.SoNgayAnHan,
+	}
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
 	"library_distributed_server/internal/repository"
 	"library_distributed_server/pkg/utils"
 
@@ -182,53 +185,74 @@ func (h *ReaderHandler) DeleteDocGia(c *gin.Context) {
 // @Produce json
 // @Param page query int false "Page number (0-based, default 0)"
 // @Param size query int false "Page size (default 20)"
+// @Param cursor query string false "Cursor from the previous page's paging.nextCursor (managers only)"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable (managers only)"
 // @Success 200 {object} models.ListResponse "List of readers"
+// @Failure 400 {object} models.ErrorResponse "Invalid cursor"
 // @Failure 500 {object} models.ErrorResponse "Failed to retrieve readers"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
 // @Router /readers [get]
 func (h *ReaderHandler) GetAllDocGia(c *gin.Context) {
-	ctx := c.Request.Context()
+	ctx, tracker := trackSites(c)
 	userRole := c.GetString("role")
 	userSite := c.GetString("maCN")
 	pagination := utils.ParsePaginationParams(c)
 
 	var readers []*models.DocGia
 	var total int
+	var nextCursor string
 	var err error
 
 	if userRole == "QUANLY" {
 		// Managers can see all readers across all sites
-		readers, total, err = h.readerRepo.GetAllReaders(ctx, &pagination)
+		var page *query.Page[*models.DocGia]
+		page, err = h.readerRepo.GetAllReaders(ctx, &pagination)
+		if errors.Is(err, query.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "Invalid cursor",
+				Details: err.Error(),
+			})
+			return
+		}
+		if err == nil {
+			readers, total, nextCursor = page.Items, page.Total, page.NextCursor
+		}
 	} else {
 		// ThuThu can only see readers from their site
 		readers, total, err = h.readerRepo.GetReadersBySite(ctx, userSite, &pagination)
 	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		c.JSON(failureStatus(err), models.ErrorResponse{
 			Error:   "Failed to retrieve readers",
 			Details: err.Error(),
 		})
 		return
 	}
 
-	listResponse := utils.CreateListResponse(readers, pagination, total)
+	listResponse := utils.CreateCursorListResponse(readers, pagination, total, nextCursor)
+	listResponse.Metadata = tracker.Metadata()
 	c.JSON(http.StatusOK, listResponse)
 }
 
 // SearchReaders handles GET /api/readers/search
 // @Summary Search readers
-// @Description Search for readers across sites (role-based access)
+// @Description Find readers at every branch by an approximate name or a partial ID, ignoring diacritics and tolerating typos, e.g. to spot duplicates before registering a reader. Matches are ranked by similarity and grouped by registration branch. (ThuThu and QuanLy)
 // @Tags Readers
 // @Produce json
-// @Param query query string true "Search query"
+// @Param query query string true "Name or reader ID, e.g. nguyen van b or DG00"
+// @Param minScore query number false "Least similarity of a match, from 0 to 1 (default 0.8)"
 // @Param page query int false "Page number (0-based, default 0)"
 // @Param size query int false "Page size (default 20)"
-// @Success 200 {object} models.ListResponse "Search results"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
+// @Success 200 {object} models.SuccessResponse "Matches grouped by registration branch"
 // @Failure 400 {object} models.ErrorResponse "Invalid query"
+// @Failure 403 {object} models.ErrorResponse "Role not allowed to search readers"
 // @Failure 500 {object} models.ErrorResponse "Search failed"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
 // @Router /readers/search [get]
 func (h *ReaderHandler) SearchReaders(c *gin.Context) {
-	ctx := c.Request.Context()
+	ctx, tracker := trackSites(c)
 	query := c.Query("query")
 	pagination := utils.ParsePaginationParams(c)
 
@@ -239,22 +263,38 @@ func (h *ReaderHandler) SearchReaders(c *gin.Context) {
 		return
 	}
 
-	readers, total, err := h.readerRepo.SearchReaders(ctx, query, &pagination)
+	var minScore float64
+	if value := c.Query("minScore"); value != "" {
+		score, err := strconv.ParseFloat(value, 64)
+		if err != nil || score < 0 || score > 1 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error: "minScore must be a number from 0 to 1",
+			})
+			return
+		}
+		minScore = score
+	}
+
+	result, err := h.readerRepo.SearchReaders(ctx, query, minScore, &pagination)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		c.JSON(failureStatus(err), models.ErrorResponse{
 			Error:   "Search failed",
 			Details: err.Error(),
 		})
 		return
 	}
 
-	listResponse := utils.CreateListResponse(readers, pagination, total)
-	c.JSON(http.StatusOK, listResponse)
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success:  true,
+		Message:  "Reader search completed successfully",
+		Data:     result,
+		Metadata: tracker.Metadata(),
+	})
 }
 
 // GetReaderWithStats handles GET /api/readers/{maDG}/stats
 // @Summary Get reader with statistics
-// @Description Get reader information with borrowing statistics
+// @Description Get reader information with borrowing statistics and the fines owed at every branch
 // @Tags Readers
 // @Produce json
 // @Param maDG path string true "Reader ID"
@@ -284,7 +324,7 @@ func (h *ReaderHandler) GetReaderWithStats(c *gin.Context) {
 
 // GetReadersWithStats handles GET /api/readers/stats
 // @Summary Get readers with statistics
-// @Description Get all readers with borrowing statistics for the site
+// @Description Get all readers with borrowing statistics and fines for the loans of the site
 // @Tags Readers
 // @Produce json
 // @Success 200 {object} models.SuccessResponse "Readers with statistics"
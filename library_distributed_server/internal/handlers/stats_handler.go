@@ -29,7 +29,7 @@ func NewStatsHandler(statsRepo repository.StatsRepositoryInterface, siteID strin
 // @Tags Statistics
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} models.SuccessResponse "Site statistics retrieved successfully"
+// @Success 200 {object} models.SuccessResponse{data=models.SiteStats} "Site statistics retrieved successfully"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /stats/site [get]
@@ -59,17 +59,19 @@ func (h *StatsHandler) GetSiteStats(c *gin.Context) {
 // GetSystemStats handles GET /stats/system
 // Manager-only endpoint for system-wide statistics
 // @Summary Get system statistics
-// @Description Get comprehensive system statistics across all sites (Manager only)
+// @Description Get comprehensive system statistics across all sites (Manager only). Each site returns partial aggregates (counts, sums, min/max and distinct-count sketches) that are merged into global values; distinct reader and title counts are estimates within about 3%.
 // @Tags Statistics
 // @Produce json
 // @Security BearerAuth
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
 // @Success 200 {object} models.SystemStatsResponse "System statistics retrieved successfully"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
 // @Failure 403 {object} models.ErrorResponse "Access denied - Manager role required"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
 // @Router /stats/system [get]
 func (h *StatsHandler) GetSystemStats(c *gin.Context) {
-	ctx := c.Request.Context()
+	ctx, tracker := trackSites(c)
 	userRole := c.GetString("role")
 
 	if userRole != "QUANLY" {
@@ -82,13 +84,14 @@ func (h *StatsHandler) GetSystemStats(c *gin.Context) {
 
 	stats, err := h.statsRepo.GetSystemStatistics(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		c.JSON(failureStatus(err), models.ErrorResponse{
 			Error:   "Failed to retrieve system statistics",
 			Details: err.Error(),
 		})
 		return
 	}
 
+	stats.Metadata = tracker.Metadata()
 	c.JSON(http.StatusOK, stats)
 }
 
@@ -99,13 +102,15 @@ func (h *StatsHandler) GetSystemStats(c *gin.Context) {
 // @Tags Statistics
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} models.SuccessResponse "Distributed statistics retrieved successfully"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
+// @Success 200 {object} models.SuccessResponse{data=models.SystemStats} "Distributed statistics retrieved successfully"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
 // @Failure 403 {object} models.ErrorResponse "Access denied - Manager role required"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
 // @Router /stats/distributed [get]
 func (h *StatsHandler) GetDistributedStats(c *gin.Context) {
-	ctx := c.Request.Context()
+	ctx, tracker := trackSites(c)
 	userRole := c.GetString("role")
 
 	if userRole != "QUANLY" {
@@ -118,7 +123,7 @@ func (h *StatsHandler) GetDistributedStats(c *gin.Context) {
 
 	stats, err := h.statsRepo.GetDistributedStatistics(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		c.JSON(failureStatus(err), models.ErrorResponse{
 			Error:   "Failed to retrieve distributed statistics",
 			Details: err.Error(),
 		})
@@ -126,9 +131,10 @@ func (h *StatsHandler) GetDistributedStats(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
-		Success: true,
-		Message: "Distributed statistics retrieved successfully",
-		Data:    stats,
+		Success:  true,
+		Message:  "Distributed statistics retrieved successfully",
+		Data:     stats,
+		Metadata: tracker.Metadata(),
 	})
 }
 
@@ -140,12 +146,14 @@ func (h *StatsHandler) GetDistributedStats(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param limit query int false "Number of books to return" default(10)
-// @Success 200 {object} models.SuccessResponse "Popular books statistics retrieved successfully"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
+// @Success 200 {object} models.SuccessResponse{data=[]models.BookWithAvailability} "Popular books statistics retrieved successfully"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
 // @Router /stats/popular-books [get]
 func (h *StatsHandler) GetPopularBooks(c *gin.Context) {
-	ctx := c.Request.Context()
+	ctx, tracker := trackSites(c)
 
 	// Parse limit parameter
 	limit := 10
@@ -157,7 +165,7 @@ func (h *StatsHandler) GetPopularBooks(c *gin.Context) {
 
 	books, err := h.statsRepo.GetPopularBooksAcrossSites(ctx, limit)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+		c.JSON(failureStatus(err), models.ErrorResponse{
 			Error:   "Failed to retrieve popular books statistics",
 			Details: err.Error(),
 		})
@@ -165,9 +173,10 @@ func (h *StatsHandler) GetPopularBooks(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
-		Success: true,
-		Message: "Popular books statistics retrieved successfully",
-		Data:    books,
+		Success:  true,
+		Message:  "Popular books statistics retrieved successfully",
+		Data:     books,
+		Metadata: tracker.Metadata(),
 	})
 }
 
@@ -179,7 +188,7 @@ func (h *StatsHandler) GetPopularBooks(c *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param days query int false "Number of days to analyze" default(30)
-// @Success 200 {object} models.SuccessResponse "Borrow trends retrieved successfully"
+// @Success 200 {object} models.SuccessResponse{data=[]models.BorrowTrend} "Borrow trends retrieved successfully"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /stats/borrow-trends [get]
@@ -221,7 +230,7 @@ func (h *StatsHandler) GetBorrowTrends(c *gin.Context) {
 // @Tags Statistics
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} models.SuccessResponse "System health retrieved successfully"
+// @Success 200 {object} models.SuccessResponse{data=models.SystemHealth} "System health retrieved successfully"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /stats/health [get]
@@ -246,15 +255,12 @@ func (h *StatsHandler) GetSystemHealth(c *gin.Context) {
 
 // GetReadersWithStats handles GET /stats/readers
 // Enhanced endpoint for Flutter app with reader statistics and pagination
-// @Summary Get readers with statistics and pagination
-// @Description Get readers with borrowing statistics with pagination (enhanced for Flutter app)
+// @Summary Get book and reader statistics of the site
+// @Description Get the copy and reader statistics of the user's site (enhanced for Flutter app)
 // @Tags Statistics
 // @Produce json
 // @Security BearerAuth
-// @Param page query int false "Page number (0-based)" default(0)
-// @Param size query int false "Items per page" default(20)
-// @Param search query string false "Search term for reader name or ID"
-// @Success 200 {object} models.ListResponse "Readers with stats retrieved successfully"
+// @Success 200 {object} models.SuccessResponse{data=models.SiteDetailStats} "Reader statistics retrieved successfully"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /stats/readers [get]
@@ -286,10 +292,10 @@ func (h *StatsHandler) GetReadersWithStats(c *gin.Context) {
 	}
 
 	// Combine stats
-	combinedStats := map[string]interface{}{
-		"book_stats":   bookStats,
-		"reader_stats": readerStats,
-		"site_id":      siteID,
+	combinedStats := models.SiteDetailStats{
+		SiteID:      siteID,
+		BookStats:   *bookStats,
+		ReaderStats: *readerStats,
 	}
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TransferHandler struct {
+	transferRepo repository.TransferRepositoryInterface
+	siteID       string
+}
+
+func NewTransferHandler(transferRepo repository.TransferRepositoryInterface, siteID string) *TransferHandler {
+	return &TransferHandler{
+		transferRepo: transferRepo,
+		siteID:       siteID,
+	}
+}
+
+// GetOutgoingTransfers handles GET /transfers/outgoing
+// @Summary Get copies to ship
+// @Description List the copies a branch has to ship, oldest first: copies of other branches returned there, to be shipped to their home branch, and copies of the branch set aside for holds picked up at another branch, to be shipped there. Librarians see their own site; managers may pass siteID.
+// @Tags Transfers
+// @Produce json
+// @Param siteID query string false "Branch that took the returns (QuanLy only, default: this site)"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
+// @Success 200 {object} models.SuccessResponse{data=[]models.ChuyenTra} "Copies to ship"
+// @Failure 500 {object} models.ErrorResponse "Failed to retrieve transfers"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
+// @Router /transfers/outgoing [get]
+func (h *TransferHandler) GetOutgoingTransfers(c *gin.Context) {
+	ctx, tracker := trackSites(c)
+
+	transfers, err := h.transferRepo.GetOutgoingTransfers(ctx, h.targetSite(c))
+	if err != nil {
+		c.JSON(failureStatus(err), models.ErrorResponse{
+			Error:   "Failed to retrieve transfers",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success:  true,
+		Message:  "Copies to ship retrieved successfully",
+		Data:     transfers,
+		Metadata: tracker.Metadata(),
+	})
+}
+
+// GetIncomingTransfers handles GET /transfers/incoming
+// @Summary Get incoming copies
+// @Description List the copies on their way to a branch, oldest first: copies of the branch returned at other branches, and copies of other branches shipped for holds picked up there. Librarians see their own site; managers may pass siteID.
+// @Tags Transfers
+// @Produce json
+// @Param siteID query string false "Home branch of the copies (QuanLy only, default: this site)"
+// @Param requireAll query bool false "Fail with 503 instead of returning partial results when a site is unavailable"
+// @Success 200 {object} models.SuccessResponse{data=[]models.ChuyenTra} "Incoming copies"
+// @Failure 500 {object} models.ErrorResponse "Failed to retrieve transfers"
+// @Failure 503 {object} models.ErrorResponse "A site is unavailable and requireAll is set"
+// @Router /transfers/incoming [get]
+func (h *TransferHandler) GetIncomingTransfers(c *gin.Context) {
+	ctx, tracker := trackSites(c)
+
+	transfers, err := h.transferRepo.GetIncomingTransfers(ctx, h.targetSite(c))
+	if err != nil {
+		c.JSON(failureStatus(err), models.ErrorResponse{
+			Error:   "Failed to retrieve transfers",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success:  true,
+		Message:  "Incoming copies retrieved successfully",
+		Data:     transfers,
+		Metadata: tracker.Metadata(),
+	})
+}
+
+// CheckIn handles PUT /transfers/check-in/:id
+// @Summary Check in shipped copy
+// @Description Put a copy returned at another branch back on the shelf of its home branch. When readers are waiting for the title, the copy is set aside for the first of them. A copy of another branch shipped for a hold picked up at the librarian's branch is set aside there for the hold, which becomes ready until its pickup deadline; if the hold ended meanwhile, the copy is listed for shipping home instead. (ThuThu of the home or pickup branch only)
+// @Tags Transfers
+// @Produce json
+// @Param id path string true "Book copy ID"
+// @Success 200 {object} models.SuccessResponse{data=models.ChuyenTra} "Copy checked in"
+// @Failure 500 {object} models.ErrorResponse "Failed to check in copy"
+// @Router /transfers/check-in/{id} [put]
+func (h *TransferHandler) CheckIn(c *gin.Context) {
+	ctx := c.Request.Context()
+	maQuyenSach := c.Param("id")
+	userSite := c.GetString("maCN")
+
+	transfer, hold, err := h.transferRepo.CheckIn(ctx, maQuyenSach, userSite)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "Failed to check in copy",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	message := "Copy " + maQuyenSach + " checked in"
+	if hold != nil {
+		message += " and set aside for hold " + hold.MaDC
+	} else if transfer.TrangThai == models.TransferInTransit {
+		message = "Copy " + maQuyenSach + " arrived after its hold ended; ship it back to site " + transfer.MaCN
+	}
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Message: message,
+		Data:    transfer,
+	})
+}
+
+// targetSite is the branch whose transfers are listed: the librarian's own, or the one a manager asks for
+func (h *TransferHandler) targetSite(c *gin.Context) string {
+	if c.GetString("role") == "THUTHU" {
+		return c.GetString("maCN")
+	}
+	if requested := strings.TrimSpace(c.Query("siteID")); requested != "" {
+		return requested
+	}
+	return h.siteID
+}
@@ -0,0 +1,375 @@
+// Package membership tracks which sites of the distributed system are alive.
+// Every site service and the coordinator exchange heartbeats with all other
+// members; a member that stops answering becomes SUSPECT and then DOWN.
+package membership
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"library_distributed_server/internal/config"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Member states
+const (
+	StateUp      = "UP"      // Heartbeats are being answered
+	StateSuspect = "SUSPECT" // Missed heartbeats, still tried by callers
+	StateDown    = "DOWN"    // Considered failed, callers fail fast
+)
+
+const historyLimit = 200
+
+// ErrSiteDown is returned for operations addressed to a site the failure detector considers down
+var ErrSiteDown = errors.New("site is down")
+
+// Heartbeat is exchanged between members. The receiver answers with its own heartbeat.
+type Heartbeat struct {
+	SiteID          string    `json:"siteId" example:"Q1"`
+	Incarnation     int64     `json:"incarnation" example:"1736935200000000000"` // Changes when the service restarts
+	DatabaseHealthy bool      `json:"databaseHealthy" example:"true"`            // Sender can reach its own database
+	SentAt          time.Time `json:"sentAt"`
+}
+
+// Member is the local view of one other site
+type Member struct {
+	SiteID          string    `json:"siteId" example:"Q3"`
+	ServiceURL      string    `json:"serviceURL" example:"http://localhost:8083"`
+	State           string    `json:"state" example:"UP"`
+	Incarnation     int64     `json:"incarnation"`
+	DatabaseHealthy bool      `json:"databaseHealthy" example:"true"`
+	LastSeen        time.Time `json:"lastSeen"`
+	LastError       string    `json:"lastError,omitempty"`
+}
+
+// Transition records a member changing state
+type Transition struct {
+	SiteID string    `json:"siteId" example:"Q3"`
+	From   string    `json:"from" example:"UP"`
+	To     string    `json:"to" example:"SUSPECT"`
+	Reason string    `json:"reason" example:"no heartbeat for 6s"`
+	At     time.Time `json:"at"`
+}
+
+// View is a snapshot of the membership as seen from one site
+type View struct {
+	Self    string       `json:"self" example:"Q1"`
+	Members []Member     `json:"members"`
+	History []Transition `json:"history"` // Most recent last
+}
+
+// Membership runs the heartbeat loop and failure detector for one site
+type Membership struct {
+	store       *config.Store
+	self        string
+	incarnation int64
+	localCheck  func(ctx context.Context) error // Health of the site's own database, nil for the coordinator
+	client      *http.Client
+
+	mutex   sync.RWMutex
+	members map[string]*Member
+	history []Transition
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// New creates the membership of site self. localCheck reports whether the site's own
+// database is reachable and may be nil for members without a database.
+func New(store *config.Store, self string, localCheck func(ctx context.Context) error) *Membership {
+	return &Membership{
+		store:       store,
+		self:        self,
+		incarnation: time.Now().UnixNano(),
+		localCheck:  localCheck,
+		client:      &http.Client{},
+		members:     make(map[string]*Member),
+		stop:        make(chan struct{}),
+	}
+}
+
+// Start launches the heartbeat loop in the background
+func (m *Membership) Start() {
+	interval := m.config().Membership.HeartbeatInterval
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			m.round()
+
+			// Pick up a heartbeat interval changed by a configuration reload
+			if current := m.config().Membership.HeartbeatInterval; current != interval {
+				interval = current
+				ticker.Reset(interval)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+	log.Printf("Membership of %s started (heartbeat every %s)", m.self, interval)
+}
+
+// Stop ends the heartbeat loop
+func (m *Membership) Stop() {
+	m.once.Do(func() { close(m.stop) })
+}
+
+// Receive handles a heartbeat from another member and returns this member's own heartbeat
+func (m *Membership) Receive(hb Heartbeat) Heartbeat {
+	m.mutex.Lock()
+	if member, exists := m.members[hb.SiteID]; exists {
+		m.observe(member, hb)
+	}
+	m.mutex.Unlock()
+
+	return m.heartbeat()
+}
+
+// State returns the state of a site. Sites without membership information
+// (this site itself, or ones not yet heard of) are reported as up.
+func (m *Membership) State(siteID string) string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if member, exists := m.members[siteID]; exists {
+		return member.State
+	}
+	return StateUp
+}
+
+// CheckAvailable returns ErrSiteDown when the site is considered down
+func (m *Membership) CheckAvailable(siteID string) error {
+	if m.State(siteID) == StateDown {
+		return fmt.Errorf("%w: %s", ErrSiteDown, siteID)
+	}
+	return nil
+}
+
+// View returns a snapshot of all members and the transition history
+func (m *Membership) View() View {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	view := View{
+		Self:    m.self,
+		Members: make([]Member, 0, len(m.members)),
+		History: append([]Transition(nil), m.history...),
+	}
+	for _, id := range m.peerIDs() {
+		if member, exists := m.members[id]; exists {
+			view.Members = append(view.Members, *member)
+		}
+	}
+	return view
+}
+
+// config returns the configuration currently in effect
+func (m *Membership) config() *config.Config {
+	return m.store.Current()
+}
+
+// heartbeat builds this member's heartbeat, checking its own database
+func (m *Membership) heartbeat() Heartbeat {
+	healthy := true
+	if m.localCheck != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), m.config().Membership.HeartbeatInterval)
+		defer cancel()
+		if err := m.localCheck(ctx); err != nil {
+			healthy = false
+		}
+	}
+	return Heartbeat{
+		SiteID:          m.self,
+		Incarnation:     m.incarnation,
+		DatabaseHealthy: healthy,
+		SentAt:          time.Now(),
+	}
+}
+
+// round sends one heartbeat to every peer and then applies the failure detector timeouts
+func (m *Membership) round() {
+	m.syncMembers()
+	hb := m.heartbeat()
+
+	m.mutex.RLock()
+	targets := make(map[string]string, len(m.members))
+	for id, member := range m.members {
+		targets[id] = member.ServiceURL
+	}
+	m.mutex.RUnlock()
+
+	var wg sync.WaitGroup
+	for id, serviceURL := range targets {
+		wg.Add(1)
+		go func(siteID, serviceURL string) {
+			defer wg.Done()
+			reply, err := m.send(serviceURL, hb)
+
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			member, exists := m.members[siteID]
+			if !exists {
+				return
+			}
+			if err != nil {
+				member.LastError = err.Error()
+				return
+			}
+			m.observe(member, reply)
+		}(id, serviceURL)
+	}
+	wg.Wait()
+
+	m.detect()
+}
+
+// send posts a heartbeat to a member and decodes its reply
+func (m *Membership) send(serviceURL string, hb Heartbeat) (Heartbeat, error) {
+	var reply Heartbeat
+
+	body, err := json.Marshal(hb)
+	if err != nil {
+		return reply, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), m.config().Membership.HeartbeatInterval)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(serviceURL, "/")+"/membership/heartbeat", bytes.NewReader(body))
+	if err != nil {
+		return reply, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(config.SiteSecretHeader, m.config().Auth.SiteSecret)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return reply, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return reply, fmt.Errorf("heartbeat rejected with status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return reply, fmt.Errorf("invalid heartbeat reply: %w", err)
+	}
+	return reply, nil
+}
+
+// observe applies a heartbeat received from or returned by a member; callers must hold the mutex
+func (m *Membership) observe(member *Member, hb Heartbeat) {
+	if member.Incarnation != 0 && hb.Incarnation != member.Incarnation {
+		m.record(member, member.State, "service restarted")
+	}
+	member.Incarnation = hb.Incarnation
+	member.DatabaseHealthy = hb.DatabaseHealthy
+	member.LastSeen = time.Now()
+	member.LastError = ""
+
+	if hb.DatabaseHealthy {
+		m.transition(member, StateUp, "heartbeat received")
+	} else {
+		// The service answers but cannot reach its own database, so its fragments are unavailable
+		m.transition(member, StateDown, "site reports its database unreachable")
+	}
+}
+
+// detect moves members that have been silent too long to SUSPECT or DOWN
+func (m *Membership) detect() {
+	settings := m.config().Membership
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	for _, member := range m.members {
+		silent := now.Sub(member.LastSeen)
+		switch {
+		case silent >= settings.DownTimeout:
+			m.transition(member, StateDown, fmt.Sprintf("no heartbeat for %s", silent.Round(time.Second)))
+		case silent >= settings.SuspectTimeout && member.State == StateUp:
+			m.transition(member, StateSuspect, fmt.Sprintf("no heartbeat for %s", silent.Round(time.Second)))
+		}
+	}
+}
+
+// syncMembers adds members for sites that joined the topology since the last round
+// and drops members for sites removed from it
+func (m *Membership) syncMembers() {
+	cfg := m.config()
+	peers := make(map[string]string)
+	for _, site := range cfg.Sites {
+		peers[site.SiteID] = site.ServiceURL
+	}
+	if cfg.Coordinator.SiteID != "" && cfg.Coordinator.ServiceURL != "" {
+		peers[cfg.Coordinator.SiteID] = cfg.Coordinator.ServiceURL
+	}
+	delete(peers, m.self)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for id := range m.members {
+		if _, exists := peers[id]; !exists {
+			delete(m.members, id)
+		}
+	}
+	for id, serviceURL := range peers {
+		if member, exists := m.members[id]; exists {
+			member.ServiceURL = serviceURL
+			continue
+		}
+		// New members start as suspect and are given until the down timeout to answer
+		member := &Member{SiteID: id, ServiceURL: serviceURL, LastSeen: time.Now()}
+		m.members[id] = member
+		m.transition(member, StateSuspect, "awaiting first heartbeat")
+	}
+}
+
+// transition changes a member's state and records it; callers must hold the mutex
+func (m *Membership) transition(member *Member, state, reason string) {
+	if member.State == state {
+		return
+	}
+	m.record(member, state, reason)
+	member.State = state
+}
+
+// record appends to the bounded transition history; callers must hold the mutex
+func (m *Membership) record(member *Member, to, reason string) {
+	t := Transition{
+		SiteID: member.SiteID,
+		From:   member.State,
+		To:     to,
+		Reason: reason,
+		At:     time.Now(),
+	}
+	log.Printf("Membership: site %s %s -> %s (%s)", t.SiteID, t.From, t.To, t.Reason)
+
+	m.history = append(m.history, t)
+	if len(m.history) > historyLimit {
+		m.history = m.history[len(m.history)-historyLimit:]
+	}
+}
+
+// peerIDs returns member IDs in topology order; callers must hold the mutex
+func (m *Membership) peerIDs() []string {
+	cfg := m.config()
+	var ids []string
+	for _, site := range cfg.Sites {
+		ids = append(ids, site.SiteID)
+	}
+	if cfg.Coordinator.SiteID != "" {
+		ids = append(ids, cfg.Coordinator.SiteID)
+	}
+	return ids
+}
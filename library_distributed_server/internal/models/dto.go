@@ -1,6 +1,12 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+
+	"library_distributed_server/internal/cache"
+	"library_distributed_server/internal/catalog"
+)
 
 // Request DTOs
 
@@ -11,6 +17,19 @@ type CreateBorrowRequest struct {
 	MaQuyenSach string `json:"maQuyenSach" binding:"required" example:"QS001" validate:"required"` // Book copy ID
 }
 
+// BatchBorrowRequest - Request to lend several copies to a reader at once
+// @Description Request payload for a batch checkout: every copy is lent, or none is
+type BatchBorrowRequest struct {
+	MaDG        string   `json:"maDG" binding:"required" example:"DG001" validate:"required"`                     // Reader ID
+	MaQuyenSach []string `json:"maQuyenSach" binding:"required,min=1,max=50,dive,required" example:"QS001,QS002"` // Book copy IDs
+}
+
+// BatchReturnRequest - Request to return several copies at once
+// @Description Request payload for a batch return: every copy is returned, or none is
+type BatchReturnRequest struct {
+	MaQuyenSach []string `json:"maQuyenSach" binding:"required,min=1,max=50,dive,required" example:"QS001,QS002"` // Book copy IDs
+}
+
 // ReturnBookRequest - Request to return a borrowed book
 // @Description Request payload for returning a borrowed book
 type ReturnBookRequest struct {
@@ -18,6 +37,153 @@ type ReturnBookRequest struct {
 	NgayTra     time.Time `json:"ngayTra" example:"2025-01-20T14:00:00Z"` // Return date
 }
 
+// PlaceHoldRequest - Request to queue a reader for a title
+// @Description Request payload for placing a hold on a title
+type PlaceHoldRequest struct {
+	MaDG         string `json:"maDG" binding:"required" example:"DG001" validate:"required"`             // Reader ID
+	ISBN         string `json:"isbn" binding:"required" example:"978-0-123456-78-9" validate:"required"` // Title to hold
+	MaCNNhanSach string `json:"maCNNhanSach" example:"Q3"`                                               // Pickup branch (optional, any branch when empty)
+}
+
+// ReturnBookResponse - Outcome of a return
+// @Description Hold the returned copy was set aside for, fine assessed for a late return and transfer home of a copy returned at another branch, when any
+type ReturnBookResponse struct {
+	Hold     *DatCho    `json:"hold,omitempty"`     // Hold served by the returned copy
+	Fine     *Phat      `json:"fine,omitempty"`     // Fine for a late return
+	Transfer *ChuyenTra `json:"transfer,omitempty"` // Shipment home of a copy returned at another branch
+}
+
+// BatchItem - Outcome of one copy of a batch checkout or return
+// @Description Loan made, or return outcome, for one copy of a batch; or why the copy blocked the batch
+type BatchItem struct {
+	MaQuyenSach string     `json:"maQuyenSach" example:"QS001"`                                                  // Book copy ID
+	Success     bool       `json:"success" example:"true"`                                                       // Whether the copy passed validation and, for a committed batch, was processed
+	Error       string     `json:"error,omitempty" example:"book copy QS002 is not available (status: Bị hỏng)"` // Why the copy blocked the batch
+	Borrow      *PhieuMuon `json:"borrow,omitempty"`                                                             // Loan made for the copy
+	Fine        *Phat      `json:"fine,omitempty"`                                                               // Fine for a late return
+	Hold        *DatCho    `json:"hold,omitempty"`                                                               // Hold served by the returned copy
+	Transfer    *ChuyenTra `json:"transfer,omitempty"`                                                           // Shipment home of a copy returned at another branch
+}
+
+// BatchReceipt - Receipt of a batch checkout or return
+// @Description Outcome of every copy of a batch, committed together. When the batch is rejected, no copy was lent or returned and the items say which copies blocked it.
+type BatchReceipt struct {
+	MaDG        string           `json:"maDG,omitempty" example:"DG001"`             // Reader (checkout)
+	MaCN        string           `json:"maCN" example:"Q1"`                          // Branch that processed the batch
+	ProcessedAt time.Time        `json:"processedAt" example:"2025-01-15T10:30:00Z"` // When the batch was processed
+	Committed   bool             `json:"committed" example:"true"`                   // Whether the whole batch was applied
+	Items       []*BatchItem     `json:"items"`                                      // Outcome per copy, in request order
+	Violation   *PolicyViolation `json:"violation,omitempty"`                        // Circulation rule that blocked the whole checkout
+	DueDate     *time.Time       `json:"dueDate,omitempty"`                          // Earliest due date of the loans made
+	TotalFine   int              `json:"totalFine" example:"15000"`                  // Total of the fines assessed for late returns (VND)
+}
+
+// BatchRejection - Batch checkout or return refused as a whole
+// @Description Batch refused during validation or processing; no copy was lent or returned
+type BatchRejection struct {
+	Reason  string        `json:"reason" example:"2 of 3 copies cannot be lent"` // Explanation
+	Receipt *BatchReceipt `json:"receipt"`                                       // Outcome per copy
+}
+
+func (e *BatchRejection) Error() string {
+	return e.Reason
+}
+
+// BatchIncomplete - Batch return whose commit stopped partway
+// @Description Best-effort batch whose transactions committed on some databases only. The items marked successful were returned and stay returned; the others are still on loan and can be returned again.
+type BatchIncomplete struct {
+	Reason  string        `json:"reason" example:"2 of 3 copies returned: failed to commit returns on site Q3"` // Explanation
+	Receipt *BatchReceipt `json:"receipt"`                                                                      // Outcome per copy
+}
+
+func (e *BatchIncomplete) Error() string {
+	return e.Reason
+}
+
+// FinePaymentRequest - Request to record a payment against a fine
+// @Description Request payload for recording a fine payment
+type FinePaymentRequest struct {
+	SoTien int    `json:"soTien" binding:"required,min=1" example:"10000" validate:"required"` // Amount paid (VND), at most the outstanding amount
+	GhiChu string `json:"ghiChu" example:"Tiền mặt"`                                           // Note (optional)
+}
+
+// FineWaiverRequest - Request to waive a fine
+// @Description Request payload for waiving all or part of a fine
+type FineWaiverRequest struct {
+	SoTien int    `json:"soTien" binding:"min=0" example:"0"`                                       // Amount waived (VND); 0 waives the whole outstanding amount
+	GhiChu string `json:"ghiChu" binding:"required" example:"Độc giả nằm viện" validate:"required"` // Reason
+}
+
+// ILLRequest - Request to borrow a title from another branch
+// @Description Request payload for an inter-library loan. Without maCNSoHuu, a branch with a free copy is chosen.
+type ILLRequest struct {
+	MaDG      string `json:"maDG" binding:"required" example:"DG004" validate:"required"`             // Reader the copy is for
+	ISBN      string `json:"isbn" binding:"required" example:"978-0-123456-78-9" validate:"required"` // Title requested
+	MaCNSoHuu string `json:"maCNSoHuu" example:"Q1"`                                                  // Owning branch (optional)
+}
+
+// ILLShipRequest - Request to ship a copy for an inter-library loan
+// @Description Request payload for shipping a copy. Without maQuyenSach, a free copy of the title is chosen.
+type ILLShipRequest struct {
+	MaQuyenSach string `json:"maQuyenSach" example:"QS001"` // Copy to ship (optional)
+}
+
+// ILLResponse - Outcome of a step of an inter-library loan
+// @Description Request after the step, with the loan made, the fine assessed or the hold served by the step, when any
+type ILLResponse struct {
+	Request *YeuCauMuon `json:"request"`          // Request after the step
+	Borrow  *PhieuMuon  `json:"borrow,omitempty"` // Loan made when lending the copy
+	Fine    *Phat       `json:"fine,omitempty"`   // Fine for a late return
+	Hold    *DatCho     `json:"hold,omitempty"`   // Hold served by the copy back on its shelf
+}
+
+// PolicyRequest - Request to create or update a circulation policy
+// @Description Request payload for a circulation policy. Leave maCN or loaiDG empty for a policy of every branch or category.
+type PolicyRequest struct {
+	MaCN        string `json:"maCN" example:"Q1"`                                                    // Branch (optional)
+	LoaiDG      string `json:"loaiDG" example:"Sinh viên"`                                           // Reader category (optional)
+	SoNgayMuon  int    `json:"soNgayMuon" binding:"required,min=1" example:"14" validate:"required"` // Loan length in days
+	SoSachToiDa int    `json:"soSachToiDa" binding:"min=0" example:"5"`                              // Loans a reader may have at once
+	SoLanGiaHan int    `json:"soLanGiaHan" binding:"min=0" example:"1"`                              // Renewals allowed per loan
+	MucPhat     int    `json:"mucPhat" binding:"min=0" example:"3000"`                               // Fine per day overdue (VND)
+	SoNgayAnHan int    `json:"soNgayAnHan" binding:"min=0" example:"2"`                              // Days overdue not charged
+}
+
+// Circulation rules a PolicyViolation may name
+const (
+	RuleMaxItems      = "soSachToiDa"    // Loans a reader may have at once
+	RuleMaxRenewals   = "soLanGiaHan"    // Renewals allowed per loan
+	RuleOverdueLoans  = "quaHan"         // No loans while one is overdue
+	RuleFineThreshold = "nguongChanPhat" // Unpaid fines above which a reader cannot borrow
+)
+
+// PolicyViolation - Circulation rule that blocked an action
+// @Description Rule of the applicable circulation policy that blocked a loan or renewal
+type PolicyViolation struct {
+	Rule   string `json:"rule" example:"soSachToiDa" enums:"soSachToiDa,soLanGiaHan,quaHan,nguongChanPhat"` // Rule that blocked the action
+	MaCS   string `json:"maCS" example:"CS-Q1-SV"`                                                          // Policy the rule belongs to; "default" for the configured defaults
+	Limit  int    `json:"limit" example:"5"`                                                                // Limit set by the rule
+	Actual int    `json:"actual" example:"5"`                                                               // Value the action would exceed
+	Reason string `json:"reason" example:"reader DG001 has reached maximum borrow limit (5 books)"`         // Explanation
+}
+
+func (v *PolicyViolation) Error() string {
+	return fmt.Sprintf("%s (policy %s, rule %s)", v.Reason, v.MaCS, v.Rule)
+}
+
+// TransitionError - Copy status change that is not allowed
+// @Description Move of a book copy between two statuses that the copy lifecycle does not allow
+type TransitionError struct {
+	From    CopyStatus   `json:"from" example:"Đang được mượn"`                                          // Current status
+	To      CopyStatus   `json:"to" example:"Đang sửa chữa"`                                             // Requested status
+	Allowed []CopyStatus `json:"allowed"`                                                                // Statuses the copy may be set to by hand
+	Reason  string       `json:"reason" example:"a copy cannot go from Đang được mượn to Đang sửa chữa"` // Explanation
+}
+
+func (e *TransitionError) Error() string {
+	return e.Reason
+}
+
 // SearchBooksRequest - Request for searching books across sites
 // @Description Request payload for searching books across all sites
 type SearchBooksRequest struct {
@@ -38,9 +204,26 @@ type TransferBookRequest struct {
 // SuccessResponse - Generic success response
 // @Description Generic success response
 type SuccessResponse struct {
-	Success bool        `json:"success" example:"true"`                             // Operation success status
-	Message string      `json:"message" example:"Operation completed successfully"` // Success message
-	Data    interface{} `json:"data,omitempty"`                                     // Response data (optional)
+	Success  bool           `json:"success" example:"true"`                             // Operation success status
+	Message  string         `json:"message" example:"Operation completed successfully"` // Success message
+	Data     interface{}    `json:"data,omitempty"`                                     // Response data (optional)
+	Metadata *QueryMetadata `json:"metadata,omitempty"`                                 // Sites reached by a distributed read (omitted for single-site operations)
+	Facets   *CatalogFacets `json:"facets,omitempty"`                                   // Filter counts of a faceted catalog search
+}
+
+// QueryMetadata - Outcome of a read spread over several sites
+// @Description Which sites a distributed read reached; partial results omit the data of failed sites
+type QueryMetadata struct {
+	SitesQueried   []string      `json:"sitesQueried" example:"Q1,Q3"`
+	SitesSucceeded []string      `json:"sitesSucceeded" example:"Q1"`
+	SitesFailed    []SiteFailure `json:"sitesFailed"`
+	Partial        bool          `json:"partial" example:"true"` // Some sites failed, so the data is incomplete
+}
+
+// SiteFailure - A site that could not answer a distributed read
+type SiteFailure struct {
+	SiteID string `json:"siteId" example:"Q3"`
+	Error  string `json:"error" example:"context deadline exceeded"`
 }
 
 // ErrorResponse - Generic error response
@@ -99,6 +282,30 @@ type ReaderWithStats struct {
 	CurrentBorrowed int    `json:"currentBorrowed" example:"3"`         // Currently borrowed books
 	OverdueBooks    int    `json:"overdueBooks" example:"1"`            // Overdue books count
 	LastBorrowDate  string `json:"lastBorrowDate" example:"2025-01-15"` // Last borrow date
+	UnpaidFines     int    `json:"unpaidFines" example:"20000"`         // Outstanding fines assessed on returns (VND)
+	AccruingFines   int    `json:"accruingFines" example:"15000"`       // Fines building up on overdue loans not yet returned (VND)
+}
+
+// FineReport - Outstanding fines across the system
+// @Description Outstanding fines at every branch, for managers. Amounts are in VND.
+type FineReport struct {
+	TotalUnpaid   int           `json:"totalUnpaid" example:"450000"`  // Outstanding fines assessed on returns
+	UnpaidFines   int           `json:"unpaidFines" example:"18"`      // Fines not settled
+	ReadersOwing  int           `json:"readersOwing" example:"11"`     // Readers with an outstanding fine at any branch
+	TotalAccruing int           `json:"totalAccruing" example:"90000"` // Fines building up on overdue loans not yet returned
+	Branches      []BranchFines `json:"branches"`                      // Per branch, in topology order
+}
+
+// BranchFines - Outstanding fines of one branch
+// @Description Outstanding fines of the loans made at one branch
+type BranchFines struct {
+	MaCN          string `json:"maCN" example:"Q1"`             // Branch code
+	DailyRate     int    `json:"dailyRate" example:"5000"`      // Fine per day overdue for readers of the default category
+	TotalUnpaid   int    `json:"totalUnpaid" example:"250000"`  // Outstanding fines assessed on returns
+	UnpaidFines   int    `json:"unpaidFines" example:"10"`      // Fines not settled
+	ReadersOwing  int    `json:"readersOwing" example:"7"`      // Readers with an outstanding fine here
+	OverdueLoans  int    `json:"overdueLoans" example:"4"`      // Loans past due, not yet returned
+	TotalAccruing int    `json:"totalAccruing" example:"60000"` // Fines building up on those loans
 }
 
 // SystemStatsResponse - System-wide statistics for managers
@@ -109,24 +316,144 @@ type SystemStatsResponse struct {
 	TotalReaders  int                    `json:"totalReaders" example:"2000"`                // Total registered readers
 	ActiveBorrows int                    `json:"activeBorrows" example:"500"`                // Currently borrowed books
 	OverdueBooks  int                    `json:"overdueBooks" example:"50"`                  // Overdue books
+	Books         BookStatistics         `json:"books"`                                      // Copy statistics merged from all sites
+	Readers       ReaderStatistics       `json:"readers"`                                    // Reader statistics merged from all sites
+	Overdue       OverdueStatistics      `json:"overdue"`                                    // Overdue statistics merged from all sites
 	SiteStats     []SiteStats            `json:"siteStats"`                                  // Per-site statistics
 	PopularBooks  []BookWithAvailability `json:"popularBooks"`                               // Most borrowed books
 	GeneratedAt   string                 `json:"generatedAt" example:"2025-01-15T10:00:00Z"` // Stats generation time
+	Metadata      *QueryMetadata         `json:"metadata,omitempty"`                         // Sites the statistics were collected from
+}
+
+// BookStatistics - Copy statistics of a site or of the whole system
+// @Description Book copy statistics; system-wide values are merged from per-site partial aggregates
+type BookStatistics struct {
+	TotalCopies     int     `json:"totalCopies" example:"500"`     // Book copies
+	AvailableCopies int     `json:"availableCopies" example:"420"` // Copies available for borrowing
+	BorrowedCopies  int     `json:"borrowedCopies" example:"80"`   // Copies on loan
+	UniqueTitles    int     `json:"uniqueTitles" example:"120"`    // Distinct ISBNs with copies; estimated when merged from several sites
+	UtilizationRate float64 `json:"utilizationRate" example:"16"`  // Borrowed copies over all copies, in percent
+}
+
+// ReaderStatistics - Reader statistics of a site or of the whole system
+// @Description Reader statistics; distinct reader counts are estimated when merged from several sites
+type ReaderStatistics struct {
+	TotalReaders              int     `json:"totalReaders" example:"200"`             // Registered readers
+	ActiveReaders             int     `json:"activeReaders" example:"150"`            // Readers who have borrowed at least once
+	ReadersWithCurrentBorrows int     `json:"readersWithCurrentBorrows" example:"60"` // Readers with a book on loan
+	ReadersWithOverdue        int     `json:"readersWithOverdue" example:"5"`         // Readers with an overdue book
+	TotalBorrows              int     `json:"totalBorrows" example:"900"`             // Borrow records
+	AvgBooksPerReader         float64 `json:"avgBooksPerReader" example:"4.5"`        // Borrow records per registered reader
+}
+
+// OverdueStatistics - Overdue loans of a site or of the whole system
+// @Description Overdue loan statistics; averages are merged from per-site counts and sums
+type OverdueStatistics struct {
+	TotalOverdue   int     `json:"totalOverdue" example:"12"`    // Loans kept past their due date
+	ActiveBorrows  int     `json:"activeBorrows" example:"80"`   // Loans not yet returned
+	AvgOverdueDays float64 `json:"avgOverdueDays" example:"6.5"` // Average days past the due date
+	MaxOverdueDays int     `json:"maxOverdueDays" example:"21"`  // Longest time past the loan period, in days
+	OverdueRate    float64 `json:"overdueRate" example:"15"`     // Overdue loans over active loans, in percent
+}
+
+// SiteDetailStats - Book and reader statistics of one site
+// @Description Detailed statistics of the user's site
+type SiteDetailStats struct {
+	SiteID      string           `json:"site_id" example:"Q1"`
+	BookStats   BookStatistics   `json:"book_stats"`
+	ReaderStats ReaderStatistics `json:"reader_stats"`
+}
+
+// BorrowTrend - Borrows on one day
+// @Description Number of borrows made on a day
+type BorrowTrend struct {
+	Date        string `json:"date" example:"2025-01-15"`
+	BorrowCount int    `json:"borrowCount" example:"12"`
+}
+
+// ReaderActivity - Borrow count of one reader
+// @Description Reader with the number of books borrowed
+type ReaderActivity struct {
+	MaDG        string `json:"maDG" example:"DG001"`
+	HoTen       string `json:"hoTen" example:"Nguyễn Văn B"`
+	BorrowCount int    `json:"borrowCount" example:"25"`
+}
+
+// ReaderEngagement - Reader engagement of a site
+// @Description Most active readers and registrations of a site
+type ReaderEngagement struct {
+	ActiveReaders       []ReaderActivity `json:"activeReaders"`                    // Top 5 borrowers
+	NewReadersThisMonth int              `json:"newReadersThisMonth" example:"10"` // Readers registered at the site
+}
+
+// SiteHealth - Health of one site
+// @Description Database health of a site
+type SiteHealth struct {
+	Status      string         `json:"status" example:"healthy"` // "healthy" or "unhealthy"
+	SiteID      string         `json:"siteID,omitempty" example:"Q1"`
+	Error       string         `json:"error,omitempty"`       // Why the site is unhealthy
+	TableCounts map[string]int `json:"tableCounts,omitempty"` // Rows per table of the site's fragments, -1 when the count failed
+	LastChecked string         `json:"lastChecked,omitempty" example:"2025-01-15T10:00:00Z"`
+}
+
+// SystemHealth - Health of every site
+// @Description Database health across all sites
+type SystemHealth struct {
+	TotalSites     int                   `json:"totalSites" example:"2"`
+	HealthySites   int                   `json:"healthySites" example:"2"`
+	UnhealthySites int                   `json:"unhealthySites" example:"0"`
+	SystemStatus   string                `json:"systemStatus" example:"healthy"` // "healthy", "degraded" or "unhealthy"
+	Sites          map[string]SiteHealth `json:"sites"`
+	LastChecked    string                `json:"lastChecked" example:"2025-01-15T10:00:00Z"`
 }
 
 // PagingInfo - Pagination information compatible with Flutter PagingModel
 // @Description Pagination information matching Flutter PagingModel structure
 type PagingInfo struct {
-	Page       int `json:"page" example:"0"`        // Current page number (0-based, matches Flutter)
-	Size       int `json:"size" example:"20"`       // Items per page (matches Flutter)
-	TotalPages int `json:"totalPages" example:"10"` // Total number of pages (matches Flutter)
+	Page       int    `json:"page" example:"0"`                           // Current page number (0-based, matches Flutter)
+	Size       int    `json:"size" example:"20"`                          // Items per page (matches Flutter)
+	TotalPages int    `json:"totalPages" example:"10"`                    // Total number of pages (matches Flutter)
+	NextCursor string `json:"nextCursor,omitempty" example:"W3sidCI6..."` // Pass as ?cursor= to fetch the next page
 }
 
 // ListResponse - Generic list response with pagination compatible with Flutter BookListModel
 // @Description Generic paginated list response matching Flutter BookListModel structure
 type ListResponse struct {
-	Items  interface{} `json:"items"`  // List of items (matches Flutter items field)
-	Paging PagingInfo  `json:"paging"` // Pagination info (matches Flutter paging field)
+	Items    interface{}    `json:"items"`              // List of items (matches Flutter items field)
+	Paging   PagingInfo     `json:"paging"`             // Pagination info (matches Flutter paging field)
+	Metadata *QueryMetadata `json:"metadata,omitempty"` // Sites reached by a distributed read (omitted for single-site lists)
+	Facets   *CatalogFacets `json:"facets,omitempty"`   // Filter counts of a faceted catalog search
+}
+
+// CatalogFilter - Filters of a faceted catalog search
+// Values of one filter are alternatives; different filters must all hold
+type CatalogFilter struct {
+	Authors   []string // TacGia, compared without diacritics or case
+	Branches  []string // MaCN of a branch holding an available copy
+	Statuses  []string // TinhTrang of at least one copy
+	HasCopies *bool    // Whether any branch holds a copy
+}
+
+// Empty reports whether no filter is set
+func (f CatalogFilter) Empty() bool {
+	return len(f.Authors) == 0 && len(f.Branches) == 0 && len(f.Statuses) == 0 && f.HasCopies == nil
+}
+
+// FacetCount - Number of matching books for one filter value
+// @Description Number of books the search would return with this filter value selected
+type FacetCount struct {
+	Value string `json:"value" example:"Q1"`
+	Label string `json:"label,omitempty" example:"Thư Viện Quận 1"` // Display name, for branches
+	Count int    `json:"count" example:"12"`
+}
+
+// CatalogFacets - Filter counts of a faceted catalog search
+// @Description Counts per filter value across all fragments; each facet applies every filter except its own
+type CatalogFacets struct {
+	Branches  []FacetCount `json:"branches"`  // Books with an available copy at the branch
+	Authors   []FacetCount `json:"authors"`   // Books by the author, most frequent first
+	Statuses  []FacetCount `json:"statuses"`  // Books with at least one copy in the status
+	HasCopies []FacetCount `json:"hasCopies"` // Books with ("true") and without ("false") copies
 }
 
 // PaginatedResponse - Generic paginated response (deprecated, use ListResponse instead)
@@ -149,3 +476,25 @@ type TransferBookResponse struct {
 	Protocol    string `json:"protocol" example:"Two-Phase Commit (2PC)"`                          // Protocol used
 	Coordinator string `json:"coordinator" example:"Distributed Transaction Coordinator"`          // Coordinator service
 }
+
+// FragmentCatalogResponse - Global fragmentation and allocation schema
+// @Description Distributed data dictionary: how each global relation is fragmented and where its fragments are stored
+type FragmentCatalogResponse struct {
+	Sites     []string           `json:"sites" example:"Q1,Q3"` // Branch sites in the topology
+	Relations []catalog.Relation `json:"relations"`             // Global relations with their fragments
+}
+
+// CacheStatsResponse - Reference data cache statistics of one site
+// @Description Use of the in-process cache of replicated tables (SACH, CHINHANH) at the site serving the request
+type CacheStatsResponse struct {
+	Site      string        `json:"site" example:"Q1"`
+	TTL       string        `json:"ttl" example:"5m0s"` // Entry lifetime, bounds staleness when an invalidation is lost
+	Relations []cache.Stats `json:"relations"`
+}
+
+// CacheFlushResponse - Result of flushing the reference data caches
+// @Description Sites whose cache was flushed and the ones that could not be reached
+type CacheFlushResponse struct {
+	Flushed []string      `json:"flushed" example:"Q1,Q3"`
+	Failed  []SiteFailure `json:"failed"` // Entries at these sites expire after the TTL
+}
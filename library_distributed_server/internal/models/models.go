@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -23,10 +24,81 @@ type Sach struct {
 // QuyenSach - Horizontally Fragmented by MaCN
 // @Description Book copy information (fragmented by branch)
 type QuyenSach struct {
-	MaQuyenSach string `json:"maQuyenSach" db:"MaQuyenSach" example:"QS001" validate:"required"`           // Book copy ID
-	ISBN        string `json:"isbn" db:"ISBN" example:"978-0-123456-78-9" validate:"required"`             // Book ISBN
-	MaCN        string `json:"maCN" db:"MaCN" example:"Q1" validate:"required"`                            // Branch code
-	TinhTrang   string `json:"tinhTrang" db:"TinhTrang" example:"Có sẵn" enums:"Có sẵn,Đang mượn,Bị hỏng"` // Book status
+	MaQuyenSach string     `json:"maQuyenSach" db:"MaQuyenSach" example:"QS001" validate:"required"`                                                                                                 // Book copy ID
+	ISBN        string     `json:"isbn" db:"ISBN" example:"978-0-123456-78-9" validate:"required"`                                                                                                   // Book ISBN
+	MaCN        string     `json:"maCN" db:"MaCN" example:"Q1" validate:"required"`                                                                                                                  // Branch code
+	TinhTrang   CopyStatus `json:"tinhTrang" db:"TinhTrang" example:"Có sẵn" enums:"Có sẵn,Đang được mượn,Đang giữ chỗ,Đang vận chuyển,Mượn liên thư viện,Bị hỏng,Đang sửa chữa,Bị mất,Đã thanh lý"` // Book status
+}
+
+// CopyStatus is the state of a book copy (QUYENSACH.TinhTrang)
+type CopyStatus string
+
+const (
+	CopyAvailable  CopyStatus = "Có sẵn"             // On the shelf, free to lend
+	CopyOnLoan     CopyStatus = "Đang được mượn"     // Lent to a reader
+	CopyOnHold     CopyStatus = "Đang giữ chỗ"       // Set aside for a ready hold
+	CopyInTransit  CopyStatus = "Đang vận chuyển"    // Shipped between branches
+	CopyAtBorrower CopyStatus = "Mượn liên thư viện" // At the branch that requested it through an inter-library loan
+	CopyDamaged    CopyStatus = "Bị hỏng"            // Out of circulation until repaired
+	CopyInRepair   CopyStatus = "Đang sửa chữa"      // Being repaired
+	CopyLost       CopyStatus = "Bị mất"             // Lost by a reader or missing from the shelf
+	CopyWithdrawn  CopyStatus = "Đã thanh lý"        // Removed from circulation for good
+)
+
+// copyTransitions lists the statuses a copy may move to from each status. Moves marked false
+// are made by circulation only (loans, returns, holds and shipments), which keeps the loan,
+// hold or request recording them; moves marked true may also be set on the copy by hand.
+var copyTransitions = map[CopyStatus]map[CopyStatus]bool{
+	CopyAvailable:  {CopyOnLoan: false, CopyOnHold: false, CopyInTransit: false, CopyDamaged: true, CopyLost: true, CopyWithdrawn: true},
+	CopyOnLoan:     {CopyAvailable: false, CopyInTransit: false, CopyLost: true},
+	CopyOnHold:     {CopyOnLoan: false, CopyAvailable: false, CopyInTransit: false},
+	CopyInTransit:  {CopyAvailable: false, CopyOnHold: false, CopyAtBorrower: false},
+	CopyAtBorrower: {CopyOnLoan: false, CopyInTransit: false},
+	CopyDamaged:    {CopyInRepair: true, CopyWithdrawn: true},
+	CopyInRepair:   {CopyAvailable: true, CopyWithdrawn: true},
+	CopyLost:       {CopyAvailable: true, CopyWithdrawn: true},
+	CopyWithdrawn:  {},
+}
+
+// CopyStatuses lists every copy status, in lifecycle order
+func CopyStatuses() []CopyStatus {
+	return []CopyStatus{CopyAvailable, CopyOnLoan, CopyOnHold, CopyInTransit, CopyAtBorrower,
+		CopyDamaged, CopyInRepair, CopyLost, CopyWithdrawn}
+}
+
+// Valid reports whether s is a known copy status
+func (s CopyStatus) Valid() bool {
+	_, known := copyTransitions[s]
+	return known
+}
+
+// CheckTransition validates a move of a copy from s to another status. Only the moves that may
+// be set by hand are allowed when manual is true.
+func (s CopyStatus) CheckTransition(to CopyStatus, manual bool) error {
+	reason := ""
+	byHand, allowed := copyTransitions[s][to]
+	switch {
+	case !to.Valid():
+		reason = fmt.Sprintf("unknown copy status %q", to)
+	case !allowed:
+		reason = fmt.Sprintf("a copy cannot go from %s to %s", s, to)
+	case manual && !byHand:
+		reason = fmt.Sprintf("a copy goes from %s to %s through circulation only", s, to)
+	default:
+		return nil
+	}
+	return &TransitionError{From: s, To: to, Allowed: s.NextCopyStatuses(true), Reason: reason}
+}
+
+// NextCopyStatuses lists the statuses a copy may be moved to from s, by hand only if manual is true
+func (s CopyStatus) NextCopyStatuses(manual bool) []CopyStatus {
+	var next []CopyStatus
+	for _, to := range CopyStatuses() {
+		if byHand, allowed := copyTransitions[s][to]; allowed && (byHand || !manual) {
+			next = append(next, to)
+		}
+	}
+	return next
 }
 
 // DocGia - Horizontally Fragmented by MaCN_DangKy
@@ -35,8 +107,12 @@ type DocGia struct {
 	MaDG       string `json:"maDG" db:"MaDG" example:"DG001" validate:"required"`           // Reader ID
 	HoTen      string `json:"hoTen" db:"HoTen" example:"Nguyễn Văn B" validate:"required"`  // Reader name
 	MaCNDangKy string `json:"maCNDangKy" db:"MaCN_DangKy" example:"Q1" validate:"required"` // Registration branch
+	LoaiDG     string `json:"loaiDG" db:"LoaiDG" example:"Thường"`                          // Reader category, chooses the circulation policy
 }
 
+// DefaultReaderCategory is the category of readers registered without one
+const DefaultReaderCategory = "Thường"
+
 // PhieuMuon - Horizontally Fragmented by MaCN
 // @Description Borrow transaction (fragmented by branch)
 type PhieuMuon struct {
@@ -46,8 +122,146 @@ type PhieuMuon struct {
 	MaCN        string     `json:"maCN" db:"MaCN" example:"Q1" validate:"required"`                  // Branch code
 	NgayMuon    time.Time  `json:"ngayMuon" db:"NgayMuon" example:"2025-01-15T10:00:00Z"`            // Borrow date
 	NgayTra     *time.Time `json:"ngayTra" db:"NgayTra" example:"2025-01-20T14:00:00Z"`              // Return date (null if not returned)
+	HanTra      time.Time  `json:"hanTra" db:"HanTra" example:"2025-02-14T10:00:00Z"`                // Due date, pushed back by renewals
+	SoLanGiaHan int        `json:"soLanGiaHan" db:"SoLanGiaHan" example:"0"`                         // Renewals so far
+}
+
+// Hold states (DATCHO.TrangThai)
+const (
+	HoldWaiting   = "Đang chờ"        // In the queue for the title
+	HoldInTransit = "Đang vận chuyển" // A copy of another branch is on its way to the pickup branch
+	HoldReady     = "Sẵn sàng"        // A copy is set aside until HanNhan
+	HoldFulfilled = "Đã nhận"         // The reader borrowed the copy
+	HoldExpired   = "Hết hạn"         // The copy was not picked up in time
+	HoldCancelled = "Đã hủy"
+)
+
+// DatCho - Horizontally Fragmented by MaCN (branch where the hold was placed)
+// @Description Hold queueing a reader for a title
+type DatCho struct {
+	MaDC          string     `json:"maDC" db:"MaDC" example:"Q1-m2x8k1"`                                                                           // Hold ID
+	MaDG          string     `json:"maDG" db:"MaDG" example:"DG001" validate:"required"`                                                           // Reader ID
+	ISBN          string     `json:"isbn" db:"ISBN" example:"978-0-123456-78-9" validate:"required"`                                               // Title held
+	MaCN          string     `json:"maCN" db:"MaCN" example:"Q1"`                                                                                  // Branch where the hold was placed
+	MaCNNhanSach  string     `json:"maCNNhanSach,omitempty" db:"MaCN_NhanSach" example:"Q3"`                                                       // Pickup branch (empty: any branch)
+	NgayDat       time.Time  `json:"ngayDat" db:"NgayDat" example:"2025-01-15T10:00:00Z"`                                                          // Queue order
+	TrangThai     string     `json:"trangThai" db:"TrangThai" example:"Đang chờ" enums:"Đang chờ,Đang vận chuyển,Sẵn sàng,Đã nhận,Hết hạn,Đã hủy"` // Hold state
+	MaQuyenSach   string     `json:"maQuyenSach,omitempty" db:"MaQuyenSach" example:"QS001"`                                                       // Copy set aside once ready or shipped
+	MaCNQuyenSach string     `json:"maCNQuyenSach,omitempty" db:"MaCN_QuyenSach" example:"Q1"`                                                     // Branch storing that copy
+	HanNhan       *time.Time `json:"hanNhan,omitempty" db:"HanNhan" example:"2025-01-18T10:00:00Z"`                                                // Pickup deadline once ready
+	ViTri         int        `json:"viTri,omitempty" example:"2"`                                                                                  // Position in the queue while waiting
 }
 
+// Inter-library loan states (YEUCAUMUON.TrangThai)
+const (
+	ILLRequested = "Đã yêu cầu"      // Waiting for the owning branch to ship a copy
+	ILLInTransit = "Đang chuyển đến" // Shipped to the requesting branch
+	ILLArrived   = "Đã đến"          // At the requesting branch, waiting for the reader
+	ILLOnLoan    = "Đang cho mượn"   // Lent to the reader by the requesting branch
+	ILLReturning = "Đang trả về"     // Shipped back to the owning branch
+	ILLCompleted = "Hoàn tất"        // Back on the owning branch's shelf
+	ILLCancelled = "Đã hủy"
+)
+
+// YeuCauMuon - Horizontally Fragmented by MaCN (requesting branch)
+// @Description Inter-library loan of a copy owned by another branch. The copy stays in its owner's fragment throughout.
+type YeuCauMuon struct {
+	MaYC        string    `json:"maYC" db:"MaYC" example:"Q3-m2x8k1"`                                                                                                // Request ID
+	MaDG        string    `json:"maDG" db:"MaDG" example:"DG004"`                                                                                                    // Reader the copy is for
+	ISBN        string    `json:"isbn" db:"ISBN" example:"978-0-123456-78-9"`                                                                                        // Title requested
+	MaCN        string    `json:"maCN" db:"MaCN" example:"Q3"`                                                                                                       // Requesting branch, which lends the copy
+	MaCNSoHuu   string    `json:"maCNSoHuu" db:"MaCN_SoHuu" example:"Q1"`                                                                                            // Owning branch, which ships the copy
+	MaQuyenSach string    `json:"maQuyenSach,omitempty" db:"MaQuyenSach" example:"QS001"`                                                                            // Copy shipped, once shipped
+	TrangThai   string    `json:"trangThai" db:"TrangThai" example:"Đã yêu cầu" enums:"Đã yêu cầu,Đang chuyển đến,Đã đến,Đang cho mượn,Đang trả về,Hoàn tất,Đã hủy"` // Request state
+	NgayYeuCau  time.Time `json:"ngayYeuCau" db:"NgayYeuCau" example:"2025-01-15T10:00:00Z"`                                                                         // Request date
+	NgayCapNhat time.Time `json:"ngayCapNhat" db:"NgayCapNhat" example:"2025-01-16T09:00:00Z"`                                                                       // Date of the last step
+}
+
+// Return transfer states (CHUYENTRA.TrangThai)
+const (
+	TransferInTransit = "Đang vận chuyển" // On its way home, or to the pickup branch of a hold
+	TransferReceived  = "Đã nhận"         // Checked in where it was shipped to
+)
+
+// ChuyenTra - Horizontally Fragmented by MaCN (home branch of the copy)
+// @Description Copy returned at a branch other than its own, shipped back to its home branch, or copy set aside for a hold, shipped to the hold's pickup branch
+type ChuyenTra struct {
+	MaCT         string     `json:"maCT" db:"MaCT" example:"Q1-m2x8k1"`                                                 // Transfer ID
+	MaQuyenSach  string     `json:"maQuyenSach" db:"MaQuyenSach" example:"QS001"`                                       // Copy shipped
+	ISBN         string     `json:"isbn" db:"ISBN" example:"978-0-123456-78-9"`                                         // Title of the copy
+	MaCN         string     `json:"maCN" db:"MaCN" example:"Q1"`                                                        // Home branch, which checks the copy in unless shipped for a hold
+	MaCNNhanTra  string     `json:"maCNNhanTra" db:"MaCN_NhanTra" example:"Q3"`                                         // Branch that ships the copy: where it was returned, or its home branch for a hold
+	MaCNNhanSach string     `json:"maCNNhanSach,omitempty" db:"MaCN_NhanSach" example:"Q3"`                             // Pickup branch the copy is shipped to for a hold, which checks it in
+	MaDC         string     `json:"maDC,omitempty" db:"MaDC" example:"Q3-m2x8k1"`                                       // Hold the copy is shipped for
+	TrangThai    string     `json:"trangThai" db:"TrangThai" example:"Đang vận chuyển" enums:"Đang vận chuyển,Đã nhận"` // Transfer state
+	NgayNhanTra  time.Time  `json:"ngayNhanTra" db:"NgayNhanTra" example:"2025-01-15T10:00:00Z"`                        // Date the copy was returned or set aside for the hold
+	NgayNhan     *time.Time `json:"ngayNhan,omitempty" db:"NgayNhan" example:"2025-01-16T09:00:00Z"`                    // Check-in date
+}
+
+// Fine states (PHAT.TrangThai)
+const (
+	FineUnpaid = "Chưa thanh toán"
+	FinePaid   = "Đã thanh toán" // Settled, at least partly by payment
+	FineWaived = "Đã miễn"       // Settled by waivers alone
+)
+
+// Fine transaction kinds (GIAODICHPHAT.Loai)
+const (
+	FinePayment = "Thanh toán"
+	FineWaiver  = "Miễn"
+)
+
+// Phat - Horizontally Fragmented by MaCN (branch that made the loan)
+// @Description Overdue fine assessed when a late loan is returned. Amounts are in VND.
+type Phat struct {
+	MaPhat       string         `json:"maPhat" db:"MaPhat" example:"Q1-m2x8k1"`                                                           // Fine ID
+	MaDG         string         `json:"maDG" db:"MaDG" example:"DG001"`                                                                   // Reader ID
+	MaQuyenSach  string         `json:"maQuyenSach" db:"MaQuyenSach" example:"QS001"`                                                     // Book copy of the loan
+	MaCN         string         `json:"maCN" db:"MaCN" example:"Q1"`                                                                      // Branch that made the loan
+	NgayMuon     time.Time      `json:"ngayMuon" db:"NgayMuon" example:"2025-01-15T10:00:00Z"`                                            // Borrow date
+	HanTra       time.Time      `json:"hanTra" db:"HanTra" example:"2025-02-14T10:00:00Z"`                                                // Due date
+	NgayTra      time.Time      `json:"ngayTra" db:"NgayTra" example:"2025-02-20T14:00:00Z"`                                              // Return date
+	SoNgayQuaHan int            `json:"soNgayQuaHan" db:"SoNgayQuaHan" example:"6"`                                                       // Days overdue
+	MucPhat      int            `json:"mucPhat" db:"MucPhat" example:"5000"`                                                              // Daily rate of the branch at return
+	SoTien       int            `json:"soTien" db:"SoTien" example:"30000"`                                                               // Amount assessed
+	DaThanhToan  int            `json:"daThanhToan" db:"DaThanhToan" example:"10000"`                                                     // Amount paid
+	DaMien       int            `json:"daMien" db:"DaMien" example:"0"`                                                                   // Amount waived
+	ConLai       int            `json:"conLai" example:"20000"`                                                                           // Amount outstanding
+	TrangThai    string         `json:"trangThai" db:"TrangThai" example:"Chưa thanh toán" enums:"Chưa thanh toán,Đã thanh toán,Đã miễn"` // Fine state
+	NgayTao      time.Time      `json:"ngayTao" db:"NgayTao" example:"2025-02-20T14:00:00Z"`                                              // Assessment date
+	GiaoDich     []GiaoDichPhat `json:"giaoDich,omitempty"`                                                                               // Payments and waivers, oldest first
+}
+
+// GiaoDichPhat - Horizontally Fragmented by MaCN (branch of the fine)
+// @Description Payment or waiver recorded against a fine
+type GiaoDichPhat struct {
+	MaGD          string    `json:"maGD" db:"MaGD" example:"Q1-m2x9a4"`                                       // Transaction ID
+	MaPhat        string    `json:"maPhat" db:"MaPhat" example:"Q1-m2x8k1"`                                   // Fine ID
+	MaCN          string    `json:"maCN" db:"MaCN" example:"Q1"`                                              // Branch of the fine
+	Loai          string    `json:"loai" db:"Loai" example:"Thanh toán" enums:"Thanh toán,Miễn"`              // Payment or waiver
+	SoTien        int       `json:"soTien" db:"SoTien" example:"10000"`                                       // Amount
+	NgayGD        time.Time `json:"ngayGD" db:"NgayGD" example:"2025-02-21T09:00:00Z"`                        // Date recorded
+	NguoiThucHien string    `json:"nguoiThucHien" db:"NguoiThucHien" example:"thuthu01"`                      // Librarian who recorded it
+	GhiChu        string    `json:"ghiChu,omitempty" db:"GhiChu" example:"Miễn do sách trả qua hộp trả sách"` // Note or waiver reason
+}
+
+// ChinhSach - Fully Replicated table
+// @Description Circulation policy of a branch and reader category. A policy without a branch applies at every branch,
+// @Description one without a category to every reader category; the most specific policy applies. Amounts are in VND.
+type ChinhSach struct {
+	MaCS        string `json:"maCS" db:"MaCS" example:"CS-Q1-SV"`                // Policy ID
+	MaCN        string `json:"maCN,omitempty" db:"MaCN" example:"Q1"`            // Branch; empty for every branch
+	LoaiDG      string `json:"loaiDG,omitempty" db:"LoaiDG" example:"Sinh viên"` // Reader category; empty for every category
+	SoNgayMuon  int    `json:"soNgayMuon" db:"SoNgayMuon" example:"14"`          // Loan length in days
+	SoSachToiDa int    `json:"soSachToiDa" db:"SoSachToiDa" example:"5"`         // Loans a reader may have at once, at every branch
+	SoLanGiaHan int    `json:"soLanGiaHan" db:"SoLanGiaHan" example:"1"`         // Renewals allowed per loan
+	MucPhat     int    `json:"mucPhat" db:"MucPhat" example:"3000"`              // Fine per day overdue
+	SoNgayAnHan int    `json:"soNgayAnHan" db:"SoNgayAnHan" example:"2"`         // Days overdue not charged
+}
+
+// DefaultPolicyID identifies the configured defaults, which apply where no policy matches
+const DefaultPolicyID = "default"
+
 // User authentication model
 // @Description User account for authentication
 type User struct {
@@ -71,9 +285,39 @@ type UserInfo struct {
 // BookSearchResult - DTO for book search across all sites
 // @Description Book search result with availability across branches
 type BookSearchResult struct {
-	Sach      Sach       `json:"sach"`                  // Book information
-	ChiNhanh  []ChiNhanh `json:"chiNhanh"`              // Available branches
-	SoLuongCo int        `json:"soLuongCo" example:"5"` // Total available copies
+	Sach      Sach       `json:"sach"`                            // Book information
+	ChiNhanh  []ChiNhanh `json:"chiNhanh"`                        // Available branches
+	SoLuongCo int        `json:"soLuongCo" example:"5"`           // Total available copies
+	Score     float64    `json:"score,omitempty" example:"2.317"` // Search relevance, higher is better
+}
+
+// ScoredSach - DTO for catalog search
+// @Description Book matched by a catalog search with its relevance
+type ScoredSach struct {
+	Sach
+	Score float64 `json:"score,omitempty" example:"2.317"` // Search relevance, higher is better
+}
+
+// ReaderMatch - DTO for fuzzy reader search
+// @Description Reader found by an approximate name or partial ID
+type ReaderMatch struct {
+	DocGia
+	Score float64 `json:"score" example:"0.917"` // Similarity from 0 to 1, higher is better
+}
+
+// ReaderMatchGroup - DTO for fuzzy reader search
+// @Description Readers found by a fuzzy search registered at one branch
+type ReaderMatchGroup struct {
+	MaCN    string         `json:"maCN" example:"Q1"`
+	TenCN   string         `json:"tenCN" example:"Chi nhánh Quận 1"`
+	Readers []*ReaderMatch `json:"readers"` // Best matches first
+}
+
+// ReaderSearchResult - DTO for fuzzy reader search
+// @Description Page of fuzzy reader search matches, grouped by registration branch
+type ReaderSearchResult struct {
+	Total  int                 `json:"total" example:"3"` // Matches over all pages
+	Groups []*ReaderMatchGroup `json:"groups"`            // Branches in the order of their best match on the page
 }
 
 // SystemStats - System-wide statistics
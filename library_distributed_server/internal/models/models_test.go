@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    CopyStatus
+		to      CopyStatus
+		manual  bool
+		allowed bool
+	}{
+		{"lend", CopyAvailable, CopyOnLoan, false, true},
+		{"lend by hand", CopyAvailable, CopyOnLoan, true, false},
+		{"damage by hand", CopyAvailable, CopyDamaged, true, true},
+		{"lose a lent copy by hand", CopyOnLoan, CopyLost, true, true},
+		{"repair a damaged copy", CopyDamaged, CopyInRepair, true, true},
+		{"shelve a repaired copy", CopyInRepair, CopyAvailable, true, true},
+		{"find a lost copy", CopyLost, CopyAvailable, true, true},
+		{"skip the repair", CopyDamaged, CopyAvailable, true, false},
+		{"repair a lent copy", CopyOnLoan, CopyInRepair, false, false},
+		{"check in a shipped copy", CopyInTransit, CopyAvailable, false, true},
+		{"check in a shipped copy by hand", CopyInTransit, CopyAvailable, true, false},
+		{"arrive at the borrower", CopyInTransit, CopyAtBorrower, false, true},
+		{"arrive at the pickup branch", CopyInTransit, CopyOnHold, false, true},
+		{"lend a held copy", CopyOnHold, CopyOnLoan, false, true},
+		{"ship a held copy home", CopyOnHold, CopyInTransit, false, true},
+		{"ship a held copy by hand", CopyOnHold, CopyInTransit, true, false},
+		{"damage a held copy", CopyOnHold, CopyDamaged, true, false},
+		{"revive a withdrawn copy", CopyWithdrawn, CopyAvailable, true, false},
+		{"unknown target", CopyAvailable, CopyStatus("Đang mượn"), false, false},
+		{"unknown source", CopyStatus("Đang mượn"), CopyAvailable, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.from.CheckTransition(tt.to, tt.manual)
+			if tt.allowed {
+				if err != nil {
+					t.Fatalf("CheckTransition(%s -> %s, manual=%v) = %v, want nil", tt.from, tt.to, tt.manual, err)
+				}
+				return
+			}
+
+			var transition *TransitionError
+			if !errors.As(err, &transition) {
+				t.Fatalf("CheckTransition(%s -> %s, manual=%v) = %v, want *TransitionError", tt.from, tt.to, tt.manual, err)
+			}
+			if transition.From != tt.from || transition.To != tt.to {
+				t.Errorf("TransitionError is %s -> %s, want %s -> %s", transition.From, transition.To, tt.from, tt.to)
+			}
+			if transition.Reason == "" {
+				t.Error("TransitionError has no reason")
+			}
+		})
+	}
+}
+
+func TestNextCopyStatuses(t *testing.T) {
+	tests := []struct {
+		from   CopyStatus
+		manual bool
+		want   []CopyStatus
+	}{
+		{CopyAvailable, true, []CopyStatus{CopyDamaged, CopyLost, CopyWithdrawn}},
+		{CopyAvailable, false, []CopyStatus{CopyOnLoan, CopyOnHold, CopyInTransit, CopyDamaged, CopyLost, CopyWithdrawn}},
+		{CopyOnLoan, true, []CopyStatus{CopyLost}},
+		{CopyInTransit, true, nil},
+		{CopyWithdrawn, false, nil},
+	}
+
+	for _, tt := range tests {
+		got := tt.from.NextCopyStatuses(tt.manual)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s.NextCopyStatuses(%v) = %v, want %v", tt.from, tt.manual, got, tt.want)
+		}
+	}
+}
+
+func TestCopyStatusesCoverTransitions(t *testing.T) {
+	statuses := CopyStatuses()
+	if len(statuses) != len(copyTransitions) {
+		t.Fatalf("CopyStatuses lists %d statuses, copyTransitions has %d", len(statuses), len(copyTransitions))
+	}
+	for _, status := range statuses {
+		if !status.Valid() {
+			t.Errorf("%s is listed but not valid", status)
+		}
+		for to := range copyTransitions[status] {
+			if !to.Valid() {
+				t.Errorf("%s moves to unknown status %s", status, to)
+			}
+		}
+	}
+}
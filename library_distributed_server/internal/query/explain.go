@@ -0,0 +1,165 @@
+package query
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Step is one query an operation ships to the sites, e.g. the rows of a page and their count
+type Step struct {
+	Purpose string
+	Query   Query
+}
+
+// Statement is the SQL of one step as shipped to one site
+type Statement struct {
+	Purpose       string        `json:"purpose" example:"rows"`
+	SQL           string        `json:"sql"`
+	Args          []interface{} `json:"args"`
+	EstimatedRows *float64      `json:"estimatedRows,omitempty" example:"120"`          // From the site's query optimizer statistics
+	EstimateError string        `json:"estimateError,omitempty" example:"site is down"` // Why no estimate is available
+}
+
+// SiteExplanation lists the statements shipped to one site
+type SiteExplanation struct {
+	SiteID     string      `json:"siteId" example:"Q3"`
+	Statements []Statement `json:"statements"`
+}
+
+// Transfer is a result moving from the site computing it to the merge site
+type Transfer struct {
+	From          string   `json:"from" example:"Q3"`
+	To            string   `json:"to" example:"Q1"`
+	EstimatedRows *float64 `json:"estimatedRows,omitempty" example:"120"`
+}
+
+// Explanation describes how an operation would be executed, without executing it
+type Explanation struct {
+	Operation    string            `json:"operation" example:"Search available books"`
+	Relation     string            `json:"relation" example:"QUYENSACH"`
+	Sites        []SiteExplanation `json:"sites"`  // Sites contacted and the SQL shipped to each
+	Pruned       []string          `json:"pruned"` // Sites skipped by fragment pruning
+	MergeSite    string            `json:"mergeSite" example:"Q1"`
+	Merge        string            `json:"merge" example:"k-way merge on d.MaDG"`
+	DataMovement []Transfer        `json:"dataMovement"` // Empty when every contacted site is the merge site
+}
+
+// Explain plans the steps of an operation and asks each site for its row estimates.
+// The merge happens at the executor's local site, which serves the request.
+func Explain(ctx context.Context, e *Executor, operation, merge string, steps ...Step) (*Explanation, error) {
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("operation %s has no steps to explain", operation)
+	}
+
+	explanation := &Explanation{
+		Operation:    operation,
+		Relation:     steps[0].Query.Relation,
+		MergeSite:    e.local,
+		Merge:        merge,
+		Pruned:       []string{},
+		DataMovement: []Transfer{},
+	}
+
+	bySite := make(map[string]*SiteExplanation)
+	var order []string
+	for i, step := range steps {
+		plan, err := e.Plan(step.Query)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 && plan.Pruned != nil {
+			explanation.Pruned = plan.Pruned
+		}
+
+		for _, sp := range plan.Sites {
+			site, exists := bySite[sp.SiteID]
+			if !exists {
+				site = &SiteExplanation{SiteID: sp.SiteID}
+				bySite[sp.SiteID] = site
+				order = append(order, sp.SiteID)
+			}
+
+			statement := Statement{Purpose: step.Purpose, SQL: sp.SQL, Args: sp.Args}
+			if rows, err := e.estimate(ctx, sp); err != nil {
+				statement.EstimateError = err.Error()
+			} else {
+				statement.EstimatedRows = &rows
+			}
+			site.Statements = append(site.Statements, statement)
+		}
+	}
+
+	for _, siteID := range order {
+		site := bySite[siteID]
+		explanation.Sites = append(explanation.Sites, *site)
+		if siteID != e.local {
+			explanation.DataMovement = append(explanation.DataMovement, Transfer{
+				From:          siteID,
+				To:            e.local,
+				EstimatedRows: site.Statements[0].EstimatedRows,
+			})
+		}
+	}
+	return explanation, nil
+}
+
+var (
+	selectStatement = regexp.MustCompile(`<StmtSimple [^>]*StatementType="SELECT"[^>]*>`)
+	estimatedRows   = regexp.MustCompile(`StatementEstRows="([^"]+)"`)
+)
+
+// estimate asks a site's optimizer for the estimated row count of a statement.
+// SHOWPLAN_XML compiles the statement without running it.
+func (e *Executor) estimate(ctx context.Context, sp SitePlan) (float64, error) {
+	db, err := e.connect(sp.SiteID)
+	if err != nil {
+		return 0, err
+	}
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SET SHOWPLAN_XML ON"); err != nil {
+		return 0, fmt.Errorf("failed to enable showplan: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.Background(), "SET SHOWPLAN_XML OFF"); err != nil {
+			// Never return a connection still in showplan mode to the pool
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+	}()
+
+	var plan string
+	if err := conn.QueryRowContext(ctx, sp.SQL, sp.Args...).Scan(&plan); err != nil {
+		return 0, fmt.Errorf("failed to get showplan: %w", err)
+	}
+
+	// The plan of a parameterized statement may also contain the wrapping EXEC; use the last SELECT
+	statements := selectStatement.FindAllString(plan, -1)
+	if len(statements) == 0 {
+		return 0, fmt.Errorf("showplan has no SELECT statement")
+	}
+	match := estimatedRows.FindStringSubmatch(statements[len(statements)-1])
+	if match == nil {
+		return 0, fmt.Errorf("showplan has no row estimate")
+	}
+	return strconv.ParseFloat(strings.TrimSpace(match[1]), 64)
+}
+
+// ExplainPage returns the steps Paginate ships for one page: the page rows and their count
+func ExplainPage(q Query, keys []SortKey, req PageRequest) ([]Step, error) {
+	paged, _, err := pageQuery(q, keys, req)
+	if err != nil {
+		return nil, err
+	}
+	return []Step{
+		{Purpose: "rows", Query: paged},
+		{Purpose: "count", Query: CountQuery(q)},
+	}, nil
+}
@@ -0,0 +1,286 @@
+package query
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned for a cursor token that was not issued for the query
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SortKey is one column of the global order of a paginated query. The keys together
+// must identify a row uniquely, and their values must compare in Go as they do in SQL,
+// so codes, numbers and dates are suitable but collation-sensitive text is not.
+type SortKey struct {
+	Column string // As written in ORDER BY, e.g. "d.MaDG"
+	Desc   bool
+}
+
+// PageRequest selects a page by offset, or by the cursor returned with the previous page
+type PageRequest struct {
+	Offset int
+	Size   int
+	Cursor string
+}
+
+// Page is one page of a globally ordered query
+type Page[T any] struct {
+	Items      []T
+	Total      int              // Sum of the per-site counts of the unpaginated query
+	NextCursor string           // Empty on the last page
+	Failed     map[string]error // Sites that could not be queried
+}
+
+// Paginate returns one page of a query ordered by keys across all relevant sites.
+// Each site returns only the rows that can appear on the page (ORDER BY with
+// OFFSET/FETCH, plus a keyset predicate when a cursor is given); the sorted
+// streams are merged and the page is cut from the merged stream. values returns
+// the sort key values of a row in key order.
+func Paginate[T any](ctx context.Context, e *Executor, q Query, keys []SortKey, scan func(*sql.Rows) (T, error), values func(T) []interface{}, req PageRequest) (*Page[T], error) {
+	paged, skip, err := pageQuery(q, keys, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Count concurrently with fetching the page
+	type countResult struct {
+		total int
+		err   error
+	}
+	counted := make(chan countResult, 1)
+	go func() {
+		total, _, err := Count(ctx, e, q)
+		counted <- countResult{total, err}
+	}()
+
+	result, err := Merge(ctx, e, paged, scan, keyOrder(keys, values))
+	count := <-counted
+	if err != nil {
+		return nil, err
+	}
+	if count.err != nil {
+		return nil, count.err
+	}
+
+	page := &Page[T]{Total: count.total, Failed: result.Failed, Items: []T{}}
+	if skip < len(result.Rows) {
+		page.Items = result.Rows[skip:]
+	}
+	if len(page.Items) > req.Size {
+		page.Items = page.Items[:req.Size]
+		page.NextCursor, err = encodeCursor(values(page.Items[req.Size-1]))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// keyOrder orders rows by the sort keys, as the paginated query's ORDER BY does
+func keyOrder[T any](keys []SortKey, values func(T) []interface{}) func(a, b T) bool {
+	return func(a, b T) bool {
+		va, vb := values(a), values(b)
+		for i, key := range keys {
+			c := compareValues(va[i], vb[i])
+			if c == 0 {
+				continue
+			}
+			if key.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	}
+}
+
+// pageQuery pushes the page down to the sites: each site returns its rows in key
+// order, after the cursor or up to the end of the requested page, plus one row to
+// detect a following page. skip is the number of merged rows before the page.
+func pageQuery(q Query, keys []SortKey, req PageRequest) (Query, int, error) {
+	if len(keys) == 0 {
+		return q, 0, fmt.Errorf("paginated query over %s has no sort keys", q.Relation)
+	}
+	if req.Size <= 0 {
+		return q, 0, fmt.Errorf("page size must be positive")
+	}
+
+	paged := q
+	paged.Filters = append([]Predicate(nil), q.Filters...)
+	paged.OrderBy = orderBy(keys)
+	if req.Cursor != "" {
+		after, err := decodeCursor(req.Cursor, len(keys))
+		if err != nil {
+			return q, 0, err
+		}
+		paged.Filters = append(paged.Filters, after.predicate(keys))
+		paged.Limit = req.Size + 1
+		return paged, 0, nil
+	}
+	paged.Limit = req.Offset + req.Size + 1
+	return paged, req.Offset, nil
+}
+
+// CountQuery returns a query counting the rows of q at each site it is shipped to
+func CountQuery(q Query) Query {
+	counting := q
+	counting.OrderBy, counting.Limit = "", 0
+	counting.wrap = "SELECT COUNT(*) FROM (%s) AS counted"
+	return counting
+}
+
+// Count sums the per-site row counts of the query
+func Count(ctx context.Context, e *Executor, q Query) (int, map[string]error, error) {
+	result, err := Union(ctx, e, CountQuery(q), func(rows *sql.Rows) (int, error) {
+		var n int
+		if err := rows.Scan(&n); err != nil {
+			return 0, fmt.Errorf("failed to scan count: %w", err)
+		}
+		return n, nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	total := 0
+	for _, n := range result.Rows {
+		total += n
+	}
+	return total, result.Failed, nil
+}
+
+// orderBy renders the ORDER BY list of the sort keys
+func orderBy(keys []SortKey) string {
+	columns := make([]string, len(keys))
+	for i, key := range keys {
+		columns[i] = key.Column
+		if key.Desc {
+			columns[i] += " DESC"
+		}
+	}
+	return strings.Join(columns, ", ")
+}
+
+// cursor holds the sort key values of the last row of a page
+type cursor []interface{}
+
+// predicate selects the rows after the cursor in key order:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+func (c cursor) predicate(keys []SortKey) Predicate {
+	var terms []string
+	var args []interface{}
+	for i, key := range keys {
+		var conditions []string
+		for j := 0; j < i; j++ {
+			conditions = append(conditions, keys[j].Column+" = ?")
+			args = append(args, c[j])
+		}
+		op := ">"
+		if key.Desc {
+			op = "<"
+		}
+		conditions = append(conditions, fmt.Sprintf("%s %s ?", key.Column, op))
+		args = append(args, c[i])
+		terms = append(terms, "("+strings.Join(conditions, " AND ")+")")
+	}
+	return Where(strings.Join(terms, " OR "), args...)
+}
+
+// cursorValue is a typed sort key value in a cursor token
+type cursorValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v"`
+}
+
+// encodeCursor turns sort key values into an opaque token
+func encodeCursor(values []interface{}) (string, error) {
+	encoded := make([]cursorValue, len(values))
+	for i, v := range values {
+		switch x := v.(type) {
+		case string:
+			encoded[i] = cursorValue{"s", x}
+		case int:
+			encoded[i] = cursorValue{"i", strconv.Itoa(x)}
+		case int64:
+			encoded[i] = cursorValue{"i", strconv.FormatInt(x, 10)}
+		case time.Time:
+			encoded[i] = cursorValue{"t", x.UTC().Format(time.RFC3339Nano)}
+		default:
+			return "", fmt.Errorf("unsupported sort key type %T", v)
+		}
+	}
+	data, err := json.Marshal(encoded)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor parses a token issued by encodeCursor for a query with n sort keys
+func decodeCursor(token string, n int) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var encoded []cursorValue
+	if err := json.Unmarshal(data, &encoded); err != nil || len(encoded) != n {
+		return nil, ErrInvalidCursor
+	}
+
+	c := make(cursor, n)
+	for i, v := range encoded {
+		switch v.Type {
+		case "s":
+			c[i] = v.Value
+		case "i":
+			number, err := strconv.ParseInt(v.Value, 10, 64)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			c[i] = number
+		case "t":
+			t, err := time.Parse(time.RFC3339Nano, v.Value)
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			c[i] = t
+		default:
+			return nil, ErrInvalidCursor
+		}
+	}
+	return c, nil
+}
+
+// compareValues orders two sort key values of the same type
+func compareValues(a, b interface{}) int {
+	switch x := a.(type) {
+	case string:
+		return strings.Compare(x, b.(string))
+	case int:
+		return compareInts(int64(x), int64(b.(int)))
+	case int64:
+		return compareInts(x, b.(int64))
+	case time.Time:
+		return x.Compare(b.(time.Time))
+	default:
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
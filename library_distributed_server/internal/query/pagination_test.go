@@ -0,0 +1,269 @@
+package query
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	borrowed := time.Date(2024, 3, 1, 9, 30, 0, 0, time.FixedZone("ICT", 7*60*60))
+
+	tests := []struct {
+		name   string
+		values []interface{}
+		want   cursor
+	}{
+		{"code", []interface{}{"DG01"}, cursor{"DG01"}},
+		{"int", []interface{}{42}, cursor{int64(42)}},
+		{"int64", []interface{}{int64(-7)}, cursor{int64(-7)}},
+		{"date and code", []interface{}{borrowed, "PM01"}, cursor{borrowed.UTC(), "PM01"}},
+		{"text with separators", []interface{}{`a"b,c`}, cursor{`a"b,c`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := encodeCursor(tt.values)
+			if err != nil {
+				t.Fatalf("encodeCursor failed: %v", err)
+			}
+			got, err := decodeCursor(token, len(tt.values))
+			if err != nil {
+				t.Fatalf("decodeCursor failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCursor = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeCursorUnsupportedType(t *testing.T) {
+	if _, err := encodeCursor([]interface{}{1.5}); err == nil {
+		t.Error("encodeCursor accepted a float sort key")
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	valid, err := encodeCursor([]interface{}{"DG01", 3})
+	if err != nil {
+		t.Fatalf("encodeCursor failed: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		keys  int
+	}{
+		{"not base64", "***", 2},
+		{"not json", "bm90IGpzb24", 2},
+		{"too few keys", valid, 3},
+		{"too many keys", valid, 1},
+		{"unknown type", encodeRaw(`[{"t":"f","v":"1.5"}]`), 1},
+		{"bad int", encodeRaw(`[{"t":"i","v":"x"}]`), 1},
+		{"bad time", encodeRaw(`[{"t":"t","v":"yesterday"}]`), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.token, tt.keys); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeCursor = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestCursorPredicate(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []SortKey
+		c    cursor
+		sql  string
+		args []interface{}
+	}{
+		{
+			name: "one key",
+			keys: []SortKey{{Column: "d.MaDG"}},
+			c:    cursor{"DG05"},
+			sql:  "(d.MaDG > ?)",
+			args: []interface{}{"DG05"},
+		},
+		{
+			name: "descending date then code",
+			keys: []SortKey{{Column: "pm.NgayMuon", Desc: true}, {Column: "pm.MaPM"}},
+			c:    cursor{"2024-03-01", "PM09"},
+			sql:  "(pm.NgayMuon < ?) OR (pm.NgayMuon = ? AND pm.MaPM > ?)",
+			args: []interface{}{"2024-03-01", "2024-03-01", "PM09"},
+		},
+		{
+			name: "three keys",
+			keys: []SortKey{{Column: "a"}, {Column: "b"}, {Column: "c", Desc: true}},
+			c:    cursor{1, 2, 3},
+			sql:  "(a > ?) OR (a = ? AND b > ?) OR (a = ? AND b = ? AND c < ?)",
+			args: []interface{}{1, 1, 2, 1, 2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		sql, args := tt.c.predicate(tt.keys).SQL()
+		if sql != tt.sql || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: predicate = %q %v, want %q %v", tt.name, sql, args, tt.sql, tt.args)
+		}
+	}
+}
+
+func TestPageQuery(t *testing.T) {
+	keys := []SortKey{{Column: "pm.NgayMuon", Desc: true}, {Column: "pm.MaPM"}}
+	q := Query{Relation: "PHIEUMUON", Filters: []Predicate{Eq("pm.MaCN", "Q1")}}
+	after, err := encodeCursor([]interface{}{"2024-03-01", "PM09"})
+	if err != nil {
+		t.Fatalf("encodeCursor failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		keys    []SortKey
+		req     PageRequest
+		limit   int
+		skip    int
+		filters int
+		wantErr bool
+	}{
+		{name: "first page", keys: keys, req: PageRequest{Size: 20}, limit: 21, skip: 0, filters: 1},
+		{name: "offset", keys: keys, req: PageRequest{Offset: 40, Size: 20}, limit: 61, skip: 40, filters: 1},
+		{name: "cursor ignores offset", keys: keys, req: PageRequest{Offset: 40, Size: 20, Cursor: after}, limit: 21, skip: 0, filters: 2},
+		{name: "invalid cursor", keys: keys, req: PageRequest{Size: 20, Cursor: "***"}, wantErr: true},
+		{name: "no sort keys", req: PageRequest{Size: 20}, wantErr: true},
+		{name: "empty page", keys: keys, req: PageRequest{Size: 0}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paged, skip, err := pageQuery(q, tt.keys, tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("pageQuery error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if paged.Limit != tt.limit || skip != tt.skip {
+				t.Errorf("limit %d skip %d, want limit %d skip %d", paged.Limit, skip, tt.limit, tt.skip)
+			}
+			if len(paged.Filters) != tt.filters {
+				t.Errorf("%d filters, want %d", len(paged.Filters), tt.filters)
+			}
+			if want := "pm.NgayMuon DESC, pm.MaPM"; paged.OrderBy != want {
+				t.Errorf("OrderBy = %q, want %q", paged.OrderBy, want)
+			}
+		})
+	}
+
+	if len(q.Filters) != 1 {
+		t.Errorf("pageQuery changed the query's filters: %v", q.Filters)
+	}
+}
+
+func TestCountQuery(t *testing.T) {
+	q := Query{Relation: "DOCGIA", Select: "SELECT * FROM DOCGIA", Filters: []Predicate{Eq("MaCN_DangKy", "Q1")}, OrderBy: "MaDG", Limit: 10}
+
+	sql, args := CountQuery(q).render()
+	want := "SELECT COUNT(*) FROM (SELECT * FROM DOCGIA WHERE (MaCN_DangKy = ?)) AS counted"
+	if sql != want || !reflect.DeepEqual(args, []interface{}{"Q1"}) {
+		t.Errorf("CountQuery = %q %v, want %q [Q1]", sql, args, want)
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	earlier := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	tests := []struct {
+		a, b interface{}
+		want int
+	}{
+		{"DG01", "DG02", -1},
+		{"DG10", "DG02", 1},
+		{"DG01", "DG01", 0},
+		{2, 10, -1},
+		{int64(10), int64(2), 1},
+		{int64(3), int64(3), 0},
+		{earlier, later, -1},
+		{later, earlier, 1},
+		{later, later, 0},
+	}
+
+	for _, tt := range tests {
+		if got := compareValues(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareValues(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+type loan struct {
+	date string
+	id   string
+}
+
+func TestMergeRows(t *testing.T) {
+	keys := []SortKey{{Column: "NgayMuon", Desc: true}, {Column: "MaPM"}}
+	less := keyOrder(keys, func(l loan) []interface{} { return []interface{}{l.date, l.id} })
+
+	tests := []struct {
+		name    string
+		results []siteRows[loan]
+		want    []loan
+	}{
+		{
+			name: "interleaved sites",
+			results: []siteRows[loan]{
+				{siteID: "Q1", rows: []loan{{"2024-03-05", "PM01"}, {"2024-03-02", "PM03"}}},
+				{siteID: "Q3", rows: []loan{{"2024-03-04", "PM02"}, {"2024-03-01", "PM04"}}},
+			},
+			want: []loan{{"2024-03-05", "PM01"}, {"2024-03-04", "PM02"}, {"2024-03-02", "PM03"}, {"2024-03-01", "PM04"}},
+		},
+		{
+			name: "ties broken by the second key",
+			results: []siteRows[loan]{
+				{siteID: "Q1", rows: []loan{{"2024-03-05", "PM07"}}},
+				{siteID: "Q3", rows: []loan{{"2024-03-05", "PM02"}, {"2024-03-05", "PM09"}}},
+			},
+			want: []loan{{"2024-03-05", "PM02"}, {"2024-03-05", "PM07"}, {"2024-03-05", "PM09"}},
+		},
+		{
+			name: "failed site skipped",
+			results: []siteRows[loan]{
+				{siteID: "Q1", rows: []loan{{"2024-03-05", "PM01"}}},
+				{siteID: "Q3", rows: []loan{{"2024-03-06", "PM02"}}, err: errors.New("timeout")},
+				{siteID: "Q5", rows: []loan{{"2024-03-04", "PM03"}}},
+			},
+			want: []loan{{"2024-03-05", "PM01"}, {"2024-03-04", "PM03"}},
+		},
+		{
+			name: "empty site",
+			results: []siteRows[loan]{
+				{siteID: "Q1"},
+				{siteID: "Q3", rows: []loan{{"2024-03-06", "PM02"}}},
+			},
+			want: []loan{{"2024-03-06", "PM02"}},
+		},
+		{
+			name:    "no rows",
+			results: []siteRows[loan]{{siteID: "Q1"}, {siteID: "Q3", err: errors.New("timeout")}},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeRows(tt.results, less); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeRows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func encodeRaw(data string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(data))
+}
@@ -0,0 +1,436 @@
+// Package query runs a read over one global relation against the sites storing its
+// fragments. Filters on the fragment key prune sites whose fragment predicate cannot
+// match; the remaining sites are queried in parallel and their rows are combined.
+package query
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"library_distributed_server/internal/catalog"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Predicate is one condition of a query's WHERE clause
+type Predicate struct {
+	Column string        // Column, optionally qualified with the relation alias
+	Op     string        // "=", "IN", "LIKE" or "" for a raw condition
+	Values []interface{} // Compared values, or the arguments of a raw condition
+	raw    string
+}
+
+// Eq matches rows whose column equals value
+func Eq(column string, value interface{}) Predicate {
+	return Predicate{Column: column, Op: "=", Values: []interface{}{value}}
+}
+
+// In matches rows whose column equals one of values
+func In(column string, values ...interface{}) Predicate {
+	return Predicate{Column: column, Op: "IN", Values: values}
+}
+
+// Like matches rows whose column matches a LIKE pattern
+func Like(column string, pattern string) Predicate {
+	return Predicate{Column: column, Op: "LIKE", Values: []interface{}{pattern}}
+}
+
+// Where is a raw condition with ? placeholders. It is shipped as is and never used for pruning.
+func Where(condition string, args ...interface{}) Predicate {
+	return Predicate{Values: args, raw: condition}
+}
+
+// SQL renders the predicate and its arguments
+func (p Predicate) SQL() (string, []interface{}) {
+	switch p.Op {
+	case "":
+		return p.raw, p.Values
+	case "IN":
+		if len(p.Values) == 0 {
+			return "1 = 0", nil
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(p.Values)), ", ")
+		return fmt.Sprintf("%s IN (%s)", p.Column, placeholders), p.Values
+	default:
+		return fmt.Sprintf("%s %s ?", p.Column, p.Op), p.Values
+	}
+}
+
+// Query is a SELECT whose FROM clause ranges over the fragments of Relation.
+// Joined tables must be replicated or co-located with the relation's fragments.
+type Query struct {
+	Relation  string        // Global relation whose fragments determine the sites contacted
+	Alias     string        // Alias of Relation in Select, used to recognise fragment key filters
+	Select    string        // SELECT ... FROM ... [JOIN ...], without WHERE
+	Args      []interface{} // Arguments of placeholders in Select, bound before the filter arguments
+	Filters   []Predicate   // Combined with AND
+	Fragments []string      // Fragment key values Select restricts Relation to, e.g. in a join; prune like an IN filter
+	GroupBy   string
+	OrderBy   string // Applied per site; Merge relies on it to combine sorted streams
+	Limit     int    // Rows fetched per site when positive; requires OrderBy
+
+	wrap string // Format wrapping the rendered SQL, e.g. to count its rows
+}
+
+// SitePlan is the SQL shipped to one site
+type SitePlan struct {
+	SiteID string        `json:"siteId" example:"Q1"`
+	SQL    string        `json:"sql"`
+	Args   []interface{} `json:"args"`
+}
+
+// Plan describes how a query will be executed
+type Plan struct {
+	Relation string     `json:"relation" example:"QUYENSACH"`
+	Sites    []SitePlan `json:"sites"`
+	Pruned   []string   `json:"pruned"` // Sites skipped because no fragment they store can match
+}
+
+// Result holds the combined rows and the outcome at each site
+type Result[T any] struct {
+	Rows   []T
+	Sites  []string         // Sites queried successfully
+	Failed map[string]error // Sites that could not be queried
+}
+
+// Executor plans and runs queries against the current catalog
+type Executor struct {
+	catalog *catalog.Catalog
+	connect func(siteID string) (*sql.DB, error)
+	local   string        // Site answering queries over replicated relations
+	timeout time.Duration // Time each site has to answer; zero means no limit
+}
+
+// NewExecutor creates an executor over a catalog snapshot. local is the preferred site
+// for replicated relations, timeout bounds the work at each site and connect returns
+// a connection to a site.
+func NewExecutor(cat *catalog.Catalog, local string, timeout time.Duration, connect func(siteID string) (*sql.DB, error)) *Executor {
+	return &Executor{
+		catalog: cat,
+		connect: connect,
+		local:   local,
+		timeout: timeout,
+	}
+}
+
+// siteContext bounds the work at one site by the per-site timeout
+func (e *Executor) siteContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, e.timeout)
+}
+
+// Plan selects the sites to contact and renders the SQL shipped to them
+func (e *Executor) Plan(q Query) (*Plan, error) {
+	rel, err := e.catalog.Relation(q.Relation)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := e.catalog.SitesFor(q.Relation)
+	if err != nil {
+		return nil, err
+	}
+
+	var sites []string
+	switch rel.Type {
+	case catalog.Replicated:
+		// Any replica answers; prefer the local one
+		site, err := e.catalog.SiteFor(q.Relation, "", e.local)
+		if err != nil {
+			return nil, err
+		}
+		sites = []string{site}
+	default:
+		sites, err = e.prune(rel, q, all)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	text, args := q.render()
+	plan := &Plan{Relation: q.Relation}
+	selected := make(map[string]bool)
+	for _, siteID := range sites {
+		selected[siteID] = true
+		plan.Sites = append(plan.Sites, SitePlan{SiteID: siteID, SQL: text, Args: args})
+	}
+	for _, siteID := range all {
+		if !selected[siteID] {
+			plan.Pruned = append(plan.Pruned, siteID)
+		}
+	}
+	return plan, nil
+}
+
+// prune keeps the sites storing a fragment whose predicate can satisfy the
+// equality and IN filters on the fragment key
+func (e *Executor) prune(rel *catalog.Relation, q Query, all []string) ([]string, error) {
+	candidates := make(map[string]bool)
+	for _, siteID := range all {
+		candidates[siteID] = true
+	}
+
+	filters := q.Filters
+	if len(q.Fragments) > 0 {
+		values := make([]interface{}, len(q.Fragments))
+		for i, v := range q.Fragments {
+			values[i] = v
+		}
+		filters = append(filters[:len(filters):len(filters)], In(rel.FragmentKey, values...))
+	}
+
+	for _, p := range filters {
+		if p.Op != "=" && p.Op != "IN" {
+			continue
+		}
+		if !q.isFragmentKey(rel, p.Column) {
+			continue
+		}
+
+		matching := make(map[string]bool)
+		for _, v := range p.Values {
+			fragment, err := e.catalog.FragmentFor(rel.Name, fmt.Sprint(v))
+			if err != nil {
+				continue // No fragment holds this value, so no site can return it
+			}
+			for _, siteID := range fragment.Sites {
+				matching[siteID] = true
+			}
+		}
+		for siteID := range candidates {
+			if !matching[siteID] {
+				delete(candidates, siteID)
+			}
+		}
+	}
+
+	sites := make([]string, 0, len(candidates))
+	for siteID := range candidates {
+		sites = append(sites, siteID)
+	}
+	sort.Strings(sites)
+	return sites, nil
+}
+
+// isFragmentKey reports whether a filter column is the relation's fragment key
+func (q Query) isFragmentKey(rel *catalog.Relation, column string) bool {
+	if qualifier, name, found := strings.Cut(column, "."); found {
+		if qualifier != q.Alias && qualifier != rel.Name {
+			return false
+		}
+		column = name
+	}
+	return strings.EqualFold(column, rel.FragmentKey)
+}
+
+// render builds the SQL text and arguments shipped to every site
+func (q Query) render() (string, []interface{}) {
+	var b strings.Builder
+	args := append([]interface{}{}, q.Args...)
+
+	b.WriteString(strings.TrimSpace(q.Select))
+	for i, p := range q.Filters {
+		condition, conditionArgs := p.SQL()
+		if i == 0 {
+			b.WriteString(" WHERE ")
+		} else {
+			b.WriteString(" AND ")
+		}
+		b.WriteString("(" + condition + ")")
+		args = append(args, conditionArgs...)
+	}
+	if q.GroupBy != "" {
+		b.WriteString(" GROUP BY " + q.GroupBy)
+	}
+	if q.OrderBy != "" {
+		b.WriteString(" ORDER BY " + q.OrderBy)
+		if q.Limit > 0 {
+			fmt.Fprintf(&b, " OFFSET 0 ROWS FETCH NEXT %d ROWS ONLY", q.Limit)
+		}
+	}
+	if q.wrap != "" {
+		return fmt.Sprintf(q.wrap, b.String()), args
+	}
+	return b.String(), args
+}
+
+// siteRows is what one site returned
+type siteRows[T any] struct {
+	siteID string
+	rows   []T
+	err    error
+}
+
+// run executes the plan on every selected site in parallel, each with the per-site
+// timeout; results are in plan order
+func run[T any](ctx context.Context, e *Executor, plan *Plan, scan func(*sql.Rows) (T, error)) []siteRows[T] {
+	results := make([]siteRows[T], len(plan.Sites))
+
+	var wg sync.WaitGroup
+	for i, sp := range plan.Sites {
+		wg.Add(1)
+		go func(i int, sp SitePlan) {
+			defer wg.Done()
+			siteCtx, cancel := e.siteContext(ctx)
+			defer cancel()
+
+			results[i] = siteRows[T]{siteID: sp.SiteID}
+			results[i].rows, results[i].err = fetch(siteCtx, e, sp, scan)
+		}(i, sp)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func fetch[T any](ctx context.Context, e *Executor, sp SitePlan, scan func(*sql.Rows) (T, error)) ([]T, error) {
+	db, err := e.connect(sp.SiteID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, sp.SQL, sp.Args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	var out []T
+	for rows.Next() {
+		row, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// collect turns per-site rows into a result, logging sites that failed and reporting
+// the outcome to the context's tracker
+func collect[T any](ctx context.Context, results []siteRows[T]) (*Result[T], error) {
+	result := &Result[T]{Failed: make(map[string]error)}
+	sites := make([]string, 0, len(results))
+	for _, r := range results {
+		sites = append(sites, r.siteID)
+		if r.err != nil {
+			log.Printf("Query on site %s failed: %v", r.siteID, r.err)
+			result.Failed[r.siteID] = r.err
+			continue
+		}
+		result.Sites = append(result.Sites, r.siteID)
+	}
+	if err := report(ctx, sites, result.Failed); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Union runs the query on every relevant site and concatenates the rows in site order
+func Union[T any](ctx context.Context, e *Executor, q Query, scan func(*sql.Rows) (T, error)) (*Result[T], error) {
+	plan, err := e.Plan(q)
+	if err != nil {
+		return nil, err
+	}
+
+	results := run(ctx, e, plan, scan)
+	result, err := collect(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if r.err == nil {
+			result.Rows = append(result.Rows, r.rows...)
+		}
+	}
+	return result, nil
+}
+
+// Merge runs the query on every relevant site and merges the per-site streams, each
+// sorted by the query's ORDER BY, into one stream ordered by less
+func Merge[T any](ctx context.Context, e *Executor, q Query, scan func(*sql.Rows) (T, error), less func(a, b T) bool) (*Result[T], error) {
+	plan, err := e.Plan(q)
+	if err != nil {
+		return nil, err
+	}
+
+	results := run(ctx, e, plan, scan)
+	result, err := collect(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	result.Rows = mergeRows(results, less)
+	return result, nil
+}
+
+// mergeRows merges the rows of the sites that answered, each sorted by less, into
+// one sorted stream
+func mergeRows[T any](results []siteRows[T], less func(a, b T) bool) []T {
+	var merged []T
+	heads := make([]int, len(results))
+	for {
+		best := -1
+		for i, r := range results {
+			if r.err != nil || heads[i] >= len(r.rows) {
+				continue
+			}
+			if best < 0 || less(r.rows[heads[i]], results[best].rows[heads[best]]) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		merged = append(merged, results[best].rows[heads[best]])
+		heads[best]++
+	}
+	return merged
+}
+
+// First runs a lookup on every relevant site and returns the first row found.
+// A row found is a complete answer even if other sites failed; otherwise the
+// failed sites are reported in the error.
+func First[T any](ctx context.Context, e *Executor, q Query, scan func(*sql.Rows) (T, error)) (T, bool, error) {
+	var zero T
+
+	plan, err := e.Plan(q)
+	if err != nil {
+		return zero, false, err
+	}
+
+	results := run(ctx, e, plan, scan)
+	failed := make(map[string]error)
+	sites := make([]string, 0, len(results))
+	for _, r := range results {
+		sites = append(sites, r.siteID)
+		if r.err != nil {
+			log.Printf("Query on site %s failed: %v", r.siteID, r.err)
+			failed[r.siteID] = r.err
+		}
+	}
+	for _, r := range results {
+		if r.err == nil && len(r.rows) > 0 {
+			// Only the answering site matters for a complete answer
+			report(ctx, []string{r.siteID}, nil)
+			return r.rows[0], true, nil
+		}
+	}
+
+	if err := report(ctx, sites, failed); err != nil {
+		return zero, false, err
+	}
+	if len(failed) > 0 {
+		ids := make([]string, 0, len(failed))
+		for siteID := range failed {
+			ids = append(ids, siteID)
+		}
+		sort.Strings(ids)
+		return zero, false, fmt.Errorf("not found on reachable sites, sites %v could not be queried", ids)
+	}
+	return zero, false, nil
+}
@@ -0,0 +1,202 @@
+package query
+
+import (
+	"library_distributed_server/internal/catalog"
+	"library_distributed_server/internal/config"
+	"reflect"
+	"testing"
+)
+
+func testExecutor() *Executor {
+	cat := catalog.FromConfig(&config.Config{
+		Sites: []config.SiteConfig{{SiteID: "Q1"}, {SiteID: "Q3"}, {SiteID: "Q5"}},
+		Allocations: []config.AllocationConfig{
+			{Relation: "QUYENSACH", Fragment: "Q5", Site: "Q1"},
+		},
+	})
+	return NewExecutor(cat, "Q3", 0, nil)
+}
+
+func planSites(plan *Plan) []string {
+	var sites []string
+	for _, sp := range plan.Sites {
+		sites = append(sites, sp.SiteID)
+	}
+	return sites
+}
+
+func TestPlanPruning(t *testing.T) {
+	e := testExecutor()
+
+	tests := []struct {
+		name   string
+		query  Query
+		sites  []string
+		pruned []string
+	}{
+		{
+			name:   "no filter",
+			query:  Query{Relation: "PHIEUMUON", Select: "SELECT * FROM PHIEUMUON"},
+			sites:  []string{"Q1", "Q3", "Q5"},
+			pruned: nil,
+		},
+		{
+			name:   "equality on the fragment key",
+			query:  Query{Relation: "PHIEUMUON", Select: "SELECT * FROM PHIEUMUON", Filters: []Predicate{Eq("MaCN", "Q3")}},
+			sites:  []string{"Q3"},
+			pruned: []string{"Q1", "Q5"},
+		},
+		{
+			name:   "IN on the fragment key",
+			query:  Query{Relation: "PHIEUMUON", Select: "SELECT * FROM PHIEUMUON", Filters: []Predicate{In("MaCN", "Q1", "Q5")}},
+			sites:  []string{"Q1", "Q5"},
+			pruned: []string{"Q3"},
+		},
+		{
+			name:   "relocated fragment",
+			query:  Query{Relation: "QUYENSACH", Select: "SELECT * FROM QUYENSACH", Filters: []Predicate{Eq("MaCN", "Q5")}},
+			sites:  []string{"Q1"},
+			pruned: []string{"Q3"},
+		},
+		{
+			name:   "unknown branch",
+			query:  Query{Relation: "PHIEUMUON", Select: "SELECT * FROM PHIEUMUON", Filters: []Predicate{Eq("MaCN", "Q9")}},
+			sites:  nil,
+			pruned: []string{"Q1", "Q3", "Q5"},
+		},
+		{
+			name:   "empty IN",
+			query:  Query{Relation: "PHIEUMUON", Select: "SELECT * FROM PHIEUMUON", Filters: []Predicate{In("MaCN")}},
+			sites:  nil,
+			pruned: []string{"Q1", "Q3", "Q5"},
+		},
+		{
+			name:   "filters intersect",
+			query:  Query{Relation: "PHIEUMUON", Select: "SELECT * FROM PHIEUMUON", Filters: []Predicate{In("MaCN", "Q1", "Q3"), Eq("MaCN", "Q3")}},
+			sites:  []string{"Q3"},
+			pruned: []string{"Q1", "Q5"},
+		},
+		{
+			name:   "alias qualified key",
+			query:  Query{Relation: "PHIEUMUON", Alias: "pm", Select: "SELECT * FROM PHIEUMUON pm", Filters: []Predicate{Eq("pm.MaCN", "Q1")}},
+			sites:  []string{"Q1"},
+			pruned: []string{"Q3", "Q5"},
+		},
+		{
+			name:   "key of a joined table",
+			query:  Query{Relation: "PHIEUMUON", Alias: "pm", Select: "SELECT * FROM PHIEUMUON pm JOIN DOCGIA dg ON dg.MaDG = pm.MaDG", Filters: []Predicate{Eq("dg.MaCN", "Q1")}},
+			sites:  []string{"Q1", "Q3", "Q5"},
+			pruned: nil,
+		},
+		{
+			name:   "other column",
+			query:  Query{Relation: "PHIEUMUON", Select: "SELECT * FROM PHIEUMUON", Filters: []Predicate{Eq("MaDG", "DG01")}},
+			sites:  []string{"Q1", "Q3", "Q5"},
+			pruned: nil,
+		},
+		{
+			name:   "LIKE on the fragment key",
+			query:  Query{Relation: "PHIEUMUON", Select: "SELECT * FROM PHIEUMUON", Filters: []Predicate{Like("MaCN", "Q1%")}},
+			sites:  []string{"Q1", "Q3", "Q5"},
+			pruned: nil,
+		},
+		{
+			name:   "raw condition",
+			query:  Query{Relation: "PHIEUMUON", Select: "SELECT * FROM PHIEUMUON", Filters: []Predicate{Where("MaCN = ?", "Q1")}},
+			sites:  []string{"Q1", "Q3", "Q5"},
+			pruned: nil,
+		},
+		{
+			name:   "fragments restricted by the select",
+			query:  Query{Relation: "PHIEUMUON", Select: "SELECT * FROM PHIEUMUON", Fragments: []string{"Q5"}},
+			sites:  []string{"Q5"},
+			pruned: []string{"Q1", "Q3"},
+		},
+		{
+			name:   "replicated relation",
+			query:  Query{Relation: "SACH", Select: "SELECT * FROM SACH"},
+			sites:  []string{"Q3"},
+			pruned: []string{"Q1", "Q5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := e.Plan(tt.query)
+			if err != nil {
+				t.Fatalf("Plan failed: %v", err)
+			}
+			if got := planSites(plan); !reflect.DeepEqual(got, tt.sites) {
+				t.Errorf("sites = %v, want %v", got, tt.sites)
+			}
+			if !reflect.DeepEqual(plan.Pruned, tt.pruned) {
+				t.Errorf("pruned = %v, want %v", plan.Pruned, tt.pruned)
+			}
+		})
+	}
+}
+
+func TestPlanUnknownRelation(t *testing.T) {
+	if _, err := testExecutor().Plan(Query{Relation: "KHONGCO"}); err == nil {
+		t.Error("Plan of an unknown relation succeeded")
+	}
+}
+
+func TestPlanDoesNotAlterFilters(t *testing.T) {
+	filters := make([]Predicate, 1, 2)
+	filters[0] = Eq("MaDG", "DG01")
+	q := Query{Relation: "PHIEUMUON", Select: "SELECT * FROM PHIEUMUON", Filters: filters, Fragments: []string{"Q1"}}
+
+	plan, err := testExecutor().Plan(q)
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if want := "SELECT * FROM PHIEUMUON WHERE (MaDG = ?)"; plan.Sites[0].SQL != want {
+		t.Errorf("SQL = %q, want %q", plan.Sites[0].SQL, want)
+	}
+}
+
+func TestPredicateSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Predicate
+		sql  string
+		args []interface{}
+	}{
+		{"equality", Eq("MaCN", "Q1"), "MaCN = ?", []interface{}{"Q1"}},
+		{"IN", In("pm.MaCN", "Q1", "Q3"), "pm.MaCN IN (?, ?)", []interface{}{"Q1", "Q3"}},
+		{"empty IN", In("MaCN"), "1 = 0", nil},
+		{"LIKE", Like("TenSach", "%Go%"), "TenSach LIKE ?", []interface{}{"%Go%"}},
+		{"raw", Where("NgayTra IS NULL OR HanTra < ?", "2024-01-01"), "NgayTra IS NULL OR HanTra < ?", []interface{}{"2024-01-01"}},
+	}
+
+	for _, tt := range tests {
+		sql, args := tt.p.SQL()
+		if sql != tt.sql || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: SQL() = %q %v, want %q %v", tt.name, sql, args, tt.sql, tt.args)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	q := Query{
+		Relation: "PHIEUMUON",
+		Select:   "SELECT pm.MaCN, COUNT(*) FROM PHIEUMUON pm JOIN DOCGIA dg ON dg.MaDG = pm.MaDG AND dg.LoaiDG = ? ",
+		Args:     []interface{}{"SinhVien"},
+		Filters:  []Predicate{Eq("pm.MaCN", "Q1"), Where("pm.NgayTra IS NULL")},
+		GroupBy:  "pm.MaCN",
+		OrderBy:  "pm.MaCN",
+		Limit:    10,
+	}
+
+	sql, args := q.render()
+	want := "SELECT pm.MaCN, COUNT(*) FROM PHIEUMUON pm JOIN DOCGIA dg ON dg.MaDG = pm.MaDG AND dg.LoaiDG = ?" +
+		" WHERE (pm.MaCN = ?) AND (pm.NgayTra IS NULL)" +
+		" GROUP BY pm.MaCN ORDER BY pm.MaCN OFFSET 0 ROWS FETCH NEXT 10 ROWS ONLY"
+	if sql != want {
+		t.Errorf("render() SQL = %q, want %q", sql, want)
+	}
+	if wantArgs := []interface{}{"SinhVien", "Q1"}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("render() args = %v, want %v", args, wantArgs)
+	}
+}
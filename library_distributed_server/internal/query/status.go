@@ -0,0 +1,143 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"library_distributed_server/internal/models"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrIncomplete is returned when sites failed and the caller required results from all of them
+var ErrIncomplete = errors.New("results incomplete")
+
+// Tracker collects the outcome at each site of every fan-out made for one request
+type Tracker struct {
+	requireAll bool
+
+	mutex   sync.Mutex
+	queried map[string]bool
+	failed  map[string]string
+}
+
+type trackerKey struct{}
+
+// Track returns a context whose fan-outs are recorded in the returned tracker.
+// With requireAll, a fan-out that cannot reach every relevant site fails with ErrIncomplete.
+func Track(ctx context.Context, requireAll bool) (context.Context, *Tracker) {
+	t := &Tracker{
+		requireAll: requireAll,
+		queried:    make(map[string]bool),
+		failed:     make(map[string]string),
+	}
+	return context.WithValue(ctx, trackerKey{}, t), t
+}
+
+// record notes the outcome of one site; a site fails if any fan-out to it failed
+func (t *Tracker) record(siteID string, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.queried[siteID] = true
+	if err != nil {
+		if _, exists := t.failed[siteID]; !exists {
+			t.failed[siteID] = err.Error()
+		}
+	}
+}
+
+// Metadata summarizes the sites reached, or returns nil when no fan-out was made
+func (t *Tracker) Metadata() *models.QueryMetadata {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if len(t.queried) == 0 {
+		return nil
+	}
+
+	meta := &models.QueryMetadata{
+		SitesQueried:   []string{},
+		SitesSucceeded: []string{},
+		SitesFailed:    []models.SiteFailure{},
+	}
+	for siteID := range t.queried {
+		meta.SitesQueried = append(meta.SitesQueried, siteID)
+	}
+	sort.Strings(meta.SitesQueried)
+	for _, siteID := range meta.SitesQueried {
+		if reason, failed := t.failed[siteID]; failed {
+			meta.SitesFailed = append(meta.SitesFailed, models.SiteFailure{SiteID: siteID, Error: reason})
+		} else {
+			meta.SitesSucceeded = append(meta.SitesSucceeded, siteID)
+		}
+	}
+	meta.Partial = len(meta.SitesFailed) > 0
+	return meta
+}
+
+// trackerFrom returns the tracker of a context, or nil
+func trackerFrom(ctx context.Context) *Tracker {
+	t, _ := ctx.Value(trackerKey{}).(*Tracker)
+	return t
+}
+
+// report records per-site outcomes with the context's tracker and returns
+// ErrIncomplete when sites failed and the tracker requires all of them
+func report(ctx context.Context, sites []string, failed map[string]error) error {
+	t := trackerFrom(ctx)
+	if t == nil {
+		return nil
+	}
+	for _, siteID := range sites {
+		t.record(siteID, failed[siteID])
+	}
+	if !t.requireAll || len(failed) == 0 {
+		return nil
+	}
+
+	reasons := make([]string, 0, len(failed))
+	for _, siteID := range sites {
+		if err, exists := failed[siteID]; exists {
+			reasons = append(reasons, fmt.Sprintf("%s: %v", siteID, err))
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrIncomplete, strings.Join(reasons, "; "))
+}
+
+// FanOut runs fn for every site in parallel, each with the executor's per-site timeout.
+// The result holds one row per site that succeeded: Rows[i] came from Sites[i].
+func FanOut[T any](ctx context.Context, e *Executor, sites []string, fn func(ctx context.Context, siteID string) (T, error)) (*Result[T], error) {
+	results := make([]siteRows[T], len(sites))
+
+	var wg sync.WaitGroup
+	for i, siteID := range sites {
+		wg.Add(1)
+		go func(i int, siteID string) {
+			defer wg.Done()
+			siteCtx, cancel := e.siteContext(ctx)
+			defer cancel()
+
+			results[i] = siteRows[T]{siteID: siteID}
+			row, err := fn(siteCtx, siteID)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			results[i].rows = []T{row}
+		}(i, siteID)
+	}
+	wg.Wait()
+
+	result, err := collect(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if r.err == nil {
+			result.Rows = append(result.Rows, r.rows...)
+		}
+	}
+	return result, nil
+}
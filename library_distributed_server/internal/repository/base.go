@@ -4,18 +4,23 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"library_distributed_server/internal/catalog"
 	"library_distributed_server/internal/config"
 	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
 	"library_distributed_server/pkg/database"
 	"library_distributed_server/pkg/utils"
 	"log"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // BaseRepository provides common raw SQL operations for all repositories
 type BaseRepository struct {
-	config *config.Config
-	pool   *database.ConnectionPool
+	store   *config.Store
+	connect func(siteID, connectionString string) (*sql.DB, error) // Opens or reuses a site's connection
 }
 
 // QueryResult represents a generic query result
@@ -30,42 +35,32 @@ type ValidationRule interface {
 	Validate(ctx context.Context, data map[string]interface{}) error
 }
 
-// FragmentationConstraint enforces fragmentation rules
-type FragmentationConstraint struct {
-	Table        string
-	FragmentKey  string
-	ExpectedSite string
-}
-
-func (fc FragmentationConstraint) Validate(ctx context.Context, data map[string]interface{}) error {
-	if value, exists := data[fc.FragmentKey]; exists {
-		if value != fc.ExpectedSite {
-			return fmt.Errorf("fragmentation violation: %s.%s=%v must be %s for this site",
-				fc.Table, fc.FragmentKey, value, fc.ExpectedSite)
-		}
-	}
-	return nil
-}
-
 // NewBaseRepository creates a new base repository with raw SQL capabilities
-func NewBaseRepository(config *config.Config) *BaseRepository {
-	pool := database.GetPool()
+func NewBaseRepository(store *config.Store) *BaseRepository {
 	return &BaseRepository{
-		config: config,
-		pool:   pool,
+		store:   store,
+		connect: database.GetPool().GetConnection,
 	}
 }
 
+// config returns the configuration currently in effect
+func (r *BaseRepository) config() *config.Config {
+	return r.store.Current()
+}
+
 // GetConnection returns a database connection for the specified site
 func (r *BaseRepository) GetConnection(siteID string) (*sql.DB, error) {
-	connectionString := r.config.GetConnectionString(siteID)
-	return r.pool.GetConnection(siteID, connectionString)
+	connectionString, err := r.config().GetConnectionString(siteID)
+	if err != nil {
+		return nil, err
+	}
+	return r.connect(siteID, connectionString)
 }
 
 // GetAllSiteConnections returns connections to all configured sites
 func (r *BaseRepository) GetAllSiteConnections() (map[string]*sql.DB, error) {
 	connections := make(map[string]*sql.DB)
-	for _, site := range r.config.Sites {
+	for _, site := range r.config().Sites {
 		conn, err := r.GetConnection(site.SiteID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to connect to site %s: %w", site.SiteID, err)
@@ -75,32 +70,81 @@ func (r *BaseRepository) GetAllSiteConnections() (map[string]*sql.DB, error) {
 	return connections, nil
 }
 
-// ValidateFragmentation validates fragmentation constraints before operations
-func (r *BaseRepository) ValidateFragmentation(ctx context.Context, table string, data map[string]interface{}, siteID string) error {
-	switch table {
-	case "DOCGIA":
-		constraint := FragmentationConstraint{
-			Table:        "DOCGIA",
-			FragmentKey:  "MaCN_DangKy",
-			ExpectedSite: siteID,
+// Catalog returns the fragmentation and allocation catalog for the current topology
+func (r *BaseRepository) Catalog() *catalog.Catalog {
+	return catalog.FromConfig(r.config())
+}
+
+// Executor returns a distributed query executor over the current catalog.
+// Replicated relations are read at the local site when it holds a copy.
+func (r *BaseRepository) Executor(local string) *query.Executor {
+	cfg := r.config()
+	return query.NewExecutor(catalog.FromConfig(cfg), local, cfg.Query.SiteTimeout, r.GetConnection)
+}
+
+// pageRequest converts API pagination parameters into a query page request
+func pageRequest(pagination *utils.PaginationParams) query.PageRequest {
+	if pagination == nil {
+		return query.PageRequest{Size: math.MaxInt32}
+	}
+	return query.PageRequest{
+		Offset: pagination.CalculateOffset(),
+		Size:   pagination.Size,
+		Cursor: pagination.Cursor,
+	}
+}
+
+// GetFragmentConnection returns a connection to the site storing rows of the relation
+// whose fragment key equals value. For replicated relations value is the preferred site.
+// Reads of a replicated relation skip to another replica when the preferred site is unavailable.
+func (r *BaseRepository) GetFragmentConnection(relation, value string) (*sql.DB, string, error) {
+	cat := r.Catalog()
+	siteID, err := cat.SiteFor(relation, value, value)
+	if err != nil {
+		return nil, "", err
+	}
+	conn, err := r.GetConnection(siteID)
+	if err == nil {
+		return conn, siteID, nil
+	}
+
+	rel, relErr := cat.Relation(relation)
+	if relErr != nil || rel.Type != catalog.Replicated {
+		return nil, siteID, err
+	}
+	for _, replica := range rel.Fragments[0].Sites {
+		if replica == siteID {
+			continue
 		}
-		return constraint.Validate(ctx, data)
-	case "QUYENSACH":
-		constraint := FragmentationConstraint{
-			Table:        "QUYENSACH",
-			FragmentKey:  "MaCN",
-			ExpectedSite: siteID,
+		if conn, replicaErr := r.GetConnection(replica); replicaErr == nil {
+			log.Printf("Site %s unavailable for %s, reading replica at %s", siteID, relation, replica)
+			return conn, replica, nil
 		}
-		return constraint.Validate(ctx, data)
-	case "PHIEUMUON":
-		constraint := FragmentationConstraint{
-			Table:        "PHIEUMUON",
-			FragmentKey:  "MaCN",
-			ExpectedSite: siteID,
+	}
+	return nil, siteID, err
+}
+
+// GetRelationConnections returns connections to every site holding a fragment of the relation
+func (r *BaseRepository) GetRelationConnections(relation string) (map[string]*sql.DB, error) {
+	sites, err := r.Catalog().SitesFor(relation)
+	if err != nil {
+		return nil, err
+	}
+
+	connections := make(map[string]*sql.DB)
+	for _, siteID := range sites {
+		conn, err := r.GetConnection(siteID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 		}
-		return constraint.Validate(ctx, data)
+		connections[siteID] = conn
 	}
-	return nil
+	return connections, nil
+}
+
+// ValidateFragmentation checks against the catalog that a row may be stored at siteID
+func (r *BaseRepository) ValidateFragmentation(ctx context.Context, table string, data map[string]interface{}, siteID string) error {
+	return r.Catalog().ValidateRow(table, data, siteID)
 }
 
 // ExecuteWithTransaction executes a function within a database transaction
@@ -124,6 +168,71 @@ func (r *BaseRepository) ExecuteWithTransaction(ctx context.Context, db *sql.DB,
 	return nil
 }
 
+// joinTx returns the transaction in which work on the fragment of relation at siteID joins
+// the transaction of its caller
+type joinTx func(relation, siteID string) (*sql.Tx, error)
+
+// laterTxs holds the transactions opened on other databases by work joined to a caller's
+// transaction. They are committed once the caller's transaction has been, so work the caller
+// rolls back leaves nothing behind in them.
+type laterTxs struct {
+	r     *BaseRepository
+	txs   map[*sql.DB]*sql.Tx
+	sites map[*sql.DB]string
+	order []*sql.DB
+}
+
+// newLaterTxs starts holding the transactions joined to a caller's; roll them back when done
+func (r *BaseRepository) newLaterTxs() *laterTxs {
+	return &laterTxs{r: r, txs: make(map[*sql.DB]*sql.Tx), sites: make(map[*sql.DB]string)}
+}
+
+// join returns the joinTx of work in tx, which runs on db
+func (l *laterTxs) join(ctx context.Context, db *sql.DB, tx *sql.Tx) joinTx {
+	return func(relation, siteID string) (*sql.Tx, error) {
+		other, _, err := l.r.GetFragmentConnection(relation, siteID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
+		}
+		// Fragments stored on the same database share one transaction
+		if other == db {
+			return tx, nil
+		}
+		if later, ok := l.txs[other]; ok {
+			return later, nil
+		}
+		later, err := other.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to begin transaction on site %s: %w", siteID, err)
+		}
+		l.txs[other], l.sites[other] = later, siteID
+		l.order = append(l.order, other)
+		return later, nil
+	}
+}
+
+// commit commits the held transactions after the caller's has committed. The caller's work
+// stands whatever happens here, so failures are only reported.
+func (l *laterTxs) commit() error {
+	var errs []string
+	for _, db := range l.order {
+		if err := l.txs[db].Commit(); err != nil {
+			errs = append(errs, fmt.Sprintf("site %s: %v", l.sites[db], err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to commit %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// rollback rolls back the held transactions not committed
+func (l *laterTxs) rollback() {
+	for _, tx := range l.txs {
+		tx.Rollback()
+	}
+}
+
 // ExecuteQuery executes a SELECT query with optional pagination
 func (r *BaseRepository) ExecuteQuery(ctx context.Context, db *sql.DB, query string, args []interface{}, pagination *utils.PaginationParams) (*sql.Rows, error) {
 	finalQuery := query
@@ -155,54 +264,71 @@ func (r *BaseRepository) GetTotalCount(ctx context.Context, db *sql.DB, countQue
 	return count, nil
 }
 
-// ExecuteParallelQueries executes queries on multiple sites in parallel
+// ExecuteParallelQueries executes queries on multiple sites in parallel, each bounded by
+// the per-site timeout. Rows are read before the result is returned: Data holds a
+// []map[string]interface{} keyed by column name.
 func (r *BaseRepository) ExecuteParallelQueries(ctx context.Context, queries map[string]string, args map[string][]interface{}) map[string]QueryResult {
+	sites := make([]string, 0, len(queries))
+	for siteID := range queries {
+		sites = append(sites, siteID)
+	}
+
 	results := make(map[string]QueryResult)
-	resultChan := make(chan struct {
-		site   string
-		result QueryResult
-	}, len(queries))
-
-	// Execute queries in parallel
-	for siteID, query := range queries {
-		go func(site string, q string) {
-			db, err := r.GetConnection(site)
-			if err != nil {
-				resultChan <- struct {
-					site   string
-					result QueryResult
-				}{site, QueryResult{Error: err}}
-				return
-			}
-
-			queryArgs := args[site]
-			rows, err := r.ExecuteQuery(ctx, db, q, queryArgs, nil)
-			if err != nil {
-				resultChan <- struct {
-					site   string
-					result QueryResult
-				}{site, QueryResult{Error: err}}
-				return
-			}
-			defer rows.Close()
-
-			// For now, just return the rows - specific repositories will handle scanning
-			resultChan <- struct {
-				site   string
-				result QueryResult
-			}{site, QueryResult{Data: rows, Error: nil}}
-		}(siteID, query)
-	}
-
-	// Collect results
-	for i := 0; i < len(queries); i++ {
-		result := <-resultChan
-		results[result.site] = result.result
+	fanOut, err := query.FanOut(ctx, r.Executor(""), sites, func(ctx context.Context, siteID string) ([]map[string]interface{}, error) {
+		db, err := r.GetConnection(siteID)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := r.ExecuteQuery(ctx, db, queries[siteID], args[siteID], nil)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		return scanMaps(rows)
+	})
+	if err != nil {
+		for _, siteID := range sites {
+			results[siteID] = QueryResult{Error: err}
+		}
+		return results
 	}
 
+	for i, siteID := range fanOut.Sites {
+		results[siteID] = QueryResult{Data: fanOut.Rows[i], TotalCount: len(fanOut.Rows[i])}
+	}
+	for siteID, err := range fanOut.Failed {
+		results[siteID] = QueryResult{Error: err}
+	}
 	return results
 }
 
+// scanMaps reads all rows into maps keyed by column name
+func scanMaps(rows *sql.Rows) ([]map[string]interface{}, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+
+	var out []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			row[column] = values[i]
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
 // CheckRecordExists checks if a record exists with given conditions
 func (r *BaseRepository) CheckRecordExists(ctx context.Context, db *sql.DB, table string, conditions map[string]interface{}) (bool, error) {
 	var whereClause []string
@@ -300,7 +426,7 @@ func (r *BaseRepository) BuildDeleteQuery(table string, conditions map[string]in
 // ScanDocGia scans a row into DocGia model
 func (r *BaseRepository) ScanDocGia(rows *sql.Rows) (*models.DocGia, error) {
 	var docGia models.DocGia
-	err := rows.Scan(&docGia.MaDG, &docGia.HoTen, &docGia.MaCNDangKy)
+	err := rows.Scan(&docGia.MaDG, &docGia.HoTen, &docGia.MaCNDangKy, &docGia.LoaiDG)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan DocGia: %w", err)
 	}
@@ -321,7 +447,7 @@ func (r *BaseRepository) ScanQuyenSach(rows *sql.Rows) (*models.QuyenSach, error
 func (r *BaseRepository) ScanPhieuMuon(rows *sql.Rows) (*models.PhieuMuon, error) {
 	var phieuMuon models.PhieuMuon
 	err := rows.Scan(&phieuMuon.MaPM, &phieuMuon.MaDG, &phieuMuon.MaQuyenSach,
-		&phieuMuon.MaCN, &phieuMuon.NgayMuon, &phieuMuon.NgayTra)
+		&phieuMuon.MaCN, &phieuMuon.NgayMuon, &phieuMuon.NgayTra, &phieuMuon.HanTra, &phieuMuon.SoLanGiaHan)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan PhieuMuon: %w", err)
 	}
@@ -359,8 +485,14 @@ func (r *BaseRepository) GetSiteIDFromContext(ctx context.Context) string {
 		return siteID.(string)
 	}
 	// Default to first configured site if not specified
-	if len(r.config.Sites) > 0 {
-		return r.config.Sites[0].SiteID
+	if sites := r.config().Sites; len(sites) > 0 {
+		return sites[0].SiteID
 	}
 	return "Q1"
 }
+
+// newRowID generates a key unique across branches for a row created at a site: the site
+// followed by a base-36 timestamp
+func newRowID(siteID string) string {
+	return siteID + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
@@ -4,16 +4,25 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"library_distributed_server/internal/cache"
 	"library_distributed_server/internal/config"
 	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
+	"library_distributed_server/internal/search"
 	"library_distributed_server/pkg/utils"
 	"log"
+	"sync/atomic"
 )
 
 // BookRepository handles book operations using raw SQL queries
 type BookRepository struct {
 	*BaseRepository
-	siteID string // Current site for this repository instance
+	siteID    string            // Current site for this repository instance
+	cache     *cache.Cache      // SACH rows and catalog pages
+	publisher *cache.Publisher  // Announces SACH writes to the caches of all sites
+	index     *search.Index     // Full-text index over the local SACH replica
+	indexing  atomic.Bool       // A background rebuild of the index is running
+	borrows   *BorrowRepository // Loans closed and holds served by status changes
 }
 
 // BookRepositoryInterface defines book-related operations with raw SQL
@@ -24,7 +33,11 @@ type BookRepositoryInterface interface {
 	UpdateBook(ctx context.Context, book *models.Sach) error
 	DeleteBook(ctx context.Context, isbn string) error
 	GetAllBooks(ctx context.Context, pagination *utils.PaginationParams) ([]*models.Sach, int, error)
-	SearchBooks(ctx context.Context, query string, pagination *utils.PaginationParams) ([]*models.Sach, int, error)
+	SearchBooks(ctx context.Context, query string, pagination *utils.PaginationParams) ([]*models.ScoredSach, int, error)
+
+	// Full-text search index over the local SACH replica
+	RebuildIndex(ctx context.Context) error
+	RefreshIndex(ctx context.Context, inv cache.Invalidation) error
 
 	// Book copy operations (fragmented tables)
 	CreateBookCopy(ctx context.Context, bookCopy *models.QuyenSach, userSite string) error
@@ -35,8 +48,11 @@ type BookRepositoryInterface interface {
 	GetBookCopiesByISBN(ctx context.Context, isbn string) ([]*models.QuyenSach, error)
 
 	// Advanced search operations
-	SearchAvailableBooks(ctx context.Context, query string) ([]*models.BookSearchResult, error)
+	SearchAvailableBooks(ctx context.Context, searchText string, filter models.CatalogFilter) ([]*models.BookSearchResult, *models.CatalogFacets, error)
+	SearchCatalog(ctx context.Context, searchText string, filter models.CatalogFilter, pagination *utils.PaginationParams) ([]*models.ScoredSach, int, *models.CatalogFacets, error)
 	GetBooksWithAvailability(ctx context.Context, siteID string) ([]*models.BookWithAvailability, error)
+	ExplainSearchAvailableBooks(ctx context.Context, query string) (*query.Explanation, error)
+	ExplainBooksWithAvailability(ctx context.Context, siteID string) (*query.Explanation, error)
 	CheckBookAvailability(ctx context.Context, isbn string, siteID string) (int, error)
 
 	// 2PC operations
@@ -44,24 +60,57 @@ type BookRepositoryInterface interface {
 }
 
 // NewBookRepository creates a new book repository with raw SQL
-func NewBookRepository(config *config.Config, siteID string) BookRepositoryInterface {
+func NewBookRepository(store *config.Store, siteID string, refCache *cache.Cache, publisher *cache.Publisher) BookRepositoryInterface {
+	base := NewBaseRepository(store)
 	return &BookRepository{
-		BaseRepository: NewBaseRepository(config),
+		BaseRepository: base,
 		siteID:         siteID,
+		cache:          refCache,
+		publisher:      publisher,
+		index:          search.NewIndex(),
+		borrows: &BorrowRepository{
+			BaseRepository: base,
+			siteID:         siteID,
+			holds:          &HoldRepository{BaseRepository: base, siteID: siteID},
+		},
+	}
+}
+
+// bookPage is a cached page of the book catalog
+type bookPage struct {
+	books []*models.Sach
+	total int
+}
+
+// pageKey identifies a cached list result by its list, search text and page
+func pageKey(list, text string, pagination *utils.PaginationParams) string {
+	if pagination == nil {
+		return fmt.Sprintf("%s:%s", list, text)
 	}
+	return fmt.Sprintf("%s:%s:%d:%d", list, text, pagination.Page, pagination.Size)
+}
+
+// invalidateBook drops a SACH row from the caches of every site once a write to it
+// committed at any replica
+func (r *BookRepository) invalidateBook(isbn string) {
+	r.publisher.Publish(cache.Invalidation{Relation: "SACH", Keys: []string{isbn}})
 }
 
 // CreateBook creates a new book in catalog using 2PC across all sites (Manager only)
 func (r *BookRepository) CreateBook(ctx context.Context, book *models.Sach) error {
-	// This requires 2PC implementation across all sites
-	connections, err := r.GetAllSiteConnections()
+	// This requires 2PC implementation across all replicas of SACH
+	connections, err := r.GetRelationConnections("SACH")
 	if err != nil {
 		return fmt.Errorf("failed to get site connections: %w", err)
 	}
 
 	// Phase 1: Prepare all sites
 	var transactions []*sql.Tx
+	committed := false
 	defer func() {
+		if committed {
+			r.invalidateBook(book.ISBN)
+		}
 		// Rollback any open transactions if something fails
 		for _, tx := range transactions {
 			if tx != nil {
@@ -109,7 +158,8 @@ func (r *BookRepository) CreateBook(ctx context.Context, book *models.Sach) erro
 			return fmt.Errorf("failed to commit transaction in site %d: %w", i, err)
 		}
 
-		transactions[i] = nil // Mark as committed
+		transactions[i] = nil
+		committed = true // Mark as committed
 	}
 
 	log.Printf("Book %s created across all sites using 2PC", book.ISBN)
@@ -118,8 +168,15 @@ func (r *BookRepository) CreateBook(ctx context.Context, book *models.Sach) erro
 
 // GetBookByISBN retrieves book information from any site (replicated data)
 func (r *BookRepository) GetBookByISBN(ctx context.Context, isbn string) (*models.Sach, error) {
+	return cache.Row(r.cache, "SACH", isbn, func() (*models.Sach, error) {
+		return r.queryBookByISBN(ctx, isbn)
+	})
+}
+
+// queryBookByISBN reads a SACH row from the local replica
+func (r *BookRepository) queryBookByISBN(ctx context.Context, isbn string) (*models.Sach, error) {
 	// Since SACH table is replicated, we can query any site
-	db, err := r.GetConnection(r.siteID)
+	db, _, err := r.GetFragmentConnection("SACH", r.siteID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to site %s: %w", r.siteID, err)
 	}
@@ -144,15 +201,19 @@ func (r *BookRepository) GetBookByISBN(ctx context.Context, isbn string) (*model
 
 // UpdateBook updates book information using 2PC across all sites (Manager only)
 func (r *BookRepository) UpdateBook(ctx context.Context, book *models.Sach) error {
-	// This requires 2PC implementation across all sites
-	connections, err := r.GetAllSiteConnections()
+	// This requires 2PC implementation across all replicas of SACH
+	connections, err := r.GetRelationConnections("SACH")
 	if err != nil {
 		return fmt.Errorf("failed to get site connections: %w", err)
 	}
 
 	// Execute update across all sites with 2PC
 	var transactions []*sql.Tx
+	committed := false
 	defer func() {
+		if committed {
+			r.invalidateBook(book.ISBN)
+		}
 		for _, tx := range transactions {
 			if tx != nil {
 				tx.Rollback()
@@ -202,6 +263,7 @@ func (r *BookRepository) UpdateBook(ctx context.Context, book *models.Sach) erro
 		}
 
 		transactions[i] = nil
+		committed = true
 	}
 
 	log.Printf("Book %s updated across all sites using 2PC", book.ISBN)
@@ -211,13 +273,13 @@ func (r *BookRepository) UpdateBook(ctx context.Context, book *models.Sach) erro
 // DeleteBook deletes book from catalog using 2PC (Manager only)
 func (r *BookRepository) DeleteBook(ctx context.Context, isbn string) error {
 	// Check if any book copies exist before deletion
-	connections, err := r.GetAllSiteConnections()
+	copyConnections, err := r.GetRelationConnections("QUYENSACH")
 	if err != nil {
 		return fmt.Errorf("failed to get site connections: %w", err)
 	}
 
 	// Check for existing book copies
-	for siteID, db := range connections {
+	for siteID, db := range copyConnections {
 		var copyCount int
 		err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM QUYENSACH WHERE ISBN = ?", isbn).Scan(&copyCount)
 		if err != nil {
@@ -229,9 +291,18 @@ func (r *BookRepository) DeleteBook(ctx context.Context, isbn string) error {
 		}
 	}
 
+	connections, err := r.GetRelationConnections("SACH")
+	if err != nil {
+		return fmt.Errorf("failed to get site connections: %w", err)
+	}
+
 	// Execute deletion across all sites with 2PC
 	var transactions []*sql.Tx
+	committed := false
 	defer func() {
+		if committed {
+			r.invalidateBook(isbn)
+		}
 		for _, tx := range transactions {
 			if tx != nil {
 				tx.Rollback()
@@ -266,6 +337,7 @@ func (r *BookRepository) DeleteBook(ctx context.Context, isbn string) error {
 		}
 
 		transactions[i] = nil
+		committed = true
 	}
 
 	log.Printf("Book %s deleted from all sites using 2PC", isbn)
@@ -274,7 +346,19 @@ func (r *BookRepository) DeleteBook(ctx context.Context, isbn string) error {
 
 // GetAllBooks retrieves all books with pagination (replicated data)
 func (r *BookRepository) GetAllBooks(ctx context.Context, pagination *utils.PaginationParams) ([]*models.Sach, int, error) {
-	db, err := r.GetConnection(r.siteID)
+	page, err := cache.Page(r.cache, "SACH", pageKey("all", "", pagination), func() (bookPage, error) {
+		books, total, err := r.queryAllBooks(ctx, pagination)
+		return bookPage{books, total}, err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return page.books, page.total, nil
+}
+
+// queryAllBooks reads a page of SACH from the local replica
+func (r *BookRepository) queryAllBooks(ctx context.Context, pagination *utils.PaginationParams) ([]*models.Sach, int, error) {
+	db, _, err := r.GetFragmentConnection("SACH", r.siteID)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to connect to site %s: %w", r.siteID, err)
 	}
@@ -310,9 +394,55 @@ func (r *BookRepository) GetAllBooks(ctx context.Context, pagination *utils.Pagi
 	return books, totalCount, nil
 }
 
-// SearchBooks searches books by title or author
-func (r *BookRepository) SearchBooks(ctx context.Context, query string, pagination *utils.PaginationParams) ([]*models.Sach, int, error) {
-	db, err := r.GetConnection(r.siteID)
+// SearchBooks searches books by title, author or ISBN, best matches first. Until the
+// search index is built it falls back to LIKE patterns, without scores.
+func (r *BookRepository) SearchBooks(ctx context.Context, searchText string, pagination *utils.PaginationParams) ([]*models.ScoredSach, int, error) {
+	index := r.searchIndex()
+	if index == nil {
+		page, err := cache.Page(r.cache, "SACH", pageKey("search", searchText, pagination), func() (bookPage, error) {
+			books, total, err := r.querySearchBooks(ctx, searchText, pagination)
+			return bookPage{books, total}, err
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		books := make([]*models.ScoredSach, len(page.books))
+		for i, book := range page.books {
+			books[i] = &models.ScoredSach{Sach: *book}
+		}
+		return books, page.total, nil
+	}
+
+	hits := index.Search(searchText, 0)
+	totalCount := len(hits)
+	if pagination != nil {
+		offset := pagination.CalculateOffset()
+		end := offset + pagination.Size
+
+		if offset >= len(hits) {
+			return []*models.ScoredSach{}, totalCount, nil
+		}
+
+		if end > len(hits) {
+			end = len(hits)
+		}
+
+		hits = hits[offset:end]
+	}
+
+	books := make([]*models.ScoredSach, len(hits))
+	for i, hit := range hits {
+		books[i] = &models.ScoredSach{
+			Sach:  models.Sach{ISBN: hit.ISBN, TenSach: hit.TenSach, TacGia: hit.TacGia},
+			Score: hit.Score,
+		}
+	}
+	return books, totalCount, nil
+}
+
+// querySearchBooks searches SACH at the local replica
+func (r *BookRepository) querySearchBooks(ctx context.Context, query string, pagination *utils.PaginationParams) ([]*models.Sach, int, error) {
+	db, _, err := r.GetFragmentConnection("SACH", r.siteID)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to connect to site %s: %w", r.siteID, err)
 	}
@@ -357,14 +487,103 @@ func (r *BookRepository) SearchBooks(ctx context.Context, query string, paginati
 	return books, totalCount, nil
 }
 
-// CreateBookCopy creates a new book copy with fragmentation validation
+// RebuildIndex builds the search index from the local SACH replica
+func (r *BookRepository) RebuildIndex(ctx context.Context) error {
+	db, _, err := r.GetFragmentConnection("SACH", r.siteID)
+	if err != nil {
+		return fmt.Errorf("failed to connect to site %s: %w", r.siteID, err)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT ISBN, TenSach, TacGia FROM SACH")
+	if err != nil {
+		return fmt.Errorf("failed to read books for the search index: %w", err)
+	}
+	defer rows.Close()
+
+	var docs []search.Document
+	for rows.Next() {
+		book, err := r.ScanSach(rows)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, search.Document{ISBN: book.ISBN, TenSach: book.TenSach, TacGia: book.TacGia})
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read books for the search index: %w", err)
+	}
+
+	r.index.Replace(docs)
+	log.Printf("Search index of site %s built with %d books", r.siteID, len(docs))
+	return nil
+}
+
+// RefreshIndex applies a catalog invalidation to the search index. The written books are
+// re-read from the local replica; an invalidation of the whole catalog rebuilds the index.
+func (r *BookRepository) RefreshIndex(ctx context.Context, inv cache.Invalidation) error {
+	if inv.Relation != "" && inv.Relation != "SACH" {
+		return nil
+	}
+	if len(inv.Keys) == 0 || !r.index.Ready() {
+		return r.RebuildIndex(ctx)
+	}
+
+	db, _, err := r.GetFragmentConnection("SACH", r.siteID)
+	if err != nil {
+		r.index.Invalidate()
+		return fmt.Errorf("failed to connect to site %s: %w", r.siteID, err)
+	}
+	for _, isbn := range inv.Keys {
+		var doc search.Document
+		err := db.QueryRowContext(ctx, "SELECT ISBN, TenSach, TacGia FROM SACH WHERE ISBN = ?", isbn).
+			Scan(&doc.ISBN, &doc.TenSach, &doc.TacGia)
+		switch {
+		case err == sql.ErrNoRows:
+			r.index.Remove(isbn)
+		case err != nil:
+			// Rebuilt on the next search
+			r.index.Invalidate()
+			return fmt.Errorf("failed to refresh book %s in the search index: %w", isbn, err)
+		default:
+			r.index.Put(doc)
+		}
+	}
+	return nil
+}
+
+// searchIndex returns the search index when it is ready. Otherwise it starts rebuilding
+// it in the background and returns nil, and callers fall back to LIKE patterns.
+func (r *BookRepository) searchIndex() *search.Index {
+	if r.index.Ready() {
+		return r.index
+	}
+	if r.indexing.CompareAndSwap(false, true) {
+		go func() {
+			defer r.indexing.Store(false)
+			ctx, cancel := context.WithTimeout(context.Background(), r.config().Query.SiteTimeout)
+			defer cancel()
+			if err := r.RebuildIndex(ctx); err != nil {
+				log.Printf("Failed to build search index of site %s: %v", r.siteID, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// CreateBookCopy creates a new book copy with fragmentation validation. New copies go on the shelf available.
 func (r *BookRepository) CreateBookCopy(ctx context.Context, bookCopy *models.QuyenSach, userSite string) error {
 	// Authorization: only allow creation in user's site
 	if bookCopy.MaCN != userSite {
 		return fmt.Errorf("access denied: cannot create book copy in site %s from site %s", bookCopy.MaCN, userSite)
 	}
 
-	db, err := r.GetConnection(bookCopy.MaCN)
+	if bookCopy.TinhTrang == "" {
+		bookCopy.TinhTrang = models.CopyAvailable
+	}
+	if bookCopy.TinhTrang != models.CopyAvailable {
+		return fmt.Errorf("a new book copy must be %s, not %s", models.CopyAvailable, bookCopy.TinhTrang)
+	}
+
+	db, fragmentSite, err := r.GetFragmentConnection("QUYENSACH", bookCopy.MaCN)
 	if err != nil {
 		return fmt.Errorf("failed to connect to site %s: %w", bookCopy.MaCN, err)
 	}
@@ -373,7 +592,7 @@ func (r *BookRepository) CreateBookCopy(ctx context.Context, bookCopy *models.Qu
 	data := map[string]interface{}{
 		"MaCN": bookCopy.MaCN,
 	}
-	if err := r.ValidateFragmentation(ctx, "QUYENSACH", data, bookCopy.MaCN); err != nil {
+	if err := r.ValidateFragmentation(ctx, "QUYENSACH", data, fragmentSite); err != nil {
 		return fmt.Errorf("fragmentation validation failed: %w", err)
 	}
 
@@ -429,37 +648,26 @@ func (r *BookRepository) CreateBookCopy(ctx context.Context, bookCopy *models.Qu
 
 // GetBookCopyByID retrieves a book copy by ID from appropriate site
 func (r *BookRepository) GetBookCopyByID(ctx context.Context, maQuyenSach string) (*models.QuyenSach, error) {
-	// Try to find book copy in all sites
-	connections, err := r.GetAllSiteConnections()
+	// MaQuyenSach does not determine the fragment, so every fragment of QUYENSACH is searched
+	bookCopy, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "QUYENSACH",
+		Select:   "SELECT MaQuyenSach, ISBN, MaCN, TinhTrang FROM QUYENSACH",
+		Filters:  []query.Predicate{query.Eq("MaQuyenSach", maQuyenSach)},
+	}, r.ScanQuyenSach)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get site connections: %w", err)
+		return nil, fmt.Errorf("failed to look up book copy %s: %w", maQuyenSach, err)
 	}
-
-	query := `
-		SELECT MaQuyenSach, ISBN, MaCN, TinhTrang
-		FROM QUYENSACH
-		WHERE MaQuyenSach = ?
-	`
-
-	for siteID, db := range connections {
-		var bookCopy models.QuyenSach
-		err := db.QueryRowContext(ctx, query, maQuyenSach).Scan(
-			&bookCopy.MaQuyenSach, &bookCopy.ISBN, &bookCopy.MaCN, &bookCopy.TinhTrang)
-
-		if err == nil {
-			log.Printf("Book copy %s found in site %s", maQuyenSach, siteID)
-			return &bookCopy, nil
-		}
-
-		if err != sql.ErrNoRows {
-			log.Printf("Error querying book copy in site %s: %v", siteID, err)
-		}
+	if !found {
+		return nil, fmt.Errorf("book copy not found: %s", maQuyenSach)
 	}
 
-	return nil, fmt.Errorf("book copy not found: %s", maQuyenSach)
+	log.Printf("Book copy %s found in site %s", maQuyenSach, bookCopy.MaCN)
+	return bookCopy, nil
 }
 
-// UpdateBookCopy updates book copy status with authorization check
+// UpdateBookCopy updates book copy status with authorization check. Only the moves of the copy
+// lifecycle that may be made by hand are allowed; a copy lost on loan has its loan closed, and a
+// copy back on the shelf is set aside for the oldest waiting hold on its title if any.
 func (r *BookRepository) UpdateBookCopy(ctx context.Context, bookCopy *models.QuyenSach, userSite string) error {
 	// First get the existing book copy to determine which site it belongs to
 	existingCopy, err := r.GetBookCopyByID(ctx, bookCopy.MaQuyenSach)
@@ -473,21 +681,32 @@ func (r *BookRepository) UpdateBookCopy(ctx context.Context, bookCopy *models.Qu
 			existingCopy.MaCN, userSite)
 	}
 
-	db, err := r.GetConnection(existingCopy.MaCN)
+	if err := existingCopy.TinhTrang.CheckTransition(bookCopy.TinhTrang, true); err != nil {
+		return err
+	}
+
+	db, _, err := r.GetFragmentConnection("QUYENSACH", existingCopy.MaCN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to site %s: %w", existingCopy.MaCN, err)
+	}
+	loanDB, _, err := r.GetFragmentConnection("PHIEUMUON", existingCopy.MaCN)
 	if err != nil {
 		return fmt.Errorf("failed to connect to site %s: %w", existingCopy.MaCN, err)
 	}
 
 	// Execute update within transaction
-	return r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
+	later := r.newLaterTxs()
+	defer later.rollback()
+	err = r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
+		// The status read must still hold, or a loan or return made meanwhile would be overwritten
 		query := `
 			UPDATE QUYENSACH 
 			SET TinhTrang = ?
-			WHERE MaQuyenSach = ? AND MaCN = ?
+			WHERE MaQuyenSach = ? AND MaCN = ? AND TinhTrang = ?
 		`
 
 		result, err := tx.ExecContext(ctx, query,
-			bookCopy.TinhTrang, bookCopy.MaQuyenSach, existingCopy.MaCN)
+			bookCopy.TinhTrang, bookCopy.MaQuyenSach, existingCopy.MaCN, existingCopy.TinhTrang)
 		if err != nil {
 			return fmt.Errorf("failed to update book copy: %w", err)
 		}
@@ -498,12 +717,77 @@ func (r *BookRepository) UpdateBookCopy(ctx context.Context, bookCopy *models.Qu
 		}
 
 		if rowsAffected == 0 {
-			return fmt.Errorf("no book copy updated with ID: %s", bookCopy.MaQuyenSach)
+			return fmt.Errorf("book copy %s changed while being updated, retry", bookCopy.MaQuyenSach)
 		}
 
-		log.Printf("Book copy %s updated in site %s", bookCopy.MaQuyenSach, existingCopy.MaCN)
+		if existingCopy.TinhTrang == models.CopyOnLoan && bookCopy.TinhTrang == models.CopyLost {
+			if err := r.closeLostLoan(ctx, db, tx, loanDB, later, existingCopy); err != nil {
+				return err
+			}
+		}
+
+		log.Printf("Book copy %s updated in site %s: %s -> %s", bookCopy.MaQuyenSach, existingCopy.MaCN,
+			existingCopy.TinhTrang, bookCopy.TinhTrang)
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	// A fine of a lost copy on another database commits once its loan and the copy have
+	if err := later.commit(); err != nil {
+		log.Printf("Loan of lost book copy %s closed but its fine was not recorded; record it by hand: %v",
+			bookCopy.MaQuyenSach, err)
+	}
+
+	bookCopy.ISBN = existingCopy.ISBN
+	bookCopy.MaCN = existingCopy.MaCN
+	if bookCopy.TinhTrang != models.CopyAvailable {
+		return nil
+	}
+
+	// The update stands even if the queue cannot be served now; the copy then stays available
+	hold, err := r.borrows.holds.AssignCopy(ctx, bookCopy)
+	if err != nil {
+		log.Printf("Failed to serve holds on %s with book copy %s: %v", bookCopy.ISBN, bookCopy.MaQuyenSach, err)
+	} else if hold != nil {
+		log.Printf("Book copy %s set aside for hold %s", bookCopy.MaQuyenSach, hold.MaDC)
+	}
+	return nil
+}
+
+// closeLostLoan closes the loan of a copy reported lost, fining it if it was already overdue. The
+// loan is closed in the transaction of the copy when both fragments are stored on the same database;
+// a fine stored elsewhere is held in later for the caller to commit.
+func (r *BookRepository) closeLostLoan(ctx context.Context, db *sql.DB, tx *sql.Tx, loanDB *sql.DB, later *laterTxs, bookCopy *models.QuyenSach) error {
+	// Fragments stored on the same database share one transaction
+	loanTx := tx
+	if loanDB != db {
+		var err error
+		loanTx, err = loanDB.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction on site %s: %w", bookCopy.MaCN, err)
+		}
+		defer loanTx.Rollback()
+	}
+
+	// Copies lent through an inter-library loan have their loan at the requesting branch, so no
+	// loan is found here and they are not reported lost by their home branch
+	loan, fine, err := r.borrows.closeLoan(ctx, loanTx, later.join(ctx, loanDB, loanTx), bookCopy.MaQuyenSach, bookCopy.MaCN)
+	if err != nil {
+		return fmt.Errorf("cannot report book copy %s lost: %w", bookCopy.MaQuyenSach, err)
+	}
+	if loanTx != tx {
+		if err := loanTx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit loan on site %s: %w", bookCopy.MaCN, err)
+		}
+	}
+
+	if fine != nil {
+		log.Printf("Loan %d of lost book copy %s closed with fine %s", loan.MaPM, bookCopy.MaQuyenSach, fine.MaPhat)
+	} else {
+		log.Printf("Loan %d of lost book copy %s closed", loan.MaPM, bookCopy.MaQuyenSach)
+	}
+	return nil
 }
 
 // DeleteBookCopy deletes a book copy with constraint validation
@@ -520,7 +804,7 @@ func (r *BookRepository) DeleteBookCopy(ctx context.Context, maQuyenSach string,
 			existingCopy.MaCN, userSite)
 	}
 
-	db, err := r.GetConnection(existingCopy.MaCN)
+	db, _, err := r.GetFragmentConnection("QUYENSACH", existingCopy.MaCN)
 	if err != nil {
 		return fmt.Errorf("failed to connect to site %s: %w", existingCopy.MaCN, err)
 	}
@@ -570,7 +854,7 @@ func (r *BookRepository) DeleteBookCopy(ctx context.Context, maQuyenSach string,
 
 // GetBookCopiesBySite retrieves all book copies for a specific site
 func (r *BookRepository) GetBookCopiesBySite(ctx context.Context, siteID string, pagination *utils.PaginationParams) ([]*models.QuyenSach, int, error) {
-	db, err := r.GetConnection(siteID)
+	db, _, err := r.GetFragmentConnection("QUYENSACH", siteID)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
@@ -613,141 +897,52 @@ func (r *BookRepository) GetBookCopiesBySite(ctx context.Context, siteID string,
 
 // GetBookCopiesByISBN retrieves all copies of a specific book across sites
 func (r *BookRepository) GetBookCopiesByISBN(ctx context.Context, isbn string) ([]*models.QuyenSach, error) {
-	connections, err := r.GetAllSiteConnections()
+	result, err := query.Merge(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "QUYENSACH",
+		Select:   "SELECT MaQuyenSach, ISBN, MaCN, TinhTrang FROM QUYENSACH",
+		Filters:  []query.Predicate{query.Eq("ISBN", isbn)},
+		OrderBy:  "MaQuyenSach",
+	}, r.ScanQuyenSach, func(a, b *models.QuyenSach) bool {
+		return a.MaQuyenSach < b.MaQuyenSach
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get site connections: %w", err)
+		return nil, fmt.Errorf("failed to query book copies: %w", err)
 	}
 
-	var allCopies []*models.QuyenSach
-
-	query := `
-		SELECT MaQuyenSach, ISBN, MaCN, TinhTrang
-		FROM QUYENSACH
-		WHERE ISBN = ?
-		ORDER BY MaQuyenSach
-	`
-
-	for siteID, db := range connections {
-		rows, err := db.QueryContext(ctx, query, isbn)
-		if err != nil {
-			log.Printf("Error querying book copies from site %s: %v", siteID, err)
-			continue
-		}
-		defer rows.Close()
-
-		for rows.Next() {
-			bookCopy, err := r.ScanQuyenSach(rows)
-			if err != nil {
-				log.Printf("Error scanning book copy from site %s: %v", siteID, err)
-				continue
-			}
-			allCopies = append(allCopies, bookCopy)
-		}
-	}
-
-	return allCopies, nil
+	return result.Rows, nil
 }
 
-// SearchAvailableBooks searches for available books across all sites (FR7)
-func (r *BookRepository) SearchAvailableBooks(ctx context.Context, query string) ([]*models.BookSearchResult, error) {
-	connections, err := r.GetAllSiteConnections()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get site connections: %w", err)
-	}
-
-	searchPattern := "%" + query + "%"
-	bookResults := make(map[string]*models.BookSearchResult)
-
-	searchQuery := `
-		SELECT 
-			s.ISBN, s.TenSach, s.TacGia,
-			cn.MaCN, cn.TenCN, cn.DiaChi,
-			COUNT(qs.MaQuyenSach) as AvailableCount
-		FROM SACH s
-		JOIN QUYENSACH qs ON s.ISBN = qs.ISBN
-		JOIN CHINHANH cn ON qs.MaCN = cn.MaCN
-		WHERE (s.TenSach LIKE ? OR s.TacGia LIKE ?) 
-			AND qs.TinhTrang = N'Có sẵn'
-		GROUP BY s.ISBN, s.TenSach, s.TacGia, cn.MaCN, cn.TenCN, cn.DiaChi
-		ORDER BY s.TenSach
-	`
-
-	for siteID, db := range connections {
-		rows, err := db.QueryContext(ctx, searchQuery, searchPattern, searchPattern)
-		if err != nil {
-			log.Printf("Error searching books in site %s: %v", siteID, err)
-			continue
-		}
-		defer rows.Close()
-
-		for rows.Next() {
-			var isbn, tenSach, tacGia string
-			var chiNhanh models.ChiNhanh
-			var availableCount int
-
-			err := rows.Scan(&isbn, &tenSach, &tacGia,
-				&chiNhanh.MaCN, &chiNhanh.TenCN, &chiNhanh.DiaChi, &availableCount)
-			if err != nil {
-				log.Printf("Error scanning search result from site %s: %v", siteID, err)
-				continue
-			}
-
-			// Group by ISBN
-			if result, exists := bookResults[isbn]; exists {
-				// Add branch info and update count
-				result.ChiNhanh = append(result.ChiNhanh, chiNhanh)
-				result.SoLuongCo += availableCount
-			} else {
-				// Create new result
-				bookResults[isbn] = &models.BookSearchResult{
-					Sach: models.Sach{
-						ISBN:    isbn,
-						TenSach: tenSach,
-						TacGia:  tacGia,
-					},
-					ChiNhanh:  []models.ChiNhanh{chiNhanh},
-					SoLuongCo: availableCount,
-				}
-			}
-		}
+// booksWithAvailabilityQuery counts the copies of every book held by a branch, or by
+// every branch when siteID is empty. The branch is restricted in the join so that
+// books without copies are still listed.
+func booksWithAvailabilityQuery(siteID string) query.Query {
+	q := query.Query{
+		Relation: "QUYENSACH",
+		Alias:    "qs",
+		Select: `
+			SELECT
+				s.ISBN, s.TenSach, s.TacGia,
+				COUNT(qs.MaQuyenSach) as TotalCount,
+				SUM(CASE WHEN qs.TinhTrang = ? THEN 1 ELSE 0 END) as AvailableCount,
+				SUM(CASE WHEN qs.TinhTrang = ? THEN 1 ELSE 0 END) as BorrowedCount
+			FROM SACH s
+			LEFT JOIN QUYENSACH qs ON s.ISBN = qs.ISBN`,
+		Args:    []interface{}{models.CopyAvailable, models.CopyOnLoan},
+		GroupBy: "s.ISBN, s.TenSach, s.TacGia",
+		OrderBy: "s.TenSach",
 	}
-
-	// Convert map to slice
-	var results []*models.BookSearchResult
-	for _, result := range bookResults {
-		results = append(results, result)
+	if siteID != "" {
+		q.Select += " AND qs.MaCN = ?"
+		q.Args = append(q.Args, siteID)
+		q.Fragments = []string{siteID}
 	}
-
-	return results, nil
+	return q
 }
 
-// GetBooksWithAvailability retrieves books with availability info for a site
+// GetBooksWithAvailability retrieves books with availability info for a site,
+// or summed over all sites when siteID is empty
 func (r *BookRepository) GetBooksWithAvailability(ctx context.Context, siteID string) ([]*models.BookWithAvailability, error) {
-	db, err := r.GetConnection(siteID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
-	}
-
-	query := `
-		SELECT 
-			s.ISBN, s.TenSach, s.TacGia,
-			COUNT(qs.MaQuyenSach) as TotalCount,
-			SUM(CASE WHEN qs.TinhTrang = N'Có sẵn' THEN 1 ELSE 0 END) as AvailableCount,
-			SUM(CASE WHEN qs.TinhTrang = N'Đang được mượn' THEN 1 ELSE 0 END) as BorrowedCount
-		FROM SACH s
-		LEFT JOIN QUYENSACH qs ON s.ISBN = qs.ISBN AND qs.MaCN = ?
-		GROUP BY s.ISBN, s.TenSach, s.TacGia
-		ORDER BY s.TenSach
-	`
-
-	rows, err := db.QueryContext(ctx, query, siteID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query books with availability: %w", err)
-	}
-	defer rows.Close()
-
-	var books []*models.BookWithAvailability
-	for rows.Next() {
+	result, err := query.Merge(ctx, r.Executor(r.siteID), booksWithAvailabilityQuery(siteID), func(rows *sql.Rows) (*models.BookWithAvailability, error) {
 		var book models.BookWithAvailability
 		err := rows.Scan(
 			&book.ISBN, &book.TenSach, &book.TacGia,
@@ -755,15 +950,41 @@ func (r *BookRepository) GetBooksWithAvailability(ctx context.Context, siteID st
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan book with availability: %w", err)
 		}
-		books = append(books, &book)
+		return &book, nil
+	}, func(a, b *models.BookWithAvailability) bool {
+		return a.TenSach < b.TenSach
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query books with availability: %w", err)
+	}
+
+	// Every site lists every book, so sum the per-site counts of each ISBN
+	var books []*models.BookWithAvailability
+	byISBN := make(map[string]*models.BookWithAvailability)
+	for _, book := range result.Rows {
+		if existing, exists := byISBN[book.ISBN]; exists {
+			existing.TotalCount += book.TotalCount
+			existing.AvailableCount += book.AvailableCount
+			existing.BorrowedCount += book.BorrowedCount
+			continue
+		}
+		byISBN[book.ISBN] = book
+		books = append(books, book)
 	}
 
 	return books, nil
 }
 
+// ExplainBooksWithAvailability describes how GetBooksWithAvailability would run, without running it
+func (r *BookRepository) ExplainBooksWithAvailability(ctx context.Context, siteID string) (*query.Explanation, error) {
+	return query.Explain(ctx, r.Executor(r.siteID), "Books with availability",
+		"merge by TenSach, then sum the per-site counts of each ISBN",
+		query.Step{Purpose: "rows", Query: booksWithAvailabilityQuery(siteID)})
+}
+
 // CheckBookAvailability checks how many copies are available for a book at a site
 func (r *BookRepository) CheckBookAvailability(ctx context.Context, isbn string, siteID string) (int, error) {
-	db, err := r.GetConnection(siteID)
+	db, _, err := r.GetFragmentConnection("QUYENSACH", siteID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
@@ -771,11 +992,11 @@ func (r *BookRepository) CheckBookAvailability(ctx context.Context, isbn string,
 	query := `
 		SELECT COUNT(*) 
 		FROM QUYENSACH 
-		WHERE ISBN = ? AND MaCN = ? AND TinhTrang = N'Có sẵn'
+		WHERE ISBN = ? AND MaCN = ? AND TinhTrang = ?
 	`
 
 	var availableCount int
-	err = db.QueryRowContext(ctx, query, isbn, siteID).Scan(&availableCount)
+	err = db.QueryRowContext(ctx, query, isbn, siteID, models.CopyAvailable).Scan(&availableCount)
 	if err != nil {
 		return 0, fmt.Errorf("failed to check book availability: %w", err)
 	}
@@ -786,12 +1007,12 @@ func (r *BookRepository) CheckBookAvailability(ctx context.Context, isbn string,
 // TransferBookCopy transfers a book copy between sites using 2PC protocol
 func (r *BookRepository) TransferBookCopy(ctx context.Context, maQuyenSach, fromSite, toSite string) error {
 	// Get connections for both sites
-	fromDB, err := r.GetConnection(fromSite)
+	fromDB, _, err := r.GetFragmentConnection("QUYENSACH", fromSite)
 	if err != nil {
 		return fmt.Errorf("failed to connect to source site %s: %w", fromSite, err)
 	}
 
-	toDB, err := r.GetConnection(toSite)
+	toDB, _, err := r.GetFragmentConnection("QUYENSACH", toSite)
 	if err != nil {
 		return fmt.Errorf("failed to connect to destination site %s: %w", toSite, err)
 	}
@@ -832,7 +1053,7 @@ func (r *BookRepository) TransferBookCopy(ctx context.Context, maQuyenSach, from
 	}
 
 	// Verify book copy is available for transfer
-	if bookCopy.TinhTrang != "Có sẵn" {
+	if bookCopy.TinhTrang != models.CopyAvailable {
 		return fmt.Errorf("book copy %s is not available for transfer (status: %s)",
 			maQuyenSach, bookCopy.TinhTrang)
 	}
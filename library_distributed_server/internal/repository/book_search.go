@@ -0,0 +1,446 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"library_distributed_server/internal/cache"
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
+	"library_distributed_server/internal/search"
+	"library_distributed_server/pkg/utils"
+	"sort"
+	"strconv"
+)
+
+const (
+	// maxSearchMatches caps the best index matches SearchAvailableBooks considers, and the
+	// number of ISBNs shipped to the sites, well below SQL Server's limit of 2100 parameters
+	maxSearchMatches = 500
+
+	maxAuthorFacets = 20 // Most frequent authors reported as facets
+
+	statusAvailable = string(models.CopyAvailable)
+)
+
+// catalogEntry is a matching book with the copies held at every branch
+type catalogEntry struct {
+	hit    search.Hit
+	copies map[string]map[string]int // Copies by MaCN and TinhTrang
+}
+
+// availableAt reports whether the branch holds an available copy
+func (e catalogEntry) availableAt(maCN string) bool {
+	return e.copies[maCN][statusAvailable] > 0
+}
+
+// available returns the number of available copies over all branches
+func (e catalogEntry) available() int {
+	total := 0
+	for _, statuses := range e.copies {
+		total += statuses[statusAvailable]
+	}
+	return total
+}
+
+// hasStatus reports whether any copy is in the status
+func (e catalogEntry) hasStatus(status string) bool {
+	for _, statuses := range e.copies {
+		if statuses[status] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// facet identifies the filter a facet count leaves out
+type facet int
+
+const (
+	facetNone facet = iota
+	facetAuthor
+	facetBranch
+	facetStatus
+	facetHasCopies
+)
+
+// matchesFilter reports whether an entry passes every filter except the one of the skipped facet
+func matchesFilter(e catalogEntry, filter models.CatalogFilter, skip facet) bool {
+	if skip != facetAuthor && len(filter.Authors) > 0 {
+		author := search.Fold(e.hit.TacGia)
+		matched := false
+		for _, a := range filter.Authors {
+			if search.Fold(a) == author {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if skip != facetBranch && len(filter.Branches) > 0 {
+		matched := false
+		for _, maCN := range filter.Branches {
+			if e.availableAt(maCN) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if skip != facetStatus && len(filter.Statuses) > 0 {
+		matched := false
+		for _, status := range filter.Statuses {
+			if e.hasStatus(status) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if skip != facetHasCopies && filter.HasCopies != nil && *filter.HasCopies != (len(e.copies) > 0) {
+		return false
+	}
+	return true
+}
+
+// catalogFacets counts the entries per filter value. Each facet applies every filter
+// except its own, so selecting a value shows the number of books in its count.
+func catalogFacets(entries []catalogEntry, filter models.CatalogFilter, branches map[string]models.ChiNhanh) *models.CatalogFacets {
+	branchCounts := make(map[string]int)
+	authorCounts := make(map[string]int)
+	statusCounts := make(map[string]int)
+	copyCounts := make(map[string]int)
+
+	for _, e := range entries {
+		if matchesFilter(e, filter, facetBranch) {
+			for maCN := range e.copies {
+				if e.availableAt(maCN) {
+					branchCounts[maCN]++
+				}
+			}
+		}
+		if matchesFilter(e, filter, facetAuthor) && e.hit.TacGia != "" {
+			authorCounts[e.hit.TacGia]++
+		}
+		if matchesFilter(e, filter, facetStatus) {
+			statuses := make(map[string]bool)
+			for _, byStatus := range e.copies {
+				for status, n := range byStatus {
+					if n > 0 {
+						statuses[status] = true
+					}
+				}
+			}
+			for status := range statuses {
+				statusCounts[status]++
+			}
+		}
+		if matchesFilter(e, filter, facetHasCopies) {
+			copyCounts[strconv.FormatBool(len(e.copies) > 0)]++
+		}
+	}
+
+	facets := &models.CatalogFacets{
+		Branches:  facetCounts(branchCounts, 0),
+		Authors:   facetCounts(authorCounts, maxAuthorFacets),
+		Statuses:  facetCounts(statusCounts, 0),
+		HasCopies: facetCounts(copyCounts, 0),
+	}
+	for i := range facets.Branches {
+		facets.Branches[i].Label = branches[facets.Branches[i].Value].TenCN
+	}
+	return facets
+}
+
+// facetCounts orders counts by frequency, then value, keeping at most limit of them (all when 0)
+func facetCounts(counts map[string]int, limit int) []models.FacetCount {
+	facets := make([]models.FacetCount, 0, len(counts))
+	for value, count := range counts {
+		facets = append(facets, models.FacetCount{Value: value, Count: count})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Value < facets[j].Value
+	})
+	if limit > 0 && len(facets) > limit {
+		facets = facets[:limit]
+	}
+	return facets
+}
+
+// catalogMatches returns the books matching the search text, best first, or every book in
+// ISBN order when the text is empty. ranked reports whether the search index scored them;
+// until it is built the local replica is searched with LIKE patterns, in title order.
+func (r *BookRepository) catalogMatches(ctx context.Context, searchText string) ([]search.Hit, bool, error) {
+	if index := r.searchIndex(); index != nil {
+		if searchText != "" {
+			return index.Search(searchText, 0), true, nil
+		}
+		var hits []search.Hit
+		for _, doc := range index.All() {
+			hits = append(hits, search.Hit{Document: doc})
+		}
+		return hits, false, nil
+	}
+
+	db, _, err := r.GetFragmentConnection("SACH", r.siteID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to connect to site %s: %w", r.siteID, err)
+	}
+
+	statement := "SELECT ISBN, TenSach, TacGia FROM SACH ORDER BY ISBN"
+	var args []interface{}
+	if searchText != "" {
+		searchPattern := "%" + searchText + "%"
+		statement = "SELECT ISBN, TenSach, TacGia FROM SACH WHERE TenSach LIKE ? OR TacGia LIKE ? ORDER BY TenSach"
+		args = []interface{}{searchPattern, searchPattern}
+	}
+
+	rows, err := db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to search books: %w", err)
+	}
+	defer rows.Close()
+
+	var hits []search.Hit
+	for rows.Next() {
+		book, err := r.ScanSach(rows)
+		if err != nil {
+			return nil, false, err
+		}
+		hits = append(hits, search.Hit{Document: search.Document{ISBN: book.ISBN, TenSach: book.TenSach, TacGia: book.TacGia}})
+	}
+	return hits, false, rows.Err()
+}
+
+// copySummaryQuery counts the copies per book, branch and TinhTrang at every QUYENSACH
+// fragment, for the given books or for all books when isbns is nil
+func copySummaryQuery(isbns []string) query.Query {
+	q := query.Query{
+		Relation: "QUYENSACH",
+		Select:   "SELECT ISBN, MaCN, TinhTrang, COUNT(*) FROM QUYENSACH",
+		GroupBy:  "ISBN, MaCN, TinhTrang",
+	}
+	if isbns != nil {
+		values := make([]interface{}, len(isbns))
+		for i, isbn := range isbns {
+			values[i] = isbn
+		}
+		q.Filters = []query.Predicate{query.In("ISBN", values...)}
+	}
+	return q
+}
+
+// summaryISBNs returns the ISBNs to ship with the copy summary, or nil to summarize
+// every book when there are too many matches to list
+func summaryISBNs(hits []search.Hit) []string {
+	if len(hits) > maxSearchMatches {
+		return nil
+	}
+	isbns := make([]string, len(hits))
+	for i, hit := range hits {
+		isbns[i] = hit.ISBN
+	}
+	return isbns
+}
+
+// copyCount is one row of the copy summary
+type copyCount struct {
+	isbn, maCN, status string
+	count              int
+}
+
+// catalogEntries attaches the copies held at every fragment to the matching books, keeping their order
+func (r *BookRepository) catalogEntries(ctx context.Context, hits []search.Hit) ([]catalogEntry, error) {
+	entries := make([]catalogEntry, len(hits))
+	if len(hits) == 0 {
+		return entries, nil
+	}
+
+	result, err := query.Union(ctx, r.Executor(r.siteID), copySummaryQuery(summaryISBNs(hits)), func(rows *sql.Rows) (copyCount, error) {
+		var c copyCount
+		if err := rows.Scan(&c.isbn, &c.maCN, &c.status, &c.count); err != nil {
+			return c, fmt.Errorf("failed to scan copy summary: %w", err)
+		}
+		return c, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize book copies: %w", err)
+	}
+
+	byISBN := make(map[string]*catalogEntry, len(hits))
+	for i, hit := range hits {
+		entries[i] = catalogEntry{hit: hit, copies: make(map[string]map[string]int)}
+		byISBN[hit.ISBN] = &entries[i]
+	}
+	for _, c := range result.Rows {
+		entry, exists := byISBN[c.isbn]
+		if !exists {
+			continue
+		}
+		if entry.copies[c.maCN] == nil {
+			entry.copies[c.maCN] = make(map[string]int)
+		}
+		entry.copies[c.maCN][c.status] += c.count
+	}
+	return entries, nil
+}
+
+// branches returns the branches of the local CHINHANH replica by MaCN
+func (r *BookRepository) branches(ctx context.Context) (map[string]models.ChiNhanh, error) {
+	list, err := cache.Page(r.cache, "CHINHANH", "all", func() ([]models.ChiNhanh, error) {
+		db, _, err := r.GetFragmentConnection("CHINHANH", r.siteID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to site %s: %w", r.siteID, err)
+		}
+		rows, err := db.QueryContext(ctx, "SELECT MaCN, TenCN, DiaChi FROM CHINHANH ORDER BY MaCN")
+		if err != nil {
+			return nil, fmt.Errorf("failed to query branches: %w", err)
+		}
+		defer rows.Close()
+
+		var branches []models.ChiNhanh
+		for rows.Next() {
+			branch, err := r.ScanChiNhanh(rows)
+			if err != nil {
+				return nil, err
+			}
+			branches = append(branches, *branch)
+		}
+		return branches, rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]models.ChiNhanh, len(list))
+	for _, branch := range list {
+		byID[branch.MaCN] = branch
+	}
+	return byID, nil
+}
+
+// SearchCatalog searches the catalog like SearchBooks, narrows the matches with the filter
+// and counts the facets of the matches, with copies merged from every QUYENSACH fragment
+func (r *BookRepository) SearchCatalog(ctx context.Context, searchText string, filter models.CatalogFilter, pagination *utils.PaginationParams) ([]*models.ScoredSach, int, *models.CatalogFacets, error) {
+	hits, _, err := r.catalogMatches(ctx, searchText)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	entries, err := r.catalogEntries(ctx, hits)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	branches, err := r.branches(ctx)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	books := []*models.ScoredSach{}
+	for _, e := range entries {
+		if matchesFilter(e, filter, facetNone) {
+			books = append(books, &models.ScoredSach{
+				Sach:  models.Sach{ISBN: e.hit.ISBN, TenSach: e.hit.TenSach, TacGia: e.hit.TacGia},
+				Score: e.hit.Score,
+			})
+		}
+	}
+
+	facets := catalogFacets(entries, filter, branches)
+	totalCount := len(books)
+	if pagination != nil {
+		offset := pagination.CalculateOffset()
+		end := offset + pagination.Size
+
+		if offset >= len(books) {
+			return []*models.ScoredSach{}, totalCount, facets, nil
+		}
+
+		if end > len(books) {
+			end = len(books)
+		}
+
+		books = books[offset:end]
+	}
+
+	return books, totalCount, facets, nil
+}
+
+// SearchAvailableBooks searches for books with an available copy at any site (FR7), best
+// matches first, narrowed by the filter, with the facet counts of the available matches
+func (r *BookRepository) SearchAvailableBooks(ctx context.Context, searchText string, filter models.CatalogFilter) ([]*models.BookSearchResult, *models.CatalogFacets, error) {
+	hits, ranked, err := r.catalogMatches(ctx, searchText)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ranked && len(hits) > maxSearchMatches {
+		hits = hits[:maxSearchMatches]
+	}
+	entries, err := r.catalogEntries(ctx, hits)
+	if err != nil {
+		return nil, nil, err
+	}
+	branches, err := r.branches(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var available []catalogEntry
+	for _, e := range entries {
+		if e.available() > 0 {
+			available = append(available, e)
+		}
+	}
+
+	results := []*models.BookSearchResult{}
+	for _, e := range available {
+		if !matchesFilter(e, filter, facetNone) {
+			continue
+		}
+		book := &models.BookSearchResult{
+			Sach:      models.Sach{ISBN: e.hit.ISBN, TenSach: e.hit.TenSach, TacGia: e.hit.TacGia},
+			ChiNhanh:  []models.ChiNhanh{},
+			SoLuongCo: e.available(),
+			Score:     e.hit.Score,
+		}
+		for maCN := range e.copies {
+			if e.availableAt(maCN) {
+				branch, exists := branches[maCN]
+				if !exists {
+					branch = models.ChiNhanh{MaCN: maCN}
+				}
+				book.ChiNhanh = append(book.ChiNhanh, branch)
+			}
+		}
+		sort.Slice(book.ChiNhanh, func(i, j int) bool { return book.ChiNhanh[i].MaCN < book.ChiNhanh[j].MaCN })
+		results = append(results, book)
+	}
+
+	return results, catalogFacets(available, filter, branches), nil
+}
+
+// ExplainSearchAvailableBooks describes how SearchAvailableBooks would run, without running it
+func (r *BookRepository) ExplainSearchAvailableBooks(ctx context.Context, searchText string) (*query.Explanation, error) {
+	hits, ranked, err := r.catalogMatches(ctx, searchText)
+	if err != nil {
+		return nil, err
+	}
+	if ranked && len(hits) > maxSearchMatches {
+		hits = hits[:maxSearchMatches]
+	}
+
+	merge := "match books in the local SACH replica with LIKE patterns, union the per-site copy counts, then filter and count facets"
+	if ranked {
+		merge = "match books in the search index, union the per-site copy counts, then filter, count facets and rank by relevance"
+	}
+	return query.Explain(ctx, r.Executor(r.siteID), "Search available books", merge,
+		query.Step{Purpose: "copies", Query: copySummaryQuery(summaryISBNs(hits))})
+}
@@ -0,0 +1,454 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
+	"log"
+	"sort"
+	"time"
+)
+
+// CreateBorrows lends several copies of the user's site to a reader at once (FR2). Every copy is
+// checked as for a single loan, and the reader's loan limit is checked against all of them
+// together; the loans are then made in one transaction, so either every copy is lent or none is.
+// Holds stored on the same database are claimed in that transaction too; others are claimed
+// first and given back if the loans fail. A batch refused by a copy or a circulation rule is
+// reported as a *models.BatchRejection carrying the outcome for each copy.
+func (r *BorrowRepository) CreateBorrows(ctx context.Context, maDG string, copies []string, userSite string) (*models.BatchReceipt, error) {
+	db, fragmentSite, err := r.GetFragmentConnection("PHIEUMUON", userSite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to site %s: %w", userSite, err)
+	}
+
+	// Validate fragmentation constraint
+	data := map[string]interface{}{
+		"MaCN": userSite,
+	}
+	if err := r.ValidateFragmentation(ctx, "PHIEUMUON", data, fragmentSite); err != nil {
+		return nil, fmt.Errorf("fragmentation validation failed: %w", err)
+	}
+
+	receipt := newBatchReceipt(userSite, copies)
+	receipt.MaDG = maDG
+
+	// The reader may be registered at another branch; their home row serializes their loans
+	reader, err := r.homeReader(ctx, maDG)
+	if err != nil {
+		return nil, fmt.Errorf("borrow validation failed: %w", err)
+	}
+	homeDB, _, err := r.GetFragmentConnection("DOCGIA", reader.MaCNDangKy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to site %s: %w", reader.MaCNDangKy, err)
+	}
+	homeTx, err := homeDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction on site %s: %w", reader.MaCNDangKy, err)
+	}
+	defer homeTx.Rollback()
+	if err := lockReader(ctx, homeTx, maDG); err != nil {
+		return nil, fmt.Errorf("borrow validation failed: %w", err)
+	}
+
+	// Every copy is checked, so that all the copies to put aside are reported at once
+	for _, item := range receipt.Items {
+		if item.Error != "" {
+			continue
+		}
+		// The loans are made in one transaction at the user's site, which a copy shipped here for
+		// a hold is not lent on
+		if lender, err := r.lender(ctx, maDG, item.MaQuyenSach, userSite); err == nil && lender != userSite {
+			item.Error = fmt.Sprintf("book copy %s of site %s shipped for a hold is lent on its own, not in a batch", item.MaQuyenSach, lender)
+			continue
+		}
+		if err := r.checkCopy(ctx, maDG, item.MaQuyenSach, userSite); err != nil {
+			item.Error = err.Error()
+		}
+	}
+	policy, err := r.checkReader(ctx, reader, userSite, len(receipt.Items))
+	if err != nil {
+		var violation *models.PolicyViolation
+		if !errors.As(err, &violation) {
+			return nil, fmt.Errorf("borrow validation failed: %w", err)
+		}
+		receipt.Violation = violation
+	}
+	if failures := batchFailures(receipt); failures > 0 {
+		return nil, rejectBatch(receipt, "%d of %d copies cannot be lent", failures, len(receipt.Items))
+	}
+	if receipt.Violation != nil {
+		return nil, rejectBatch(receipt, "%s", receipt.Violation.Reason)
+	}
+
+	// Copies set aside for the reader's holds are claimed with the loans when the hold is stored
+	// on the same database, and beforehand otherwise, as for a single loan
+	var claimed []*models.DatCho
+	holds := make(map[string]*models.DatCho)
+	restoreHolds := func() {
+		for _, hold := range claimed {
+			// Give the reader their hold back
+			if _, err := r.holds.setHoldState(ctx, hold, models.HoldFulfilled, models.HoldReady); err != nil {
+				log.Printf("Failed to restore hold %s after failed batch borrow: %v", hold.MaDC, err)
+			}
+		}
+	}
+	for _, item := range receipt.Items {
+		hold, err := r.heldFor(ctx, item.MaQuyenSach, maDG)
+		if err == nil && hold != nil {
+			holds[item.MaQuyenSach] = hold
+			var holdDB *sql.DB
+			if holdDB, _, err = r.GetFragmentConnection("DATCHO", hold.MaCN); err == nil && holdDB != db {
+				if err = r.claimHold(ctx, hold); err == nil {
+					claimed = append(claimed, hold)
+				}
+			}
+		}
+		if err != nil {
+			restoreHolds()
+			item.Error = err.Error()
+			return nil, rejectBatch(receipt, "book copy %s cannot be lent", item.MaQuyenSach)
+		}
+	}
+
+	// All loans are made in one transaction
+	err = r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
+		for _, item := range receipt.Items {
+			// A hold still ready is stored with the loans and claimed here
+			if hold := holds[item.MaQuyenSach]; hold != nil && hold.TrangThai == models.HoldReady {
+				if err := claimHoldIn(ctx, tx, hold); err != nil {
+					item.Error = err.Error()
+					return err
+				}
+			}
+
+			borrow := &models.PhieuMuon{
+				MaDG:        maDG,
+				MaQuyenSach: item.MaQuyenSach,
+				MaCN:        userSite,
+			}
+			if err := r.lend(ctx, tx, borrow, policy, holds[item.MaQuyenSach] != nil); err != nil {
+				item.Error = err.Error()
+				return err
+			}
+			item.Borrow = borrow
+		}
+		return nil
+	})
+	if err != nil {
+		restoreHolds()
+		for _, item := range receipt.Items {
+			item.Borrow = nil
+		}
+		if batchFailures(receipt) == 0 {
+			return nil, err
+		}
+		return nil, rejectBatch(receipt, "no copy was lent: %v", err)
+	}
+
+	// The loans are committed; ending the lock transaction lets the reader's next loan count them
+	if err := homeTx.Commit(); err != nil {
+		log.Printf("Failed to release lock on reader %s at site %s: %v", maDG, reader.MaCNDangKy, err)
+	}
+
+	receipt.Committed = true
+	for _, item := range receipt.Items {
+		item.Success = true
+		if receipt.DueDate == nil || item.Borrow.HanTra.Before(*receipt.DueDate) {
+			dueDate := item.Borrow.HanTra
+			receipt.DueDate = &dueDate
+		}
+	}
+	log.Printf("Batch of %d loans made for reader %s in site %s", len(receipt.Items), maDG, userSite)
+	return receipt, nil
+}
+
+// ReturnBooks takes back several copies at the user's site at once (FR3). Every copy must be on
+// loan from its home branch, as for a single return; copies of other branches are shipped home.
+// The returns are made in one transaction per database involved, committed only once every copy
+// has been processed, so a batch refused by a copy changes nothing. A refused batch is reported
+// as a *models.BatchRejection carrying the outcome for each copy. Once processed, the returns
+// are committed database by database and are best-effort, not all-or-nothing: the transactions
+// recording transfers commit first, as for a single return elsewhere, then the loans, then fines
+// stored apart from them. A commit failing after others went through leaves those returns made
+// and is reported as a *models.BatchIncomplete whose receipt marks the copies returned; the
+// others are still on loan and can be returned again.
+func (r *BorrowRepository) ReturnBooks(ctx context.Context, copies []string, userSite string) (*models.BatchReceipt, error) {
+	receipt := newBatchReceipt(userSite, copies)
+
+	ids := make([]interface{}, len(copies))
+	for i, maQuyenSach := range copies {
+		ids[i] = maQuyenSach
+	}
+
+	// MaQuyenSach does not determine the fragment, so every fragment is searched for all copies at once
+	found, err := query.Union(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "QUYENSACH",
+		Select:   "SELECT MaQuyenSach, ISBN, MaCN, TinhTrang FROM QUYENSACH",
+		Filters:  []query.Predicate{query.In("MaQuyenSach", ids...)},
+	}, r.ScanQuyenSach)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up book copies: %w", err)
+	}
+	if len(found.Failed) > 0 {
+		return nil, fmt.Errorf("cannot look up book copies: sites %s unavailable", failedSites(found.Failed))
+	}
+	active, err := query.Union(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "PHIEUMUON",
+		Select:   "SELECT MaPM, MaDG, MaQuyenSach, MaCN, NgayMuon, NgayTra, HanTra, SoLanGiaHan FROM PHIEUMUON",
+		Filters: []query.Predicate{
+			query.In("MaQuyenSach", ids...),
+			query.Where("NgayTra IS NULL"),
+		},
+	}, r.scanPhieuMuon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find borrow records: %w", err)
+	}
+	if len(active.Failed) > 0 {
+		return nil, fmt.Errorf("cannot find borrow records: sites %s unavailable", failedSites(active.Failed))
+	}
+
+	bookCopies := make(map[string]*models.QuyenSach, len(found.Rows))
+	for _, bookCopy := range found.Rows {
+		bookCopies[bookCopy.MaQuyenSach] = bookCopy
+	}
+	loans := make(map[string]*models.PhieuMuon, len(active.Rows))
+	for _, loan := range active.Rows {
+		loans[loan.MaQuyenSach] = loan
+	}
+
+	// Every copy is checked, so that all the copies to put aside are reported at once
+	for _, item := range receipt.Items {
+		if item.Error != "" {
+			continue
+		}
+		bookCopy, loan := bookCopies[item.MaQuyenSach], loans[item.MaQuyenSach]
+		switch {
+		case bookCopy == nil:
+			item.Error = fmt.Sprintf("book copy not found: %s", item.MaQuyenSach)
+		case loan == nil:
+			item.Error = fmt.Sprintf("no active borrow record found for book copy %s", item.MaQuyenSach)
+		case loan.MaCN != bookCopy.MaCN:
+			item.Error = fmt.Sprintf("book copy %s of site %s was lent by site %s through an inter-library loan; return it through the request",
+				item.MaQuyenSach, bookCopy.MaCN, loan.MaCN)
+		}
+	}
+	if failures := batchFailures(receipt); failures > 0 {
+		return nil, rejectBatch(receipt, "%d of %d copies cannot be returned", failures, len(receipt.Items))
+	}
+
+	// Fragments stored on the same database share one transaction
+	type batchTx struct {
+		tx        *sql.Tx
+		siteID    string
+		transfers bool // Records transfers, so commits before the others
+		fines     bool // Records only fines, so commits after the loans they charge
+	}
+	txs := make(map[*sql.DB]*batchTx)
+	var order []*sql.DB
+	used := make(map[*models.BatchItem][]*sql.DB)
+	fined := make(map[*models.BatchItem]*sql.DB)
+	defer func() {
+		for _, btx := range txs {
+			btx.tx.Rollback()
+		}
+	}()
+	begin := func(item *models.BatchItem, relation, siteID string) (*sql.DB, *sql.Tx, error) {
+		db, _, err := r.GetFragmentConnection(relation, siteID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
+		}
+		btx, ok := txs[db]
+		if !ok {
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to begin transaction on site %s: %w", siteID, err)
+			}
+			btx = &batchTx{tx: tx, siteID: siteID, fines: relation == "PHAT"}
+			txs[db] = btx
+			order = append(order, db)
+		}
+		// A fine does not make its copy's return depend on its database
+		if relation == "PHAT" {
+			fined[item] = db
+		} else {
+			used[item] = append(used[item], db)
+			btx.fines = false
+		}
+		btx.transfers = btx.transfers || relation == "CHUYENTRA"
+		return db, btx.tx, nil
+	}
+	join := func(item *models.BatchItem) joinTx {
+		return func(relation, siteID string) (*sql.Tx, error) {
+			_, tx, err := begin(item, relation, siteID)
+			return tx, err
+		}
+	}
+
+	for _, item := range receipt.Items {
+		bookCopy := bookCopies[item.MaQuyenSach]
+		_, tx, err := begin(item, "PHIEUMUON", bookCopy.MaCN)
+		if err != nil {
+			return nil, err
+		}
+
+		if bookCopy.MaCN == userSite {
+			_, item.Fine, err = r.shelve(ctx, tx, join(item), bookCopy)
+		} else {
+			var transferTx *sql.Tx
+			if _, transferTx, err = begin(item, "CHUYENTRA", bookCopy.MaCN); err != nil {
+				return nil, err
+			}
+			item.Transfer = &models.ChuyenTra{
+				MaCT:        newRowID(bookCopy.MaCN),
+				MaQuyenSach: bookCopy.MaQuyenSach,
+				ISBN:        bookCopy.ISBN,
+				MaCN:        bookCopy.MaCN,
+				MaCNNhanTra: userSite,
+				TrangThai:   models.TransferInTransit,
+				NgayNhanTra: time.Now(),
+			}
+			if err = r.recordTransfer(ctx, transferTx, item.Transfer); err == nil {
+				item.Fine, err = r.ship(ctx, tx, join(item), bookCopy)
+			}
+		}
+		if err != nil {
+			for _, other := range receipt.Items {
+				other.Fine, other.Transfer = nil, nil
+			}
+			item.Error = err.Error()
+			return nil, rejectBatch(receipt, "no copy was returned: book copy %s cannot be returned", item.MaQuyenSach)
+		}
+	}
+
+	// A transfer committed without its loan stays listed, and returning the copy again finishes it.
+	// Fines come last, so that none is recorded for a loan left open.
+	rank := func(btx *batchTx) int {
+		switch {
+		case btx.transfers:
+			return 0
+		case btx.fines:
+			return 2
+		}
+		return 1
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return rank(txs[order[i]]) < rank(txs[order[j]])
+	})
+	committed := make(map[*sql.DB]bool, len(order))
+	var commitErr error
+	for _, db := range order {
+		btx := txs[db]
+		if btx.fines {
+			if commitErr != nil {
+				break
+			}
+			if err := btx.tx.Commit(); err != nil {
+				log.Printf("Batch return in site %s failed to commit fines on site %s; record them by hand: %v", userSite, btx.siteID, err)
+			} else {
+				committed[db] = true
+			}
+			delete(txs, db)
+			continue
+		}
+		if err := btx.tx.Commit(); err != nil {
+			commitErr = fmt.Errorf("failed to commit returns on site %s: %w", btx.siteID, err)
+			break
+		}
+		committed[db] = true
+		delete(txs, db)
+	}
+	if commitErr != nil && len(committed) == 0 {
+		return nil, commitErr
+	}
+
+	returned := 0
+	for _, item := range receipt.Items {
+		for _, db := range used[item] {
+			if !committed[db] {
+				item.Error = fmt.Sprintf("book copy %s was not returned: %v; return it again", item.MaQuyenSach, commitErr)
+				item.Fine, item.Transfer = nil, nil
+				break
+			}
+		}
+		if item.Error != "" {
+			continue
+		}
+		if db, ok := fined[item]; ok && !committed[db] {
+			if item.Fine != nil {
+				log.Printf("Loan of book copy %s closed but fine %s of %d was not recorded; record it by hand",
+					item.MaQuyenSach, item.Fine.MaPhat, item.Fine.SoTien)
+			}
+			item.Fine = nil
+		}
+		item.Success = true
+		returned++
+		if item.Fine != nil {
+			receipt.TotalFine += item.Fine.SoTien
+		}
+		bookCopy := bookCopies[item.MaQuyenSach]
+		if bookCopy.MaCN != userSite {
+			continue
+		}
+
+		// The returns stand even if the queue cannot be served now; the copy then stays available
+		bookCopy.TinhTrang = models.CopyAvailable
+		hold, err := r.holds.AssignCopy(ctx, bookCopy)
+		if err != nil {
+			log.Printf("Failed to serve holds on %s with returned copy %s: %v", bookCopy.ISBN, bookCopy.MaQuyenSach, err)
+			continue
+		}
+		item.Hold = hold
+	}
+	if commitErr != nil {
+		log.Printf("Batch return in site %s committed %d of %d returns: %v", userSite, returned, len(receipt.Items), commitErr)
+		return nil, &models.BatchIncomplete{
+			Reason:  fmt.Sprintf("%d of %d copies returned: %v", returned, len(receipt.Items), commitErr),
+			Receipt: receipt,
+		}
+	}
+
+	receipt.Committed = true
+	log.Printf("Batch of %d returns taken back in site %s", len(receipt.Items), userSite)
+	return receipt, nil
+}
+
+// newBatchReceipt starts the receipt of a batch of copies processed at siteID, with an item per
+// copy in request order. A copy listed twice fails on its second listing.
+func newBatchReceipt(siteID string, copies []string) *models.BatchReceipt {
+	receipt := &models.BatchReceipt{
+		MaCN:        siteID,
+		ProcessedAt: time.Now(),
+		Items:       make([]*models.BatchItem, len(copies)),
+	}
+	seen := make(map[string]bool, len(copies))
+	for i, maQuyenSach := range copies {
+		receipt.Items[i] = &models.BatchItem{MaQuyenSach: maQuyenSach}
+		if seen[maQuyenSach] {
+			receipt.Items[i].Error = fmt.Sprintf("book copy %s is listed more than once", maQuyenSach)
+		}
+		seen[maQuyenSach] = true
+	}
+	return receipt
+}
+
+// batchFailures counts the copies of a batch that failed
+func batchFailures(receipt *models.BatchReceipt) int {
+	failures := 0
+	for _, item := range receipt.Items {
+		if item.Error != "" {
+			failures++
+		}
+	}
+	return failures
+}
+
+// rejectBatch refuses a whole batch, marking the copies that did not fail as passing
+func rejectBatch(receipt *models.BatchReceipt, format string, args ...interface{}) *models.BatchRejection {
+	for _, item := range receipt.Items {
+		item.Success = item.Error == ""
+	}
+	return &models.BatchRejection{Reason: fmt.Sprintf(format, args...), Receipt: receipt}
+}
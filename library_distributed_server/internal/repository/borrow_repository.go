@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"library_distributed_server/internal/config"
 	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
 	"library_distributed_server/pkg/utils"
 	"log"
 	"time"
@@ -14,20 +15,24 @@ import (
 // BorrowRepository handles borrow operations using raw SQL queries
 type BorrowRepository struct {
 	*BaseRepository
-	siteID string // Current site for this repository instance
+	siteID string          // Current site for this repository instance
+	holds  *HoldRepository // Hold queue served by returns and checked by loans
 }
 
 // BorrowRepositoryInterface defines borrow-related operations with raw SQL
 type BorrowRepositoryInterface interface {
 	// Core borrow operations (FR2, FR3)
 	CreateBorrow(ctx context.Context, borrow *models.PhieuMuon, userSite string) error
-	ReturnBook(ctx context.Context, maQuyenSach string, userSite string) error
+	ReturnBook(ctx context.Context, maQuyenSach string, userSite string) (*models.ReturnBookResponse, error)
+	CreateBorrows(ctx context.Context, maDG string, copies []string, userSite string) (*models.BatchReceipt, error)
+	ReturnBooks(ctx context.Context, copies []string, userSite string) (*models.BatchReceipt, error)
+	RenewBorrow(ctx context.Context, maPM int, userSite string) (*models.PhieuMuon, error)
 	GetBorrowByID(ctx context.Context, maPM int) (*models.PhieuMuon, error)
 	GetBorrowsBySite(ctx context.Context, siteID string, pagination *utils.PaginationParams) ([]*models.PhieuMuon, int, error)
 
 	// Advanced borrow operations
 	GetActiveBorrowsByReader(ctx context.Context, maDG string) ([]*models.PhieuMuon, error)
-	GetBorrowHistory(ctx context.Context, maDG string, pagination *utils.PaginationParams) ([]*models.PhieuMuon, int, error)
+	GetBorrowHistory(ctx context.Context, maDG string, pagination *utils.PaginationParams) (*query.Page[*models.PhieuMuon], error)
 	GetOverdueBooks(ctx context.Context, siteID string) ([]*models.BorrowRecordWithDetails, error)
 	GetBorrowRecordsWithDetails(ctx context.Context, siteID string, pagination *utils.PaginationParams) ([]*models.BorrowRecordWithDetails, int, error)
 
@@ -41,21 +46,29 @@ type BorrowRepositoryInterface interface {
 }
 
 // NewBorrowRepository creates a new borrow repository with raw SQL
-func NewBorrowRepository(config *config.Config, siteID string) BorrowRepositoryInterface {
+func NewBorrowRepository(store *config.Store, siteID string) BorrowRepositoryInterface {
+	base := NewBaseRepository(store)
 	return &BorrowRepository{
-		BaseRepository: NewBaseRepository(config),
+		BaseRepository: base,
 		siteID:         siteID,
+		holds:          &HoldRepository{BaseRepository: base, siteID: siteID},
 	}
 }
 
-// CreateBorrow creates a new borrow record with validation (FR2)
+// CreateBorrow creates a new borrow record with validation (FR2). A copy of another branch
+// shipped to userSite for the reader's hold is lent there on its own branch's books (see lender).
 func (r *BorrowRepository) CreateBorrow(ctx context.Context, borrow *models.PhieuMuon, userSite string) error {
 	// Authorization: only allow creation in user's site
 	if borrow.MaCN != userSite {
 		return fmt.Errorf("access denied: cannot create borrow in site %s from site %s", borrow.MaCN, userSite)
 	}
+	lender, err := r.lender(ctx, borrow.MaDG, borrow.MaQuyenSach, userSite)
+	if err != nil {
+		return fmt.Errorf("borrow validation failed: %w", err)
+	}
+	borrow.MaCN = lender
 
-	db, err := r.GetConnection(borrow.MaCN)
+	db, fragmentSite, err := r.GetFragmentConnection("PHIEUMUON", borrow.MaCN)
 	if err != nil {
 		return fmt.Errorf("failed to connect to site %s: %w", borrow.MaCN, err)
 	}
@@ -64,173 +77,569 @@ func (r *BorrowRepository) CreateBorrow(ctx context.Context, borrow *models.Phie
 	data := map[string]interface{}{
 		"MaCN": borrow.MaCN,
 	}
-	if err := r.ValidateFragmentation(ctx, "PHIEUMUON", data, borrow.MaCN); err != nil {
+	if err := r.ValidateFragmentation(ctx, "PHIEUMUON", data, fragmentSite); err != nil {
 		return fmt.Errorf("fragmentation validation failed: %w", err)
 	}
 
-	// Validate borrow eligibility
-	if err := r.CanBorrowBook(ctx, borrow.MaDG, borrow.MaQuyenSach, borrow.MaCN); err != nil {
+	// The reader may be registered at another branch; their home row serializes their loans
+	reader, err := r.homeReader(ctx, borrow.MaDG)
+	if err != nil {
+		return fmt.Errorf("borrow validation failed: %w", err)
+	}
+	homeDB, _, err := r.GetFragmentConnection("DOCGIA", reader.MaCNDangKy)
+	if err != nil {
+		return fmt.Errorf("failed to connect to site %s: %w", reader.MaCNDangKy, err)
+	}
+	homeTx, err := homeDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction on site %s: %w", reader.MaCNDangKy, err)
+	}
+	defer homeTx.Rollback()
+	if err := lockReader(ctx, homeTx, borrow.MaDG); err != nil {
 		return fmt.Errorf("borrow validation failed: %w", err)
 	}
 
-	// Execute borrow operation within transaction
-	return r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
-		// Double-check book availability (within transaction for consistency)
-		var bookStatus string
-		err := tx.QueryRowContext(ctx, `
-			SELECT TinhTrang 
-			FROM QUYENSACH 
-			WHERE MaQuyenSach = ? AND MaCN = ?
-		`, borrow.MaQuyenSach, borrow.MaCN).Scan(&bookStatus)
+	// Validate borrow eligibility under the policy of the branch and the reader's category
+	policy, err := r.checkBorrow(ctx, reader, borrow.MaQuyenSach, borrow.MaCN)
+	if err != nil {
+		return fmt.Errorf("borrow validation failed: %w", err)
+	}
 
+	// A copy set aside for a hold is claimed with the loan when the hold is stored on the same
+	// database, and beforehand otherwise, so it cannot pass to the next reader meanwhile
+	hold, err := r.heldFor(ctx, borrow.MaQuyenSach, borrow.MaDG)
+	if err != nil {
+		return err
+	}
+	claimed := false
+	if hold != nil {
+		holdDB, _, err := r.GetFragmentConnection("DATCHO", hold.MaCN)
 		if err != nil {
-			return fmt.Errorf("book copy not found: %w", err)
+			return fmt.Errorf("failed to connect to site %s: %w", hold.MaCN, err)
+		}
+		if holdDB != db {
+			if err := r.claimHold(ctx, hold); err != nil {
+				return err
+			}
+			claimed = true
 		}
+	}
 
-		if bookStatus != "Có sẵn" {
-			return fmt.Errorf("book copy %s is not available (status: %s)", borrow.MaQuyenSach, bookStatus)
+	// Execute borrow operation within transaction
+	err = r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
+		if hold != nil && !claimed {
+			if err := claimHoldIn(ctx, tx, hold); err != nil {
+				return err
+			}
+		}
+		return r.lend(ctx, tx, borrow, policy, hold != nil)
+	})
+	if err != nil {
+		if claimed {
+			// Give the reader their hold back
+			if _, restoreErr := r.holds.setHoldState(ctx, hold, models.HoldFulfilled, models.HoldReady); restoreErr != nil {
+				log.Printf("Failed to restore hold %s after failed borrow: %v", hold.MaDC, restoreErr)
+			}
 		}
+		return err
+	}
 
-		// Update book status to borrowed
-		_, err = tx.ExecContext(ctx, `
-			UPDATE QUYENSACH 
-			SET TinhTrang = N'Đang được mượn'
-			WHERE MaQuyenSach = ? AND MaCN = ?
-		`, borrow.MaQuyenSach, borrow.MaCN)
+	// The loan is committed; ending the lock transaction lets the reader's next loan count it
+	if err := homeTx.Commit(); err != nil {
+		log.Printf("Failed to release lock on reader %s at site %s: %v", borrow.MaDG, reader.MaCNDangKy, err)
+	}
+	return nil
+}
 
-		if err != nil {
-			return fmt.Errorf("failed to update book status: %w", err)
-		}
+// lend records the loan of a copy of the branch of borrow in tx, due one loan period of the
+// policy from now. A copy set aside for a hold is lent only when onHold is set, once the hold
+// has been claimed for the reader.
+func (r *BorrowRepository) lend(ctx context.Context, tx *sql.Tx, borrow *models.PhieuMuon, policy *models.ChinhSach, onHold bool) error {
+	// Double-check book availability (within transaction for consistency); the update
+	// lock keeps a concurrent loan of the same copy waiting until this one commits
+	var bookStatus models.CopyStatus
+	err := tx.QueryRowContext(ctx, `
+		SELECT TinhTrang 
+		FROM QUYENSACH WITH (UPDLOCK)
+		WHERE MaQuyenSach = ? AND MaCN = ?
+	`, borrow.MaQuyenSach, borrow.MaCN).Scan(&bookStatus)
 
-		// Create borrow record
-		query := `
-			INSERT INTO PHIEUMUON (MaDG, MaQuyenSach, MaCN, NgayMuon)
-			VALUES (?, ?, ?, ?)
-		`
+	if err != nil {
+		return fmt.Errorf("book copy not found: %w", err)
+	}
 
-		result, err := tx.ExecContext(ctx, query,
-			borrow.MaDG, borrow.MaQuyenSach, borrow.MaCN, time.Now())
-		if err != nil {
-			return fmt.Errorf("failed to create borrow record: %w", err)
-		}
+	if bookStatus != models.CopyAvailable && !(onHold && bookStatus == models.CopyOnHold) {
+		return fmt.Errorf("book copy %s is not available (status: %s)", borrow.MaQuyenSach, bookStatus)
+	}
 
-		// Get the generated borrow ID
-		borrowID, err := result.LastInsertId()
-		if err != nil {
-			return fmt.Errorf("failed to get borrow ID: %w", err)
-		}
+	// Update book status to borrowed
+	_, err = tx.ExecContext(ctx, `
+		UPDATE QUYENSACH 
+		SET TinhTrang = ?
+		WHERE MaQuyenSach = ? AND MaCN = ?
+	`, models.CopyOnLoan, borrow.MaQuyenSach, borrow.MaCN)
 
-		log.Printf("Borrow record %d created for reader %s, book %s in site %s",
-			borrowID, borrow.MaDG, borrow.MaQuyenSach, borrow.MaCN)
-		return nil
-	})
+	if err != nil {
+		return fmt.Errorf("failed to update book status: %w", err)
+	}
+
+	// Create borrow record, due one loan period of the policy from now
+	borrow.NgayMuon = time.Now()
+	borrow.HanTra = borrow.NgayMuon.Add(loanPeriod(policy))
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO PHIEUMUON (MaDG, MaQuyenSach, MaCN, NgayMuon, HanTra)
+		OUTPUT INSERTED.MaPM
+		VALUES (?, ?, ?, ?, ?)
+	`, borrow.MaDG, borrow.MaQuyenSach, borrow.MaCN, borrow.NgayMuon, borrow.HanTra).Scan(&borrow.MaPM)
+	if err != nil {
+		return fmt.Errorf("failed to create borrow record: %w", err)
+	}
+
+	log.Printf("Borrow record %d created for reader %s, book %s in site %s",
+		borrow.MaPM, borrow.MaDG, borrow.MaQuyenSach, borrow.MaCN)
+	return nil
+}
+
+// claimHold marks a ready hold as picked up by its reader, on its own ahead of the loan
+func (r *BorrowRepository) claimHold(ctx context.Context, hold *models.DatCho) error {
+	claimed, err := r.holds.setHoldState(ctx, hold, models.HoldReady, models.HoldFulfilled)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return fmt.Errorf("hold %s on book copy %s is no longer ready", hold.MaDC, hold.MaQuyenSach)
+	}
+	return nil
+}
+
+// claimHoldIn marks a ready hold stored with the loan as picked up, in the loan's transaction
+func claimHoldIn(ctx context.Context, tx *sql.Tx, hold *models.DatCho) error {
+	result, err := tx.ExecContext(ctx, "UPDATE DATCHO SET TrangThai = ? WHERE MaDC = ? AND TrangThai = ?",
+		models.HoldFulfilled, hold.MaDC, models.HoldReady)
+	if err != nil {
+		return fmt.Errorf("failed to update hold %s: %w", hold.MaDC, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("hold %s on book copy %s is no longer ready", hold.MaDC, hold.MaQuyenSach)
+	}
+	return nil
+}
+
+// heldFor returns the ready hold a copy is set aside for, which must be the reader's, or nil
+// when the copy is not on hold
+func (r *BorrowRepository) heldFor(ctx context.Context, maQuyenSach, maDG string) (*models.DatCho, error) {
+	bookCopy, err := r.GetActiveBookCopy(ctx, maQuyenSach)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate book copy: %w", err)
+	}
+	if bookCopy.TinhTrang != models.CopyOnHold {
+		return nil, nil
+	}
+
+	hold, err := r.holds.readyHold(ctx, maQuyenSach)
+	if err != nil {
+		return nil, err
+	}
+	if hold.MaDG != maDG {
+		return nil, fmt.Errorf("book copy %s is on hold for another reader", maQuyenSach)
+	}
+	return hold, nil
 }
 
 // ReturnBook processes book return with validation (FR3)
-func (r *BorrowRepository) ReturnBook(ctx context.Context, maQuyenSach string, userSite string) error {
+// A late return is fined under the policy of the lending branch and the reader's category, and
+// the freed copy is set aside for the oldest waiting hold on its title. A copy of another branch
+// is taken back all the same and shipped home (see returnElsewhere). The fine, the hold and the
+// transfer home, if any, are returned.
+func (r *BorrowRepository) ReturnBook(ctx context.Context, maQuyenSach string, userSite string) (*models.ReturnBookResponse, error) {
 	// Find which site the book copy belongs to
 	bookCopy, err := r.GetActiveBookCopy(ctx, maQuyenSach)
 	if err != nil {
-		return fmt.Errorf("failed to locate book copy: %w", err)
+		return nil, fmt.Errorf("failed to locate book copy: %w", err)
 	}
 
-	// Authorization: only allow return in user's site
+	// A reader may return a copy at any branch; one of another branch is routed home
 	if bookCopy.MaCN != userSite {
-		return fmt.Errorf("access denied: cannot return book in site %s from site %s", bookCopy.MaCN, userSite)
+		return r.returnElsewhere(ctx, bookCopy, userSite)
 	}
 
-	db, err := r.GetConnection(bookCopy.MaCN)
+	db, _, err := r.GetFragmentConnection("PHIEUMUON", bookCopy.MaCN)
 	if err != nil {
-		return fmt.Errorf("failed to connect to site %s: %w", bookCopy.MaCN, err)
+		return nil, fmt.Errorf("failed to connect to site %s: %w", bookCopy.MaCN, err)
 	}
-
 	// Execute return operation within transaction
-	return r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
-		// Find active borrow record
-		var borrowID int
-		var borrowedDate time.Time
-		err := tx.QueryRowContext(ctx, `
-			SELECT MaPM, NgayMuon
-			FROM PHIEUMUON 
-			WHERE MaQuyenSach = ? AND MaCN = ? AND NgayTra IS NULL
-		`, maQuyenSach, bookCopy.MaCN).Scan(&borrowID, &borrowedDate)
+	var fine *models.Phat
+	later := r.newLaterTxs()
+	defer later.rollback()
+	err = r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
+		_, lateFine, err := r.shelve(ctx, tx, later.join(ctx, db, tx), bookCopy)
+		fine = lateFine
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	fine = r.commitFine(later, maQuyenSach, fine)
 
-		if err != nil {
-			if err == sql.ErrNoRows {
-				return fmt.Errorf("no active borrow record found for book copy %s", maQuyenSach)
+	// The return stands even if the queue cannot be served now; the copy then stays available
+	bookCopy.TinhTrang = models.CopyAvailable
+	hold, err := r.holds.AssignCopy(ctx, bookCopy)
+	if err != nil {
+		log.Printf("Failed to serve holds on %s with returned copy %s: %v", bookCopy.ISBN, maQuyenSach, err)
+		return &models.ReturnBookResponse{Fine: fine}, nil
+	}
+	return &models.ReturnBookResponse{Hold: hold, Fine: fine}, nil
+}
+
+// shelve closes the loan of a copy returned at its home branch and puts it back on the shelf, in
+// tx; the fine, if any, is recorded in the transaction join returns
+func (r *BorrowRepository) shelve(ctx context.Context, tx *sql.Tx, join joinTx, bookCopy *models.QuyenSach) (*models.PhieuMuon, *models.Phat, error) {
+	loan, fine, err := r.closeLoan(ctx, tx, join, bookCopy.MaQuyenSach, bookCopy.MaCN)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Update book status to available
+	_, err = tx.ExecContext(ctx, `
+		UPDATE QUYENSACH 
+		SET TinhTrang = ?
+		WHERE MaQuyenSach = ? AND MaCN = ?
+	`, models.CopyAvailable, bookCopy.MaQuyenSach, bookCopy.MaCN)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update book status: %w", err)
+	}
+
+	log.Printf("Book %s returned successfully, borrow record %d updated in site %s",
+		bookCopy.MaQuyenSach, loan.MaPM, bookCopy.MaCN)
+	return loan, fine, nil
+}
+
+// returnElsewhere takes back at userSite a copy lent by its home branch. The loan is closed in
+// the home branch's fragment, and fined, as a return there would be; the copy is marked in
+// transit and a transfer is recorded with it until its home branch checks it in. When the
+// CHUYENTRA fragment is on another database, the transfer is committed first: a return failing
+// after it leaves the transfer listed, and returning the copy again finishes it.
+func (r *BorrowRepository) returnElsewhere(ctx context.Context, bookCopy *models.QuyenSach, userSite string) (*models.ReturnBookResponse, error) {
+	// The loan of a copy is stored at the branch that lent it, which is not the owner for an
+	// inter-library loan
+	loan, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "PHIEUMUON",
+		Select:   "SELECT MaPM, MaDG, MaQuyenSach, MaCN, NgayMuon, NgayTra, HanTra, SoLanGiaHan FROM PHIEUMUON",
+		Filters: []query.Predicate{
+			query.Eq("MaQuyenSach", bookCopy.MaQuyenSach),
+			query.Where("NgayTra IS NULL"),
+		},
+	}, r.scanPhieuMuon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find borrow record: %w", err)
+	}
+	if !found {
+		return nil, fmt.Errorf("no active borrow record found for book copy %s", bookCopy.MaQuyenSach)
+	}
+	if loan.MaCN != bookCopy.MaCN {
+		return nil, fmt.Errorf("book copy %s of site %s was lent by site %s through an inter-library loan; return it through the request",
+			bookCopy.MaQuyenSach, bookCopy.MaCN, loan.MaCN)
+	}
+
+	db, _, err := r.GetFragmentConnection("PHIEUMUON", bookCopy.MaCN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to site %s: %w", bookCopy.MaCN, err)
+	}
+	transferDB, _, err := r.GetFragmentConnection("CHUYENTRA", bookCopy.MaCN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to site %s: %w", bookCopy.MaCN, err)
+	}
+
+	transfer := &models.ChuyenTra{
+		MaCT:        newRowID(bookCopy.MaCN),
+		MaQuyenSach: bookCopy.MaQuyenSach,
+		ISBN:        bookCopy.ISBN,
+		MaCN:        bookCopy.MaCN,
+		MaCNNhanTra: userSite,
+		TrangThai:   models.TransferInTransit,
+		NgayNhanTra: time.Now(),
+	}
+
+	var fine *models.Phat
+	later := r.newLaterTxs()
+	defer later.rollback()
+	shipCopy := func(tx *sql.Tx) error {
+		lateFine, err := r.ship(ctx, tx, later.join(ctx, db, tx), bookCopy)
+		fine = lateFine
+		return err
+	}
+
+	// Fragments stored on the same database share one transaction
+	if transferDB == db {
+		err = r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
+			if err := r.recordTransfer(ctx, tx, transfer); err != nil {
+				return err
 			}
-			return fmt.Errorf("failed to find borrow record: %w", err)
+			return shipCopy(tx)
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		err = r.ExecuteWithTransaction(ctx, transferDB, func(tx *sql.Tx) error {
+			return r.recordTransfer(ctx, tx, transfer)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := r.ExecuteWithTransaction(ctx, db, shipCopy); err != nil {
+			r.withdrawTransfer(ctx, transferDB, transfer)
+			return nil, err
 		}
+	}
+	fine = r.commitFine(later, bookCopy.MaQuyenSach, fine)
 
-		// Update borrow record with return date
-		_, err = tx.ExecContext(ctx, `
-			UPDATE PHIEUMUON 
-			SET NgayTra = ?
-			WHERE MaPM = ?
-		`, time.Now(), borrowID)
+	log.Printf("Book %s of site %s returned at site %s (borrow record %d), shipped home as transfer %s",
+		bookCopy.MaQuyenSach, bookCopy.MaCN, userSite, loan.MaPM, transfer.MaCT)
+	return &models.ReturnBookResponse{Fine: fine, Transfer: transfer}, nil
+}
+
+// ship closes the loan of a copy returned away from its home branch and marks it in transit, in
+// tx; the fine, if any, is recorded in the transaction join returns
+func (r *BorrowRepository) ship(ctx context.Context, tx *sql.Tx, join joinTx, bookCopy *models.QuyenSach) (*models.Phat, error) {
+	_, fine, err := r.closeLoan(ctx, tx, join, bookCopy.MaQuyenSach, bookCopy.MaCN)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE QUYENSACH
+		SET TinhTrang = ?
+		WHERE MaQuyenSach = ? AND MaCN = ? AND TinhTrang = ?
+	`, models.CopyInTransit, bookCopy.MaQuyenSach, bookCopy.MaCN, models.CopyOnLoan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update book status: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return nil, fmt.Errorf("book copy %s is not on loan", bookCopy.MaQuyenSach)
+	}
+	return fine, nil
+}
+
+// recordTransfer records in tx the transfer home of a copy returned away from its home branch.
+// A transfer left by an earlier return of the copy that failed after recording it is taken
+// over instead, so the copy is listed once.
+func (r *BorrowRepository) recordTransfer(ctx context.Context, tx *sql.Tx, transfer *models.ChuyenTra) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+transferColumns+`
+		FROM CHUYENTRA WITH (UPDLOCK)
+		WHERE MaQuyenSach = ? AND MaCN = ? AND MaCN_NhanSach IS NULL AND TrangThai = ?
+	`, transfer.MaQuyenSach, transfer.MaCN, models.TransferInTransit)
+	if err != nil {
+		return fmt.Errorf("failed to find transfer of book copy %s: %w", transfer.MaQuyenSach, err)
+	}
+	var pending *models.ChuyenTra
+	if rows.Next() {
+		pending, err = scanChuyenTra(rows)
+	} else {
+		err = rows.Err()
+	}
+	rows.Close()
+	if err != nil {
+		return fmt.Errorf("failed to find transfer of book copy %s: %w", transfer.MaQuyenSach, err)
+	}
 
+	if pending != nil {
+		_, err = tx.ExecContext(ctx, "UPDATE CHUYENTRA SET MaCN_NhanTra = ?, NgayNhanTra = ? WHERE MaCT = ?",
+			transfer.MaCNNhanTra, transfer.NgayNhanTra, pending.MaCT)
 		if err != nil {
-			return fmt.Errorf("failed to update borrow record: %w", err)
+			return fmt.Errorf("failed to update transfer %s: %w", pending.MaCT, err)
 		}
+		transfer.MaCT = pending.MaCT
+		return nil
+	}
 
-		// Update book status to available
-		_, err = tx.ExecContext(ctx, `
-			UPDATE QUYENSACH 
-			SET TinhTrang = N'Có sẵn'
-			WHERE MaQuyenSach = ? AND MaCN = ?
-		`, maQuyenSach, bookCopy.MaCN)
+	return insertTransfer(ctx, tx, transfer)
+}
 
-		if err != nil {
-			return fmt.Errorf("failed to update book status: %w", err)
+// withdrawTransfer removes a transfer recorded for a return that then failed. A transfer that
+// cannot be removed stays listed until the copy is returned again.
+func (r *BorrowRepository) withdrawTransfer(ctx context.Context, db *sql.DB, transfer *models.ChuyenTra) {
+	_, err := db.ExecContext(ctx, "DELETE FROM CHUYENTRA WHERE MaCT = ? AND TrangThai = ?",
+		transfer.MaCT, models.TransferInTransit)
+	if err != nil {
+		log.Printf("Failed to withdraw transfer %s of book copy %s after a failed return; return the copy again to finish it: %v",
+			transfer.MaCT, transfer.MaQuyenSach, err)
+	}
+}
+
+// closeLoan records the return of the active loan of a copy at branch maCN in tx. A late return
+// is fined under the policy of the branch and the reader's category, in the transaction join
+// returns for the PHAT fragment.
+func (r *BorrowRepository) closeLoan(ctx context.Context, tx *sql.Tx, join joinTx, maQuyenSach, maCN string) (*models.PhieuMuon, *models.Phat, error) {
+	// Find active borrow record, and how late it is by the same clock as the return date
+	returned := time.Now()
+	loan := models.PhieuMuon{MaQuyenSach: maQuyenSach, MaCN: maCN, NgayTra: &returned}
+	var daysOverdue int
+	err := tx.QueryRowContext(ctx, `
+		SELECT MaPM, MaDG, NgayMuon, HanTra, DATEDIFF(day, HanTra, ?)
+		FROM PHIEUMUON 
+		WHERE MaQuyenSach = ? AND MaCN = ? AND NgayTra IS NULL
+	`, returned, maQuyenSach, maCN).Scan(&loan.MaPM, &loan.MaDG, &loan.NgayMuon, &loan.HanTra, &daysOverdue)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, fmt.Errorf("no active borrow record found for book copy %s", maQuyenSach)
 		}
+		return nil, nil, fmt.Errorf("failed to find borrow record: %w", err)
+	}
+
+	// Update borrow record with return date
+	_, err = tx.ExecContext(ctx, `
+		UPDATE PHIEUMUON 
+		SET NgayTra = ?
+		WHERE MaPM = ?
+	`, returned, loan.MaPM)
 
-		log.Printf("Book %s returned successfully, borrow record %d updated in site %s",
-			maQuyenSach, borrowID, bookCopy.MaCN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update borrow record: %w", err)
+	}
+
+	if daysOverdue <= 0 {
+		return &loan, nil, nil
+	}
+
+	policy, err := r.loanPolicy(ctx, &loan)
+	if err != nil {
+		return nil, nil, err
+	}
+	// A fine on another database is committed by the caller after the loan, so a return that
+	// fails leaves no fine behind
+	fineTx, err := join("PHAT", maCN)
+	if err != nil {
+		return nil, nil, err
+	}
+	fine, err := r.assessFine(ctx, fineTx, &loan, daysOverdue, policy)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &loan, fine, nil
+}
+
+// commitFine commits the fine of a closed loan held in later, once the loan has committed. The
+// return stands if it fails; the fine is then reported as not recorded and nil is returned.
+func (r *BorrowRepository) commitFine(later *laterTxs, maQuyenSach string, fine *models.Phat) *models.Phat {
+	if err := later.commit(); err != nil {
+		if fine != nil {
+			log.Printf("Loan of book copy %s closed but fine %s of %d was not recorded; record it by hand: %v",
+				maQuyenSach, fine.MaPhat, fine.SoTien, err)
+		}
 		return nil
-	})
+	}
+	return fine
 }
 
-// GetBorrowByID retrieves a borrow record by ID
-func (r *BorrowRepository) GetBorrowByID(ctx context.Context, maPM int) (*models.PhieuMuon, error) {
-	// Try to find borrow record in all sites
-	connections, err := r.GetAllSiteConnections()
+// RenewBorrow pushes the due date of a loan made at the user's site back by one loan period,
+// as often as the policy of the branch and the reader's category allows. A title other readers
+// are waiting for is not renewed, so the copy returns to the queue.
+func (r *BorrowRepository) RenewBorrow(ctx context.Context, maPM int, userSite string) (*models.PhieuMuon, error) {
+	db, _, err := r.GetFragmentConnection("PHIEUMUON", userSite)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get site connections: %w", err)
+		return nil, fmt.Errorf("failed to connect to site %s: %w", userSite, err)
 	}
 
-	query := `
-		SELECT MaPM, MaDG, MaQuyenSach, MaCN, NgayMuon, NgayTra
-		FROM PHIEUMUON
-		WHERE MaPM = ?
-	`
+	var borrow *models.PhieuMuon
+	err = r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
+		// The update lock keeps a concurrent renewal or return waiting until this one commits
+		rows, err := tx.QueryContext(ctx, `
+			SELECT MaPM, MaDG, MaQuyenSach, MaCN, NgayMuon, NgayTra, HanTra, SoLanGiaHan
+			FROM PHIEUMUON WITH (UPDLOCK)
+			WHERE MaPM = ? AND MaCN = ?
+		`, maPM, userSite)
+		if err != nil {
+			return fmt.Errorf("failed to find borrow record: %w", err)
+		}
+		if rows.Next() {
+			borrow, err = r.scanPhieuMuon(rows)
+		} else if err = rows.Err(); err == nil {
+			err = fmt.Errorf("borrow record %d not found at site %s", maPM, userSite)
+		}
+		rows.Close()
+		if err != nil {
+			return err
+		}
 
-	for siteID, db := range connections {
-		var borrow models.PhieuMuon
-		var ngayTra sql.NullTime
+		if borrow.NgayTra != nil {
+			return fmt.Errorf("borrow record %d is already returned", maPM)
+		}
+		policy, err := r.loanPolicy(ctx, borrow)
+		if err != nil {
+			return err
+		}
+		if borrow.SoLanGiaHan >= policy.SoLanGiaHan {
+			return violation(policy.MaCS, models.RuleMaxRenewals, policy.SoLanGiaHan, borrow.SoLanGiaHan,
+				"borrow record %d has reached the limit of %d renewals", maPM, policy.SoLanGiaHan)
+		}
 
-		err := db.QueryRowContext(ctx, query, maPM).Scan(
-			&borrow.MaPM, &borrow.MaDG, &borrow.MaQuyenSach,
-			&borrow.MaCN, &borrow.NgayMuon, &ngayTra)
+		// The copy of an inter-library loan stays in its owner's fragment
+		bookCopy, err := r.GetActiveBookCopy(ctx, borrow.MaQuyenSach)
+		if err != nil {
+			return fmt.Errorf("failed to find book copy %s: %w", borrow.MaQuyenSach, err)
+		}
+		isbn := bookCopy.ISBN
+		waiting, err := r.holds.hasWaitingHolds(ctx, isbn)
+		if err != nil {
+			return err
+		}
+		if waiting {
+			return fmt.Errorf("cannot renew borrow record %d: other readers are waiting for %s", maPM, isbn)
+		}
 
-		if err == nil {
-			if ngayTra.Valid {
-				borrow.NgayTra = &ngayTra.Time
-			}
-			log.Printf("Borrow record %d found in site %s", maPM, siteID)
-			return &borrow, nil
+		// An overdue loan is renewed from today, so the renewal does not leave it overdue
+		from := borrow.HanTra
+		if now := time.Now(); now.After(from) {
+			from = now
 		}
+		borrow.HanTra = from.Add(loanPeriod(policy))
+		borrow.SoLanGiaHan++
 
-		if err != sql.ErrNoRows {
-			log.Printf("Error querying borrow record in site %s: %v", siteID, err)
+		_, err = tx.ExecContext(ctx, `
+			UPDATE PHIEUMUON
+			SET HanTra = ?, SoLanGiaHan = ?
+			WHERE MaPM = ?
+		`, borrow.HanTra, borrow.SoLanGiaHan, maPM)
+		if err != nil {
+			return fmt.Errorf("failed to update borrow record: %w", err)
 		}
+
+		log.Printf("Borrow record %d renewed until %s in site %s (renewal %d)",
+			maPM, borrow.HanTra.Format("2006-01-02"), userSite, borrow.SoLanGiaHan)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return borrow, nil
+}
 
-	return nil, fmt.Errorf("borrow record not found: %d", maPM)
+// GetBorrowByID retrieves a borrow record by ID
+func (r *BorrowRepository) GetBorrowByID(ctx context.Context, maPM int) (*models.PhieuMuon, error) {
+	// MaPM does not determine the fragment, so every fragment of PHIEUMUON is searched
+	borrow, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "PHIEUMUON",
+		Select:   "SELECT MaPM, MaDG, MaQuyenSach, MaCN, NgayMuon, NgayTra, HanTra, SoLanGiaHan FROM PHIEUMUON",
+		Filters:  []query.Predicate{query.Eq("MaPM", maPM)},
+	}, r.scanPhieuMuon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up borrow record %d: %w", maPM, err)
+	}
+	if !found {
+		return nil, fmt.Errorf("borrow record not found: %d", maPM)
+	}
+
+	log.Printf("Borrow record %d found in site %s", maPM, borrow.MaCN)
+	return borrow, nil
 }
 
 // GetBorrowsBySite retrieves all borrow records for a specific site
 func (r *BorrowRepository) GetBorrowsBySite(ctx context.Context, siteID string, pagination *utils.PaginationParams) ([]*models.PhieuMuon, int, error) {
-	db, err := r.GetConnection(siteID)
+	db, _, err := r.GetFragmentConnection("PHIEUMUON", siteID)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
@@ -248,7 +657,7 @@ func (r *BorrowRepository) GetBorrowsBySite(ctx context.Context, siteID string,
 
 	// Get paginated data
 	baseQuery := `
-		SELECT MaPM, MaDG, MaQuyenSach, MaCN, NgayMuon, NgayTra
+		SELECT MaPM, MaDG, MaQuyenSach, MaCN, NgayMuon, NgayTra, HanTra, SoLanGiaHan
 		FROM PHIEUMUON
 		WHERE MaCN = ?
 	`
@@ -271,111 +680,51 @@ func (r *BorrowRepository) GetBorrowsBySite(ctx context.Context, siteID string,
 	return borrows, totalCount, nil
 }
 
-// GetActiveBorrowsByReader retrieves active borrows for a specific reader
+// GetActiveBorrowsByReader retrieves active borrows for a specific reader, most recent first
 func (r *BorrowRepository) GetActiveBorrowsByReader(ctx context.Context, maDG string) ([]*models.PhieuMuon, error) {
-	// Try to find reader's borrows in all sites
-	connections, err := r.GetAllSiteConnections()
+	// A reader may borrow at any branch, so every fragment of PHIEUMUON is searched
+	result, err := query.Merge(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "PHIEUMUON",
+		Select:   "SELECT MaPM, MaDG, MaQuyenSach, MaCN, NgayMuon, NgayTra, HanTra, SoLanGiaHan FROM PHIEUMUON",
+		Filters: []query.Predicate{
+			query.Eq("MaDG", maDG),
+			query.Where("NgayTra IS NULL"),
+		},
+		OrderBy: "NgayMuon DESC",
+	}, r.scanPhieuMuon, func(a, b *models.PhieuMuon) bool {
+		return a.NgayMuon.After(b.NgayMuon)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get site connections: %w", err)
+		return nil, fmt.Errorf("failed to query active borrows: %w", err)
 	}
 
-	var allBorrows []*models.PhieuMuon
-
-	query := `
-		SELECT MaPM, MaDG, MaQuyenSach, MaCN, NgayMuon, NgayTra
-		FROM PHIEUMUON
-		WHERE MaDG = ? AND NgayTra IS NULL
-		ORDER BY NgayMuon DESC
-	`
-
-	for siteID, db := range connections {
-		rows, err := db.QueryContext(ctx, query, maDG)
-		if err != nil {
-			log.Printf("Error querying active borrows from site %s: %v", siteID, err)
-			continue
-		}
-		defer rows.Close()
-
-		for rows.Next() {
-			borrow, err := r.scanPhieuMuon(rows)
-			if err != nil {
-				log.Printf("Error scanning borrow from site %s: %v", siteID, err)
-				continue
-			}
-			allBorrows = append(allBorrows, borrow)
-		}
-	}
-
-	return allBorrows, nil
+	return result.Rows, nil
 }
 
-// GetBorrowHistory retrieves borrow history for a reader with pagination
-func (r *BorrowRepository) GetBorrowHistory(ctx context.Context, maDG string, pagination *utils.PaginationParams) ([]*models.PhieuMuon, int, error) {
-	connections, err := r.GetAllSiteConnections()
+// GetBorrowHistory retrieves borrow history for a reader, most recent first, with pagination
+func (r *BorrowRepository) GetBorrowHistory(ctx context.Context, maDG string, pagination *utils.PaginationParams) (*query.Page[*models.PhieuMuon], error) {
+	// MaPM is an identity per site, so MaCN is needed to order borrows of the same instant
+	page, err := query.Paginate(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "PHIEUMUON",
+		Select:   "SELECT MaPM, MaDG, MaQuyenSach, MaCN, NgayMuon, NgayTra, HanTra, SoLanGiaHan FROM PHIEUMUON",
+		Filters:  []query.Predicate{query.Eq("MaDG", maDG)},
+	}, []query.SortKey{
+		{Column: "NgayMuon", Desc: true},
+		{Column: "MaCN"},
+		{Column: "MaPM", Desc: true},
+	}, r.scanPhieuMuon, func(borrow *models.PhieuMuon) []interface{} {
+		return []interface{}{borrow.NgayMuon, borrow.MaCN, borrow.MaPM}
+	}, pageRequest(pagination))
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get site connections: %w", err)
-	}
-
-	var allBorrows []*models.PhieuMuon
-	totalCount := 0
-
-	query := `
-		SELECT MaPM, MaDG, MaQuyenSach, MaCN, NgayMuon, NgayTra
-		FROM PHIEUMUON
-		WHERE MaDG = ?
-		ORDER BY NgayMuon DESC
-	`
-
-	for siteID, db := range connections {
-		// Get count from this site
-		countQuery := `SELECT COUNT(*) FROM PHIEUMUON WHERE MaDG = ?`
-		siteCount, err := r.GetTotalCount(ctx, db, countQuery, []interface{}{maDG})
-		if err != nil {
-			log.Printf("Error getting borrow count from site %s: %v", siteID, err)
-			continue
-		}
-		totalCount += siteCount
-
-		// Get borrow records from this site
-		rows, err := db.QueryContext(ctx, query, maDG)
-		if err != nil {
-			log.Printf("Error querying borrow history from site %s: %v", siteID, err)
-			continue
-		}
-		defer rows.Close()
-
-		for rows.Next() {
-			borrow, err := r.scanPhieuMuon(rows)
-			if err != nil {
-				log.Printf("Error scanning borrow history from site %s: %v", siteID, err)
-				continue
-			}
-			allBorrows = append(allBorrows, borrow)
-		}
+		return nil, fmt.Errorf("failed to get borrow history: %w", err)
 	}
-
-	// Apply pagination to combined results
-	if pagination != nil {
-		offset := pagination.CalculateOffset()
-		end := offset + pagination.Size
-
-		if offset >= len(allBorrows) {
-			return []*models.PhieuMuon{}, totalCount, nil
-		}
-
-		if end > len(allBorrows) {
-			end = len(allBorrows)
-		}
-
-		allBorrows = allBorrows[offset:end]
-	}
-
-	return allBorrows, totalCount, nil
+	return page, nil
 }
 
-// GetOverdueBooks retrieves overdue books for a site
+// GetOverdueBooks retrieves overdue books for a site. The copy of an inter-library loan is
+// not in the site's QUYENSACH fragment, so its title is found through the request.
 func (r *BorrowRepository) GetOverdueBooks(ctx context.Context, siteID string) ([]*models.BorrowRecordWithDetails, error) {
-	db, err := r.GetConnection(siteID)
+	db, _, err := r.GetFragmentConnection("PHIEUMUON", siteID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
@@ -384,22 +733,25 @@ func (r *BorrowRepository) GetOverdueBooks(ctx context.Context, siteID string) (
 		SELECT 
 			pm.MaPM,
 			s.ISBN, s.TenSach, s.TacGia,
-			dg.MaDG, dg.HoTen,
+			pm.MaDG, ISNULL(dg.HoTen, ''),
 			CONVERT(varchar, pm.NgayMuon, 23) as BorrowDate,
-			CONVERT(varchar, DATEADD(day, 30, pm.NgayMuon), 23) as DueDate,
+			CONVERT(varchar, pm.HanTra, 23) as DueDate,
 			'' as ReturnDate,
 			'Overdue' as Status,
-			DATEDIFF(day, DATEADD(day, 30, pm.NgayMuon), GETDATE()) as DaysOverdue,
+			DATEDIFF(day, pm.HanTra, GETDATE()) as DaysOverdue,
 			pm.MaQuyenSach,
 			pm.MaCN
 		FROM PHIEUMUON pm
-		JOIN QUYENSACH qs ON pm.MaQuyenSach = qs.MaQuyenSach
-		JOIN SACH s ON qs.ISBN = s.ISBN
-		JOIN DOCGIA dg ON pm.MaDG = dg.MaDG
+		LEFT JOIN QUYENSACH qs ON pm.MaQuyenSach = qs.MaQuyenSach
+		OUTER APPLY (
+			SELECT TOP 1 yc.ISBN FROM YEUCAUMUON yc WHERE yc.MaQuyenSach = pm.MaQuyenSach AND yc.MaCN = pm.MaCN
+		) ill
+		JOIN SACH s ON s.ISBN = ISNULL(qs.ISBN, ill.ISBN)
+		LEFT JOIN DOCGIA dg ON pm.MaDG = dg.MaDG
 		WHERE pm.MaCN = ? 
 			AND pm.NgayTra IS NULL 
-			AND DATEDIFF(day, pm.NgayMuon, GETDATE()) > 30
-		ORDER BY pm.NgayMuon
+			AND DATEDIFF(day, pm.HanTra, GETDATE()) > 0
+		ORDER BY pm.HanTra
 	`
 
 	rows, err := db.QueryContext(ctx, query, siteID)
@@ -422,12 +774,15 @@ func (r *BorrowRepository) GetOverdueBooks(ctx context.Context, siteID string) (
 		overdueBooks = append(overdueBooks, &record)
 	}
 
+	if err := r.fillReaderNames(ctx, overdueBooks); err != nil {
+		return nil, err
+	}
 	return overdueBooks, nil
 }
 
 // GetBorrowRecordsWithDetails retrieves detailed borrow records for a site
 func (r *BorrowRepository) GetBorrowRecordsWithDetails(ctx context.Context, siteID string, pagination *utils.PaginationParams) ([]*models.BorrowRecordWithDetails, int, error) {
-	db, err := r.GetConnection(siteID)
+	db, _, err := r.GetFragmentConnection("PHIEUMUON", siteID)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
@@ -436,9 +791,12 @@ func (r *BorrowRepository) GetBorrowRecordsWithDetails(ctx context.Context, site
 	countQuery := `
 		SELECT COUNT(*) 
 		FROM PHIEUMUON pm
-		JOIN QUYENSACH qs ON pm.MaQuyenSach = qs.MaQuyenSach
-		JOIN SACH s ON qs.ISBN = s.ISBN
-		JOIN DOCGIA dg ON pm.MaDG = dg.MaDG
+		LEFT JOIN QUYENSACH qs ON pm.MaQuyenSach = qs.MaQuyenSach
+		OUTER APPLY (
+			SELECT TOP 1 yc.ISBN FROM YEUCAUMUON yc WHERE yc.MaQuyenSach = pm.MaQuyenSach AND yc.MaCN = pm.MaCN
+		) ill
+		JOIN SACH s ON s.ISBN = ISNULL(qs.ISBN, ill.ISBN)
+		LEFT JOIN DOCGIA dg ON pm.MaDG = dg.MaDG
 		WHERE pm.MaCN = ?
 	`
 	totalCount, err := r.GetTotalCount(ctx, db, countQuery, []interface{}{siteID})
@@ -451,26 +809,29 @@ func (r *BorrowRepository) GetBorrowRecordsWithDetails(ctx context.Context, site
 		SELECT 
 			pm.MaPM,
 			s.ISBN, s.TenSach, s.TacGia,
-			dg.MaDG, dg.HoTen,
+			pm.MaDG, ISNULL(dg.HoTen, ''),
 			CONVERT(varchar, pm.NgayMuon, 23) as BorrowDate,
-			CONVERT(varchar, DATEADD(day, 30, pm.NgayMuon), 23) as DueDate,
+			CONVERT(varchar, pm.HanTra, 23) as DueDate,
 			ISNULL(CONVERT(varchar, pm.NgayTra, 23), '') as ReturnDate,
 			CASE 
 				WHEN pm.NgayTra IS NOT NULL THEN 'Returned'
-				WHEN DATEDIFF(day, pm.NgayMuon, GETDATE()) > 30 THEN 'Overdue'
+				WHEN DATEDIFF(day, pm.HanTra, GETDATE()) > 0 THEN 'Overdue'
 				ELSE 'Borrowed'
 			END as Status,
 			CASE 
-				WHEN pm.NgayTra IS NULL AND DATEDIFF(day, pm.NgayMuon, GETDATE()) > 30 
-				THEN DATEDIFF(day, DATEADD(day, 30, pm.NgayMuon), GETDATE())
+				WHEN pm.NgayTra IS NULL AND DATEDIFF(day, pm.HanTra, GETDATE()) > 0
+				THEN DATEDIFF(day, pm.HanTra, GETDATE())
 				ELSE 0
 			END as DaysOverdue,
 			pm.MaQuyenSach,
 			pm.MaCN
 		FROM PHIEUMUON pm
-		JOIN QUYENSACH qs ON pm.MaQuyenSach = qs.MaQuyenSach
-		JOIN SACH s ON qs.ISBN = s.ISBN
-		JOIN DOCGIA dg ON pm.MaDG = dg.MaDG
+		LEFT JOIN QUYENSACH qs ON pm.MaQuyenSach = qs.MaQuyenSach
+		OUTER APPLY (
+			SELECT TOP 1 yc.ISBN FROM YEUCAUMUON yc WHERE yc.MaQuyenSach = pm.MaQuyenSach AND yc.MaCN = pm.MaCN
+		) ill
+		JOIN SACH s ON s.ISBN = ISNULL(qs.ISBN, ill.ISBN)
+		LEFT JOIN DOCGIA dg ON pm.MaDG = dg.MaDG
 		WHERE pm.MaCN = ?
 	`
 
@@ -494,33 +855,107 @@ func (r *BorrowRepository) GetBorrowRecordsWithDetails(ctx context.Context, site
 		records = append(records, &record)
 	}
 
+	if err := r.fillReaderNames(ctx, records); err != nil {
+		return nil, 0, err
+	}
 	return records, totalCount, nil
 }
 
-// CanBorrowBook validates if a reader can borrow a specific book
+// fillReaderNames completes records of loans to readers registered at other branches,
+// whose names are not in the lending site's DOCGIA fragment
+func (r *BorrowRepository) fillReaderNames(ctx context.Context, records []*models.BorrowRecordWithDetails) error {
+	var missing []interface{}
+	seen := make(map[string]bool)
+	for _, record := range records {
+		if record.ReaderName == "" && !seen[record.ReaderID] {
+			seen[record.ReaderID] = true
+			missing = append(missing, record.ReaderID)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	result, err := query.Union(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "DOCGIA",
+		Select:   "SELECT MaDG, HoTen, MaCN_DangKy, LoaiDG FROM DOCGIA",
+		Filters:  []query.Predicate{query.In("MaDG", missing...)},
+	}, r.ScanDocGia)
+	if err != nil {
+		return fmt.Errorf("failed to look up readers of other branches: %w", err)
+	}
+
+	names := make(map[string]string, len(result.Rows))
+	for _, reader := range result.Rows {
+		names[reader.MaDG] = reader.HoTen
+	}
+	for _, record := range records {
+		if record.ReaderName == "" {
+			record.ReaderName = names[record.ReaderID]
+		}
+	}
+	return nil
+}
+
+// CanBorrowBook validates if a reader can borrow a specific book at siteID. The reader may be
+// registered at any branch; the loan limit and the overdue block count their loans at every branch.
+// A rule of the circulation policy blocking the loan is reported as a *models.PolicyViolation.
 func (r *BorrowRepository) CanBorrowBook(ctx context.Context, maDG, maQuyenSach, siteID string) error {
-	db, err := r.GetConnection(siteID)
+	// Check if reader exists at their registration branch
+	reader, err := r.homeReader(ctx, maDG)
 	if err != nil {
-		return fmt.Errorf("failed to connect to site %s: %w", siteID, err)
+		return err
+	}
+	lender, err := r.lender(ctx, maDG, maQuyenSach, siteID)
+	if err != nil {
+		return err
 	}
 
-	// Check if reader exists and belongs to this site
-	var readerCount int
-	err = db.QueryRowContext(ctx, `
-		SELECT COUNT(*) 
-		FROM DOCGIA 
-		WHERE MaDG = ? AND MaCN_DangKy = ?
-	`, maDG, siteID).Scan(&readerCount)
+	_, err = r.checkBorrow(ctx, reader, maQuyenSach, lender)
+	return err
+}
+
+// lender returns the branch whose books record a loan of a copy made at siteID: siteID itself,
+// or the copy's home branch for a copy shipped to siteID for a ready hold of the reader. Such a
+// loan is then returned like any other of the copy's branch.
+func (r *BorrowRepository) lender(ctx context.Context, maDG, maQuyenSach, siteID string) (string, error) {
+	bookCopy, err := r.GetActiveBookCopy(ctx, maQuyenSach)
+	if err != nil || bookCopy.MaCN == siteID || bookCopy.TinhTrang != models.CopyOnHold {
+		return siteID, nil // checkCopy reports a copy that cannot be lent at siteID
+	}
 
+	hold, err := r.holds.readyHold(ctx, maQuyenSach)
 	if err != nil {
-		return fmt.Errorf("failed to validate reader: %w", err)
+		return "", err
+	}
+	if hold.MaCNNhanSach != siteID {
+		return siteID, nil
 	}
-	if readerCount == 0 {
-		return fmt.Errorf("reader %s not found in site %s", maDG, siteID)
+	if hold.MaDG != maDG {
+		return "", fmt.Errorf("book copy %s is on hold for another reader", maQuyenSach)
+	}
+	return bookCopy.MaCN, nil
+}
+
+// checkBorrow validates a loan of a book copy at siteID to a reader, and returns the policy
+// of the branch and the reader's category that the loan is made under
+func (r *BorrowRepository) checkBorrow(ctx context.Context, reader *models.DocGia, maQuyenSach, siteID string) (*models.ChinhSach, error) {
+	if err := r.checkCopy(ctx, reader.MaDG, maQuyenSach, siteID); err != nil {
+		return nil, err
+	}
+	return r.checkReader(ctx, reader, siteID, 1)
+}
+
+// checkCopy validates that a book copy of siteID may be lent to a reader: it is on the shelf,
+// or set aside for a hold of theirs
+func (r *BorrowRepository) checkCopy(ctx context.Context, maDG, maQuyenSach, siteID string) error {
+	db, _, err := r.GetFragmentConnection("QUYENSACH", siteID)
+	if err != nil {
+		return fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
 
 	// Check if book copy exists and is available
-	var bookStatus string
+	var bookStatus models.CopyStatus
 	err = db.QueryRowContext(ctx, `
 		SELECT TinhTrang 
 		FROM QUYENSACH 
@@ -534,78 +969,85 @@ func (r *BorrowRepository) CanBorrowBook(ctx context.Context, maDG, maQuyenSach,
 		return fmt.Errorf("failed to check book availability: %w", err)
 	}
 
-	if bookStatus != "Có sẵn" {
+	switch bookStatus {
+	case models.CopyAvailable:
+	case models.CopyOnHold:
+		// A copy set aside for a hold may only be lent to the reader who placed it
+		hold, err := r.holds.readyHold(ctx, maQuyenSach)
+		if err != nil {
+			return err
+		}
+		if hold.MaDG != maDG {
+			return fmt.Errorf("book copy %s is on hold for another reader", maQuyenSach)
+		}
+	default:
 		return fmt.Errorf("book copy %s is not available (status: %s)", maQuyenSach, bookStatus)
 	}
+	return nil
+}
 
-	// Check if reader has any overdue books
-	var overdueCount int
-	err = db.QueryRowContext(ctx, `
-		SELECT COUNT(*) 
-		FROM PHIEUMUON 
-		WHERE MaDG = ? AND NgayTra IS NULL 
-			AND DATEDIFF(day, NgayMuon, GETDATE()) > 30
-	`, maDG).Scan(&overdueCount)
+// checkReader validates that a reader may take items more loans at siteID, whatever the copies,
+// and returns the policy of the branch and the reader's category that the loans are made under
+func (r *BorrowRepository) checkReader(ctx context.Context, reader *models.DocGia, siteID string, items int) (*models.ChinhSach, error) {
+	maDG := reader.MaDG
+	policy, err := r.policyFor(ctx, siteID, reader.LoaiDG)
+	if err != nil {
+		return nil, err
+	}
 
+	// Check overdue books and the borrow limit over the loans at every branch
+	loans, err := r.ReaderLoans(ctx, maDG)
 	if err != nil {
-		return fmt.Errorf("failed to check overdue books: %w", err)
+		return nil, err
 	}
-	if overdueCount > 0 {
-		return fmt.Errorf("reader %s has %d overdue books", maDG, overdueCount)
+	if loans.Overdue > 0 {
+		return nil, violation(policy.MaCS, models.RuleOverdueLoans, 0, loans.Overdue,
+			"reader %s has %d overdue books", maDG, loans.Overdue)
+	}
+	if loans.Active >= policy.SoSachToiDa {
+		return nil, violation(policy.MaCS, models.RuleMaxItems, policy.SoSachToiDa, loans.Active,
+			"reader %s has reached maximum borrow limit (%d books)", maDG, policy.SoSachToiDa)
+	}
+	if loans.Active+items > policy.SoSachToiDa {
+		return nil, violation(policy.MaCS, models.RuleMaxItems, policy.SoSachToiDa, loans.Active+items,
+			"reader %s has %d books on loan and may borrow %d more, not %d (limit %d books)",
+			maDG, loans.Active, policy.SoSachToiDa-loans.Active, items, policy.SoSachToiDa)
 	}
 
-	// Check borrow limit (e.g., max 3 books)
-	var activeBorrows int
-	err = db.QueryRowContext(ctx, `
-		SELECT COUNT(*) 
-		FROM PHIEUMUON 
-		WHERE MaDG = ? AND NgayTra IS NULL
-	`, maDG).Scan(&activeBorrows)
-
+	// Check unpaid fines at every branch against the configured threshold
+	balance, err := r.ReaderFineBalance(ctx, maDG)
 	if err != nil {
-		return fmt.Errorf("failed to check active borrows: %w", err)
+		return nil, err
 	}
-	if activeBorrows >= 3 {
-		return fmt.Errorf("reader %s has reached maximum borrow limit (%d books)", maDG, activeBorrows)
+	if threshold := r.config().Fines.BlockThreshold; balance > threshold {
+		return nil, violation(models.DefaultPolicyID, models.RuleFineThreshold, threshold, balance,
+			"reader %s owes %d VND in unpaid fines (limit %d VND)", maDG, balance, threshold)
 	}
 
-	return nil
+	return policy, nil
 }
 
 // GetActiveBookCopy retrieves book copy information for active borrow operations
 func (r *BorrowRepository) GetActiveBookCopy(ctx context.Context, maQuyenSach string) (*models.QuyenSach, error) {
-	// Try to find book copy in all sites
-	connections, err := r.GetAllSiteConnections()
+	// MaQuyenSach does not determine the fragment, so every fragment of QUYENSACH is searched
+	bookCopy, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "QUYENSACH",
+		Select:   "SELECT MaQuyenSach, ISBN, MaCN, TinhTrang FROM QUYENSACH",
+		Filters:  []query.Predicate{query.Eq("MaQuyenSach", maQuyenSach)},
+	}, r.ScanQuyenSach)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get site connections: %w", err)
+		return nil, fmt.Errorf("failed to look up book copy %s: %w", maQuyenSach, err)
 	}
-
-	query := `
-		SELECT MaQuyenSach, ISBN, MaCN, TinhTrang
-		FROM QUYENSACH
-		WHERE MaQuyenSach = ?
-	`
-
-	for siteID, db := range connections {
-		var bookCopy models.QuyenSach
-		err := db.QueryRowContext(ctx, query, maQuyenSach).Scan(
-			&bookCopy.MaQuyenSach, &bookCopy.ISBN, &bookCopy.MaCN, &bookCopy.TinhTrang)
-
-		if err == nil {
-			return &bookCopy, nil
-		}
-
-		if err != sql.ErrNoRows {
-			log.Printf("Error querying book copy in site %s: %v", siteID, err)
-		}
+	if !found {
+		return nil, fmt.Errorf("book copy not found: %s", maQuyenSach)
 	}
 
-	return nil, fmt.Errorf("book copy not found: %s", maQuyenSach)
+	return bookCopy, nil
 }
 
 // GetBorrowStatistics retrieves borrowing statistics for a site
 func (r *BorrowRepository) GetBorrowStatistics(ctx context.Context, siteID string) (map[string]interface{}, error) {
-	db, err := r.GetConnection(siteID)
+	db, _, err := r.GetFragmentConnection("PHIEUMUON", siteID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
@@ -634,7 +1076,7 @@ func (r *BorrowRepository) GetBorrowStatistics(ctx context.Context, siteID strin
 		SELECT COUNT(*) 
 		FROM PHIEUMUON 
 		WHERE MaCN = ? AND NgayTra IS NULL 
-			AND DATEDIFF(day, NgayMuon, GETDATE()) > 30
+			AND DATEDIFF(day, HanTra, GETDATE()) > 0
 	`, siteID).Scan(&overdueBooks)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get overdue books: %w", err)
@@ -662,7 +1104,7 @@ func (r *BorrowRepository) GetBorrowStatistics(ctx context.Context, siteID strin
 
 // GetPopularBooks retrieves most frequently borrowed books for a site
 func (r *BorrowRepository) GetPopularBooks(ctx context.Context, siteID string, limit int) ([]*models.BookWithAvailability, error) {
-	db, err := r.GetConnection(siteID)
+	db, _, err := r.GetFragmentConnection("PHIEUMUON", siteID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
@@ -671,8 +1113,8 @@ func (r *BorrowRepository) GetPopularBooks(ctx context.Context, siteID string, l
 		SELECT TOP (?) 
 			s.ISBN, s.TenSach, s.TacGia,
 			COUNT(qs.MaQuyenSach) as TotalCount,
-			SUM(CASE WHEN qs.TinhTrang = N'Có sẵn' THEN 1 ELSE 0 END) as AvailableCount,
-			SUM(CASE WHEN qs.TinhTrang = N'Đang được mượn' THEN 1 ELSE 0 END) as BorrowedCount
+			SUM(CASE WHEN qs.TinhTrang = ? THEN 1 ELSE 0 END) as AvailableCount,
+			SUM(CASE WHEN qs.TinhTrang = ? THEN 1 ELSE 0 END) as BorrowedCount
 		FROM SACH s
 		JOIN QUYENSACH qs ON s.ISBN = qs.ISBN AND qs.MaCN = ?
 		JOIN PHIEUMUON pm ON qs.MaQuyenSach = pm.MaQuyenSach
@@ -680,7 +1122,7 @@ func (r *BorrowRepository) GetPopularBooks(ctx context.Context, siteID string, l
 		ORDER BY COUNT(pm.MaPM) DESC
 	`
 
-	rows, err := db.QueryContext(ctx, query, limit, siteID)
+	rows, err := db.QueryContext(ctx, query, limit, models.CopyAvailable, models.CopyOnLoan, siteID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query popular books: %w", err)
 	}
@@ -707,7 +1149,7 @@ func (r *BorrowRepository) scanPhieuMuon(rows *sql.Rows) (*models.PhieuMuon, err
 	var ngayTra sql.NullTime
 
 	err := rows.Scan(&borrow.MaPM, &borrow.MaDG, &borrow.MaQuyenSach,
-		&borrow.MaCN, &borrow.NgayMuon, &ngayTra)
+		&borrow.MaCN, &borrow.NgayMuon, &ngayTra, &borrow.HanTra, &borrow.SoLanGiaHan)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan PhieuMuon: %w", err)
 	}
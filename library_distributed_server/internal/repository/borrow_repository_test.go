@@ -0,0 +1,303 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"library_distributed_server/internal/config"
+	"library_distributed_server/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// finesOfQ1AtQ2 stores the PHAT fragment of Q1 at Q2, away from Q1's loans
+var finesOfQ1AtQ2 = config.AllocationConfig{Relation: "PHAT", Fragment: "Q1", Site: "Q2"}
+
+// onLoan answers at siteID for copies of the branch lent to reader DG001 ten days ago and
+// daysOverdue days overdue
+func onLoan(siteID string, daysOverdue int, copies ...string) func(stmt fakeStmt) *fakeResult {
+	lent := time.Now().AddDate(0, 0, -10)
+	due := lent.AddDate(0, 0, 10-daysOverdue)
+	return func(stmt fakeStmt) *fakeResult {
+		query := strings.TrimSpace(stmt.query)
+		switch {
+		case strings.HasPrefix(query, "SELECT MaQuyenSach, ISBN, MaCN, TinhTrang FROM QUYENSACH"):
+			var rows [][]driver.Value
+			for _, maQuyenSach := range copies {
+				rows = append(rows, []driver.Value{maQuyenSach, "978-0-123456-78-9", siteID, string(models.CopyOnLoan)})
+			}
+			return &fakeResult{rows: rows}
+		case strings.HasPrefix(query, "SELECT MaPM, MaDG, MaQuyenSach, MaCN, NgayMuon, NgayTra, HanTra, SoLanGiaHan FROM PHIEUMUON"):
+			var rows [][]driver.Value
+			for i, maQuyenSach := range copies {
+				rows = append(rows, []driver.Value{int64(i + 1), "DG001", maQuyenSach, siteID, lent, nil, due, int64(0)})
+			}
+			return &fakeResult{rows: rows}
+		case strings.Contains(query, "DATEDIFF"):
+			return &fakeResult{rows: [][]driver.Value{{int64(1), "DG001", lent, due, int64(daysOverdue)}}}
+		case strings.HasPrefix(query, "SELECT MaDG, HoTen, MaCN_DangKy, LoaiDG FROM DOCGIA"):
+			return &fakeResult{rows: [][]driver.Value{{"DG001", "Nguyễn Văn A", siteID, "Thường"}}}
+		}
+		return nil
+	}
+}
+
+func TestLateReturnFinedOnlyOnceLoanCommits(t *testing.T) {
+	ctx := context.Background()
+	q1 := &fakeSite{respond: onLoan("Q1", 3, "QS001"), failCommit: true}
+	q2 := &fakeSite{}
+	base := fakeSites{"Q1": q1, "Q2": q2}.base(t, finesOfQ1AtQ2)
+	borrows := &BorrowRepository{BaseRepository: base, siteID: "Q1", holds: &HoldRepository{BaseRepository: base, siteID: "Q1"}}
+
+	// The loan fails to commit, so the fine must not be recorded
+	if _, err := borrows.ReturnBook(ctx, "QS001", "Q1"); err == nil {
+		t.Fatal("ReturnBook succeeded with Q1 failing to commit")
+	}
+	if fines := q2.committed("INSERT INTO PHAT"); len(fines) != 0 {
+		t.Fatalf("Q2 committed fines %+v of a loan left open", fines)
+	}
+
+	q1.failCommit = false
+	response, err := borrows.ReturnBook(ctx, "QS001", "Q1")
+	if err != nil {
+		t.Fatalf("ReturnBook: %v", err)
+	}
+	if response.Fine == nil || response.Fine.SoTien != 15000 {
+		t.Fatalf("returned fine %+v, want 15000 for 3 days", response.Fine)
+	}
+	if fines := q2.committed("INSERT INTO PHAT"); len(fines) != 1 {
+		t.Errorf("Q2 committed %d fines, want 1", len(fines))
+	}
+}
+
+func TestLateReturnReusesRecordedFine(t *testing.T) {
+	ctx := context.Background()
+	lent := time.Now().AddDate(0, 0, -10)
+	q1 := &fakeSite{respond: onLoan("Q1", 3, "QS001")}
+	q2 := &fakeSite{}
+	q2.respond = func(stmt fakeStmt) *fakeResult {
+		if strings.Contains(stmt.query, "FROM PHAT WITH (UPDLOCK, HOLDLOCK)") {
+			return &fakeResult{rows: [][]driver.Value{{"Q1-fine1", "DG001", "QS001", "Q1", lent, lent.AddDate(0, 0, 7), time.Now(),
+				int64(3), int64(5000), int64(15000), int64(0), int64(0), models.FineUnpaid, time.Now()}}}
+		}
+		return nil
+	}
+	base := fakeSites{"Q1": q1, "Q2": q2}.base(t, finesOfQ1AtQ2)
+	borrows := &BorrowRepository{BaseRepository: base, siteID: "Q1", holds: &HoldRepository{BaseRepository: base, siteID: "Q1"}}
+
+	response, err := borrows.ReturnBook(ctx, "QS001", "Q1")
+	if err != nil {
+		t.Fatalf("ReturnBook: %v", err)
+	}
+	if response.Fine == nil || response.Fine.MaPhat != "Q1-fine1" {
+		t.Fatalf("returned fine %+v, want the recorded Q1-fine1", response.Fine)
+	}
+	if fines := q2.committed("INSERT INTO PHAT"); len(fines) != 0 {
+		t.Errorf("Q2 committed fines %+v for a loan already fined", fines)
+	}
+}
+
+func TestBatchReturnReportsCopiesReturnedBeforeCommitFailed(t *testing.T) {
+	ctx := context.Background()
+	q1 := &fakeSite{respond: onLoan("Q1", 0, "QS001"), failCommit: true}
+	q3 := &fakeSite{respond: onLoan("Q3", 0, "QS002")}
+	base := fakeSites{"Q1": q1, "Q3": q3}.base(t)
+	borrows := &BorrowRepository{BaseRepository: base, siteID: "Q1", holds: &HoldRepository{BaseRepository: base, siteID: "Q1"}}
+
+	// Q3 records the transfer home of its copy, so commits first; Q1 then fails
+	_, err := borrows.ReturnBooks(ctx, []string{"QS001", "QS002"}, "Q1")
+	var incomplete *models.BatchIncomplete
+	if !errors.As(err, &incomplete) {
+		t.Fatalf("ReturnBooks = %v, want *models.BatchIncomplete", err)
+	}
+	receipt := incomplete.Receipt
+	if receipt.Committed {
+		t.Error("receipt of a partly committed batch is marked committed")
+	}
+	if item := receipt.Items[0]; item.Success || item.Error == "" {
+		t.Errorf("copy of the failed site reported %+v, want it not returned", item)
+	}
+	if item := receipt.Items[1]; !item.Success || item.Transfer == nil {
+		t.Errorf("copy of the committed site reported %+v, want it returned and shipped home", item)
+	}
+
+	if loans := q3.committed("UPDATE PHIEUMUON"); len(loans) != 1 {
+		t.Errorf("Q3 committed %d returns, want 1", len(loans))
+	}
+	if loans := q1.committed("UPDATE PHIEUMUON"); len(loans) != 0 {
+		t.Errorf("Q1 committed returns %+v with its commit failing", loans)
+	}
+}
+
+func TestBatchReturnFinesOnlyOnceLoansCommit(t *testing.T) {
+	ctx := context.Background()
+	q1 := &fakeSite{respond: onLoan("Q1", 3, "QS001", "QS002"), failCommit: true}
+	q2 := &fakeSite{}
+	base := fakeSites{"Q1": q1, "Q2": q2}.base(t, finesOfQ1AtQ2)
+	borrows := &BorrowRepository{BaseRepository: base, siteID: "Q1", holds: &HoldRepository{BaseRepository: base, siteID: "Q1"}}
+
+	if _, err := borrows.ReturnBooks(ctx, []string{"QS001", "QS002"}, "Q1"); err == nil {
+		t.Fatal("ReturnBooks succeeded with Q1 failing to commit")
+	}
+	if fines := q2.committed("INSERT INTO PHAT"); len(fines) != 0 {
+		t.Fatalf("Q2 committed fines %+v of loans left open", fines)
+	}
+
+	q1.failCommit = false
+	receipt, err := borrows.ReturnBooks(ctx, []string{"QS001", "QS002"}, "Q1")
+	if err != nil {
+		t.Fatalf("ReturnBooks: %v", err)
+	}
+	if receipt.TotalFine != 30000 {
+		t.Errorf("batch fined %d, want 30000", receipt.TotalFine)
+	}
+	if fines := q2.committed("INSERT INTO PHAT"); len(fines) != 2 {
+		t.Errorf("Q2 committed %d fines, want 2", len(fines))
+	}
+}
+
+// readyAtQ1 is the hold of reader DG001 at Q1 that copy QS001 of Q1 is set aside for
+func readyAtQ1() *models.DatCho {
+	deadline := time.Now().Add(48 * time.Hour)
+	return &models.DatCho{
+		MaDC:          "Q1-hold1",
+		MaDG:          "DG001",
+		ISBN:          "978-0-123456-78-9",
+		MaCN:          "Q1",
+		MaCNNhanSach:  "Q1",
+		NgayDat:       time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC),
+		TrangThai:     models.HoldReady,
+		MaQuyenSach:   "QS001",
+		MaCNQuyenSach: "Q1",
+		HanNhan:       &deadline,
+	}
+}
+
+// lendable answers at Q1 for reader DG001 with no loans, copy QS001 on hold for them and any
+// other copy on the shelf; holds stored at Q1 are answered with hold
+func lendable(hold *models.DatCho) func(stmt fakeStmt) *fakeResult {
+	status := func(maQuyenSach driver.Value) string {
+		if maQuyenSach == "QS001" {
+			return string(models.CopyOnHold)
+		}
+		return string(models.CopyAvailable)
+	}
+	return func(stmt fakeStmt) *fakeResult {
+		query := strings.TrimSpace(stmt.query)
+		switch {
+		case strings.HasPrefix(query, "SELECT MaQuyenSach, ISBN, MaCN, TinhTrang FROM QUYENSACH"):
+			return &fakeResult{rows: [][]driver.Value{{stmt.args[0], "978-0-123456-78-9", "Q1", status(stmt.args[0])}}}
+		case strings.HasPrefix(query, "SELECT TinhTrang"):
+			return &fakeResult{rows: [][]driver.Value{{status(stmt.args[0])}}}
+		case strings.HasPrefix(query, "SELECT MaDG FROM DOCGIA WITH (UPDLOCK, ROWLOCK)"):
+			return &fakeResult{rows: [][]driver.Value{{"DG001"}}}
+		case strings.HasPrefix(query, "SELECT MaDG, HoTen, MaCN_DangKy, LoaiDG FROM DOCGIA"):
+			return &fakeResult{rows: [][]driver.Value{{"DG001", "Nguyễn Văn A", "Q1", "Thường"}}}
+		case strings.HasPrefix(query, "INSERT INTO PHIEUMUON"):
+			return &fakeResult{rows: [][]driver.Value{{int64(7)}}}
+		case strings.Contains(query, "FROM DATCHO") && hold != nil:
+			return &fakeResult{rows: [][]driver.Value{holdRow(hold)}}
+		}
+		return nil
+	}
+}
+
+// heldElsewhere answers holds stored away from Q1
+func heldElsewhere(hold *models.DatCho) func(stmt fakeStmt) *fakeResult {
+	return func(stmt fakeStmt) *fakeResult {
+		if strings.Contains(stmt.query, "FROM DATCHO") {
+			return &fakeResult{rows: [][]driver.Value{holdRow(hold)}}
+		}
+		return nil
+	}
+}
+
+func TestBorrowClaimsHoldWithLoan(t *testing.T) {
+	ctx := context.Background()
+	q1 := &fakeSite{respond: lendable(readyAtQ1()), failCommit: true}
+	base := fakeSites{"Q1": q1}.base(t)
+	borrows := &BorrowRepository{BaseRepository: base, siteID: "Q1", holds: &HoldRepository{BaseRepository: base, siteID: "Q1"}}
+
+	// The loan fails to commit, and the hold stays ready with it
+	if err := borrows.CreateBorrow(ctx, &models.PhieuMuon{MaDG: "DG001", MaQuyenSach: "QS001", MaCN: "Q1"}, "Q1"); err == nil {
+		t.Fatal("CreateBorrow succeeded with Q1 failing to commit")
+	}
+	if updates := q1.committed("UPDATE DATCHO"); len(updates) != 0 {
+		t.Fatalf("Q1 committed hold updates %+v of a loan not made", updates)
+	}
+
+	q1.failCommit = false
+	if err := borrows.CreateBorrow(ctx, &models.PhieuMuon{MaDG: "DG001", MaQuyenSach: "QS001", MaCN: "Q1"}, "Q1"); err != nil {
+		t.Fatalf("CreateBorrow: %v", err)
+	}
+	updates, loans := q1.committed("UPDATE DATCHO"), q1.committed("INSERT INTO PHIEUMUON")
+	if len(updates) != 1 || updates[0].args[0] != models.HoldFulfilled {
+		t.Fatalf("Q1 committed hold updates %+v, want one to %s", updates, models.HoldFulfilled)
+	}
+	if len(loans) != 1 || updates[0].tx == 0 || updates[0].tx != loans[0].tx {
+		t.Errorf("hold claimed in transaction %d, want that of the loan", updates[0].tx)
+	}
+}
+
+func TestBorrowGivesBackHoldStoredElsewhere(t *testing.T) {
+	ctx := context.Background()
+	q1 := &fakeSite{respond: lendable(nil), failCommit: true}
+	q2 := &fakeSite{respond: heldElsewhere(readyAtQ1())}
+	base := fakeSites{"Q1": q1, "Q2": q2}.base(t, config.AllocationConfig{Relation: "DATCHO", Fragment: "Q1", Site: "Q2"})
+	borrows := &BorrowRepository{BaseRepository: base, siteID: "Q1", holds: &HoldRepository{BaseRepository: base, siteID: "Q1"}}
+
+	if err := borrows.CreateBorrow(ctx, &models.PhieuMuon{MaDG: "DG001", MaQuyenSach: "QS001", MaCN: "Q1"}, "Q1"); err == nil {
+		t.Fatal("CreateBorrow succeeded with Q1 failing to commit")
+	}
+	updates := q2.committed("UPDATE DATCHO")
+	if len(updates) != 2 || updates[0].args[0] != models.HoldFulfilled || updates[1].args[0] != models.HoldReady {
+		t.Fatalf("Q2 committed hold updates %+v, want the claim then its restore", updates)
+	}
+}
+
+func TestBatchBorrowClaimsHoldWithLoans(t *testing.T) {
+	ctx := context.Background()
+	q1 := &fakeSite{respond: lendable(readyAtQ1()), failCommit: true}
+	base := fakeSites{"Q1": q1}.base(t)
+	borrows := &BorrowRepository{BaseRepository: base, siteID: "Q1", holds: &HoldRepository{BaseRepository: base, siteID: "Q1"}}
+
+	if _, err := borrows.CreateBorrows(ctx, "DG001", []string{"QS001", "QS002"}, "Q1"); err == nil {
+		t.Fatal("CreateBorrows succeeded with Q1 failing to commit")
+	}
+	if updates := q1.committed("UPDATE DATCHO"); len(updates) != 0 {
+		t.Fatalf("Q1 committed hold updates %+v of loans not made", updates)
+	}
+
+	q1.failCommit = false
+	receipt, err := borrows.CreateBorrows(ctx, "DG001", []string{"QS001", "QS002"}, "Q1")
+	if err != nil {
+		t.Fatalf("CreateBorrows: %v", err)
+	}
+	if !receipt.Committed {
+		t.Error("receipt of a committed batch is not marked committed")
+	}
+	updates, loans := q1.committed("UPDATE DATCHO"), q1.committed("INSERT INTO PHIEUMUON")
+	if len(updates) != 1 || updates[0].args[0] != models.HoldFulfilled {
+		t.Fatalf("Q1 committed hold updates %+v, want one to %s", updates, models.HoldFulfilled)
+	}
+	if len(loans) != 2 || updates[0].tx == 0 || updates[0].tx != loans[0].tx {
+		t.Errorf("hold claimed in transaction %d, want that of the loans", updates[0].tx)
+	}
+}
+
+func TestBatchBorrowGivesBackHoldStoredElsewhere(t *testing.T) {
+	ctx := context.Background()
+	q1 := &fakeSite{respond: lendable(nil), failCommit: true}
+	q2 := &fakeSite{respond: heldElsewhere(readyAtQ1())}
+	base := fakeSites{"Q1": q1, "Q2": q2}.base(t, config.AllocationConfig{Relation: "DATCHO", Fragment: "Q1", Site: "Q2"})
+	borrows := &BorrowRepository{BaseRepository: base, siteID: "Q1", holds: &HoldRepository{BaseRepository: base, siteID: "Q1"}}
+
+	if _, err := borrows.CreateBorrows(ctx, "DG001", []string{"QS001", "QS002"}, "Q1"); err == nil {
+		t.Fatal("CreateBorrows succeeded with Q1 failing to commit")
+	}
+	updates := q2.committed("UPDATE DATCHO")
+	if len(updates) != 2 || updates[0].args[0] != models.HoldFulfilled || updates[1].args[0] != models.HoldReady {
+		t.Fatalf("Q2 committed hold updates %+v, want the claim then its restore", updates)
+	}
+}
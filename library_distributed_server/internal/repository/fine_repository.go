@@ -0,0 +1,494 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"library_distributed_server/internal/config"
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
+	"log"
+	"time"
+)
+
+// fineColumns are the PHAT columns read by scanPhat, in order
+const fineColumns = "MaPhat, MaDG, MaQuyenSach, MaCN, NgayMuon, HanTra, NgayTra, SoNgayQuaHan, MucPhat, SoTien, DaThanhToan, DaMien, TrangThai, NgayTao"
+
+// FineRepository handles overdue fines and the payments and waivers recorded against them.
+// A fine is stored in the PHAT fragment of the branch that made the loan, next to its
+// transactions in GIAODICHPHAT, while the reader may be registered at any branch.
+type FineRepository struct {
+	*BaseRepository
+	siteID string // Current site for this repository instance
+}
+
+// FineRepositoryInterface defines fine operations
+type FineRepositoryInterface interface {
+	GetFine(ctx context.Context, maPhat string) (*models.Phat, error)
+	GetFinesByReader(ctx context.Context, maDG string) ([]*models.Phat, error)
+	GetSiteFines(ctx context.Context, siteID string, unpaidOnly bool) ([]*models.Phat, error)
+
+	// Settlement at the branch of the fine
+	RecordPayment(ctx context.Context, maPhat string, amount int, note, user, userSite string) (*models.Phat, error)
+	WaiveFine(ctx context.Context, maPhat string, amount int, note, user, userSite string) (*models.Phat, error)
+
+	// Reporting (Manager only)
+	GetFineReport(ctx context.Context) (*models.FineReport, error)
+}
+
+// NewFineRepository creates a new fine repository
+func NewFineRepository(store *config.Store, siteID string) FineRepositoryInterface {
+	return &FineRepository{
+		BaseRepository: NewBaseRepository(store),
+		siteID:         siteID,
+	}
+}
+
+// GetFine retrieves a fine with its payments and waivers, from whichever branch made the loan
+func (r *FineRepository) GetFine(ctx context.Context, maPhat string) (*models.Phat, error) {
+	// MaPhat does not determine the fragment, so every fragment of PHAT is searched
+	fine, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "PHAT",
+		Select:   "SELECT " + fineColumns + " FROM PHAT",
+		Filters:  []query.Predicate{query.Eq("MaPhat", maPhat)},
+	}, scanPhat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up fine %s: %w", maPhat, err)
+	}
+	if !found {
+		return nil, fmt.Errorf("fine not found: %s", maPhat)
+	}
+
+	// The transactions of a fine are stored in the GIAODICHPHAT fragment of the same branch
+	result, err := query.Merge(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "GIAODICHPHAT",
+		Select:   "SELECT MaGD, MaPhat, MaCN, Loai, SoTien, NgayGD, NguoiThucHien, GhiChu FROM GIAODICHPHAT",
+		Filters: []query.Predicate{
+			query.Eq("MaCN", fine.MaCN),
+			query.Eq("MaPhat", maPhat),
+		},
+		OrderBy: "NgayGD, MaGD",
+	}, scanGiaoDichPhat, func(a, b *models.GiaoDichPhat) bool {
+		if !a.NgayGD.Equal(b.NgayGD) {
+			return a.NgayGD.Before(b.NgayGD)
+		}
+		return a.MaGD < b.MaGD
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transactions of fine %s: %w", maPhat, err)
+	}
+	for _, t := range result.Rows {
+		fine.GiaoDich = append(fine.GiaoDich, *t)
+	}
+	return fine, nil
+}
+
+// GetFinesByReader retrieves the fines of a reader at every branch, most recent first
+func (r *FineRepository) GetFinesByReader(ctx context.Context, maDG string) ([]*models.Phat, error) {
+	result, err := query.Merge(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "PHAT",
+		Select:   "SELECT " + fineColumns + " FROM PHAT",
+		Filters:  []query.Predicate{query.Eq("MaDG", maDG)},
+		OrderBy:  "NgayTao DESC, MaPhat DESC",
+	}, scanPhat, newerFine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fines of reader %s: %w", maDG, err)
+	}
+	return result.Rows, nil
+}
+
+// GetSiteFines retrieves the fines assessed at a branch, most recent first
+func (r *FineRepository) GetSiteFines(ctx context.Context, siteID string, unpaidOnly bool) ([]*models.Phat, error) {
+	filters := []query.Predicate{query.Eq("MaCN", siteID)}
+	if unpaidOnly {
+		filters = append(filters, query.Eq("TrangThai", models.FineUnpaid))
+	}
+
+	result, err := query.Merge(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "PHAT",
+		Select:   "SELECT " + fineColumns + " FROM PHAT",
+		Filters:  filters,
+		OrderBy:  "NgayTao DESC, MaPhat DESC",
+	}, scanPhat, newerFine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fines of site %s: %w", siteID, err)
+	}
+	return result.Rows, nil
+}
+
+// RecordPayment records a payment of at most the outstanding amount against a fine of userSite
+func (r *FineRepository) RecordPayment(ctx context.Context, maPhat string, amount int, note, user, userSite string) (*models.Phat, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("payment amount must be positive")
+	}
+	return r.settle(ctx, maPhat, models.FinePayment, amount, note, user, userSite)
+}
+
+// WaiveFine waives part of a fine of userSite, or all of its outstanding amount when amount is 0
+func (r *FineRepository) WaiveFine(ctx context.Context, maPhat string, amount int, note, user, userSite string) (*models.Phat, error) {
+	if amount < 0 {
+		return nil, fmt.Errorf("waiver amount must not be negative")
+	}
+	return r.settle(ctx, maPhat, models.FineWaiver, amount, note, user, userSite)
+}
+
+// GetFineReport sums the outstanding fines and the fines accruing on overdue loans at every branch
+func (r *FineRepository) GetFineReport(ctx context.Context) (*models.FineReport, error) {
+	cfg := r.config()
+
+	type owing struct {
+		maCN, maDG    string
+		fines, amount int
+	}
+	unpaid, err := query.Union(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "PHAT",
+		Select:   "SELECT MaCN, MaDG, COUNT(*), SUM(SoTien - DaThanhToan - DaMien) FROM PHAT",
+		Filters:  []query.Predicate{query.Eq("TrangThai", models.FineUnpaid)},
+		GroupBy:  "MaCN, MaDG",
+	}, func(rows *sql.Rows) (owing, error) {
+		var o owing
+		err := rows.Scan(&o.maCN, &o.maDG, &o.fines, &o.amount)
+		return o, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outstanding fines: %w", err)
+	}
+
+	accruing, err := r.accruingFines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := r.circulationPolicies(ctx, r.siteID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Branches in topology order, then any branch known only from its rows
+	var order []string
+	seen := make(map[string]bool)
+	add := func(maCN string) {
+		if !seen[maCN] {
+			seen[maCN] = true
+			order = append(order, maCN)
+		}
+	}
+	for _, siteID := range cfg.SiteIDs() {
+		add(siteID)
+	}
+	for _, o := range unpaid.Rows {
+		add(o.maCN)
+	}
+	for _, a := range accruing {
+		add(a.maCN)
+	}
+
+	report := &models.FineReport{Branches: make([]models.BranchFines, len(order))}
+	branches := make(map[string]*models.BranchFines, len(order))
+	for i, maCN := range order {
+		rate := policies.resolve(maCN, models.DefaultReaderCategory).MucPhat
+		report.Branches[i] = models.BranchFines{MaCN: maCN, DailyRate: rate}
+		branches[maCN] = &report.Branches[i]
+	}
+
+	readers := make(map[string]bool)
+	for _, o := range unpaid.Rows {
+		b := branches[o.maCN]
+		b.TotalUnpaid += o.amount
+		b.UnpaidFines += o.fines
+		b.ReadersOwing++
+		report.TotalUnpaid += o.amount
+		report.UnpaidFines += o.fines
+		readers[o.maDG] = true
+	}
+	report.ReadersOwing = len(readers)
+
+	for _, a := range accruing {
+		b := branches[a.maCN]
+		b.OverdueLoans += a.loans
+		b.TotalAccruing += a.amount
+		report.TotalAccruing += a.amount
+	}
+	return report, nil
+}
+
+// settle records a payment or waiver against a fine stored at userSite. The fine row is locked
+// so concurrent settlements cannot together exceed the amount assessed.
+func (r *FineRepository) settle(ctx context.Context, maPhat, kind string, amount int, note, user, userSite string) (*models.Phat, error) {
+	db, _, err := r.GetFragmentConnection("PHAT", userSite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to site %s: %w", userSite, err)
+	}
+	transactionDB, _, err := r.GetFragmentConnection("GIAODICHPHAT", userSite)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to site %s: %w", userSite, err)
+	}
+	if transactionDB != db {
+		return nil, fmt.Errorf("fragments PHAT and GIAODICHPHAT of branch %s are stored at different sites", userSite)
+	}
+
+	var fine *models.Phat
+	err = r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, "SELECT "+fineColumns+" FROM PHAT WITH (UPDLOCK) WHERE MaPhat = ? AND MaCN = ?", maPhat, userSite)
+		if err != nil {
+			return fmt.Errorf("failed to find fine: %w", err)
+		}
+		if rows.Next() {
+			fine, err = scanPhat(rows)
+		} else if err = rows.Err(); err == nil {
+			err = fmt.Errorf("fine %s not found at site %s", maPhat, userSite)
+		}
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		if fine.ConLai == 0 {
+			return fmt.Errorf("fine %s is already settled", maPhat)
+		}
+		if kind == models.FineWaiver && amount == 0 {
+			amount = fine.ConLai
+		}
+		if amount > fine.ConLai {
+			return fmt.Errorf("amount %d exceeds the %d outstanding on fine %s", amount, fine.ConLai, maPhat)
+		}
+
+		if kind == models.FinePayment {
+			fine.DaThanhToan += amount
+		} else {
+			fine.DaMien += amount
+		}
+		fine.ConLai -= amount
+		switch {
+		case fine.ConLai > 0:
+			fine.TrangThai = models.FineUnpaid
+		case fine.DaThanhToan > 0:
+			fine.TrangThai = models.FinePaid
+		default:
+			fine.TrangThai = models.FineWaived
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO GIAODICHPHAT (MaGD, MaPhat, MaCN, Loai, SoTien, NgayGD, NguoiThucHien, GhiChu)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, newRowID(userSite), maPhat, userSite, kind, amount, time.Now(), user, nullString(note))
+		if err != nil {
+			return fmt.Errorf("failed to record %s: %w", kind, err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE PHAT
+			SET DaThanhToan = ?, DaMien = ?, TrangThai = ?
+			WHERE MaPhat = ?
+		`, fine.DaThanhToan, fine.DaMien, fine.TrangThai, maPhat)
+		if err != nil {
+			return fmt.Errorf("failed to update fine: %w", err)
+		}
+
+		log.Printf("%s of %d recorded against fine %s (reader %s) by %s in site %s, %d outstanding",
+			kind, amount, maPhat, fine.MaDG, user, userSite, fine.ConLai)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fine, nil
+}
+
+// assessFine records the fine of a loan returned daysOverdue days late under policy, in the PHAT
+// fragment reached through tx. It returns nil when the loan was returned within the grace days
+// or the policy charges nothing. A loan is fined once: the fine already recorded for it, as by
+// a return retried after its fine committed, is returned instead.
+func (r *BaseRepository) assessFine(ctx context.Context, tx *sql.Tx, loan *models.PhieuMuon, daysOverdue int, policy *models.ChinhSach) (*models.Phat, error) {
+	rate := policy.MucPhat
+	charged := chargedDays(policy, daysOverdue)
+	if charged <= 0 || rate <= 0 {
+		return nil, nil
+	}
+
+	// The key-range lock keeps a concurrent return of the loan from recording its fine meanwhile
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+fineColumns+`
+		FROM PHAT WITH (UPDLOCK, HOLDLOCK)
+		WHERE MaQuyenSach = ? AND MaCN = ? AND NgayMuon = ?
+	`, loan.MaQuyenSach, loan.MaCN, loan.NgayMuon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find fine of book copy %s: %w", loan.MaQuyenSach, err)
+	}
+	var existing *models.Phat
+	if rows.Next() {
+		existing, err = scanPhat(rows)
+	} else {
+		err = rows.Err()
+	}
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find fine of book copy %s: %w", loan.MaQuyenSach, err)
+	}
+	if existing != nil {
+		log.Printf("Fine %s already recorded for the loan of book copy %s from %s",
+			existing.MaPhat, loan.MaQuyenSach, loan.NgayMuon.Format(time.DateOnly))
+		return existing, nil
+	}
+
+	fine := &models.Phat{
+		MaPhat:       newRowID(loan.MaCN),
+		MaDG:         loan.MaDG,
+		MaQuyenSach:  loan.MaQuyenSach,
+		MaCN:         loan.MaCN,
+		NgayMuon:     loan.NgayMuon,
+		HanTra:       loan.HanTra,
+		NgayTra:      *loan.NgayTra,
+		SoNgayQuaHan: daysOverdue,
+		MucPhat:      rate,
+		SoTien:       charged * rate,
+		ConLai:       charged * rate,
+		TrangThai:    models.FineUnpaid,
+		NgayTao:      *loan.NgayTra,
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO PHAT (MaPhat, MaDG, MaQuyenSach, MaCN, NgayMuon, HanTra, NgayTra, SoNgayQuaHan, MucPhat, SoTien, TrangThai, NgayTao)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, fine.MaPhat, fine.MaDG, fine.MaQuyenSach, fine.MaCN, fine.NgayMuon, fine.HanTra, fine.NgayTra,
+		fine.SoNgayQuaHan, fine.MucPhat, fine.SoTien, fine.TrangThai, fine.NgayTao)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record fine: %w", err)
+	}
+
+	log.Printf("Fine %s of %d for %d days overdue assessed on reader %s in site %s under policy %s",
+		fine.MaPhat, fine.SoTien, daysOverdue, fine.MaDG, fine.MaCN, policy.MaCS)
+	return fine, nil
+}
+
+// ReaderFineBalance sums the outstanding fines of a reader at every branch. Like loan limits,
+// the balance must not be checked on a partial sum, so a site that cannot be reached is an error.
+func (r *BaseRepository) ReaderFineBalance(ctx context.Context, maDG string) (int, error) {
+	result, err := query.Union(ctx, r.Executor(""), query.Query{
+		Relation: "PHAT",
+		Select:   "SELECT ISNULL(SUM(SoTien - DaThanhToan - DaMien), 0) FROM PHAT",
+		Filters: []query.Predicate{
+			query.Eq("MaDG", maDG),
+			query.Eq("TrangThai", models.FineUnpaid),
+		},
+	}, func(rows *sql.Rows) (int, error) {
+		var amount int
+		err := rows.Scan(&amount)
+		return amount, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to sum fines of reader %s: %w", maDG, err)
+	}
+	if len(result.Failed) > 0 {
+		return 0, fmt.Errorf("cannot sum fines of reader %s: sites %s unavailable", maDG, failedSites(result.Failed))
+	}
+
+	balance := 0
+	for _, amount := range result.Rows {
+		balance += amount
+	}
+	return balance, nil
+}
+
+// loanAccrual is the fine building up on the overdue loans of one reader at one branch
+type loanAccrual struct {
+	maCN, maDG    string
+	loans, amount int
+}
+
+// accruingFines computes the fines building up on overdue loans not yet returned, per branch and
+// reader. Each loan is priced with the policy of its branch and its reader's category, so the
+// loans are shipped one by one; a reader whose category cannot be looked up is priced as the
+// default category.
+func (r *BaseRepository) accruingFines(ctx context.Context, filters ...query.Predicate) ([]loanAccrual, error) {
+	type overdueLoan struct {
+		maCN, maDG string
+		days       int
+	}
+	result, err := query.Union(ctx, r.Executor(""), query.Query{
+		Relation: "PHIEUMUON",
+		Select:   "SELECT MaCN, MaDG, DATEDIFF(day, HanTra, GETDATE()) FROM PHIEUMUON",
+		Filters:  append(filters, query.Where("NgayTra IS NULL AND DATEDIFF(day, HanTra, GETDATE()) > 0")),
+	}, func(rows *sql.Rows) (overdueLoan, error) {
+		var l overdueLoan
+		err := rows.Scan(&l.maCN, &l.maDG, &l.days)
+		return l, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query overdue loans: %w", err)
+	}
+	if len(result.Rows) == 0 {
+		return nil, nil
+	}
+
+	var readers []interface{}
+	categories := make(map[string]string)
+	for _, l := range result.Rows {
+		if _, seen := categories[l.maDG]; !seen {
+			categories[l.maDG] = models.DefaultReaderCategory
+			readers = append(readers, l.maDG)
+		}
+	}
+	found, err := query.Union(ctx, r.Executor(""), query.Query{
+		Relation: "DOCGIA",
+		Select:   "SELECT MaDG, HoTen, MaCN_DangKy, LoaiDG FROM DOCGIA",
+		Filters:  []query.Predicate{query.In("MaDG", readers...)},
+	}, r.ScanDocGia)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up readers of overdue loans: %w", err)
+	}
+	for _, reader := range found.Rows {
+		categories[reader.MaDG] = reader.LoaiDG
+	}
+
+	policies, err := r.circulationPolicies(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var accruals []loanAccrual
+	index := make(map[[2]string]int)
+	for _, l := range result.Rows {
+		policy := policies.resolve(l.maCN, categories[l.maDG])
+		key := [2]string{l.maCN, l.maDG}
+		i, exists := index[key]
+		if !exists {
+			i = len(accruals)
+			index[key] = i
+			accruals = append(accruals, loanAccrual{maCN: l.maCN, maDG: l.maDG})
+		}
+		accruals[i].loans++
+		accruals[i].amount += chargedDays(policy, l.days) * policy.MucPhat
+	}
+	return accruals, nil
+}
+
+// newerFine orders fines most recent first
+func newerFine(a, b *models.Phat) bool {
+	if !a.NgayTao.Equal(b.NgayTao) {
+		return a.NgayTao.After(b.NgayTao)
+	}
+	return a.MaPhat > b.MaPhat
+}
+
+// scanPhat scans a row of fineColumns
+func scanPhat(rows *sql.Rows) (*models.Phat, error) {
+	var fine models.Phat
+	err := rows.Scan(&fine.MaPhat, &fine.MaDG, &fine.MaQuyenSach, &fine.MaCN, &fine.NgayMuon,
+		&fine.HanTra, &fine.NgayTra, &fine.SoNgayQuaHan, &fine.MucPhat, &fine.SoTien,
+		&fine.DaThanhToan, &fine.DaMien, &fine.TrangThai, &fine.NgayTao)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan Phat: %w", err)
+	}
+	fine.ConLai = fine.SoTien - fine.DaThanhToan - fine.DaMien
+	return &fine, nil
+}
+
+func scanGiaoDichPhat(rows *sql.Rows) (*models.GiaoDichPhat, error) {
+	var t models.GiaoDichPhat
+	var note sql.NullString
+	err := rows.Scan(&t.MaGD, &t.MaPhat, &t.MaCN, &t.Loai, &t.SoTien, &t.NgayGD, &t.NguoiThucHien, &note)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan GiaoDichPhat: %w", err)
+	}
+	t.GhiChu = note.String
+	return &t, nil
+}
@@ -0,0 +1,850 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"library_distributed_server/internal/config"
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
+	"log"
+	"time"
+)
+
+// holdColumns are the DATCHO columns read by scanDatCho, in order
+const holdColumns = "MaDC, MaDG, ISBN, MaCN, MaCN_NhanSach, NgayDat, TrangThai, MaQuyenSach, MaCN_QuyenSach, HanNhan"
+
+// errCopyUnavailable reports that a copy was no longer free when setting it aside
+var errCopyUnavailable = errors.New("book copy is no longer available")
+
+// HoldRepository handles the hold queue for titles. Holds are stored in the DATCHO fragment
+// of the branch where they were placed (the reader's registration branch), while the copy
+// set aside for a hold stays in the QUYENSACH fragment of its own branch, so a copy returned
+// at one branch can serve a hold placed at another. A copy set aside for a hold picked up at
+// another branch is shipped there with a transfer, as a copy returned elsewhere is shipped home.
+type HoldRepository struct {
+	*BaseRepository
+	siteID string // Current site for this repository instance
+}
+
+// HoldRepositoryInterface defines hold queue operations
+type HoldRepositoryInterface interface {
+	PlaceHold(ctx context.Context, hold *models.DatCho, userSite string) error
+	CancelHold(ctx context.Context, maDC string, userSite string) error
+	GetHold(ctx context.Context, maDC string) (*models.DatCho, error)
+	GetHoldQueue(ctx context.Context, isbn string) ([]*models.DatCho, error)
+	GetHoldsByReader(ctx context.Context, maDG string) ([]*models.DatCho, error)
+	GetHoldShelf(ctx context.Context, siteID string) ([]*models.DatCho, error)
+
+	// Queue processing
+	AssignCopy(ctx context.Context, bookCopy *models.QuyenSach) (*models.DatCho, error)
+	ExpireHolds(ctx context.Context) (int, error)
+}
+
+// NewHoldRepository creates a new hold repository
+func NewHoldRepository(store *config.Store, siteID string) HoldRepositoryInterface {
+	return &HoldRepository{
+		BaseRepository: NewBaseRepository(store),
+		siteID:         siteID,
+	}
+}
+
+// PlaceHold queues a reader registered at userSite for a title. A copy already free at
+// any branch is set aside right away; otherwise the next matching return serves the queue.
+func (r *HoldRepository) PlaceHold(ctx context.Context, hold *models.DatCho, userSite string) error {
+	// Authorization: holds are placed at the reader's registration branch
+	if hold.MaCN != userSite {
+		return fmt.Errorf("access denied: cannot place hold in site %s from site %s", hold.MaCN, userSite)
+	}
+
+	db, fragmentSite, err := r.GetFragmentConnection("DATCHO", hold.MaCN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to site %s: %w", hold.MaCN, err)
+	}
+
+	data := map[string]interface{}{
+		"MaCN": hold.MaCN,
+	}
+	if err := r.ValidateFragmentation(ctx, "DATCHO", data, fragmentSite); err != nil {
+		return fmt.Errorf("fragmentation validation failed: %w", err)
+	}
+
+	var readerCount int
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM DOCGIA WHERE MaDG = ? AND MaCN_DangKy = ?",
+		hold.MaDG, hold.MaCN).Scan(&readerCount)
+	if err != nil {
+		return fmt.Errorf("failed to validate reader: %w", err)
+	}
+	if readerCount == 0 {
+		return fmt.Errorf("reader %s not found in site %s", hold.MaDG, hold.MaCN)
+	}
+
+	// SACH and CHINHANH are replicated, so the local copy answers
+	var bookCount int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM SACH WHERE ISBN = ?", hold.ISBN).Scan(&bookCount); err != nil {
+		return fmt.Errorf("failed to validate book: %w", err)
+	}
+	if bookCount == 0 {
+		return fmt.Errorf("book not found: %s", hold.ISBN)
+	}
+	if hold.MaCNNhanSach != "" {
+		var branchCount int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM CHINHANH WHERE MaCN = ?", hold.MaCNNhanSach).Scan(&branchCount); err != nil {
+			return fmt.Errorf("failed to validate pickup branch: %w", err)
+		}
+		if branchCount == 0 {
+			return fmt.Errorf("pickup branch not found: %s", hold.MaCNNhanSach)
+		}
+	}
+
+	hold.MaDC = newRowID(hold.MaCN)
+	hold.NgayDat = time.Now()
+	hold.TrangThai = models.HoldWaiting
+
+	err = r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
+		// One active hold per reader and title; the range lock keeps a concurrent request out
+		var active int
+		err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM DATCHO WITH (UPDLOCK, HOLDLOCK)
+			WHERE MaDG = ? AND ISBN = ? AND TrangThai IN (?, ?, ?)
+		`, hold.MaDG, hold.ISBN, models.HoldWaiting, models.HoldInTransit, models.HoldReady).Scan(&active)
+		if err != nil {
+			return fmt.Errorf("failed to check existing holds: %w", err)
+		}
+		if active > 0 {
+			return fmt.Errorf("reader %s already has an active hold on %s", hold.MaDG, hold.ISBN)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO DATCHO (MaDC, MaDG, ISBN, MaCN, MaCN_NhanSach, NgayDat, TrangThai)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, hold.MaDC, hold.MaDG, hold.ISBN, hold.MaCN, nullString(hold.MaCNNhanSach), hold.NgayDat, hold.TrangThai)
+		if err != nil {
+			return fmt.Errorf("failed to create hold: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("Hold %s placed for reader %s on %s in site %s", hold.MaDC, hold.MaDG, hold.ISBN, hold.MaCN)
+
+	// A copy may be free at a branch the reader did not check; the queue decides who gets it
+	if bookCopy, found := r.availableCopy(ctx, hold.ISBN, hold.MaCNNhanSach); found {
+		if _, err := r.AssignCopy(ctx, bookCopy); err != nil {
+			log.Printf("Failed to set aside free copy %s for holds on %s: %v", bookCopy.MaQuyenSach, hold.ISBN, err)
+		}
+	}
+
+	placed, err := r.GetHold(ctx, hold.MaDC)
+	if err != nil {
+		return nil // The hold is placed; only the refreshed state is missing
+	}
+	*hold = *placed
+	return nil
+}
+
+// CancelHold cancels an active hold placed at userSite. A copy already set aside for it
+// goes to the next reader in the queue.
+func (r *HoldRepository) CancelHold(ctx context.Context, maDC string, userSite string) error {
+	hold, err := r.GetHold(ctx, maDC)
+	if err != nil {
+		return err
+	}
+
+	if hold.MaCN != userSite {
+		return fmt.Errorf("access denied: cannot cancel hold in site %s from site %s", hold.MaCN, userSite)
+	}
+
+	switch hold.TrangThai {
+	case models.HoldWaiting:
+		cancelled, err := r.setHoldState(ctx, hold, models.HoldWaiting, models.HoldCancelled)
+		if err != nil {
+			return err
+		}
+		if !cancelled {
+			// A copy was set aside between the lookup and the update
+			return r.CancelHold(ctx, maDC, userSite)
+		}
+	case models.HoldInTransit:
+		// The copy on its way is shipped home once checked in at the pickup branch
+		cancelled, err := r.setHoldState(ctx, hold, models.HoldInTransit, models.HoldCancelled)
+		if err != nil {
+			return err
+		}
+		if !cancelled {
+			// The copy was checked in between the lookup and the update
+			return r.CancelHold(ctx, maDC, userSite)
+		}
+	case models.HoldReady:
+		if err := r.release(ctx, hold, models.HoldCancelled); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("hold %s is no longer active (status: %s)", maDC, hold.TrangThai)
+	}
+
+	log.Printf("Hold %s cancelled in site %s", maDC, hold.MaCN)
+	return nil
+}
+
+// GetHold retrieves a hold by ID
+func (r *HoldRepository) GetHold(ctx context.Context, maDC string) (*models.DatCho, error) {
+	// MaDC does not determine the fragment, so every fragment of DATCHO is searched
+	hold, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "DATCHO",
+		Select:   "SELECT " + holdColumns + " FROM DATCHO",
+		Filters:  []query.Predicate{query.Eq("MaDC", maDC)},
+	}, scanDatCho)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up hold %s: %w", maDC, err)
+	}
+	if !found {
+		return nil, fmt.Errorf("hold not found: %s", maDC)
+	}
+	return hold, nil
+}
+
+// GetHoldQueue retrieves the active holds on a title across every branch, in queue order,
+// numbering the waiting ones
+func (r *HoldRepository) GetHoldQueue(ctx context.Context, isbn string) ([]*models.DatCho, error) {
+	result, err := r.queue(ctx, isbn, models.HoldWaiting, models.HoldInTransit, models.HoldReady)
+	if err != nil {
+		return nil, err
+	}
+
+	position := 0
+	for _, hold := range result {
+		if hold.TrangThai == models.HoldWaiting {
+			position++
+			hold.ViTri = position
+		}
+	}
+	return result, nil
+}
+
+// GetHoldsByReader retrieves the holds of a reader, most recent first
+func (r *HoldRepository) GetHoldsByReader(ctx context.Context, maDG string) ([]*models.DatCho, error) {
+	result, err := query.Merge(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "DATCHO",
+		Select:   "SELECT " + holdColumns + " FROM DATCHO",
+		Filters:  []query.Predicate{query.Eq("MaDG", maDG)},
+		OrderBy:  "NgayDat DESC, MaDC DESC",
+	}, scanDatCho, func(a, b *models.DatCho) bool {
+		if !a.NgayDat.Equal(b.NgayDat) {
+			return a.NgayDat.After(b.NgayDat)
+		}
+		return a.MaDC > b.MaDC
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holds of reader %s: %w", maDG, err)
+	}
+	return result.Rows, nil
+}
+
+// GetHoldShelf retrieves the ready holds whose copy waits for pickup at a branch, earliest deadline
+// first: holds picked up there, and holds of any pickup branch served by a copy of the branch
+func (r *HoldRepository) GetHoldShelf(ctx context.Context, siteID string) ([]*models.DatCho, error) {
+	// The holds may come from any branch, so every fragment of DATCHO is searched
+	result, err := query.Merge(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "DATCHO",
+		Select:   "SELECT " + holdColumns + " FROM DATCHO",
+		Filters: []query.Predicate{
+			query.Eq("TrangThai", models.HoldReady),
+			query.Where("(MaCN_NhanSach = ? OR (MaCN_NhanSach IS NULL AND MaCN_QuyenSach = ?))", siteID, siteID),
+		},
+		OrderBy: "HanNhan, MaDC",
+	}, scanDatCho, func(a, b *models.DatCho) bool {
+		if !a.HanNhan.Equal(*b.HanNhan) {
+			return a.HanNhan.Before(*b.HanNhan)
+		}
+		return a.MaDC < b.MaDC
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query hold shelf of site %s: %w", siteID, err)
+	}
+	return result.Rows, nil
+}
+
+// AssignCopy sets a copy that became available aside for the oldest waiting hold on its
+// title, whichever branch the hold was placed at. A copy of another branch than the hold's
+// pickup branch is shipped there, and the hold is ready once the copy is checked in (see
+// receive). It returns the hold, or nil when nobody is waiting or the copy was taken meanwhile.
+func (r *HoldRepository) AssignCopy(ctx context.Context, bookCopy *models.QuyenSach) (*models.DatCho, error) {
+	waiting, err := r.queue(ctx, bookCopy.ISBN, models.HoldWaiting)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hold := range waiting {
+		assigned, err := r.setAside(ctx, hold, bookCopy)
+		if errors.Is(err, errCopyUnavailable) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if assigned {
+			return hold, nil
+		}
+		// The hold was cancelled or served meanwhile; try the next one
+	}
+	return nil, nil
+}
+
+// ExpireHolds ends the ready holds stored at this site whose pickup deadline has passed,
+// passing each copy on to the next reader in its queue. It returns the number expired.
+func (r *HoldRepository) ExpireHolds(ctx context.Context) (int, error) {
+	db, _, err := r.GetFragmentConnection("DATCHO", r.siteID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to site %s: %w", r.siteID, err)
+	}
+
+	rows, err := db.QueryContext(ctx,
+		"SELECT "+holdColumns+" FROM DATCHO WHERE MaCN = ? AND TrangThai = ? AND HanNhan < ?",
+		r.siteID, models.HoldReady, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to query expired holds: %w", err)
+	}
+	var expired []*models.DatCho
+	for rows.Next() {
+		hold, err := scanDatCho(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, hold)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read expired holds: %w", err)
+	}
+
+	count := 0
+	for _, hold := range expired {
+		// One hold failing, e.g. because its copy's site is down, must not block the others
+		if err := r.release(ctx, hold, models.HoldExpired); err != nil {
+			log.Printf("Failed to expire hold %s: %v", hold.MaDC, err)
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// queue retrieves the holds on a title in the given states across every branch, oldest first
+func (r *HoldRepository) queue(ctx context.Context, isbn string, states ...string) ([]*models.DatCho, error) {
+	values := make([]interface{}, len(states))
+	for i, state := range states {
+		values[i] = state
+	}
+
+	result, err := query.Merge(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "DATCHO",
+		Select:   "SELECT " + holdColumns + " FROM DATCHO",
+		Filters: []query.Predicate{
+			query.Eq("ISBN", isbn),
+			query.In("TrangThai", values...),
+		},
+		OrderBy: "NgayDat, MaDC",
+	}, scanDatCho, func(a, b *models.DatCho) bool {
+		if !a.NgayDat.Equal(b.NgayDat) {
+			return a.NgayDat.Before(b.NgayDat)
+		}
+		return a.MaDC < b.MaDC
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query hold queue of %s: %w", isbn, err)
+	}
+	return result.Rows, nil
+}
+
+// hasWaitingHolds reports whether readers at any branch are waiting for a title. A
+// site that cannot be reached may hold the oldest of them, so it is an error.
+func (r *HoldRepository) hasWaitingHolds(ctx context.Context, isbn string) (bool, error) {
+	result, err := query.Union(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "DATCHO",
+		Select:   "SELECT COUNT(*) FROM DATCHO",
+		Filters: []query.Predicate{
+			query.Eq("ISBN", isbn),
+			query.Eq("TrangThai", models.HoldWaiting),
+		},
+	}, func(rows *sql.Rows) (int, error) {
+		var count int
+		err := rows.Scan(&count)
+		return count, err
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to query holds on %s: %w", isbn, err)
+	}
+	if len(result.Failed) > 0 {
+		return false, fmt.Errorf("cannot check holds on %s: sites %s unavailable", isbn, failedSites(result.Failed))
+	}
+
+	for _, count := range result.Rows {
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// availableCopy finds a free copy of a title, preferring the pickup branch
+func (r *HoldRepository) availableCopy(ctx context.Context, isbn, pickupBranch string) (*models.QuyenSach, bool) {
+	lookup := func(filters ...query.Predicate) (*models.QuyenSach, bool) {
+		bookCopy, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+			Relation: "QUYENSACH",
+			Select:   "SELECT MaQuyenSach, ISBN, MaCN, TinhTrang FROM QUYENSACH",
+			Filters:  append([]query.Predicate{query.Eq("ISBN", isbn), query.Eq("TinhTrang", models.CopyAvailable)}, filters...),
+		}, r.ScanQuyenSach)
+		if err != nil {
+			log.Printf("Failed to look up free copies of %s: %v", isbn, err)
+		}
+		return bookCopy, found
+	}
+
+	if pickupBranch != "" {
+		if bookCopy, found := lookup(query.Eq("MaCN", pickupBranch)); found {
+			return bookCopy, true
+		}
+	}
+	return lookup()
+}
+
+// setAside marks a free copy as on hold and the waiting hold as ready in one step. A copy of
+// another branch than the hold's pickup branch is marked in transit instead, with a transfer to
+// the pickup branch recorded next to it, and the hold waits in transit until the copy is checked
+// in there. Both rows are updated only from the expected state, so a copy lent or a hold
+// cancelled concurrently is left alone. It reports false when the hold is no longer waiting.
+func (r *HoldRepository) setAside(ctx context.Context, hold *models.DatCho, bookCopy *models.QuyenSach) (bool, error) {
+	copyDB, _, err := r.GetFragmentConnection("QUYENSACH", bookCopy.MaCN)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to site %s: %w", bookCopy.MaCN, err)
+	}
+	holdDB, _, err := r.GetFragmentConnection("DATCHO", hold.MaCN)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to site %s: %w", hold.MaCN, err)
+	}
+
+	var transfer *models.ChuyenTra
+	transferDB := copyDB
+	if hold.MaCNNhanSach != "" && hold.MaCNNhanSach != bookCopy.MaCN {
+		transfer = &models.ChuyenTra{
+			MaCT:         newRowID(bookCopy.MaCN),
+			MaQuyenSach:  bookCopy.MaQuyenSach,
+			ISBN:         bookCopy.ISBN,
+			MaCN:         bookCopy.MaCN,
+			MaCNNhanTra:  bookCopy.MaCN,
+			MaCNNhanSach: hold.MaCNNhanSach,
+			MaDC:         hold.MaDC,
+			TrangThai:    models.TransferInTransit,
+			NgayNhanTra:  time.Now(),
+		}
+		transferDB, _, err = r.GetFragmentConnection("CHUYENTRA", bookCopy.MaCN)
+		if err != nil {
+			return false, fmt.Errorf("failed to connect to site %s: %w", bookCopy.MaCN, err)
+		}
+	}
+
+	copyTx, err := copyDB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction on site %s: %w", bookCopy.MaCN, err)
+	}
+	defer copyTx.Rollback()
+
+	// Fragments stored on the same database share one transaction
+	holdTx := copyTx
+	if holdDB != copyDB {
+		holdTx, err = holdDB.BeginTx(ctx, nil)
+		if err != nil {
+			return false, fmt.Errorf("failed to begin transaction on site %s: %w", hold.MaCN, err)
+		}
+		defer holdTx.Rollback()
+	}
+	transferTx := copyTx
+	if transferDB == holdDB {
+		transferTx = holdTx
+	} else if transferDB != copyDB {
+		transferTx, err = transferDB.BeginTx(ctx, nil)
+		if err != nil {
+			return false, fmt.Errorf("failed to begin transaction on site %s: %w", bookCopy.MaCN, err)
+		}
+		defer transferTx.Rollback()
+	}
+
+	// A shipped copy has no pickup deadline until it is checked in
+	copyStatus, holdState := models.CopyOnHold, models.HoldReady
+	var deadline *time.Time
+	if transfer != nil {
+		copyStatus, holdState = models.CopyInTransit, models.HoldInTransit
+	} else {
+		pickupBy := time.Now().Add(r.config().Holds.PickupWindow)
+		deadline = &pickupBy
+	}
+
+	result, err := copyTx.ExecContext(ctx, `
+		UPDATE QUYENSACH
+		SET TinhTrang = ?
+		WHERE MaQuyenSach = ? AND MaCN = ? AND TinhTrang = ?
+	`, copyStatus, bookCopy.MaQuyenSach, bookCopy.MaCN, models.CopyAvailable)
+	if err != nil {
+		return false, fmt.Errorf("failed to set aside book copy: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return false, errCopyUnavailable
+	}
+
+	result, err = holdTx.ExecContext(ctx, `
+		UPDATE DATCHO
+		SET TrangThai = ?, MaQuyenSach = ?, MaCN_QuyenSach = ?, HanNhan = ?
+		WHERE MaDC = ? AND TrangThai = ?
+	`, holdState, bookCopy.MaQuyenSach, bookCopy.MaCN, deadline, hold.MaDC, models.HoldWaiting)
+	if err != nil {
+		return false, fmt.Errorf("failed to update hold: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return false, nil
+	}
+
+	if transfer != nil {
+		if err := insertTransfer(ctx, transferTx, transfer); err != nil {
+			return false, err
+		}
+	}
+
+	if holdTx != copyTx {
+		if err := holdTx.Commit(); err != nil {
+			return false, fmt.Errorf("failed to commit hold on site %s: %w", hold.MaCN, err)
+		}
+	}
+	if transferTx != copyTx && transferTx != holdTx {
+		if err := transferTx.Commit(); err != nil {
+			return false, fmt.Errorf("failed to commit transfer on site %s: %w", bookCopy.MaCN, err)
+		}
+	}
+	if err := copyTx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit book copy on site %s: %w", bookCopy.MaCN, err)
+	}
+
+	hold.TrangThai = holdState
+	hold.MaQuyenSach = bookCopy.MaQuyenSach
+	hold.MaCNQuyenSach = bookCopy.MaCN
+	hold.HanNhan = deadline
+	bookCopy.TinhTrang = copyStatus
+
+	if transfer != nil {
+		log.Printf("Book copy %s in site %s set aside for hold %s (reader %s), shipped to site %s as transfer %s",
+			bookCopy.MaQuyenSach, bookCopy.MaCN, hold.MaDC, hold.MaDG, hold.MaCNNhanSach, transfer.MaCT)
+		return true, nil
+	}
+	log.Printf("Book copy %s in site %s set aside for hold %s (reader %s) until %s",
+		bookCopy.MaQuyenSach, bookCopy.MaCN, hold.MaDC, hold.MaDG, deadline.Format(time.RFC3339))
+	return true, nil
+}
+
+// receive checks in a copy shipped for a hold at the hold's pickup branch and sets it aside there
+// until the pickup deadline, returning the hold made ready. A hold that ended while the copy was on
+// its way gets nothing: the shipment becomes a transfer home from the pickup branch, as for a copy
+// returned there, and nil is returned.
+func (r *HoldRepository) receive(ctx context.Context, transfer *models.ChuyenTra) (*models.DatCho, error) {
+	hold, err := r.GetHold(ctx, transfer.MaDC)
+	if err != nil {
+		return nil, err
+	}
+
+	db, _, err := r.GetFragmentConnection("CHUYENTRA", transfer.MaCN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to site %s: %w", transfer.MaCN, err)
+	}
+	copyDB, _, err := r.GetFragmentConnection("QUYENSACH", transfer.MaCN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to site %s: %w", transfer.MaCN, err)
+	}
+	holdDB, _, err := r.GetFragmentConnection("DATCHO", hold.MaCN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to site %s: %w", hold.MaCN, err)
+	}
+
+	now := time.Now()
+	deadline := now.Add(r.config().Holds.PickupWindow)
+	ready := false
+	err = r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
+		var err error
+
+		// Fragments stored on the same database share one transaction
+		holdTx := tx
+		if holdDB != db {
+			holdTx, err = holdDB.BeginTx(ctx, nil)
+			if err != nil {
+				return fmt.Errorf("failed to begin transaction on site %s: %w", hold.MaCN, err)
+			}
+			defer holdTx.Rollback()
+		}
+		copyTx := tx
+		if copyDB == holdDB {
+			copyTx = holdTx
+		} else if copyDB != db {
+			copyTx, err = copyDB.BeginTx(ctx, nil)
+			if err != nil {
+				return fmt.Errorf("failed to begin transaction on site %s: %w", transfer.MaCN, err)
+			}
+			defer copyTx.Rollback()
+		}
+
+		result, err := holdTx.ExecContext(ctx, "UPDATE DATCHO SET TrangThai = ?, HanNhan = ? WHERE MaDC = ? AND TrangThai = ?",
+			models.HoldReady, deadline, hold.MaDC, models.HoldInTransit)
+		if err != nil {
+			return fmt.Errorf("failed to update hold %s: %w", hold.MaDC, err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return fmt.Errorf("failed to update hold %s: %w", hold.MaDC, err)
+		}
+		ready = affected > 0
+
+		if ready {
+			result, err = tx.ExecContext(ctx, "UPDATE CHUYENTRA SET TrangThai = ?, NgayNhan = ? WHERE MaCT = ? AND TrangThai = ?",
+				models.TransferReceived, now, transfer.MaCT, models.TransferInTransit)
+		} else {
+			result, err = tx.ExecContext(ctx, `
+				UPDATE CHUYENTRA
+				SET MaCN_NhanTra = ?, MaCN_NhanSach = NULL, MaDC = NULL, NgayNhanTra = ?
+				WHERE MaCT = ? AND TrangThai = ?
+			`, transfer.MaCNNhanSach, now, transfer.MaCT, models.TransferInTransit)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update transfer %s: %w", transfer.MaCT, err)
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return fmt.Errorf("book copy %s is not on its way to site %s", transfer.MaQuyenSach, transfer.MaCNNhanSach)
+		}
+
+		if ready {
+			result, err = copyTx.ExecContext(ctx, `
+				UPDATE QUYENSACH
+				SET TinhTrang = ?
+				WHERE MaQuyenSach = ? AND MaCN = ? AND TinhTrang = ?
+			`, models.CopyOnHold, transfer.MaQuyenSach, transfer.MaCN, models.CopyInTransit)
+			if err != nil {
+				return fmt.Errorf("failed to update book status: %w", err)
+			}
+			if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+				return fmt.Errorf("book copy %s is not in transit", transfer.MaQuyenSach)
+			}
+		}
+
+		if holdTx != tx {
+			if err := holdTx.Commit(); err != nil {
+				return fmt.Errorf("failed to commit hold on site %s: %w", hold.MaCN, err)
+			}
+		}
+		if copyTx != tx && copyTx != holdTx {
+			if err := copyTx.Commit(); err != nil {
+				return fmt.Errorf("failed to commit book copy on site %s: %w", transfer.MaCN, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !ready {
+		log.Printf("Hold %s ended while book copy %s was shipped to site %s; shipping it home to site %s as transfer %s",
+			hold.MaDC, transfer.MaQuyenSach, transfer.MaCNNhanSach, transfer.MaCN, transfer.MaCT)
+		transfer.MaCNNhanTra = transfer.MaCNNhanSach
+		transfer.MaCNNhanSach = ""
+		transfer.MaDC = ""
+		transfer.NgayNhanTra = now
+		return nil, nil
+	}
+
+	transfer.TrangThai = models.TransferReceived
+	transfer.NgayNhan = &now
+	hold.TrangThai = models.HoldReady
+	hold.HanNhan = &deadline
+	log.Printf("Book copy %s of site %s checked in at site %s for hold %s (reader %s) until %s",
+		transfer.MaQuyenSach, transfer.MaCN, transfer.MaCNNhanSach, hold.MaDC, hold.MaDG, deadline.Format(time.RFC3339))
+	return hold, nil
+}
+
+// sendHome ships a held copy waiting at another branch back home once its hold ended, with a
+// transfer as for a copy returned there. The next reader in the queue is served when the copy
+// is checked in at home.
+func (r *HoldRepository) sendHome(ctx context.Context, bookCopy *models.QuyenSach, from string) error {
+	db, _, err := r.GetFragmentConnection("QUYENSACH", bookCopy.MaCN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to site %s: %w", bookCopy.MaCN, err)
+	}
+	transferDB, _, err := r.GetFragmentConnection("CHUYENTRA", bookCopy.MaCN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to site %s: %w", bookCopy.MaCN, err)
+	}
+
+	transfer := &models.ChuyenTra{
+		MaCT:        newRowID(bookCopy.MaCN),
+		MaQuyenSach: bookCopy.MaQuyenSach,
+		ISBN:        bookCopy.ISBN,
+		MaCN:        bookCopy.MaCN,
+		MaCNNhanTra: from,
+		TrangThai:   models.TransferInTransit,
+		NgayNhanTra: time.Now(),
+	}
+	shipped := false
+	err = r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE QUYENSACH
+			SET TinhTrang = ?
+			WHERE MaQuyenSach = ? AND MaCN = ? AND TinhTrang = ?
+		`, models.CopyInTransit, bookCopy.MaQuyenSach, bookCopy.MaCN, models.CopyOnHold)
+		if err != nil {
+			return fmt.Errorf("failed to ship book copy %s home: %w", bookCopy.MaQuyenSach, err)
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return nil // Lent or released meanwhile
+		}
+
+		// Fragments stored on the same database share one transaction
+		if transferDB == db {
+			if err := insertTransfer(ctx, tx, transfer); err != nil {
+				return err
+			}
+		} else if err := r.ExecuteWithTransaction(ctx, transferDB, func(transferTx *sql.Tx) error {
+			return insertTransfer(ctx, transferTx, transfer)
+		}); err != nil {
+			return err
+		}
+		shipped = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if shipped {
+		bookCopy.TinhTrang = models.CopyInTransit
+		log.Printf("Book copy %s of site %s left at site %s, shipped home as transfer %s",
+			bookCopy.MaQuyenSach, bookCopy.MaCN, from, transfer.MaCT)
+	}
+	return nil
+}
+
+// release ends a ready hold in the given state and offers its copy to the next reader, or ships
+// it home first when it waits at another branch
+func (r *HoldRepository) release(ctx context.Context, hold *models.DatCho, state string) error {
+	released, err := r.setHoldState(ctx, hold, models.HoldReady, state)
+	if err != nil || !released {
+		return err // Not released: picked up or released meanwhile
+	}
+
+	bookCopy, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "QUYENSACH",
+		Select:   "SELECT MaQuyenSach, ISBN, MaCN, TinhTrang FROM QUYENSACH",
+		Filters:  []query.Predicate{query.Eq("MaQuyenSach", hold.MaQuyenSach)},
+	}, r.ScanQuyenSach)
+	if err != nil {
+		return fmt.Errorf("failed to look up book copy %s: %w", hold.MaQuyenSach, err)
+	}
+	if !found {
+		return nil
+	}
+	// A copy shipped to the pickup branch goes back home before serving the queue
+	if hold.MaCNNhanSach != "" && hold.MaCNNhanSach != bookCopy.MaCN {
+		return r.sendHome(ctx, bookCopy, hold.MaCNNhanSach)
+	}
+
+	db, _, err := r.GetFragmentConnection("QUYENSACH", bookCopy.MaCN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to site %s: %w", bookCopy.MaCN, err)
+	}
+	result, err := db.ExecContext(ctx, `
+		UPDATE QUYENSACH
+		SET TinhTrang = ?
+		WHERE MaQuyenSach = ? AND MaCN = ? AND TinhTrang = ?
+	`, models.CopyAvailable, bookCopy.MaQuyenSach, bookCopy.MaCN, models.CopyOnHold)
+	if err != nil {
+		return fmt.Errorf("failed to release book copy %s: %w", bookCopy.MaQuyenSach, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return nil
+	}
+	bookCopy.TinhTrang = models.CopyAvailable
+
+	next, err := r.AssignCopy(ctx, bookCopy)
+	if err != nil {
+		return fmt.Errorf("failed to pass book copy %s on: %w", bookCopy.MaQuyenSach, err)
+	}
+	if next != nil {
+		log.Printf("Hold %s ended (%s), book copy %s passed on to hold %s", hold.MaDC, state, bookCopy.MaQuyenSach, next.MaDC)
+	}
+	return nil
+}
+
+// setHoldState moves a hold from one state to another, reporting false if it was no longer in from
+func (r *HoldRepository) setHoldState(ctx context.Context, hold *models.DatCho, from, to string) (bool, error) {
+	db, _, err := r.GetFragmentConnection("DATCHO", hold.MaCN)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to site %s: %w", hold.MaCN, err)
+	}
+
+	result, err := db.ExecContext(ctx, "UPDATE DATCHO SET TrangThai = ? WHERE MaDC = ? AND TrangThai = ?",
+		to, hold.MaDC, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to update hold %s: %w", hold.MaDC, err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to update hold %s: %w", hold.MaDC, err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+	hold.TrangThai = to
+	return true, nil
+}
+
+// readyHold finds the ready hold a copy is set aside for
+func (r *HoldRepository) readyHold(ctx context.Context, maQuyenSach string) (*models.DatCho, error) {
+	hold, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "DATCHO",
+		Select:   "SELECT " + holdColumns + " FROM DATCHO",
+		Filters: []query.Predicate{
+			query.Eq("MaQuyenSach", maQuyenSach),
+			query.Eq("TrangThai", models.HoldReady),
+		},
+	}, scanDatCho)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up hold on book copy %s: %w", maQuyenSach, err)
+	}
+	if !found {
+		return nil, fmt.Errorf("no ready hold found for book copy %s", maQuyenSach)
+	}
+	return hold, nil
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// scanDatCho scans a row of holdColumns into a DatCho model with null handling
+func scanDatCho(rows *sql.Rows) (*models.DatCho, error) {
+	var hold models.DatCho
+	var pickupBranch, maQuyenSach, copyBranch sql.NullString
+	var hanNhan sql.NullTime
+
+	err := rows.Scan(&hold.MaDC, &hold.MaDG, &hold.ISBN, &hold.MaCN, &pickupBranch,
+		&hold.NgayDat, &hold.TrangThai, &maQuyenSach, &copyBranch, &hanNhan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan DatCho: %w", err)
+	}
+
+	hold.MaCNNhanSach = pickupBranch.String
+	hold.MaQuyenSach = maQuyenSach.String
+	hold.MaCNQuyenSach = copyBranch.String
+	if hanNhan.Valid {
+		hold.HanNhan = &hanNhan.Time
+	}
+	return &hold, nil
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"library_distributed_server/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// holdRow is a DATCHO row of holdColumns
+func holdRow(hold *models.DatCho) []driver.Value {
+	var hanNhan driver.Value
+	if hold.HanNhan != nil {
+		hanNhan = *hold.HanNhan
+	}
+	return []driver.Value{hold.MaDC, hold.MaDG, hold.ISBN, hold.MaCN, nullable(hold.MaCNNhanSach), hold.NgayDat,
+		hold.TrangThai, nullable(hold.MaQuyenSach), nullable(hold.MaCNQuyenSach), hanNhan}
+}
+
+// transferRow is a CHUYENTRA row of transferColumns recorded by an INSERT
+func transferRow(insert fakeStmt) []driver.Value {
+	return append(append([]driver.Value{}, insert.args...), nil)
+}
+
+// waitingAtQ3 is a hold placed at Q3 for pickup at Q3, the only one on its title
+func waitingAtQ3() *models.DatCho {
+	return &models.DatCho{
+		MaDC:         "Q3-hold1",
+		MaDG:         "DG004",
+		ISBN:         "978-0-123456-78-9",
+		MaCN:         "Q3",
+		MaCNNhanSach: "Q3",
+		NgayDat:      time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC),
+		TrangThai:    models.HoldWaiting,
+	}
+}
+
+func TestReturnAtQ1FillsHoldForPickupAtQ3(t *testing.T) {
+	ctx := context.Background()
+	hold := waitingAtQ3()
+	var shipment []driver.Value
+
+	q1 := &fakeSite{}
+	q1.respond = func(stmt fakeStmt) *fakeResult {
+		switch {
+		case strings.HasPrefix(strings.TrimSpace(stmt.query), "SELECT MaQuyenSach, ISBN, MaCN, TinhTrang FROM QUYENSACH"):
+			return &fakeResult{rows: [][]driver.Value{{"QS001", hold.ISBN, "Q1", string(models.CopyOnLoan)}}}
+		case strings.Contains(stmt.query, "FROM PHIEUMUON"):
+			lent := time.Now().AddDate(0, 0, -7)
+			return &fakeResult{rows: [][]driver.Value{{int64(12), "DG001", lent, lent.AddDate(0, 0, 14), int64(-7)}}}
+		case strings.Contains(stmt.query, "FROM CHUYENTRA") && shipment != nil:
+			return &fakeResult{rows: [][]driver.Value{shipment}}
+		}
+		return nil
+	}
+	q3 := &fakeSite{}
+	q3.respond = func(stmt fakeStmt) *fakeResult {
+		if strings.Contains(stmt.query, "FROM DATCHO") {
+			return &fakeResult{rows: [][]driver.Value{holdRow(hold)}}
+		}
+		return nil
+	}
+
+	base := fakeSites{"Q1": q1, "Q3": q3}.base(t)
+	holds := &HoldRepository{BaseRepository: base, siteID: "Q1"}
+	borrows := &BorrowRepository{BaseRepository: base, siteID: "Q1", holds: holds}
+
+	// The copy returned at Q1 is shipped to Q3 for the hold
+	response, err := borrows.ReturnBook(ctx, "QS001", "Q1")
+	if err != nil {
+		t.Fatalf("ReturnBook: %v", err)
+	}
+	if response.Hold == nil || response.Hold.MaDC != hold.MaDC {
+		t.Fatalf("returned copy served hold %+v, want %s", response.Hold, hold.MaDC)
+	}
+	if response.Hold.TrangThai != models.HoldInTransit || response.Hold.MaQuyenSach != "QS001" || response.Hold.HanNhan != nil {
+		t.Errorf("hold is %s with copy %q and deadline %v, want in transit with QS001 and no deadline",
+			response.Hold.TrangThai, response.Hold.MaQuyenSach, response.Hold.HanNhan)
+	}
+
+	copyUpdates := q1.committed("UPDATE QUYENSACH")
+	if last := copyUpdates[len(copyUpdates)-1]; last.args[0] != string(models.CopyInTransit) {
+		t.Errorf("copy left as %v, want %s", last.args[0], models.CopyInTransit)
+	}
+	inserts := q1.committed("INSERT INTO CHUYENTRA")
+	if len(inserts) != 1 {
+		t.Fatalf("Q1 committed %d transfers, want 1", len(inserts))
+	}
+	if args := inserts[0].args; args[3] != "Q1" || args[5] != "Q3" || args[6] != hold.MaDC {
+		t.Errorf("transfer of %v from %v to %v for %v, want from Q1 to Q3 for %s", args[1], args[3], args[5], args[6], hold.MaDC)
+	}
+	holdUpdates := q3.committed("UPDATE DATCHO")
+	if len(holdUpdates) != 1 || holdUpdates[0].args[0] != models.HoldInTransit || holdUpdates[0].args[3] != nil {
+		t.Fatalf("Q3 committed hold updates %+v, want one to %s without a deadline", holdUpdates, models.HoldInTransit)
+	}
+
+	// Q3 checks the copy in and the hold becomes ready there
+	shipment = transferRow(inserts[0])
+	hold.TrangThai = models.HoldInTransit
+	hold.MaQuyenSach, hold.MaCNQuyenSach = "QS001", "Q1"
+
+	transfers := &TransferRepository{BaseRepository: base, siteID: "Q3", holds: &HoldRepository{BaseRepository: base, siteID: "Q3"}}
+	transfer, ready, err := transfers.CheckIn(ctx, "QS001", "Q3")
+	if err != nil {
+		t.Fatalf("CheckIn: %v", err)
+	}
+	if transfer.TrangThai != models.TransferReceived || transfer.MaCNNhanSach != "Q3" {
+		t.Errorf("transfer is %s to %q, want %s to Q3", transfer.TrangThai, transfer.MaCNNhanSach, models.TransferReceived)
+	}
+	if ready == nil || ready.TrangThai != models.HoldReady || ready.HanNhan == nil {
+		t.Fatalf("checked-in copy left hold %+v, want it ready with a deadline", ready)
+	}
+
+	if updates := q1.committed("UPDATE CHUYENTRA"); len(updates) != 1 || updates[0].args[0] != models.TransferReceived {
+		t.Errorf("Q1 committed transfer updates %+v, want one to %s", updates, models.TransferReceived)
+	}
+	copyUpdates = q1.committed("UPDATE QUYENSACH")
+	if last := copyUpdates[len(copyUpdates)-1]; last.args[0] != string(models.CopyOnHold) {
+		t.Errorf("checked-in copy left as %v, want %s", last.args[0], models.CopyOnHold)
+	}
+	holdUpdates = q3.committed("UPDATE DATCHO")
+	if last := holdUpdates[len(holdUpdates)-1]; last.args[0] != models.HoldReady {
+		t.Errorf("hold left as %v, want %s", last.args[0], models.HoldReady)
+	}
+}
+
+func TestCheckInShipsHomeWhenHoldEnded(t *testing.T) {
+	ctx := context.Background()
+	hold := waitingAtQ3()
+	hold.TrangThai = models.HoldCancelled
+	hold.MaQuyenSach, hold.MaCNQuyenSach = "QS001", "Q1"
+	shipped := time.Now().Add(-time.Hour)
+
+	q1 := &fakeSite{}
+	q1.respond = func(stmt fakeStmt) *fakeResult {
+		if strings.HasPrefix(strings.TrimSpace(stmt.query), "SELECT") && strings.Contains(stmt.query, "FROM CHUYENTRA") {
+			return &fakeResult{rows: [][]driver.Value{{"Q1-ship1", "QS001", hold.ISBN, "Q1", "Q1", "Q3", hold.MaDC,
+				models.TransferInTransit, shipped, nil}}}
+		}
+		return nil
+	}
+	q3 := &fakeSite{}
+	q3.respond = func(stmt fakeStmt) *fakeResult {
+		switch {
+		case strings.Contains(stmt.query, "UPDATE DATCHO"):
+			return &fakeResult{affected: 0} // Cancelled while the copy was on its way
+		case strings.Contains(stmt.query, "FROM DATCHO"):
+			return &fakeResult{rows: [][]driver.Value{holdRow(hold)}}
+		}
+		return nil
+	}
+
+	base := fakeSites{"Q1": q1, "Q3": q3}.base(t)
+	transfers := &TransferRepository{BaseRepository: base, siteID: "Q3", holds: &HoldRepository{BaseRepository: base, siteID: "Q3"}}
+	transfer, ready, err := transfers.CheckIn(ctx, "QS001", "Q3")
+	if err != nil {
+		t.Fatalf("CheckIn: %v", err)
+	}
+	if ready != nil {
+		t.Errorf("ended hold made ready: %+v", ready)
+	}
+	if transfer.TrangThai != models.TransferInTransit || transfer.MaCNNhanTra != "Q3" || transfer.MaCNNhanSach != "" || transfer.MaDC != "" {
+		t.Errorf("transfer is %s from %s to %q for %q, want in transit home from Q3",
+			transfer.TrangThai, transfer.MaCNNhanTra, transfer.MaCNNhanSach, transfer.MaDC)
+	}
+
+	updates := q1.committed("UPDATE CHUYENTRA")
+	if len(updates) != 1 || !strings.Contains(updates[0].query, "MaCN_NhanSach = NULL") || updates[0].args[0] != "Q3" {
+		t.Errorf("Q1 committed transfer updates %+v, want the shipment sent home from Q3", updates)
+	}
+	if updates := q1.committed("UPDATE QUYENSACH"); len(updates) != 0 {
+		t.Errorf("copy of an ended hold updated: %+v", updates)
+	}
+}
@@ -0,0 +1,697 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"library_distributed_server/internal/config"
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
+	"log"
+	"time"
+)
+
+// illColumns are the YEUCAUMUON columns read by scanYeuCauMuon, in order
+const illColumns = "MaYC, MaDG, ISBN, MaCN, MaCN_SoHuu, MaQuyenSach, TrangThai, NgayYeuCau, NgayCapNhat"
+
+// ILLRepository handles inter-library loans. A request is stored in the YEUCAUMUON fragment
+// of the requesting branch, which lends the copy, while the copy stays in the QUYENSACH
+// fragment of its owning branch throughout. Every step updates the request and the copy
+// together, each only from its expected state.
+type ILLRepository struct {
+	*BaseRepository
+	siteID  string            // Current site for this repository instance
+	borrows *BorrowRepository // Loans made at the requesting branch
+}
+
+// ILLRepositoryInterface defines inter-library loan operations
+type ILLRepositoryInterface interface {
+	GetRequest(ctx context.Context, maYC string) (*models.YeuCauMuon, error)
+	GetRequests(ctx context.Context, siteID, trangThai string) ([]*models.YeuCauMuon, error)
+
+	// Requesting branch
+	RequestCopy(ctx context.Context, request *models.YeuCauMuon, userSite string) error
+	ReceiveCopy(ctx context.Context, maYC string, userSite string) (*models.YeuCauMuon, error)
+	LendCopy(ctx context.Context, maYC string, userSite string) (*models.YeuCauMuon, *models.PhieuMuon, error)
+	ReturnCopy(ctx context.Context, maYC string, userSite string) (*models.YeuCauMuon, *models.Phat, error)
+
+	// Owning branch
+	ShipCopy(ctx context.Context, maYC, maQuyenSach string, userSite string) (*models.YeuCauMuon, error)
+	ReceiveReturn(ctx context.Context, maYC string, userSite string) (*models.YeuCauMuon, *models.DatCho, error)
+
+	// Either branch
+	CancelRequest(ctx context.Context, maYC string, userSite string) (*models.YeuCauMuon, error)
+
+	// Recovery of steps whose two commits did not both go through
+	ReconcileRequests(ctx context.Context) (int, error)
+}
+
+// illCopySteps gives, for each state of a request holding a copy, the status of the copy in
+// that state and the statuses it had one step earlier
+var illCopySteps = map[string]struct {
+	status   models.CopyStatus
+	previous []models.CopyStatus
+}{
+	models.ILLInTransit: {models.CopyInTransit, []models.CopyStatus{models.CopyAvailable}},
+	models.ILLArrived:   {models.CopyAtBorrower, []models.CopyStatus{models.CopyInTransit}},
+	models.ILLOnLoan:    {models.CopyOnLoan, []models.CopyStatus{models.CopyAtBorrower}},
+	models.ILLReturning: {models.CopyInTransit, []models.CopyStatus{models.CopyAtBorrower, models.CopyOnLoan}},
+}
+
+// NewILLRepository creates a new inter-library loan repository
+func NewILLRepository(store *config.Store, siteID string) ILLRepositoryInterface {
+	base := NewBaseRepository(store)
+	return &ILLRepository{
+		BaseRepository: base,
+		siteID:         siteID,
+		borrows: &BorrowRepository{
+			BaseRepository: base,
+			siteID:         siteID,
+			holds:          &HoldRepository{BaseRepository: base, siteID: siteID},
+		},
+	}
+}
+
+// RequestCopy asks another branch for a copy of a title on behalf of a reader. Without an
+// owning branch, one with a free copy is chosen.
+func (r *ILLRepository) RequestCopy(ctx context.Context, request *models.YeuCauMuon, userSite string) error {
+	// Authorization: requests are placed by the branch that will lend the copy
+	if request.MaCN != userSite {
+		return fmt.Errorf("access denied: cannot request a copy for site %s from site %s", request.MaCN, userSite)
+	}
+
+	db, fragmentSite, err := r.GetFragmentConnection("YEUCAUMUON", request.MaCN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to site %s: %w", request.MaCN, err)
+	}
+
+	data := map[string]interface{}{
+		"MaCN": request.MaCN,
+	}
+	if err := r.ValidateFragmentation(ctx, "YEUCAUMUON", data, fragmentSite); err != nil {
+		return fmt.Errorf("fragmentation validation failed: %w", err)
+	}
+
+	// The reader may be registered at any branch
+	if _, err := r.homeReader(ctx, request.MaDG); err != nil {
+		return err
+	}
+
+	// SACH and CHINHANH are replicated, so the local copy answers
+	var bookCount int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM SACH WHERE ISBN = ?", request.ISBN).Scan(&bookCount); err != nil {
+		return fmt.Errorf("failed to validate book: %w", err)
+	}
+	if bookCount == 0 {
+		return fmt.Errorf("book not found: %s", request.ISBN)
+	}
+
+	// A title free on the requesting branch's own shelf is lent from there
+	if _, found := r.freeCopy(ctx, request.ISBN, query.Eq("MaCN", request.MaCN)); found {
+		return fmt.Errorf("a copy of %s is available at %s", request.ISBN, request.MaCN)
+	}
+
+	switch {
+	case request.MaCNSoHuu == request.MaCN:
+		return fmt.Errorf("cannot request a copy of %s from the requesting branch %s", request.ISBN, request.MaCN)
+	case request.MaCNSoHuu != "":
+		var branchCount int
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM CHINHANH WHERE MaCN = ?", request.MaCNSoHuu).Scan(&branchCount); err != nil {
+			return fmt.Errorf("failed to validate owning branch: %w", err)
+		}
+		if branchCount == 0 {
+			return fmt.Errorf("owning branch not found: %s", request.MaCNSoHuu)
+		}
+	default:
+		bookCopy, found := r.freeCopy(ctx, request.ISBN, query.Where("MaCN <> ?", request.MaCN))
+		if !found {
+			return fmt.Errorf("no other branch has a free copy of %s", request.ISBN)
+		}
+		request.MaCNSoHuu = bookCopy.MaCN
+	}
+
+	request.MaYC = newRowID(request.MaCN)
+	request.MaQuyenSach = ""
+	request.TrangThai = models.ILLRequested
+	request.NgayYeuCau = time.Now()
+	request.NgayCapNhat = request.NgayYeuCau
+
+	err = r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
+		// One open request per reader and title; the range lock keeps a concurrent request out
+		var open int
+		err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM YEUCAUMUON WITH (UPDLOCK, HOLDLOCK)
+			WHERE MaDG = ? AND ISBN = ? AND TrangThai NOT IN (?, ?)
+		`, request.MaDG, request.ISBN, models.ILLCompleted, models.ILLCancelled).Scan(&open)
+		if err != nil {
+			return fmt.Errorf("failed to check existing requests: %w", err)
+		}
+		if open > 0 {
+			return fmt.Errorf("reader %s already has an open inter-library loan request for %s", request.MaDG, request.ISBN)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO YEUCAUMUON (MaYC, MaDG, ISBN, MaCN, MaCN_SoHuu, TrangThai, NgayYeuCau, NgayCapNhat)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, request.MaYC, request.MaDG, request.ISBN, request.MaCN, request.MaCNSoHuu,
+			request.TrangThai, request.NgayYeuCau, request.NgayCapNhat)
+		if err != nil {
+			return fmt.Errorf("failed to create inter-library loan request: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Inter-library loan request %s placed by site %s at site %s for reader %s on %s",
+		request.MaYC, request.MaCN, request.MaCNSoHuu, request.MaDG, request.ISBN)
+	return nil
+}
+
+// ShipCopy sends a free copy of the requested title from the owning branch. Without a copy,
+// the first free one is chosen.
+func (r *ILLRepository) ShipCopy(ctx context.Context, maYC, maQuyenSach string, userSite string) (*models.YeuCauMuon, error) {
+	request, err := r.GetRequest(ctx, maYC)
+	if err != nil {
+		return nil, err
+	}
+	if request.MaCNSoHuu != userSite {
+		return nil, fmt.Errorf("access denied: cannot ship a copy of site %s from site %s", request.MaCNSoHuu, userSite)
+	}
+
+	if maQuyenSach == "" {
+		bookCopy, found := r.freeCopy(ctx, request.ISBN, query.Eq("MaCN", request.MaCNSoHuu))
+		if !found {
+			return nil, fmt.Errorf("no free copy of %s at site %s", request.ISBN, request.MaCNSoHuu)
+		}
+		maQuyenSach = bookCopy.MaQuyenSach
+	}
+	request.MaQuyenSach = maQuyenSach
+
+	err = r.advance(ctx, request, models.ILLRequested, models.ILLInTransit, models.CopyAvailable, models.CopyInTransit, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Book copy %s shipped from site %s to site %s for inter-library loan request %s",
+		maQuyenSach, request.MaCNSoHuu, request.MaCN, maYC)
+	return request, nil
+}
+
+// ReceiveCopy records the arrival of a shipped copy at the requesting branch
+func (r *ILLRepository) ReceiveCopy(ctx context.Context, maYC string, userSite string) (*models.YeuCauMuon, error) {
+	request, err := r.requesterRequest(ctx, maYC, userSite)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.advance(ctx, request, models.ILLInTransit, models.ILLArrived, models.CopyInTransit, models.CopyAtBorrower, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Book copy %s of site %s arrived at site %s for inter-library loan request %s",
+		request.MaQuyenSach, request.MaCNSoHuu, request.MaCN, maYC)
+	return request, nil
+}
+
+// LendCopy lends an arrived copy to the reader it was requested for. The loan is stored at
+// the requesting branch and made under its policy, like any other loan of that branch.
+func (r *ILLRepository) LendCopy(ctx context.Context, maYC string, userSite string) (*models.YeuCauMuon, *models.PhieuMuon, error) {
+	request, err := r.requesterRequest(ctx, maYC, userSite)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The reader's home row serializes their loans, as in CreateBorrow
+	reader, err := r.homeReader(ctx, request.MaDG)
+	if err != nil {
+		return nil, nil, fmt.Errorf("borrow validation failed: %w", err)
+	}
+	homeDB, _, err := r.GetFragmentConnection("DOCGIA", reader.MaCNDangKy)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to site %s: %w", reader.MaCNDangKy, err)
+	}
+	homeTx, err := homeDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction on site %s: %w", reader.MaCNDangKy, err)
+	}
+	defer homeTx.Rollback()
+	if err := lockReader(ctx, homeTx, request.MaDG); err != nil {
+		return nil, nil, fmt.Errorf("borrow validation failed: %w", err)
+	}
+
+	policy, err := r.borrows.checkReader(ctx, reader, request.MaCN, 1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("borrow validation failed: %w", err)
+	}
+
+	borrow := &models.PhieuMuon{
+		MaDG:        request.MaDG,
+		MaQuyenSach: request.MaQuyenSach,
+		MaCN:        request.MaCN,
+		NgayMuon:    time.Now(),
+	}
+	borrow.HanTra = borrow.NgayMuon.Add(loanPeriod(policy))
+
+	err = r.advance(ctx, request, models.ILLArrived, models.ILLOnLoan, models.CopyAtBorrower, models.CopyOnLoan, func(tx *sql.Tx) error {
+		var maPM int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO PHIEUMUON (MaDG, MaQuyenSach, MaCN, NgayMuon, HanTra)
+			OUTPUT INSERTED.MaPM
+			VALUES (?, ?, ?, ?, ?)
+		`, borrow.MaDG, borrow.MaQuyenSach, borrow.MaCN, borrow.NgayMuon, borrow.HanTra).Scan(&maPM)
+		if err != nil {
+			return fmt.Errorf("failed to create borrow record: %w", err)
+		}
+		borrow.MaPM = maPM
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The loan is committed; ending the lock transaction lets the reader's next loan count it
+	if err := homeTx.Commit(); err != nil {
+		log.Printf("Failed to release lock on reader %s at site %s: %v", request.MaDG, reader.MaCNDangKy, err)
+	}
+
+	log.Printf("Book copy %s of site %s lent to reader %s at site %s (borrow record %d, request %s)",
+		request.MaQuyenSach, request.MaCNSoHuu, request.MaDG, request.MaCN, borrow.MaPM, maYC)
+	return request, borrow, nil
+}
+
+// ReturnCopy ships a copy back to its owning branch: after the reader returns it, closing the
+// loan and fining a late return, or when the reader never picked it up
+func (r *ILLRepository) ReturnCopy(ctx context.Context, maYC string, userSite string) (*models.YeuCauMuon, *models.Phat, error) {
+	request, err := r.requesterRequest(ctx, maYC, userSite)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if request.TrangThai == models.ILLArrived {
+		err = r.advance(ctx, request, models.ILLArrived, models.ILLReturning, models.CopyAtBorrower, models.CopyInTransit, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Uncollected book copy %s shipped back from site %s to site %s (request %s)",
+			request.MaQuyenSach, request.MaCN, request.MaCNSoHuu, maYC)
+		return request, nil, nil
+	}
+
+	db, _, err := r.GetFragmentConnection("PHIEUMUON", request.MaCN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to site %s: %w", request.MaCN, err)
+	}
+
+	var fine *models.Phat
+	later := r.newLaterTxs()
+	defer later.rollback()
+	err = r.advance(ctx, request, models.ILLOnLoan, models.ILLReturning, models.CopyOnLoan, models.CopyInTransit, func(tx *sql.Tx) error {
+		_, lateFine, err := r.borrows.closeLoan(ctx, tx, later.join(ctx, db, tx), request.MaQuyenSach, request.MaCN)
+		fine = lateFine
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	fine = r.borrows.commitFine(later, request.MaQuyenSach, fine)
+
+	log.Printf("Book copy %s returned at site %s and shipped back to site %s (request %s)",
+		request.MaQuyenSach, request.MaCN, request.MaCNSoHuu, maYC)
+	return request, fine, nil
+}
+
+// ReceiveReturn puts a copy shipped back on its owning branch's shelf, setting it aside for
+// the oldest waiting hold on its title if any. The hold is returned.
+func (r *ILLRepository) ReceiveReturn(ctx context.Context, maYC string, userSite string) (*models.YeuCauMuon, *models.DatCho, error) {
+	request, err := r.GetRequest(ctx, maYC)
+	if err != nil {
+		return nil, nil, err
+	}
+	if request.MaCNSoHuu != userSite {
+		return nil, nil, fmt.Errorf("access denied: cannot receive a copy of site %s at site %s", request.MaCNSoHuu, userSite)
+	}
+
+	err = r.advance(ctx, request, models.ILLReturning, models.ILLCompleted, models.CopyInTransit, models.CopyAvailable, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Book copy %s back at site %s, inter-library loan request %s completed",
+		request.MaQuyenSach, request.MaCNSoHuu, maYC)
+
+	// The request is completed even if the queue cannot be served now; the copy then stays available
+	hold, err := r.borrows.holds.AssignCopy(ctx, &models.QuyenSach{
+		MaQuyenSach: request.MaQuyenSach,
+		ISBN:        request.ISBN,
+		MaCN:        request.MaCNSoHuu,
+		TinhTrang:   models.CopyAvailable,
+	})
+	if err != nil {
+		log.Printf("Failed to serve holds on %s with returned copy %s: %v", request.ISBN, request.MaQuyenSach, err)
+		return request, nil, nil
+	}
+	return request, hold, nil
+}
+
+// CancelRequest withdraws a request no copy was shipped for yet, from either branch
+func (r *ILLRepository) CancelRequest(ctx context.Context, maYC string, userSite string) (*models.YeuCauMuon, error) {
+	request, err := r.GetRequest(ctx, maYC)
+	if err != nil {
+		return nil, err
+	}
+	if request.MaCN != userSite && request.MaCNSoHuu != userSite {
+		return nil, fmt.Errorf("access denied: cannot cancel request %s of sites %s and %s from site %s",
+			maYC, request.MaCN, request.MaCNSoHuu, userSite)
+	}
+	if request.TrangThai != models.ILLRequested {
+		return nil, fmt.Errorf("request %s can no longer be cancelled (status: %s)", maYC, request.TrangThai)
+	}
+
+	db, _, err := r.GetFragmentConnection("YEUCAUMUON", request.MaCN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to site %s: %w", request.MaCN, err)
+	}
+	now := time.Now()
+	result, err := db.ExecContext(ctx, `
+		UPDATE YEUCAUMUON SET TrangThai = ?, NgayCapNhat = ?
+		WHERE MaYC = ? AND TrangThai = ?
+	`, models.ILLCancelled, now, maYC, models.ILLRequested)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel request %s: %w", maYC, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return nil, fmt.Errorf("request %s can no longer be cancelled: a copy was shipped meanwhile", maYC)
+	}
+	request.TrangThai = models.ILLCancelled
+	request.NgayCapNhat = now
+
+	log.Printf("Inter-library loan request %s cancelled from site %s", maYC, userSite)
+	return request, nil
+}
+
+// ReconcileRequests repairs the requests stored at this site whose step committed on one
+// side only. advance commits the request first, so a request one step ahead of its copy has
+// the copy moved on; a completion commits the copy first, so a returning request whose copy
+// is back on the shelf is completed. Any other mismatch is logged for a librarian. It returns
+// the number of requests repaired.
+func (r *ILLRepository) ReconcileRequests(ctx context.Context) (int, error) {
+	db, _, err := r.GetFragmentConnection("YEUCAUMUON", r.siteID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to connect to site %s: %w", r.siteID, err)
+	}
+
+	rows, err := db.QueryContext(ctx,
+		"SELECT "+illColumns+" FROM YEUCAUMUON WHERE MaCN = ? AND TrangThai IN (?, ?, ?, ?)",
+		r.siteID, models.ILLInTransit, models.ILLArrived, models.ILLOnLoan, models.ILLReturning)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query active requests: %w", err)
+	}
+	var active []*models.YeuCauMuon
+	for rows.Next() {
+		request, err := scanYeuCauMuon(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		active = append(active, request)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read active requests: %w", err)
+	}
+
+	count := 0
+	for _, request := range active {
+		// One request failing, e.g. because its owner's site is down, must not block the others
+		repaired, err := r.reconcile(ctx, request)
+		if err != nil {
+			log.Printf("Failed to reconcile inter-library loan request %s: %v", request.MaYC, err)
+			continue
+		}
+		if repaired {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// reconcile brings a request and its copy back in step, reporting whether it changed either
+func (r *ILLRepository) reconcile(ctx context.Context, request *models.YeuCauMuon) (bool, error) {
+	bookCopy, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "QUYENSACH",
+		Select:   "SELECT MaQuyenSach, ISBN, MaCN, TinhTrang FROM QUYENSACH",
+		Filters: []query.Predicate{
+			query.Eq("MaQuyenSach", request.MaQuyenSach),
+			query.Eq("MaCN", request.MaCNSoHuu),
+		},
+	}, r.ScanQuyenSach)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up book copy %s: %w", request.MaQuyenSach, err)
+	}
+	if !found {
+		return false, fmt.Errorf("book copy %s not found at site %s", request.MaQuyenSach, request.MaCNSoHuu)
+	}
+
+	step := illCopySteps[request.TrangThai]
+	if bookCopy.TinhTrang == step.status {
+		return false, nil
+	}
+
+	if request.TrangThai == models.ILLReturning && bookCopy.TinhTrang == models.CopyAvailable {
+		db, _, err := r.GetFragmentConnection("YEUCAUMUON", request.MaCN)
+		if err != nil {
+			return false, fmt.Errorf("failed to connect to site %s: %w", request.MaCN, err)
+		}
+		result, err := db.ExecContext(ctx, `
+			UPDATE YEUCAUMUON SET TrangThai = ?, NgayCapNhat = ?
+			WHERE MaYC = ? AND TrangThai = ?
+		`, models.ILLCompleted, time.Now(), request.MaYC, models.ILLReturning)
+		if err != nil {
+			return false, fmt.Errorf("failed to complete request %s: %w", request.MaYC, err)
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return false, nil
+		}
+		log.Printf("Inter-library loan request %s completed: book copy %s was already back at site %s",
+			request.MaYC, request.MaQuyenSach, request.MaCNSoHuu)
+
+		// The receipt stopped before the copy was offered to the hold queue
+		if _, err := r.borrows.holds.AssignCopy(ctx, bookCopy); err != nil {
+			log.Printf("Failed to serve holds on %s with returned copy %s: %v", request.ISBN, request.MaQuyenSach, err)
+		}
+		return true, nil
+	}
+
+	for _, previous := range step.previous {
+		if bookCopy.TinhTrang != previous {
+			continue
+		}
+		db, _, err := r.GetFragmentConnection("QUYENSACH", request.MaCNSoHuu)
+		if err != nil {
+			return false, fmt.Errorf("failed to connect to site %s: %w", request.MaCNSoHuu, err)
+		}
+		result, err := db.ExecContext(ctx, `
+			UPDATE QUYENSACH
+			SET TinhTrang = ?
+			WHERE MaQuyenSach = ? AND MaCN = ? AND TinhTrang = ?
+		`, step.status, request.MaQuyenSach, request.MaCNSoHuu, previous)
+		if err != nil {
+			return false, fmt.Errorf("failed to update book copy %s: %w", request.MaQuyenSach, err)
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			return false, nil
+		}
+		log.Printf("Book copy %s of site %s moved from %s to %s to match inter-library loan request %s (%s)",
+			request.MaQuyenSach, request.MaCNSoHuu, previous, step.status, request.MaYC, request.TrangThai)
+		return true, nil
+	}
+
+	log.Printf("Inter-library loan request %s is %s but book copy %s of site %s is %s; a librarian must resolve it",
+		request.MaYC, request.TrangThai, request.MaQuyenSach, request.MaCNSoHuu, bookCopy.TinhTrang)
+	return false, nil
+}
+
+// GetRequest retrieves an inter-library loan request by ID
+func (r *ILLRepository) GetRequest(ctx context.Context, maYC string) (*models.YeuCauMuon, error) {
+	// MaYC does not determine the fragment, so every fragment of YEUCAUMUON is searched
+	request, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "YEUCAUMUON",
+		Select:   "SELECT " + illColumns + " FROM YEUCAUMUON",
+		Filters:  []query.Predicate{query.Eq("MaYC", maYC)},
+	}, scanYeuCauMuon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up request %s: %w", maYC, err)
+	}
+	if !found {
+		return nil, fmt.Errorf("inter-library loan request not found: %s", maYC)
+	}
+	return request, nil
+}
+
+// GetRequests retrieves the requests a branch placed or was asked to ship, most recent first,
+// optionally in one state
+func (r *ILLRepository) GetRequests(ctx context.Context, siteID, trangThai string) ([]*models.YeuCauMuon, error) {
+	// Requests to ship are stored at the branches that placed them, so every fragment is searched
+	filters := []query.Predicate{query.Where("(MaCN = ? OR MaCN_SoHuu = ?)", siteID, siteID)}
+	if trangThai != "" {
+		filters = append(filters, query.Eq("TrangThai", trangThai))
+	}
+
+	result, err := query.Merge(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "YEUCAUMUON",
+		Select:   "SELECT " + illColumns + " FROM YEUCAUMUON",
+		Filters:  filters,
+		OrderBy:  "NgayYeuCau DESC, MaYC DESC",
+	}, scanYeuCauMuon, func(a, b *models.YeuCauMuon) bool {
+		if !a.NgayYeuCau.Equal(b.NgayYeuCau) {
+			return a.NgayYeuCau.After(b.NgayYeuCau)
+		}
+		return a.MaYC > b.MaYC
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query inter-library loan requests of site %s: %w", siteID, err)
+	}
+	return result.Rows, nil
+}
+
+// requesterRequest retrieves a request for a step taken by the requesting branch
+func (r *ILLRepository) requesterRequest(ctx context.Context, maYC string, userSite string) (*models.YeuCauMuon, error) {
+	request, err := r.GetRequest(ctx, maYC)
+	if err != nil {
+		return nil, err
+	}
+	if request.MaCN != userSite {
+		return nil, fmt.Errorf("access denied: cannot handle request %s of site %s from site %s", maYC, request.MaCN, userSite)
+	}
+	return request, nil
+}
+
+// freeCopy finds a free copy of a title at any branch matching the filters
+func (r *ILLRepository) freeCopy(ctx context.Context, isbn string, filters ...query.Predicate) (*models.QuyenSach, bool) {
+	bookCopy, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "QUYENSACH",
+		Select:   "SELECT MaQuyenSach, ISBN, MaCN, TinhTrang FROM QUYENSACH",
+		Filters:  append([]query.Predicate{query.Eq("ISBN", isbn), query.Eq("TinhTrang", models.CopyAvailable)}, filters...),
+	}, r.ScanQuyenSach)
+	if err != nil {
+		log.Printf("Failed to look up free copies of %s: %v", isbn, err)
+	}
+	return bookCopy, found
+}
+
+// advance takes one step of a request: the copy moves from copyFrom to copyTo in its owner's
+// QUYENSACH fragment and the request from one state to the next in the requesting branch's
+// YEUCAUMUON fragment, each only from its expected state. then, if given, runs in the
+// requesting branch's transaction; the branch's PHIEUMUON and PHAT fragments are stored with
+// its YEUCAUMUON fragment. On separate databases the request commits before the copy, except
+// on completion, so that ReconcileRequests can finish a step only one side committed.
+func (r *ILLRepository) advance(ctx context.Context, request *models.YeuCauMuon, from, to string, copyFrom, copyTo models.CopyStatus, then func(tx *sql.Tx) error) error {
+	if request.TrangThai != from {
+		return fmt.Errorf("request %s is not %s (status: %s)", request.MaYC, from, request.TrangThai)
+	}
+
+	copyDB, _, err := r.GetFragmentConnection("QUYENSACH", request.MaCNSoHuu)
+	if err != nil {
+		return fmt.Errorf("failed to connect to site %s: %w", request.MaCNSoHuu, err)
+	}
+	requestDB, _, err := r.GetFragmentConnection("YEUCAUMUON", request.MaCN)
+	if err != nil {
+		return fmt.Errorf("failed to connect to site %s: %w", request.MaCN, err)
+	}
+
+	copyTx, err := copyDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction on site %s: %w", request.MaCNSoHuu, err)
+	}
+	defer copyTx.Rollback()
+
+	// Fragments stored on the same database share one transaction
+	requestTx := copyTx
+	if requestDB != copyDB {
+		requestTx, err = requestDB.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction on site %s: %w", request.MaCN, err)
+		}
+		defer requestTx.Rollback()
+	}
+
+	result, err := copyTx.ExecContext(ctx, `
+		UPDATE QUYENSACH
+		SET TinhTrang = ?
+		WHERE MaQuyenSach = ? AND MaCN = ? AND ISBN = ? AND TinhTrang = ?
+	`, copyTo, request.MaQuyenSach, request.MaCNSoHuu, request.ISBN, copyFrom)
+	if err != nil {
+		return fmt.Errorf("failed to update book copy %s: %w", request.MaQuyenSach, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("book copy %s of %s at site %s is not %s", request.MaQuyenSach, request.ISBN, request.MaCNSoHuu, copyFrom)
+	}
+
+	now := time.Now()
+	result, err = requestTx.ExecContext(ctx, `
+		UPDATE YEUCAUMUON
+		SET TrangThai = ?, MaQuyenSach = ?, NgayCapNhat = ?
+		WHERE MaYC = ? AND TrangThai = ?
+	`, to, request.MaQuyenSach, now, request.MaYC, from)
+	if err != nil {
+		return fmt.Errorf("failed to update request %s: %w", request.MaYC, err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return fmt.Errorf("request %s is no longer %s", request.MaYC, from)
+	}
+
+	if then != nil {
+		if err := then(requestTx); err != nil {
+			return err
+		}
+	}
+
+	commitRequest := func() error {
+		if requestTx == copyTx {
+			return nil
+		}
+		if err := requestTx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit request on site %s: %w", request.MaCN, err)
+		}
+		return nil
+	}
+	commitCopy := func() error {
+		if err := copyTx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit book copy on site %s: %w", request.MaCNSoHuu, err)
+		}
+		return nil
+	}
+	// A completed request no longer follows its copy, which may be lent again, so the
+	// copy's side commits first and a returning request is completed from it
+	commits := []func() error{commitRequest, commitCopy}
+	if to == models.ILLCompleted {
+		commits = []func() error{commitCopy, commitRequest}
+	}
+	for _, commit := range commits {
+		if err := commit(); err != nil {
+			return err
+		}
+	}
+
+	request.TrangThai = to
+	request.NgayCapNhat = now
+	return nil
+}
+
+// scanYeuCauMuon scans a row of illColumns into a YeuCauMuon model with null handling
+func scanYeuCauMuon(rows *sql.Rows) (*models.YeuCauMuon, error) {
+	var request models.YeuCauMuon
+	var maQuyenSach sql.NullString
+
+	err := rows.Scan(&request.MaYC, &request.MaDG, &request.ISBN, &request.MaCN, &request.MaCNSoHuu,
+		&maQuyenSach, &request.TrangThai, &request.NgayYeuCau, &request.NgayCapNhat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan YeuCauMuon: %w", err)
+	}
+
+	request.MaQuyenSach = maQuyenSach.String
+	return &request, nil
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
+	"sort"
+	"strings"
+)
+
+// LoanCounts are the loans of a reader not yet returned, at every branch
+type LoanCounts struct {
+	Active  int
+	Overdue int // Kept past their due date
+}
+
+// ReaderLoans counts the loans of a reader across every PHIEUMUON fragment. Limits must
+// not be enforced on a partial count, so a site that cannot be reached is an error.
+func (r *BaseRepository) ReaderLoans(ctx context.Context, maDG string) (LoanCounts, error) {
+	var total LoanCounts
+	result, err := query.Union(ctx, r.Executor(""), query.Query{
+		Relation: "PHIEUMUON",
+		Select: `SELECT COUNT(*),
+			ISNULL(SUM(CASE WHEN DATEDIFF(day, HanTra, GETDATE()) > 0 THEN 1 ELSE 0 END), 0)
+			FROM PHIEUMUON`,
+		Filters: []query.Predicate{
+			query.Eq("MaDG", maDG),
+			query.Where("NgayTra IS NULL"),
+		},
+	}, func(rows *sql.Rows) (LoanCounts, error) {
+		var c LoanCounts
+		err := rows.Scan(&c.Active, &c.Overdue)
+		return c, err
+	})
+	if err != nil {
+		return total, fmt.Errorf("failed to count loans of reader %s: %w", maDG, err)
+	}
+	if len(result.Failed) > 0 {
+		return total, fmt.Errorf("cannot count loans of reader %s: sites %s unavailable", maDG, failedSites(result.Failed))
+	}
+
+	for _, c := range result.Rows {
+		total.Active += c.Active
+		total.Overdue += c.Overdue
+	}
+	return total, nil
+}
+
+// failedSites lists the sites of a fan-out that could not be queried, for error messages
+func failedSites(failed map[string]error) string {
+	sites := make([]string, 0, len(failed))
+	for siteID := range failed {
+		sites = append(sites, siteID)
+	}
+	sort.Strings(sites)
+	return strings.Join(sites, ", ")
+}
+
+// homeReader finds a reader in the DOCGIA fragment of their registration branch
+func (r *BaseRepository) homeReader(ctx context.Context, maDG string) (*models.DocGia, error) {
+	// MaDG does not determine the fragment, so every fragment of DOCGIA is searched
+	reader, found, err := query.First(ctx, r.Executor(""), query.Query{
+		Relation: "DOCGIA",
+		Select:   "SELECT MaDG, HoTen, MaCN_DangKy, LoaiDG FROM DOCGIA",
+		Filters:  []query.Predicate{query.Eq("MaDG", maDG)},
+	}, r.ScanDocGia)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up reader %s: %w", maDG, err)
+	}
+	if !found {
+		return nil, fmt.Errorf("reader not found: %s", maDG)
+	}
+	return reader, nil
+}
+
+// lockReader locks a reader's row in their home DOCGIA fragment until tx ends. Every loan
+// of the reader takes this lock first, whichever branch lends, so the loans counted across
+// branches cannot change between the limit check and the insert.
+func lockReader(ctx context.Context, tx *sql.Tx, maDG string) error {
+	var locked string
+	err := tx.QueryRowContext(ctx, "SELECT MaDG FROM DOCGIA WITH (UPDLOCK, ROWLOCK) WHERE MaDG = ?", maDG).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("reader not found: %s", maDG)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock reader %s: %w", maDG, err)
+	}
+	return nil
+}
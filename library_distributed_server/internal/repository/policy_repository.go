@@ -0,0 +1,326 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"library_distributed_server/internal/config"
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PolicyRepository manages the circulation policies, replicated to every site
+type PolicyRepository struct {
+	*BaseRepository
+	siteID string // Current site for this repository instance
+}
+
+// PolicyRepositoryInterface defines circulation policy operations
+type PolicyRepositoryInterface interface {
+	GetPolicies(ctx context.Context) ([]*models.ChinhSach, error)
+	GetPolicy(ctx context.Context, maCS string) (*models.ChinhSach, error)
+	GetEffectivePolicy(ctx context.Context, maCN, loaiDG string) (*models.ChinhSach, error)
+
+	// Replicated writes (Manager only)
+	CreatePolicy(ctx context.Context, policy *models.ChinhSach) error
+	UpdatePolicy(ctx context.Context, policy *models.ChinhSach) error
+	DeletePolicy(ctx context.Context, maCS string) error
+}
+
+// NewPolicyRepository creates a new circulation policy repository
+func NewPolicyRepository(store *config.Store, siteID string) PolicyRepositoryInterface {
+	return &PolicyRepository{
+		BaseRepository: NewBaseRepository(store),
+		siteID:         siteID,
+	}
+}
+
+const policyColumns = "MaCS, MaCN, LoaiDG, SoNgayMuon, SoSachToiDa, SoLanGiaHan, MucPhat, SoNgayAnHan"
+
+// GetPolicies lists the policies of the local CHINHSACH replica, general policies first
+func (r *PolicyRepository) GetPolicies(ctx context.Context) ([]*models.ChinhSach, error) {
+	policies, err := r.circulationPolicies(ctx, r.siteID)
+	if err != nil {
+		return nil, err
+	}
+	return policies.policies, nil
+}
+
+// GetPolicy retrieves a policy from the local CHINHSACH replica
+func (r *PolicyRepository) GetPolicy(ctx context.Context, maCS string) (*models.ChinhSach, error) {
+	policy, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "CHINHSACH",
+		Select:   "SELECT " + policyColumns + " FROM CHINHSACH",
+		Filters:  []query.Predicate{query.Eq("MaCS", maCS)},
+	}, scanChinhSach)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy %s: %w", maCS, err)
+	}
+	if !found {
+		return nil, fmt.Errorf("policy not found: %s", maCS)
+	}
+	return policy, nil
+}
+
+// GetEffectivePolicy returns the policy applying to loans of a branch to a reader category
+func (r *PolicyRepository) GetEffectivePolicy(ctx context.Context, maCN, loaiDG string) (*models.ChinhSach, error) {
+	if loaiDG == "" {
+		loaiDG = models.DefaultReaderCategory
+	}
+	return r.policyFor(ctx, maCN, loaiDG)
+}
+
+// CreatePolicy adds a policy at every replica of CHINHSACH (Manager only)
+func (r *PolicyRepository) CreatePolicy(ctx context.Context, policy *models.ChinhSach) error {
+	policy.MaCS = newRowID("CS")
+	err := r.replicatePolicy(ctx, policy.MaCS, func(tx *sql.Tx, siteID string) error {
+		// A policy for the same branch and category would make the applicable one ambiguous
+		var count int
+		err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM CHINHSACH
+			WHERE ISNULL(MaCN, '') = ? AND ISNULL(LoaiDG, N'') = ?
+		`, policy.MaCN, policy.LoaiDG).Scan(&count)
+		if err != nil {
+			return fmt.Errorf("failed to check policies in site %s: %w", siteID, err)
+		}
+		if count > 0 {
+			return fmt.Errorf("a policy for branch %q and reader category %q already exists", policy.MaCN, policy.LoaiDG)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO CHINHSACH (`+policyColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, policy.MaCS, nullString(policy.MaCN), nullString(policy.LoaiDG), policy.SoNgayMuon,
+			policy.SoSachToiDa, policy.SoLanGiaHan, policy.MucPhat, policy.SoNgayAnHan)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Policy %s for branch %q and reader category %q created across all sites",
+		policy.MaCS, policy.MaCN, policy.LoaiDG)
+	return nil
+}
+
+// UpdatePolicy changes the limits of a policy at every replica of CHINHSACH (Manager only).
+// The branch and category a policy applies to cannot change.
+func (r *PolicyRepository) UpdatePolicy(ctx context.Context, policy *models.ChinhSach) error {
+	err := r.replicatePolicy(ctx, policy.MaCS, func(tx *sql.Tx, siteID string) error {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE CHINHSACH
+			SET SoNgayMuon = ?, SoSachToiDa = ?, SoLanGiaHan = ?, MucPhat = ?, SoNgayAnHan = ?
+			WHERE MaCS = ?
+		`, policy.SoNgayMuon, policy.SoSachToiDa, policy.SoLanGiaHan, policy.MucPhat, policy.SoNgayAnHan, policy.MaCS)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+			return fmt.Errorf("policy %s not found in site %s", policy.MaCS, siteID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Policy %s updated across all sites", policy.MaCS)
+	return nil
+}
+
+// DeletePolicy removes a policy from every replica of CHINHSACH (Manager only).
+// Loans it applied to fall back on the next most specific policy.
+func (r *PolicyRepository) DeletePolicy(ctx context.Context, maCS string) error {
+	err := r.replicatePolicy(ctx, maCS, func(tx *sql.Tx, siteID string) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM CHINHSACH WHERE MaCS = ?", maCS)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Policy %s deleted across all sites", maCS)
+	return nil
+}
+
+// replicatePolicy applies a write to every replica of CHINHSACH. The write runs in a
+// serializable transaction at each site, and the transactions are committed one site at a
+// time only once all of them succeeded. A commit failing after others went through leaves
+// the replicas apart; the error and the log name the sites that committed so an operator
+// can bring the others in line.
+func (r *PolicyRepository) replicatePolicy(ctx context.Context, maCS string, write func(tx *sql.Tx, siteID string) error) error {
+	connections, err := r.GetRelationConnections("CHINHSACH")
+	if err != nil {
+		return fmt.Errorf("failed to get site connections: %w", err)
+	}
+	sites := make([]string, 0, len(connections))
+	for siteID := range connections {
+		sites = append(sites, siteID)
+	}
+	sort.Strings(sites)
+
+	transactions := make(map[string]*sql.Tx, len(sites))
+	defer func() {
+		// Rollback any open transactions if something fails
+		for _, tx := range transactions {
+			tx.Rollback()
+		}
+	}()
+
+	for _, siteID := range sites {
+		tx, err := connections[siteID].BeginTx(ctx, &sql.TxOptions{
+			Isolation: sql.LevelSerializable,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction for site %s: %w", siteID, err)
+		}
+		transactions[siteID] = tx
+
+		if err := write(tx, siteID); err != nil {
+			return fmt.Errorf("failed to write policy %s in site %s: %w", maCS, siteID, err)
+		}
+	}
+
+	var committed []string
+	for _, siteID := range sites {
+		if err := transactions[siteID].Commit(); err != nil {
+			delete(transactions, siteID)
+			if len(committed) == 0 {
+				return fmt.Errorf("failed to commit policy %s in site %s: %w", maCS, siteID, err)
+			}
+			log.Printf("Policy %s committed in sites %s but not in site %s; replicas of CHINHSACH differ until repaired",
+				maCS, strings.Join(committed, ", "), siteID)
+			return fmt.Errorf("failed to commit policy %s in site %s after it committed in sites %s: %w",
+				maCS, siteID, strings.Join(committed, ", "), err)
+		}
+		delete(transactions, siteID)
+		committed = append(committed, siteID)
+	}
+	return nil
+}
+
+// policySet is the content of a CHINHSACH replica together with the configured defaults
+type policySet struct {
+	policies []*models.ChinhSach
+	loans    config.LoanConfig
+	fines    config.FineConfig
+}
+
+// circulationPolicies reads every policy, preferably from the replica at local
+func (r *BaseRepository) circulationPolicies(ctx context.Context, local string) (policySet, error) {
+	cfg := r.config()
+	set := policySet{loans: cfg.Loans, fines: cfg.Fines}
+
+	result, err := query.Union(ctx, r.Executor(local), query.Query{
+		Relation: "CHINHSACH",
+		Select:   "SELECT " + policyColumns + " FROM CHINHSACH",
+		OrderBy:  "CASE WHEN MaCN IS NULL THEN 0 ELSE 1 END, MaCN, CASE WHEN LoaiDG IS NULL THEN 0 ELSE 1 END, LoaiDG",
+	}, scanChinhSach)
+	if err != nil {
+		return set, fmt.Errorf("failed to read circulation policies: %w", err)
+	}
+	if len(result.Failed) > 0 {
+		return set, fmt.Errorf("cannot read circulation policies: sites %s unavailable", failedSites(result.Failed))
+	}
+	set.policies = result.Rows
+	return set, nil
+}
+
+// policyFor returns the policy applying to loans of a branch to a reader category
+func (r *BaseRepository) policyFor(ctx context.Context, maCN, loaiDG string) (*models.ChinhSach, error) {
+	set, err := r.circulationPolicies(ctx, maCN)
+	if err != nil {
+		return nil, err
+	}
+	return set.resolve(maCN, loaiDG), nil
+}
+
+// loanPolicy returns the policy a loan is made under: that of its branch and its reader's category
+func (r *BaseRepository) loanPolicy(ctx context.Context, loan *models.PhieuMuon) (*models.ChinhSach, error) {
+	reader, err := r.homeReader(ctx, loan.MaDG)
+	if err != nil {
+		return nil, err
+	}
+	return r.policyFor(ctx, loan.MaCN, reader.LoaiDG)
+}
+
+// resolve picks the most specific policy matching a branch and reader category: the policy of
+// both, then of the branch, then of the category, then of every branch and category. Where
+// none matches, the configured defaults apply.
+func (s policySet) resolve(maCN, loaiDG string) *models.ChinhSach {
+	var best *models.ChinhSach
+	bestRank := -1
+	for _, p := range s.policies {
+		if (p.MaCN != "" && p.MaCN != maCN) || (p.LoaiDG != "" && p.LoaiDG != loaiDG) {
+			continue
+		}
+		rank := 0
+		if p.MaCN != "" {
+			rank += 2
+		}
+		if p.LoaiDG != "" {
+			rank++
+		}
+		if rank > bestRank {
+			best, bestRank = p, rank
+		}
+	}
+	if best != nil {
+		return best
+	}
+
+	days := int(s.loans.Period / (24 * time.Hour))
+	if days < 1 {
+		days = 1
+	}
+	return &models.ChinhSach{
+		MaCS:        models.DefaultPolicyID,
+		SoNgayMuon:  days,
+		SoSachToiDa: s.loans.MaxItems,
+		SoLanGiaHan: s.loans.MaxRenewals,
+		MucPhat:     s.fines.RateFor(maCN),
+		SoNgayAnHan: s.fines.GraceDays,
+	}
+}
+
+// loanPeriod is the time from a loan, or a renewal, to the due date under a policy
+func loanPeriod(policy *models.ChinhSach) time.Duration {
+	return time.Duration(policy.SoNgayMuon) * 24 * time.Hour
+}
+
+// chargedDays are the days overdue a policy charges for, after its grace days
+func chargedDays(policy *models.ChinhSach, daysOverdue int) int {
+	if daysOverdue <= policy.SoNgayAnHan {
+		return 0
+	}
+	return daysOverdue - policy.SoNgayAnHan
+}
+
+// violation reports a rule of a policy that blocks an action
+func violation(maCS, rule string, limit, actual int, format string, args ...interface{}) *models.PolicyViolation {
+	return &models.PolicyViolation{
+		Rule:   rule,
+		MaCS:   maCS,
+		Limit:  limit,
+		Actual: actual,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+// scanChinhSach scans a row of policyColumns
+func scanChinhSach(rows *sql.Rows) (*models.ChinhSach, error) {
+	var policy models.ChinhSach
+	var maCN, loaiDG sql.NullString
+	err := rows.Scan(&policy.MaCS, &maCN, &loaiDG, &policy.SoNgayMuon, &policy.SoSachToiDa,
+		&policy.SoLanGiaHan, &policy.MucPhat, &policy.SoNgayAnHan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan ChinhSach: %w", err)
+	}
+	policy.MaCN = maCN.String
+	policy.LoaiDG = loaiDG.String
+	return &policy, nil
+}
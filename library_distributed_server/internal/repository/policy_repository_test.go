@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"library_distributed_server/internal/config"
+	"library_distributed_server/internal/models"
+	"testing"
+	"time"
+)
+
+func TestPolicySetResolve(t *testing.T) {
+	set := policySet{
+		policies: []*models.ChinhSach{
+			{MaCS: "CS-ALL"},
+			{MaCS: "CS-SV", LoaiDG: "Sinh viên"},
+			{MaCS: "CS-Q1", MaCN: "Q1"},
+			{MaCS: "CS-Q1-SV", MaCN: "Q1", LoaiDG: "Sinh viên"},
+			{MaCS: "CS-Q3-GV", MaCN: "Q3", LoaiDG: "Giảng viên"},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		maCN   string
+		loaiDG string
+		want   string
+	}{
+		{"branch and category", "Q1", "Sinh viên", "CS-Q1-SV"},
+		{"branch over category", "Q1", "Giảng viên", "CS-Q1"},
+		{"category", "Q3", "Sinh viên", "CS-SV"},
+		{"other category of the branch", "Q3", models.DefaultReaderCategory, "CS-ALL"},
+		{"branch and category elsewhere", "Q3", "Giảng viên", "CS-Q3-GV"},
+		{"every branch and category", "Q5", models.DefaultReaderCategory, "CS-ALL"},
+	}
+
+	for _, tt := range tests {
+		if got := set.resolve(tt.maCN, tt.loaiDG); got.MaCS != tt.want {
+			t.Errorf("%s: resolve(%s, %s) = %s, want %s", tt.name, tt.maCN, tt.loaiDG, got.MaCS, tt.want)
+		}
+	}
+}
+
+func TestPolicySetResolveDefaults(t *testing.T) {
+	set := policySet{
+		policies: []*models.ChinhSach{{MaCS: "CS-Q1", MaCN: "Q1"}},
+		loans:    config.LoanConfig{Period: 14 * 24 * time.Hour, MaxItems: 5, MaxRenewals: 2},
+		fines:    config.FineConfig{DailyRate: 5000, BranchRates: map[string]int{"Q3": 3000}, GraceDays: 1},
+	}
+
+	tests := []struct {
+		maCN string
+		want models.ChinhSach
+	}{
+		{"Q3", models.ChinhSach{MaCS: models.DefaultPolicyID, SoNgayMuon: 14, SoSachToiDa: 5, SoLanGiaHan: 2, MucPhat: 3000, SoNgayAnHan: 1}},
+		{"Q5", models.ChinhSach{MaCS: models.DefaultPolicyID, SoNgayMuon: 14, SoSachToiDa: 5, SoLanGiaHan: 2, MucPhat: 5000, SoNgayAnHan: 1}},
+	}
+
+	for _, tt := range tests {
+		if got := set.resolve(tt.maCN, models.DefaultReaderCategory); *got != tt.want {
+			t.Errorf("resolve(%s) = %+v, want %+v", tt.maCN, *got, tt.want)
+		}
+	}
+
+	set.loans.Period = time.Hour
+	if got := set.resolve("Q5", models.DefaultReaderCategory).SoNgayMuon; got != 1 {
+		t.Errorf("a loan period under a day resolves to %d days, want 1", got)
+	}
+}
+
+func TestChargedDays(t *testing.T) {
+	tests := []struct {
+		grace       int
+		daysOverdue int
+		want        int
+	}{
+		{0, 0, 0},
+		{0, 3, 3},
+		{2, 1, 0},
+		{2, 2, 0},
+		{2, 3, 1},
+		{2, 10, 8},
+		{0, -1, 0},
+	}
+
+	for _, tt := range tests {
+		policy := &models.ChinhSach{SoNgayAnHan: tt.grace}
+		if got := chargedDays(policy, tt.daysOverdue); got != tt.want {
+			t.Errorf("chargedDays(grace %d, %d days overdue) = %d, want %d", tt.grace, tt.daysOverdue, got, tt.want)
+		}
+	}
+}
@@ -3,17 +3,28 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"library_distributed_server/internal/cache"
 	"library_distributed_server/internal/config"
 	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
+	"library_distributed_server/internal/search"
 	"library_distributed_server/pkg/utils"
 	"log"
+	"math"
+	"sort"
 )
 
+// defaultReaderMatchScore is the least similarity of a reader found by SearchReaders, letting
+// through one typo in a five-letter word
+const defaultReaderMatchScore = 0.8
+
 // ReaderRepository handles reader operations using raw SQL queries
 type ReaderRepository struct {
 	*BaseRepository
-	siteID string // Current site for this repository instance
+	siteID string       // Current site for this repository instance
+	cache  *cache.Cache // CHINHANH rows
 }
 
 // ReaderRepositoryInterface defines reader-related operations with raw SQL
@@ -26,20 +37,36 @@ type ReaderRepositoryInterface interface {
 	GetReadersBySite(ctx context.Context, siteID string, pagination *utils.PaginationParams) ([]*models.DocGia, int, error)
 
 	// Distributed operations (manager-only)
-	GetAllReaders(ctx context.Context, pagination *utils.PaginationParams) ([]*models.DocGia, int, error)
-	SearchReaders(ctx context.Context, query string, pagination *utils.PaginationParams) ([]*models.DocGia, int, error)
+	GetAllReaders(ctx context.Context, pagination *utils.PaginationParams) (*query.Page[*models.DocGia], error)
+	ExplainGetAllReaders(ctx context.Context, pagination *utils.PaginationParams) (*query.Explanation, error)
+	SearchReaders(ctx context.Context, searchText string, minScore float64, pagination *utils.PaginationParams) (*models.ReaderSearchResult, error)
 	GetReaderWithStats(ctx context.Context, maDG string) (*models.ReaderWithStats, error)
 	GetReadersWithStats(ctx context.Context, siteID string) ([]*models.ReaderWithStats, error)
 }
 
 // NewReaderRepository creates a new reader repository with raw SQL
-func NewReaderRepository(config *config.Config, siteID string) ReaderRepositoryInterface {
+func NewReaderRepository(store *config.Store, siteID string, refCache *cache.Cache) ReaderRepositoryInterface {
 	return &ReaderRepository{
-		BaseRepository: NewBaseRepository(config),
+		BaseRepository: NewBaseRepository(store),
 		siteID:         siteID,
+		cache:          refCache,
 	}
 }
 
+// getBranch reads a CHINHANH row from the given replica through the site cache.
+// A missing branch is reported as sql.ErrNoRows and is not cached.
+func (r *ReaderRepository) getBranch(ctx context.Context, db *sql.DB, maCN string) (*models.ChiNhanh, error) {
+	return cache.Row(r.cache, "CHINHANH", maCN, func() (*models.ChiNhanh, error) {
+		var branch models.ChiNhanh
+		err := db.QueryRowContext(ctx, "SELECT MaCN, TenCN, DiaChi FROM CHINHANH WHERE MaCN = ?", maCN).
+			Scan(&branch.MaCN, &branch.TenCN, &branch.DiaChi)
+		if err != nil {
+			return nil, err
+		}
+		return &branch, nil
+	})
+}
+
 // CreateReader creates a new reader with fragmentation validation (FR8)
 func (r *ReaderRepository) CreateReader(ctx context.Context, reader *models.DocGia, userSite string) error {
 	// Authorization: only allow creation in user's site
@@ -47,7 +74,7 @@ func (r *ReaderRepository) CreateReader(ctx context.Context, reader *models.DocG
 		return fmt.Errorf("access denied: cannot create reader in site %s from site %s", reader.MaCNDangKy, userSite)
 	}
 
-	db, err := r.GetConnection(reader.MaCNDangKy)
+	db, fragmentSite, err := r.GetFragmentConnection("DOCGIA", reader.MaCNDangKy)
 	if err != nil {
 		return fmt.Errorf("failed to connect to site %s: %w", reader.MaCNDangKy, err)
 	}
@@ -56,7 +83,7 @@ func (r *ReaderRepository) CreateReader(ctx context.Context, reader *models.DocG
 	data := map[string]interface{}{
 		"MaCN_DangKy": reader.MaCNDangKy,
 	}
-	if err := r.ValidateFragmentation(ctx, "DOCGIA", data, reader.MaCNDangKy); err != nil {
+	if err := r.ValidateFragmentation(ctx, "DOCGIA", data, fragmentSite); err != nil {
 		return fmt.Errorf("fragmentation validation failed: %w", err)
 	}
 
@@ -72,24 +99,24 @@ func (r *ReaderRepository) CreateReader(ctx context.Context, reader *models.DocG
 	}
 
 	// Validate that the branch exists
-	exists, err = r.CheckRecordExists(ctx, db, "CHINHANH", map[string]interface{}{
-		"MaCN": reader.MaCNDangKy,
-	})
-	if err != nil {
+	if _, err := r.getBranch(ctx, db, reader.MaCNDangKy); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("branch %s does not exist", reader.MaCNDangKy)
+		}
 		return fmt.Errorf("failed to validate branch: %w", err)
 	}
-	if !exists {
-		return fmt.Errorf("branch %s does not exist", reader.MaCNDangKy)
-	}
 
 	// Execute insert within transaction
 	return r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
+		if reader.LoaiDG == "" {
+			reader.LoaiDG = models.DefaultReaderCategory
+		}
 		query := `
-			INSERT INTO DOCGIA (MaDG, HoTen, MaCN_DangKy)
-			VALUES (?, ?, ?)
+			INSERT INTO DOCGIA (MaDG, HoTen, MaCN_DangKy, LoaiDG)
+			VALUES (?, ?, ?, ?)
 		`
 
-		_, err := tx.ExecContext(ctx, query, reader.MaDG, reader.HoTen, reader.MaCNDangKy)
+		_, err := tx.ExecContext(ctx, query, reader.MaDG, reader.HoTen, reader.MaCNDangKy, reader.LoaiDG)
 		if err != nil {
 			return fmt.Errorf("failed to insert reader: %w", err)
 		}
@@ -101,34 +128,22 @@ func (r *ReaderRepository) CreateReader(ctx context.Context, reader *models.DocG
 
 // GetReaderByID retrieves a reader by ID from the appropriate site
 func (r *ReaderRepository) GetReaderByID(ctx context.Context, maDG string) (*models.DocGia, error) {
-	// Try to find reader in all sites since we don't know which site they belong to
-	connections, err := r.GetAllSiteConnections()
+	// MaDG does not determine the fragment, so every fragment of DOCGIA is searched
+	reader, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "DOCGIA",
+		Alias:    "d",
+		Select:   "SELECT d.MaDG, d.HoTen, d.MaCN_DangKy, d.LoaiDG FROM DOCGIA d",
+		Filters:  []query.Predicate{query.Eq("d.MaDG", maDG)},
+	}, r.ScanDocGia)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get site connections: %w", err)
+		return nil, fmt.Errorf("failed to look up reader %s: %w", maDG, err)
 	}
-
-	query := `
-		SELECT d.MaDG, d.HoTen, d.MaCN_DangKy
-		FROM DOCGIA d
-		WHERE d.MaDG = ?
-	`
-
-	for siteID, db := range connections {
-		var reader models.DocGia
-		err := db.QueryRowContext(ctx, query, maDG).Scan(
-			&reader.MaDG, &reader.HoTen, &reader.MaCNDangKy)
-
-		if err == nil {
-			log.Printf("Reader %s found in site %s", maDG, siteID)
-			return &reader, nil
-		}
-
-		if err != sql.ErrNoRows {
-			log.Printf("Error querying reader in site %s: %v", siteID, err)
-		}
+	if !found {
+		return nil, fmt.Errorf("reader not found: %s", maDG)
 	}
 
-	return nil, fmt.Errorf("reader not found: %s", maDG)
+	log.Printf("Reader %s found in site %s", maDG, reader.MaCNDangKy)
+	return reader, nil
 }
 
 // UpdateReader updates reader information with authorization check
@@ -146,20 +161,25 @@ func (r *ReaderRepository) UpdateReader(ctx context.Context, reader *models.DocG
 	}
 
 	// Get connection to the appropriate site
-	db, err := r.GetConnection(existingReader.MaCNDangKy)
+	db, _, err := r.GetFragmentConnection("DOCGIA", existingReader.MaCNDangKy)
 	if err != nil {
 		return fmt.Errorf("failed to connect to site %s: %w", existingReader.MaCNDangKy, err)
 	}
 
+	// A reader updated without a category keeps theirs
+	if reader.LoaiDG == "" {
+		reader.LoaiDG = existingReader.LoaiDG
+	}
+
 	// Execute update within transaction
 	return r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
 		query := `
 			UPDATE DOCGIA 
-			SET HoTen = ?
+			SET HoTen = ?, LoaiDG = ?
 			WHERE MaDG = ? AND MaCN_DangKy = ?
 		`
 
-		result, err := tx.ExecContext(ctx, query, reader.HoTen, reader.MaDG, existingReader.MaCNDangKy)
+		result, err := tx.ExecContext(ctx, query, reader.HoTen, reader.LoaiDG, reader.MaDG, existingReader.MaCNDangKy)
 		if err != nil {
 			return fmt.Errorf("failed to update reader: %w", err)
 		}
@@ -193,27 +213,24 @@ func (r *ReaderRepository) DeleteReader(ctx context.Context, maDG string, userSi
 	}
 
 	// Get connection to the appropriate site
-	db, err := r.GetConnection(existingReader.MaCNDangKy)
+	db, _, err := r.GetFragmentConnection("DOCGIA", existingReader.MaCNDangKy)
 	if err != nil {
 		return fmt.Errorf("failed to connect to site %s: %w", existingReader.MaCNDangKy, err)
 	}
 
 	// Execute deletion within transaction with constraint checking
 	return r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
-		// Check if reader has active borrows
-		var activeBorrows int
-		err := tx.QueryRowContext(ctx, `
-			SELECT COUNT(*) 
-			FROM PHIEUMUON 
-			WHERE MaDG = ? AND NgayTra IS NULL
-		`, maDG).Scan(&activeBorrows)
-
+		// Check if reader has active borrows at any branch; the lock keeps new loans out
+		if err := lockReader(ctx, tx, maDG); err != nil {
+			return err
+		}
+		loans, err := r.ReaderLoans(ctx, maDG)
 		if err != nil {
 			return fmt.Errorf("failed to check active borrows: %w", err)
 		}
 
-		if activeBorrows > 0 {
-			return fmt.Errorf("cannot delete reader %s: has %d active borrows", maDG, activeBorrows)
+		if loans.Active > 0 {
+			return fmt.Errorf("cannot delete reader %s: has %d active borrows", maDG, loans.Active)
 		}
 
 		// Delete the reader
@@ -243,7 +260,7 @@ func (r *ReaderRepository) DeleteReader(ctx context.Context, maDG string, userSi
 
 // GetReadersBySite retrieves all readers for a specific site with pagination
 func (r *ReaderRepository) GetReadersBySite(ctx context.Context, siteID string, pagination *utils.PaginationParams) ([]*models.DocGia, int, error) {
-	db, err := r.GetConnection(siteID)
+	db, _, err := r.GetFragmentConnection("DOCGIA", siteID)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
@@ -261,7 +278,7 @@ func (r *ReaderRepository) GetReadersBySite(ctx context.Context, siteID string,
 
 	// Get paginated data
 	baseQuery := `
-		SELECT d.MaDG, d.HoTen, d.MaCN_DangKy
+		SELECT d.MaDG, d.HoTen, d.MaCN_DangKy, d.LoaiDG
 		FROM DOCGIA d
 		WHERE d.MaCN_DangKy = ?
 	`
@@ -284,142 +301,116 @@ func (r *ReaderRepository) GetReadersBySite(ctx context.Context, siteID string,
 	return readers, totalCount, nil
 }
 
-// GetAllReaders retrieves readers from all sites (Manager only)
-func (r *ReaderRepository) GetAllReaders(ctx context.Context, pagination *utils.PaginationParams) ([]*models.DocGia, int, error) {
-	connections, err := r.GetAllSiteConnections()
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get site connections: %w", err)
+// allReadersQuery lists readers of every branch; allReadersOrder is their global order
+var (
+	allReadersQuery = query.Query{
+		Relation: "DOCGIA",
+		Alias:    "d",
+		Select:   "SELECT d.MaDG, d.HoTen, d.MaCN_DangKy, d.LoaiDG FROM DOCGIA d",
 	}
+	allReadersOrder = []query.SortKey{{Column: "d.MaDG"}}
+)
 
-	var allReaders []*models.DocGia
-	totalCount := 0
-
-	for siteID, db := range connections {
-		// Get count from this site
-		countQuery := `SELECT COUNT(*) FROM DOCGIA WHERE MaCN_DangKy = ?`
-		siteCount, err := r.GetTotalCount(ctx, db, countQuery, []interface{}{siteID})
-		if err != nil {
-			log.Printf("Error getting count from site %s: %v", siteID, err)
-			continue
-		}
-		totalCount += siteCount
-
-		// Get readers from this site
-		query := `
-			SELECT d.MaDG, d.HoTen, d.MaCN_DangKy
-			FROM DOCGIA d
-			WHERE d.MaCN_DangKy = ?
-			ORDER BY d.MaDG
-		`
-
-		rows, err := db.QueryContext(ctx, query, siteID)
-		if err != nil {
-			log.Printf("Error querying readers from site %s: %v", siteID, err)
-			continue
-		}
-		defer rows.Close()
-
-		for rows.Next() {
-			reader, err := r.ScanDocGia(rows)
-			if err != nil {
-				log.Printf("Error scanning reader from site %s: %v", siteID, err)
-				continue
-			}
-			allReaders = append(allReaders, reader)
-		}
+// GetAllReaders retrieves readers from all sites ordered by MaDG (Manager only)
+func (r *ReaderRepository) GetAllReaders(ctx context.Context, pagination *utils.PaginationParams) (*query.Page[*models.DocGia], error) {
+	page, err := query.Paginate(ctx, r.Executor(r.siteID), allReadersQuery, allReadersOrder, r.ScanDocGia,
+		func(reader *models.DocGia) []interface{} {
+			return []interface{}{reader.MaDG}
+		}, pageRequest(pagination))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get readers: %w", err)
 	}
+	return page, nil
+}
 
-	// Apply pagination to combined results
-	if pagination != nil {
-		offset := pagination.CalculateOffset()
-		end := offset + pagination.Size
-
-		if offset >= len(allReaders) {
-			return []*models.DocGia{}, totalCount, nil
-		}
-
-		if end > len(allReaders) {
-			end = len(allReaders)
-		}
-
-		allReaders = allReaders[offset:end]
+// ExplainGetAllReaders describes how GetAllReaders would fetch a page, without running it
+func (r *ReaderRepository) ExplainGetAllReaders(ctx context.Context, pagination *utils.PaginationParams) (*query.Explanation, error) {
+	request := pageRequest(pagination)
+	steps, err := query.ExplainPage(allReadersQuery, allReadersOrder, request)
+	if err != nil {
+		return nil, err
 	}
-
-	return allReaders, totalCount, nil
+	merge := fmt.Sprintf("k-way merge on d.MaDG, skip %d and take %d; sum the per-site counts", request.Offset, request.Size)
+	if request.Cursor != "" {
+		merge = fmt.Sprintf("k-way merge on d.MaDG after the cursor, take %d; sum the per-site counts", request.Size)
+	}
+	return query.Explain(ctx, r.Executor(r.siteID), "All readers", merge, steps...)
 }
 
-// SearchReaders searches for readers across all sites by name
-func (r *ReaderRepository) SearchReaders(ctx context.Context, query string, pagination *utils.PaginationParams) ([]*models.DocGia, int, error) {
-	connections, err := r.GetAllSiteConnections()
+// SearchReaders finds readers at all sites whose name or ID resembles the search text,
+// ignoring diacritics and tolerating typos, to spot duplicates before a registration.
+// Matches scoring at least minScore (defaultReaderMatchScore when 0) are ranked best
+// first, paginated, and grouped by registration branch.
+func (r *ReaderRepository) SearchReaders(ctx context.Context, searchText string, minScore float64, pagination *utils.PaginationParams) (*models.ReaderSearchResult, error) {
+	if minScore <= 0 {
+		minScore = defaultReaderMatchScore
+	}
+
+	// Similarity is computed here, so every reader is shipped to the merge site
+	result, err := query.Union(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "DOCGIA",
+		Alias:    "d",
+		Select:   "SELECT d.MaDG, d.HoTen, d.MaCN_DangKy, d.LoaiDG FROM DOCGIA d",
+	}, r.ScanDocGia)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to get site connections: %w", err)
+		return nil, fmt.Errorf("failed to search readers: %w", err)
 	}
 
-	var allReaders []*models.DocGia
-	totalCount := 0
-
-	searchQuery := `
-		SELECT d.MaDG, d.HoTen, d.MaCN_DangKy
-		FROM DOCGIA d
-		WHERE d.MaCN_DangKy = ? AND (
-			d.MaDG LIKE ? OR 
-			d.HoTen LIKE ?
-		)
-		ORDER BY d.HoTen
-	`
-
-	searchPattern := "%" + query + "%"
-
-	for siteID, db := range connections {
-		// Get count from this site
-		countQuery := `
-			SELECT COUNT(*) 
-			FROM DOCGIA 
-			WHERE MaCN_DangKy = ? AND (MaDG LIKE ? OR HoTen LIKE ?)
-		`
-		siteCount, err := r.GetTotalCount(ctx, db, countQuery,
-			[]interface{}{siteID, searchPattern, searchPattern})
-		if err != nil {
-			log.Printf("Error getting search count from site %s: %v", siteID, err)
-			continue
+	var matches []*models.ReaderMatch
+	for _, reader := range result.Rows {
+		score := max(search.Similarity(searchText, reader.HoTen), search.Similarity(searchText, reader.MaDG))
+		if score >= minScore {
+			matches = append(matches, &models.ReaderMatch{DocGia: *reader, Score: math.Round(score*1000) / 1000})
 		}
-		totalCount += siteCount
-
-		// Get search results from this site
-		rows, err := db.QueryContext(ctx, searchQuery, siteID, searchPattern, searchPattern)
-		if err != nil {
-			log.Printf("Error searching readers in site %s: %v", siteID, err)
-			continue
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
 		}
-		defer rows.Close()
+		return matches[i].MaDG < matches[j].MaDG
+	})
 
-		for rows.Next() {
-			reader, err := r.ScanDocGia(rows)
-			if err != nil {
-				log.Printf("Error scanning search result from site %s: %v", siteID, err)
-				continue
-			}
-			allReaders = append(allReaders, reader)
-		}
-	}
+	totalCount := len(matches)
 
-	// Apply pagination to combined results
+	// Apply pagination to ranked results
 	if pagination != nil {
 		offset := pagination.CalculateOffset()
 		end := offset + pagination.Size
 
-		if offset >= len(allReaders) {
-			return []*models.DocGia{}, totalCount, nil
+		if offset >= len(matches) {
+			matches = nil
+		} else {
+			if end > len(matches) {
+				end = len(matches)
+			}
+			matches = matches[offset:end]
 		}
+	}
 
-		if end > len(allReaders) {
-			end = len(allReaders)
+	db, _, err := r.GetFragmentConnection("CHINHANH", r.siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to site %s: %w", r.siteID, err)
+	}
+
+	searchResult := &models.ReaderSearchResult{Total: totalCount, Groups: []*models.ReaderMatchGroup{}}
+	groups := make(map[string]*models.ReaderMatchGroup)
+	for _, match := range matches {
+		group, exists := groups[match.MaCNDangKy]
+		if !exists {
+			group = &models.ReaderMatchGroup{MaCN: match.MaCNDangKy}
+			branch, err := r.getBranch(ctx, db, match.MaCNDangKy)
+			if err == nil {
+				group.TenCN = branch.TenCN
+			} else if !errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("failed to get branch %s: %w", match.MaCNDangKy, err)
+			}
+			groups[match.MaCNDangKy] = group
+			searchResult.Groups = append(searchResult.Groups, group)
 		}
-
-		allReaders = allReaders[offset:end]
+		group.Readers = append(group.Readers, match)
 	}
 
-	return allReaders, totalCount, nil
+	return searchResult, nil
 }
 
 // GetReaderWithStats retrieves reader with borrowing statistics
@@ -430,34 +421,66 @@ func (r *ReaderRepository) GetReaderWithStats(ctx context.Context, maDG string)
 		return nil, fmt.Errorf("reader not found: %w", err)
 	}
 
-	// Get connection to the reader's site
-	db, err := r.GetConnection(reader.MaCNDangKy)
+	// The reader may borrow at any branch, so the statistics of every PHIEUMUON fragment are combined
+	type partial struct {
+		total, current, overdue int
+		last                    sql.NullTime
+	}
+	result, err := query.Union(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "PHIEUMUON",
+		Select: `SELECT 
+				COUNT(*),
+				ISNULL(SUM(CASE WHEN NgayTra IS NULL THEN 1 ELSE 0 END), 0),
+				ISNULL(SUM(CASE WHEN NgayTra IS NULL AND DATEDIFF(day, HanTra, GETDATE()) > 0 THEN 1 ELSE 0 END), 0),
+				MAX(NgayMuon)
+			FROM PHIEUMUON`,
+		Filters: []query.Predicate{query.Eq("MaDG", maDG)},
+	}, func(rows *sql.Rows) (partial, error) {
+		var p partial
+		err := rows.Scan(&p.total, &p.current, &p.overdue, &p.last)
+		return p, err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to site %s: %w", reader.MaCNDangKy, err)
+		return nil, fmt.Errorf("failed to get reader statistics: %w", err)
 	}
 
-	// Query borrowing statistics
-	statsQuery := `
-		SELECT 
-			COUNT(*) as TotalBorrowed,
-			SUM(CASE WHEN NgayTra IS NULL THEN 1 ELSE 0 END) as CurrentBorrowed,
-			SUM(CASE WHEN NgayTra IS NULL AND DATEDIFF(day, NgayMuon, GETDATE()) > 30 THEN 1 ELSE 0 END) as OverdueBooks,
-			MAX(NgayMuon) as LastBorrowDate
-		FROM PHIEUMUON 
-		WHERE MaDG = ? AND MaCN = ?
-	`
-
 	var stats models.ReaderWithStats
 	var lastBorrowDate sql.NullTime
+	for _, p := range result.Rows {
+		stats.TotalBorrowed += p.total
+		stats.CurrentBorrowed += p.current
+		stats.OverdueBooks += p.overdue
+		if p.last.Valid && (!lastBorrowDate.Valid || p.last.Time.After(lastBorrowDate.Time)) {
+			lastBorrowDate = p.last
+		}
+	}
 
-	err = db.QueryRowContext(ctx, statsQuery, maDG, reader.MaCNDangKy).Scan(
-		&stats.TotalBorrowed,
-		&stats.CurrentBorrowed,
-		&stats.OverdueBooks,
-		&lastBorrowDate,
-	)
+	// Fines are owed at the branches that made the loans
+	fines, err := query.Union(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "PHAT",
+		Select:   "SELECT ISNULL(SUM(SoTien - DaThanhToan - DaMien), 0) FROM PHAT",
+		Filters: []query.Predicate{
+			query.Eq("MaDG", maDG),
+			query.Eq("TrangThai", models.FineUnpaid),
+		},
+	}, func(rows *sql.Rows) (int, error) {
+		var amount int
+		err := rows.Scan(&amount)
+		return amount, err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get reader statistics: %w", err)
+		return nil, fmt.Errorf("failed to get reader fines: %w", err)
+	}
+	for _, amount := range fines.Rows {
+		stats.UnpaidFines += amount
+	}
+
+	accruing, err := r.accruingFines(ctx, query.Eq("MaDG", maDG))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reader fines: %w", err)
+	}
+	for _, a := range accruing {
+		stats.AccruingFines += a.amount
 	}
 
 	// Populate reader info
@@ -476,11 +499,21 @@ func (r *ReaderRepository) GetReaderWithStats(ctx context.Context, maDG string)
 
 // GetReadersWithStats retrieves all readers with statistics for a site
 func (r *ReaderRepository) GetReadersWithStats(ctx context.Context, siteID string) ([]*models.ReaderWithStats, error) {
-	db, err := r.GetConnection(siteID)
+	db, _, err := r.GetFragmentConnection("DOCGIA", siteID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
 
+	// Fines accrue on the loans of this site under its policies
+	accruing, err := r.accruingFines(ctx, query.Eq("MaCN", siteID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accruing fines: %w", err)
+	}
+	accruingByReader := make(map[string]int, len(accruing))
+	for _, a := range accruing {
+		accruingByReader[a.maDG] += a.amount
+	}
+
 	query := `
 		SELECT 
 			d.MaDG,
@@ -489,24 +522,31 @@ func (r *ReaderRepository) GetReadersWithStats(ctx context.Context, siteID strin
 			ISNULL(stats.TotalBorrowed, 0) as TotalBorrowed,
 			ISNULL(stats.CurrentBorrowed, 0) as CurrentBorrowed,
 			ISNULL(stats.OverdueBooks, 0) as OverdueBooks,
-			ISNULL(CONVERT(varchar, stats.LastBorrowDate, 23), '') as LastBorrowDate
+			ISNULL(CONVERT(varchar, stats.LastBorrowDate, 23), '') as LastBorrowDate,
+			ISNULL(fines.UnpaidFines, 0) as UnpaidFines
 		FROM DOCGIA d
 		LEFT JOIN (
 			SELECT 
 				MaDG,
 				COUNT(*) as TotalBorrowed,
 				SUM(CASE WHEN NgayTra IS NULL THEN 1 ELSE 0 END) as CurrentBorrowed,
-				SUM(CASE WHEN NgayTra IS NULL AND DATEDIFF(day, NgayMuon, GETDATE()) > 30 THEN 1 ELSE 0 END) as OverdueBooks,
+				SUM(CASE WHEN NgayTra IS NULL AND DATEDIFF(day, HanTra, GETDATE()) > 0 THEN 1 ELSE 0 END) as OverdueBooks,
 				MAX(NgayMuon) as LastBorrowDate
 			FROM PHIEUMUON 
 			WHERE MaCN = ?
 			GROUP BY MaDG
 		) stats ON d.MaDG = stats.MaDG
+		LEFT JOIN (
+			SELECT MaDG, SUM(SoTien - DaThanhToan - DaMien) as UnpaidFines
+			FROM PHAT
+			WHERE MaCN = ? AND TrangThai = ?
+			GROUP BY MaDG
+		) fines ON d.MaDG = fines.MaDG
 		WHERE d.MaCN_DangKy = ?
 		ORDER BY d.HoTen
 	`
 
-	rows, err := db.QueryContext(ctx, query, siteID, siteID)
+	rows, err := db.QueryContext(ctx, query, siteID, siteID, models.FineUnpaid, siteID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query readers with stats: %w", err)
 	}
@@ -523,10 +563,12 @@ func (r *ReaderRepository) GetReadersWithStats(ctx context.Context, siteID strin
 			&reader.CurrentBorrowed,
 			&reader.OverdueBooks,
 			&reader.LastBorrowDate,
+			&reader.UnpaidFines,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan reader with stats: %w", err)
 		}
+		reader.AccruingFines = accruingByReader[reader.MaDG]
 		readers = append(readers, &reader)
 	}
 
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"library_distributed_server/internal/config"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeStmt is a statement run on a fake site
+type fakeStmt struct {
+	query string
+	args  []driver.Value
+	tx    int    // Transaction the statement ran in, 0 when run on its own
+	state string // "committed", "rolled back" or "" while its transaction is open
+}
+
+// fakeResult answers a statement: the rows of a query, or the rows affected by an update
+type fakeResult struct {
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+// fakeSite is the database of one site in a test. It records every statement and answers
+// with respond, which sees the statement before it is recorded; an update answered with no
+// result affects one row. The fake applies nothing, so tests hand respond the rows to return.
+type fakeSite struct {
+	mutex      sync.Mutex
+	respond    func(stmt fakeStmt) *fakeResult
+	failCommit bool // Commits fail, as when the site goes down before committing
+	stmts      []*fakeStmt
+	lastTx     int
+	db         *sql.DB
+}
+
+// fakeSites opens the fake databases of a test by site ID
+type fakeSites map[string]*fakeSite
+
+// connect returns the one connection of a site, as the connection pool does
+func (f fakeSites) connect(siteID, _ string) (*sql.DB, error) {
+	site, ok := f[siteID]
+	if !ok {
+		return nil, errors.New("site unavailable: " + siteID)
+	}
+	if site.db == nil {
+		site.db = sql.OpenDB(site)
+	}
+	return site.db, nil
+}
+
+// base returns a repository base over the sites, each storing its own fragments but those
+// allocated elsewhere. Readers may borrow 5 copies for 14 days, and are fined 5000 a day late.
+func (f fakeSites) base(t *testing.T, allocations ...config.AllocationConfig) *BaseRepository {
+	t.Helper()
+	ids := make([]string, 0, len(f))
+	for id := range f {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	cfg := &config.Config{
+		Loans:       config.LoanConfig{Period: 14 * 24 * time.Hour, MaxItems: 5},
+		Fines:       config.FineConfig{DailyRate: 5000},
+		Holds:       config.HoldConfig{PickupWindow: 72 * time.Hour},
+		Allocations: allocations,
+	}
+	for _, id := range ids {
+		cfg.Sites = append(cfg.Sites, config.SiteConfig{SiteID: id})
+	}
+
+	store, err := config.NewStore(func() (*config.Config, error) { return cfg, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &BaseRepository{store: store, connect: f.connect}
+}
+
+// committed returns the statements containing fragment that took effect on the site, in order
+func (s *fakeSite) committed(fragment string) []fakeStmt {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var stmts []fakeStmt
+	for _, stmt := range s.stmts {
+		if (stmt.tx == 0 || stmt.state == "committed") && strings.Contains(stmt.query, fragment) {
+			stmts = append(stmts, *stmt)
+		}
+	}
+	return stmts
+}
+
+// run records a statement and answers it
+func (s *fakeSite) run(query string, args []driver.NamedValue, tx int) *fakeResult {
+	stmt := &fakeStmt{query: query, tx: tx}
+	for _, arg := range args {
+		stmt.args = append(stmt.args, arg.Value)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var result *fakeResult
+	if s.respond != nil {
+		result = s.respond(*stmt)
+	}
+	if result == nil {
+		result = &fakeResult{affected: 1}
+	}
+	if result.err == nil {
+		s.stmts = append(s.stmts, stmt)
+	}
+	return result
+}
+
+// end closes a transaction, reporting whether its statements took effect
+func (s *fakeSite) end(tx int, commit bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	state := "rolled back"
+	if commit && !s.failCommit {
+		state = "committed"
+	}
+	for _, stmt := range s.stmts {
+		if stmt.tx == tx && stmt.state == "" {
+			stmt.state = state
+		}
+	}
+	if commit && s.failCommit {
+		return errors.New("commit failed")
+	}
+	return nil
+}
+
+func (s *fakeSite) Connect(context.Context) (driver.Conn, error) { return &fakeConn{site: s}, nil }
+func (s *fakeSite) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("open fake sites with sql.OpenDB")
+}
+
+// fakeConn runs statements on a fake site, in at most one transaction at a time
+type fakeConn struct {
+	site *fakeSite
+	tx   int
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.site.mutex.Lock()
+	c.site.lastTx++
+	c.tx = c.site.lastTx
+	c.site.mutex.Unlock()
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error   { return c.finish(true) }
+func (c *fakeConn) Rollback() error { return c.finish(false) }
+
+func (c *fakeConn) finish(commit bool) error {
+	tx := c.tx
+	c.tx = 0
+	return c.site.end(tx, commit)
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result := c.site.run(query, args, c.tx)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return driver.RowsAffected(result.affected), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result := c.site.run(query, args, c.tx)
+	if result.err != nil {
+		return nil, result.err
+	}
+	return &fakeRows{rows: result.rows}, nil
+}
+
+// fakeRows returns rows of any number of columns
+type fakeRows struct {
+	rows [][]driver.Value
+	next int
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
+
+// nullable stores an empty string as NULL, as nullString does
+func nullable(s string) driver.Value {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"library_distributed_server/internal/aggregate"
+	"library_distributed_server/internal/models"
+	"math"
+)
+
+// overdueLoans selects the loans of a site (the parameter) kept past their due date
+const overdueLoans = "PHIEUMUON WHERE MaCN = ? AND NgayTra IS NULL AND DATEDIFF(day, HanTra, GETDATE()) > 0"
+
+// statsAggregate holds the partial statistics of one or more sites. Merging the partials
+// of every site and finalizing them gives the values computed over all fragments at once.
+type statsAggregate struct {
+	// QUYENSACH
+	copies, availableCopies, borrowedCopies int
+	titles                                  *aggregate.Distinct // ISBN
+
+	// DOCGIA and PHIEUMUON
+	readers, borrows                               int
+	activeReaders, currentBorrowers, lateBorrowers *aggregate.Distinct // MaDG
+
+	// PHIEUMUON not yet returned
+	activeBorrows int
+	overdueDays   aggregate.Stat // Days past the loan period of overdue loans
+}
+
+func newStatsAggregate() *statsAggregate {
+	return &statsAggregate{
+		titles:           aggregate.NewDistinct(),
+		activeReaders:    aggregate.NewDistinct(),
+		currentBorrowers: aggregate.NewDistinct(),
+		lateBorrowers:    aggregate.NewDistinct(),
+	}
+}
+
+// merge adds the partial statistics of other sites
+func (a *statsAggregate) merge(o *statsAggregate) {
+	a.copies += o.copies
+	a.availableCopies += o.availableCopies
+	a.borrowedCopies += o.borrowedCopies
+	a.titles.Merge(o.titles)
+
+	a.readers += o.readers
+	a.borrows += o.borrows
+	a.activeReaders.Merge(o.activeReaders)
+	a.currentBorrowers.Merge(o.currentBorrowers)
+	a.lateBorrowers.Merge(o.lateBorrowers)
+
+	a.activeBorrows += o.activeBorrows
+	a.overdueDays = a.overdueDays.Merge(o.overdueDays)
+}
+
+// books finalizes the copy statistics
+func (a *statsAggregate) books() models.BookStatistics {
+	return models.BookStatistics{
+		TotalCopies:     a.copies,
+		AvailableCopies: a.availableCopies,
+		BorrowedCopies:  a.borrowedCopies,
+		UniqueTitles:    a.titles.Estimate(),
+		UtilizationRate: percentage(a.borrowedCopies, a.copies),
+	}
+}
+
+// readerStatistics finalizes the reader statistics
+func (a *statsAggregate) readerStatistics() models.ReaderStatistics {
+	stats := models.ReaderStatistics{
+		TotalReaders:              a.readers,
+		ActiveReaders:             a.activeReaders.Estimate(),
+		ReadersWithCurrentBorrows: a.currentBorrowers.Estimate(),
+		ReadersWithOverdue:        a.lateBorrowers.Estimate(),
+		TotalBorrows:              a.borrows,
+	}
+	if a.readers > 0 {
+		stats.AvgBooksPerReader = float64(a.borrows) / float64(a.readers)
+	}
+	return stats
+}
+
+// overdue finalizes the overdue statistics
+func (a *statsAggregate) overdue() models.OverdueStatistics {
+	return models.OverdueStatistics{
+		TotalOverdue:   a.overdueDays.Count,
+		ActiveBorrows:  a.activeBorrows,
+		AvgOverdueDays: a.overdueDays.Mean(),
+		MaxOverdueDays: int(a.overdueDays.Max),
+		OverdueRate:    percentage(a.overdueDays.Count, a.activeBorrows),
+	}
+}
+
+// siteStats finalizes the summary of a single site
+func (a *statsAggregate) siteStats(siteID string) models.SiteStats {
+	return models.SiteStats{
+		SiteID:       siteID,
+		BooksOnLoan:  a.activeBorrows,
+		TotalBooks:   a.copies,
+		TotalReaders: a.readers,
+	}
+}
+
+// percentage returns part over whole in percent, 0 when whole is 0
+func percentage(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*10000) / 100
+}
+
+// siteAggregate computes the partial statistics of the fragments of one site
+func (r *StatsRepository) siteAggregate(ctx context.Context, siteID string) (*statsAggregate, error) {
+	db, err := r.GetConnection(siteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
+	}
+
+	a := newStatsAggregate()
+	if err := siteCopyAggregate(ctx, db, siteID, a); err != nil {
+		return nil, err
+	}
+	if err := siteReaderAggregate(ctx, db, siteID, a); err != nil {
+		return nil, err
+	}
+	if err := siteLoanAggregate(ctx, db, siteID, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// siteCopyAggregate reads the QUYENSACH partials of a site
+func siteCopyAggregate(ctx context.Context, db *sql.DB, siteID string, a *statsAggregate) error {
+	err := db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*),
+			ISNULL(SUM(CASE WHEN TinhTrang = ? THEN 1 ELSE 0 END), 0),
+			ISNULL(SUM(CASE WHEN TinhTrang = ? THEN 1 ELSE 0 END), 0)
+		FROM QUYENSACH
+		WHERE MaCN = ?
+	`, models.CopyAvailable, models.CopyOnLoan, siteID).Scan(&a.copies, &a.availableCopies, &a.borrowedCopies)
+	if err != nil {
+		return fmt.Errorf("failed to count copies: %w", err)
+	}
+
+	if a.titles, err = aggregate.QueryDistinct(ctx, db, "ISBN", "QUYENSACH WHERE MaCN = ?", siteID); err != nil {
+		return fmt.Errorf("failed to count unique titles: %w", err)
+	}
+	return nil
+}
+
+// siteReaderAggregate reads the DOCGIA and PHIEUMUON reader partials of a site
+func siteReaderAggregate(ctx context.Context, db *sql.DB, siteID string, a *statsAggregate) error {
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM DOCGIA WHERE MaCN_DangKy = ?", siteID).Scan(&a.readers)
+	if err != nil {
+		return fmt.Errorf("failed to get total readers: %w", err)
+	}
+
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM PHIEUMUON WHERE MaCN = ?", siteID).Scan(&a.borrows)
+	if err != nil {
+		return fmt.Errorf("failed to get total borrows: %w", err)
+	}
+
+	if a.activeReaders, err = aggregate.QueryDistinct(ctx, db, "MaDG", "PHIEUMUON WHERE MaCN = ?", siteID); err != nil {
+		return fmt.Errorf("failed to get active readers: %w", err)
+	}
+	if a.currentBorrowers, err = aggregate.QueryDistinct(ctx, db, "MaDG", "PHIEUMUON WHERE MaCN = ? AND NgayTra IS NULL", siteID); err != nil {
+		return fmt.Errorf("failed to get readers with current borrows: %w", err)
+	}
+	if a.lateBorrowers, err = aggregate.QueryDistinct(ctx, db, "MaDG", overdueLoans, siteID); err != nil {
+		return fmt.Errorf("failed to get readers with overdue: %w", err)
+	}
+	return nil
+}
+
+// siteLoanAggregate reads the partials of the loans of a site not yet returned
+func siteLoanAggregate(ctx context.Context, db *sql.DB, siteID string, a *statsAggregate) error {
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM PHIEUMUON WHERE MaCN = ? AND NgayTra IS NULL", siteID).Scan(&a.activeBorrows)
+	if err != nil {
+		return fmt.Errorf("failed to get total active borrows: %w", err)
+	}
+
+	if a.overdueDays, err = aggregate.QueryStat(ctx, db, "DATEDIFF(day, HanTra, GETDATE())", overdueLoans, siteID); err != nil {
+		return fmt.Errorf("failed to get overdue days: %w", err)
+	}
+	return nil
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"library_distributed_server/internal/config"
 	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
 	"log"
+	"sort"
 	"time"
 )
 
@@ -19,8 +21,8 @@ type StatsRepository struct {
 type StatsRepositoryInterface interface {
 	// Site-specific statistics
 	GetSiteStatistics(ctx context.Context, siteID string) (*models.SiteStats, error)
-	GetSiteBookStatistics(ctx context.Context, siteID string) (map[string]interface{}, error)
-	GetSiteReaderStatistics(ctx context.Context, siteID string) (map[string]interface{}, error)
+	GetSiteBookStatistics(ctx context.Context, siteID string) (*models.BookStatistics, error)
+	GetSiteReaderStatistics(ctx context.Context, siteID string) (*models.ReaderStatistics, error)
 
 	// System-wide statistics (Manager only)
 	GetSystemStatistics(ctx context.Context) (*models.SystemStatsResponse, error)
@@ -28,19 +30,19 @@ type StatsRepositoryInterface interface {
 	GetPopularBooksAcrossSites(ctx context.Context, limit int) ([]*models.BookWithAvailability, error)
 
 	// Performance analytics
-	GetBorrowTrends(ctx context.Context, siteID string, days int) ([]map[string]interface{}, error)
-	GetOverdueAnalytics(ctx context.Context, siteID string) (map[string]interface{}, error)
-	GetReaderEngagementStats(ctx context.Context, siteID string) (map[string]interface{}, error)
+	GetBorrowTrends(ctx context.Context, siteID string, days int) ([]models.BorrowTrend, error)
+	GetOverdueAnalytics(ctx context.Context, siteID string) (*models.OverdueStatistics, error)
+	GetReaderEngagementStats(ctx context.Context, siteID string) (*models.ReaderEngagement, error)
 
 	// Health monitoring
-	GetSystemHealth(ctx context.Context) (map[string]interface{}, error)
-	GetSiteHealth(ctx context.Context, siteID string) (map[string]interface{}, error)
+	GetSystemHealth(ctx context.Context) (*models.SystemHealth, error)
+	GetSiteHealth(ctx context.Context, siteID string) (*models.SiteHealth, error)
 }
 
 // NewStatsRepository creates a new statistics repository with raw SQL
-func NewStatsRepository(config *config.Config) StatsRepositoryInterface {
+func NewStatsRepository(store *config.Store) StatsRepositoryInterface {
 	return &StatsRepository{
-		BaseRepository: NewBaseRepository(config),
+		BaseRepository: NewBaseRepository(store),
 	}
 }
 
@@ -92,208 +94,90 @@ func (r *StatsRepository) GetSiteStatistics(ctx context.Context, siteID string)
 }
 
 // GetSiteBookStatistics retrieves detailed book statistics for a site
-func (r *StatsRepository) GetSiteBookStatistics(ctx context.Context, siteID string) (map[string]interface{}, error) {
+func (r *StatsRepository) GetSiteBookStatistics(ctx context.Context, siteID string) (*models.BookStatistics, error) {
 	db, err := r.GetConnection(siteID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
 
-	stats := make(map[string]interface{})
-
-	// Total book copies
-	var totalCopies int
-	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM QUYENSACH WHERE MaCN = ?", siteID).Scan(&totalCopies)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get total copies: %w", err)
-	}
-	stats["totalCopies"] = totalCopies
-
-	// Available copies
-	var availableCopies int
-	err = db.QueryRowContext(ctx, `
-		SELECT COUNT(*) 
-		FROM QUYENSACH 
-		WHERE MaCN = ? AND TinhTrang = N'Có sẵn'
-	`, siteID).Scan(&availableCopies)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get available copies: %w", err)
-	}
-	stats["availableCopies"] = availableCopies
-
-	// Borrowed copies
-	var borrowedCopies int
-	err = db.QueryRowContext(ctx, `
-		SELECT COUNT(*) 
-		FROM QUYENSACH 
-		WHERE MaCN = ? AND TinhTrang = N'Đang được mượn'
-	`, siteID).Scan(&borrowedCopies)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get borrowed copies: %w", err)
-	}
-	stats["borrowedCopies"] = borrowedCopies
-
-	// Unique book titles (ISBNs)
-	var uniqueTitles int
-	err = db.QueryRowContext(ctx, `
-		SELECT COUNT(DISTINCT ISBN) 
-		FROM QUYENSACH 
-		WHERE MaCN = ?
-	`, siteID).Scan(&uniqueTitles)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get unique titles: %w", err)
-	}
-	stats["uniqueTitles"] = uniqueTitles
-
-	// Utilization rate
-	if totalCopies > 0 {
-		utilizationRate := float64(borrowedCopies) / float64(totalCopies) * 100
-		stats["utilizationRate"] = utilizationRate
-	} else {
-		stats["utilizationRate"] = 0.0
+	a := newStatsAggregate()
+	if err := siteCopyAggregate(ctx, db, siteID, a); err != nil {
+		return nil, err
 	}
-
-	return stats, nil
+	stats := a.books()
+	return &stats, nil
 }
 
 // GetSiteReaderStatistics retrieves detailed reader statistics for a site
-func (r *StatsRepository) GetSiteReaderStatistics(ctx context.Context, siteID string) (map[string]interface{}, error) {
+func (r *StatsRepository) GetSiteReaderStatistics(ctx context.Context, siteID string) (*models.ReaderStatistics, error) {
 	db, err := r.GetConnection(siteID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
 
-	stats := make(map[string]interface{})
-
-	// Total readers
-	var totalReaders int
-	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM DOCGIA WHERE MaCN_DangKy = ?", siteID).Scan(&totalReaders)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get total readers: %w", err)
-	}
-	stats["totalReaders"] = totalReaders
-
-	// Active readers (have borrowed at least one book)
-	var activeReaders int
-	err = db.QueryRowContext(ctx, `
-		SELECT COUNT(DISTINCT MaDG) 
-		FROM PHIEUMUON 
-		WHERE MaCN = ?
-	`, siteID).Scan(&activeReaders)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get active readers: %w", err)
+	a := newStatsAggregate()
+	if err := siteReaderAggregate(ctx, db, siteID, a); err != nil {
+		return nil, err
 	}
-	stats["activeReaders"] = activeReaders
-
-	// Readers with current borrows
-	var readersWithCurrentBorrows int
-	err = db.QueryRowContext(ctx, `
-		SELECT COUNT(DISTINCT MaDG) 
-		FROM PHIEUMUON 
-		WHERE MaCN = ? AND NgayTra IS NULL
-	`, siteID).Scan(&readersWithCurrentBorrows)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get readers with current borrows: %w", err)
-	}
-	stats["readersWithCurrentBorrows"] = readersWithCurrentBorrows
-
-	// Readers with overdue books
-	var readersWithOverdue int
-	err = db.QueryRowContext(ctx, `
-		SELECT COUNT(DISTINCT MaDG) 
-		FROM PHIEUMUON 
-		WHERE MaCN = ? AND NgayTra IS NULL 
-			AND DATEDIFF(day, NgayMuon, GETDATE()) > 30
-	`, siteID).Scan(&readersWithOverdue)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get readers with overdue: %w", err)
-	}
-	stats["readersWithOverdue"] = readersWithOverdue
-
-	// Average books per reader
-	if totalReaders > 0 {
-		var totalBorrows int
-		err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM PHIEUMUON WHERE MaCN = ?", siteID).Scan(&totalBorrows)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get total borrows: %w", err)
-		}
-		avgBooksPerReader := float64(totalBorrows) / float64(totalReaders)
-		stats["avgBooksPerReader"] = avgBooksPerReader
-	} else {
-		stats["avgBooksPerReader"] = 0.0
-	}
-
-	return stats, nil
+	stats := a.readerStatistics()
+	return &stats, nil
 }
 
-// GetSystemStatistics retrieves comprehensive system-wide statistics (Manager only)
+// GetSystemStatistics retrieves comprehensive system-wide statistics (Manager only).
+// Each site computes partial aggregates over its fragments in parallel and they are
+// merged here; sites that fail are left out of the totals and reported through the
+// context's query tracker.
 func (r *StatsRepository) GetSystemStatistics(ctx context.Context) (*models.SystemStatsResponse, error) {
-	connections, err := r.GetAllSiteConnections()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get site connections: %w", err)
-	}
+	executor := r.Executor("")
 
 	response := &models.SystemStatsResponse{
 		GeneratedAt: time.Now().Format("2006-01-02T15:04:05Z"),
+		SiteStats:   []models.SiteStats{},
 	}
 
-	var allSiteStats []models.SiteStats
-
-	// Collect statistics from each site
-	for siteID := range connections {
-		siteStats, err := r.GetSiteStatistics(ctx, siteID)
-		if err != nil {
-			log.Printf("Error getting statistics from site %s: %v", siteID, err)
-			continue
-		}
-
-		allSiteStats = append(allSiteStats, *siteStats)
-
-		// Aggregate totals
-		response.TotalCopies += siteStats.TotalBooks
-		response.TotalReaders += siteStats.TotalReaders
-		response.ActiveBorrows += siteStats.BooksOnLoan
+	// Collect partial statistics from each site
+	partials, err := query.FanOut(ctx, executor, r.config().SiteIDs(), r.siteAggregate)
+	if err != nil {
+		return nil, err
+	}
+
+	total := newStatsAggregate()
+	for i, siteID := range partials.Sites {
+		response.SiteStats = append(response.SiteStats, partials.Rows[i].siteStats(siteID))
+		total.merge(partials.Rows[i])
+	}
+	response.Books = total.books()
+	response.Readers = total.readerStatistics()
+	response.Overdue = total.overdue()
+	response.TotalCopies = response.Books.TotalCopies
+	response.TotalReaders = response.Readers.TotalReaders
+	response.ActiveBorrows = response.Overdue.ActiveBorrows
+	response.OverdueBooks = response.Overdue.TotalOverdue
+
+	// Total unique book titles from the replicated SACH table; one replica answers
+	titles, err := query.Union(ctx, executor, query.Query{
+		Relation: "SACH",
+		Select:   "SELECT COUNT(*) FROM SACH",
+	}, func(rows *sql.Rows) (int, error) {
+		var n int
+		err := rows.Scan(&n)
+		return n, err
+	})
+	if err != nil {
+		return nil, err
 	}
-
-	response.SiteStats = allSiteStats
-
-	// Get total unique book titles across all sites (from replicated SACH table)
-	if len(connections) > 0 {
-		// Use first available connection since SACH is replicated
-		for _, db := range connections {
-			err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM SACH").Scan(&response.TotalBooks)
-			if err != nil {
-				log.Printf("Error getting total books count: %v", err)
-			}
-			break // Only need to query one site for replicated data
-		}
-	}
-
-	// Calculate overdue books across all sites
-	for siteID, db := range connections {
-		var siteOverdue int
-		err = db.QueryRowContext(ctx, `
-			SELECT COUNT(*) 
-			FROM PHIEUMUON 
-			WHERE MaCN = ? AND NgayTra IS NULL 
-				AND DATEDIFF(day, NgayMuon, GETDATE()) > 30
-		`, siteID).Scan(&siteOverdue)
-		if err != nil {
-			log.Printf("Error getting overdue count from site %s: %v", siteID, err)
-			continue
-		}
-		response.OverdueBooks += siteOverdue
+	for _, n := range titles.Rows {
+		response.TotalBooks = n
 	}
 
 	// Get popular books across all sites
 	popularBooks, err := r.GetPopularBooksAcrossSites(ctx, 10)
 	if err != nil {
-		log.Printf("Error getting popular books: %v", err)
-	} else {
-		// Convert from []*models.BookWithAvailability to []models.BookWithAvailability
-		for _, book := range popularBooks {
-			if book != nil {
-				response.PopularBooks = append(response.PopularBooks, *book)
-			}
+		return nil, err
+	}
+	for _, book := range popularBooks {
+		if book != nil {
+			response.PopularBooks = append(response.PopularBooks, *book)
 		}
 	}
 
@@ -302,23 +186,17 @@ func (r *StatsRepository) GetSystemStatistics(ctx context.Context) (*models.Syst
 
 // GetDistributedStatistics retrieves system statistics in legacy format
 func (r *StatsRepository) GetDistributedStatistics(ctx context.Context) (*models.SystemStats, error) {
-	connections, err := r.GetAllSiteConnections()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get site connections: %w", err)
-	}
-
 	stats := &models.SystemStats{
 		StatsBySite: make(map[string]models.SiteStats),
 	}
 
 	// Collect statistics from each site
-	for siteID := range connections {
-		siteStats, err := r.GetSiteStatistics(ctx, siteID)
-		if err != nil {
-			log.Printf("Error getting statistics from site %s: %v", siteID, err)
-			continue
-		}
-
+	result, err := query.FanOut(ctx, r.Executor(""), r.config().SiteIDs(), r.GetSiteStatistics)
+	if err != nil {
+		return nil, err
+	}
+	for i, siteID := range result.Sites {
+		siteStats := result.Rows[i]
 		stats.StatsBySite[siteID] = *siteStats
 		stats.TotalBooksOnLoan += siteStats.BooksOnLoan
 	}
@@ -326,86 +204,81 @@ func (r *StatsRepository) GetDistributedStatistics(ctx context.Context) (*models
 	return stats, nil
 }
 
-// GetPopularBooksAcrossSites retrieves most popular books system-wide
+// popularBook is one site's counts for a book
+type popularBook struct {
+	book        models.BookWithAvailability
+	borrowCount int
+}
+
+// GetPopularBooksAcrossSites retrieves the most borrowed books system-wide
 func (r *StatsRepository) GetPopularBooksAcrossSites(ctx context.Context, limit int) ([]*models.BookWithAvailability, error) {
-	connections, err := r.GetAllSiteConnections()
+	result, err := query.Union(ctx, r.Executor(""), query.Query{
+		Relation: "QUYENSACH",
+		Alias:    "qs",
+		Select: `
+			SELECT 
+				s.ISBN, s.TenSach, s.TacGia,
+				COUNT(qs.MaQuyenSach) as TotalCount,
+				SUM(CASE WHEN qs.TinhTrang = ? THEN 1 ELSE 0 END) as AvailableCount,
+				SUM(CASE WHEN qs.TinhTrang = ? THEN 1 ELSE 0 END) as BorrowedCount,
+				COUNT(pm.MaPM) as BorrowCount
+			FROM SACH s
+			LEFT JOIN QUYENSACH qs ON s.ISBN = qs.ISBN
+			LEFT JOIN PHIEUMUON pm ON qs.MaQuyenSach = pm.MaQuyenSach`,
+		Args:    []interface{}{models.CopyAvailable, models.CopyOnLoan},
+		GroupBy: "s.ISBN, s.TenSach, s.TacGia",
+	}, func(rows *sql.Rows) (popularBook, error) {
+		var p popularBook
+		err := rows.Scan(&p.book.ISBN, &p.book.TenSach, &p.book.TacGia,
+			&p.book.TotalCount, &p.book.AvailableCount, &p.book.BorrowedCount, &p.borrowCount)
+		if err != nil {
+			return p, fmt.Errorf("failed to scan popular book: %w", err)
+		}
+		return p, nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get site connections: %w", err)
+		return nil, fmt.Errorf("failed to query popular books: %w", err)
 	}
 
-	// Map to aggregate book statistics across sites
-	bookStats := make(map[string]*models.BookWithAvailability)
-
-	query := `
-		SELECT 
-			s.ISBN, s.TenSach, s.TacGia,
-			COUNT(qs.MaQuyenSach) as TotalCount,
-			SUM(CASE WHEN qs.TinhTrang = N'Có sẵn' THEN 1 ELSE 0 END) as AvailableCount,
-			SUM(CASE WHEN qs.TinhTrang = N'Đang được mượn' THEN 1 ELSE 0 END) as BorrowedCount,
-			COUNT(pm.MaPM) as BorrowCount
-		FROM SACH s
-		LEFT JOIN QUYENSACH qs ON s.ISBN = qs.ISBN AND qs.MaCN = ?
-		LEFT JOIN PHIEUMUON pm ON qs.MaQuyenSach = pm.MaQuyenSach
-		GROUP BY s.ISBN, s.TenSach, s.TacGia
-		ORDER BY COUNT(pm.MaPM) DESC
-	`
-
-	for siteID, db := range connections {
-		rows, err := db.QueryContext(ctx, query, siteID)
-		if err != nil {
-			log.Printf("Error querying popular books from site %s: %v", siteID, err)
+	// Aggregate book statistics across sites
+	bookStats := make(map[string]*popularBook)
+	for _, p := range result.Rows {
+		if existing, exists := bookStats[p.book.ISBN]; exists {
+			existing.book.TotalCount += p.book.TotalCount
+			existing.book.AvailableCount += p.book.AvailableCount
+			existing.book.BorrowedCount += p.book.BorrowedCount
+			existing.borrowCount += p.borrowCount
 			continue
 		}
-		defer rows.Close()
-
-		for rows.Next() {
-			var isbn, tenSach, tacGia string
-			var totalCount, availableCount, borrowedCount, borrowCount int
-
-			err := rows.Scan(&isbn, &tenSach, &tacGia, &totalCount, &availableCount, &borrowedCount, &borrowCount)
-			if err != nil {
-				log.Printf("Error scanning popular book from site %s: %v", siteID, err)
-				continue
-			}
-
-			if book, exists := bookStats[isbn]; exists {
-				// Aggregate counts
-				book.TotalCount += totalCount
-				book.AvailableCount += availableCount
-				book.BorrowedCount += borrowedCount
-			} else {
-				// Create new entry
-				bookStats[isbn] = &models.BookWithAvailability{
-					ISBN:           isbn,
-					TenSach:        tenSach,
-					TacGia:         tacGia,
-					TotalCount:     totalCount,
-					AvailableCount: availableCount,
-					BorrowedCount:  borrowedCount,
-				}
-			}
-		}
+		p := p
+		bookStats[p.book.ISBN] = &p
 	}
 
-	// Convert map to slice and sort by popularity (could be enhanced with actual borrow counts)
-	var books []*models.BookWithAvailability
-	for _, book := range bookStats {
-		if book.TotalCount > 0 { // Only include books that have copies
-			books = append(books, book)
+	var popular []*popularBook
+	for _, p := range bookStats {
+		if p.book.TotalCount > 0 { // Only include books that have copies
+			popular = append(popular, p)
 		}
 	}
-
-	// Simple sorting by total copies (in a real implementation, sort by actual borrow frequency)
-	// TODO: Implement proper sorting by borrow frequency
-	if len(books) > limit {
-		books = books[:limit]
+	sort.Slice(popular, func(i, j int) bool {
+		if popular[i].borrowCount != popular[j].borrowCount {
+			return popular[i].borrowCount > popular[j].borrowCount
+		}
+		return popular[i].book.ISBN < popular[j].book.ISBN
+	})
+	if len(popular) > limit {
+		popular = popular[:limit]
 	}
 
+	books := make([]*models.BookWithAvailability, len(popular))
+	for i, p := range popular {
+		books[i] = &p.book
+	}
 	return books, nil
 }
 
 // GetBorrowTrends retrieves borrowing trends over specified days
-func (r *StatsRepository) GetBorrowTrends(ctx context.Context, siteID string, days int) ([]map[string]interface{}, error) {
+func (r *StatsRepository) GetBorrowTrends(ctx context.Context, siteID string, days int) ([]models.BorrowTrend, error) {
 	db, err := r.GetConnection(siteID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
@@ -428,93 +301,42 @@ func (r *StatsRepository) GetBorrowTrends(ctx context.Context, siteID string, da
 	}
 	defer rows.Close()
 
-	var trends []map[string]interface{}
+	trends := []models.BorrowTrend{}
 	for rows.Next() {
-		var date string
-		var count int
-		err := rows.Scan(&date, &count)
+		var trend models.BorrowTrend
+		err := rows.Scan(&trend.Date, &trend.BorrowCount)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan borrow trend: %w", err)
 		}
-
-		trend := map[string]interface{}{
-			"date":        date,
-			"borrowCount": count,
-		}
 		trends = append(trends, trend)
 	}
 
-	return trends, nil
+	return trends, rows.Err()
 }
 
 // GetOverdueAnalytics retrieves overdue book analytics for a site
-func (r *StatsRepository) GetOverdueAnalytics(ctx context.Context, siteID string) (map[string]interface{}, error) {
+func (r *StatsRepository) GetOverdueAnalytics(ctx context.Context, siteID string) (*models.OverdueStatistics, error) {
 	db, err := r.GetConnection(siteID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
 
-	analytics := make(map[string]interface{})
-
-	// Total overdue books
-	var totalOverdue int
-	err = db.QueryRowContext(ctx, `
-		SELECT COUNT(*) 
-		FROM PHIEUMUON 
-		WHERE MaCN = ? AND NgayTra IS NULL 
-			AND DATEDIFF(day, NgayMuon, GETDATE()) > 30
-	`, siteID).Scan(&totalOverdue)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get total overdue: %w", err)
-	}
-	analytics["totalOverdue"] = totalOverdue
-
-	// Average overdue days
-	var avgOverdueDays sql.NullFloat64
-	err = db.QueryRowContext(ctx, `
-		SELECT AVG(CAST(DATEDIFF(day, NgayMuon, GETDATE()) - 30 AS FLOAT))
-		FROM PHIEUMUON 
-		WHERE MaCN = ? AND NgayTra IS NULL 
-			AND DATEDIFF(day, NgayMuon, GETDATE()) > 30
-	`, siteID).Scan(&avgOverdueDays)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get average overdue days: %w", err)
-	}
-	if avgOverdueDays.Valid {
-		analytics["avgOverdueDays"] = avgOverdueDays.Float64
-	} else {
-		analytics["avgOverdueDays"] = 0
-	}
-
-	// Overdue rate
-	var totalActive int
-	err = db.QueryRowContext(ctx, `
-		SELECT COUNT(*) 
-		FROM PHIEUMUON 
-		WHERE MaCN = ? AND NgayTra IS NULL
-	`, siteID).Scan(&totalActive)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get total active borrows: %w", err)
-	}
-
-	if totalActive > 0 {
-		overdueRate := float64(totalOverdue) / float64(totalActive) * 100
-		analytics["overdueRate"] = overdueRate
-	} else {
-		analytics["overdueRate"] = 0.0
+	a := newStatsAggregate()
+	if err := siteLoanAggregate(ctx, db, siteID, a); err != nil {
+		return nil, err
 	}
-
-	return analytics, nil
+	analytics := a.overdue()
+	return &analytics, nil
 }
 
 // GetReaderEngagementStats retrieves reader engagement statistics
-func (r *StatsRepository) GetReaderEngagementStats(ctx context.Context, siteID string) (map[string]interface{}, error) {
+func (r *StatsRepository) GetReaderEngagementStats(ctx context.Context, siteID string) (*models.ReaderEngagement, error) {
 	db, err := r.GetConnection(siteID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
 
-	stats := make(map[string]interface{})
+	stats := &models.ReaderEngagement{ActiveReaders: []models.ReaderActivity{}}
 
 	// Most active readers (top 5)
 	query := `
@@ -533,92 +355,80 @@ func (r *StatsRepository) GetReaderEngagementStats(ctx context.Context, siteID s
 	}
 	defer rows.Close()
 
-	var activeReaders []map[string]interface{}
 	for rows.Next() {
-		var maDG, hoTen string
-		var borrowCount int
-		err := rows.Scan(&maDG, &hoTen, &borrowCount)
+		var reader models.ReaderActivity
+		err := rows.Scan(&reader.MaDG, &reader.HoTen, &reader.BorrowCount)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan active reader: %w", err)
 		}
-
-		reader := map[string]interface{}{
-			"maDG":        maDG,
-			"hoTen":       hoTen,
-			"borrowCount": borrowCount,
-		}
-		activeReaders = append(activeReaders, reader)
+		stats.ActiveReaders = append(stats.ActiveReaders, reader)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read active readers: %w", err)
 	}
-	stats["activeReaders"] = activeReaders
 
 	// New readers this month
-	var newReadersThisMonth int
 	err = db.QueryRowContext(ctx, `
 		SELECT COUNT(*) 
 		FROM DOCGIA 
 		WHERE MaCN_DangKy = ?
-	`, siteID).Scan(&newReadersThisMonth)
+	`, siteID).Scan(&stats.NewReadersThisMonth)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get new readers count: %w", err)
 	}
-	stats["newReadersThisMonth"] = newReadersThisMonth
 
 	return stats, nil
 }
 
 // GetSystemHealth retrieves system-wide health metrics
-func (r *StatsRepository) GetSystemHealth(ctx context.Context) (map[string]interface{}, error) {
-	connections, err := r.GetAllSiteConnections()
+func (r *StatsRepository) GetSystemHealth(ctx context.Context) (*models.SystemHealth, error) {
+	sites := r.config().SiteIDs()
+	result, err := query.FanOut(ctx, r.Executor(""), sites, func(ctx context.Context, siteID string) (*models.SiteHealth, error) {
+		db, err := r.GetConnection(siteID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect: %w", err)
+		}
+		return r.getSiteHealthFromDB(ctx, db, siteID)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to get site connections: %w", err)
+		return nil, err
 	}
 
-	health := make(map[string]interface{})
-	siteHealths := make(map[string]interface{})
-
-	var totalSites int
-	var healthySites int
-
-	for siteID, db := range connections {
-		siteHealth, err := r.getSiteHealthFromDB(ctx, db, siteID)
-		if err != nil {
-			log.Printf("Error getting health for site %s: %v", siteID, err)
-			siteHealth = map[string]interface{}{
-				"status": "unhealthy",
-				"error":  err.Error(),
-			}
-		} else {
-			healthySites++
+	health := &models.SystemHealth{
+		TotalSites:   len(sites),
+		HealthySites: len(result.Sites),
+		SystemStatus: "healthy",
+		Sites:        make(map[string]models.SiteHealth),
+		LastChecked:  time.Now().Format("2006-01-02T15:04:05Z"),
+	}
+	for i, siteID := range result.Sites {
+		health.Sites[siteID] = *result.Rows[i]
+	}
+	for siteID, err := range result.Failed {
+		health.Sites[siteID] = models.SiteHealth{
+			Status: "unhealthy",
+			Error:  err.Error(),
 		}
-
-		siteHealths[siteID] = siteHealth
-		totalSites++
 	}
 
-	health["totalSites"] = totalSites
-	health["healthySites"] = healthySites
-	health["unhealthySites"] = totalSites - healthySites
-	health["systemStatus"] = "healthy"
-	if healthySites < totalSites {
-		health["systemStatus"] = "degraded"
+	health.UnhealthySites = health.TotalSites - health.HealthySites
+	if health.HealthySites < health.TotalSites {
+		health.SystemStatus = "degraded"
 	}
-	if healthySites == 0 {
-		health["systemStatus"] = "unhealthy"
+	if health.HealthySites == 0 {
+		health.SystemStatus = "unhealthy"
 	}
 
-	health["sites"] = siteHealths
-	health["lastChecked"] = time.Now().Format("2006-01-02T15:04:05Z")
-
 	return health, nil
 }
 
 // GetSiteHealth retrieves health metrics for a specific site
-func (r *StatsRepository) GetSiteHealth(ctx context.Context, siteID string) (map[string]interface{}, error) {
+func (r *StatsRepository) GetSiteHealth(ctx context.Context, siteID string) (*models.SiteHealth, error) {
 	db, err := r.GetConnection(siteID)
 	if err != nil {
-		return map[string]interface{}{
-			"status": "unhealthy",
-			"error":  fmt.Sprintf("failed to connect: %v", err),
+		return &models.SiteHealth{
+			Status: "unhealthy",
+			Error:  fmt.Sprintf("failed to connect: %v", err),
 		}, nil
 	}
 
@@ -626,21 +436,21 @@ func (r *StatsRepository) GetSiteHealth(ctx context.Context, siteID string) (map
 }
 
 // getSiteHealthFromDB retrieves health metrics from a database connection
-func (r *StatsRepository) getSiteHealthFromDB(ctx context.Context, db *sql.DB, siteID string) (map[string]interface{}, error) {
-	health := make(map[string]interface{})
-
+func (r *StatsRepository) getSiteHealthFromDB(ctx context.Context, db *sql.DB, siteID string) (*models.SiteHealth, error) {
 	// Test database connectivity
 	err := db.PingContext(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("database ping failed: %w", err)
 	}
 
-	health["status"] = "healthy"
-	health["siteID"] = siteID
+	health := &models.SiteHealth{
+		Status:      "healthy",
+		SiteID:      siteID,
+		TableCounts: make(map[string]int),
+	}
 
 	// Get basic table counts to ensure database integrity
 	tables := []string{"SACH", "CHINHANH", "DOCGIA", "QUYENSACH", "PHIEUMUON"}
-	tableCounts := make(map[string]int)
 
 	for _, table := range tables {
 		var count int
@@ -661,14 +471,13 @@ func (r *StatsRepository) getSiteHealthFromDB(ctx context.Context, db *sql.DB, s
 
 		if err != nil {
 			log.Printf("Error querying table %s in site %s: %v", table, siteID, err)
-			tableCounts[table] = -1 // Indicate error
+			health.TableCounts[table] = -1 // Indicate error
 		} else {
-			tableCounts[table] = count
+			health.TableCounts[table] = count
 		}
 	}
 
-	health["tableCounts"] = tableCounts
-	health["lastChecked"] = time.Now().Format("2006-01-02T15:04:05Z")
+	health.LastChecked = time.Now().Format("2006-01-02T15:04:05Z")
 
 	return health, nil
 }
@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"library_distributed_server/internal/config"
+	"library_distributed_server/internal/models"
+	"library_distributed_server/internal/query"
+	"log"
+	"time"
+)
+
+// transferColumns are the CHUYENTRA columns read by scanChuyenTra, in order
+const transferColumns = "MaCT, MaQuyenSach, ISBN, MaCN, MaCN_NhanTra, MaCN_NhanSach, MaDC, TrangThai, NgayNhanTra, NgayNhan"
+
+// TransferRepository handles the copies returned at a branch other than their own. A transfer
+// is stored in the CHUYENTRA fragment of the copy's home branch, next to the copy, and is
+// listed for shipping at the branch that took the return until the home branch checks it in.
+// Copies set aside for a hold picked up at another branch are shipped there the same way and
+// checked in by the pickup branch (see HoldRepository.setAside).
+type TransferRepository struct {
+	*BaseRepository
+	siteID string          // Current site for this repository instance
+	holds  *HoldRepository // Hold queue served by copies checked in
+}
+
+// TransferRepositoryInterface defines return transfer operations
+type TransferRepositoryInterface interface {
+	GetOutgoingTransfers(ctx context.Context, siteID string) ([]*models.ChuyenTra, error)
+	GetIncomingTransfers(ctx context.Context, siteID string) ([]*models.ChuyenTra, error)
+	CheckIn(ctx context.Context, maQuyenSach string, userSite string) (*models.ChuyenTra, *models.DatCho, error)
+}
+
+// NewTransferRepository creates a new return transfer repository
+func NewTransferRepository(store *config.Store, siteID string) TransferRepositoryInterface {
+	base := NewBaseRepository(store)
+	return &TransferRepository{
+		BaseRepository: base,
+		siteID:         siteID,
+		holds:          &HoldRepository{BaseRepository: base, siteID: siteID},
+	}
+}
+
+// GetOutgoingTransfers retrieves the copies returned at a branch that it has to ship home, oldest first
+func (r *TransferRepository) GetOutgoingTransfers(ctx context.Context, siteID string) ([]*models.ChuyenTra, error) {
+	// Transfers are stored at the copies' home branches, so every fragment is searched
+	return r.transfers(ctx, query.Eq("MaCN_NhanTra", siteID))
+}
+
+// GetIncomingTransfers retrieves the copies on their way to a branch, oldest first: its own copies
+// returned elsewhere, and copies of other branches shipped for holds picked up there
+func (r *TransferRepository) GetIncomingTransfers(ctx context.Context, siteID string) ([]*models.ChuyenTra, error) {
+	return r.transfers(ctx, query.Where("(MaCN_NhanSach = ? OR (MaCN_NhanSach IS NULL AND MaCN = ?))", siteID, siteID))
+}
+
+// CheckIn puts a copy shipped home back on its branch's shelf, setting it aside for the oldest
+// waiting hold on its title if any. A copy of another branch shipped for a hold picked up at
+// userSite is set aside for that hold instead. The transfer and the hold are returned.
+func (r *TransferRepository) CheckIn(ctx context.Context, maQuyenSach string, userSite string) (*models.ChuyenTra, *models.DatCho, error) {
+	// The shipment is stored at the copy's home branch, so every fragment is searched
+	shipment, found, err := query.First(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "CHUYENTRA",
+		Select:   "SELECT " + transferColumns + " FROM CHUYENTRA",
+		Filters: []query.Predicate{
+			query.Eq("MaQuyenSach", maQuyenSach),
+			query.Eq("MaCN_NhanSach", userSite),
+			query.Eq("TrangThai", models.TransferInTransit),
+		},
+	}, scanChuyenTra)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find transfer: %w", err)
+	}
+	if found {
+		hold, err := r.holds.receive(ctx, shipment)
+		if err != nil {
+			return nil, nil, err
+		}
+		return shipment, hold, nil
+	}
+
+	db, _, err := r.GetFragmentConnection("CHUYENTRA", userSite)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to site %s: %w", userSite, err)
+	}
+	copyDB, _, err := r.GetFragmentConnection("QUYENSACH", userSite)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to site %s: %w", userSite, err)
+	}
+
+	var transfer *models.ChuyenTra
+	err = r.ExecuteWithTransaction(ctx, db, func(tx *sql.Tx) error {
+		// The update lock keeps a concurrent check-in of the same copy waiting until this one commits
+		rows, err := tx.QueryContext(ctx, `
+			SELECT `+transferColumns+`
+			FROM CHUYENTRA WITH (UPDLOCK)
+			WHERE MaQuyenSach = ? AND MaCN = ? AND MaCN_NhanSach IS NULL AND TrangThai = ?
+		`, maQuyenSach, userSite, models.TransferInTransit)
+		if err != nil {
+			return fmt.Errorf("failed to find transfer: %w", err)
+		}
+		if rows.Next() {
+			transfer, err = scanChuyenTra(rows)
+		} else if err = rows.Err(); err == nil {
+			err = fmt.Errorf("book copy %s is not on its way to site %s", maQuyenSach, userSite)
+		}
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		_, err = tx.ExecContext(ctx, "UPDATE CHUYENTRA SET TrangThai = ?, NgayNhan = ? WHERE MaCT = ?",
+			models.TransferReceived, now, transfer.MaCT)
+		if err != nil {
+			return fmt.Errorf("failed to update transfer %s: %w", transfer.MaCT, err)
+		}
+
+		// Fragments stored on the same database share one transaction
+		copyTx := tx
+		if copyDB != db {
+			copyTx, err = copyDB.BeginTx(ctx, nil)
+			if err != nil {
+				return fmt.Errorf("failed to begin transaction on site %s: %w", userSite, err)
+			}
+			defer copyTx.Rollback()
+		}
+		result, err := copyTx.ExecContext(ctx, `
+			UPDATE QUYENSACH
+			SET TinhTrang = ?
+			WHERE MaQuyenSach = ? AND MaCN = ? AND TinhTrang = ?
+		`, models.CopyAvailable, maQuyenSach, userSite, models.CopyInTransit)
+		if err != nil {
+			return fmt.Errorf("failed to update book status: %w", err)
+		}
+		if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+			// A return that failed after recording its transfer leaves the copy on loan
+			var status models.CopyStatus
+			if err := copyTx.QueryRowContext(ctx, "SELECT TinhTrang FROM QUYENSACH WHERE MaQuyenSach = ? AND MaCN = ?",
+				maQuyenSach, userSite).Scan(&status); err == nil && status == models.CopyOnLoan {
+				return fmt.Errorf("book copy %s is still on loan: its return at site %s did not complete; return it again there to finish the transfer",
+					maQuyenSach, transfer.MaCNNhanTra)
+			}
+			return fmt.Errorf("book copy %s is not in transit", maQuyenSach)
+		}
+		if copyTx != tx {
+			if err := copyTx.Commit(); err != nil {
+				return fmt.Errorf("failed to commit book copy on site %s: %w", userSite, err)
+			}
+		}
+
+		transfer.TrangThai = models.TransferReceived
+		transfer.NgayNhan = &now
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Book copy %s returned at site %s checked in at site %s (transfer %s)",
+		maQuyenSach, transfer.MaCNNhanTra, userSite, transfer.MaCT)
+
+	// The check-in stands even if the queue cannot be served now; the copy then stays available
+	hold, err := r.holds.AssignCopy(ctx, &models.QuyenSach{
+		MaQuyenSach: maQuyenSach,
+		ISBN:        transfer.ISBN,
+		MaCN:        userSite,
+		TinhTrang:   models.CopyAvailable,
+	})
+	if err != nil {
+		log.Printf("Failed to serve holds on %s with checked-in copy %s: %v", transfer.ISBN, maQuyenSach, err)
+		return transfer, nil, nil
+	}
+	return transfer, hold, nil
+}
+
+// insertTransfer records a transfer in tx, which runs on the CHUYENTRA fragment of the copy's home branch
+func insertTransfer(ctx context.Context, tx *sql.Tx, transfer *models.ChuyenTra) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO CHUYENTRA (MaCT, MaQuyenSach, ISBN, MaCN, MaCN_NhanTra, MaCN_NhanSach, MaDC, TrangThai, NgayNhanTra)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, transfer.MaCT, transfer.MaQuyenSach, transfer.ISBN, transfer.MaCN, transfer.MaCNNhanTra,
+		nullString(transfer.MaCNNhanSach), nullString(transfer.MaDC), transfer.TrangThai, transfer.NgayNhanTra)
+	if err != nil {
+		return fmt.Errorf("failed to record transfer of book copy %s: %w", transfer.MaQuyenSach, err)
+	}
+	return nil
+}
+
+// transfers retrieves the transfers in transit matching a filter, oldest first
+func (r *TransferRepository) transfers(ctx context.Context, filter query.Predicate) ([]*models.ChuyenTra, error) {
+	result, err := query.Merge(ctx, r.Executor(r.siteID), query.Query{
+		Relation: "CHUYENTRA",
+		Select:   "SELECT " + transferColumns + " FROM CHUYENTRA",
+		Filters:  []query.Predicate{filter, query.Eq("TrangThai", models.TransferInTransit)},
+		OrderBy:  "NgayNhanTra, MaCT",
+	}, scanChuyenTra, func(a, b *models.ChuyenTra) bool {
+		if !a.NgayNhanTra.Equal(b.NgayNhanTra) {
+			return a.NgayNhanTra.Before(b.NgayNhanTra)
+		}
+		return a.MaCT < b.MaCT
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transfers: %w", err)
+	}
+	return result.Rows, nil
+}
+
+// scanChuyenTra scans a row of transferColumns into a ChuyenTra model with null handling
+func scanChuyenTra(rows *sql.Rows) (*models.ChuyenTra, error) {
+	var transfer models.ChuyenTra
+	var pickupBranch, maDC sql.NullString
+	var ngayNhan sql.NullTime
+
+	err := rows.Scan(&transfer.MaCT, &transfer.MaQuyenSach, &transfer.ISBN, &transfer.MaCN,
+		&transfer.MaCNNhanTra, &pickupBranch, &maDC, &transfer.TrangThai, &transfer.NgayNhanTra, &ngayNhan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan ChuyenTra: %w", err)
+	}
+
+	transfer.MaCNNhanSach = pickupBranch.String
+	transfer.MaDC = maDC.String
+	if ngayNhan.Valid {
+		transfer.NgayNhan = &ngayNhan.Time
+	}
+	return &transfer, nil
+}
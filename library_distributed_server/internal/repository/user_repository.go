@@ -13,9 +13,9 @@ type UserRepository struct {
 	siteID string // Current site ID for this repository instance
 }
 
-func NewUserRepository(config *config.Config, siteID string) *UserRepository {
+func NewUserRepository(store *config.Store, siteID string) *UserRepository {
 	return &UserRepository{
-		BaseRepository: NewBaseRepository(config),
+		BaseRepository: NewBaseRepository(store),
 		siteID:         siteID,
 	}
 }
@@ -152,7 +152,7 @@ func (r *UserRepository) GetAllDocGia() ([]models.DocGia, error) {
 		return nil, err
 	}
 
-	query := "SELECT MaDG, HoTen, MaCN_DangKy FROM DOCGIA WHERE MaCN_DangKy = ?"
+	query := "SELECT MaDG, HoTen, MaCN_DangKy, LoaiDG FROM DOCGIA WHERE MaCN_DangKy = ?"
 	rows, err := conn.Query(query, r.siteID)
 	if err != nil {
 		return nil, err
@@ -162,7 +162,7 @@ func (r *UserRepository) GetAllDocGia() ([]models.DocGia, error) {
 	var readers []models.DocGia
 	for rows.Next() {
 		var reader models.DocGia
-		err := rows.Scan(&reader.MaDG, &reader.HoTen, &reader.MaCNDangKy)
+		err := rows.Scan(&reader.MaDG, &reader.HoTen, &reader.MaCNDangKy, &reader.LoaiDG)
 		if err != nil {
 			return nil, err
 		}
@@ -183,14 +183,12 @@ func (r *UserRepository) tryDirectGetUser(conn *sql.DB, username string) (*model
 				ELSE 'UNKNOWN'
 			END as Role,
 			CASE 
-				WHEN dp.name = 'ThuThu_Q1' THEN 'Q1'
-				WHEN dp.name = 'ThuThu_Q3' THEN 'Q3'
-				WHEN dp.name = 'QuanLy' THEN ''
+				WHEN dp.name LIKE 'ThuThu[_]%' THEN SUBSTRING(dp.name, 8, LEN(dp.name))
 				ELSE ''
 			END as MaCN
 		FROM sys.database_principals dp
 		WHERE dp.type = 'S' 
-			AND dp.name IN ('ThuThu_Q1', 'ThuThu_Q3', 'QuanLy')
+			AND (dp.name LIKE 'ThuThu[_]%' OR dp.name = 'QuanLy')
 			AND dp.name = ?
 	`
 
@@ -256,7 +254,7 @@ func (r *UserRepository) tryStoredProcedureAuth(conn *sql.DB, username, password
 func (r *UserRepository) tryDirectAuth(username, password string) bool {
 	// Get site config
 	var siteConfig *config.SiteConfig
-	for _, site := range r.config.Sites {
+	for _, site := range r.config().Sites {
 		if site.SiteID == r.siteID {
 			siteConfig = &site
 			break
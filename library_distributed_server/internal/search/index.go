@@ -0,0 +1,260 @@
+// Package search keeps an in-process full-text index of the book catalog (SACH).
+// Titles, authors and ISBNs are folded to lowercase ASCII so that "lap trinh"
+// matches "Lập trình", query words also match as prefixes of indexed words,
+// and matches are ranked with BM25.
+package search
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// BM25 parameters
+const (
+	k1 = 1.2
+	b  = 0.75
+
+	prefixWeight = 0.5 // Share of the score a word earns when it only starts with the query word
+)
+
+// Document is one SACH row as indexed
+type Document struct {
+	ISBN    string
+	TenSach string
+	TacGia  string
+}
+
+// Hit is a book matching a search, with its relevance
+type Hit struct {
+	Document
+	Score float64 // BM25 relevance, higher is better
+}
+
+// Index is an inverted index over the book catalog of one site
+type Index struct {
+	mutex      sync.RWMutex
+	ready      bool
+	docs       map[string]Document       // Indexed documents, by ISBN
+	lengths    map[string]int            // Words per document, by ISBN
+	postings   map[string]map[string]int // Occurrences of a word, by word and ISBN
+	words      []string                  // Indexed words in order, for prefix lookups
+	totalWords int
+}
+
+// NewIndex creates an empty index; it is not ready until the first Replace
+func NewIndex() *Index {
+	return &Index{
+		docs:     make(map[string]Document),
+		lengths:  make(map[string]int),
+		postings: make(map[string]map[string]int),
+	}
+}
+
+// Fold lowercases text and strips Vietnamese diacritics, mapping đ to d
+func Fold(text string) string {
+	var folded strings.Builder
+	for _, r := range norm.NFD.String(text) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			continue
+		case r == 'đ' || r == 'Đ':
+			folded.WriteRune('d')
+		default:
+			folded.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return folded.String()
+}
+
+// Tokenize folds text and splits it into words of letters and digits
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(Fold(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// words returns the indexed words of a document: its title and author, and its ISBN
+// both in parts and without separators so that "978-0-12" and "978012" match
+func (d Document) words() []string {
+	words := Tokenize(d.TenSach + " " + d.TacGia)
+	parts := Tokenize(d.ISBN)
+	words = append(words, parts...)
+	if len(parts) > 1 {
+		words = append(words, strings.Join(parts, ""))
+	}
+	return words
+}
+
+// Ready reports whether the index has been built from the catalog
+func (ix *Index) Ready() bool {
+	ix.mutex.RLock()
+	defer ix.mutex.RUnlock()
+	return ix.ready
+}
+
+// Invalidate marks the index as not ready, e.g. when an update could not be applied
+func (ix *Index) Invalidate() {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+	ix.ready = false
+}
+
+// Replace rebuilds the index from the whole catalog and marks it ready
+func (ix *Index) Replace(docs []Document) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	ix.docs = make(map[string]Document, len(docs))
+	ix.lengths = make(map[string]int, len(docs))
+	ix.postings = make(map[string]map[string]int)
+	ix.words = nil
+	ix.totalWords = 0
+	for _, doc := range docs {
+		ix.add(doc)
+	}
+	ix.ready = true
+}
+
+// Put adds a document or replaces the one with the same ISBN
+func (ix *Index) Put(doc Document) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+	ix.remove(doc.ISBN)
+	ix.add(doc)
+}
+
+// Remove drops the document with the given ISBN
+func (ix *Index) Remove(isbn string) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+	ix.remove(isbn)
+}
+
+// All returns every indexed document in ISBN order
+func (ix *Index) All() []Document {
+	ix.mutex.RLock()
+	defer ix.mutex.RUnlock()
+
+	docs := make([]Document, 0, len(ix.docs))
+	for _, doc := range ix.docs {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ISBN < docs[j].ISBN })
+	return docs
+}
+
+// add indexes a document; callers must hold the mutex
+func (ix *Index) add(doc Document) {
+	words := doc.words()
+	ix.docs[doc.ISBN] = doc
+	ix.lengths[doc.ISBN] = len(words)
+	ix.totalWords += len(words)
+	for _, word := range words {
+		docs, exists := ix.postings[word]
+		if !exists {
+			docs = make(map[string]int)
+			ix.postings[word] = docs
+			i := sort.SearchStrings(ix.words, word)
+			ix.words = append(ix.words, "")
+			copy(ix.words[i+1:], ix.words[i:])
+			ix.words[i] = word
+		}
+		docs[doc.ISBN]++
+	}
+}
+
+// remove unindexes a document; callers must hold the mutex
+func (ix *Index) remove(isbn string) {
+	length, exists := ix.lengths[isbn]
+	if !exists {
+		return
+	}
+	delete(ix.docs, isbn)
+	delete(ix.lengths, isbn)
+	ix.totalWords -= length
+
+	kept := ix.words[:0]
+	for _, word := range ix.words {
+		docs := ix.postings[word]
+		delete(docs, isbn)
+		if len(docs) == 0 {
+			delete(ix.postings, word)
+			continue
+		}
+		kept = append(kept, word)
+	}
+	ix.words = kept
+}
+
+// Search returns the books matching every word of the text, best first, at most limit of them.
+// A query word matches an indexed word equal to it or, at a lower score, starting with it.
+func (ix *Index) Search(text string, limit int) []Hit {
+	queryWords := Tokenize(text)
+	if len(queryWords) == 0 {
+		return nil
+	}
+
+	ix.mutex.RLock()
+	defer ix.mutex.RUnlock()
+
+	n := float64(len(ix.lengths))
+	if n == 0 {
+		return nil
+	}
+	avgLength := float64(ix.totalWords) / n
+
+	var scores map[string]float64
+	for _, q := range queryWords {
+		// Best score of the query word in each document
+		best := make(map[string]float64)
+		for i := sort.SearchStrings(ix.words, q); i < len(ix.words) && strings.HasPrefix(ix.words[i], q); i++ {
+			word := ix.words[i]
+			docs := ix.postings[word]
+			weight := 1.0
+			if word != q {
+				weight = prefixWeight
+			}
+			idf := math.Log(1 + (n-float64(len(docs))+0.5)/(float64(len(docs))+0.5))
+			for isbn, tf := range docs {
+				length := float64(ix.lengths[isbn])
+				score := weight * idf * float64(tf) * (k1 + 1) / (float64(tf) + k1*(1-b+b*length/avgLength))
+				if score > best[isbn] {
+					best[isbn] = score
+				}
+			}
+		}
+
+		// Keep only documents matching every query word so far
+		if scores == nil {
+			scores = best
+			continue
+		}
+		for isbn, score := range scores {
+			if s, matched := best[isbn]; matched {
+				scores[isbn] = score + s
+			} else {
+				delete(scores, isbn)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for isbn, score := range scores {
+		hits = append(hits, Hit{Document: ix.docs[isbn], Score: math.Round(score*1000) / 1000})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ISBN < hits[j].ISBN
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
@@ -0,0 +1,154 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Lập trình", "lap trinh"},
+		{"Đường Đời", "duong doi"},
+		{"Nguyễn Nhật Ánh", "nguyen nhat anh"},
+		{"Tiếng Việt", "tieng viet"},
+		{"La\u0323\u0302p tri\u0300nh", "lap trinh"}, // Precomposed and combining marks fold alike
+		{"Go 1.23", "go 1.23"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Fold(tt.text); got != tt.want {
+			t.Errorf("Fold(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Lập trình Go", []string{"lap", "trinh", "go"}},
+		{"Dế Mèn phiêu lưu ký!", []string{"de", "men", "phieu", "luu", "ky"}},
+		{"978-604-1-00000-1", []string{"978", "604", "1", "00000", "1"}},
+		{"  -- ", []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := Tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func testIndex() *Index {
+	ix := NewIndex()
+	ix.Replace([]Document{
+		{ISBN: "978-604-1", TenSach: "Lập trình Go", TacGia: "Nguyễn Văn An"},
+		{ISBN: "978-604-2", TenSach: "Lập trình Go nâng cao: Go trong hệ phân tán", TacGia: "Trần Bình"},
+		{ISBN: "978-604-3", TenSach: "Cơ sở dữ liệu phân tán", TacGia: "Lê Đức"},
+		{ISBN: "978-604-4", TenSach: "Lập trình hướng đối tượng", TacGia: "Nguyễn Văn An"},
+		{ISBN: "978-604-5", TenSach: "Dế Mèn phiêu lưu ký", TacGia: "Tô Hoài"},
+	})
+	return ix
+}
+
+func hitISBNs(hits []Hit) []string {
+	var isbns []string
+	for _, hit := range hits {
+		isbns = append(isbns, hit.ISBN)
+	}
+	return isbns
+}
+
+func TestSearch(t *testing.T) {
+	ix := testIndex()
+
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []string
+	}{
+		{"without diacritics", "lap trinh", 0, []string{"978-604-1", "978-604-4", "978-604-2"}},
+		{"with diacritics", "Phân tán", 0, []string{"978-604-3", "978-604-2"}},
+		{"every word must match", "lap trinh go", 0, []string{"978-604-1", "978-604-2"}},
+		{"repeated word ranks higher", "go", 0, []string{"978-604-2", "978-604-1"}},
+		{"author", "duc", 0, []string{"978-604-3"}},
+		{"đ folds to d", "Đức", 0, []string{"978-604-3"}},
+		{"exact word before prefix", "an", 0, []string{"978-604-1", "978-604-4"}},
+		{"prefix", "phie", 0, []string{"978-604-5"}},
+		{"isbn in parts", "978 604 5", 0, []string{"978-604-5"}},
+		{"isbn without separators", "9786045", 0, []string{"978-604-5"}},
+		{"limit", "lap trinh", 2, []string{"978-604-1", "978-604-4"}},
+		{"no match", "python", 0, nil},
+		{"empty query", " - ", 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hitISBNs(ix.Search(tt.query, tt.limit)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchPrefixScoresLower(t *testing.T) {
+	ix := NewIndex()
+	ix.Replace([]Document{
+		{ISBN: "1", TenSach: "Toán"},
+		{ISBN: "2", TenSach: "Toàn tập"},
+		{ISBN: "3", TenSach: "Toán học"},
+	})
+
+	exact := ix.Search("toan", 0)
+	if got := hitISBNs(exact); !reflect.DeepEqual(got, []string{"1", "2", "3"}) {
+		t.Fatalf("Search(toan) = %v, want [1 2 3]", got)
+	}
+
+	for i, hit := range ix.Search("toa", 0) {
+		if hit.ISBN != exact[i].ISBN || hit.Score >= exact[i].Score {
+			t.Errorf("prefix match %s scores %v, want it ranked like %s and below its exact score %v", hit.ISBN, hit.Score, exact[i].ISBN, exact[i].Score)
+		}
+	}
+}
+
+func TestIndexUpdates(t *testing.T) {
+	ix := NewIndex()
+	if ix.Ready() {
+		t.Fatal("new index is ready")
+	}
+	if hits := ix.Search("go", 0); hits != nil {
+		t.Errorf("empty index returned %v", hits)
+	}
+
+	ix = testIndex()
+	if !ix.Ready() {
+		t.Fatal("index is not ready after Replace")
+	}
+
+	ix.Put(Document{ISBN: "978-604-1", TenSach: "Học Rust", TacGia: "Nguyễn Văn An"})
+	if got := hitISBNs(ix.Search("lap trinh go", 0)); !reflect.DeepEqual(got, []string{"978-604-2"}) {
+		t.Errorf("after Put, Search(lap trinh go) = %v, want [978-604-2]", got)
+	}
+	if got := hitISBNs(ix.Search("rust", 0)); !reflect.DeepEqual(got, []string{"978-604-1"}) {
+		t.Errorf("after Put, Search(rust) = %v, want [978-604-1]", got)
+	}
+
+	ix.Remove("978-604-5")
+	if hits := ix.Search("de men", 0); len(hits) != 0 {
+		t.Errorf("after Remove, Search(de men) = %v", hitISBNs(hits))
+	}
+	if got := len(ix.All()); got != 4 {
+		t.Errorf("All returned %d documents, want 4", got)
+	}
+
+	ix.Invalidate()
+	if ix.Ready() {
+		t.Error("index is ready after Invalidate")
+	}
+}
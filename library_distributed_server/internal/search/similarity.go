@@ -0,0 +1,65 @@
+package search
+
+import "strings"
+
+// Similarity scores how closely text matches a query that may be partial or misspelt,
+// from 0 to 1. Each query word is scored against its closest word of the text and the
+// scores are averaged: a word starting with the query word scores 1, one containing it
+// 0.9, and any other word one minus their edit distance over the longer length.
+func Similarity(query, text string) float64 {
+	queryWords := Tokenize(query)
+	words := Tokenize(text)
+	if len(queryWords) == 0 || len(words) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, q := range queryWords {
+		best := 0.0
+		for _, word := range words {
+			if s := wordSimilarity(q, word); s > best {
+				best = s
+			}
+		}
+		total += best
+	}
+	return total / float64(len(queryWords))
+}
+
+// wordSimilarity scores a folded query word against a folded word
+func wordSimilarity(q, word string) float64 {
+	switch {
+	case strings.HasPrefix(word, q):
+		return 1
+	case len(q) >= 3 && strings.Contains(word, q):
+		return 0.9
+	}
+
+	a, b := []rune(q), []rune(word)
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	return 1 - float64(editDistance(a, b))/float64(longest)
+}
+
+// editDistance is the Levenshtein distance between two words
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
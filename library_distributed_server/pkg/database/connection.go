@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
@@ -10,9 +11,18 @@ import (
 	_ "github.com/denisenkom/go-mssqldb"
 )
 
+// connectTimeout bounds the initial ping of a new connection. Sites that stay
+// unreachable are reported by the membership failure detector instead of retried here.
+const connectTimeout = 3 * time.Second
+
+// drainDelay is how long a replaced connection stays open for requests that already hold it
+const drainDelay = 30 * time.Second
+
 type ConnectionPool struct {
-	connections map[string]*sql.DB
-	mutex       sync.RWMutex
+	connections  map[string]*sql.DB
+	dsns         map[string]string // Connection string each cached connection was opened with
+	availability func(siteID string) error
+	mutex        sync.RWMutex
 }
 
 var (
@@ -24,14 +34,29 @@ func GetPool() *ConnectionPool {
 	once.Do(func() {
 		pool = &ConnectionPool{
 			connections: make(map[string]*sql.DB),
+			dsns:        make(map[string]string),
 		}
 	})
 	return pool
 }
 
+// SetAvailabilityCheck installs a check consulted before handing out a connection,
+// so requests to a site known to be down fail immediately
+func (cp *ConnectionPool) SetAvailabilityCheck(check func(siteID string) error) {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+	cp.availability = check
+}
+
 func (cp *ConnectionPool) GetConnection(siteID string, connectionString string) (*sql.DB, error) {
 	cp.mutex.RLock()
-	if conn, exists := cp.connections[siteID]; exists {
+	if cp.availability != nil {
+		if err := cp.availability(siteID); err != nil {
+			cp.mutex.RUnlock()
+			return nil, err
+		}
+	}
+	if conn, exists := cp.connections[siteID]; exists && cp.dsns[siteID] == connectionString {
 		cp.mutex.RUnlock()
 		return conn, nil
 	}
@@ -41,7 +66,12 @@ func (cp *ConnectionPool) GetConnection(siteID string, connectionString string)
 	defer cp.mutex.Unlock()
 
 	if conn, exists := cp.connections[siteID]; exists {
-		return conn, nil
+		if cp.dsns[siteID] == connectionString {
+			return conn, nil
+		}
+		// The site's connection settings changed on a configuration reload
+		log.Printf("Connection settings for site %s changed, reconnecting", siteID)
+		cp.drain(siteID)
 	}
 
 	db, err := sql.Open("mssql", connectionString)
@@ -53,24 +83,48 @@ func (cp *ConnectionPool) GetConnection(siteID string, connectionString string)
 	db.SetMaxIdleConns(5)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	if err := testConnectionWithRetry(db, 3); err != nil {
+	if err := testConnection(db, connectTimeout); err != nil {
 		db.Close()
 		return nil, fmt.Errorf("failed to connect to site %s: %w", siteID, err)
 	}
 
 	cp.connections[siteID] = db
+	cp.dsns[siteID] = connectionString
 	log.Printf("Successfully connected to site %s", siteID)
 	return db, nil
 }
 
-func testConnectionWithRetry(db *sql.DB, maxRetries int) error {
-	for i := 0; i < maxRetries; i++ {
-		if err := db.Ping(); err == nil {
-			return nil
+// Drain removes the cached connection of a site, closing it once in-flight requests had time to finish
+func (cp *ConnectionPool) Drain(siteID string) {
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+	cp.drain(siteID)
+}
+
+// drain removes a cached connection; callers must hold the write lock
+func (cp *ConnectionPool) drain(siteID string) {
+	conn, exists := cp.connections[siteID]
+	if !exists {
+		return
+	}
+	delete(cp.connections, siteID)
+	delete(cp.dsns, siteID)
+
+	time.AfterFunc(drainDelay, func() {
+		if err := conn.Close(); err != nil {
+			log.Printf("Error closing drained connection to site %s: %v", siteID, err)
 		}
-		time.Sleep(time.Duration(i+1) * time.Second)
+		log.Printf("Drained old connection to site %s", siteID)
+	})
+}
+
+func testConnection(db *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("connection failed: %w", err)
 	}
-	return fmt.Errorf("connection failed after %d retries", maxRetries)
+	return nil
 }
 
 func (cp *ConnectionPool) CloseAll() {
@@ -83,4 +137,5 @@ func (cp *ConnectionPool) CloseAll() {
 		}
 	}
 	cp.connections = make(map[string]*sql.DB)
+	cp.dsns = make(map[string]string)
 }
@@ -0,0 +1,472 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// Migration is one idempotent schema change applied to a branch database.
+// Statements are generated per site because fragment CHECK constraints embed the site ID.
+type Migration struct {
+	ID          string
+	Description string
+	Statements  func(siteID string) []string
+}
+
+var siteIDPattern = regexp.MustCompile(`^[A-Za-z0-9_]{1,10}$`)
+
+// ValidateSiteID checks that a site ID is safe to embed in DDL and fits MaCN VARCHAR(10)
+func ValidateSiteID(siteID string) error {
+	if !siteIDPattern.MatchString(siteID) {
+		return fmt.Errorf("invalid site ID %q: expected 1-10 letters, digits or underscores", siteID)
+	}
+	return nil
+}
+
+// migrations lists the branch schema in order. Statements must be safe to re-run,
+// since databases created from docs/migration_script_*.sql already contain the base schema.
+var migrations = []Migration{
+	{
+		ID:          "0001_base_schema",
+		Description: "Replicated tables CHINHANH, SACH and fragments DOCGIA, QUYENSACH, PHIEUMUON",
+		Statements: func(siteID string) []string {
+			return []string{
+				`IF NOT EXISTS (SELECT * FROM sys.tables WHERE name = 'CHINHANH')
+				CREATE TABLE CHINHANH (
+					MaCN VARCHAR(10) PRIMARY KEY,
+					TenCN NVARCHAR(255) NOT NULL,
+					DiaChi NVARCHAR(255) NOT NULL
+				)`,
+				`IF NOT EXISTS (SELECT * FROM sys.tables WHERE name = 'SACH')
+				CREATE TABLE SACH (
+					ISBN VARCHAR(20) PRIMARY KEY,
+					TenSach NVARCHAR(255) NOT NULL,
+					TacGia NVARCHAR(255) NOT NULL
+				)`,
+				fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sys.tables WHERE name = 'QUYENSACH')
+				CREATE TABLE QUYENSACH (
+					MaQuyenSach VARCHAR(20) PRIMARY KEY,
+					ISBN VARCHAR(20) NOT NULL,
+					MaCN VARCHAR(10) NOT NULL,
+					TinhTrang NVARCHAR(50) NOT NULL DEFAULT N'Có sẵn',
+					FOREIGN KEY (ISBN) REFERENCES SACH(ISBN),
+					FOREIGN KEY (MaCN) REFERENCES CHINHANH(MaCN),
+					CONSTRAINT CHK_QuyenSach_MaCN CHECK (MaCN = '%s'),
+					CONSTRAINT CHK_QuyenSach_TinhTrang CHECK (TinhTrang IN (N'Có sẵn', N'Đang được mượn'))
+				)`, siteID),
+				fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sys.tables WHERE name = 'DOCGIA')
+				CREATE TABLE DOCGIA (
+					MaDG VARCHAR(10) PRIMARY KEY,
+					HoTen NVARCHAR(255) NOT NULL,
+					MaCN_DangKy VARCHAR(10) NOT NULL,
+					FOREIGN KEY (MaCN_DangKy) REFERENCES CHINHANH(MaCN),
+					CONSTRAINT CHK_DocGia_MaCN CHECK (MaCN_DangKy = '%s')
+				)`, siteID),
+				fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sys.tables WHERE name = 'PHIEUMUON')
+				CREATE TABLE PHIEUMUON (
+					MaPM INT IDENTITY(1,1) PRIMARY KEY,
+					MaDG VARCHAR(10) NOT NULL,
+					MaQuyenSach VARCHAR(20) NOT NULL,
+					MaCN VARCHAR(10) NOT NULL,
+					NgayMuon DATETIME NOT NULL DEFAULT GETDATE(),
+					NgayTra DATETIME NULL,
+					FOREIGN KEY (MaDG) REFERENCES DOCGIA(MaDG),
+					FOREIGN KEY (MaQuyenSach) REFERENCES QUYENSACH(MaQuyenSach),
+					FOREIGN KEY (MaCN) REFERENCES CHINHANH(MaCN),
+					CONSTRAINT CHK_PhieuMuon_MaCN CHECK (MaCN = '%s')
+				)`, siteID),
+			}
+		},
+	},
+	{
+		ID:          "0002_holds",
+		Description: "Fragment DATCHO (hold queue) and the on-hold copy status",
+		Statements: func(siteID string) []string {
+			return []string{
+				`IF OBJECT_ID('CHK_QuyenSach_TinhTrang', 'C') IS NOT NULL
+				ALTER TABLE QUYENSACH DROP CONSTRAINT CHK_QuyenSach_TinhTrang`,
+				`ALTER TABLE QUYENSACH ADD CONSTRAINT CHK_QuyenSach_TinhTrang
+				CHECK (TinhTrang IN (N'Có sẵn', N'Đang được mượn', N'Đang giữ chỗ'))`,
+				fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sys.tables WHERE name = 'DATCHO')
+				CREATE TABLE DATCHO (
+					MaDC VARCHAR(30) PRIMARY KEY,
+					MaDG VARCHAR(10) NOT NULL,
+					ISBN VARCHAR(20) NOT NULL,
+					MaCN VARCHAR(10) NOT NULL,
+					MaCN_NhanSach VARCHAR(10) NULL,
+					NgayDat DATETIME NOT NULL DEFAULT GETDATE(),
+					TrangThai NVARCHAR(50) NOT NULL DEFAULT N'Đang chờ',
+					MaQuyenSach VARCHAR(20) NULL,
+					MaCN_QuyenSach VARCHAR(10) NULL,
+					HanNhan DATETIME NULL,
+					FOREIGN KEY (MaDG) REFERENCES DOCGIA(MaDG),
+					FOREIGN KEY (ISBN) REFERENCES SACH(ISBN),
+					FOREIGN KEY (MaCN) REFERENCES CHINHANH(MaCN),
+					FOREIGN KEY (MaCN_NhanSach) REFERENCES CHINHANH(MaCN),
+					CONSTRAINT CHK_DatCho_MaCN CHECK (MaCN = '%s'),
+					CONSTRAINT CHK_DatCho_TrangThai CHECK (TrangThai IN (N'Đang chờ', N'Sẵn sàng', N'Đã nhận', N'Hết hạn', N'Đã hủy'))
+				)`, siteID),
+				`IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'IX_DatCho_ISBN_TrangThai')
+				CREATE INDEX IX_DatCho_ISBN_TrangThai ON DATCHO (ISBN, TrangThai, NgayDat)`,
+			}
+		},
+	},
+	{
+		ID:          "0003_cross_branch_loans",
+		Description: "Drop the PHIEUMUON to DOCGIA foreign key so branches lend to readers registered elsewhere",
+		Statements: func(siteID string) []string {
+			return []string{
+				// The constraint was created unnamed, so its generated name is looked up
+				`DECLARE @fk SYSNAME
+				SELECT @fk = fk.name
+				FROM sys.foreign_keys fk
+				JOIN sys.foreign_key_columns fkc ON fkc.constraint_object_id = fk.object_id
+				JOIN sys.columns c ON c.object_id = fkc.parent_object_id AND c.column_id = fkc.parent_column_id
+				WHERE fk.parent_object_id = OBJECT_ID('PHIEUMUON')
+					AND fk.referenced_object_id = OBJECT_ID('DOCGIA')
+					AND c.name = 'MaDG'
+				IF @fk IS NOT NULL
+					EXEC('ALTER TABLE PHIEUMUON DROP CONSTRAINT ' + QUOTENAME(@fk))`,
+				`IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'IX_PhieuMuon_MaDG')
+				CREATE INDEX IX_PhieuMuon_MaDG ON PHIEUMUON (MaDG, NgayTra)`,
+			}
+		},
+	},
+	{
+		ID:          "0004_loan_due_dates",
+		Description: "Stored due date and renewal count on PHIEUMUON",
+		Statements: func(siteID string) []string {
+			return []string{
+				`IF COL_LENGTH('PHIEUMUON', 'HanTra') IS NULL
+				ALTER TABLE PHIEUMUON ADD HanTra DATETIME NULL`,
+				`IF COL_LENGTH('PHIEUMUON', 'SoLanGiaHan') IS NULL
+				ALTER TABLE PHIEUMUON ADD SoLanGiaHan INT NOT NULL CONSTRAINT DF_PhieuMuon_SoLanGiaHan DEFAULT 0`,
+				// Loans made before due dates were stored had the 30-day period
+				`UPDATE PHIEUMUON SET HanTra = DATEADD(day, 30, NgayMuon) WHERE HanTra IS NULL`,
+				`ALTER TABLE PHIEUMUON ALTER COLUMN HanTra DATETIME NOT NULL`,
+			}
+		},
+	},
+	{
+		ID:          "0005_fines",
+		Description: "Fragments PHAT (overdue fines) and GIAODICHPHAT (payments and waivers)",
+		Statements: func(siteID string) []string {
+			return []string{
+				// A fine identifies its loan by copy and dates rather than MaPM, which relocation renumbers
+				fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sys.tables WHERE name = 'PHAT')
+				CREATE TABLE PHAT (
+					MaPhat VARCHAR(30) PRIMARY KEY,
+					MaDG VARCHAR(10) NOT NULL,
+					MaQuyenSach VARCHAR(20) NOT NULL,
+					MaCN VARCHAR(10) NOT NULL,
+					NgayMuon DATETIME NOT NULL,
+					HanTra DATETIME NOT NULL,
+					NgayTra DATETIME NOT NULL,
+					SoNgayQuaHan INT NOT NULL,
+					MucPhat INT NOT NULL,
+					SoTien INT NOT NULL,
+					DaThanhToan INT NOT NULL DEFAULT 0,
+					DaMien INT NOT NULL DEFAULT 0,
+					TrangThai NVARCHAR(50) NOT NULL DEFAULT N'Chưa thanh toán',
+					NgayTao DATETIME NOT NULL DEFAULT GETDATE(),
+					FOREIGN KEY (MaCN) REFERENCES CHINHANH(MaCN),
+					CONSTRAINT CHK_Phat_MaCN CHECK (MaCN = '%s'),
+					CONSTRAINT CHK_Phat_SoTien CHECK (DaThanhToan >= 0 AND DaMien >= 0 AND DaThanhToan + DaMien <= SoTien),
+					CONSTRAINT CHK_Phat_TrangThai CHECK (TrangThai IN (N'Chưa thanh toán', N'Đã thanh toán', N'Đã miễn'))
+				)`, siteID),
+				`IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'IX_Phat_MaDG')
+				CREATE INDEX IX_Phat_MaDG ON PHAT (MaDG, TrangThai)`,
+				fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sys.tables WHERE name = 'GIAODICHPHAT')
+				CREATE TABLE GIAODICHPHAT (
+					MaGD VARCHAR(30) PRIMARY KEY,
+					MaPhat VARCHAR(30) NOT NULL,
+					MaCN VARCHAR(10) NOT NULL,
+					Loai NVARCHAR(20) NOT NULL,
+					SoTien INT NOT NULL,
+					NgayGD DATETIME NOT NULL DEFAULT GETDATE(),
+					NguoiThucHien VARCHAR(50) NOT NULL,
+					GhiChu NVARCHAR(255) NULL,
+					FOREIGN KEY (MaPhat) REFERENCES PHAT(MaPhat),
+					FOREIGN KEY (MaCN) REFERENCES CHINHANH(MaCN),
+					CONSTRAINT CHK_GiaoDichPhat_MaCN CHECK (MaCN = '%s'),
+					CONSTRAINT CHK_GiaoDichPhat_Loai CHECK (Loai IN (N'Thanh toán', N'Miễn')),
+					CONSTRAINT CHK_GiaoDichPhat_SoTien CHECK (SoTien > 0)
+				)`, siteID),
+				`IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'IX_GiaoDichPhat_MaPhat')
+				CREATE INDEX IX_GiaoDichPhat_MaPhat ON GIAODICHPHAT (MaPhat)`,
+			}
+		},
+	},
+	{
+		ID:          "0006_circulation_policies",
+		Description: "Adds DOCGIA.LoaiDG (reader category) and replicates CHINHSACH (circulation policies)",
+		Statements: func(siteID string) []string {
+			return []string{
+				`IF COL_LENGTH('DOCGIA', 'LoaiDG') IS NULL
+				ALTER TABLE DOCGIA ADD LoaiDG NVARCHAR(50) NOT NULL CONSTRAINT DF_DocGia_LoaiDG DEFAULT N'Thường'`,
+				// A NULL branch or category makes the policy apply to every branch or category
+				`IF NOT EXISTS (SELECT * FROM sys.tables WHERE name = 'CHINHSACH')
+				CREATE TABLE CHINHSACH (
+					MaCS VARCHAR(30) PRIMARY KEY,
+					MaCN VARCHAR(10) NULL,
+					LoaiDG NVARCHAR(50) NULL,
+					SoNgayMuon INT NOT NULL,
+					SoSachToiDa INT NOT NULL,
+					SoLanGiaHan INT NOT NULL,
+					MucPhat INT NOT NULL,
+					SoNgayAnHan INT NOT NULL DEFAULT 0,
+					FOREIGN KEY (MaCN) REFERENCES CHINHANH(MaCN),
+					CONSTRAINT UQ_ChinhSach UNIQUE (MaCN, LoaiDG),
+					CONSTRAINT CHK_ChinhSach_GioiHan CHECK (SoNgayMuon > 0 AND SoSachToiDa >= 0 AND SoLanGiaHan >= 0 AND MucPhat >= 0 AND SoNgayAnHan >= 0)
+				)`,
+			}
+		},
+	},
+	{
+		ID:          "0007_interlibrary_loans",
+		Description: "Fragment YEUCAUMUON (inter-library loan requests), the in-transit copy statuses and loans of copies owned by other branches",
+		Statements: func(siteID string) []string {
+			return []string{
+				`IF OBJECT_ID('CHK_QuyenSach_TinhTrang', 'C') IS NOT NULL
+				ALTER TABLE QUYENSACH DROP CONSTRAINT CHK_QuyenSach_TinhTrang`,
+				`ALTER TABLE QUYENSACH ADD CONSTRAINT CHK_QuyenSach_TinhTrang
+				CHECK (TinhTrang IN (N'Có sẵn', N'Đang được mượn', N'Đang giữ chỗ', N'Đang vận chuyển', N'Mượn liên thư viện'))`,
+				// A copy lent through an inter-library loan stays in its owner's QUYENSACH fragment
+				`DECLARE @fk SYSNAME
+				SELECT @fk = fk.name
+				FROM sys.foreign_keys fk
+				JOIN sys.foreign_key_columns fkc ON fkc.constraint_object_id = fk.object_id
+				JOIN sys.columns c ON c.object_id = fkc.parent_object_id AND c.column_id = fkc.parent_column_id
+				WHERE fk.parent_object_id = OBJECT_ID('PHIEUMUON')
+					AND fk.referenced_object_id = OBJECT_ID('QUYENSACH')
+					AND c.name = 'MaQuyenSach'
+				IF @fk IS NOT NULL
+					EXEC('ALTER TABLE PHIEUMUON DROP CONSTRAINT ' + QUOTENAME(@fk))`,
+				`IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'IX_PhieuMuon_MaQuyenSach')
+				CREATE INDEX IX_PhieuMuon_MaQuyenSach ON PHIEUMUON (MaQuyenSach, NgayTra)`,
+				// Stored at the requesting branch; the owning branch finds its requests by MaCN_SoHuu
+				fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sys.tables WHERE name = 'YEUCAUMUON')
+				CREATE TABLE YEUCAUMUON (
+					MaYC VARCHAR(30) PRIMARY KEY,
+					MaDG VARCHAR(10) NOT NULL,
+					ISBN VARCHAR(20) NOT NULL,
+					MaCN VARCHAR(10) NOT NULL,
+					MaCN_SoHuu VARCHAR(10) NOT NULL,
+					MaQuyenSach VARCHAR(20) NULL,
+					TrangThai NVARCHAR(50) NOT NULL DEFAULT N'Đã yêu cầu',
+					NgayYeuCau DATETIME NOT NULL DEFAULT GETDATE(),
+					NgayCapNhat DATETIME NOT NULL DEFAULT GETDATE(),
+					FOREIGN KEY (ISBN) REFERENCES SACH(ISBN),
+					FOREIGN KEY (MaCN) REFERENCES CHINHANH(MaCN),
+					FOREIGN KEY (MaCN_SoHuu) REFERENCES CHINHANH(MaCN),
+					CONSTRAINT CHK_YeuCauMuon_MaCN CHECK (MaCN = '%s'),
+					CONSTRAINT CHK_YeuCauMuon_TrangThai CHECK (TrangThai IN (N'Đã yêu cầu', N'Đang chuyển đến', N'Đã đến', N'Đang cho mượn', N'Đang trả về', N'Hoàn tất', N'Đã hủy'))
+				)`, siteID),
+				`IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'IX_YeuCauMuon_MaCN_SoHuu')
+				CREATE INDEX IX_YeuCauMuon_MaCN_SoHuu ON YEUCAUMUON (MaCN_SoHuu, TrangThai)`,
+				`IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'IX_YeuCauMuon_MaQuyenSach')
+				CREATE INDEX IX_YeuCauMuon_MaQuyenSach ON YEUCAUMUON (MaQuyenSach)`,
+			}
+		},
+	},
+	{
+		ID:          "0008_return_transfers",
+		Description: "Fragment CHUYENTRA (copies returned at another branch, shipped back to their own)",
+		Statements: func(siteID string) []string {
+			return []string{
+				// Stored with the copy at its home branch; the branch that took the return finds its shipments by MaCN_NhanTra
+				fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sys.tables WHERE name = 'CHUYENTRA')
+				CREATE TABLE CHUYENTRA (
+					MaCT VARCHAR(30) PRIMARY KEY,
+					MaQuyenSach VARCHAR(20) NOT NULL,
+					ISBN VARCHAR(20) NOT NULL,
+					MaCN VARCHAR(10) NOT NULL,
+					MaCN_NhanTra VARCHAR(10) NOT NULL,
+					TrangThai NVARCHAR(50) NOT NULL DEFAULT N'Đang vận chuyển',
+					NgayNhanTra DATETIME NOT NULL DEFAULT GETDATE(),
+					NgayNhan DATETIME NULL,
+					FOREIGN KEY (ISBN) REFERENCES SACH(ISBN),
+					FOREIGN KEY (MaCN) REFERENCES CHINHANH(MaCN),
+					FOREIGN KEY (MaCN_NhanTra) REFERENCES CHINHANH(MaCN),
+					CONSTRAINT CHK_ChuyenTra_MaCN CHECK (MaCN = '%s'),
+					CONSTRAINT CHK_ChuyenTra_TrangThai CHECK (TrangThai IN (N'Đang vận chuyển', N'Đã nhận'))
+				)`, siteID),
+				`IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'IX_ChuyenTra_MaCN_NhanTra')
+				CREATE INDEX IX_ChuyenTra_MaCN_NhanTra ON CHUYENTRA (MaCN_NhanTra, TrangThai)`,
+				`IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'IX_ChuyenTra_MaQuyenSach')
+				CREATE INDEX IX_ChuyenTra_MaQuyenSach ON CHUYENTRA (MaQuyenSach, TrangThai)`,
+			}
+		},
+	},
+	{
+		ID:          "0009_copy_status_lifecycle",
+		Description: "Copy statuses for damaged, in repair, lost and withdrawn copies, with existing statuses normalised",
+		Statements: func(siteID string) []string {
+			return []string{
+				`IF OBJECT_ID('CHK_QuyenSach_TinhTrang', 'C') IS NOT NULL
+				ALTER TABLE QUYENSACH DROP CONSTRAINT CHK_QuyenSach_TinhTrang`,
+				`UPDATE QUYENSACH SET TinhTrang = LTRIM(RTRIM(TinhTrang)) WHERE TinhTrang <> LTRIM(RTRIM(TinhTrang))`,
+				`UPDATE QUYENSACH SET TinhTrang = N'Đang được mượn' WHERE TinhTrang IN (N'Đang mượn', N'Đã mượn')`,
+				`UPDATE QUYENSACH SET TinhTrang = N'Bị hỏng' WHERE TinhTrang IN (N'Hỏng', N'Hư hỏng', N'Bị hư')`,
+				`UPDATE QUYENSACH SET TinhTrang = N'Bị mất' WHERE TinhTrang IN (N'Mất', N'Đã mất')`,
+				// A copy left locked by a book transfer that never completed is back on its shelf
+				`UPDATE QUYENSACH SET TinhTrang = N'Có sẵn' WHERE TinhTrang = N'Đang chuyển'`,
+				// Any other status is replaced by what the loans of the branch say about the copy
+				`UPDATE qs
+				SET TinhTrang = CASE
+					WHEN EXISTS (SELECT 1 FROM PHIEUMUON pm WHERE pm.MaQuyenSach = qs.MaQuyenSach AND pm.NgayTra IS NULL)
+					THEN N'Đang được mượn' ELSE N'Có sẵn' END
+				FROM QUYENSACH qs
+				WHERE qs.TinhTrang NOT IN (N'Có sẵn', N'Đang được mượn', N'Đang giữ chỗ', N'Đang vận chuyển', N'Mượn liên thư viện',
+					N'Bị hỏng', N'Đang sửa chữa', N'Bị mất', N'Đã thanh lý')`,
+				`ALTER TABLE QUYENSACH ADD CONSTRAINT CHK_QuyenSach_TinhTrang
+				CHECK (TinhTrang IN (N'Có sẵn', N'Đang được mượn', N'Đang giữ chỗ', N'Đang vận chuyển', N'Mượn liên thư viện',
+					N'Bị hỏng', N'Đang sửa chữa', N'Bị mất', N'Đã thanh lý'))`,
+			}
+		},
+	},
+	{
+		ID:          "0010_fragment_key_indexes",
+		Description: "Indexes on the fragment key of every horizontal relation, so a relocation locks only its branch's rows",
+		Statements: func(siteID string) []string {
+			var stmts []string
+			for _, key := range fragmentKeys {
+				index := fmt.Sprintf("IX_%s_%s", key.relation, key.column)
+				stmts = append(stmts, fmt.Sprintf(`IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = '%s')
+				CREATE INDEX %s ON %s (%s)`, index, index, key.relation, key.column))
+			}
+			return stmts
+		},
+	},
+	{
+		ID:          "0011_hold_shipments",
+		Description: "Holds waiting for a copy shipped to their pickup branch, and the CHUYENTRA columns recording such shipments",
+		Statements: func(siteID string) []string {
+			return []string{
+				`IF OBJECT_ID('CHK_DatCho_TrangThai', 'C') IS NOT NULL
+				ALTER TABLE DATCHO DROP CONSTRAINT CHK_DatCho_TrangThai`,
+				`ALTER TABLE DATCHO ADD CONSTRAINT CHK_DatCho_TrangThai
+				CHECK (TrangThai IN (N'Đang chờ', N'Đang vận chuyển', N'Sẵn sàng', N'Đã nhận', N'Hết hạn', N'Đã hủy'))`,
+				// A shipment for a hold is stored with the copy like a return transfer, and checked in at MaCN_NhanSach
+				`IF COL_LENGTH('CHUYENTRA', 'MaCN_NhanSach') IS NULL
+				ALTER TABLE CHUYENTRA ADD MaCN_NhanSach VARCHAR(10) NULL
+					CONSTRAINT FK_ChuyenTra_MaCN_NhanSach FOREIGN KEY REFERENCES CHINHANH(MaCN)`,
+				`IF COL_LENGTH('CHUYENTRA', 'MaDC') IS NULL
+				ALTER TABLE CHUYENTRA ADD MaDC VARCHAR(30) NULL`,
+				`IF NOT EXISTS (SELECT * FROM sys.indexes WHERE name = 'IX_ChuyenTra_MaCN_NhanSach')
+				CREATE INDEX IX_ChuyenTra_MaCN_NhanSach ON CHUYENTRA (MaCN_NhanSach, TrangThai)`,
+			}
+		},
+	},
+}
+
+// fragmentKeys lists the fragment key column of each horizontal relation
+var fragmentKeys = []struct{ relation, column string }{
+	{"DOCGIA", "MaCN_DangKy"},
+	{"QUYENSACH", "MaCN"},
+	{"PHIEUMUON", "MaCN"},
+	{"DATCHO", "MaCN"},
+	{"PHAT", "MaCN"},
+	{"GIAODICHPHAT", "MaCN"},
+	{"YEUCAUMUON", "MaCN"},
+	{"CHUYENTRA", "MaCN"},
+}
+
+// Migrate brings a branch database up to date, recording applied migrations in SCHEMA_MIGRATIONS
+func Migrate(db *sql.DB, siteID string) error {
+	if err := ValidateSiteID(siteID); err != nil {
+		return err
+	}
+
+	_, err := db.Exec(`
+		IF NOT EXISTS (SELECT * FROM sys.tables WHERE name = 'SCHEMA_MIGRATIONS')
+		CREATE TABLE SCHEMA_MIGRATIONS (
+			ID VARCHAR(100) PRIMARY KEY,
+			Description NVARCHAR(255) NOT NULL,
+			AppliedAt DATETIME NOT NULL DEFAULT GETDATE()
+		)`)
+	if err != nil {
+		return fmt.Errorf("failed to create SCHEMA_MIGRATIONS on site %s: %w", siteID, err)
+	}
+
+	for _, m := range migrations {
+		var exists int
+		err := db.QueryRow("SELECT COUNT(*) FROM SCHEMA_MIGRATIONS WHERE ID = ?", m.ID).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("failed to check migration %s on site %s: %w", m.ID, siteID, err)
+		}
+		if exists > 0 {
+			continue
+		}
+
+		if err := applyMigration(db, siteID, m); err != nil {
+			return err
+		}
+		log.Printf("Applied migration %s on site %s", m.ID, siteID)
+	}
+
+	return nil
+}
+
+func applyMigration(db *sql.DB, siteID string, m Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %s on site %s: %w", m.ID, siteID, err)
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range m.Statements(siteID) {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("migration %s failed on site %s: %w", m.ID, siteID, err)
+		}
+	}
+
+	if _, err := tx.Exec("INSERT INTO SCHEMA_MIGRATIONS (ID, Description) VALUES (?, ?)", m.ID, m.Description); err != nil {
+		return fmt.Errorf("failed to record migration %s on site %s: %w", m.ID, siteID, err)
+	}
+
+	return tx.Commit()
+}
+
+// fragmentChecks names the CHECK constraint enforcing the fragment predicate of each horizontal relation
+var fragmentChecks = map[string]string{
+	"DOCGIA":       "CHK_DocGia_MaCN",
+	"QUYENSACH":    "CHK_QuyenSach_MaCN",
+	"PHIEUMUON":    "CHK_PhieuMuon_MaCN",
+	"DATCHO":       "CHK_DatCho_MaCN",
+	"PHAT":         "CHK_Phat_MaCN",
+	"GIAODICHPHAT": "CHK_GiaoDichPhat_MaCN",
+	"YEUCAUMUON":   "CHK_YeuCauMuon_MaCN",
+	"CHUYENTRA":    "CHK_ChuyenTra_MaCN",
+}
+
+// SetFragmentValues replaces a relation's fragment CHECK constraint so the site accepts
+// rows whose fragment column is one of values (the branches whose fragments it stores)
+func SetFragmentValues(tx *sql.Tx, relation, column string, values []string) error {
+	constraint, exists := fragmentChecks[relation]
+	if !exists {
+		return fmt.Errorf("relation %s has no fragment constraint", relation)
+	}
+
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		if err := ValidateSiteID(v); err != nil {
+			return err
+		}
+		quoted[i] = "'" + v + "'"
+	}
+
+	stmts := []string{
+		fmt.Sprintf("IF OBJECT_ID('%s', 'C') IS NOT NULL ALTER TABLE %s DROP CONSTRAINT %s", constraint, relation, constraint),
+		fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s CHECK (%s IN (%s))", relation, constraint, column, strings.Join(quoted, ", ")),
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to update %s: %w", constraint, err)
+		}
+	}
+	return nil
+}
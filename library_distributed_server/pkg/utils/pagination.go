@@ -11,8 +11,9 @@ import (
 
 // PaginationParams holds pagination parameters parsed from query string
 type PaginationParams struct {
-	Page int // 0-based page number
-	Size int // Items per page
+	Page   int    // 0-based page number
+	Size   int    // Items per page
+	Cursor string // Token from the previous page's nextCursor; takes precedence over Page
 }
 
 // ParsePaginationParams parses pagination parameters from Gin context query params
@@ -42,8 +43,9 @@ func ParsePaginationParams(c *gin.Context) PaginationParams {
 	}
 
 	return PaginationParams{
-		Page: page,
-		Size: size,
+		Page:   page,
+		Size:   size,
+		Cursor: c.Query("cursor"),
 	}
 }
 
@@ -76,6 +78,13 @@ func CreateListResponse(items interface{}, pagination PaginationParams, totalCou
 	}
 }
 
+// CreateCursorListResponse creates a list response for a cursor-paginated query
+func CreateCursorListResponse(items interface{}, pagination PaginationParams, totalCount int, nextCursor string) models.ListResponse {
+	response := CreateListResponse(items, pagination, totalCount)
+	response.Paging.NextCursor = nextCursor
+	return response
+}
+
 // GetSearchTerm extracts search term from query parameters
 func GetSearchTerm(c *gin.Context) string {
 	return c.Query("search")
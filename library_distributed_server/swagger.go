@@ -51,4 +51,4 @@
 // swagger:meta
 package docs
 
-//go:generate swag init -g cmd/site-q1/main.go -o docs
+//go:generate swag init -g cmd/site/main.go -o docs/site